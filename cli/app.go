@@ -329,6 +329,7 @@ func NewApp() *App {
 		cliStorageProviders: []StorageProvider{
 			{"from-config", "the provided configuration file", func() StorageFlags { return &storageFromConfigFlags{} }},
 
+			{"archive", "an archive of exported snapshots", func() StorageFlags { return &storageArchiveFlags{} }},
 			{"azure", "an Azure blob storage", func() StorageFlags { return &storageAzureFlags{} }},
 			{"b2", "a B2 bucket", func() StorageFlags { return &storageB2Flags{} }},
 			{"filesystem", "a filesystem", func() StorageFlags { return &storageFilesystemFlags{} }},
@@ -32,6 +32,8 @@ type commandBenchmarkCompression struct {
 	operations   string
 	algorithms   string
 
+	dictionaryChunkSize int
+
 	out textOutput
 }
 
@@ -47,6 +49,7 @@ func (c *commandBenchmarkCompression) setup(svc appServices, parent commandParen
 	cmd.Flag("print-options", "Print out options usable for repository creation").BoolVar(&c.optionPrint)
 	cmd.Flag("deprecated", "Included deprecated compression algorithms").BoolVar(&c.deprecated)
 	cmd.Flag("algorithms", "Comma-separated list of algorithms to benchmark").StringVar(&c.algorithms)
+	cmd.Flag("dictionary-chunk-size", "Split data into chunks of this size and compare compression with and without trained dictionary").IntVar(&c.dictionaryChunkSize)
 	cmd.Action(svc.noRepositoryAction(c.run))
 	c.out.setup(svc)
 }
@@ -162,6 +165,83 @@ func (c *commandBenchmarkCompression) run(ctx context.Context) error {
 		}
 	}
 
+	if c.dictionaryChunkSize > 0 {
+		return c.runDictionaryComparison(ctx, data, algorithms)
+	}
+
+	return nil
+}
+
+// runDictionaryComparison splits the data into small chunks, trains a dictionary on every other
+// chunk and compares the compressed size of the remaining chunks with and without the dictionary.
+func (c *commandBenchmarkCompression) runDictionaryComparison(ctx context.Context, data []byte, algorithms map[compression.Name]compression.Compressor) error {
+	var trainChunks, testChunks [][]byte
+
+	for i := 0; len(data) > 0; i++ {
+		n := min(c.dictionaryChunkSize, len(data))
+
+		if i%2 == 0 {
+			trainChunks = append(trainChunks, data[:n])
+		} else {
+			testChunks = append(testChunks, data[:n])
+		}
+
+		data = data[n:]
+	}
+
+	d, err := compression.TrainDictionary(1, trainChunks, 0)
+	if err != nil {
+		return errors.Wrap(err, "unable to train dictionary")
+	}
+
+	log(ctx).Infof("Trained %v dictionary using %v chunks, comparing compression of %v chunks.", units.BytesString(len(d.Data)), len(trainChunks), len(testChunks))
+
+	var names []string
+
+	for name, comp := range algorithms {
+		if _, ok := comp.(compression.DictionaryCompressor); ok {
+			names = append(names, string(name))
+		}
+	}
+
+	sort.Strings(names)
+
+	c.out.printStdout("     %-30v %-14v %-14v %v\n", "Compression", "No Dictionary", "Dictionary", "Gain")
+	c.out.printStdout("------------------------------------------------------------------------------------------------\n")
+
+	var buf bytes.Buffer
+
+	for ndx, name := range names {
+		dc := algorithms[compression.Name(name)].(compression.DictionaryCompressor) //nolint:forcetypeassert
+
+		var withoutDict, withDict int
+
+		for _, chunk := range testChunks {
+			buf.Reset()
+
+			if err := dc.CompressWithDictionary(&buf, bytes.NewReader(chunk), nil); err != nil {
+				return errors.Wrapf(err, "unable to compress data using %v", name)
+			}
+
+			withoutDict += buf.Len()
+
+			buf.Reset()
+
+			if err := dc.CompressWithDictionary(&buf, bytes.NewReader(chunk), d); err != nil {
+				return errors.Wrapf(err, "unable to compress data using %v with dictionary", name)
+			}
+
+			withDict += buf.Len()
+		}
+
+		c.out.printStdout("%3d. %-30v %-14v %-14v %.1f%%\n",
+			ndx,
+			name,
+			units.BytesString(withoutDict),
+			units.BytesString(withDict),
+			100*(1-float64(withDict)/float64(withoutDict)))
+	}
+
 	return nil
 }
 
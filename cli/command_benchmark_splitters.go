@@ -2,6 +2,7 @@
 
 import (
 	"context"
+	"crypto/sha256"
 	"math"
 	"math/rand"
 	"sort"
@@ -22,6 +23,7 @@ type commandBenchmarkSplitters struct {
 	blockCount  int
 	printOption bool
 	parallel    int
+	shiftEdits  int
 
 	out textOutput
 }
@@ -34,6 +36,7 @@ func (c *commandBenchmarkSplitters) setup(svc appServices, parent commandParent)
 	cmd.Flag("block-count", "Number of data blocks to split").Default("16").IntVar(&c.blockCount)
 	cmd.Flag("print-options", "Print out the fastest dynamic splitter option").BoolVar(&c.printOption)
 	cmd.Flag("parallel", "Number of parallel goroutines").Default("1").IntVar(&c.parallel)
+	cmd.Flag("shift-edits", "Number of random insertions and deletions in each shifted data block used to measure deduplication ratio (0 to disable)").Default("8").IntVar(&c.shiftEdits)
 
 	cmd.Action(svc.noRepositoryAction(c.run))
 
@@ -53,6 +56,7 @@ type benchResult struct {
 		p90            int
 		max            int
 		bytesPerSecond int64
+		dedupRatio     float64
 	}
 
 	var results []benchResult
@@ -75,6 +79,16 @@ type benchResult struct {
 		dataBlocks = append(dataBlocks, b)
 	}
 
+	// generate copies of data blocks with small insertions and deletions, which shift the
+	// remaining data, to measure how well each splitter recovers split points.
+	var shiftedBlocks [][]byte
+
+	if c.shiftEdits > 0 {
+		for _, b := range dataBlocks {
+			shiftedBlocks = append(shiftedBlocks, shiftedCopy(rnd, b, c.shiftEdits))
+		}
+	}
+
 	log(ctx).Infof("splitting %v blocks of %v each, parallelism %v", c.blockCount, c.blockSize, c.parallel)
 
 	for _, sp := range splitter.SupportedAlgorithms() {
@@ -121,13 +135,15 @@ type benchResult struct {
 			segmentLengths[len(segmentLengths)*90/100],
 			segmentLengths[len(segmentLengths)-1],
 			int64(bytesPerSecond),
+			dedupRatio(splitter.GetFactory(sp), dataBlocks, shiftedBlocks),
 		}
 
-		c.out.printStdout("%-25v %12v/s count:%v min:%v 10th:%v 25th:%v 50th:%v 75th:%v 90th:%v max:%v\n",
+		c.out.printStdout("%-25v %12v/s count:%v min:%v 10th:%v 25th:%v 50th:%v 75th:%v 90th:%v max:%v dedup:%.3f\n",
 			r.splitter,
 			units.BytesString(r.bytesPerSecond),
 			r.segmentCount,
 			r.min, r.p10, r.p25, r.p50, r.p75, r.p90, r.max,
+			r.dedupRatio,
 		)
 
 		results = append(results, r)
@@ -139,12 +155,13 @@ type benchResult struct {
 	c.out.printStdout("-----------------------------------------------------------------\n")
 
 	for ndx, r := range results {
-		c.out.printStdout("%3v. %-25v %-12v/s count:%v min:%v 10th:%v 25th:%v 50th:%v 75th:%v 90th:%v max:%v\n",
+		c.out.printStdout("%3v. %-25v %-12v/s count:%v min:%v 10th:%v 25th:%v 50th:%v 75th:%v 90th:%v max:%v dedup:%.3f\n",
 			ndx,
 			r.splitter,
 			units.BytesString(r.bytesPerSecond),
 			r.segmentCount,
-			r.min, r.p10, r.p25, r.p50, r.p75, r.p90, r.max)
+			r.min, r.p10, r.p25, r.p50, r.p75, r.p90, r.max,
+			r.dedupRatio)
 
 		if best.duration > r.duration && !strings.HasPrefix(r.splitter, "FIXED") {
 			best = r
@@ -157,3 +174,64 @@ type benchResult struct {
 
 	return nil
 }
+
+// shiftedCopy returns a copy of the provided data with the specified number of random
+// insertions and deletions of up to 1KB each.
+func shiftedCopy(rnd *rand.Rand, data []byte, edits int) []byte {
+	const maxEditLength = 1024
+
+	result := append([]byte(nil), data...)
+
+	for range edits {
+		pos := rnd.Intn(len(result) + 1)
+		n := rnd.Intn(maxEditLength) + 1
+
+		if rnd.Intn(2) == 0 && pos+n <= len(result) {
+			result = append(result[:pos], result[pos+n:]...)
+			continue
+		}
+
+		ins := make([]byte, n)
+		rnd.Read(ins) //nolint:errcheck
+
+		result = append(result[:pos], append(ins, result[pos:]...)...)
+	}
+
+	return result
+}
+
+// dedupRatio splits original and shifted data blocks and returns the ratio of total number
+// of bytes to the number of bytes in unique segments.
+func dedupRatio(fact splitter.Factory, dataBlocks, shiftedBlocks [][]byte) float64 {
+	if len(shiftedBlocks) == 0 {
+		return 1
+	}
+
+	unique := map[[sha256.Size]byte]bool{}
+
+	var totalBytes, uniqueBytes int64
+
+	for _, d := range append(append([][]byte(nil), dataBlocks...), shiftedBlocks...) {
+		s := fact()
+
+		for len(d) > 0 {
+			n := s.NextSplitPoint(d)
+			if n < 0 {
+				n = len(d)
+			}
+
+			h := sha256.Sum256(d[:n])
+			if !unique[h] {
+				unique[h] = true
+				uniqueBytes += int64(n)
+			}
+
+			totalBytes += int64(n)
+			d = d[n:]
+		}
+
+		s.Close()
+	}
+
+	return float64(totalBytes) / float64(uniqueBytes)
+}
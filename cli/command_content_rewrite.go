@@ -16,6 +16,7 @@ type commandContentRewrite struct {
 	contentRewriteParallelism   int
 	contentRewriteShortPacks    bool
 	contentRewriteFormatVersion int
+	contentRewriteEncryption    bool
 	contentRewritePackPrefix    string
 	contentRewriteDryRun        bool
 	contentRewriteSafety        maintenance.SafetyParameters
@@ -31,6 +32,7 @@ func (c *commandContentRewrite) setup(svc appServices, parent commandParent) {
 
 	cmd.Flag("short", "Rewrite contents from short packs").BoolVar(&c.contentRewriteShortPacks)
 	cmd.Flag("format-version", "Rewrite contents using the provided format version").Default("-1").IntVar(&c.contentRewriteFormatVersion)
+	cmd.Flag("previous-encryption", "Rewrite contents encrypted using previous encryption algorithms").BoolVar(&c.contentRewriteEncryption)
 	cmd.Flag("pack-prefix", "Only rewrite contents from pack blobs with a given prefix").StringVar(&c.contentRewritePackPrefix)
 	cmd.Flag("dry-run", "Do not actually rewrite, only print what would happen").Short('n').BoolVar(&c.contentRewriteDryRun)
 	c.contentRange.setup(cmd)
@@ -49,13 +51,14 @@ func (c *commandContentRewrite) runContentRewriteCommand(ctx context.Context, re
 	}
 
 	_, err = maintenance.RewriteContents(ctx, rep, &maintenance.RewriteContentsOptions{
-		ContentIDRange: c.contentRange.contentIDRange(),
-		ContentIDs:     contentIDs,
-		FormatVersion:  c.contentRewriteFormatVersion,
-		PackPrefix:     blob.ID(c.contentRewritePackPrefix),
-		Parallel:       c.contentRewriteParallelism,
-		ShortPacks:     c.contentRewriteShortPacks,
-		DryRun:         c.contentRewriteDryRun,
+		ContentIDRange:     c.contentRange.contentIDRange(),
+		ContentIDs:         contentIDs,
+		FormatVersion:      c.contentRewriteFormatVersion,
+		PreviousEncryption: c.contentRewriteEncryption,
+		PackPrefix:         blob.ID(c.contentRewritePackPrefix),
+		Parallel:           c.contentRewriteParallelism,
+		ShortPacks:         c.contentRewriteShortPacks,
+		DryRun:             c.contentRewriteDryRun,
 	}, c.contentRewriteSafety)
 
 	return errors.Wrap(err, "error rewriting contents")
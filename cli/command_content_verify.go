@@ -11,6 +11,8 @@
 	"github.com/kopia/kopia/internal/timetrack"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/repo/maintenancestats"
 )
 
 type commandContentVerify struct {
@@ -19,6 +21,8 @@ type commandContentVerify struct {
 	contentVerifyIncludeDeleted bool
 	contentVerifyPercent        float64
 	progressInterval            time.Duration
+	scrub                       bool
+	scrubRepair                 bool
 
 	contentRange contentRangeFlags
 }
@@ -31,6 +35,8 @@ func (c *commandContentVerify) setup(svc appServices, parent commandParent) {
 	cmd.Flag("include-deleted", "Include deleted contents").BoolVar(&c.contentVerifyIncludeDeleted)
 	cmd.Flag("download-percent", "Download a percentage of files [0.0 .. 100.0]").Float64Var(&c.contentVerifyPercent)
 	cmd.Flag("progress-interval", "Progress output interval").Default("3s").DurationVar(&c.progressInterval)
+	cmd.Flag("scrub", "Read all pack blobs and detect contents that required error correction").BoolVar(&c.scrub)
+	cmd.Flag("repair", "Rewrite contents that required error correction when scrubbing").BoolVar(&c.scrubRepair)
 	c.contentRange.setup(cmd)
 	cmd.Action(svc.directRepositoryReadAction(c.run))
 }
@@ -100,6 +106,41 @@ func (c *commandContentVerify) run(ctx context.Context, rep repo.DirectRepositor
 		return errors.Wrap(err, "verify contents")
 	}
 
+	if c.scrub {
+		return scrubContents(ctx, rep, maintenance.ScrubContentsOptions{
+			Parallel:       c.contentVerifyParallel,
+			ContentIDRange: c.contentRange.contentIDRange(),
+			DryRun:         !c.scrubRepair,
+		})
+	}
+
+	return nil
+}
+
+// scrubContents scrubs contents in a separate write session, since verification otherwise only requires read access.
+func scrubContents(ctx context.Context, rep repo.DirectRepository, opt maintenance.ScrubContentsOptions) error {
+	var stats *maintenancestats.ScrubContentsStats
+
+	if err := repo.DirectWriteSession(ctx, rep, repo.WriteSessionOptions{
+		Purpose: "cli:scrub-contents",
+	}, func(ctx context.Context, dw repo.DirectRepositoryWriter) error {
+		var err error
+
+		stats, err = maintenance.ScrubContents(ctx, dw, opt)
+
+		return errors.Wrap(err, "error scrubbing contents")
+	}); err != nil {
+		return errors.Wrap(err, "scrub contents")
+	}
+
+	if stats.CorrectedContentCount > stats.RepairedContentCount {
+		log(ctx).Warnf("%v contents required error correction but were not repaired, pass --repair to rewrite them.", stats.CorrectedContentCount-stats.RepairedContentCount)
+	}
+
+	if stats.UnreadableContentCount > 0 {
+		return errors.Errorf("found %v unreadable contents", stats.UnreadableContentCount)
+	}
+
 	return nil
 }
 
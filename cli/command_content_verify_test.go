@@ -38,3 +38,19 @@ func (s *formatSpecificTestSuite) TestContentVerify(t *testing.T) {
 
 	env.RunAndExpectFailure(t, "content", "verify", "--full")
 }
+
+func TestContentVerifyScrub(t *testing.T) {
+	env := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+
+	dir := testutil.TempDirectory(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file1.txt"), bytes.Repeat([]byte{1, 2, 3, 4, 5}, 15000), 0o600))
+
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir, "--ecc-overhead-percent=10")
+	env.RunAndExpectSuccess(t, "snapshot", "create", dir)
+
+	_, stderr := env.RunAndExpectSuccessWithErrOut(t, "content", "verify", "--scrub")
+	mustGetLineContaining(t, stderr, "Found 0 contents with 0 corrupted shards")
+
+	env.RunAndExpectSuccess(t, "content", "verify", "--scrub", "--prefix=k")
+	env.RunAndExpectSuccess(t, "snapshot", "verify", "--scrub", "--repair")
+}
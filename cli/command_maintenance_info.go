@@ -74,6 +74,12 @@ func (c *commandMaintenanceInfo) run(ctx context.Context, rep repo.DirectReposit
 		c.out.printStdout("Object Lock Extension: disabled\n")
 	}
 
+	if p.ScrubContents {
+		c.out.printStdout("Content Scrubbing: enabled\n")
+	} else {
+		c.out.printStdout("Content Scrubbing: disabled\n")
+	}
+
 	if p.ListParallelism != 0 {
 		c.out.printStdout("List parallelism: %v\n", p.ListParallelism)
 	}
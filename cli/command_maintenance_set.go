@@ -25,6 +25,7 @@ type commandMaintenanceSet struct {
 	maxTotalRetainedLogSizeMB int64
 
 	extendObjectLocks []bool // optional boolean
+	scrubContents     []bool // optional boolean
 
 	listParallelism int
 }
@@ -58,6 +59,7 @@ func (c *commandMaintenanceSet) setup(svc appServices, parent commandParent) {
 	cmd.Flag("max-retained-log-age", "Set maximum age of log sessions to retain").DurationVar(&c.maxRetainedLogAge)
 	cmd.Flag("max-retained-log-size-mb", "Set maximum total size of log sessions").Int64Var(&c.maxTotalRetainedLogSizeMB)
 	cmd.Flag("extend-object-locks", "Extend retention period of locked objects as part of full maintenance.").BoolListVar(&c.extendObjectLocks)
+	cmd.Flag("scrub-contents", "Read all pack blobs and repair contents damaged by bit rot as part of full maintenance.").BoolListVar(&c.scrubContents)
 
 	cmd.Flag("list-parallelism", "Override list parallelism.").IntVar(&c.listParallelism)
 
@@ -155,6 +157,20 @@ func (c *commandMaintenanceSet) setMaintenanceObjectLockExtendFromFlags(ctx cont
 	}
 }
 
+func (c *commandMaintenanceSet) setMaintenanceScrubContentsFromFlags(ctx context.Context, p *maintenance.Params, changed *bool) {
+	if len(c.scrubContents) > 0 {
+		lastVal := c.scrubContents[len(c.scrubContents)-1]
+		p.ScrubContents = lastVal
+		*changed = true
+
+		if lastVal {
+			log(ctx).Info("Content scrubbing maintenance enabled.")
+		} else {
+			log(ctx).Info("Content scrubbing maintenance disabled.")
+		}
+	}
+}
+
 func (c *commandMaintenanceSet) run(ctx context.Context, rep repo.DirectRepositoryWriter) error {
 	p, err := maintenance.GetParams(ctx, rep)
 	if err != nil {
@@ -174,6 +190,7 @@ func (c *commandMaintenanceSet) run(ctx context.Context, rep repo.DirectReposito
 	c.setLogCleanupParametersFromFlags(ctx, p, &changedParams)
 	c.setListBlobsParallelismFromFlags(ctx, p, &changedParams)
 	c.setMaintenanceObjectLockExtendFromFlags(ctx, p, &changedParams)
+	c.setMaintenanceScrubContentsFromFlags(ctx, p, &changedParams)
 
 	if pauseDuration := c.maintenanceSetPauseQuick; pauseDuration != -1 {
 		s.NextQuickMaintenanceTime = rep.Time().Add(pauseDuration)
@@ -22,6 +22,7 @@ type commandMount struct {
 	mountFuseAllowOther         bool
 	mountFuseAllowNonEmptyMount bool
 	mountPreferWebDAV           bool
+	mountTimeMachine            bool
 	maxCachedEntries            int
 	maxCachedDirectories        int
 
@@ -39,6 +40,7 @@ func (c *commandMount) setup(svc appServices, parent commandParent) {
 	cmd.Flag("fuse-allow-other", "Allows other users to access the file system.").BoolVar(&c.mountFuseAllowOther)
 	cmd.Flag("fuse-allow-non-empty-mount", "Allows the mounting over a non-empty directory. The files in it will be shadowed by the freshly created mount.").BoolVar(&c.mountFuseAllowNonEmptyMount)
 	cmd.Flag("webdav", "Use WebDAV to mount the repository object regardless of fuse availability.").BoolVar(&c.mountPreferWebDAV)
+	cmd.Flag("time-machine", "When mounting all sources, provide 'latest', 'previous', 'by-date' and 'versions' directories for each source.").BoolVar(&c.mountTimeMachine)
 
 	cmd.Flag("max-cached-entries", "Limit the number of cached directory entries").Default("100000").IntVar(&c.maxCachedEntries)
 	cmd.Flag("max-cached-dirs", "Limit the number of cached directories").Default("100").IntVar(&c.maxCachedDirectories)
@@ -57,9 +59,17 @@ func (c *commandMount) newFSCache() cachefs.DirectoryCacher {
 func (c *commandMount) run(ctx context.Context, rep repo.Repository) error {
 	var entry fs.Directory
 
-	if c.mountObjectID == "all" {
+	switch {
+	case c.mountObjectID == "all" && c.mountTimeMachine:
+		entry = snapshotfs.TimeMachineEntry(rep)
+
+	case c.mountObjectID == "all":
 		entry = snapshotfs.AllSourcesEntry(rep)
-	} else {
+
+	case c.mountTimeMachine:
+		return errors.New("--time-machine can only be used when mounting all sources")
+
+	default:
 		var err error
 
 		entry, err = snapshotfs.FilesystemDirectoryFromIDWithPath(ctx, rep, c.mountObjectID, false)
@@ -28,6 +28,8 @@ type commandPolicySet struct {
 	policySchedulingFlags
 	policyOSSnapshotFlags
 	policyUploadFlags
+	policySourceCommandFlags
+	policyAnomalyDetectionFlags
 }
 
 func (c *commandPolicySet) setup(svc appServices, parent commandParent) {
@@ -46,6 +48,8 @@ func (c *commandPolicySet) setup(svc appServices, parent commandParent) {
 	c.policySchedulingFlags.setup(cmd)
 	c.policyOSSnapshotFlags.setup(cmd)
 	c.policyUploadFlags.setup(cmd)
+	c.policySourceCommandFlags.setup(cmd)
+	c.policyAnomalyDetectionFlags.setup(cmd)
 
 	cmd.Action(svc.repositoryWriterAction(c.run))
 }
@@ -138,6 +142,14 @@ func (c *commandPolicySet) setPolicyFromFlags(ctx context.Context, p *policy.Pol
 		return errors.Wrap(err, "upload policy")
 	}
 
+	if err := c.setSourceCommandsFromFlags(ctx, &p.SourceCommands, changeCount); err != nil {
+		return errors.Wrap(err, "source commands")
+	}
+
+	if err := c.setAnomalyDetectionPolicyFromFlags(ctx, &p.AnomalyDetection, changeCount); err != nil {
+		return errors.Wrap(err, "anomaly detection policy")
+	}
+
 	// It's not really a list, just optional boolean, last one wins.
 	for _, inherit := range c.inherit {
 		*changeCount++
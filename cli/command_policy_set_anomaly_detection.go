@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/alecthomas/kingpin/v2"
+
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+type policyAnomalyDetectionFlags struct {
+	detectAnomalies               string
+	anomalyMinFiles               string
+	anomalyModifiedFilesPercent   string
+	anomalyExtensionChurnPercent  string
+	anomalyEntropyIncreasePercent string
+	anomalyPinPreviousSnapshot    string
+}
+
+func (c *policyAnomalyDetectionFlags) setup(cmd *kingpin.CmdClause) {
+	cmd.Flag("detect-anomalies", "Detect mass changes of files compared to the previous snapshot, typical for ransomware ('true', 'false', 'inherit')").EnumVar(&c.detectAnomalies, booleanEnumValues...)
+	cmd.Flag("anomaly-min-files", "Minimum number of files found in the previous snapshot for anomaly detection").StringVar(&c.anomalyMinFiles)
+	cmd.Flag("anomaly-modified-files-percent", "Report an anomaly when at least this percentage of files was modified").StringVar(&c.anomalyModifiedFilesPercent)
+	cmd.Flag("anomaly-extension-churn-percent", "Report an anomaly when at least this percentage of files was renamed with the same extension appended").StringVar(&c.anomalyExtensionChurnPercent)
+	cmd.Flag("anomaly-entropy-increase-percent", "Report an anomaly when at least this percentage of sampled modified files became incompressible").StringVar(&c.anomalyEntropyIncreasePercent)
+	cmd.Flag("anomaly-pin-previous-snapshot", "Pin the previous snapshot when an anomaly is detected ('true', 'false', 'inherit')").EnumVar(&c.anomalyPinPreviousSnapshot, booleanEnumValues...)
+}
+
+func (c *policyAnomalyDetectionFlags) setAnomalyDetectionPolicyFromFlags(ctx context.Context, p *policy.AnomalyDetectionPolicy, changeCount *int) error {
+	if err := applyPolicyBoolPtr(ctx, "detect anomalies", &p.Enabled, c.detectAnomalies, changeCount); err != nil {
+		return err
+	}
+
+	if err := applyOptionalInt(ctx, "anomaly detection minimum files", &p.MinFiles, c.anomalyMinFiles, changeCount); err != nil {
+		return err
+	}
+
+	if err := applyOptionalInt(ctx, "modified files threshold", &p.ModifiedFilesPercent, c.anomalyModifiedFilesPercent, changeCount); err != nil {
+		return err
+	}
+
+	if err := applyOptionalInt(ctx, "extension churn threshold", &p.ExtensionChurnPercent, c.anomalyExtensionChurnPercent, changeCount); err != nil {
+		return err
+	}
+
+	if err := applyOptionalInt(ctx, "entropy increase threshold", &p.EntropyIncreasePercent, c.anomalyEntropyIncreasePercent, changeCount); err != nil {
+		return err
+	}
+
+	return applyPolicyBoolPtr(ctx, "pin previous snapshot on anomaly", &p.PinPreviousSnapshot, c.anomalyPinPreviousSnapshot, changeCount)
+}
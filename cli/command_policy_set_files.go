@@ -21,10 +21,28 @@ type policyFilesFlags struct {
 	policySetClearDotIgnore  bool
 	policySetMaxFileSize     string
 
+	// Include rules.
+	policySetAddInclude    []string
+	policySetRemoveInclude []string
+	policySetClearInclude  bool
+
+	// Respect .gitignore files.
+	policyGitIgnore string
+
+	// Attribute rules.
+	policySetAddAttributeRule    []string
+	policySetRemoveAttributeRule []string
+	policySetClearAttributeRule  bool
+
 	// Ignore other mounted filesystems.
 	policyOneFileSystem string
 
 	policyIgnoreCacheDirs string
+
+	// Archives to expand.
+	policySetAddExpandArchives    []string
+	policySetRemoveExpandArchives []string
+	policySetClearExpandArchives  bool
 }
 
 func (c *policyFilesFlags) setup(cmd *kingpin.CmdClause) {
@@ -39,10 +57,28 @@ func (c *policyFilesFlags) setup(cmd *kingpin.CmdClause) {
 	cmd.Flag("clear-dot-ignore", "Clear list of paths in the dot-ignore list").BoolVar(&c.policySetClearDotIgnore)
 	cmd.Flag("max-file-size", "Exclude files above given size").PlaceHolder("N").StringVar(&c.policySetMaxFileSize)
 
+	// Include rules.
+	cmd.Flag("add-include", "List of paths to add to the include list, when not empty only matching paths are included").PlaceHolder("PATTERN").StringsVar(&c.policySetAddInclude)
+	cmd.Flag("remove-include", "List of paths to remove from the include list").PlaceHolder("PATTERN").StringsVar(&c.policySetRemoveInclude)
+	cmd.Flag("clear-include", "Clear list of paths in the include list").BoolVar(&c.policySetClearInclude)
+
+	// Respect .gitignore files.
+	// Attribute rules.
+	cmd.Flag("add-attribute-rule", "List of attribute rules excluding files: 'older-than:DURATION', 'size:MIN-MAX', 'nodump', 'type:socket|fifo|device', 'uid:N' or 'mime:PATTERN', which reads the beginning of every file, including unchanged ones").PlaceHolder("RULE").StringsVar(&c.policySetAddAttributeRule)
+	cmd.Flag("remove-attribute-rule", "List of attribute rules to remove").PlaceHolder("RULE").StringsVar(&c.policySetRemoveAttributeRule)
+	cmd.Flag("clear-attribute-rule", "Clear list of attribute rules").BoolVar(&c.policySetClearAttributeRule)
+
+	cmd.Flag("git-ignore", "Respect .gitignore files, .git/info/exclude and the core.excludesFile of git ('true', 'false', 'inherit')").EnumVar(&c.policyGitIgnore, booleanEnumValues...)
+
 	// Ignore other mounted filesystems.
 	cmd.Flag("one-file-system", "Stay in parent filesystem when finding files ('true', 'false', 'inherit')").EnumVar(&c.policyOneFileSystem, booleanEnumValues...)
 
 	cmd.Flag("ignore-cache-dirs", "Ignore cache directories ('true', 'false', 'inherit')").EnumVar(&c.policyIgnoreCacheDirs, booleanEnumValues...)
+
+	// Archives to expand.
+	cmd.Flag("add-expand-archives", "List of file name patterns of tar and zip archives to snapshot as trees of their members").PlaceHolder("PATTERN").StringsVar(&c.policySetAddExpandArchives)
+	cmd.Flag("remove-expand-archives", "List of file name patterns to remove from the list of archives to expand").PlaceHolder("PATTERN").StringsVar(&c.policySetRemoveExpandArchives)
+	cmd.Flag("clear-expand-archives", "Clear list of archives to expand").BoolVar(&c.policySetClearExpandArchives)
 }
 
 func (c *policyFilesFlags) setFilesPolicyFromFlags(ctx context.Context, fp *policy.FilesPolicy, changeCount *int) error {
@@ -52,6 +88,13 @@ func (c *policyFilesFlags) setFilesPolicyFromFlags(ctx context.Context, fp *poli
 
 	applyPolicyStringList(ctx, "dot-ignore filenames", &fp.DotIgnoreFiles, c.policySetAddDotIgnore, c.policySetRemoveDotIgnore, c.policySetClearDotIgnore, changeCount)
 	applyPolicyStringList(ctx, "ignore rules", &fp.IgnoreRules, c.policySetAddIgnore, c.policySetRemoveIgnore, c.policySetClearIgnore, changeCount)
+	applyPolicyStringList(ctx, "include rules", &fp.IncludeRules, c.policySetAddInclude, c.policySetRemoveInclude, c.policySetClearInclude, changeCount)
+	applyPolicyStringList(ctx, "attribute rules", &fp.AttributeRules, c.policySetAddAttributeRule, c.policySetRemoveAttributeRule, c.policySetClearAttributeRule, changeCount)
+	applyPolicyStringList(ctx, "expand archives", &fp.ExpandArchives, c.policySetAddExpandArchives, c.policySetRemoveExpandArchives, c.policySetClearExpandArchives, changeCount)
+
+	if err := applyPolicyBoolPtr(ctx, "respect .gitignore files", &fp.GitIgnore, c.policyGitIgnore, changeCount); err != nil {
+		return err
+	}
 
 	if err := applyPolicyBoolPtr(ctx, "ignore cache dirs", &fp.IgnoreCacheDirectories, c.policyIgnoreCacheDirs, changeCount); err != nil {
 		return err
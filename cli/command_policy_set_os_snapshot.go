@@ -10,13 +10,31 @@
 )
 
 type policyOSSnapshotFlags struct {
-	policyEnableVolumeShadowCopy string
+	policyEnableVolumeShadowCopy      string
+	policyEnableFilesystemSnapshot    string
+	policyFilesystemSnapshotProvider  string
+	policyFilesystemSnapshotCreateCmd string
+	policyFilesystemSnapshotRemoveCmd string
 }
 
 func (c *policyOSSnapshotFlags) setup(cmd *kingpin.CmdClause) {
 	osSnapshotMode := []string{policy.OSSnapshotNeverString, policy.OSSnapshotAlwaysString, policy.OSSnapshotWhenAvailableString, inheritPolicyString}
 
 	cmd.Flag("enable-volume-shadow-copy", "Enable Volume Shadow Copy snapshots ('never', 'always', 'when-available', 'inherit')").PlaceHolder("MODE").EnumVar(&c.policyEnableVolumeShadowCopy, osSnapshotMode...)
+
+	providers := []string{
+		policy.FilesystemSnapshotProviderAuto,
+		policy.FilesystemSnapshotProviderBtrfs,
+		policy.FilesystemSnapshotProviderLVM,
+		policy.FilesystemSnapshotProviderZFS,
+		policy.FilesystemSnapshotProviderCommand,
+		inheritPolicyString,
+	}
+
+	cmd.Flag("enable-filesystem-snapshot", "Enable file system snapshots on Linux ('never', 'always', 'when-available', 'inherit')").PlaceHolder("MODE").EnumVar(&c.policyEnableFilesystemSnapshot, osSnapshotMode...)
+	cmd.Flag("filesystem-snapshot-provider", "File system snapshot provider ('auto', 'btrfs', 'lvm', 'zfs', 'command', 'inherit')").PlaceHolder("PROVIDER").EnumVar(&c.policyFilesystemSnapshotProvider, providers...)
+	cmd.Flag("filesystem-snapshot-create-command", "Command which creates a file system snapshot and prints KOPIA_SNAPSHOT_PATH=<path> ('inherit' to reset)").StringVar(&c.policyFilesystemSnapshotCreateCmd)
+	cmd.Flag("filesystem-snapshot-remove-command", "Command which removes a file system snapshot ('inherit' to reset)").StringVar(&c.policyFilesystemSnapshotRemoveCmd)
 }
 
 func (c *policyOSSnapshotFlags) setOSSnapshotPolicyFromFlags(ctx context.Context, fp *policy.OSSnapshotPolicy, changeCount *int) error {
@@ -24,9 +42,37 @@ func (c *policyOSSnapshotFlags) setOSSnapshotPolicyFromFlags(ctx context.Context
 		return errors.Wrap(err, "enable volume shadow copy")
 	}
 
+	if err := applyPolicyOSSnapshotMode(ctx, "enable file system snapshot", &fp.FilesystemSnapshot.Enable, c.policyEnableFilesystemSnapshot, changeCount); err != nil {
+		return errors.Wrap(err, "enable file system snapshot")
+	}
+
+	applyPolicyOSSnapshotString(ctx, "file system snapshot provider", &fp.FilesystemSnapshot.Provider, c.policyFilesystemSnapshotProvider, changeCount)
+	applyPolicyOSSnapshotString(ctx, "file system snapshot create command", &fp.FilesystemSnapshot.CreateCommand, c.policyFilesystemSnapshotCreateCmd, changeCount)
+	applyPolicyOSSnapshotString(ctx, "file system snapshot remove command", &fp.FilesystemSnapshot.RemoveCommand, c.policyFilesystemSnapshotRemoveCmd, changeCount)
+
 	return nil
 }
 
+func applyPolicyOSSnapshotString(ctx context.Context, desc string, val *string, str string, changeCount *int) {
+	switch str {
+	case "":
+		// not changed
+		return
+
+	case inheritPolicyString, defaultPolicyString:
+		log(ctx).Infof(" - resetting %q to a default value inherited from parent.", desc)
+
+		*val = ""
+
+	default:
+		log(ctx).Infof(" - setting %q to %q.", desc, str)
+
+		*val = str
+	}
+
+	*changeCount++
+}
+
 func applyPolicyOSSnapshotMode(ctx context.Context, desc string, val **policy.OSSnapshotMode, str string, changeCount *int) error {
 	if str == "" {
 		// not changed
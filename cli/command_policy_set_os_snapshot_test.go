@@ -47,3 +47,43 @@ func TestSetOSSnapshotPolicy(t *testing.T) {
 
 	require.Contains(t, lines, " Volume Shadow Copy: never (defined for this target)")
 }
+
+func TestSetFilesystemSnapshotPolicy(t *testing.T) {
+	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	lines := e.RunAndExpectSuccess(t, "policy", "show", "--global")
+	lines = compressSpaces(lines)
+	require.Contains(t, lines, " File system snapshot: never (defined for this target)")
+
+	e.RunAndExpectSuccess(t, "policy", "set", "--global", "--enable-filesystem-snapshot=when-available")
+
+	td := testutil.TempDirectory(t)
+
+	lines = e.RunAndExpectSuccess(t, "policy", "show", td)
+	lines = compressSpaces(lines)
+	require.Contains(t, lines, " File system snapshot: when-available inherited from (global)")
+	require.Contains(t, lines, " Provider: auto inherited from (global)")
+
+	e.RunAndExpectSuccess(t, "policy", "set", td,
+		"--filesystem-snapshot-provider=command",
+		"--filesystem-snapshot-create-command=make-snapshot",
+		"--filesystem-snapshot-remove-command=remove-snapshot")
+
+	lines = e.RunAndExpectSuccess(t, "policy", "show", td)
+	lines = compressSpaces(lines)
+	require.Contains(t, lines, " Provider: command (defined for this target)")
+	require.Contains(t, lines, " Create command: make-snapshot (defined for this target)")
+	require.Contains(t, lines, " Remove command: remove-snapshot (defined for this target)")
+
+	e.RunAndExpectSuccess(t, "policy", "set", td, "--filesystem-snapshot-provider=inherit", "--filesystem-snapshot-remove-command=inherit")
+
+	lines = e.RunAndExpectSuccess(t, "policy", "show", td)
+	lines = compressSpaces(lines)
+	require.Contains(t, lines, " Provider: auto inherited from (global)")
+	require.NotContains(t, lines, " Remove command: remove-snapshot (defined for this target)")
+
+	e.RunAndExpectFailure(t, "policy", "set", td, "--filesystem-snapshot-provider=no-such-provider")
+}
@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"context"
+	"encoding/csv"
+	"slices"
+	"strings"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+type policySourceCommandFlags struct {
+	policySetAddSourceCommand    []string
+	policySetRemoveSourceCommand []string
+	policySetClearSourceCommands bool
+}
+
+func (c *policySourceCommandFlags) setup(cmd *kingpin.CmdClause) {
+	cmd.Flag("add-source-command", "Snapshot standard output of a command as a file instead of the directory contents (FILE=COMMAND)").PlaceHolder("FILE=COMMAND").StringsVar(&c.policySetAddSourceCommand)
+	cmd.Flag("remove-source-command", "Remove source command producing the given file").PlaceHolder("FILE").StringsVar(&c.policySetRemoveSourceCommand)
+	cmd.Flag("clear-source-commands", "Remove all source commands").BoolVar(&c.policySetClearSourceCommands)
+}
+
+func (c *policySourceCommandFlags) setSourceCommandsFromFlags(ctx context.Context, p *policy.SourceCommandsPolicy, changeCount *int) error {
+	if c.policySetClearSourceCommands {
+		log(ctx).Info(" - removing all source commands")
+
+		*changeCount++
+
+		p.Commands = nil
+	}
+
+	for _, fileName := range c.policySetRemoveSourceCommand {
+		log(ctx).Infof(" - removing source command for %v", fileName)
+
+		*changeCount++
+
+		p.Commands = slices.DeleteFunc(p.Commands, func(sc policy.SourceCommand) bool {
+			return sc.FileName == fileName
+		})
+	}
+
+	for _, v := range c.policySetAddSourceCommand {
+		sc, err := parseSourceCommand(v)
+		if err != nil {
+			return err
+		}
+
+		log(ctx).Infof(" - setting source command for %v to %v", sc.FileName, quoteArguments(append([]string{sc.Command}, sc.Arguments...)...))
+
+		*changeCount++
+
+		p.Commands = append(slices.DeleteFunc(p.Commands, func(existing policy.SourceCommand) bool {
+			return existing.FileName == sc.FileName
+		}), sc)
+	}
+
+	return nil
+}
+
+// parseSourceCommand parses FILE=COMMAND, where the command is split into arguments on spaces, honoring quotes.
+func parseSourceCommand(v string) (policy.SourceCommand, error) {
+	fileName, command, ok := strings.Cut(v, "=")
+	if !ok || fileName == "" || command == "" {
+		return policy.SourceCommand{}, errors.Errorf("invalid source command %q, expected FILE=COMMAND", v)
+	}
+
+	r := csv.NewReader(strings.NewReader(command))
+	r.Comma = ' ' // space
+
+	fields, err := r.Read()
+	if err != nil {
+		return policy.SourceCommand{}, errors.Wrapf(err, "error parsing source command for %v", fileName)
+	}
+
+	return policy.SourceCommand{
+		FileName:  fileName,
+		Command:   fields[0],
+		Arguments: fields[1:],
+	}, nil
+}
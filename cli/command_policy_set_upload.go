@@ -12,12 +12,16 @@ type policyUploadFlags struct {
 	maxParallelUploads            string
 	maxParallelFileReads          string
 	parallelizeUploadAboveSizeMiB string
+	buildSearchIndex              string
+	detectMovedFiles              string
 }
 
 func (c *policyUploadFlags) setup(cmd *kingpin.CmdClause) {
 	cmd.Flag("max-parallel-file-reads", "Maximum number of parallel file reads").StringVar(&c.maxParallelFileReads)
 	cmd.Flag("max-parallel-snapshots", "Maximum number of parallel snapshots (server, KopiaUI only)").StringVar(&c.maxParallelUploads)
 	cmd.Flag("parallel-upload-above-size-mib", "Use parallel uploads above size").StringVar(&c.parallelizeUploadAboveSizeMiB)
+	cmd.Flag("build-search-index", "Build filename search index after each snapshot ('true', 'false', 'inherit')").EnumVar(&c.buildSearchIndex, booleanEnumValues...)
+	cmd.Flag("detect-moved-files", "Reuse files moved or renamed since the previous snapshot without hashing them ('true', 'false', 'inherit')").EnumVar(&c.detectMovedFiles, booleanEnumValues...)
 }
 
 func (c *policyUploadFlags) setUploadPolicyFromFlags(ctx context.Context, up *policy.UploadPolicy, changeCount *int) error {
@@ -29,5 +33,13 @@ func (c *policyUploadFlags) setUploadPolicyFromFlags(ctx context.Context, up *po
 		return err
 	}
 
-	return applyOptionalInt64MiB(ctx, "parallel upload above size", &up.ParallelUploadAboveSize, c.parallelizeUploadAboveSizeMiB, changeCount)
+	if err := applyOptionalInt64MiB(ctx, "parallel upload above size", &up.ParallelUploadAboveSize, c.parallelizeUploadAboveSizeMiB, changeCount); err != nil {
+		return err
+	}
+
+	if err := applyPolicyBoolPtr(ctx, "build search index", &up.BuildSearchIndex, c.buildSearchIndex, changeCount); err != nil {
+		return err
+	}
+
+	return applyPolicyBoolPtr(ctx, "detect moved files", &up.DetectMovedFiles, c.detectMovedFiles, changeCount)
 }
@@ -114,6 +114,7 @@ func definitionPointToString(target, src snapshot.SourceInfo) string {
 func printPolicy(out *textOutput, p *policy.Policy, def *policy.Definition) {
 	var rows []policyTableRow
 
+	rows = appendSourceCommandsPolicyRows(rows, p)
 	rows = appendRetentionPolicyRows(rows, p, def)
 	rows = append(rows, policyTableRow{})
 	rows = appendFilesPolicyValue(rows, p, def)
@@ -124,6 +125,8 @@ func printPolicy(out *textOutput, p *policy.Policy, def *policy.Definition) {
 	rows = append(rows, policyTableRow{})
 	rows = appendUploadPolicyRows(rows, p, def)
 	rows = append(rows, policyTableRow{})
+	rows = appendAnomalyDetectionPolicyRows(rows, p, def)
+	rows = append(rows, policyTableRow{})
 	rows = appendCompressionPolicyRows(rows, p, def)
 	rows = append(rows, policyTableRow{})
 	rows = appendMetadataCompressionPolicyRows(rows, p, def)
@@ -184,6 +187,21 @@ func appendFilesPolicyValue(items []policyTableRow, p *policy.Policy, def *polic
 		items = append(items, policyTableRow{"  No ignore rules:", "", ""})
 	}
 
+	if len(p.FilesPolicy.IncludeRules) > 0 {
+		items = append(items, policyTableRow{
+			"  Include only:", "", definitionPointToString(p.Target(), def.FilesPolicy.IncludeRules),
+		})
+		for _, rule := range p.FilesPolicy.IncludeRules {
+			items = append(items, policyTableRow{"    " + rule, "", ""})
+		}
+	}
+
+	items = append(items, policyTableRow{
+		"  Respect .gitignore files:",
+		boolToString(p.FilesPolicy.GitIgnore.OrDefault(false)),
+		definitionPointToString(p.Target(), def.FilesPolicy.GitIgnore),
+	})
+
 	if len(p.FilesPolicy.DotIgnoreFiles) > 0 {
 		items = append(items, policyTableRow{
 			"  Read ignore rules from files:", "",
@@ -195,6 +213,15 @@ func appendFilesPolicyValue(items []policyTableRow, p *policy.Policy, def *polic
 		}
 	}
 
+	if len(p.FilesPolicy.AttributeRules) > 0 {
+		items = append(items, policyTableRow{
+			"  Ignore files matching attribute rules:", "", definitionPointToString(p.Target(), def.FilesPolicy.AttributeRules),
+		})
+		for _, rule := range p.FilesPolicy.AttributeRules {
+			items = append(items, policyTableRow{"    " + rule, "", ""})
+		}
+	}
+
 	if maxSize := p.FilesPolicy.MaxFileSize; maxSize > 0 {
 		items = append(items, policyTableRow{
 			"  Ignore files above:",
@@ -209,6 +236,17 @@ func appendFilesPolicyValue(items []policyTableRow, p *policy.Policy, def *polic
 		definitionPointToString(p.Target(), def.FilesPolicy.OneFileSystem),
 	})
 
+	if len(p.FilesPolicy.ExpandArchives) > 0 {
+		items = append(items, policyTableRow{
+			"  Expand archives:", "",
+			definitionPointToString(p.Target(), def.FilesPolicy.ExpandArchives),
+		})
+
+		for _, pattern := range p.FilesPolicy.ExpandArchives {
+			items = append(items, policyTableRow{"    " + pattern, "", ""})
+		}
+	}
+
 	return items
 }
 
@@ -277,6 +315,22 @@ func appendUploadPolicyRows(rows []policyTableRow, p *policy.Policy, def *policy
 		policyTableRow{"  Max parallel snapshots (server/UI):", valueOrNotSet(p.UploadPolicy.MaxParallelSnapshots), definitionPointToString(p.Target(), def.UploadPolicy.MaxParallelSnapshots)},
 		policyTableRow{"  Max parallel file reads:", valueOrNotSet(p.UploadPolicy.MaxParallelFileReads), definitionPointToString(p.Target(), def.UploadPolicy.MaxParallelFileReads)},
 		policyTableRow{"  Parallel upload above size:", valueOrNotSetOptionalInt64Bytes(p.UploadPolicy.ParallelUploadAboveSize), definitionPointToString(p.Target(), def.UploadPolicy.ParallelUploadAboveSize)},
+		policyTableRow{"  Build search index:", boolToString(p.UploadPolicy.BuildSearchIndex.OrDefault(false)), definitionPointToString(p.Target(), def.UploadPolicy.BuildSearchIndex)},
+		policyTableRow{"  Detect moved files:", boolToString(p.UploadPolicy.DetectMovedFiles.OrDefault(false)), definitionPointToString(p.Target(), def.UploadPolicy.DetectMovedFiles)},
+	)
+}
+
+func appendAnomalyDetectionPolicyRows(rows []policyTableRow, p *policy.Policy, def *policy.Definition) []policyTableRow {
+	a := p.AnomalyDetection
+
+	return append(rows,
+		policyTableRow{"Anomaly detection:", "", ""},
+		policyTableRow{"  Detect anomalies:", boolToString(a.Enabled.OrDefault(false)), definitionPointToString(p.Target(), def.AnomalyDetection.Enabled)},
+		policyTableRow{"  Minimum files:", fmt.Sprintf("%v", a.MinFiles.OrDefault(policy.DefaultAnomalyMinFiles)), definitionPointToString(p.Target(), def.AnomalyDetection.MinFiles)},
+		policyTableRow{"  Modified files threshold:", fmt.Sprintf("%v%%", a.ModifiedFilesPercent.OrDefault(policy.DefaultAnomalyModifiedFilesPercent)), definitionPointToString(p.Target(), def.AnomalyDetection.ModifiedFilesPercent)},
+		policyTableRow{"  Extension churn threshold:", fmt.Sprintf("%v%%", a.ExtensionChurnPercent.OrDefault(policy.DefaultAnomalyExtensionChurnPercent)), definitionPointToString(p.Target(), def.AnomalyDetection.ExtensionChurnPercent)},
+		policyTableRow{"  Entropy increase threshold:", fmt.Sprintf("%v%%", a.EntropyIncreasePercent.OrDefault(policy.DefaultAnomalyEntropyIncreasePercent)), definitionPointToString(p.Target(), def.AnomalyDetection.EntropyIncreasePercent)},
+		policyTableRow{"  Pin previous snapshot:", boolToString(a.PinPreviousSnapshot.OrDefault(false)), definitionPointToString(p.Target(), def.AnomalyDetection.PinPreviousSnapshot)},
 	)
 }
 
@@ -457,6 +511,20 @@ func appendActionsPolicyRows(rows []policyTableRow, p *policy.Policy, def *polic
 	return rows
 }
 
+func appendSourceCommandsPolicyRows(rows []policyTableRow, p *policy.Policy) []policyTableRow {
+	if !p.SourceCommands.IsCommandSource() {
+		return rows
+	}
+
+	rows = append(rows, policyTableRow{"Snapshot output of commands:", "", "(non-inheritable)"})
+
+	for _, c := range p.SourceCommands.Commands {
+		rows = append(rows, policyTableRow{"  " + c.FileName + ":", quoteArguments(append([]string{c.Command}, c.Arguments...)...), ""})
+	}
+
+	return append(rows, policyTableRow{})
+}
+
 func appendActionCommandRows(rows []policyTableRow, h *policy.ActionCommand) []policyTableRow {
 	if h.Script != "" {
 		rows = append(rows,
@@ -491,8 +559,37 @@ func appendOSSnapshotPolicyRows(rows []policyTableRow, p *policy.Policy, def *po
 			p.OSSnapshotPolicy.VolumeShadowCopy.Enable.OrDefault(policy.OSSnapshotNever).String(),
 			definitionPointToString(p.Target(), def.OSSnapshotPolicy.VolumeShadowCopy.Enable),
 		},
+		policyTableRow{
+			"  File system snapshot:",
+			p.OSSnapshotPolicy.FilesystemSnapshot.Enable.OrDefault(policy.OSSnapshotNever).String(),
+			definitionPointToString(p.Target(), def.OSSnapshotPolicy.FilesystemSnapshot.Enable),
+		},
 	)
 
+	if fsp := &p.OSSnapshotPolicy.FilesystemSnapshot; fsp.Enable.OrDefault(policy.OSSnapshotNever) != policy.OSSnapshotNever {
+		rows = append(rows, policyTableRow{
+			"    Provider:",
+			fsp.ProviderOrDefault(),
+			definitionPointToString(p.Target(), def.OSSnapshotPolicy.FilesystemSnapshot.Provider),
+		})
+
+		if fsp.CreateCommand != "" {
+			rows = append(rows, policyTableRow{
+				"    Create command:",
+				fsp.CreateCommand,
+				definitionPointToString(p.Target(), def.OSSnapshotPolicy.FilesystemSnapshot.CreateCommand),
+			})
+		}
+
+		if fsp.RemoveCommand != "" {
+			rows = append(rows, policyTableRow{
+				"    Remove command:",
+				fsp.RemoveCommand,
+				definitionPointToString(p.Target(), def.OSSnapshotPolicy.FilesystemSnapshot.RemoveCommand),
+			})
+		}
+	}
+
 	return rows
 }
 
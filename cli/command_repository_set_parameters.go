@@ -11,6 +11,7 @@
 	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/encryption"
 	"github.com/kopia/kopia/repo/format"
 	"github.com/kopia/kopia/repo/maintenance"
 )
@@ -31,6 +32,10 @@ type commandRepositorySetParameters struct {
 
 	upgradeRepositoryFormat bool
 
+	enableCompressionDictionaries bool
+
+	encryption string
+
 	addRequiredFeature           string
 	removeRequiredFeature        string
 	warnOnMissingRequiredFeature bool
@@ -47,6 +52,8 @@ func (c *commandRepositorySetParameters) setup(svc appServices, parent commandPa
 	cmd.Flag("retention-period", "Set the blob retention-period for supported storage backends.").DurationVar(&c.retentionPeriod)
 
 	cmd.Flag("upgrade", "Upgrade repository to the latest stable format").BoolVar(&c.upgradeRepositoryFormat)
+	cmd.Flag("encryption", "Change encryption algorithm of new contents, existing contents can be migrated using 'content rewrite --previous-encryption'").EnumVar(&c.encryption, encryption.SupportedAlgorithms(false)...)
+	cmd.Flag("enable-compression-dictionaries", "Allow dictionary compressors, older clients will no longer be able to open the repository").BoolVar(&c.enableCompressionDictionaries)
 
 	cmd.Flag("epoch-refresh-frequency", "Epoch refresh frequency").DurationVar(&c.epochRefreshFrequency)
 	cmd.Flag("epoch-min-duration", "Minimal duration of a single epoch").DurationVar(&c.epochMinDuration)
@@ -216,6 +223,24 @@ func (c *commandRepositorySetParameters) run(ctx context.Context, rep repo.Direc
 
 	requiredFeatures = c.addRemoveUpdateRequiredFeatures(requiredFeatures, &anyChange)
 
+	if c.enableCompressionDictionaries && !feature.IsRequired(requiredFeatures, format.FeatureCompressionDictionaries) {
+		requiredFeatures = append(requiredFeatures, feature.Required{
+			Feature: format.FeatureCompressionDictionaries,
+			IfNotUnderstood: feature.IfNotUnderstood{
+				Message: "The repository contains contents compressed using trained dictionaries.",
+			},
+		})
+		anyChange = true
+
+		log(ctx).Info(" - enabling compression dictionaries.\n")
+	}
+
+	if c.encryption != "" {
+		log(ctx).Infof(" - changing encryption algorithm to %v.\n", c.encryption)
+
+		anyChange = true
+	}
+
 	if !anyChange {
 		log(ctx).Info("no changes")
 		return nil
@@ -236,6 +261,14 @@ func (c *commandRepositorySetParameters) run(ctx context.Context, rep repo.Direc
 		return errors.Wrap(err, "error updating repository parameters")
 	}
 
+	if c.encryption != "" {
+		if err := rep.FormatManager().ChangeEncryption(ctx, c.encryption); err != nil {
+			return errors.Wrap(err, "unable to change encryption")
+		}
+
+		log(ctx).Info("Contents encrypted using previous algorithms can be migrated using 'kopia content rewrite --previous-encryption'.")
+	}
+
 	log(ctx).Info("NOTE: Repository parameters updated, you must disconnect and re-connect all other Kopia clients.")
 
 	return nil
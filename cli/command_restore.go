@@ -129,6 +129,18 @@ type commandRestore struct {
 	restoreShallowAtDepth         int32
 	minSizeForPlaceholder         int32
 	snapshotTime                  string
+	packOrderedReads              bool
+	spoolDirectory                string
+	maxSpoolSizeMB                int64
+	includePatterns               []string
+	excludePatterns               []string
+	minFileSize                   int64
+	maxFileSize                   int64
+	modifiedAfter                 string
+	modifiedBefore                string
+	pathMappings                  []string
+	resumable                     bool
+	extractArchives               bool
 
 	restores []restoreSourceTarget
 
@@ -160,6 +172,18 @@ func (c *commandRestore) setup(svc appServices, parent commandParent) {
 	cmd.Flag("shallow-minsize", "When doing a shallow restore, write actual files instead of placeholders smaller than this size.").Int32Var(&c.minSizeForPlaceholder)
 	cmd.Flag("snapshot-time", "When using a path as the source, use the latest snapshot available before this date. Default is latest").Default("latest").StringVar(&c.snapshotTime)
 	cmd.Flag("flush-files", "Specifies whether or not to flush files after restore completes").Default("false").BoolVar(&c.flushFiles)
+	cmd.Flag("pack-ordered-reads", "Fetch file contents in pack order, reading each pack once into a local spool (requires direct repository connection)").BoolVar(&c.packOrderedReads)
+	cmd.Flag("spool-dir", "Directory for the local spool used by pack-ordered reads (default is system temporary directory)").StringVar(&c.spoolDirectory)
+	cmd.Flag("max-spool-size-mb", "Maximum size of the local spool used by pack-ordered reads").PlaceHolder("MB").Default("1000").Int64Var(&c.maxSpoolSizeMB)
+	cmd.Flag("include", "Only restore files matching the provided pattern (can be specified multiple times)").StringsVar(&c.includePatterns)
+	cmd.Flag("exclude", "Do not restore files and directories matching the provided pattern (can be specified multiple times)").StringsVar(&c.excludePatterns)
+	cmd.Flag("min-size", "Only restore files of at least the provided size in bytes").Int64Var(&c.minFileSize)
+	cmd.Flag("max-size", "Only restore files of at most the provided size in bytes").Int64Var(&c.maxFileSize)
+	cmd.Flag("modified-after", "Only restore files modified after the end of the provided date or period").StringVar(&c.modifiedAfter)
+	cmd.Flag("modified-before", "Only restore files modified before the start of the provided date or period").StringVar(&c.modifiedBefore)
+	cmd.Flag("resumable", "Record completed files in a journal next to the target directory, so that interrupted restore can be resumed by running it again").BoolVar(&c.resumable)
+	cmd.Flag("extract-archives", "Restore archives expanded during snapshot as directories of their members instead of their original files").BoolVar(&c.extractArchives)
+	cmd.Flag("map", "Restore the provided path inside the snapshot to a different path inside the target, only restoring mapped paths (can be specified multiple times)").PlaceHolder("SOURCE=TARGET").StringsVar(&c.pathMappings)
 	cmd.Action(svc.repositoryReaderAction(c.run))
 }
 
@@ -339,12 +363,16 @@ func (c *commandRestore) detectRestoreMode(ctx context.Context, m, targetpath st
 }
 
 func printRestoreStats(ctx context.Context, st *restore.Stats) {
-	var maybeSkipped, maybeDeletedDirs, maybeDeletedFiles, maybeDeletedSymlinks, maybeErrors string
+	var maybeSkipped, maybeResumed, maybeDeletedDirs, maybeDeletedFiles, maybeDeletedSymlinks, maybeErrors string
 
 	if st.SkippedCount > 0 {
 		maybeSkipped = fmt.Sprintf(", skipped %v (%v)", st.SkippedCount, units.BytesString(st.SkippedTotalFileSize))
 	}
 
+	if st.ResumedFileCount > 0 || st.RedoneFileCount > 0 {
+		maybeResumed = fmt.Sprintf(", resumed %v (%v), redone %v", st.ResumedFileCount, units.BytesString(st.ResumedTotalFileSize), st.RedoneFileCount)
+	}
+
 	if st.DeletedDirCount > 0 {
 		maybeDeletedDirs = fmt.Sprintf(", deleted directories %v", st.DeletedDirCount)
 	}
@@ -361,12 +389,12 @@ func printRestoreStats(ctx context.Context, st *restore.Stats) {
 		maybeErrors = fmt.Sprintf(", ignored %v errors", st.IgnoredErrorCount)
 	}
 
-	log(ctx).Infof("Restored %v files, %v directories and %v symbolic links (%v)%v%v%v%v%v.\n",
+	log(ctx).Infof("Restored %v files, %v directories and %v symbolic links (%v)%v%v%v%v%v%v.\n",
 		st.RestoredFileCount,
 		st.RestoredDirCount,
 		st.RestoredSymlinkCount,
 		units.BytesString(st.RestoredTotalFileSize),
-		maybeSkipped, maybeDeletedDirs, maybeDeletedFiles, maybeDeletedSymlinks, maybeErrors)
+		maybeSkipped, maybeResumed, maybeDeletedDirs, maybeDeletedFiles, maybeDeletedSymlinks, maybeErrors)
 }
 
 func (c *commandRestore) setupPlaceholderExpansion(ctx context.Context, rep repo.Repository, rstp restoreSourceTarget, output restore.Output) (fs.Entry, error) {
@@ -440,15 +468,26 @@ func (c *commandRestore) run(ctx context.Context, rep repo.Repository) error {
 			restoreProgress.SetCounters(stats)
 		}
 
-		st, err := restore.Entry(ctx, rep, output, rootEntry, restore.Options{
+		opt := restore.Options{
 			Parallel:               c.restoreParallel,
 			Incremental:            c.restoreIncremental,
 			DeleteExtra:            c.restoreDeleteExtra,
 			IgnoreErrors:           c.restoreIgnoreErrors,
 			RestoreDirEntryAtDepth: c.restoreShallowAtDepth,
 			MinSizeForPlaceholder:  c.minSizeForPlaceholder,
+			PackOrderedReads:       c.packOrderedReads,
+			SpoolDirectory:         c.spoolDirectory,
+			MaxSpoolSize:           c.maxSpoolSizeMB * 1e6, // convert MB to bytes
+			Resumable:              c.resumable,
+			ExtractArchives:        c.extractArchives,
 			ProgressCallback:       progressCallback,
-		})
+		}
+
+		if err := c.setupFilters(&opt); err != nil {
+			return err
+		}
+
+		st, err := restore.Entry(ctx, rep, output, rootEntry, opt)
 		if err != nil {
 			return errors.Wrap(err, "error restoring")
 		}
@@ -461,6 +500,44 @@ func (c *commandRestore) run(ctx context.Context, rep repo.Repository) error {
 	return nil
 }
 
+// setupFilters sets the options selecting which entries are restored and where.
+func (c *commandRestore) setupFilters(opt *restore.Options) error {
+	opt.IncludePatterns = c.includePatterns
+	opt.ExcludePatterns = c.excludePatterns
+	opt.MinFileSize = c.minFileSize
+	opt.MaxFileSize = c.maxFileSize
+
+	if c.modifiedAfter != "" {
+		t, err := computeMaxTime(c.modifiedAfter)
+		if err != nil {
+			return errors.Wrap(err, "invalid --modified-after")
+		}
+
+		opt.ModifiedAfter = t
+	}
+
+	if c.modifiedBefore != "" {
+		// files modified during the provided period are not restored.
+		t, err := computeMinTime(c.modifiedBefore)
+		if err != nil {
+			return errors.Wrap(err, "invalid --modified-before")
+		}
+
+		opt.ModifiedBefore = t
+	}
+
+	for _, m := range c.pathMappings {
+		src, dst, ok := strings.Cut(m, "=")
+		if !ok {
+			return errors.Errorf("invalid path mapping %q, expected SOURCE=TARGET", m)
+		}
+
+		opt.PathMappings = append(opt.PathMappings, restore.PathMapping{Source: src, Target: dst})
+	}
+
+	return nil
+}
+
 // tryToConvertPathToID checks if the source is a path and in this case returns the ID of the snapshot
 // containing the latest version available.
 func (c *commandRestore) tryToConvertPathToID(ctx context.Context, rep repo.Repository, source string) (string, error) {
@@ -494,20 +571,15 @@ func (c *commandRestore) tryToConvertPathToID(ctx context.Context, rep repo.Repo
 		return "", errors.New("the source must contain a path element")
 	}
 
-	manifestIDs, err := findSnapshotsForSource(ctx, rep, si, map[string]string{})
+	ms, err := findSnapshotsForSource(ctx, rep, si, nil)
 	if err != nil {
 		return "", err
 	}
 
-	if len(manifestIDs) == 0 {
+	if len(ms) == 0 {
 		return "", errors.Errorf("no snapshots contain data for %v", source)
 	}
 
-	ms, err := snapshot.LoadSnapshots(ctx, rep, manifestIDs)
-	if err != nil {
-		return "", errors.Wrap(err, "unable to load snapshots")
-	}
-
 	m, relPath, ohid := findLastManifestWithPath(ctx, rep, ms, si.Path, filter)
 	if m == nil {
 		return "", errors.Errorf("no snapshots contain data for %v", source)
@@ -549,31 +621,46 @@ func createSnapshotTimeFilter(timespec string) (func(*snapshot.Manifest, int, in
 
 // computeMaxTime returns the first time after the max allowed.
 func computeMaxTime(timespec string) (time.Time, error) {
+	_, end, err := computeTimeRange(timespec)
+
+	return end, err
+}
+
+// computeMinTime returns the first time of the period described by the timespec.
+func computeMinTime(timespec string) (time.Time, error) {
+	start, _, err := computeTimeRange(timespec)
+
+	return start, err
+}
+
+// computeTimeRange returns the start and the first time after the end of the period described by the timespec.
+func computeTimeRange(timespec string) (start, end time.Time, err error) {
 	now := clock.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
 
 	if timespec == "yesterday" {
-		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local), nil
+		return today.AddDate(0, 0, -1), today, nil
 	}
 
 	if timespec == "last-month" {
-		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local), nil
+		t := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+		return t.AddDate(0, -1, 0), t, nil
 	}
 
 	if timespec == "last-year" {
-		return time.Date(now.Year(), 1, 1, 0, 0, 0, 0, time.Local), nil
+		t := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, time.Local)
+		return t.AddDate(-1, 0, 0), t, nil
 	}
 
 	if strings.HasSuffix(timespec, "-ago") {
 		ymd := timeAgoRE.FindStringSubmatch(timespec)
 		if ymd != nil {
-			t := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
-
 			years, _ := strconv.Atoi(ymd[1])
 			months, _ := strconv.Atoi(ymd[2])
 			days, _ := strconv.Atoi(ymd[3])
 
 			// +1 to compute end time of current day
-			return t.AddDate(-years, -months, -days+1), nil
+			return today.AddDate(-years, -months, -days), today.AddDate(-years, -months, -days+1), nil
 		}
 	}
 
@@ -621,19 +708,17 @@ func computeMaxTime(timespec string) (time.Time, error) {
 
 		switch f.precision {
 		case year:
-			t = t.AddDate(1, 0, 0)
+			return t, t.AddDate(1, 0, 0), nil
 		case month:
-			t = t.AddDate(0, 1, 0)
+			return t, t.AddDate(0, 1, 0), nil
 		case day:
-			t = t.AddDate(0, 0, 1)
+			return t, t.AddDate(0, 0, 1), nil
 		default:
-			t = t.Add(f.precision)
+			return t, t.Add(f.precision), nil
 		}
-
-		return t, nil
 	}
 
-	return now, errors.Errorf("Invalid time spec: %v", timespec)
+	return now, now, errors.Errorf("Invalid time spec: %v", timespec)
 }
 
 func findLastManifestWithPath(ctx context.Context, rep repo.Repository, ms []*snapshot.Manifest, path string, filter func(*snapshot.Manifest, int, int) bool) (*snapshot.Manifest, string, object.ID) {
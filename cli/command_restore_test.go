@@ -7,6 +7,7 @@
 	"github.com/stretchr/testify/require"
 
 	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/snapshot/restore"
 )
 
 func TestRestoreSnapshotMaxTime(t *testing.T) {
@@ -49,6 +50,50 @@ func TestRestoreSnapshotMaxTime(t *testing.T) {
 	requireTime(at(2019, 1, 1, 13, 1, 16), "2019-01-1 13:01:15")
 }
 
+func TestRestoreSnapshotMinTime(t *testing.T) {
+	t.Parallel()
+
+	now := clock.Now()
+	ago := func(y, m, d int) time.Time {
+		r := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		return r.AddDate(y, m, d)
+	}
+	at := func(y, mo, d, h, m, s int) time.Time {
+		return time.Date(y, time.Month(mo), d, h, m, s, 0, now.Location())
+	}
+
+	requireTime := func(expected time.Time, timespec string) {
+		mt, err := computeMinTime(timespec)
+		require.NoError(t, err)
+		require.Equal(t, expected, mt)
+	}
+
+	requireTime(ago(0, 0, -1), "yesterday")
+	requireTime(ago(0, 0, -1), "1d-ago")
+	requireTime(at(now.Year(), int(now.Month())-1, 1, 0, 0, 0), "last-month")
+	requireTime(at(now.Year()-1, 1, 1, 0, 0, 0), "last-year")
+	requireTime(ago(-2, -2, -2), "2y-2m-2d-ago")
+
+	requireTime(at(2019, 1, 1, 0, 0, 0), "2019")
+	requireTime(at(2019, 1, 1, 0, 0, 0), "2019-1")
+	requireTime(at(2019, 1, 1, 0, 0, 0), "2019-01-1")
+	requireTime(at(2019, 1, 1, 13, 1, 15), "2019-01-1 13:01:15")
+}
+
+func TestRestoreModifiedFilters(t *testing.T) {
+	t.Parallel()
+
+	c := &commandRestore{modifiedAfter: "2024-02-28", modifiedBefore: "2024-03-01"}
+
+	var opt restore.Options
+
+	require.NoError(t, c.setupFilters(&opt))
+
+	// files modified on the day provided to --modified-before are not restored.
+	require.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local), opt.ModifiedBefore)
+	require.Equal(t, time.Date(2024, 2, 29, 0, 0, 0, 0, time.Local), opt.ModifiedAfter)
+}
+
 func TestRestoreSnapshotFilter(t *testing.T) {
 	f, err := createSnapshotTimeFilter("latest")
 	require.NoError(t, err)
@@ -65,6 +65,7 @@ type commandServerStart struct {
 	serverStartTLSPrintFullServerCert   bool
 	uiTitlePrefix                       string
 	uiPreferencesFile                   string
+	pendingRestoresFile                 string
 	asyncRepoConnect                    bool
 	persistentLogs                      bool
 	debugScheduler                      bool
@@ -120,6 +121,7 @@ func (c *commandServerStart) setup(svc advancedAppServices, parent commandParent
 	cmd.Flag("persistent-logs", "Persist logs in a file").Default("true").BoolVar(&c.persistentLogs)
 	cmd.Flag("ui-title-prefix", "UI title prefix").Hidden().Envar(svc.EnvName("KOPIA_UI_TITLE_PREFIX")).StringVar(&c.uiTitlePrefix)
 	cmd.Flag("ui-preferences-file", "Path to JSON file storing UI preferences").StringVar(&c.uiPreferencesFile)
+	cmd.Flag("pending-restores-file", "Path to JSON file storing resumable restores to be resumed after restart, their journals are stored next to it").StringVar(&c.pendingRestoresFile)
 
 	cmd.Flag("log-server-requests", "Log server requests").Hidden().BoolVar(&c.logServerRequests)
 	cmd.Flag("disable-csrf-token-checks", "Disable CSRF token").Hidden().BoolVar(&c.disableCSRFTokenChecks)
@@ -147,6 +149,11 @@ func (c *commandServerStart) serverStartOptions(ctx context.Context) (*server.Op
 		uiPreferencesFile = filepath.Join(filepath.Dir(c.svc.repositoryConfigFileName()), "ui-preferences.json")
 	}
 
+	pendingRestoresFile := c.pendingRestoresFile
+	if pendingRestoresFile == "" {
+		pendingRestoresFile = filepath.Join(filepath.Dir(c.svc.repositoryConfigFileName()), "pending-restores.json")
+	}
+
 	return &server.Options{
 		ConfigFile:           c.svc.repositoryConfigFileName(),
 		ConnectOptions:       c.co.toRepoConnectOptions(),
@@ -160,6 +167,7 @@ func (c *commandServerStart) serverStartOptions(ctx context.Context) (*server.Op
 		LogRequests:          c.logServerRequests,
 		PasswordPersist:      c.svc.passwordPersistenceStrategy(),
 		UIPreferencesFile:    uiPreferencesFile,
+		PendingRestoresFile:  pendingRestoresFile,
 		UITitlePrefix:        c.uiTitlePrefix,
 		PersistentLogs:       c.persistentLogs,
 
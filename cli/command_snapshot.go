@@ -1,13 +1,19 @@
 package cli
 
 type commandSnapshot struct {
+	copy        commandSnapshotCopy
 	copyHistory commandSnapshotCopyMoveHistory
 	moveHistory commandSnapshotCopyMoveHistory
 	create      commandSnapshotCreate
 	delete      commandSnapshotDelete
 	estimate    commandSnapshotEstimate
+	export      commandSnapshotExport
 	expire      commandSnapshotExpire
+	find        commandSnapshotFind
 	fix         commandSnapshotFix
+	history     commandSnapshotHistory
+	importCmd   commandSnapshotImport
+	index       commandSnapshotIndex
 	list        commandSnapshotList
 	migrate     commandSnapshotMigrate
 	pin         commandSnapshotPin
@@ -17,13 +23,19 @@ type commandSnapshot struct {
 
 func (c *commandSnapshot) setup(svc advancedAppServices, parent commandParent) {
 	cmd := parent.Command("snapshot", "Commands to manipulate snapshots.").Alias("snap")
+	c.copy.setup(svc, cmd)
 	c.copyHistory.setup(svc, cmd, false)
 	c.moveHistory.setup(svc, cmd, true)
 	c.create.setup(svc, cmd)
 	c.delete.setup(svc, cmd)
 	c.estimate.setup(svc, cmd)
+	c.export.setup(svc, cmd)
 	c.expire.setup(svc, cmd)
+	c.find.setup(svc, cmd)
 	c.fix.setup(svc, cmd)
+	c.history.setup(svc, cmd)
+	c.importCmd.setup(svc, cmd)
+	c.index.setup(svc, cmd)
 	c.list.setup(svc, cmd)
 	c.migrate.setup(svc, cmd)
 	c.pin.setup(svc, cmd)
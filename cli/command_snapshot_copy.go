@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot/snapshotcopy"
+)
+
+type commandSnapshotCopy struct {
+	copyTargetConfig         string
+	copySelection            snapshotSelection
+	copyParallel             int
+	copyCheckpointIntervalMB int64
+
+	svc advancedAppServices
+	out textOutput
+}
+
+func (c *commandSnapshotCopy) setup(svc advancedAppServices, parent commandParent) {
+	cmd := parent.Command("copy", "Copy snapshots to another repository transferring the contents they reference")
+	cmd.Flag("to-repo", "Configuration file for the destination repository").Required().ExistingFileVar(&c.copyTargetConfig)
+	cmd.Flag("parallel", "Number of files copied in parallel").Default("8").IntVar(&c.copyParallel)
+	cmd.Flag("checkpoint-interval-mb", "Flush the destination repository after copying this many megabytes").Default("1000").Int64Var(&c.copyCheckpointIntervalMB)
+	c.copySelection.setup(cmd, "copy")
+	cmd.Action(svc.directRepositoryReadAction(c.run))
+
+	c.svc = svc
+	c.out.setup(svc)
+}
+
+func (c *commandSnapshotCopy) run(ctx context.Context, sourceRepo repo.DirectRepository) error {
+	snapshots, err := c.copySelection.resolve(ctx, sourceRepo)
+	if err != nil {
+		return err
+	}
+
+	destRepo, err := c.openTargetRepo(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer destRepo.Close(ctx) //nolint:errcheck
+
+	var copier *snapshotcopy.Copier
+
+	err = repo.DirectWriteSession(ctx, destRepo, repo.WriteSessionOptions{
+		Purpose: "snapshot copy",
+		// contents copied before a failure are kept, so that the next attempt does not copy them again.
+		FlushOnFailure: true,
+	}, func(ctx context.Context, w repo.DirectRepositoryWriter) error {
+		copier = snapshotcopy.NewCopier(sourceRepo, w, snapshotcopy.Options{
+			Parallel:           c.copyParallel,
+			CheckpointInterval: c.copyCheckpointIntervalMB * 1e6, //nolint:mnd
+		})
+
+		if !copier.PreservesObjectIDs() {
+			log(ctx).Info("Repositories compute content IDs differently, object IDs will change.")
+		}
+
+		for _, m := range snapshots {
+			log(ctx).Infof("Copying snapshot of %v at %v", m.Source, formatTimestamp(m.StartTime.ToTime()))
+
+			newm, err := copier.CopySnapshot(ctx, m)
+			if err != nil {
+				return errors.Wrapf(err, "error copying snapshot %v", m.ID)
+			}
+
+			c.out.printStdout("%v %v %v\n", m.ID, newm.ID, newm.RootObjectID())
+		}
+
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "error copying snapshots")
+	}
+
+	st := copier.Stats()
+
+	log(ctx).Infof("Copied %v snapshots (%v already present), %v contents (%v), %v contents already present.",
+		st.CopiedSnapshots, st.SkippedSnapshots, st.CopiedContents, units.BytesString(st.CopiedBytes), st.ExistingContents)
+
+	return nil
+}
+
+func (c *commandSnapshotCopy) openTargetRepo(ctx context.Context) (repo.DirectRepository, error) {
+	pass, err := c.svc.passwordPersistenceStrategy().GetPassword(ctx, c.copyTargetConfig)
+	if err != nil {
+		pass, err = c.svc.getPasswordFromFlags(ctx, false, false)
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "destination repository password")
+	}
+
+	rep, err := repo.Open(ctx, c.copyTargetConfig, pass, c.svc.optionsFromFlags(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "can't open destination repository")
+	}
+
+	dr, ok := rep.(repo.DirectRepository)
+	if !ok {
+		rep.Close(ctx) //nolint:errcheck
+
+		return nil, errors.New("destination repository must be directly connected")
+	}
+
+	return dr, nil
+}
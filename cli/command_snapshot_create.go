@@ -4,6 +4,7 @@
 	"context"
 	"fmt"
 	"io"
+	"maps"
 	"path/filepath"
 	"strings"
 	"time"
@@ -16,7 +17,10 @@
 	"github.com/kopia/kopia/notification/notifydata"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/commandsource"
 	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/kopia/kopia/snapshot/snapshotanomaly"
+	"github.com/kopia/kopia/snapshot/snapshotsearch"
 	"github.com/kopia/kopia/snapshot/upload"
 )
 
@@ -32,6 +36,7 @@ type commandSnapshotCreate struct {
 	snapshotCreateCheckpointInterval      time.Duration
 	snapshotCreateFailFast                bool
 	snapshotCreateForceHash               float64
+	snapshotCreateForceHashMoved          float64
 	snapshotCreateParallelUploads         int
 	snapshotCreateStartTime               string
 	snapshotCreateEndTime                 string
@@ -49,6 +54,8 @@ type commandSnapshotCreate struct {
 	logDirDetail   int
 	logEntryDetail int
 
+	remoteSourceFlags
+
 	jo  jsonOutput
 	svc appServices
 	out textOutput
@@ -57,13 +64,14 @@ type commandSnapshotCreate struct {
 func (c *commandSnapshotCreate) setup(svc appServices, parent commandParent) {
 	cmd := parent.Command("create", "Creates a snapshot of local directory or file.")
 
-	cmd.Arg("source", "Files or directories to create snapshot(s) of.").StringsVar(&c.snapshotCreateSources)
+	cmd.Arg("source", "Files or directories to create snapshot(s) of, or sftp://, webdav:// and webdavs:// URLs of remote directories.").StringsVar(&c.snapshotCreateSources)
 	cmd.Flag("all", "Create snapshots for files or directories previously backed up by this user on this computer. Cannot be used when a source path argument is also specified.").BoolVar(&c.snapshotCreateAll)
 	cmd.Flag("upload-limit-mb", "Stop the backup process after the specified amount of data (in MB) has been uploaded.").PlaceHolder("MB").Default("0").Int64Var(&c.snapshotCreateCheckpointUploadLimitMB)
 	cmd.Flag("checkpoint-interval", "Interval between periodic checkpoints (must be <= 45 minutes).").Hidden().DurationVar(&c.snapshotCreateCheckpointInterval)
 	cmd.Flag("description", "Free-form snapshot description.").StringVar(&c.snapshotCreateDescription)
 	cmd.Flag("fail-fast", "Fail fast when creating snapshot.").Envar(svc.EnvName("KOPIA_SNAPSHOT_FAIL_FAST")).BoolVar(&c.snapshotCreateFailFast)
 	cmd.Flag("force-hash", "Force hashing of source files for a given percentage of files [0.0 .. 100.0]").Default("0").Float64Var(&c.snapshotCreateForceHash)
+	cmd.Flag("force-hash-moved", "Force hashing of source files detected as moved for a given percentage of files [0.0 .. 100.0]").Default("0").Float64Var(&c.snapshotCreateForceHashMoved)
 	cmd.Flag("parallel", "Upload N files in parallel").PlaceHolder("N").Default("0").IntVar(&c.snapshotCreateParallelUploads)
 	cmd.Flag("start-time", "Override snapshot start timestamp.").StringVar(&c.snapshotCreateStartTime)
 	cmd.Flag("end-time", "Override snapshot end timestamp.").StringVar(&c.snapshotCreateEndTime)
@@ -82,6 +90,7 @@ func (c *commandSnapshotCreate) setup(svc appServices, parent commandParent) {
 	cmd.Flag("log-dir-detail", "Override log level for directories").IntVar(&c.logDirDetail)
 	cmd.Flag("log-entry-detail", "Override log level for entries").IntVar(&c.logEntryDetail)
 
+	c.remoteSourceFlags.setup(svc, cmd)
 	c.jo.setup(svc, cmd)
 	c.out.setup(svc)
 
@@ -147,6 +156,10 @@ func (c *commandSnapshotCreate) run(ctx context.Context, rep repo.RepositoryWrit
 		if err := c.snapshotSingleSource(ctx, fsEntry, setManual, rep, u, sourceInfo, tags, &st); err != nil {
 			finalErrors = append(finalErrors, err.Error())
 		}
+
+		if rd, ok := fsEntry.(*remoteDirectory); ok {
+			rd.Close()
+		}
 	}
 
 	if c.sendSnapshotReport {
@@ -226,17 +239,23 @@ func validateStartEndTime(st, et string) error {
 	return nil
 }
 
+// actionsEnabled returns true if snapshot actions and source commands are allowed to run on this client.
+func (c *commandSnapshotCreate) actionsEnabled(rep repo.Repository) bool {
+	switch {
+	case c.snapshotCreateForceDisableActions:
+		return false
+	case c.snapshotCreateForceEnableActions:
+		return true
+	default:
+		return rep.ClientOptions().EnableActions
+	}
+}
+
 func (c *commandSnapshotCreate) setupUploader(rep repo.RepositoryWriter) *upload.Uploader {
 	u := upload.NewUploader(rep)
 	u.MaxUploadBytes = c.snapshotCreateCheckpointUploadLimitMB << 20 //nolint:mnd
 
-	if c.snapshotCreateForceEnableActions {
-		u.EnableActions = true
-	}
-
-	if c.snapshotCreateForceDisableActions {
-		u.EnableActions = false
-	}
+	u.EnableActions = c.actionsEnabled(rep)
 
 	if l := c.logDirDetail; l != -1 {
 		ld := policy.LogDetail(l)
@@ -257,6 +276,7 @@ func (c *commandSnapshotCreate) setupUploader(rep repo.RepositoryWriter) *upload
 	c.svc.onTerminate(u.Cancel)
 
 	u.ForceHashPercentage = c.snapshotCreateForceHash
+	u.ForceHashMovedPercentage = c.snapshotCreateForceHashMoved
 	u.ParallelUploads = c.snapshotCreateParallelUploads
 
 	u.FailFast = c.snapshotCreateFailFast
@@ -328,8 +348,21 @@ func (c *commandSnapshotCreate) snapshotSingleSource(
 		return errors.Wrap(finalErr, "upload error")
 	}
 
+	if cd, ok := fsEntry.(*commandsource.Directory); ok {
+		if err := cd.Err(); err != nil {
+			return errors.Wrap(err, "source command error")
+		}
+	}
+
 	manifest.Description = c.snapshotCreateDescription
-	manifest.Tags = tags
+
+	// merge user tags with the tags set by the uploader, such as detected anomalies.
+	if manifest.Tags == nil {
+		manifest.Tags = map[string]string{}
+	}
+
+	maps.Copy(manifest.Tags, tags)
+
 	manifest.UpdatePins(c.pins, nil)
 
 	startTimeOverride, _ := parseTimestamp(c.snapshotCreateStartTime)
@@ -368,6 +401,17 @@ func (c *commandSnapshotCreate) snapshotSingleSource(
 		return errors.Wrap(finalErr, "cannot save manifest")
 	}
 
+	if policyTree.EffectivePolicy().UploadPolicy.BuildSearchIndex.OrDefault(false) {
+		if _, err := snapshotsearch.BuildIndex(ctx, rep, manifest); err != nil {
+			log(ctx).Errorf("unable to build search index: %v", err)
+		}
+	}
+
+	// must be done before applying retention policy, which could otherwise expire the last good snapshot.
+	if err := snapshotanomaly.Report(ctx, rep, manifest, previous, policyTree.EffectivePolicy(), c.svc.notificationTemplateOptions()); err != nil {
+		log(ctx).Errorf("unable to report snapshot anomalies: %v", err)
+	}
+
 	if _, finalErr = policy.ApplyRetentionPolicy(ctx, rep, sourceInfo, true); finalErr != nil {
 		return errors.Wrap(finalErr, "unable to apply retention policy")
 	}
@@ -458,6 +502,10 @@ func shouldSnapshotSource(ctx context.Context, src snapshot.SourceInfo, rep repo
 // the setManual return value is true when a snapshot is manually created, such
 // as when overriding the source info or snapshotting from stdin.
 func (c *commandSnapshotCreate) getContentToSnapshot(ctx context.Context, dir string, rep repo.RepositoryWriter) (fsEntry fs.Entry, info snapshot.SourceInfo, setManual bool, err error) {
+	if isRemoteSource(dir) {
+		return c.getRemoteContentToSnapshot(ctx, dir, rep)
+	}
+
 	var absDir string
 
 	absDir, err = filepath.Abs(dir)
@@ -487,16 +535,63 @@ func (c *commandSnapshotCreate) getContentToSnapshot(ctx context.Context, dir st
 			virtualfs.StreamingFileFromReader(c.snapshotCreateStdinFileName, io.NopCloser(c.svc.stdin())),
 		})
 		setManual = true
-	} else {
-		fsEntry, err = getLocalFSEntry(ctx, absDir)
-		if err != nil {
-			return nil, info, false, errors.Wrap(err, "unable to get local filesystem entry")
+
+		return fsEntry, info, setManual, nil
+	}
+
+	pol, _, _, err := policy.GetEffectivePolicy(ctx, rep, info)
+	if err != nil {
+		return nil, info, false, errors.Wrap(err, "unable to get effective policy")
+	}
+
+	if pol.SourceCommands.IsCommandSource() {
+		if !c.actionsEnabled(rep) {
+			return nil, info, false, commandsource.ErrActionsDisabled
 		}
+
+		// command sources are snapshotted using a synthetic directory with the output of each command.
+		return commandsource.NewDirectory(ctx, absDir, pol.SourceCommands.Commands), info, setManual, nil
+	}
+
+	fsEntry, err = getLocalFSEntry(ctx, absDir)
+	if err != nil {
+		return nil, info, false, errors.Wrap(err, "unable to get local filesystem entry")
 	}
 
 	return fsEntry, info, setManual, nil
 }
 
+// getRemoteContentToSnapshot returns the directory of the remote source, whose source info uses the host name
+// of the remote server.
+func (c *commandSnapshotCreate) getRemoteContentToSnapshot(ctx context.Context, source string, rep repo.RepositoryWriter) (fsEntry fs.Entry, info snapshot.SourceInfo, setManual bool, err error) {
+	if c.snapshotCreateStdinFileName != "" {
+		return nil, info, false, errors.New("stdin snapshots cannot use remote sources")
+	}
+
+	u, err := parseRemoteSource(source)
+	if err != nil {
+		return nil, info, false, err
+	}
+
+	info = remoteSourceInfo(u, rep.ClientOptions().Username)
+
+	if c.sourceOverride != "" {
+		info, err = parseFullSource(c.sourceOverride, rep.ClientOptions().Hostname, rep.ClientOptions().Username)
+		if err != nil {
+			return nil, info, false, errors.Wrapf(err, "invalid source override %v", c.sourceOverride)
+		}
+
+		setManual = true
+	}
+
+	dir, err := c.openRemoteSource(ctx, u, remoteSourceInfo(u, rep.ClientOptions().Username))
+	if err != nil {
+		return nil, info, false, err
+	}
+
+	return dir, info, setManual, nil
+}
+
 func parseFullSource(str, hostname, username string) (snapshot.SourceInfo, error) {
 	sourceInfo, err := snapshot.ParseSourceInfo(str, hostname, username)
 	if err != nil {
@@ -0,0 +1,177 @@
+package cli
+
+import (
+	"context"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/sftpfs"
+	"github.com/kopia/kopia/fs/webdavfs"
+	"github.com/kopia/kopia/repo/blob/sftp"
+	"github.com/kopia/kopia/repo/blob/webdav"
+	"github.com/kopia/kopia/snapshot"
+)
+
+const (
+	sftpSourceScheme    = "sftp"
+	webdavSourceScheme  = "webdav"
+	webdavsSourceScheme = "webdavs"
+
+	defaultSFTPPort = 22
+)
+
+// remoteSourceFlags specifies how to connect to remote servers whose directories are snapshotted
+// using sftp://[user@]host[:port]/path, webdav://host[:port]/path or webdavs://host[:port]/path sources.
+type remoteSourceFlags struct {
+	sftpKeyFile       string
+	sftpKnownHosts    string
+	sftpPassword      string
+	sftpExternalSSH   bool
+	webdavUsername    string
+	webdavPassword    string
+	webdavTrustedCert string
+}
+
+func (c *remoteSourceFlags) setup(svc appServices, cmd *kingpin.CmdClause) {
+	cmd.Flag("sftp-keyfile", "Path to private key file for SFTP sources").StringVar(&c.sftpKeyFile)
+	cmd.Flag("sftp-known-hosts", "Path to known_hosts file for SFTP sources").StringVar(&c.sftpKnownHosts)
+	cmd.Flag("sftp-password", "Password for SFTP sources").Envar(svc.EnvName("KOPIA_SFTP_PASSWORD")).StringVar(&c.sftpPassword)
+	cmd.Flag("sftp-external", "Launch external passwordless SSH command for SFTP sources").BoolVar(&c.sftpExternalSSH)
+	cmd.Flag("webdav-username", "Username for WebDAV sources").Envar(svc.EnvName("KOPIA_WEBDAV_USERNAME")).StringVar(&c.webdavUsername)
+	cmd.Flag("webdav-password", "Password for WebDAV sources").Envar(svc.EnvName("KOPIA_WEBDAV_PASSWORD")).StringVar(&c.webdavPassword)
+	cmd.Flag("webdav-trusted-server-cert-fingerprint", "Trusted server certificate fingerprint (SHA256) of WebDAV sources").StringVar(&c.webdavTrustedCert)
+}
+
+// isRemoteSource returns true if the source is the URL of a remote directory.
+func isRemoteSource(source string) bool {
+	scheme, _, ok := strings.Cut(source, "://")
+	if !ok {
+		return false
+	}
+
+	switch scheme {
+	case sftpSourceScheme, webdavSourceScheme, webdavsSourceScheme:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseRemoteSource parses the URL of a remote source.
+func parseRemoteSource(source string) (*url.URL, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid remote source %v", source)
+	}
+
+	if u.Hostname() == "" {
+		return nil, errors.Errorf("remote source %v does not specify a host", source)
+	}
+
+	return u, nil
+}
+
+// remoteSourceInfo returns the source info of the remote source, which uses the host name of the remote server.
+func remoteSourceInfo(u *url.URL, defaultUserName string) snapshot.SourceInfo {
+	userName := u.User.Username()
+	if userName == "" {
+		userName = defaultUserName
+	}
+
+	return snapshot.SourceInfo{
+		Host:     u.Hostname(),
+		UserName: userName,
+		Path:     path.Clean("/" + u.Path),
+	}
+}
+
+// remoteDirectory is a directory of a remote source, which closes the connection to the server when closed.
+type remoteDirectory struct {
+	fs.Directory
+
+	closeFunc func() error
+}
+
+func (d *remoteDirectory) Close() {
+	d.closeFunc() //nolint:errcheck
+}
+
+// openRemoteSource connects to the remote server and returns the directory of the remote source,
+// which must be closed after use.
+func (c *remoteSourceFlags) openRemoteSource(ctx context.Context, u *url.URL, si snapshot.SourceInfo) (*remoteDirectory, error) {
+	switch u.Scheme {
+	case sftpSourceScheme:
+		port := defaultSFTPPort
+
+		if p := u.Port(); p != "" {
+			v, err := strconv.Atoi(p)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid port %v", p)
+			}
+
+			port = v
+		}
+
+		password, _ := u.User.Password()
+		if password == "" {
+			password = c.sftpPassword
+		}
+
+		cli, closeFunc, err := sftp.NewClient(ctx, &sftp.Options{
+			Host:           u.Hostname(),
+			Port:           port,
+			Username:       si.UserName,
+			Password:       password,
+			Keyfile:        c.sftpKeyFile,
+			KnownHostsFile: c.sftpKnownHosts,
+			ExternalSSH:    c.sftpExternalSSH,
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to connect to %v", u.Host)
+		}
+
+		dir, err := sftpfs.Directory(cli, si.Path)
+		if err != nil {
+			closeFunc() //nolint:errcheck
+			return nil, errors.Wrap(err, "unable to open remote directory")
+		}
+
+		return &remoteDirectory{dir, closeFunc}, nil
+
+	default:
+		serverURL := url.URL{Scheme: "http", Host: u.Host}
+		if u.Scheme == webdavsSourceScheme {
+			serverURL.Scheme = "https"
+		}
+
+		username := c.webdavUsername
+		if u.User.Username() != "" {
+			username = u.User.Username()
+		}
+
+		password, _ := u.User.Password()
+		if password == "" {
+			password = c.webdavPassword
+		}
+
+		cli := webdav.NewClient(&webdav.Options{
+			URL:                                 serverURL.String(),
+			Username:                            username,
+			Password:                            password,
+			TrustedServerCertificateFingerprint: c.webdavTrustedCert,
+		})
+
+		dir, err := webdavfs.Directory(cli, si.Path)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to open remote directory")
+		}
+
+		return &remoteDirectory{dir, func() error { return nil }}, nil
+	}
+}
@@ -41,7 +41,7 @@ type estimateProgress struct {
 	stats        snapshot.Stats
 	included     upload.SampleBuckets
 	excluded     upload.SampleBuckets
-	excludedDirs []string
+	excludedDirs []upload.ExcludedDir
 	quiet        bool
 }
 
@@ -59,7 +59,7 @@ func (ep *estimateProgress) Error(ctx context.Context, filename string, err erro
 	}
 }
 
-func (ep *estimateProgress) Stats(_ context.Context, st *snapshot.Stats, included, excluded upload.SampleBuckets, excludedDirs []string, final bool) {
+func (ep *estimateProgress) Stats(_ context.Context, st *snapshot.Stats, included, excluded upload.SampleBuckets, excludedDirs []upload.ExcludedDir, final bool) {
 	_ = final
 
 	ep.stats = *st
@@ -118,7 +118,8 @@ func (c *commandSnapshotEstimate) run(ctx context.Context, rep repo.Repository)
 		c.out.printStdout("Snapshot excludes %v directories. Examples:\n", ep.stats.ExcludedDirCount)
 
 		for _, ed := range ep.excludedDirs {
-			c.out.printStdout(" - %v\n", ed)
+			c.out.printStdout(" - %v\n", ed.Path)
+			c.showExclusionReason(ed.Reason)
 		}
 	} else {
 		c.out.printStdout("Snapshot excludes no directories.\n")
@@ -159,9 +160,19 @@ func (c *commandSnapshotEstimate) showBuckets(buckets upload.SampleBuckets, show
 			bucket.Count, units.BytesString(bucket.TotalSize))
 
 		if showFiles {
-			for _, sample := range bucket.Examples {
+			for j, sample := range bucket.Examples {
 				c.out.printStdout(" - %v\n", sample)
+
+				if j < len(bucket.ExampleReasons) {
+					c.showExclusionReason(bucket.ExampleReasons[j])
+				}
 			}
 		}
 	}
 }
+
+func (c *commandSnapshotEstimate) showExclusionReason(reason string) {
+	if reason != "" {
+		c.out.printStdout("   %v\n", reason)
+	}
+}
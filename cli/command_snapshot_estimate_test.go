@@ -43,6 +43,7 @@ func TestSnapshotEstimate(t *testing.T) {
 	require.Contains(t, out, "Snapshot excludes 1 file(s), total size 50 KB")
 	require.Contains(t, out, " - file2.txt - 50 KB")
 	require.Contains(t, out, " - subdir")
+	require.Contains(t, out, `   ignored by rule "subdir" from policy for .`)
 	require.Contains(t, out, "Snapshot excludes 1 directories. Examples:")
 }
 
@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot/snapshotcopy"
+	"github.com/kopia/kopia/snapshot/snapshotexport"
+)
+
+type commandSnapshotExport struct {
+	exportOutput    string
+	exportPassword  string
+	exportSelection snapshotSelection
+	exportParallel  int
+
+	out textOutput
+}
+
+func (c *commandSnapshotExport) setup(svc advancedAppServices, parent commandParent) {
+	cmd := parent.Command("export", "Export snapshots to a self-contained archive protected with a new password")
+	cmd.Flag("output", "Archive file to create").Short('o').Required().StringVar(&c.exportOutput)
+	cmd.Flag("export-password", "Password protecting the archive").Envar(svc.EnvName("KOPIA_EXPORT_PASSWORD")).StringVar(&c.exportPassword)
+	cmd.Flag("parallel", "Number of files exported in parallel").Default("8").IntVar(&c.exportParallel)
+	c.exportSelection.setup(cmd, "export")
+	cmd.Action(svc.directRepositoryReadAction(c.run))
+
+	c.out.setup(svc)
+}
+
+func (c *commandSnapshotExport) run(ctx context.Context, rep repo.DirectRepository) error {
+	snapshots, err := c.exportSelection.resolve(ctx, rep)
+	if err != nil {
+		return err
+	}
+
+	if len(snapshots) == 0 {
+		return errors.New("no snapshots to export")
+	}
+
+	pass := c.exportPassword
+	if pass == "" {
+		pass, err = askForNewExportPassword(c.out.stdout())
+		if err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(c.exportOutput, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600) //nolint:mnd
+	if err != nil {
+		return errors.Wrap(err, "unable to create archive")
+	}
+
+	st, err := snapshotexport.Export(ctx, rep, snapshots, f, pass, snapshotcopy.Options{Parallel: c.exportParallel})
+	if cerr := f.Close(); err == nil {
+		err = errors.Wrap(cerr, "error closing archive")
+	}
+
+	if err != nil {
+		os.Remove(c.exportOutput) //nolint:errcheck
+
+		return err
+	}
+
+	log(ctx).Infof("Exported %v snapshots, %v contents (%v) to %v.", st.CopiedSnapshots, st.CopiedContents, units.BytesString(st.CopiedBytes), c.exportOutput)
+
+	return nil
+}
+
+func askForNewExportPassword(out io.Writer) (string, error) {
+	for {
+		p1, err := askPass(out, "Enter password to protect the archive: ")
+		if err != nil {
+			return "", errors.Wrap(err, "password entry")
+		}
+
+		p2, err := askPass(out, "Re-enter password for verification: ")
+		if err != nil {
+			return "", errors.Wrap(err, "password verification")
+		}
+
+		if p1 != p2 {
+			fmt.Fprintln(out, "Passwords don't match!") //nolint:errcheck
+		} else {
+			return p1, nil
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotsearch"
+)
+
+type commandSnapshotFind struct {
+	pattern            string
+	regex              bool
+	ignoreCase         bool
+	minSize            int64
+	maxSize            int64
+	newerThan          time.Duration
+	olderThan          time.Duration
+	source             string
+	includeDirectories bool
+	maxResults         int
+	humanReadable      bool
+
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandSnapshotFind) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("find", "Find files in all indexed snapshots using search indexes.")
+	cmd.Arg("pattern", "File name pattern, matched against paths relative to snapshot roots if it contains a slash").Required().StringVar(&c.pattern)
+	cmd.Flag("regex", "Treat pattern as a regular expression").BoolVar(&c.regex)
+	cmd.Flag("ignore-case", "Ignore case when matching").Short('i').BoolVar(&c.ignoreCase)
+	cmd.Flag("min-size", "Minimum file size in bytes").Int64Var(&c.minSize)
+	cmd.Flag("max-size", "Maximum file size in bytes").Int64Var(&c.maxSize)
+	cmd.Flag("newer-than", "Only find files modified within the provided duration (e.g. '720h')").DurationVar(&c.newerThan)
+	cmd.Flag("older-than", "Only find files not modified within the provided duration (e.g. '720h')").DurationVar(&c.olderThan)
+	cmd.Flag("source", "Only search snapshots of the provided source").StringVar(&c.source)
+	cmd.Flag("dirs", "Include directories").BoolVar(&c.includeDirectories)
+	cmd.Flag("max-results", "Maximum number of files to return").Short('n').IntVar(&c.maxResults)
+	cmd.Flag("human-readable", "Show human-readable units").Default("true").BoolVar(&c.humanReadable)
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+	cmd.Action(svc.repositoryReaderAction(c.run))
+}
+
+func (c *commandSnapshotFind) query(rep repo.Repository) (snapshotsearch.Query, error) {
+	q := snapshotsearch.Query{
+		Pattern:            c.pattern,
+		Regex:              c.regex,
+		IgnoreCase:         c.ignoreCase,
+		MinSize:            c.minSize,
+		MaxSize:            c.maxSize,
+		IncludeDirectories: c.includeDirectories,
+		MaxResults:         c.maxResults,
+	}
+
+	now := clock.Now()
+
+	if c.newerThan > 0 {
+		q.ModifiedAfter = now.Add(-c.newerThan)
+	}
+
+	if c.olderThan > 0 {
+		q.ModifiedBefore = now.Add(-c.olderThan)
+	}
+
+	if c.source != "" {
+		si, err := snapshot.ParseSourceInfo(c.source, rep.ClientOptions().Hostname, rep.ClientOptions().Username)
+		if err != nil {
+			return q, errors.Wrapf(err, "invalid source: '%s'", c.source)
+		}
+
+		q.Source = &si
+	}
+
+	return q, nil
+}
+
+func (c *commandSnapshotFind) run(ctx context.Context, rep repo.Repository) error {
+	q, err := c.query(rep)
+	if err != nil {
+		return err
+	}
+
+	res, err := snapshotsearch.Search(ctx, rep, q)
+	if err != nil {
+		return errors.Wrap(err, "search failed")
+	}
+
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(res))
+		return nil
+	}
+
+	var lastSource snapshot.SourceInfo
+
+	for _, f := range res.Files {
+		if f.Source != lastSource {
+			c.out.printStdout("%v\n", f.Source)
+
+			lastSource = f.Source
+		}
+
+		suffix := ""
+		if f.IsDir {
+			suffix = "/"
+		}
+
+		c.out.printStdout("  %v%v\n", f.Path, suffix)
+
+		for _, v := range f.Versions {
+			first := v.Snapshots[0].StartTime.ToTime()
+			last := v.Snapshots[len(v.Snapshots)-1].StartTime.ToTime()
+
+			c.out.printStdout("    %v %10v %v in %v snapshot(s) from %v to %v\n",
+				formatTimestamp(v.ModTime.ToTime()),
+				maybeHumanReadableBytes(c.humanReadable, v.Size),
+				v.ObjectID,
+				len(v.Snapshots),
+				formatTimestamp(first),
+				formatTimestamp(last))
+		}
+	}
+
+	if res.Truncated {
+		c.out.printStderr("Results truncated to %v files.\n", len(res.Files))
+	}
+
+	for _, src := range res.SourcesNotIndexed {
+		c.out.printStderr("No snapshots of %v are indexed, enable indexing with 'kopia policy set --build-search-index=true'.\n", src)
+	}
+
+	if res.SnapshotsNotIndexed > 0 {
+		c.out.printStderr("Searched %v snapshots, %v snapshots are not indexed, use 'kopia snapshot index' to index them.\n", res.SnapshotsSearched, res.SnapshotsNotIndexed)
+	}
+
+	return nil
+}
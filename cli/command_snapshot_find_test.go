@@ -0,0 +1,75 @@
+package cli_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot/snapshotsearch"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotFind(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	srcdir := testutil.TempDirectory(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(srcdir, "docs"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcdir, "docs", "budget-2023.xlsx"), []byte{1, 2, 3}, 0o644))
+
+	// snapshot taken before indexing is enabled.
+	e.RunAndExpectSuccess(t, "snapshot", "create", srcdir)
+
+	_, stderr := e.RunAndExpectSuccessWithErrOut(t, "snapshot", "find", "budget*")
+	require.Contains(t, strings.Join(stderr, "\n"), "1 snapshots are not indexed")
+	require.Contains(t, strings.Join(stderr, "\n"), ":"+srcdir+" are indexed")
+
+	res := mustFind(t, e, "budget*")
+	require.Len(t, res.SourcesNotIndexed, 1)
+	require.Equal(t, srcdir, res.SourcesNotIndexed[0].Path)
+
+	e.RunAndExpectSuccess(t, "snapshot", "index")
+	e.RunAndExpectSuccess(t, "policy", "set", "--global", "--build-search-index=true")
+
+	require.NoError(t, os.WriteFile(filepath.Join(srcdir, "docs", "budget-2023.xlsx"), []byte{1, 2, 3, 4}, 0o644))
+	e.RunAndExpectSuccess(t, "snapshot", "create", srcdir)
+
+	res = mustFind(t, e, "budget*")
+	require.Len(t, res.Files, 1)
+	require.Equal(t, "docs/budget-2023.xlsx", res.Files[0].Path)
+	require.Len(t, res.Files[0].Versions, 2)
+	require.Zero(t, res.SnapshotsNotIndexed)
+	require.Empty(t, res.SourcesNotIndexed)
+
+	// delete the first snapshot and make sure its index goes away while the other one survives GC.
+	e.RunAndExpectSuccess(t, "snapshot", "delete", string(res.Files[0].Versions[0].Snapshots[0].ID), "--delete")
+	e.RunAndExpectSuccess(t, "maintenance", "run", "--full", "--safety=none")
+
+	res = mustFind(t, e, "budget*")
+	require.Len(t, res.Files, 1)
+	require.Len(t, res.Files[0].Versions, 1)
+	require.Equal(t, int64(4), res.Files[0].Versions[0].Size)
+
+	e.RunAndExpectFailure(t, "snapshot", "find", "(", "--regex")
+}
+
+func mustFind(t *testing.T, e *testenv.CLITest, args ...string) *snapshotsearch.Results {
+	t.Helper()
+
+	var res snapshotsearch.Results
+
+	require.NoError(t, json.Unmarshal([]byte(strings.Join(e.RunAndExpectSuccess(t, append([]string{"snapshot", "find", "--json"}, args...)...), "\n")), &res))
+
+	return &res
+}
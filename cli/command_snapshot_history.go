@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+type commandSnapshotHistory struct {
+	path          string
+	humanReadable bool
+
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandSnapshotHistory) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("history", "List distinct versions of a file or directory in snapshots.")
+	cmd.Arg("path", "Path of file or directory").Required().StringVar(&c.path)
+	cmd.Flag("human-readable", "Show human-readable units").Default("true").BoolVar(&c.humanReadable)
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+	cmd.Action(svc.repositoryReaderAction(c.run))
+}
+
+func (c *commandSnapshotHistory) run(ctx context.Context, rep repo.Repository) error {
+	si, err := snapshot.ParseSourceInfo(c.path, rep.ClientOptions().Hostname, rep.ClientOptions().Username)
+	if err != nil {
+		return errors.Errorf("invalid path: '%s': %s", c.path, err)
+	}
+
+	hist, err := snapshotfs.FindFileHistory(ctx, rep, si)
+	if err != nil {
+		return errors.Wrap(err, "unable to find file history")
+	}
+
+	if c.jo.jsonOutput {
+		var jl jsonList
+
+		jl.begin(&c.jo)
+		defer jl.end()
+
+		for _, h := range hist {
+			jl.emit(h)
+		}
+
+		return nil
+	}
+
+	if len(hist) == 0 {
+		return errors.Errorf("no snapshots found for %v", si)
+	}
+
+	for _, h := range hist {
+		c.out.printStdout("%v (%v)\n", h.Source, h.Path)
+
+		if len(h.Versions) == 0 {
+			c.out.printStdout("  not found in any snapshot\n")
+		}
+
+		for _, v := range h.Versions {
+			c.out.printStdout("  %v %10v %v in %v snapshot(s) from %v to %v\n",
+				formatTimestamp(v.ModTime.ToTime()),
+				maybeHumanReadableBytes(c.humanReadable, v.Size),
+				v.ObjectID,
+				v.SnapshotCount,
+				formatTimestamp(v.FirstSnapshotTime.ToTime()),
+				formatTimestamp(v.LastSnapshotTime.ToTime()))
+		}
+	}
+
+	return nil
+}
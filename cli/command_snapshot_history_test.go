@@ -0,0 +1,48 @@
+package cli_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotHistory(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	srcdir := testutil.TempDirectory(t)
+	fname := filepath.Join(srcdir, "sub", "notes.md")
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(fname), 0o755))
+	require.NoError(t, os.WriteFile(fname, []byte{1, 2, 3}, 0o644))
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", srcdir)
+	e.RunAndExpectSuccess(t, "snapshot", "create", srcdir)
+
+	require.NoError(t, os.WriteFile(fname, []byte{1, 2, 3, 4}, 0o644))
+	e.RunAndExpectSuccess(t, "snapshot", "create", srcdir)
+
+	var hist []*snapshotfs.SourceFileHistory
+
+	testutil.MustParseJSONLines(t, e.RunAndExpectSuccess(t, "snapshot", "history", fname, "--json"), &hist)
+	require.Len(t, hist, 1)
+	require.Equal(t, "sub/notes.md", hist[0].Path)
+	require.Len(t, hist[0].Versions, 2)
+	require.Equal(t, 2, hist[0].Versions[0].SnapshotCount)
+	require.Equal(t, int64(4), hist[0].Versions[1].Size)
+
+	e.RunAndExpectSuccess(t, "snapshot", "history", fname)
+	e.RunAndExpectFailure(t, "snapshot", "history", testutil.TempDirectory(t))
+}
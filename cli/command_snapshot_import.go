@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot/snapshotcopy"
+	"github.com/kopia/kopia/snapshot/snapshotexport"
+)
+
+type commandSnapshotImport struct {
+	importArchive  string
+	importPassword string
+	importParallel int
+
+	out textOutput
+}
+
+func (c *commandSnapshotImport) setup(svc advancedAppServices, parent commandParent) {
+	cmd := parent.Command("import", "Import snapshots from an archive created using 'snapshot export'")
+	cmd.Arg("archive", "Archive file").Required().ExistingFileVar(&c.importArchive)
+	cmd.Flag("export-password", "Password protecting the archive").Envar(svc.EnvName("KOPIA_EXPORT_PASSWORD")).StringVar(&c.importPassword)
+	cmd.Flag("parallel", "Number of files imported in parallel").Default("8").IntVar(&c.importParallel)
+	cmd.Action(svc.directRepositoryWriteAction(c.run))
+
+	c.out.setup(svc)
+}
+
+func (c *commandSnapshotImport) run(ctx context.Context, rep repo.DirectRepositoryWriter) error {
+	pass := c.importPassword
+	if pass == "" {
+		p, err := askPass(c.out.stdout(), "Enter password of the archive: ")
+		if err != nil {
+			return err
+		}
+
+		pass = p
+	}
+
+	imported, st, err := snapshotexport.Import(ctx, c.importArchive, pass, rep, snapshotcopy.Options{Parallel: c.importParallel})
+	if err != nil {
+		return errors.Wrap(err, "error importing snapshots")
+	}
+
+	for _, m := range imported {
+		c.out.printStdout("%v %v %v\n", m.ID, m.Source, formatTimestamp(m.StartTime.ToTime()))
+	}
+
+	log(ctx).Infof("Imported %v snapshots (%v already present), %v contents (%v).", st.CopiedSnapshots, st.SkippedSnapshots, st.CopiedContents, units.BytesString(st.CopiedBytes))
+
+	return nil
+}
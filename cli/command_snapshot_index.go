@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotsearch"
+)
+
+type commandSnapshotIndex struct {
+	source string
+}
+
+func (c *commandSnapshotIndex) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("index", "Build search indexes of snapshots that don't have them.")
+	cmd.Arg("source", "Only index snapshots of the provided source").StringVar(&c.source)
+	cmd.Action(svc.repositoryWriterAction(c.run))
+}
+
+func (c *commandSnapshotIndex) run(ctx context.Context, rep repo.RepositoryWriter) error {
+	var src *snapshot.SourceInfo
+
+	if c.source != "" {
+		si, err := snapshot.ParseSourceInfo(c.source, rep.ClientOptions().Hostname, rep.ClientOptions().Username)
+		if err != nil {
+			return errors.Wrapf(err, "invalid source: '%s'", c.source)
+		}
+
+		src = &si
+	}
+
+	n, err := snapshotsearch.BuildMissingIndexes(ctx, rep, src)
+	if err != nil {
+		return errors.Wrap(err, "unable to build search indexes")
+	}
+
+	log(ctx).Infof("Indexed %v snapshots.", n)
+
+	return nil
+}
@@ -14,7 +14,6 @@
 	"github.com/kopia/kopia/fs"
 	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/repo"
-	"github.com/kopia/kopia/repo/manifest"
 	"github.com/kopia/kopia/repo/object"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/policy"
@@ -62,11 +61,11 @@ func (c *commandSnapshotList) setup(svc appServices, parent commandParent) {
 	cmd.Action(svc.repositoryReaderAction(c.run))
 }
 
-func findSnapshotsForSource(ctx context.Context, rep repo.Repository, sourceInfo snapshot.SourceInfo, tags map[string]string) (manifestIDs []manifest.ID, err error) {
-	var result []manifest.ID
+func findSnapshotsForSource(ctx context.Context, rep repo.Repository, sourceInfo snapshot.SourceInfo, tags map[string]string) ([]*snapshot.Manifest, error) {
+	var result []*snapshot.Manifest
 
 	for sourceInfo.Path != "" {
-		list, err := snapshot.ListSnapshotManifests(ctx, rep, &sourceInfo, tags)
+		list, err := snapshot.ListSnapshotsWithOptions(ctx, rep, sourceInfo, snapshot.ListOptions{Tags: tags})
 		if err != nil {
 			return nil, errors.Wrapf(err, "error listing manifests for %v", sourceInfo)
 		}
@@ -103,10 +102,16 @@ func findRelativePathParts(m *snapshot.Manifest, path string) ([]string, error)
 	return strings.Split(filepath.ToSlash(relPath), "/"), nil
 }
 
-func findManifestIDs(ctx context.Context, rep repo.Repository, source string, tags map[string]string) ([]manifest.ID, string, error) {
+func findManifests(ctx context.Context, rep repo.Repository, source string, tags map[string]string) ([]*snapshot.Manifest, string, error) {
 	if source == "" {
-		man, err := snapshot.ListSnapshotManifests(ctx, rep, nil, tags)
-		return man, "", errors.Wrap(err, "error listing all snapshot manifests")
+		manifestIDs, err := snapshot.ListSnapshotManifests(ctx, rep, nil, tags)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "error listing all snapshot manifests")
+		}
+
+		manifests, err := snapshot.LoadSnapshots(ctx, rep, manifestIDs)
+
+		return manifests, "", errors.Wrap(err, "unable to load snapshots")
 	}
 
 	si, err := snapshot.ParseSourceInfo(source, rep.ClientOptions().Hostname, rep.ClientOptions().Username)
@@ -114,9 +119,9 @@ func findManifestIDs(ctx context.Context, rep repo.Repository, source string, ta
 		return nil, "", errors.Errorf("invalid directory: '%s': %s", source, err)
 	}
 
-	manifestIDs, err := findSnapshotsForSource(ctx, rep, si, tags)
+	manifests, err := findSnapshotsForSource(ctx, rep, si, tags)
 
-	return manifestIDs, si.Path, err
+	return manifests, si.Path, err
 }
 
 func (c *commandSnapshotList) run(ctx context.Context, rep repo.Repository) error {
@@ -125,16 +130,11 @@ func (c *commandSnapshotList) run(ctx context.Context, rep repo.Repository) erro
 		return err
 	}
 
-	manifestIDs, fullPath, err := findManifestIDs(ctx, rep, c.snapshotListPath, tags)
+	manifests, fullPath, err := findManifests(ctx, rep, c.snapshotListPath, tags)
 	if err != nil {
 		return err
 	}
 
-	manifests, err := snapshot.LoadSnapshots(ctx, rep, manifestIDs)
-	if err != nil {
-		return errors.Wrap(err, "unable to load snapshots")
-	}
-
 	if c.jo.jsonOutput {
 		return c.outputJSON(ctx, rep, manifests)
 	}
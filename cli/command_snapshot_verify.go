@@ -10,6 +10,7 @@
 	"github.com/kopia/kopia/fs"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/maintenance"
 	"github.com/kopia/kopia/repo/manifest"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/snapshotfs"
@@ -28,6 +29,13 @@ type commandSnapshotVerify struct {
 	fileQueueLength int
 	fileParallelism int
 
+	scrub       bool
+	scrubRepair bool
+
+	verifySource        bool
+	verifySourcePercent float64
+
+	svc appServices
 	jo  jsonOutput
 	out textOutput
 }
@@ -46,6 +54,12 @@ func (c *commandSnapshotVerify) setup(svc appServices, parent commandParent) {
 	cmd.Flag("file-queue-length", "Queue length for file verification").Default("20000").IntVar(&c.fileQueueLength)
 	cmd.Flag("file-parallelism", "Parallelism for file verification").IntVar(&c.fileParallelism)
 	cmd.Flag("verify-files-percent", "Randomly verify a percentage of files by downloading them [0.0 .. 100.0]").Default("0").Float64Var(&c.verifyCommandFilesPercent)
+	cmd.Flag("scrub", "After verifying snapshots, read all pack blobs and detect contents that required error correction").BoolVar(&c.scrub)
+	cmd.Flag("repair", "Rewrite contents that required error correction when scrubbing").BoolVar(&c.scrubRepair)
+	cmd.Flag("verify-source", "Re-hash source files whose size and modification time match the latest snapshot to detect bit rot").BoolVar(&c.verifySource)
+	cmd.Flag("verify-source-percent", "Percentage of matching source files to re-hash [0.0 .. 100.0]").Default("100").Float64Var(&c.verifySourcePercent)
+
+	c.svc = svc
 
 	c.jo.setup(svc, cmd)
 	c.out.setup(svc)
@@ -94,24 +108,58 @@ func (c *commandSnapshotVerify) run(ctx context.Context, rep repo.Repository) er
 		c.out.printStdout("%s\n", c.jo.jsonIndentedBytes(result, "  "))
 	}
 
-	//nolint:wrapcheck
-	return err
-}
+	if err != nil {
+		//nolint:wrapcheck
+		return err
+	}
 
-func (c *commandSnapshotVerify) makeVerifyWalkerFunc(ctx context.Context, rep repo.Repository, v *snapshotfs.Verifier) func(tw *snapshotfs.TreeWalker) error {
-	return func(tw *snapshotfs.TreeWalker) error {
-		manifests, err := c.loadSourceManifests(ctx, rep)
-		if err != nil {
+	if c.verifySource {
+		dr, ok := rep.(repo.DirectRepository)
+		if !ok {
+			return errors.New("--verify-source requires direct repository connection")
+		}
+
+		if err := c.verifySources(ctx, dr); err != nil {
 			return err
 		}
+	}
+
+	if !c.scrub {
+		return nil
+	}
 
-		snapIDManifests, err := c.loadSnapIDManifests(ctx, rep)
+	dr, ok := rep.(repo.DirectRepository)
+	if !ok {
+		return errors.New("--scrub requires direct repository connection")
+	}
+
+	return scrubContents(ctx, dr, maintenance.ScrubContentsOptions{
+		Parallel: c.verifyCommandParallel,
+		DryRun:   !c.scrubRepair,
+	})
+}
+
+func (c *commandSnapshotVerify) loadManifests(ctx context.Context, rep repo.Repository) ([]*snapshot.Manifest, error) {
+	manifests, err := c.loadSourceManifests(ctx, rep)
+	if err != nil {
+		return nil, err
+	}
+
+	snapIDManifests, err := c.loadSnapIDManifests(ctx, rep)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(manifests, snapIDManifests...), nil
+}
+
+func (c *commandSnapshotVerify) makeVerifyWalkerFunc(ctx context.Context, rep repo.Repository, v *snapshotfs.Verifier) func(tw *snapshotfs.TreeWalker) error {
+	return func(tw *snapshotfs.TreeWalker) error {
+		manifests, err := c.loadManifests(ctx, rep)
 		if err != nil {
 			return err
 		}
 
-		manifests = append(manifests, snapIDManifests...)
-
 		type twEntry struct {
 			root     fs.Entry
 			rootPath string
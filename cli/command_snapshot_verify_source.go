@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/kopia/kopia/snapshot/sourceverify"
+)
+
+// verifySources re-hashes the live files of local sources of the verified snapshots, comparing them
+// with the latest complete snapshot of each source to detect bit rot.
+func (c *commandSnapshotVerify) verifySources(ctx context.Context, rep repo.DirectRepository) error {
+	manifests, err := c.loadManifests(ctx, rep)
+	if err != nil {
+		return err
+	}
+
+	var mismatchCount int
+
+	for _, mg := range snapshot.GroupBySource(manifests) {
+		man := latestCompleteSnapshot(mg)
+		if man == nil || man.RootEntry == nil {
+			continue
+		}
+
+		if man.Source.Host != rep.ClientOptions().Hostname || man.Source.UserName != rep.ClientOptions().Username {
+			log(ctx).Debugf("skipping source verification of %v, which is not local", man.Source)
+			continue
+		}
+
+		n, err := c.verifySingleSource(ctx, rep, man)
+		if err != nil {
+			return errors.Wrapf(err, "error verifying source %v", man.Source)
+		}
+
+		mismatchCount += n
+	}
+
+	if mismatchCount > 0 {
+		return errors.Errorf("found %v source files with probable bit rot", mismatchCount)
+	}
+
+	return nil
+}
+
+func (c *commandSnapshotVerify) verifySingleSource(ctx context.Context, rep repo.DirectRepository, man *snapshot.Manifest) (int, error) {
+	live, err := localfs.NewEntry(man.Source.Path)
+	if err != nil {
+		log(ctx).Warnf("unable to verify source %v: %v", man.Source, err)
+		return 0, nil
+	}
+
+	root, err := snapshotfs.SnapshotRoot(rep, man)
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to get snapshot root")
+	}
+
+	log(ctx).Infof("Verifying source %v against snapshot %v from %v...", man.Source, man.ID, formatTimestamp(man.StartTime.ToTime()))
+
+	result, err := sourceverify.Verify(ctx, rep, root, live, sourceverify.Options{
+		FilesPercent: c.verifySourcePercent,
+		Parallelism:  c.fileParallelism,
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to verify source")
+	}
+
+	log(ctx).Infof("Re-hashed %v of %v unchanged files (%v), %v mismatches, %v errors.",
+		result.HashedFiles, result.MatchingFiles, units.BytesString(result.HashedBytes), len(result.Mismatches), result.ErrorCount)
+
+	for _, m := range result.Mismatches {
+		c.out.printStdout("Probable bit rot: %v (%v, modified %v)\n", m.Path, units.BytesString(m.Size), formatTimestamp(m.ModTime))
+	}
+
+	sourceverify.Report(ctx, rep, man, result, c.svc.notificationTemplateOptions())
+
+	return len(result.Mismatches), nil
+}
+
+func latestCompleteSnapshot(manifests []*snapshot.Manifest) *snapshot.Manifest {
+	var result *snapshot.Manifest
+
+	for _, m := range manifests {
+		if m.IncompleteReason == "" && (result == nil || m.StartTime.After(result.StartTime)) {
+			result = m
+		}
+	}
+
+	return result
+}
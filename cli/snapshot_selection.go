@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"context"
+	"sort"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// snapshotSelection selects complete snapshots by their IDs or sources, or snapshots of all sources.
+type snapshotSelection struct {
+	snapshots  []string
+	all        bool
+	latestOnly bool
+}
+
+func (c *snapshotSelection) setup(cmd *kingpin.CmdClause, verb string) {
+	cmd.Flag("all", "Select snapshots of all sources").BoolVar(&c.all)
+	cmd.Flag("latest-only", "Only select the latest snapshot of each source").BoolVar(&c.latestOnly)
+	cmd.Arg("snapshot", "Snapshot IDs or sources (user@host:path) to "+verb).StringsVar(&c.snapshots)
+}
+
+func (c *snapshotSelection) resolve(ctx context.Context, rep repo.Repository) ([]*snapshot.Manifest, error) {
+	if c.all == (len(c.snapshots) > 0) {
+		return nil, errors.New("must specify either --all or a list of snapshots or sources")
+	}
+
+	var sources []snapshot.SourceInfo
+
+	if c.all {
+		all, err := snapshot.ListSources(ctx, rep)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to list sources")
+		}
+
+		sources = all
+	}
+
+	var result []*snapshot.Manifest
+
+	for _, arg := range c.snapshots {
+		m, err := snapshot.LoadSnapshot(ctx, rep, manifest.ID(arg))
+		if err == nil {
+			result = append(result, m)
+			continue
+		}
+
+		if !errors.Is(err, snapshot.ErrSnapshotNotFound) {
+			return nil, errors.Wrapf(err, "error loading snapshot %v", arg)
+		}
+
+		si, err := snapshot.ParseSourceInfo(arg, rep.ClientOptions().Hostname, rep.ClientOptions().Username)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%q is neither a snapshot ID nor a source", arg)
+		}
+
+		sources = append(sources, si)
+	}
+
+	for _, si := range sources {
+		snapshots, err := c.sourceSnapshots(ctx, rep, si)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, snapshots...)
+	}
+
+	return result, nil
+}
+
+func (c *snapshotSelection) sourceSnapshots(ctx context.Context, rep repo.Repository, si snapshot.SourceInfo) ([]*snapshot.Manifest, error) {
+	snapshots, err := snapshot.ListSnapshots(ctx, rep, si)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error listing snapshots of %v", si)
+	}
+
+	var result []*snapshot.Manifest
+
+	for _, m := range snapshots {
+		if m.IncompleteReason != "" {
+			log(ctx).Debugf("ignoring incomplete %v at %v", si, formatTimestamp(m.StartTime.ToTime()))
+			continue
+		}
+
+		result = append(result, m)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StartTime.Before(result[j].StartTime)
+	})
+
+	if c.latestOnly && len(result) > 0 {
+		result = result[len(result)-1:]
+	}
+
+	return result, nil
+}
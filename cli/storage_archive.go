@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/ospath"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/archive"
+)
+
+type storageArchiveFlags struct {
+	options archive.Options
+}
+
+func (c *storageArchiveFlags) Setup(_ StorageProviderServices, cmd *kingpin.CmdClause) {
+	cmd.Flag("path", "Path to the archive created using 'snapshot export'").Required().StringVar(&c.options.Path)
+}
+
+func (c *storageArchiveFlags) Connect(ctx context.Context, isCreate bool, _ int) (blob.Storage, error) {
+	if isCreate {
+		return nil, errors.New("archives are created using 'snapshot export'")
+	}
+
+	opt := c.options
+
+	opt.Path = ospath.ResolveUserFriendlyPath(opt.Path, false)
+
+	if !ospath.IsAbs(opt.Path) {
+		return nil, errors.New("archive path must be absolute")
+	}
+
+	//nolint:wrapcheck
+	return archive.New(ctx, &opt, isCreate)
+}
@@ -0,0 +1,274 @@
+// Package archivefs exposes members of tar and zip archives as fs.Directory trees.
+package archivefs
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/virtualfs"
+	"github.com/kopia/kopia/repo/logging"
+)
+
+var log = logging.Module("archivefs")
+
+// Format identifies the format of an archive.
+type Format = string
+
+// Supported archive formats.
+const (
+	FormatTar   Format = "tar"
+	FormatTarGz Format = "tar.gz"
+	FormatZip   Format = "zip"
+)
+
+// DefaultMaxSpoolSize is the default limit of the size of decompressed tar.gz archives.
+const DefaultMaxSpoolSize = 4 << 30
+
+// implicitDirPermissions are the permissions of directories which are not stored in the archive.
+const implicitDirPermissions os.FileMode = 0o755
+
+// FormatFromName returns the archive format based on the extension of the file name or an empty string
+// if the name does not have an extension of a supported archive.
+func FormatFromName(name string) Format {
+	lower := strings.ToLower(name)
+
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return FormatTarGz
+	case strings.HasSuffix(lower, ".tar"):
+		return FormatTar
+	case strings.HasSuffix(lower, ".zip"):
+		return FormatZip
+	default:
+		return ""
+	}
+}
+
+// Options provides options for opening archives.
+type Options struct {
+	// MaxSpoolSize is the maximum size of a decompressed tar.gz archive, which is spooled to a temporary file.
+	// Larger archives are rejected. Zero means DefaultMaxSpoolSize.
+	MaxSpoolSize int64
+}
+
+// Directory is an archive opened as a directory with the same name and metadata as the archive file.
+// Its members can be read until the directory is closed.
+type Directory struct {
+	fs.Directory
+
+	closeOnce sync.Once
+	cleanup   func()
+}
+
+// Close releases resources associated with the archive.
+func (d *Directory) Close() {
+	d.closeOnce.Do(d.cleanup)
+}
+
+// Open opens the archive file in the provided format as a directory.
+// Archives with members that can't be represented in the directory, such as devices or hard links
+// to unknown members, are rejected, so that they can be stored as files without losing any of their contents.
+func Open(ctx context.Context, f fs.File, format Format, opt Options) (*Directory, error) {
+	r, err := f.Open(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to open %v", f.Name())
+	}
+
+	md := virtualfs.Metadata{
+		Mode:    f.Mode(),
+		ModTime: f.ModTime(),
+		Owner:   f.Owner(),
+	}
+
+	b := newTreeBuilder(md)
+	cleanup := func() { r.Close() } //nolint:errcheck
+
+	switch format {
+	case FormatTar:
+		err = readTar(b, r, &lockedReaderAt{r: r})
+
+	case FormatTarGz:
+		maxSpoolSize := opt.MaxSpoolSize
+		if maxSpoolSize == 0 {
+			maxSpoolSize = DefaultMaxSpoolSize
+		}
+
+		cleanup, err = readTarGz(b, r, maxSpoolSize)
+
+	case FormatZip:
+		err = readZip(b, &lockedReaderAt{r: r}, f.Size())
+
+	default:
+		err = errors.Errorf("unsupported archive format %q", format)
+	}
+
+	if err != nil {
+		cleanup()
+		return nil, errors.Wrapf(err, "unable to read %v archive %v", format, f.Name())
+	}
+
+	return &Directory{
+		Directory: b.build(f.Name()),
+		cleanup:   cleanup,
+	}, nil
+}
+
+// lockedReaderAt implements io.ReaderAt over io.ReadSeeker which is not safe for concurrent use.
+type lockedReaderAt struct {
+	mu sync.Mutex
+	// +checklocks:mu
+	r io.ReadSeeker
+}
+
+func (r *lockedReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.r.Seek(off, io.SeekStart); err != nil {
+		return 0, errors.Wrap(err, "seek error")
+	}
+
+	n, err := io.ReadFull(r.r, p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+
+	return n, err //nolint:wrapcheck
+}
+
+// treeNode is a directory or a non-directory entry of the tree being built.
+type treeNode struct {
+	md       virtualfs.Metadata
+	children map[string]*treeNode // nil for non-directories
+
+	// newEntry creates the entry for non-directories.
+	newEntry func(name string) fs.Entry
+}
+
+// treeBuilder builds a directory tree from archive members, which can be listed in any order.
+type treeBuilder struct {
+	root              *treeNode
+	implicitDirParent virtualfs.Metadata
+}
+
+func newTreeBuilder(md virtualfs.Metadata) *treeBuilder {
+	return &treeBuilder{
+		root:              &treeNode{md: md, children: map[string]*treeNode{}},
+		implicitDirParent: md,
+	}
+}
+
+// splitMemberPath returns the components of the member path, which is never allowed to escape the root.
+func splitMemberPath(p string) []string {
+	p = strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(p, "\\", "/")), "/")
+	if p == "" {
+		return nil
+	}
+
+	return strings.Split(p, "/")
+}
+
+// parentOf returns the directory node for the parent of the provided path components, creating missing directories.
+func (b *treeBuilder) parentOf(parts []string) *treeNode {
+	n := b.root
+
+	for _, p := range parts[:len(parts)-1] {
+		c := n.children[p]
+		if c == nil || c.children == nil {
+			c = &treeNode{
+				md: virtualfs.Metadata{
+					Mode:    implicitDirPermissions,
+					ModTime: b.implicitDirParent.ModTime,
+					Owner:   b.implicitDirParent.Owner,
+				},
+				children: map[string]*treeNode{},
+			}
+
+			n.children[p] = c
+		}
+
+		n = c
+	}
+
+	return n
+}
+
+func (b *treeBuilder) addDir(name string, md virtualfs.Metadata) {
+	parts := splitMemberPath(name)
+	if len(parts) == 0 {
+		// the archive root keeps the metadata of the archive file.
+		return
+	}
+
+	parent := b.parentOf(parts)
+	last := parts[len(parts)-1]
+
+	if existing := parent.children[last]; existing != nil && existing.children != nil {
+		existing.md = md
+		return
+	}
+
+	parent.children[last] = &treeNode{md: md, children: map[string]*treeNode{}}
+}
+
+func (b *treeBuilder) addEntry(name string, md virtualfs.Metadata, newEntry func(name string) fs.Entry) error {
+	parts := splitMemberPath(name)
+	if len(parts) == 0 {
+		return errors.Errorf("invalid member name %q", name)
+	}
+
+	// later members replace earlier ones with the same name, which is how archives are extracted.
+	b.parentOf(parts).children[parts[len(parts)-1]] = &treeNode{md: md, newEntry: newEntry}
+
+	return nil
+}
+
+// find returns the node for the provided member name or nil if not found.
+func (b *treeBuilder) find(name string) *treeNode {
+	n := b.root
+
+	for _, p := range splitMemberPath(name) {
+		if n.children == nil {
+			return nil
+		}
+
+		n = n.children[p]
+		if n == nil {
+			return nil
+		}
+	}
+
+	return n
+}
+
+func (b *treeBuilder) build(name string) fs.Directory {
+	return buildNode(name, b.root).(fs.Directory) //nolint:forcetypeassert
+}
+
+func buildNode(name string, n *treeNode) fs.Entry {
+	if n.children == nil {
+		return n.newEntry(name)
+	}
+
+	names := make([]string, 0, len(n.children))
+	for childName := range n.children {
+		names = append(names, childName)
+	}
+
+	sort.Strings(names)
+
+	entries := make([]fs.Entry, 0, len(names))
+	for _, childName := range names {
+		entries = append(entries, buildNode(childName, n.children[childName]))
+	}
+
+	return virtualfs.NewStaticDirectoryWithMetadata(name, n.md, entries)
+}
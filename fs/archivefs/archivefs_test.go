@@ -0,0 +1,279 @@
+package archivefs_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/archivefs"
+	"github.com/kopia/kopia/fs/virtualfs"
+	"github.com/kopia/kopia/internal/testlogging"
+)
+
+var testTime = time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+
+func TestFormatFromName(t *testing.T) {
+	cases := map[string]archivefs.Format{
+		"a.tar":     archivefs.FormatTar,
+		"a.TAR.GZ":  archivefs.FormatTarGz,
+		"a.tgz":     archivefs.FormatTarGz,
+		"a.zip":     archivefs.FormatZip,
+		"a.gz":      "",
+		"a.tar.bz2": "",
+		"tar":       "",
+	}
+
+	for name, want := range cases {
+		require.Equal(t, want, archivefs.FormatFromName(name), name)
+	}
+}
+
+func makeTar(t *testing.T, extra ...*tar.Header) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	tw := tar.NewWriter(&buf)
+
+	write := func(hdr *tar.Header, content string) {
+		hdr.ModTime = testTime
+		hdr.Size = int64(len(content))
+
+		require.NoError(t, tw.WriteHeader(hdr))
+
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+
+	write(&tar.Header{Name: "./dir/", Typeflag: tar.TypeDir, Mode: 0o700, Uid: 5, Gid: 6}, "")
+	write(&tar.Header{Name: "./dir/file1.txt", Typeflag: tar.TypeReg, Mode: 0o640, Uid: 5, Gid: 6}, "hello")
+	write(&tar.Header{Name: "implicit/file2.txt", Typeflag: tar.TypeReg, Mode: 0o600}, "world!")
+	write(&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "dir/file1.txt", Mode: 0o777}, "")
+	write(&tar.Header{Name: "hardlink", Typeflag: tar.TypeLink, Linkname: "dir/file1.txt"}, "")
+	write(&tar.Header{Name: "../escape.txt", Typeflag: tar.TypeReg, Mode: 0o644}, "escaped")
+
+	for _, hdr := range extra {
+		write(hdr, "")
+	}
+
+	require.NoError(t, tw.Close())
+
+	return buf.Bytes()
+}
+
+func makeZip(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	zw := zip.NewWriter(&buf)
+
+	write := func(name string, mode os.FileMode, method uint16, content string) {
+		fh := &zip.FileHeader{Name: name, Method: method, Modified: testTime}
+		fh.SetMode(mode)
+
+		w, err := zw.CreateHeader(fh)
+		require.NoError(t, err)
+
+		_, err = io.WriteString(w, content)
+		require.NoError(t, err)
+	}
+
+	write("dir/", os.ModeDir|0o700, zip.Store, "")
+	write("dir/file1.txt", 0o640, zip.Deflate, "hello")
+	write("implicit/file2.txt", 0o600, zip.Store, "world!")
+	write("link", os.ModeSymlink|0o777, zip.Store, "dir/file1.txt")
+	write("escape.txt", 0o644, zip.Deflate, "escaped")
+
+	require.NoError(t, zw.Close())
+
+	return buf.Bytes()
+}
+
+func gzipped(t *testing.T, b []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write(b)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	return buf.Bytes()
+}
+
+func archiveFile(name string, data []byte) fs.File {
+	return virtualfs.FileFromReaderAt(name, virtualfs.Metadata{
+		Mode:    0o644,
+		ModTime: testTime.Add(time.Hour),
+		Owner:   fs.OwnerInfo{UserID: 1, GroupID: 2},
+	}, int64(len(data)), bytes.NewReader(data))
+}
+
+func readFile(ctx context.Context, t *testing.T, dir fs.Directory, names ...string) string {
+	t.Helper()
+
+	e := lookup(ctx, t, dir, names...)
+
+	f, ok := e.(fs.File)
+	require.True(t, ok, "not a file: %v", names)
+
+	r, err := f.Open(ctx)
+	require.NoError(t, err)
+
+	defer r.Close()
+
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	return string(b)
+}
+
+func lookup(ctx context.Context, t *testing.T, dir fs.Directory, names ...string) fs.Entry {
+	t.Helper()
+
+	var e fs.Entry = dir
+
+	for _, n := range names {
+		d, ok := e.(fs.Directory)
+		require.True(t, ok)
+
+		var err error
+
+		e, err = d.Child(ctx, n)
+		require.NoError(t, err, "child %v", n)
+	}
+
+	return e
+}
+
+func entryNames(ctx context.Context, t *testing.T, dir fs.Directory) []string {
+	t.Helper()
+
+	entries, err := fs.GetAllEntries(ctx, dir)
+	require.NoError(t, err)
+
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+
+	return names
+}
+
+func verifyTree(ctx context.Context, t *testing.T, d fs.Directory, hasOwners bool) {
+	t.Helper()
+
+	require.Equal(t, os.ModeDir|0o644, d.Mode())
+	require.True(t, testTime.Add(time.Hour).Equal(d.ModTime()))
+
+	require.Equal(t, "hello", readFile(ctx, t, d, "dir", "file1.txt"))
+	require.Equal(t, "world!", readFile(ctx, t, d, "implicit", "file2.txt"))
+	require.Equal(t, "escaped", readFile(ctx, t, d, "escape.txt"))
+
+	dir := lookup(ctx, t, d, "dir")
+	require.Equal(t, os.ModeDir|0o700, dir.Mode())
+	require.True(t, testTime.Equal(dir.ModTime()))
+
+	// directories not stored in the archive get default permissions.
+	require.Equal(t, os.ModeDir|0o755, lookup(ctx, t, d, "implicit").Mode())
+
+	f1 := lookup(ctx, t, d, "dir", "file1.txt")
+	require.Equal(t, os.FileMode(0o640), f1.Mode())
+	require.True(t, testTime.Equal(f1.ModTime()))
+	require.Equal(t, int64(5), f1.Size())
+
+	if hasOwners {
+		require.Equal(t, fs.OwnerInfo{UserID: 5, GroupID: 6}, f1.Owner())
+	}
+
+	l, ok := lookup(ctx, t, d, "link").(fs.Symlink)
+	require.True(t, ok)
+
+	target, err := l.Readlink(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "dir/file1.txt", target)
+}
+
+func TestOpen(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	tarData := makeTar(t)
+
+	cases := []struct {
+		format archivefs.Format
+		data   []byte
+	}{
+		{archivefs.FormatTar, tarData},
+		{archivefs.FormatTarGz, gzipped(t, tarData)},
+		{archivefs.FormatZip, makeZip(t)},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.format, func(t *testing.T) {
+			d, err := archivefs.Open(ctx, archiveFile("archive."+tc.format, tc.data), tc.format, archivefs.Options{})
+			require.NoError(t, err)
+
+			defer d.Close()
+
+			require.Equal(t, "archive."+tc.format, d.Name())
+			verifyTree(ctx, t, d, tc.format != archivefs.FormatZip)
+
+			if tc.format != archivefs.FormatZip {
+				require.Equal(t, []string{"dir", "escape.txt", "hardlink", "implicit", "link"}, entryNames(ctx, t, d))
+				require.Equal(t, "hello", readFile(ctx, t, d, "hardlink"))
+			}
+		})
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	for _, format := range []archivefs.Format{archivefs.FormatTar, archivefs.FormatTarGz, archivefs.FormatZip, "rar"} {
+		_, err := archivefs.Open(ctx, archiveFile("bad", bytes.Repeat([]byte("not an archive"), 100)), format, archivefs.Options{})
+		require.Error(t, err, format)
+	}
+}
+
+func TestOpenTarGzExceedingSpoolSize(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	tarData := makeTar(t)
+
+	_, err := archivefs.Open(ctx, archiveFile("archive.tar.gz", gzipped(t, tarData)), archivefs.FormatTarGz, archivefs.Options{
+		MaxSpoolSize: int64(len(tarData)) - 1,
+	})
+	require.ErrorContains(t, err, "exceeds")
+
+	d, err := archivefs.Open(ctx, archiveFile("archive.tar.gz", gzipped(t, tarData)), archivefs.FormatTarGz, archivefs.Options{
+		MaxSpoolSize: int64(len(tarData)),
+	})
+	require.NoError(t, err)
+
+	d.Close()
+}
+
+func TestOpenUnsupportedMembers(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	// archives with members which can't be expanded are rejected, so they are stored as files.
+	for _, hdr := range []*tar.Header{
+		{Name: "fifo", Typeflag: tar.TypeFifo},
+		{Name: "hardlink2", Typeflag: tar.TypeLink, Linkname: "no-such-file"},
+		{Name: ".", Typeflag: tar.TypeReg},
+	} {
+		_, err := archivefs.Open(ctx, archiveFile("archive.tar", makeTar(t, hdr)), archivefs.FormatTar, archivefs.Options{})
+		require.ErrorContains(t, err, hdr.Name)
+	}
+}
@@ -0,0 +1,167 @@
+package archivefs
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/virtualfs"
+)
+
+// countingReader keeps track of the position in the underlying stream, which allows tar member contents
+// to be located without copying them.
+type countingReader struct {
+	r   io.ReadSeeker
+	pos int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.pos += int64(n)
+
+	return n, err //nolint:wrapcheck
+}
+
+// Seek allows the tar reader to skip over member contents.
+func (r *countingReader) Seek(offset int64, whence int) (int64, error) {
+	pos, err := r.r.Seek(offset, whence)
+	if err != nil {
+		return 0, err //nolint:wrapcheck
+	}
+
+	r.pos = pos
+
+	return pos, nil
+}
+
+func isSparse(hdr *tar.Header) bool {
+	if hdr.Typeflag == tar.TypeGNUSparse {
+		return true
+	}
+
+	for k := range hdr.PAXRecords {
+		if strings.HasPrefix(k, "GNU.sparse.") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func tarMetadata(hdr *tar.Header) virtualfs.Metadata {
+	return virtualfs.Metadata{
+		Mode:    os.FileMode(hdr.Mode).Perm(), //nolint:gosec
+		ModTime: hdr.ModTime,
+		Owner: fs.OwnerInfo{
+			UserID:  uint32(hdr.Uid), //nolint:gosec
+			GroupID: uint32(hdr.Gid), //nolint:gosec
+		},
+	}
+}
+
+// readTar adds members of the uncompressed tar archive read from r to the tree, their contents are read from ra.
+func readTar(b *treeBuilder, r io.ReadSeeker, ra io.ReaderAt) error {
+	cr := &countingReader{r: r}
+	tr := tar.NewReader(cr)
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return errors.Wrap(err, "error reading tar header")
+		}
+
+		if isSparse(hdr) {
+			return errors.Errorf("sparse member %v is not supported", hdr.Name)
+		}
+
+		if err := addTarMember(b, hdr, io.NewSectionReader(ra, cr.pos, hdr.Size)); err != nil {
+			return err
+		}
+	}
+}
+
+func addTarMember(b *treeBuilder, hdr *tar.Header, content *io.SectionReader) error {
+	md := tarMetadata(hdr)
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		b.addDir(hdr.Name, md)
+
+		return nil
+
+	case tar.TypeReg:
+		size := hdr.Size
+
+		return b.addEntry(hdr.Name, md, func(name string) fs.Entry {
+			return virtualfs.FileFromReaderAt(name, md, size, content)
+		})
+
+	case tar.TypeSymlink:
+		target := hdr.Linkname
+
+		return b.addEntry(hdr.Name, md, func(name string) fs.Entry {
+			return virtualfs.NewSymlink(name, md, target)
+		})
+
+	case tar.TypeLink:
+		// hard links share the contents of a previous member.
+		target := b.find(hdr.Linkname)
+		if target == nil || target.newEntry == nil {
+			return errors.Errorf("hard link %v to unknown member %v", hdr.Name, hdr.Linkname)
+		}
+
+		return b.addEntry(hdr.Name, target.md, target.newEntry)
+
+	case tar.TypeXGlobalHeader:
+		// global PAX headers only carry metadata.
+		return nil
+
+	default:
+		return errors.Errorf("member %v of type %q is not supported", hdr.Name, hdr.Typeflag)
+	}
+}
+
+// readTarGz decompresses the archive to a temporary file of at most maxSpoolSize bytes, which is read
+// as an uncompressed tar archive and removed by the returned cleanup function.
+func readTarGz(b *treeBuilder, r fs.Reader, maxSpoolSize int64) (cleanup func(), err error) {
+	defer r.Close() //nolint:errcheck
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return func() {}, errors.Wrap(err, "unable to open gzip stream")
+	}
+
+	tmp, err := os.CreateTemp("", "kopia-archive")
+	if err != nil {
+		return func() {}, errors.Wrap(err, "unable to create temporary file")
+	}
+
+	cleanup = func() {
+		tmp.Close()           //nolint:errcheck
+		os.Remove(tmp.Name()) //nolint:errcheck
+	}
+
+	n, err := io.CopyN(tmp, gz, maxSpoolSize+1)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return cleanup, errors.Wrap(err, "unable to decompress archive")
+	}
+
+	if n > maxSpoolSize {
+		return cleanup, errors.Errorf("decompressed archive exceeds %v bytes", maxSpoolSize)
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return cleanup, errors.Wrap(err, "seek error")
+	}
+
+	return cleanup, readTar(b, tmp, tmp)
+}
@@ -0,0 +1,96 @@
+package archivefs
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/virtualfs"
+)
+
+// maxZipSymlinkLength is the maximum length of a symlink target stored in a zip archive.
+const maxZipSymlinkLength = 4096
+
+// readZip adds members of the zip archive to the tree.
+func readZip(b *treeBuilder, ra io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return errors.Wrap(err, "unable to open zip archive")
+	}
+
+	for _, zf := range zr.File {
+		mode := zf.Mode()
+		md := virtualfs.Metadata{
+			Mode:    mode.Perm(),
+			ModTime: zf.Modified,
+		}
+
+		switch {
+		case mode.IsDir():
+			b.addDir(zf.Name, md)
+
+		case mode&os.ModeSymlink != 0:
+			target, err := readZipSymlink(zf)
+			if err != nil {
+				return err
+			}
+
+			if err := b.addEntry(zf.Name, md, func(name string) fs.Entry {
+				return virtualfs.NewSymlink(name, md, target)
+			}); err != nil {
+				return err
+			}
+
+		case mode.IsRegular():
+			if err := addZipFile(b, ra, zf, md); err != nil {
+				return err
+			}
+
+		default:
+			return errors.Errorf("member %v with mode %v is not supported", zf.Name, mode)
+		}
+	}
+
+	return nil
+}
+
+func addZipFile(b *treeBuilder, ra io.ReaderAt, zf *zip.File, md virtualfs.Metadata) error {
+	size := int64(zf.UncompressedSize64) //nolint:gosec
+
+	if zf.Method == zip.Store {
+		// stored members are read directly from the archive, which allows random access.
+		offset, err := zf.DataOffset()
+		if err != nil {
+			return errors.Wrapf(err, "unable to locate %v", zf.Name)
+		}
+
+		content := io.NewSectionReader(ra, offset, size)
+
+		return b.addEntry(zf.Name, md, func(name string) fs.Entry {
+			return virtualfs.FileFromReaderAt(name, md, size, content)
+		})
+	}
+
+	return b.addEntry(zf.Name, md, func(name string) fs.Entry {
+		return virtualfs.FileFromOpener(name, md, size, zf.Open)
+	})
+}
+
+func readZipSymlink(zf *zip.File) (string, error) {
+	rc, err := zf.Open()
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to open %v", zf.Name)
+	}
+
+	defer rc.Close() //nolint:errcheck
+
+	target, err := io.ReadAll(io.LimitReader(rc, maxZipSymlinkLength))
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to read %v", zf.Name)
+	}
+
+	return string(target), nil
+}
@@ -34,6 +34,12 @@ type OwnerInfo struct {
 type DeviceInfo struct {
 	Dev  uint64 `json:"dev"`
 	Rdev uint64 `json:"rdev"`
+
+	// Ino is the inode number of the entry on the device, zero if not known.
+	Ino uint64 `json:"ino,omitempty"`
+
+	// Ctime is the time of the last change of the inode in nanoseconds since the epoch, zero if not known.
+	Ctime int64 `json:"ctime,omitempty"`
 }
 
 // Reader allows reading from a file and retrieving its up-to-date file info.
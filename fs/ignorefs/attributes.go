@@ -0,0 +1,144 @@
+package ignorefs
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+// mimeSniffLength is the number of bytes used to determine the MIME type of a file.
+const mimeSniffLength = 512
+
+// excludingAttributeRule returns the first attribute rule matching the entry or nil if none matches.
+// MIME type rules are skipped, see MIMERules.
+func (c *ignoreContext) excludingAttributeRule(ctx context.Context, e fs.Entry) *policy.AttributeRule {
+	for i := range c.attributeRules {
+		r := &c.attributeRules[i]
+
+		if r.Kind != policy.AttributeRuleMIME && matchesAttributeRule(ctx, r, e) {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// MIMERules applies MIME type rules of policies, which are parsed once for each policy.
+// Determining the MIME type requires reading the file, so these rules are not applied when listing
+// directories, but only to files which are about to be snapshotted, including unchanged ones.
+// The zero value is ready to use.
+type MIMERules struct {
+	parsed sync.Map // *policy.Policy => []policy.AttributeRule
+}
+
+// rules returns the MIME type rules of the policy.
+func (m *MIMERules) rules(pol *policy.Policy) []policy.AttributeRule {
+	if v, ok := m.parsed.Load(pol); ok {
+		return v.([]policy.AttributeRule) //nolint:forcetypeassert
+	}
+
+	var result []policy.AttributeRule
+
+	// invalid rules are reported when listing directories.
+	rules, _ := policy.ParseAttributeRules(pol.FilesPolicy.AttributeRules)
+
+	for _, r := range rules {
+		if r.Kind == policy.AttributeRuleMIME {
+			result = append(result, r)
+		}
+	}
+
+	v, _ := m.parsed.LoadOrStore(pol, result)
+
+	return v.([]policy.AttributeRule) //nolint:forcetypeassert
+}
+
+// ExcludingRule returns the first MIME type rule of the policy matching the file or nil if none matches.
+func (m *MIMERules) ExcludingRule(ctx context.Context, pol *policy.Policy, f fs.File) *policy.AttributeRule {
+	if len(pol.FilesPolicy.AttributeRules) == 0 {
+		return nil
+	}
+
+	rules := m.rules(pol)
+	if len(rules) == 0 {
+		return nil
+	}
+
+	mt := sniffMIMEType(ctx, f)
+
+	for i := range rules {
+		if ok, _ := path.Match(rules[i].MIMEPattern, mt); ok {
+			return &rules[i]
+		}
+	}
+
+	return nil
+}
+
+func matchesAttributeRule(ctx context.Context, r *policy.AttributeRule, e fs.Entry) bool {
+	switch r.Kind {
+	case policy.AttributeRuleOlderThan:
+		return !e.IsDir() && e.ModTime().Before(clock.Now().Add(-r.OlderThan))
+
+	case policy.AttributeRuleSize:
+		return !e.IsDir() && e.Size() >= r.MinSize && (r.MaxSize < 0 || e.Size() <= r.MaxSize)
+
+	case policy.AttributeRuleNoDump:
+		return hasNoDumpAttribute(ctx, e)
+
+	case policy.AttributeRuleType:
+		return matchesFileType(e.Mode(), r.FileType)
+
+	case policy.AttributeRuleUID:
+		return e.Owner().UserID == r.UID
+
+	default:
+		return false
+	}
+}
+
+func matchesFileType(mode os.FileMode, fileType string) bool {
+	switch fileType {
+	case policy.FileTypeSocket:
+		return mode&os.ModeSocket != 0
+	case policy.FileTypeFIFO:
+		return mode&os.ModeNamedPipe != 0
+	case policy.FileTypeDevice:
+		return mode&os.ModeDevice != 0
+	default:
+		return false
+	}
+}
+
+// sniffMIMEType returns the media type of the file based on its contents or an empty string if it cannot be read.
+func sniffMIMEType(ctx context.Context, f fs.File) string {
+	r, err := f.Open(ctx)
+	if err != nil {
+		log(ctx).Debugf("unable to open %v to determine MIME type: %v", f.Name(), err)
+		return ""
+	}
+
+	defer r.Close() //nolint:errcheck
+
+	buf := make([]byte, mimeSniffLength)
+
+	n, err := io.ReadFull(r, buf)
+	if err != nil && n == 0 {
+		return ""
+	}
+
+	mt, _, err := mime.ParseMediaType(http.DetectContentType(buf[:n]))
+	if err != nil {
+		return ""
+	}
+
+	return mt
+}
@@ -0,0 +1,376 @@
+package ignorefs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/wcmatch"
+)
+
+// maxGitConfigIncludeDepth is the maximum nesting of included git configuration files, same as in git.
+const maxGitConfigIncludeDepth = 10
+
+// gitFiles caches git configuration and excludes files read from the local filesystem, which are shared by all git work trees.
+type gitFiles struct {
+	mu sync.Mutex
+	// +checklocks:mu
+	data map[string][]byte // contents by path, nil if the file could not be read
+}
+
+func (g *gitFiles) read(ctx context.Context, path string) []byte {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if b, ok := g.data[path]; ok {
+		return b
+	}
+
+	b, err := os.ReadFile(path) //nolint:gosec
+	if err != nil && !os.IsNotExist(err) {
+		log(ctx).Debugf("unable to read git file %v: %v", path, err)
+	}
+
+	if g.data == nil {
+		g.data = map[string][]byte{}
+	}
+
+	g.data[path] = b
+
+	return b
+}
+
+// excludesRules returns the rules of the excludes file configured for the git work tree.
+func (g *gitFiles) excludesRules(ctx context.Context, baseDir string, workTree, gitDir fs.Directory) ([]ignoreRule, error) {
+	path := g.excludesFilePath(ctx, workTree, gitDir)
+	if path == "" {
+		return nil, nil
+	}
+
+	data := g.read(ctx, path)
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	return parseIgnoreRules(bytes.NewReader(data), baseDir, path, true)
+}
+
+// excludesFilePath returns the path of the excludes file of the git work tree, which is the value of 'core.excludesFile'
+// in the repository configuration or the global configuration of the work tree owner and defaults to '$XDG_CONFIG_HOME/git/ignore'.
+// The repository configuration is only available when '.git' is a directory.
+func (g *gitFiles) excludesFilePath(ctx context.Context, workTree, gitDir fs.Directory) string {
+	home, configHome := gitUserDirs(workTree.Owner())
+
+	c := &gitConfigReader{
+		files:    g,
+		home:     home,
+		workTree: workTree.LocalFilesystemPath(),
+	}
+
+	if gitDir != nil {
+		c.gitDir = gitDir.LocalFilesystemPath()
+	}
+
+	var result string
+
+	// values in later files take precedence.
+	if configHome != "" {
+		result = filepath.Join(configHome, "git", "ignore")
+		c.parseLocalFile(ctx, filepath.Join(configHome, "git", "config"), 0)
+	}
+
+	if home != "" {
+		c.parseLocalFile(ctx, filepath.Join(home, ".gitconfig"), 0)
+	}
+
+	if gitDir != nil {
+		if f := childFile(ctx, gitDir, "config"); f != nil {
+			c.parseFile(ctx, f)
+		}
+	}
+
+	if c.excludesFileSet {
+		result = c.excludesFile
+	}
+
+	return result
+}
+
+// gitUserDirs returns the home and configuration directories of the user whose git configuration applies to a work tree
+// with the provided owner. That is the current user, unless running as root, e.g. as a service snapshotting home directories,
+// in which case it is the owner of the work tree.
+func gitUserDirs(owner fs.OwnerInfo) (home, configHome string) {
+	if os.Geteuid() == 0 && owner.UserID != 0 {
+		u, err := user.LookupId(strconv.FormatUint(uint64(owner.UserID), 10))
+		if err != nil {
+			return "", ""
+		}
+
+		return u.HomeDir, filepath.Join(u.HomeDir, ".config")
+	}
+
+	home, _ = os.UserHomeDir()
+
+	configHome = os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" && home != "" {
+		configHome = filepath.Join(home, ".config")
+	}
+
+	return home, configHome
+}
+
+// gitConfigReader reads 'core.excludesFile' from git configuration files following 'include.path' and
+// 'includeIf.gitdir:<pattern>.path' directives. Other conditional includes are not supported and are skipped.
+type gitConfigReader struct {
+	files    *gitFiles
+	home     string // home directory used to expand '~/'
+	workTree string // local path of the work tree used to resolve relative paths, "" if not known
+	gitDir   string // local path of the '.git' directory used to evaluate 'gitdir:' conditions, "" if not known
+
+	excludesFile    string
+	excludesFileSet bool
+}
+
+// parseFile parses the repository configuration file, whose relative include paths are relative to the '.git' directory.
+func (c *gitConfigReader) parseFile(ctx context.Context, f fs.File) {
+	r, err := f.Open(ctx)
+	if err != nil {
+		log(ctx).Debugf("unable to open git config %v: %v", f.Name(), err)
+		return
+	}
+
+	defer r.Close() //nolint:errcheck
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		log(ctx).Debugf("unable to read git config %v: %v", f.Name(), err)
+		return
+	}
+
+	c.parse(ctx, b, c.gitDir, 0)
+}
+
+func (c *gitConfigReader) parseLocalFile(ctx context.Context, path string, depth int) {
+	if b := c.files.read(ctx, path); len(b) > 0 {
+		c.parse(ctx, b, filepath.Dir(path), depth)
+	}
+}
+
+// parse parses the contents of a git configuration file located in the provided local directory.
+func (c *gitConfigReader) parse(ctx context.Context, data []byte, dir string, depth int) {
+	var section, subsection string
+
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+
+		if strings.HasPrefix(line, "[") {
+			var ok bool
+
+			// entries can follow the section header on the same line.
+			section, subsection, line, ok = parseGitConfigSection(line)
+			if !ok {
+				section, subsection = "", ""
+				continue
+			}
+		}
+
+		key, value, ok := parseGitConfigEntry(line)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case section == "core" && subsection == "" && key == "excludesfile":
+			c.excludesFile = c.resolvePath(value, c.workTree)
+			c.excludesFileSet = true
+
+		case section == "include" && subsection == "" && key == "path",
+			section == "includeif" && key == "path" && c.conditionMatches(subsection, dir):
+			if depth >= maxGitConfigIncludeDepth {
+				log(ctx).Debugf("git config includes nested too deeply, ignoring %v", value)
+				continue
+			}
+
+			if p := c.resolvePath(value, dir); p != "" {
+				c.parseLocalFile(ctx, p, depth+1)
+			}
+		}
+	}
+}
+
+// resolvePath expands '~/' and resolves relative paths against the provided directory, returns "" if not possible.
+func (c *gitConfigReader) resolvePath(p, dir string) string {
+	if rest, ok := strings.CutPrefix(p, "~/"); ok {
+		if c.home == "" {
+			return ""
+		}
+
+		return filepath.Join(c.home, rest)
+	}
+
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+
+	if dir == "" {
+		return ""
+	}
+
+	return filepath.Join(dir, p)
+}
+
+// conditionMatches evaluates the condition of 'includeIf' in a configuration file located in the provided directory.
+func (c *gitConfigReader) conditionMatches(cond, dir string) bool {
+	ignoreCase := false
+
+	pattern, ok := strings.CutPrefix(cond, "gitdir:")
+	if !ok {
+		pattern, ok = strings.CutPrefix(cond, "gitdir/i:")
+		ignoreCase = true
+	}
+
+	if !ok || pattern == "" || c.gitDir == "" {
+		return false
+	}
+
+	trailingSlash := strings.HasSuffix(pattern, "/")
+
+	switch {
+	case strings.HasPrefix(pattern, "~/"), strings.HasPrefix(pattern, "./"):
+		if pattern = c.resolvePath(strings.TrimPrefix(pattern, "./"), dir); pattern == "" {
+			return false
+		}
+
+	case !filepath.IsAbs(pattern):
+		pattern = "**/" + pattern
+	}
+
+	pattern = filepath.ToSlash(pattern)
+
+	// patterns ending with a slash match everything inside.
+	if trailingSlash {
+		pattern = strings.TrimSuffix(pattern, "/") + "/**"
+	}
+
+	m, err := wcmatch.NewWildcardMatcher(pattern, wcmatch.IgnoreCase(ignoreCase))
+	if err != nil {
+		return false
+	}
+
+	return m.Match(filepath.ToSlash(c.gitDir), true)
+}
+
+// parseGitConfigSection parses the section header at the beginning of the line and returns the lowercase section name,
+// the subsection and the remainder of the line. Subsections are case-sensitive, except in the deprecated '[section.subsection]' syntax.
+func parseGitConfigSection(line string) (section, subsection, rest string, ok bool) {
+	header := line[1:]
+
+	i := strings.IndexAny(header, `"]`)
+	if i < 0 {
+		return "", "", "", false
+	}
+
+	section = strings.ToLower(strings.TrimSpace(header[:i]))
+
+	if header[i] == ']' {
+		section, subsection, _ = strings.Cut(section, ".")
+
+		return section, subsection, strings.TrimSpace(header[i+1:]), true
+	}
+
+	var sb strings.Builder
+
+	for j := i + 1; j < len(header); j++ {
+		switch ch := header[j]; {
+		case ch == '\\' && j+1 < len(header):
+			j++
+			sb.WriteByte(header[j])
+
+		case ch == '"':
+			rest, ok = strings.CutPrefix(header[j+1:], "]")
+			return section, sb.String(), strings.TrimSpace(rest), ok
+
+		default:
+			sb.WriteByte(ch)
+		}
+	}
+
+	return "", "", "", false
+}
+
+// parseGitConfigEntry parses the 'key = value' line and returns the lowercase key and the value without comments, quotes and escapes.
+func parseGitConfigEntry(line string) (key, value string, ok bool) {
+	k, v, ok := strings.Cut(line, "=")
+	if !ok {
+		return "", "", false
+	}
+
+	key = strings.ToLower(strings.TrimSpace(k))
+	if key == "" || strings.ContainsAny(key, "#; \t") {
+		return "", "", false
+	}
+
+	return key, parseGitConfigValue(strings.TrimSpace(v)), true
+}
+
+func parseGitConfigValue(v string) string {
+	var (
+		sb       strings.Builder
+		inQuotes bool
+		spaces   string // unquoted whitespace which is only kept when followed by more of the value
+	)
+
+	flushSpaces := func() {
+		sb.WriteString(spaces)
+		spaces = ""
+	}
+
+	for i := 0; i < len(v); i++ {
+		switch ch := v[i]; {
+		case ch == '\\' && i+1 < len(v):
+			i++
+
+			flushSpaces()
+
+			switch v[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'b':
+				sb.WriteByte('\b')
+			default:
+				sb.WriteByte(v[i])
+			}
+
+		case ch == '"':
+			flushSpaces()
+
+			inQuotes = !inQuotes
+
+		case inQuotes:
+			sb.WriteByte(ch)
+
+		case ch == '#', ch == ';':
+			return sb.String()
+
+		case ch == ' ', ch == '\t':
+			spaces += string(ch)
+
+		default:
+			flushSpaces()
+			sb.WriteByte(ch)
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,105 @@
+package ignorefs
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGitConfigValue(t *testing.T) {
+	cases := map[string]string{
+		"plain":                              "plain",
+		"value # comment":                    "value",
+		"value ; comment":                    "value",
+		"value#comment":                      "value",
+		`"quoted # not a comment" # comment`: "quoted # not a comment",
+		`"with \"escaped\" quotes"`:          `with "escaped" quotes`,
+		`back\\slash`:                        `back\slash`,
+		`"  spaces  "`:                       "  spaces  ",
+		"inner   spaces   ":                  "inner   spaces",
+		`tab\tand\nnewline`:                  "tab\tand\nnewline",
+		"":                                   "",
+	}
+
+	for input, want := range cases {
+		require.Equal(t, want, parseGitConfigValue(input), input)
+	}
+}
+
+func TestParseGitConfigSection(t *testing.T) {
+	cases := []struct {
+		line       string
+		section    string
+		subsection string
+		rest       string
+		ok         bool
+	}{
+		{line: "[core]", section: "core", ok: true},
+		{line: "[ Core ]", section: "core", ok: true},
+		{line: `[includeIf "gitdir:~/Work/"]`, section: "includeif", subsection: "gitdir:~/Work/", ok: true},
+		{line: `[core "a \"b\" c"]`, section: "core", subsection: `a "b" c`, ok: true},
+		{line: "[core.Sub]", section: "core", subsection: "sub", ok: true},
+		{line: "[core] excludesFile = x", section: "core", rest: "excludesFile = x", ok: true},
+		{line: "[core"},
+		{line: `[core "sub]`},
+	}
+
+	for _, tc := range cases {
+		section, subsection, rest, ok := parseGitConfigSection(tc.line)
+		require.Equal(t, tc.ok, ok, tc.line)
+
+		if tc.ok {
+			require.Equal(t, tc.section, section, tc.line)
+			require.Equal(t, tc.subsection, subsection, tc.line)
+			require.Equal(t, tc.rest, rest, tc.line)
+		}
+	}
+}
+
+func TestParseGitConfigEntry(t *testing.T) {
+	key, value, ok := parseGitConfigEntry(`excludesFile = "~/my ignore" ; comment`)
+	require.True(t, ok)
+	require.Equal(t, "excludesfile", key)
+	require.Equal(t, "~/my ignore", value)
+
+	for _, line := range []string{"", "# excludesFile = x", "; excludesFile = x", "bare", "= value"} {
+		_, _, ok := parseGitConfigEntry(line)
+		require.False(t, ok, line)
+	}
+}
+
+func TestGitConfigConditionMatches(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses unix paths")
+	}
+
+	c := &gitConfigReader{
+		home:   "/home/user",
+		gitDir: "/home/user/work/project/.git",
+	}
+
+	cases := map[string]bool{
+		"gitdir:~/work/":                      true,
+		"gitdir:/home/user/work/":             true,
+		"gitdir:/home/user/work/project/.git": true,
+		"gitdir:work/":                        true,
+		"gitdir:project/.git":                 true,
+		"gitdir:./work/":                      true,
+		"gitdir:~/other/":                     false,
+		"gitdir:/home/user/work":              false,
+		"gitdir:~/WORK/":                      false,
+		"gitdir/i:~/WORK/":                    true,
+		"gitdir:":                             false,
+		"onbranch:main":                       false,
+		"hasconfig:remote.*.url:https://x/**": false,
+	}
+
+	for cond, want := range cases {
+		require.Equal(t, want, c.conditionMatches(cond, "/home/user"), cond)
+	}
+
+	// conditions can't match when the location of the '.git' directory is not known.
+	c.gitDir = ""
+	require.False(t, c.conditionMatches("gitdir:~/work/", "/home/user"))
+}
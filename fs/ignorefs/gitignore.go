@@ -0,0 +1,88 @@
+package ignorefs
+
+import (
+	"context"
+
+	"github.com/kopia/kopia/fs"
+)
+
+const (
+	gitIgnoreFileName = ".gitignore"
+	gitDirName        = ".git"
+)
+
+// gitRules are git ignore rules found in a directory.
+type gitRules struct {
+	workTree bool         // directory contains '.git' and is the root of a git work tree
+	rules    []ignoreRule // rules in the order of increasing precedence
+}
+
+// loadGitRules returns the rules of the excludes file configured by 'core.excludesFile' and '.git/info/exclude' for roots
+// of git work trees followed by the rules of '.gitignore', which is the same precedence git uses.
+func (d *ignoreDirectory) loadGitRules(ctx context.Context) (gitRules, error) {
+	var result gitRules
+
+	if e, err := d.Directory.Child(ctx, gitDirName); err == nil {
+		result.workTree = true
+
+		// '.git' is a file pointing elsewhere in submodules and linked work trees.
+		gitDir, _ := e.(fs.Directory)
+
+		excludes, err := d.parentContext.git.excludesRules(ctx, d.relativePath, d.Directory, gitDir)
+		if err != nil {
+			return gitRules{}, err
+		}
+
+		result.rules = append(result.rules, excludes...)
+
+		if gitDir != nil {
+			if f := childFile(ctx, gitDir, "info", "exclude"); f != nil {
+				rules, err := parseIgnoreFile(ctx, d.relativePath, ignoreFile{d.relativePath + "/" + gitDirName + "/info/exclude", f}, true)
+				if err != nil {
+					return gitRules{}, err
+				}
+
+				result.rules = append(result.rules, rules...)
+			}
+		}
+	}
+
+	f, err := d.findIgnoreFile(ctx, gitIgnoreFileName)
+	if err != nil {
+		return gitRules{}, err
+	}
+
+	if f != nil {
+		rules, err := parseIgnoreFile(ctx, d.relativePath, ignoreFile{d.relativePath + "/" + gitIgnoreFileName, f}, true)
+		if err != nil {
+			return gitRules{}, err
+		}
+
+		result.rules = append(result.rules, rules...)
+	}
+
+	return result, nil
+}
+
+// childFile returns the file at the provided path relative to the directory or nil if not found.
+func childFile(ctx context.Context, dir fs.Directory, names ...string) fs.File {
+	var e fs.Entry = dir
+
+	for _, n := range names {
+		d, ok := e.(fs.Directory)
+		if !ok {
+			return nil
+		}
+
+		c, err := d.Child(ctx, n)
+		if err != nil {
+			return nil
+		}
+
+		e = c
+	}
+
+	f, _ := e.(fs.File)
+
+	return f
+}
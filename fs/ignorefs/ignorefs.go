@@ -1,9 +1,20 @@
 // Package ignorefs implements a wrapper that hides ignored files listed in '.kopiaignore' and in policies attached to directories.
+//
+// Ignore rules use .gitignore syntax and are evaluated starting with the root directory. Within each directory
+// the rules come from (in order): the policy defined for the directory, git exclude files of a git work tree
+// rooted in the directory, the '.gitignore' file and other dot-ignore files. The last rule matching an entry
+// decides whether it is ignored, so rules in subdirectories can re-include entries ignored by their parents, but
+// entries in ignored directories can never be re-included because ignored directories are not entered.
+//
+// When include rules are defined, entries which are not ignored are additionally required to match an include rule
+// or to be inside a directory matching one. Directories are always entered to look for included entries.
 package ignorefs
 
 import (
 	"bufio"
 	"context"
+	"fmt"
+	"io"
 	"strings"
 	"sync"
 
@@ -24,41 +35,105 @@
 )
 
 // IgnoreCallback is a function called by ignorefs to report whenever a file or directory is being ignored while listing its parent.
-type IgnoreCallback func(ctx context.Context, path string, metadata fs.Entry, pol *policy.Tree)
+// The reason is a human-readable description of why the entry was ignored.
+type IgnoreCallback func(ctx context.Context, path string, metadata fs.Entry, pol *policy.Tree, reason string)
+
+// ignoreRule is a rule together with the description of where it was defined.
+type ignoreRule struct {
+	wcmatch.WildcardMatcher
+
+	source  string // policy or file defining the rule
+	fromGit bool   // rule comes from git ignore files and only applies within its git work tree
+}
+
+func (r *ignoreRule) String() string {
+	return fmt.Sprintf("rule %q from %v", r.Pattern(), r.source)
+}
 
 type ignoreContext struct {
 	parent *ignoreContext
 
 	onIgnore []IgnoreCallback
+	git      *gitFiles
 
-	dotIgnoreFiles []string                  // which files to look for more ignore rules
-	matchers       []wcmatch.WildcardMatcher // current set of rules to ignore files
-	maxFileSize    int64                     // maximum size of file allowed
+	dotIgnoreFiles []string               // which files to look for more ignore rules
+	matchers       []ignoreRule           // current set of rules to ignore files
+	includes       []ignoreRule           // current set of rules to include files
+	hasIncludes    bool                   // whether this or any parent context has include rules
+	gitWorkTree    bool                   // directory is the root of a git work tree, where git rules of parent directories don't apply
+	maxFileSize    int64                  // maximum size of file allowed
+	attributeRules []policy.AttributeRule // rules to exclude entries based on their attributes
 
 	oneFileSystem bool // should we enter other mounted filesystems
 }
 
-func (c *ignoreContext) shouldIncludeByName(ctx context.Context, path string, e fs.Entry, policyTree *policy.Tree) bool {
-	shouldIgnore := false
+// ignoringRule returns the rule which causes the path to be ignored or nil if the path is not ignored.
+func (c *ignoreContext) ignoringRule(path string, isDir, skipGitRules bool) *ignoreRule {
+	var result *ignoreRule
 
 	// Start by checking with any ignores defined in a parent directory (if there is one).
 	// Any matches here may be negated by .ignore-files in lower directories.
 	if c.parent != nil {
-		shouldIgnore = !c.parent.shouldIncludeByName(ctx, path, e, policyTree)
+		result = c.parent.ignoringRule(path, isDir, skipGitRules || c.gitWorkTree)
 	}
 
-	for _, m := range c.matchers {
+	for i := range c.matchers {
+		m := &c.matchers[i]
+
+		if skipGitRules && m.fromGit {
+			continue
+		}
+
 		// If we already matched a pattern and concluded that the path should be ignored, we only check
-		// negated patterns (and vice versa)
-		if !shouldIgnore && !m.Negated() || shouldIgnore && m.Negated() {
-			shouldIgnore = m.Match(trimLeadingCurrentDir(path), e.IsDir())
+		// negated patterns (and vice versa). Note that negated matchers report a match when the path does not match.
+		switch {
+		case result == nil && !m.Negated():
+			if m.Match(path, isDir) {
+				result = m
+			}
+
+		case result != nil && m.Negated():
+			if !m.Match(path, isDir) {
+				result = nil
+			}
 		}
 	}
 
-	if shouldIgnore {
-		for _, oi := range c.onIgnore {
-			oi(ctx, strings.TrimPrefix(path, "./"), e, policyTree)
+	return result
+}
+
+// isIncluded returns true if the path matches include rules, starting with the provided state of its parent directory.
+func (c *ignoreContext) isIncluded(path string, isDir, parentIncluded bool) bool {
+	result := parentIncluded
+
+	if c.parent != nil {
+		result = c.parent.isIncluded(path, isDir, parentIncluded)
+	}
+
+	for i := range c.includes {
+		m := &c.includes[i]
+
+		switch {
+		case !result && !m.Negated():
+			result = m.Match(path, isDir)
+
+		case result && m.Negated():
+			result = m.Match(path, isDir)
 		}
+	}
+
+	return result
+}
+
+func (c *ignoreContext) reportIgnored(ctx context.Context, path string, e fs.Entry, policyTree *policy.Tree, reason string) {
+	for _, oi := range c.onIgnore {
+		oi(ctx, strings.TrimPrefix(path, "./"), e, policyTree, reason)
+	}
+}
+
+func (c *ignoreContext) shouldIncludeByName(ctx context.Context, path string, e fs.Entry, policyTree *policy.Tree) bool {
+	if r := c.ignoringRule(trimLeadingCurrentDir(path), e.IsDir(), false); r != nil {
+		c.reportIgnored(ctx, path, e, policyTree, "ignored by "+r.String())
 
 		return false
 	}
@@ -78,6 +153,7 @@ type ignoreDirectory struct {
 	relativePath  string
 	parentContext *ignoreContext
 	policyTree    *policy.Tree
+	included      bool // directory matches include rules, so all its entries are included unless excluded again
 
 	fs.Directory
 }
@@ -133,9 +209,7 @@ func (d *ignoreDirectory) skipCacheDirectory(ctx context.Context, relativePath s
 	}
 
 	// if the given directory contains a marker file used for kopia cache, pretend the directory was empty.
-	for _, oi := range d.parentContext.onIgnore {
-		oi(ctx, strings.TrimPrefix(relativePath, "./"), d, policyTree)
-	}
+	d.parentContext.reportIgnored(ctx, relativePath, d, policyTree, "cache directory containing "+cachedir.CacheDirMarkerFile)
 
 	return true
 }
@@ -231,24 +305,42 @@ func (d *ignoreDirectory) maybeWrappedChildEntry(ctx context.Context, ic *ignore
 	}
 
 	if maxSize := ic.maxFileSize; maxSize > 0 && e.Size() > maxSize {
+		ic.reportIgnored(ctx, s, e, d.policyTree, fmt.Sprintf("larger than maximum file size of %v bytes", maxSize))
 		return nil, false
 	}
 
 	if !ic.shouldIncludeByDevice(e, d) {
+		ic.reportIgnored(ctx, s, e, d.policyTree, "on a different file system")
 		return nil, false
 	}
 
+	if r := ic.excludingAttributeRule(ctx, e); r != nil {
+		ic.reportIgnored(ctx, s, e, d.policyTree, fmt.Sprintf("matched attribute rule %q", r))
+		return nil, false
+	}
+
+	included := true
+	if ic.hasIncludes {
+		included = ic.isIncluded(trimLeadingCurrentDir(s), e.IsDir(), d.included)
+	}
+
 	if dir, ok := e.(fs.Directory); ok {
 		id := ignoreDirectoryPool.Get().(*ignoreDirectory) //nolint:forcetypeassert
 
 		id.relativePath = s
 		id.parentContext = ic
 		id.policyTree = d.policyTree.Child(e.Name())
+		id.included = included
 		id.Directory = dir
 
 		return id, true
 	}
 
+	if !included {
+		ic.reportIgnored(ctx, s, e, d.policyTree, "not matched by any include rule")
+		return nil, false
+	}
+
 	return e, true
 }
 
@@ -299,6 +391,31 @@ func resolveSymlink(ctx context.Context, entry fs.Symlink) (fs.File, error) {
 	return nil, errors.Wrapf(errTooManySymlinks, "cannot resolve '%q'", entry.Name())
 }
 
+// findIgnoreFile returns the file with the provided name in the directory following symlinks or nil if there is no such file.
+func (d *ignoreDirectory) findIgnoreFile(ctx context.Context, name string) (fs.File, error) {
+	e, err := d.Directory.Child(ctx, name)
+	if err != nil {
+		return nil, nil //nolint:nilerr
+	}
+
+	switch entry := e.(type) {
+	case fs.File:
+		return entry, nil
+
+	case fs.Symlink:
+		return resolveSymlink(ctx, entry)
+
+	default:
+		return nil, nil
+	}
+}
+
+// ignoreFile is a file with ignore rules found in a directory.
+type ignoreFile struct {
+	source string // path of the file relative to the root
+	file   fs.File
+}
+
 func (d *ignoreDirectory) buildContext(ctx context.Context) (*ignoreContext, error) {
 	effectiveDotIgnoreFiles := d.parentContext.dotIgnoreFiles
 
@@ -307,26 +424,37 @@ func (d *ignoreDirectory) buildContext(ctx context.Context) (*ignoreContext, err
 		effectiveDotIgnoreFiles = pol.FilesPolicy.DotIgnoreFiles
 	}
 
-	var dotIgnoreFiles []fs.File
+	gitIgnore := d.policyTree.EffectivePolicy().FilesPolicy.GitIgnore.OrDefault(false)
+
+	var dotIgnoreFiles []ignoreFile
 
 	for _, dotfile := range effectiveDotIgnoreFiles {
-		if e, err := d.Directory.Child(ctx, dotfile); err == nil {
-			switch entry := e.(type) {
-			case fs.File:
-				dotIgnoreFiles = append(dotIgnoreFiles, entry)
-
-			case fs.Symlink:
-				target, err := resolveSymlink(ctx, entry)
-				if err != nil {
-					return nil, err
-				}
-
-				dotIgnoreFiles = append(dotIgnoreFiles, target)
-			}
+		if gitIgnore && dotfile == gitIgnoreFileName {
+			// loaded with git rules
+			continue
+		}
+
+		f, err := d.findIgnoreFile(ctx, dotfile)
+		if err != nil {
+			return nil, err
+		}
+
+		if f != nil {
+			dotIgnoreFiles = append(dotIgnoreFiles, ignoreFile{d.relativePath + "/" + dotfile, f})
 		}
 	}
 
-	if len(dotIgnoreFiles) == 0 && pol == nil {
+	var git gitRules
+
+	if gitIgnore {
+		var err error
+
+		if git, err = d.loadGitRules(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(dotIgnoreFiles) == 0 && pol == nil && !git.workTree && len(git.rules) == 0 {
 		// no dotfiles and no policy at this level, reuse parent ignore rules
 		return d.parentContext, nil
 	}
@@ -334,8 +462,12 @@ func (d *ignoreDirectory) buildContext(ctx context.Context) (*ignoreContext, err
 	newic := &ignoreContext{
 		parent:         d.parentContext,
 		onIgnore:       d.parentContext.onIgnore,
+		git:            d.parentContext.git,
 		dotIgnoreFiles: effectiveDotIgnoreFiles,
+		hasIncludes:    d.parentContext.hasIncludes,
+		gitWorkTree:    git.workTree,
 		maxFileSize:    d.parentContext.maxFileSize,
+		attributeRules: d.parentContext.attributeRules,
 		oneFileSystem:  d.parentContext.oneFileSystem,
 	}
 
@@ -343,8 +475,18 @@ func (d *ignoreDirectory) buildContext(ctx context.Context) (*ignoreContext, err
 		if err := newic.overrideFromPolicy(&pol.FilesPolicy, d.relativePath); err != nil {
 			return nil, err
 		}
+
+		// attribute rules are not accumulated, the most specific policy defining them wins.
+		rules, err := policy.ParseAttributeRules(d.policyTree.EffectivePolicy().FilesPolicy.AttributeRules)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to parse attribute rules for %v", d.relativePath)
+		}
+
+		newic.attributeRules = rules
 	}
 
+	newic.matchers = append(newic.matchers, git.rules...)
+
 	if err := newic.loadDotIgnoreFiles(ctx, d.relativePath, dotIgnoreFiles); err != nil {
 		return nil, err
 	}
@@ -368,6 +510,8 @@ func (c *ignoreContext) overrideFromPolicy(fp *policy.FilesPolicy, dirPath strin
 
 	c.oneFileSystem = fp.OneFileSystem.OrDefault(false)
 
+	source := "policy for " + dirPath
+
 	// append policy-level rules
 	for _, rule := range fp.IgnoreRules {
 		m, err := wcmatch.NewWildcardMatcher(rule, wcmatch.IgnoreCase(false), wcmatch.BaseDir(trimLeadingCurrentDir(dirPath)))
@@ -375,17 +519,27 @@ func (c *ignoreContext) overrideFromPolicy(fp *policy.FilesPolicy, dirPath strin
 			return errors.Wrapf(err, "unable to parse ignore entry %v", dirPath)
 		}
 
-		c.matchers = append(c.matchers, *m)
+		c.matchers = append(c.matchers, ignoreRule{WildcardMatcher: *m, source: source})
+	}
+
+	for _, rule := range fp.IncludeRules {
+		m, err := wcmatch.NewWildcardMatcher(rule, wcmatch.IgnoreCase(false), wcmatch.BaseDir(trimLeadingCurrentDir(dirPath)))
+		if err != nil {
+			return errors.Wrapf(err, "unable to parse include entry %v", dirPath)
+		}
+
+		c.includes = append(c.includes, ignoreRule{WildcardMatcher: *m, source: source})
+		c.hasIncludes = true
 	}
 
 	return nil
 }
 
-func (c *ignoreContext) loadDotIgnoreFiles(ctx context.Context, dirPath string, dotIgnoreFiles []fs.File) error {
+func (c *ignoreContext) loadDotIgnoreFiles(ctx context.Context, dirPath string, dotIgnoreFiles []ignoreFile) error {
 	for _, f := range dotIgnoreFiles {
-		matchers, err := parseIgnoreFile(ctx, dirPath, f)
+		matchers, err := parseIgnoreFile(ctx, dirPath, f, false)
 		if err != nil {
-			return errors.Wrapf(err, "unable to parse ignore file %v", f.Name())
+			return errors.Wrapf(err, "unable to parse ignore file %v", f.file.Name())
 		}
 
 		c.matchers = append(c.matchers, matchers...)
@@ -414,22 +568,24 @@ func combineAndDedupe(slices ...[]string) []string {
 	return result
 }
 
-func parseIgnoreFile(ctx context.Context, baseDir string, file fs.File) ([]wcmatch.WildcardMatcher, error) {
-	f, err := file.Open(ctx)
+func parseIgnoreFile(ctx context.Context, baseDir string, f ignoreFile, fromGit bool) ([]ignoreRule, error) {
+	r, err := f.file.Open(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to open ignore file")
 	}
-	defer f.Close() //nolint:errcheck
+	defer r.Close() //nolint:errcheck
 
-	var matchers []wcmatch.WildcardMatcher
+	return parseIgnoreRules(r, baseDir, f.source, fromGit)
+}
+
+func parseIgnoreRules(r io.Reader, baseDir, source string, fromGit bool) ([]ignoreRule, error) {
+	var matchers []ignoreRule
 
 	// Remove the "current directory" indicator from the baseDir if present, since wcmatch does
 	// not deal with that.
-	if strings.HasPrefix(baseDir, "./") || baseDir == "." {
-		baseDir = baseDir[1:]
-	}
+	baseDir = trimLeadingCurrentDir(baseDir)
 
-	s := bufio.NewScanner(f)
+	s := bufio.NewScanner(r)
 	for s.Scan() {
 		line := s.Text()
 
@@ -443,15 +599,15 @@ func parseIgnoreFile(ctx context.Context, baseDir string, file fs.File) ([]wcmat
 			continue
 		}
 
-		m, err := wcmatch.NewWildcardMatcher(line, wcmatch.IgnoreCase(false), wcmatch.BaseDir(trimLeadingCurrentDir(baseDir)))
+		m, err := wcmatch.NewWildcardMatcher(line, wcmatch.IgnoreCase(false), wcmatch.BaseDir(baseDir))
 		if err != nil {
 			return nil, errors.Wrapf(err, "unable to parse ignore entry %v", line)
 		}
 
-		matchers = append(matchers, *m)
+		matchers = append(matchers, ignoreRule{WildcardMatcher: *m, source: source, fromGit: fromGit})
 	}
 
-	return matchers, nil
+	return matchers, errors.Wrap(s.Err(), "error reading ignore rules")
 }
 
 // trimLeadingCurrentDir strips a leading "./" from a directory, or replace with empty string if the directory contains only a ".".
@@ -468,13 +624,13 @@ func trimLeadingCurrentDir(dir string) string {
 
 // New returns a fs.Directory that wraps another fs.Directory and hides files specified in the ignore dotfiles.
 func New(dir fs.Directory, policyTree *policy.Tree, options ...Option) fs.Directory {
-	rootContext := &ignoreContext{}
+	rootContext := &ignoreContext{git: &gitFiles{}}
 
 	for _, opt := range options {
 		opt(rootContext)
 	}
 
-	return &ignoreDirectory{".", rootContext, policyTree, dir}
+	return &ignoreDirectory{relativePath: ".", parentContext: rootContext, policyTree: policyTree, Directory: dir}
 }
 
 var _ fs.Directory = &ignoreDirectory{}
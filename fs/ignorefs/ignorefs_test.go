@@ -3,14 +3,22 @@
 import (
 	"bytes"
 	"context"
+	"os"
+	"os/user"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/kylelemons/godebug/pretty"
 	"github.com/stretchr/testify/require"
 
 	"github.com/kopia/kopia/fs"
 	"github.com/kopia/kopia/fs/ignorefs"
+	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/fs/virtualfs"
+	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/internal/mockfs"
 	"github.com/kopia/kopia/internal/testlogging"
 	"github.com/kopia/kopia/snapshot/policy"
@@ -555,6 +563,157 @@ func setupFilesystem(skipDefaultFiles bool) *mockfs.Directory {
 		},
 		ignoredFiles: []string{},
 	},
+	{
+		desc: "gitignore files with negation and nested files",
+		setup: func(root *mockfs.Directory) {
+			root.AddFileLines(".gitignore", []string{
+				"# comment",
+				"file*",
+				"!file2",
+				"/bin/",
+			}, 0)
+			root.Subdir("src").AddFileLines(".gitignore", []string{
+				"some-src/",
+				"!file1",
+			}, 0)
+			root.Subdir("src").AddFile("file1", dummyFileContents, 0)  // re-included by ./src/.gitignore
+			root.Subdir("src").AddFile("file3", dummyFileContents, 0)  // ignored by ./.gitignore
+			root.Subdir("pkg").AddFile("bin", dummyFileContents, 0)    // not ignored, anchored pattern for directory
+			root.Subdir("pkg").AddFile("file22", dummyFileContents, 0) // ignored by ./.gitignore
+		},
+		policyTree: policy.BuildTree(map[string]*policy.Policy{
+			".": {
+				FilesPolicy: policy.FilesPolicy{
+					GitIgnore: &trueValue,
+				},
+			},
+		}, policy.DefaultPolicy),
+		addedFiles: []string{
+			"./.gitignore",
+			"./src/.gitignore",
+			"./src/file1",
+			"./pkg/bin",
+		},
+		ignoredFiles: []string{
+			"./file1",
+			"./file3",
+			"./bin/",
+			"./bin/some-bin",
+			"./src/some-src/",
+			"./src/some-src/f1",
+			"./src/file3",
+			"./pkg/file22",
+		},
+	},
+	{
+		desc: "gitignore files are not used by default",
+		setup: func(root *mockfs.Directory) {
+			root.AddFileLines(".gitignore", []string{"file*"}, 0)
+		},
+		addedFiles: []string{
+			"./.gitignore",
+		},
+	},
+	{
+		desc: "git info exclude and nested work tree",
+		setup: func(root *mockfs.Directory) {
+			root.AddFileLines(".gitignore", []string{"*.log"}, 0)
+			root.AddDir(".git", 0).AddDir("info", 0).AddFileLines("exclude", []string{"file1", "!a.log"}, 0)
+			root.AddFile("a.log", dummyFileContents, 0) // .gitignore has precedence over .git/info/exclude
+			root.AddFile("b.log", dummyFileContents, 0)
+
+			// submodule with its own work tree, where rules of the parent work tree do not apply.
+			root.Subdir("src").AddFileLines(".git", []string{"gitdir: ../.git/modules/src"}, 0)
+			root.Subdir("src").AddFile("c.log", dummyFileContents, 0)
+			root.Subdir("src").AddFile("file1", dummyFileContents, 0)
+		},
+		policyTree: policy.BuildTree(map[string]*policy.Policy{
+			".": {
+				FilesPolicy: policy.FilesPolicy{
+					GitIgnore: &trueValue,
+				},
+			},
+		}, policy.DefaultPolicy),
+		addedFiles: []string{
+			"./.gitignore",
+			"./.git/",
+			"./.git/info/",
+			"./.git/info/exclude",
+			"./src/.git",
+			"./src/c.log",
+			"./src/file1",
+		},
+		ignoredFiles: []string{
+			"./file1",
+			"./a.log",
+			"./b.log",
+		},
+	},
+	{
+		desc: "include rules",
+		setup: func(root *mockfs.Directory) {
+			root.Subdir("bin").AddFile("skip", dummyFileContents, 0)
+			root.Subdir("src").AddFile("a.go", dummyFileContents, 0)
+			root.Subdir("src").Subdir("some-src").AddFile("b.go", dummyFileContents, 0)
+		},
+		policyTree: policy.BuildTree(map[string]*policy.Policy{
+			".": {
+				FilesPolicy: policy.FilesPolicy{
+					IncludeRules: []string{
+						"*.go",
+						"bin/",
+						"!bin/skip",
+					},
+				},
+			},
+		}, policy.DefaultPolicy),
+		addedFiles: []string{
+			"./src/a.go",
+			"./src/some-src/b.go",
+		},
+		ignoredFiles: []string{
+			"./file1",
+			"./file2",
+			"./file3",
+			"./ignored-by-rule",
+			"./largefile1",
+			"./bin/skip",
+			"./pkg/some-pkg",
+			"./src/some-src/f1",
+		},
+	},
+	{
+		desc: "ignore rules take precedence over include rules",
+		setup: func(root *mockfs.Directory) {
+			root.Subdir("src").AddFile("a.go", dummyFileContents, 0)
+			root.Subdir("src").AddFile("a_test.go", dummyFileContents, 0)
+		},
+		policyTree: policy.BuildTree(map[string]*policy.Policy{
+			".": {
+				FilesPolicy: policy.FilesPolicy{
+					IncludeRules: []string{"*.go", "file1"},
+				},
+			},
+			"./src": {
+				FilesPolicy: policy.FilesPolicy{
+					IgnoreRules: []string{"*_test.go"},
+				},
+			},
+		}, policy.DefaultPolicy),
+		addedFiles: []string{
+			"./src/a.go",
+		},
+		ignoredFiles: []string{
+			"./file2",
+			"./file3",
+			"./ignored-by-rule",
+			"./largefile1",
+			"./bin/some-bin",
+			"./pkg/some-pkg",
+			"./src/some-src/f1",
+			"./src/a_test.go",
+		},
+	},
 }
 
 func TestIgnoreFS(t *testing.T) {
@@ -640,3 +799,340 @@ func verifyDirectoryTree(t *testing.T, dir fs.Directory, expected []string) {
 	diff := pretty.Compare(output, expected)
 	require.Empty(t, diff, "unexpected directory tree, diff(-got,+want)")
 }
+
+func TestIgnoreFS_ReportsReasons(t *testing.T) {
+	root := setupFilesystem(false)
+	root.AddFileLines(".gitignore", []string{"file1"}, 0)
+	root.Subdir("src").AddFileLines(".kopiaignore", []string{"some-*"}, 0)
+
+	pt := policy.BuildTree(map[string]*policy.Policy{
+		".": {
+			FilesPolicy: policy.FilesPolicy{
+				GitIgnore:      &trueValue,
+				DotIgnoreFiles: []string{".kopiaignore"},
+				IgnoreRules:    []string{"*-by-rule"},
+				IncludeRules:   []string{"file*", "src/", "largefile1"},
+				MaxFileSize:    int64(len(tooLargeFileContents)) - 1,
+			},
+		},
+	}, policy.DefaultPolicy)
+
+	reasons := map[string]string{}
+
+	ifs := ignorefs.New(root, pt, ignorefs.ReportIgnoredFiles(func(_ context.Context, path string, _ fs.Entry, _ *policy.Tree, reason string) {
+		reasons[path] = reason
+	}))
+
+	walkTree(t, ifs)
+
+	require.Equal(t, map[string]string{
+		"file1":           `ignored by rule "file1" from ./.gitignore`,
+		"ignored-by-rule": `ignored by rule "*-by-rule" from policy for .`,
+		"largefile1":      "larger than maximum file size of 4999999 bytes",
+		"bin/some-bin":    "not matched by any include rule",
+		"pkg/some-pkg":    "not matched by any include rule",
+		"src/some-src":    `ignored by rule "some-*" from ./src/.kopiaignore`,
+		".gitignore":      "not matched by any include rule",
+	}, reasons)
+}
+
+func TestIgnoreFS_GitGlobalExcludesFile(t *testing.T) {
+	home := t.TempDir()
+
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".gitconfig"), []byte("[user]\n\tname = someone\n[Core]\n\texcludesFile = \"~/global-ignore\"\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(home, "global-ignore"), []byte("*.tmp\n"), 0o600))
+
+	root := mockfs.NewDirectory()
+	root.AddFile("a.tmp", dummyFileContents, 0)
+	root.AddDir("not-a-repo", 0).AddFile("b.tmp", dummyFileContents, 0)
+
+	repoDir := root.AddDir("repo", 0)
+	repoDir.AddDir(".git", 0)
+	repoDir.AddFile("c.tmp", dummyFileContents, 0)
+	repoDir.AddFileLines(".gitignore", []string{"!d.tmp"}, 0)
+	repoDir.AddFile("d.tmp", dummyFileContents, 0)
+
+	pt := policy.BuildTree(map[string]*policy.Policy{
+		".": {
+			FilesPolicy: policy.FilesPolicy{
+				GitIgnore: &trueValue,
+			},
+		},
+	}, policy.DefaultPolicy)
+
+	// excludes file only applies in git work trees.
+	verifyDirectoryTree(t, ignorefs.New(root, pt), []string{
+		"./",
+		"./a.tmp",
+		"./not-a-repo/",
+		"./not-a-repo/b.tmp",
+		"./repo/",
+		"./repo/.git/",
+		"./repo/.gitignore",
+		"./repo/d.tmp",
+	})
+}
+
+func TestIgnoreFS_GitConfigIncludes(t *testing.T) {
+	home := t.TempDir()
+
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".gitconfig"), []byte(`[core]
+	excludesFile = ~/not-used
+[include]
+	path = included.gitconfig ; relative to the including file
+[core "subsection"]
+	excludesFile = ~/not-used-either
+`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(home, "included.gitconfig"), []byte("[core] excludesFile = \"~/global-ignore\" # comment\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(home, "global-ignore"), []byte("*.tmp\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(home, "not-used"), []byte("*\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(home, "not-used-either"), []byte("*\n"), 0o600))
+
+	root := mockfs.NewDirectory()
+	root.AddDir(".git", 0)
+	root.AddFile("a.tmp", dummyFileContents, 0)
+	root.AddFile("b.txt", dummyFileContents, 0)
+
+	verifyDirectoryTree(t, ignorefs.New(root, gitIgnorePolicyTree()), []string{
+		"./",
+		"./.git/",
+		"./b.txt",
+	})
+}
+
+func TestIgnoreFS_GitConfigConditionalIncludes(t *testing.T) {
+	home := t.TempDir()
+	dir := t.TempDir()
+
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".gitconfig"), []byte(`[includeIf "gitdir:`+filepath.ToSlash(dir)+`/work/"]
+	path = work.gitconfig
+[includeIf "onbranch:main"]
+	path = other.gitconfig
+`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(home, "work.gitconfig"), []byte("[core]\n\texcludesFile = ~/work-ignore\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(home, "other.gitconfig"), []byte("[core]\n\texcludesFile = ~/other-ignore\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(home, "work-ignore"), []byte("*.tmp\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(home, "other-ignore"), []byte("*\n"), 0o600))
+
+	for _, p := range []string{"work/project", "personal/project"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, p, ".git"), 0o700))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, p, "a.tmp"), dummyFileContents, 0o600))
+	}
+
+	root, err := localfs.Directory(dir)
+	require.NoError(t, err)
+
+	// local directories are not listed in a particular order.
+	require.ElementsMatch(t, []string{
+		"./",
+		"./personal/",
+		"./personal/project/",
+		"./personal/project/.git/",
+		"./personal/project/a.tmp",
+		"./work/",
+		"./work/project/",
+		"./work/project/.git/",
+	}, walkTree(t, ignorefs.New(root, gitIgnorePolicyTree())))
+}
+
+func TestIgnoreFS_GitRepositoryExcludesFile(t *testing.T) {
+	home := t.TempDir()
+
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".gitconfig"), []byte("[core]\n\texcludesFile = ~/global-ignore\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(home, "global-ignore"), []byte("*.tmp\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(home, "repo-ignore"), []byte("*.bak\n"), 0o600))
+
+	root := mockfs.NewDirectory()
+
+	// repository configuration takes precedence over the global one.
+	repo1 := root.AddDir("repo1", 0)
+	repo1.AddDir(".git", 0).AddFileLines("config", []string{
+		"[core]",
+		"\texcludesFile = " + filepath.ToSlash(filepath.Join(home, "repo-ignore")) + " ; comment",
+	}, 0)
+	repo1.AddFile("a.tmp", dummyFileContents, 0)
+	repo1.AddFile("b.bak", dummyFileContents, 0)
+
+	repo2 := root.AddDir("repo2", 0)
+	repo2.AddDir(".git", 0)
+	repo2.AddFile("a.tmp", dummyFileContents, 0)
+	repo2.AddFile("b.bak", dummyFileContents, 0)
+
+	verifyDirectoryTree(t, ignorefs.New(root, gitIgnorePolicyTree()), []string{
+		"./",
+		"./repo1/",
+		"./repo1/.git/",
+		"./repo1/.git/config",
+		"./repo1/a.tmp",
+		"./repo2/",
+		"./repo2/.git/",
+		"./repo2/b.bak",
+	})
+}
+
+func TestIgnoreFS_GitExcludesFileOfWorkTreeOwner(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("requires running as root")
+	}
+
+	nobody, err := user.Lookup("nobody")
+	if err != nil {
+		t.Skip("user 'nobody' not found")
+	}
+
+	nobodyUID, err := strconv.ParseUint(nobody.Uid, 10, 32)
+	require.NoError(t, err)
+
+	home := t.TempDir()
+
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".gitconfig"), []byte("[core]\n\texcludesFile = ~/global-ignore\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(home, "global-ignore"), []byte("*.tmp\n"), 0o600))
+
+	workTree := func(name string, uid uint32) fs.Entry {
+		md := virtualfs.Metadata{Mode: os.ModeDir | 0o755, Owner: fs.OwnerInfo{UserID: uid}}
+
+		return virtualfs.NewStaticDirectoryWithMetadata(name, md, []fs.Entry{
+			virtualfs.NewStaticDirectoryWithMetadata(".git", md, nil),
+			virtualfs.FileFromReaderAt("a.tmp", virtualfs.Metadata{Mode: 0o644}, int64(len(dummyFileContents)), bytes.NewReader(dummyFileContents)),
+		})
+	}
+
+	// the configuration of the current user does not apply to work trees of other users.
+	root := virtualfs.NewStaticDirectory("root", []fs.Entry{
+		workTree("mine", 0),
+		workTree("nobodys", uint32(nobodyUID)),
+	})
+
+	verifyDirectoryTree(t, ignorefs.New(root, gitIgnorePolicyTree()), []string{
+		"./",
+		"./mine/",
+		"./mine/.git/",
+		"./nobodys/",
+		"./nobodys/.git/",
+		"./nobodys/a.tmp",
+	})
+}
+
+func gitIgnorePolicyTree() *policy.Tree {
+	return policy.BuildTree(map[string]*policy.Policy{
+		".": {
+			FilesPolicy: policy.FilesPolicy{
+				GitIgnore: &trueValue,
+			},
+		},
+	}, policy.DefaultPolicy)
+}
+
+func TestIgnoreFS_AttributeRules(t *testing.T) {
+	now := clock.Now()
+	recent := virtualfs.Metadata{Mode: 0o644, ModTime: now, Owner: fs.OwnerInfo{UserID: 1000}}
+	old := virtualfs.Metadata{Mode: 0o644, ModTime: now.Add(-60 * 24 * time.Hour), Owner: fs.OwnerInfo{UserID: 1000}}
+	other := virtualfs.Metadata{Mode: 0o755, ModTime: now, Owner: fs.OwnerInfo{UserID: 1234}}
+
+	newFile := func(name string, md virtualfs.Metadata, content []byte) fs.File {
+		return virtualfs.FileFromReaderAt(name, md, int64(len(content)), bytes.NewReader(content))
+	}
+
+	root := virtualfs.NewStaticDirectoryWithMetadata("root", recent, []fs.Entry{
+		newFile("recent.txt", recent, dummyFileContents),
+		newFile("old.txt", old, dummyFileContents),
+		newFile("big.bin", recent, bytes.Repeat([]byte{1}, 2000)),
+		newFile("doc.pdf", recent, []byte("%PDF-1.4\n")),
+		newFile("other.txt", other, dummyFileContents),
+		virtualfs.NewStaticDirectoryWithMetadata("old-dir", old, []fs.Entry{
+			newFile("f", recent, dummyFileContents),
+		}),
+		virtualfs.NewStaticDirectoryWithMetadata("other-dir", other, nil),
+		mockfs.NewFile("pipe", nil, os.ModeNamedPipe|0o600),
+	})
+
+	pt := policy.BuildTree(map[string]*policy.Policy{
+		".": {
+			FilesPolicy: policy.FilesPolicy{
+				// the first matching rule is reported.
+				AttributeRules: []string{
+					"type:fifo",
+					"older-than:30d",
+					"size:1KB-",
+					"mime:application/pdf",
+					"uid:1234",
+					"nodump",
+				},
+			},
+		},
+	}, policy.DefaultPolicy)
+
+	reasons := map[string]string{}
+
+	ifs := ignorefs.New(root, pt, ignorefs.ReportIgnoredFiles(func(_ context.Context, path string, _ fs.Entry, _ *policy.Tree, reason string) {
+		reasons[path] = reason
+	}))
+
+	// directories are not excluded based on their age, MIME type rules are not applied when listing directories.
+	verifyDirectoryTree(t, ifs, []string{
+		"./",
+		"./recent.txt",
+		"./doc.pdf",
+		"./old-dir/",
+		"./old-dir/f",
+	})
+
+	require.Equal(t, map[string]string{
+		"old.txt":   `matched attribute rule "older-than:30d"`,
+		"big.bin":   `matched attribute rule "size:1KB-"`,
+		"other.txt": `matched attribute rule "uid:1234"`,
+		"other-dir": `matched attribute rule "uid:1234"`,
+		"pipe":      `matched attribute rule "type:fifo"`,
+	}, reasons)
+}
+
+func TestMIMERules(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	pol := &policy.Policy{
+		FilesPolicy: policy.FilesPolicy{
+			AttributeRules: []string{"size:1KB-", "mime:image/*", "mime:application/pdf"},
+		},
+	}
+
+	newFile := func(content string) fs.File {
+		return virtualfs.FileFromReaderAt("f", virtualfs.Metadata{}, int64(len(content)), bytes.NewReader([]byte(content)))
+	}
+
+	var m ignorefs.MIMERules
+
+	r := m.ExcludingRule(ctx, pol, newFile("%PDF-1.4\n"))
+	require.NotNil(t, r)
+	require.Equal(t, "application/pdf", r.MIMEPattern)
+
+	require.Nil(t, m.ExcludingRule(ctx, pol, newFile("plain text")))
+	require.Nil(t, m.ExcludingRule(ctx, policy.DefaultPolicy, newFile("%PDF-1.4\n")))
+}
+
+func TestIgnoreFS_InvalidAttributeRule(t *testing.T) {
+	pt := policy.BuildTree(map[string]*policy.Policy{
+		".": {
+			FilesPolicy: policy.FilesPolicy{
+				AttributeRules: []string{"size:big"},
+			},
+		},
+	}, policy.DefaultPolicy)
+
+	_, err := fs.GetAllEntries(testlogging.Context(t), ignorefs.New(setupFilesystem(false), pt))
+	require.ErrorContains(t, err, `invalid attribute rule "size:big"`)
+}
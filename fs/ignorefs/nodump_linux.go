@@ -0,0 +1,26 @@
+package ignorefs
+
+import (
+	"context"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/kopia/kopia/fs"
+)
+
+// hasNoDumpAttribute returns true if the local file or directory has the 'nodump' attribute set with chattr.
+func hasNoDumpAttribute(ctx context.Context, e fs.Entry) bool {
+	p := e.LocalFilesystemPath()
+	if p == "" {
+		return false
+	}
+
+	var stx unix.Statx_t
+
+	if err := unix.Statx(unix.AT_FDCWD, p, unix.AT_SYMLINK_NOFOLLOW, 0, &stx); err != nil {
+		log(ctx).Debugf("unable to read attributes of %v: %v", p, err)
+		return false
+	}
+
+	return stx.Attributes_mask&stx.Attributes&unix.STATX_ATTR_NODUMP != 0
+}
@@ -0,0 +1,46 @@
+package ignorefs_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs/ignorefs"
+	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+func TestIgnoreFS_NoDumpAttribute(t *testing.T) {
+	td := testutil.TempDirectory(t)
+
+	require.NoError(t, os.WriteFile(filepath.Join(td, "kept"), dummyFileContents, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(td, "nodump"), dummyFileContents, 0o600))
+	require.NoError(t, os.Mkdir(filepath.Join(td, "nodump-dir"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(td, "nodump-dir", "f"), dummyFileContents, 0o600))
+
+	for _, p := range []string{"nodump", "nodump-dir"} {
+		if out, err := exec.Command("chattr", "+d", filepath.Join(td, p)).CombinedOutput(); err != nil {
+			t.Skipf("unable to set nodump attribute: %v %s", err, out)
+		}
+	}
+
+	dir, err := localfs.Directory(td)
+	require.NoError(t, err)
+
+	pt := policy.BuildTree(map[string]*policy.Policy{
+		".": {
+			FilesPolicy: policy.FilesPolicy{
+				AttributeRules: []string{"nodump"},
+			},
+		},
+	}, policy.DefaultPolicy)
+
+	verifyDirectoryTree(t, ignorefs.New(dir, pt), []string{
+		"./",
+		"./kept",
+	})
+}
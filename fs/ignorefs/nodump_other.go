@@ -0,0 +1,14 @@
+//go:build !linux
+
+package ignorefs
+
+import (
+	"context"
+
+	"github.com/kopia/kopia/fs"
+)
+
+// hasNoDumpAttribute returns false, the 'nodump' attribute is only supported on Linux.
+func hasNoDumpAttribute(_ context.Context, _ fs.Entry) bool {
+	return false
+}
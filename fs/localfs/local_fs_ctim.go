@@ -0,0 +1,9 @@
+//go:build linux || openbsd
+
+package localfs
+
+import "syscall"
+
+func platformSpecificCtime(stat *syscall.Stat_t) int64 {
+	return stat.Ctim.Nano()
+}
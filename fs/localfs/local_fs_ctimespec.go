@@ -0,0 +1,9 @@
+//go:build darwin || freebsd || netbsd
+
+package localfs
+
+import "syscall"
+
+func platformSpecificCtime(stat *syscall.Stat_t) int64 {
+	return stat.Ctimespec.Nano()
+}
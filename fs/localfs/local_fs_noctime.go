@@ -0,0 +1,9 @@
+//go:build !windows && !linux && !openbsd && !darwin && !freebsd && !netbsd
+
+package localfs
+
+import "syscall"
+
+func platformSpecificCtime(_ *syscall.Stat_t) int64 {
+	return 0
+}
@@ -27,6 +27,8 @@ func platformSpecificDeviceInfo(fi os.FileInfo) fs.DeviceInfo {
 		// not making a separate type for 32-bit platforms here..
 		oi.Dev = platformSpecificWidenDev(stat.Dev)
 		oi.Rdev = platformSpecificWidenDev(stat.Rdev)
+		oi.Ino = stat.Ino
+		oi.Ctime = platformSpecificCtime(stat)
 	}
 
 	return oi
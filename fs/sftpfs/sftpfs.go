@@ -0,0 +1,207 @@
+// Package sftpfs implements fs.Directory for remote directory trees read over SFTP.
+package sftpfs
+
+import (
+	"context"
+	"os"
+	"path"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
+
+	"github.com/kopia/kopia/fs"
+)
+
+type sftpEntry struct {
+	cli     *sftp.Client
+	path    string
+	name    string
+	mode    os.FileMode
+	size    int64
+	modTime time.Time
+	owner   fs.OwnerInfo
+}
+
+func (e *sftpEntry) Name() string {
+	return e.name
+}
+
+func (e *sftpEntry) IsDir() bool {
+	return e.mode.IsDir()
+}
+
+func (e *sftpEntry) Mode() os.FileMode {
+	return e.mode
+}
+
+func (e *sftpEntry) Size() int64 {
+	return e.size
+}
+
+func (e *sftpEntry) ModTime() time.Time {
+	return e.modTime
+}
+
+func (e *sftpEntry) Sys() any {
+	return nil
+}
+
+func (e *sftpEntry) Owner() fs.OwnerInfo {
+	return e.owner
+}
+
+func (e *sftpEntry) Device() fs.DeviceInfo {
+	return fs.DeviceInfo{}
+}
+
+func (e *sftpEntry) LocalFilesystemPath() string {
+	return ""
+}
+
+func (e *sftpEntry) Close() {
+}
+
+type sftpDirectory struct {
+	sftpEntry
+}
+
+func (d *sftpDirectory) SupportsMultipleIterations() bool {
+	return true
+}
+
+func (d *sftpDirectory) Child(_ context.Context, name string) (fs.Entry, error) {
+	p := path.Join(d.path, name)
+
+	fi, err := d.cli.Lstat(p)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return entryFromFileInfo(d.cli, p, name, fi), nil
+}
+
+func (d *sftpDirectory) Iterate(_ context.Context) (fs.DirectoryIterator, error) {
+	fis, err := d.cli.ReadDir(d.path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read directory %v", d.path)
+	}
+
+	entries := make([]fs.Entry, 0, len(fis))
+
+	for _, fi := range fis {
+		entries = append(entries, entryFromFileInfo(d.cli, path.Join(d.path, fi.Name()), fi.Name(), fi))
+	}
+
+	return fs.StaticIterator(entries, nil), nil
+}
+
+type sftpFile struct {
+	sftpEntry
+}
+
+func (f *sftpFile) Open(_ context.Context) (fs.Reader, error) {
+	r, err := f.cli.Open(f.path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to open %v", f.path)
+	}
+
+	return &sftpFileReader{r, f}, nil
+}
+
+type sftpFileReader struct {
+	*sftp.File
+	f *sftpFile
+}
+
+func (r *sftpFileReader) Entry() (fs.Entry, error) {
+	return r.f, nil
+}
+
+type sftpSymlink struct {
+	sftpEntry
+}
+
+func (s *sftpSymlink) Readlink(_ context.Context) (string, error) {
+	target, err := s.cli.ReadLink(s.path)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to read link %v", s.path)
+	}
+
+	return target, nil
+}
+
+func (s *sftpSymlink) Resolve(_ context.Context) (fs.Entry, error) {
+	fi, err := s.cli.Stat(s.path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to resolve %v", s.path)
+	}
+
+	return entryFromFileInfo(s.cli, s.path, s.name, fi), nil
+}
+
+type sftpErrorEntry struct {
+	sftpEntry
+	err error
+}
+
+func (e *sftpErrorEntry) ErrorInfo() error {
+	return e.err
+}
+
+func translateError(err error) error {
+	if os.IsNotExist(err) {
+		return fs.ErrEntryNotFound
+	}
+
+	return errors.Wrap(err, "sftp error")
+}
+
+func entryFromFileInfo(cli *sftp.Client, p, name string, fi os.FileInfo) fs.Entry {
+	e := sftpEntry{
+		cli:     cli,
+		path:    p,
+		name:    name,
+		mode:    fi.Mode(),
+		size:    fi.Size(),
+		modTime: fi.ModTime(),
+	}
+
+	if st, ok := fi.Sys().(*sftp.FileStat); ok {
+		e.owner = fs.OwnerInfo{UserID: st.UID, GroupID: st.GID}
+	}
+
+	switch fi.Mode() & os.ModeType {
+	case os.ModeDir:
+		return &sftpDirectory{e}
+	case os.ModeSymlink:
+		return &sftpSymlink{e}
+	case 0:
+		return &sftpFile{e}
+	default:
+		return &sftpErrorEntry{e, fs.ErrUnknown}
+	}
+}
+
+// Directory returns fs.Directory for the remote directory accessed using the provided SFTP client.
+func Directory(cli *sftp.Client, dirPath string) (fs.Directory, error) {
+	dirPath = path.Clean(dirPath)
+
+	fi, err := cli.Stat(dirPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to stat %v", dirPath)
+	}
+
+	if !fi.IsDir() {
+		return nil, errors.Errorf("not a directory: %v", dirPath)
+	}
+
+	return entryFromFileInfo(cli, dirPath, path.Base(dirPath), fi).(fs.Directory), nil //nolint:forcetypeassert
+}
+
+var (
+	_ fs.Directory  = &sftpDirectory{}
+	_ fs.File       = &sftpFile{}
+	_ fs.Symlink    = &sftpSymlink{}
+	_ fs.ErrorEntry = &sftpErrorEntry{}
+)
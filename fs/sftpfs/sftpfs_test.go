@@ -0,0 +1,120 @@
+package sftpfs_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/sftpfs"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/upload"
+)
+
+// newInProcessClient returns SFTP client connected to an in-process SFTP server serving the local file system.
+func newInProcessClient(t *testing.T) *sftp.Client {
+	t.Helper()
+
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+
+	server, err := sftp.NewServer(struct {
+		io.Reader
+		io.WriteCloser
+	}{serverReader, serverWriter})
+	require.NoError(t, err)
+
+	go server.Serve() //nolint:errcheck
+
+	cli, err := sftp.NewClientPipe(clientReader, clientWriter)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		// closing the server first lets the client receive loop finish.
+		server.Close()
+		cli.Close()
+	})
+
+	return cli
+}
+
+func TestSFTPDirectory(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("in-process SFTP server uses unix paths")
+	}
+
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	td := testutil.TempDirectory(t)
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	require.NoError(t, os.Mkdir(filepath.Join(td, "sub"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(td, "sub", "f1"), []byte("hello"), 0o640))
+	require.NoError(t, os.Chtimes(filepath.Join(td, "sub", "f1"), mtime, mtime))
+	require.NoError(t, os.Symlink("sub/f1", filepath.Join(td, "link")))
+
+	cli := newInProcessClient(t)
+
+	_, err := sftpfs.Directory(cli, filepath.Join(td, "missing"))
+	require.Error(t, err)
+
+	dir, err := sftpfs.Directory(cli, td)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Base(td), dir.Name())
+
+	entries, err := fs.GetAllEntries(ctx, dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	sub, err := dir.Child(ctx, "sub")
+	require.NoError(t, err)
+	require.True(t, sub.IsDir())
+
+	_, err = dir.Child(ctx, "no-such-entry")
+	require.ErrorIs(t, err, fs.ErrEntryNotFound)
+
+	f1, err := sub.(fs.Directory).Child(ctx, "f1")
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o640), f1.Mode())
+	require.Equal(t, int64(5), f1.Size())
+	require.True(t, mtime.Equal(f1.ModTime()))
+	require.Equal(t, fs.OwnerInfo{UserID: uint32(os.Getuid()), GroupID: uint32(os.Getgid())}, f1.Owner()) //nolint:gosec
+
+	r, err := f1.(fs.File).Open(ctx)
+	require.NoError(t, err)
+
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(b))
+	require.NoError(t, r.Close())
+
+	link, err := dir.Child(ctx, "link")
+	require.NoError(t, err)
+
+	target, err := link.(fs.Symlink).Readlink(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "sub/f1", target)
+
+	// second snapshot of unchanged remote directory uses cached entries of the previous one.
+	u := upload.NewUploader(env.RepositoryWriter)
+	si := snapshot.SourceInfo{Host: "remote-host", UserName: "user", Path: td}
+
+	man1, err := u.Upload(ctx, dir, nil, si)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), man1.Stats.NonCachedFiles)
+
+	man2, err := u.Upload(ctx, dir, nil, si, man1)
+	require.NoError(t, err)
+	// the file and the symlink are both found in the previous snapshot.
+	require.Equal(t, int32(2), man2.Stats.CachedFiles)
+	require.Equal(t, int32(0), man2.Stats.NonCachedFiles)
+	require.Equal(t, man1.RootObjectID(), man2.RootObjectID())
+}
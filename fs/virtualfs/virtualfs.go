@@ -1,4 +1,4 @@
-// Package virtualfs implements an in-memory abstraction of fs.Directory and fs.StreamingFile.
+// Package virtualfs implements an in-memory abstraction of fs.Directory, fs.File, fs.Symlink and fs.StreamingFile.
 package virtualfs
 
 import (
@@ -97,6 +97,31 @@ func NewStaticDirectory(name string, entries []fs.Entry) fs.Directory {
 	}
 }
 
+// Metadata describes the metadata of a virtual entry.
+type Metadata struct {
+	Mode    os.FileMode
+	ModTime time.Time
+	Owner   fs.OwnerInfo
+}
+
+func (m Metadata) virtualEntry(name string, typ os.FileMode, size int64) virtualEntry {
+	return virtualEntry{
+		name:    name,
+		mode:    m.Mode.Perm() | typ,
+		size:    size,
+		modTime: m.ModTime,
+		owner:   m.Owner,
+	}
+}
+
+// NewStaticDirectoryWithMetadata returns a virtual static directory with the provided metadata.
+func NewStaticDirectoryWithMetadata(name string, md Metadata, entries []fs.Entry) fs.Directory {
+	return &staticDirectory{
+		virtualEntry: md.virtualEntry(name, os.ModeDir, 0),
+		entries:      entries,
+	}
+}
+
 type streamingDirectory struct {
 	virtualEntry
 
@@ -187,9 +212,172 @@ func StreamingFileWithModTimeFromReader(name string, t time.Time, reader io.Read
 	}
 }
 
+// readerAtFile is an implementation of fs.File which reads its contents from io.ReaderAt.
+type readerAtFile struct {
+	virtualEntry
+	r io.ReaderAt
+}
+
+func (f *readerAtFile) Open(_ context.Context) (fs.Reader, error) {
+	return &readerAtFileReader{io.NewSectionReader(f.r, 0, f.size), f}, nil
+}
+
+type readerAtFileReader struct {
+	*io.SectionReader
+	f *readerAtFile
+}
+
+func (r *readerAtFileReader) Close() error {
+	return nil
+}
+
+func (r *readerAtFileReader) Entry() (fs.Entry, error) {
+	return r.f, nil
+}
+
+// FileFromReaderAt returns a file with the given name, metadata and size, whose contents are read
+// from the provided io.ReaderAt. The file can be opened multiple times and concurrently.
+func FileFromReaderAt(name string, md Metadata, size int64, r io.ReaderAt) fs.File {
+	return &readerAtFile{
+		virtualEntry: md.virtualEntry(name, 0, size),
+		r:            r,
+	}
+}
+
+// openerFile is an implementation of fs.File which reads its contents from streams returned by the opener.
+type openerFile struct {
+	virtualEntry
+	open func() (io.ReadCloser, error)
+}
+
+func (f *openerFile) Open(_ context.Context) (fs.Reader, error) {
+	return &openerFileReader{f: f}, nil
+}
+
+// openerFileReader implements seeking over sequential streams by skipping forward or re-opening the stream.
+type openerFileReader struct {
+	f         *openerFile
+	rc        io.ReadCloser
+	pos       int64 // logical position
+	streamPos int64 // position of rc
+}
+
+var errInvalidSeek = errors.New("invalid seek")
+
+func (r *openerFileReader) Read(p []byte) (int, error) {
+	if r.pos >= r.f.size {
+		return 0, io.EOF
+	}
+
+	if r.rc != nil && r.pos < r.streamPos {
+		r.rc.Close() //nolint:errcheck
+		r.rc = nil
+	}
+
+	if r.rc == nil {
+		rc, err := r.f.open()
+		if err != nil {
+			return 0, err
+		}
+
+		r.rc = rc
+		r.streamPos = 0
+	}
+
+	if r.pos > r.streamPos {
+		n, err := io.CopyN(io.Discard, r.rc, r.pos-r.streamPos)
+		r.streamPos += n
+
+		if err != nil {
+			return 0, err //nolint:wrapcheck
+		}
+	}
+
+	if remaining := r.f.size - r.pos; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	n, err := r.rc.Read(p)
+	r.pos += int64(n)
+	r.streamPos += int64(n)
+
+	return n, err //nolint:wrapcheck
+}
+
+func (r *openerFileReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.f.size
+	default:
+		return 0, errInvalidSeek
+	}
+
+	if offset < 0 {
+		return 0, errInvalidSeek
+	}
+
+	r.pos = offset
+
+	return offset, nil
+}
+
+func (r *openerFileReader) Close() error {
+	if r.rc == nil {
+		return nil
+	}
+
+	err := r.rc.Close()
+	r.rc = nil
+
+	return err //nolint:wrapcheck
+}
+
+func (r *openerFileReader) Entry() (fs.Entry, error) {
+	return r.f, nil
+}
+
+// FileFromOpener returns a file with the given name, metadata and size, whose contents are read
+// from streams returned by the provided function. Seeking backwards re-opens the stream.
+func FileFromOpener(name string, md Metadata, size int64, open func() (io.ReadCloser, error)) fs.File {
+	return &openerFile{
+		virtualEntry: md.virtualEntry(name, 0, size),
+		open:         open,
+	}
+}
+
+// virtualSymlink is an in-memory implementation of fs.Symlink.
+type virtualSymlink struct {
+	virtualEntry
+	target string
+}
+
+func (sl *virtualSymlink) Readlink(_ context.Context) (string, error) {
+	return sl.target, nil
+}
+
+var errResolveNotSupported = errors.New("resolving virtual symlinks is not supported")
+
+func (sl *virtualSymlink) Resolve(_ context.Context) (fs.Entry, error) {
+	return nil, errResolveNotSupported
+}
+
+// NewSymlink returns a virtual symbolic link with the given name, metadata and target.
+func NewSymlink(name string, md Metadata, target string) fs.Symlink {
+	return &virtualSymlink{
+		virtualEntry: md.virtualEntry(name, os.ModeSymlink, int64(len(target))),
+		target:       target,
+	}
+}
+
 var (
 	_ fs.Directory     = &staticDirectory{}
 	_ fs.Directory     = &streamingDirectory{}
 	_ fs.StreamingFile = &virtualFile{}
+	_ fs.File          = &readerAtFile{}
+	_ fs.File          = &openerFile{}
+	_ fs.Symlink       = &virtualSymlink{}
 	_ fs.Entry         = &virtualEntry{}
 )
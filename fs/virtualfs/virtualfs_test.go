@@ -200,3 +200,96 @@ func TestStreamingDirectory_ReturnsCallbackError(t *testing.T) {
 	})
 	require.ErrorIs(t, err, errCallback)
 }
+
+func TestEntriesWithMetadata(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	md := Metadata{
+		Mode:    0o640,
+		ModTime: time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC),
+		Owner:   fs.OwnerInfo{UserID: 10, GroupID: 20},
+	}
+
+	f := FileFromReaderAt("f", md, 5, bytes.NewReader([]byte("0123456789")))
+	l := NewSymlink("l", md, "target")
+	d := NewStaticDirectoryWithMetadata("d", md, []fs.Entry{f, l})
+
+	require.True(t, d.IsDir())
+	require.Equal(t, os.ModeDir|0o640, d.Mode())
+	require.Equal(t, md.ModTime, d.ModTime())
+	require.Equal(t, md.Owner, d.Owner())
+
+	require.Equal(t, os.FileMode(0o640), f.Mode())
+	require.Equal(t, int64(5), f.Size())
+
+	// files can be opened multiple times.
+	for range 2 {
+		r, err := f.Open(ctx)
+		require.NoError(t, err)
+
+		b, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, "01234", string(b))
+
+		e, err := r.Entry()
+		require.NoError(t, err)
+		require.Equal(t, f, e)
+		require.NoError(t, r.Close())
+	}
+
+	require.Equal(t, os.ModeSymlink|0o640, l.Mode())
+
+	target, err := l.Readlink(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "target", target)
+
+	e, err := d.Child(ctx, "l")
+	require.NoError(t, err)
+	require.Equal(t, l, e)
+}
+
+func TestFileFromOpener(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	opens := 0
+
+	f := FileFromOpener("f", Metadata{Mode: 0o600}, 10, func() (io.ReadCloser, error) {
+		opens++
+		return io.NopCloser(bytes.NewReader([]byte("0123456789"))), nil
+	})
+
+	r, err := f.Open(ctx)
+	require.NoError(t, err)
+
+	defer r.Close()
+
+	b := make([]byte, 3)
+
+	_, err = r.Seek(4, io.SeekStart)
+	require.NoError(t, err)
+	_, err = io.ReadFull(r, b)
+	require.NoError(t, err)
+	require.Equal(t, "456", string(b))
+	require.Equal(t, 1, opens)
+
+	// seeking forward skips within the same stream.
+	_, err = r.Seek(1, io.SeekCurrent)
+	require.NoError(t, err)
+	_, err = io.ReadFull(r, b[:2])
+	require.NoError(t, err)
+	require.Equal(t, "89", string(b[:2]))
+	require.Equal(t, 1, opens)
+
+	// seeking backwards re-opens the stream.
+	_, err = r.Seek(1, io.SeekStart)
+	require.NoError(t, err)
+	_, err = io.ReadFull(r, b)
+	require.NoError(t, err)
+	require.Equal(t, "123", string(b))
+	require.Equal(t, 2, opens)
+
+	_, err = r.Seek(0, io.SeekEnd)
+	require.NoError(t, err)
+	_, err = r.Read(b)
+	require.ErrorIs(t, err, io.EOF)
+}
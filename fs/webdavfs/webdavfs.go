@@ -0,0 +1,219 @@
+// Package webdavfs implements fs.Directory for remote directory trees read over WebDAV.
+//
+// WebDAV does not expose ownership or permissions, so entries have no owner and use the permissions reported
+// by the client library.
+package webdavfs
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/studio-b12/gowebdav"
+
+	"github.com/kopia/kopia/fs"
+)
+
+type davEntry struct {
+	cli     *gowebdav.Client
+	path    string
+	name    string
+	mode    os.FileMode
+	size    int64
+	modTime time.Time
+}
+
+func (e *davEntry) Name() string {
+	return e.name
+}
+
+func (e *davEntry) IsDir() bool {
+	return e.mode.IsDir()
+}
+
+func (e *davEntry) Mode() os.FileMode {
+	return e.mode
+}
+
+func (e *davEntry) Size() int64 {
+	return e.size
+}
+
+func (e *davEntry) ModTime() time.Time {
+	return e.modTime
+}
+
+func (e *davEntry) Sys() any {
+	return nil
+}
+
+func (e *davEntry) Owner() fs.OwnerInfo {
+	return fs.OwnerInfo{}
+}
+
+func (e *davEntry) Device() fs.DeviceInfo {
+	return fs.DeviceInfo{}
+}
+
+func (e *davEntry) LocalFilesystemPath() string {
+	return ""
+}
+
+func (e *davEntry) Close() {
+}
+
+type davDirectory struct {
+	davEntry
+}
+
+func (d *davDirectory) SupportsMultipleIterations() bool {
+	return true
+}
+
+func (d *davDirectory) Child(_ context.Context, name string) (fs.Entry, error) {
+	p := path.Join(d.path, name)
+
+	fi, err := d.cli.Stat(p)
+	if err != nil {
+		if gowebdav.IsErrNotFound(err) {
+			return nil, fs.ErrEntryNotFound
+		}
+
+		return nil, errors.Wrapf(err, "unable to stat %v", p)
+	}
+
+	return entryFromFileInfo(d.cli, p, name, fi), nil
+}
+
+func (d *davDirectory) Iterate(_ context.Context) (fs.DirectoryIterator, error) {
+	fis, err := d.cli.ReadDir(d.path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read directory %v", d.path)
+	}
+
+	entries := make([]fs.Entry, 0, len(fis))
+
+	for _, fi := range fis {
+		entries = append(entries, entryFromFileInfo(d.cli, path.Join(d.path, fi.Name()), fi.Name(), fi))
+	}
+
+	return fs.StaticIterator(entries, nil), nil
+}
+
+type davFile struct {
+	davEntry
+}
+
+func (f *davFile) Open(_ context.Context) (fs.Reader, error) {
+	return &davFileReader{f: f}, nil
+}
+
+// davFileReader reads file contents using range requests starting at the current position,
+// which are issued lazily on first read after opening or seeking.
+type davFileReader struct {
+	f   *davFile
+	rc  io.ReadCloser
+	pos int64
+}
+
+var errInvalidSeek = errors.New("invalid seek")
+
+func (r *davFileReader) Read(p []byte) (int, error) {
+	if r.pos >= r.f.size {
+		return 0, io.EOF
+	}
+
+	if r.rc == nil {
+		rc, err := r.f.cli.ReadStreamRange(r.f.path, r.pos, r.f.size-r.pos)
+		if err != nil {
+			return 0, errors.Wrapf(err, "unable to read %v", r.f.path)
+		}
+
+		r.rc = rc
+	}
+
+	n, err := r.rc.Read(p)
+	r.pos += int64(n)
+
+	return n, err //nolint:wrapcheck
+}
+
+func (r *davFileReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.f.size
+	default:
+		return 0, errInvalidSeek
+	}
+
+	if offset < 0 {
+		return 0, errInvalidSeek
+	}
+
+	if offset != r.pos {
+		r.closeStream()
+		r.pos = offset
+	}
+
+	return offset, nil
+}
+
+func (r *davFileReader) closeStream() {
+	if r.rc != nil {
+		r.rc.Close() //nolint:errcheck
+		r.rc = nil
+	}
+}
+
+func (r *davFileReader) Close() error {
+	r.closeStream()
+	return nil
+}
+
+func (r *davFileReader) Entry() (fs.Entry, error) {
+	return r.f, nil
+}
+
+func entryFromFileInfo(cli *gowebdav.Client, p, name string, fi os.FileInfo) fs.Entry {
+	e := davEntry{
+		cli:     cli,
+		path:    p,
+		name:    name,
+		mode:    fi.Mode(),
+		size:    fi.Size(),
+		modTime: fi.ModTime(),
+	}
+
+	if fi.IsDir() {
+		return &davDirectory{e}
+	}
+
+	return &davFile{e}
+}
+
+// Directory returns fs.Directory for the remote directory accessed using the provided WebDAV client.
+func Directory(cli *gowebdav.Client, dirPath string) (fs.Directory, error) {
+	dirPath = path.Clean("/" + dirPath)
+
+	fi, err := cli.Stat(dirPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to stat %v", dirPath)
+	}
+
+	if !fi.IsDir() {
+		return nil, errors.Errorf("not a directory: %v", dirPath)
+	}
+
+	return entryFromFileInfo(cli, dirPath, path.Base(dirPath), fi).(fs.Directory), nil //nolint:forcetypeassert
+}
+
+var (
+	_ fs.Directory = &davDirectory{}
+	_ fs.File      = &davFile{}
+)
@@ -0,0 +1,92 @@
+package webdavfs_test
+
+import (
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/studio-b12/gowebdav"
+	"golang.org/x/net/webdav"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/webdavfs"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/upload"
+)
+
+func TestWebDAVDirectory(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	td := testutil.TempDirectory(t)
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(td, "data", "sub"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(td, "data", "sub", "f1"), []byte("hello world"), 0o640))
+	require.NoError(t, os.Chtimes(filepath.Join(td, "data", "sub", "f1"), mtime, mtime))
+
+	server := httptest.NewServer(&webdav.Handler{
+		FileSystem: webdav.Dir(td),
+		LockSystem: webdav.NewMemLS(),
+	})
+	defer server.Close()
+
+	cli := gowebdav.NewClient(server.URL, "", "")
+
+	_, err := webdavfs.Directory(cli, "missing")
+	require.Error(t, err)
+
+	_, err = webdavfs.Directory(cli, "data/sub/f1")
+	require.Error(t, err)
+
+	dir, err := webdavfs.Directory(cli, "data")
+	require.NoError(t, err)
+	require.Equal(t, "data", dir.Name())
+
+	sub, err := dir.Child(ctx, "sub")
+	require.NoError(t, err)
+	require.True(t, sub.IsDir())
+
+	_, err = dir.Child(ctx, "no-such-entry")
+	require.ErrorIs(t, err, fs.ErrEntryNotFound)
+
+	f1, err := sub.(fs.Directory).Child(ctx, "f1")
+	require.NoError(t, err)
+	require.Equal(t, int64(11), f1.Size())
+	require.True(t, mtime.Equal(f1.ModTime()))
+
+	r, err := f1.(fs.File).Open(ctx)
+	require.NoError(t, err)
+
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, "hello world", string(b))
+
+	// seeking issues range requests.
+	_, err = r.Seek(6, io.SeekStart)
+	require.NoError(t, err)
+
+	b, err = io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, "world", string(b))
+	require.NoError(t, r.Close())
+
+	// second snapshot of unchanged remote directory uses cached entries of the previous one.
+	u := upload.NewUploader(env.RepositoryWriter)
+	si := snapshot.SourceInfo{Host: "remote-host", UserName: "user", Path: "/data"}
+
+	man1, err := u.Upload(ctx, dir, nil, si)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), man1.Stats.NonCachedFiles)
+
+	man2, err := u.Upload(ctx, dir, nil, si, man1)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), man2.Stats.CachedFiles)
+	require.Equal(t, int32(0), man2.Stats.NonCachedFiles)
+	require.Equal(t, man1.RootObjectID(), man2.RootObjectID())
+}
@@ -58,6 +58,13 @@ func GetUnsupportedFeatures(required []Required, supported []Feature) []Required
 	return result
 }
 
+// IsRequired returns true if the provided feature is among the required features.
+func IsRequired(required []Required, f Feature) bool {
+	return slices.ContainsFunc(required, func(r Required) bool {
+		return r.Feature == f
+	})
+}
+
 func isSupported(req Required, supported []Feature) bool {
 	return slices.Contains(supported, req.Feature)
 }
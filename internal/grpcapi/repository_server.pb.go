@@ -28,6 +28,8 @@
 	NotificationEventArgType_ARG_TYPE_EMPTY                 NotificationEventArgType = 1 //
 	NotificationEventArgType_ARG_TYPE_ERROR_INFO            NotificationEventArgType = 2
 	NotificationEventArgType_ARG_TYPE_MULTI_SNAPSHOT_STATUS NotificationEventArgType = 3
+	NotificationEventArgType_ARG_TYPE_SNAPSHOT_ANOMALY      NotificationEventArgType = 4
+	NotificationEventArgType_ARG_TYPE_SOURCE_BIT_ROT        NotificationEventArgType = 5
 )
 
 // Enum value maps for NotificationEventArgType.
@@ -37,12 +39,16 @@
 		1: "ARG_TYPE_EMPTY",
 		2: "ARG_TYPE_ERROR_INFO",
 		3: "ARG_TYPE_MULTI_SNAPSHOT_STATUS",
+		4: "ARG_TYPE_SNAPSHOT_ANOMALY",
+		5: "ARG_TYPE_SOURCE_BIT_ROT",
 	}
 	NotificationEventArgType_value = map[string]int32{
 		"ARG_TYPE_UNKNOWN":               0,
 		"ARG_TYPE_EMPTY":                 1,
 		"ARG_TYPE_ERROR_INFO":            2,
 		"ARG_TYPE_MULTI_SNAPSHOT_STATUS": 3,
+		"ARG_TYPE_SNAPSHOT_ANOMALY":      4,
+		"ARG_TYPE_SOURCE_BIT_ROT":        5,
 	}
 )
 
@@ -2233,12 +2239,14 @@ func (*SessionResponse_SendNotification) isSessionResponse_Response() {}
 	"\x16apply_retention_policy\x18\x14 \x01(\v2..kopia_repository.ApplyRetentionPolicyResponseH\x00R\x14applyRetentionPolicy\x12Y\n" +
 	"\x11send_notification\x18\x15 \x01(\v2*.kopia_repository.SendNotificationResponseH\x00R\x10sendNotificationB\n" +
 	"\n" +
-	"\bresponse*\x81\x01\n" +
+	"\bresponse*\xbd\x01\n" +
 	"\x18NotificationEventArgType\x12\x14\n" +
 	"\x10ARG_TYPE_UNKNOWN\x10\x00\x12\x12\n" +
 	"\x0eARG_TYPE_EMPTY\x10\x01\x12\x17\n" +
 	"\x13ARG_TYPE_ERROR_INFO\x10\x02\x12\"\n" +
-	"\x1eARG_TYPE_MULTI_SNAPSHOT_STATUS\x10\x032e\n" +
+	"\x1eARG_TYPE_MULTI_SNAPSHOT_STATUS\x10\x03\x12\x1d\n" +
+	"\x19ARG_TYPE_SNAPSHOT_ANOMALY\x10\x04\x12\x1b\n" +
+	"\x17ARG_TYPE_SOURCE_BIT_ROT\x10\x052e\n" +
 	"\x0fKopiaRepository\x12R\n" +
 	"\aSession\x12 .kopia_repository.SessionRequest\x1a!.kopia_repository.SessionResponse(\x010\x01B)Z'github.com/kopia/kopia/internal/grpcapib\x06proto3"
 
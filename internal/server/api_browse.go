@@ -0,0 +1,278 @@
+package server
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/restore"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+const (
+	defaultBrowsePageSize = 1000
+	maxBrowsePageSize     = 10000
+)
+
+// nopWriteCloser prevents archive outputs from closing the HTTP response writer.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// errStopListing stops directory iteration once the requested page has been read.
+var errStopListing = errors.New("stop listing")
+
+func browseError(rc requestContext, err *apiError) {
+	rc.w.Header().Set("Content-Type", "application/json")
+	rc.w.Header().Set("X-Content-Type-Options", "nosniff")
+	rc.w.WriteHeader(err.httpErrorCode)
+
+	_ = json.NewEncoder(rc.w).Encode(&serverapi.ErrorResponse{
+		Code:  err.apiErrorCode,
+		Error: err.message,
+	})
+}
+
+// handleSnapshotBrowse serves files and directories of a snapshot by path. Files are served with
+// range support, directories are listed in pages or streamed as ZIP or TAR archives.
+func handleSnapshotBrowse(ctx context.Context, rc requestContext) {
+	if !requireUIUser(ctx, rc) {
+		http.Error(rc.w, "access denied", http.StatusForbidden)
+		return
+	}
+
+	if rc.rep == nil {
+		browseError(rc, requestError(serverapi.ErrorNotConnected, "not connected"))
+		return
+	}
+
+	man, err := findBrowsedSnapshot(ctx, rc, rc.muxVar("snapshotID"))
+	if err != nil {
+		browseError(rc, internalServerError(err))
+		return
+	}
+
+	if man == nil {
+		browseError(rc, notFoundError("snapshot not found"))
+		return
+	}
+
+	root, err := snapshotfs.SnapshotRoot(rc.rep, man)
+	if err != nil {
+		browseError(rc, internalServerError(err))
+		return
+	}
+
+	relPath := strings.Trim(rc.muxVar("path"), "/")
+
+	var parts []string
+	if relPath != "" {
+		parts = strings.Split(relPath, "/")
+	}
+
+	e, err := findBrowsedEntry(ctx, root, parts)
+	if err != nil {
+		browseError(rc, internalServerError(err))
+		return
+	}
+
+	if e == nil {
+		browseError(rc, &apiError{http.StatusNotFound, serverapi.ErrorPathNotFound, "entry not found"})
+		return
+	}
+
+	switch e := e.(type) {
+	case fs.Directory:
+		if format := rc.queryParam("format"); format != "" {
+			streamDirectoryArchive(ctx, rc, e, archiveName(man, relPath), format)
+			return
+		}
+
+		listDirectory(ctx, rc, e, relPath)
+
+	case fs.File:
+		serveFile(ctx, rc, e)
+
+	default:
+		browseError(rc, requestError(serverapi.ErrorMalformedRequest, "unsupported entry type"))
+	}
+}
+
+// findBrowsedSnapshot returns the snapshot with the provided manifest or root object ID or nil if not found.
+func findBrowsedSnapshot(ctx context.Context, rc requestContext, snapshotID string) (*snapshot.Manifest, error) {
+	man, err := snapshot.LoadSnapshot(ctx, rc.rep, manifest.ID(snapshotID))
+	if err == nil {
+		return man, nil
+	}
+
+	if !errors.Is(err, snapshot.ErrSnapshotNotFound) {
+		return nil, errors.Wrap(err, "unable to load snapshot")
+	}
+
+	if _, err := object.ParseID(snapshotID); err != nil {
+		// neither a manifest nor an object ID.
+		return nil, nil //nolint:nilnil
+	}
+
+	//nolint:wrapcheck
+	return snapshotfs.FindSnapshotByRootObjectIDOrManifestID(ctx, rc.rep, snapshotID, false)
+}
+
+// findBrowsedEntry returns the entry at the provided path in the snapshot or nil if not found.
+func findBrowsedEntry(ctx context.Context, root fs.Entry, parts []string) (fs.Entry, error) {
+	current := root
+
+	for _, part := range parts {
+		dir, ok := current.(fs.Directory)
+		if !ok {
+			return nil, nil //nolint:nilnil
+		}
+
+		e, err := dir.Child(ctx, part)
+		if errors.Is(err, fs.ErrEntryNotFound) {
+			return nil, nil //nolint:nilnil
+		}
+
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading directory")
+		}
+
+		current = e
+	}
+
+	return current, nil
+}
+
+func archiveName(man *snapshot.Manifest, relPath string) string {
+	if relPath != "" {
+		return path.Base(relPath)
+	}
+
+	if n := path.Base(strings.ReplaceAll(man.Source.Path, "\\", "/")); n != "/" && n != "." {
+		return n
+	}
+
+	return string(man.ID)
+}
+
+func listDirectory(ctx context.Context, rc requestContext, dir fs.Directory, relPath string) {
+	offset, _ := strconv.Atoi(rc.queryParam("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	limit, _ := strconv.Atoi(rc.queryParam("limit"))
+	if limit <= 0 {
+		limit = defaultBrowsePageSize
+	}
+
+	limit = min(limit, maxBrowsePageSize)
+
+	resp := &serverapi.BrowseDirectoryResponse{
+		Path:    relPath,
+		Entries: []*snapshot.DirEntry{},
+		Offset:  offset,
+	}
+
+	// entries are returned in the order in which they are stored in the snapshot, one entry past
+	// the requested page is read to determine whether there are more.
+	i := 0
+
+	err := fs.IterateEntries(ctx, dir, func(_ context.Context, e fs.Entry) error {
+		defer func() { i++ }()
+
+		switch {
+		case i < offset:
+			return nil
+
+		case i == offset+limit:
+			resp.NextOffset = i
+			return errStopListing
+		}
+
+		if h, ok := e.(snapshot.HasDirEntry); ok {
+			resp.Entries = append(resp.Entries, h.DirEntry())
+		}
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStopListing) {
+		browseError(rc, internalServerError(err))
+		return
+	}
+
+	rc.w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(rc.w).Encode(resp); err != nil {
+		userLog(ctx).Errorf("error encoding response: %v", err)
+	}
+}
+
+func serveFile(ctx context.Context, rc requestContext, f fs.File) {
+	r, err := f.Open(ctx)
+	if err != nil {
+		browseError(rc, internalServerError(err))
+		return
+	}
+
+	defer r.Close() //nolint:errcheck
+
+	if rc.queryParam("download") != "" {
+		rc.w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": f.Name()}))
+	}
+
+	if h, ok := f.(object.HasObjectID); ok {
+		rc.w.Header().Set("ETag", strconv.Quote(h.ObjectID().String()))
+	}
+
+	// ServeContent determines content type based on file extension or sniffed data, handles
+	// range and conditional requests by seeking in the object reader.
+	http.ServeContent(rc.w, rc.req, f.Name(), f.ModTime(), r)
+}
+
+func streamDirectoryArchive(ctx context.Context, rc requestContext, dir fs.Directory, name, format string) {
+	var (
+		out         restore.Output
+		contentType string
+		ext         string
+		w           = nopWriteCloser{rc.w}
+	)
+
+	switch format {
+	case "zip":
+		out, contentType, ext = restore.NewZipOutput(w, zip.Deflate), "application/zip", ".zip"
+	case "zip-uncompressed":
+		out, contentType, ext = restore.NewZipOutput(w, zip.Store), "application/zip", ".zip"
+	case "tar":
+		out, contentType, ext = restore.NewTarOutput(w), "application/x-tar", ".tar"
+	case "tgz":
+		out, contentType, ext = restore.NewTarOutput(gzip.NewWriter(w)), "application/gzip", ".tar.gz"
+	default:
+		browseError(rc, requestError(serverapi.ErrorMalformedRequest, "unsupported archive format"))
+		return
+	}
+
+	rc.w.Header().Set("Content-Type", contentType)
+	rc.w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + ext}))
+
+	if _, err := restore.Entry(ctx, rc.rep, out, dir, restore.Options{}); err != nil {
+		// headers have already been sent, the client will observe truncated archive.
+		userLog(ctx).Errorf("error streaming archive %v: %v", name, err)
+	}
+}
@@ -0,0 +1,185 @@
+package server_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/apiclient"
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/internal/servertesting"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/upload"
+)
+
+func TestSnapshotBrowse(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	si := env.LocalPathSourceInfo("/dummy/path")
+
+	var snapID manifest.ID
+
+	require.NoError(t, repo.WriteSession(ctx, env.Repository, repo.WriteSessionOptions{Purpose: "Test"}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		u := upload.NewUploader(w)
+
+		dir := mockfs.NewDirectory()
+		docs := dir.AddDir("docs", 0o755)
+		docs.AddFile("readme.txt", []byte("hello world"), 0o644)
+		docs.AddFile("page.html", []byte("<html></html>"), 0o644)
+
+		many := dir.AddDir("many", 0o755)
+		for i := range 25 {
+			many.AddFile(fmt.Sprintf("f%02d", i), []byte{byte(i)}, 0o644)
+		}
+
+		man, err := u.Upload(ctx, dir, nil, si)
+		require.NoError(t, err)
+
+		snapID, err = snapshot.SaveSnapshot(ctx, w, man)
+		require.NoError(t, err)
+
+		return nil
+	}))
+
+	srvInfo := servertesting.StartServer(t, env, false)
+
+	cli, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             srvInfo.BaseURL,
+		TrustedServerCertificateFingerprint: srvInfo.TrustedServerCertificateFingerprint,
+		Username:                            servertesting.TestUIUsername,
+		Password:                            servertesting.TestUIPassword,
+	})
+
+	require.NoError(t, err)
+	require.NoError(t, cli.FetchCSRFTokenForTesting(ctx))
+
+	// directory listing with pagination
+	resp, err := serverapi.BrowseSnapshotDirectory(ctx, cli, string(snapID), "", 0, 0)
+	require.NoError(t, err)
+	require.Len(t, resp.Entries, 2)
+	require.Zero(t, resp.NextOffset)
+	require.Equal(t, "docs", resp.Entries[0].Name)
+	require.Equal(t, snapshot.EntryTypeDirectory, resp.Entries[0].Type)
+
+	var names []string
+
+	for offset := 0; ; {
+		resp, err = serverapi.BrowseSnapshotDirectory(ctx, cli, string(snapID), "many", offset, 10)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(resp.Entries), 10)
+
+		for _, e := range resp.Entries {
+			names = append(names, e.Name)
+		}
+
+		if resp.NextOffset == 0 {
+			break
+		}
+
+		offset = resp.NextOffset
+	}
+
+	require.Len(t, names, 25)
+	require.True(t, sort.StringsAreSorted(names))
+
+	_, err = serverapi.BrowseSnapshotDirectory(ctx, cli, string(snapID), "no-such-dir", 0, 0)
+	require.ErrorContains(t, err, "404")
+
+	_, err = serverapi.BrowseSnapshotDirectory(ctx, cli, "no-such-snapshot", "", 0, 0)
+	require.ErrorContains(t, err, "404")
+
+	get := func(urlPath string, headers map[string]string) (*http.Response, []byte) {
+		t.Helper()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, srvInfo.BaseURL+"/api/v1/snapshots/"+string(snapID)+"/fs/"+urlPath, http.NoBody)
+		require.NoError(t, err)
+
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+
+		r, err := cli.HTTPClient.Do(req)
+		require.NoError(t, err)
+
+		defer r.Body.Close()
+
+		b, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		return r, b
+	}
+
+	// whole file
+	r, b := get("docs/readme.txt", nil)
+	require.Equal(t, http.StatusOK, r.StatusCode)
+	require.Equal(t, "hello world", string(b))
+	require.Contains(t, r.Header.Get("Content-Type"), "text/plain")
+	require.Equal(t, "bytes", r.Header.Get("Accept-Ranges"))
+
+	// range request
+	r, b = get("docs/readme.txt", map[string]string{"Range": "bytes=6-"})
+	require.Equal(t, http.StatusPartialContent, r.StatusCode)
+	require.Equal(t, "world", string(b))
+
+	r, _ = get("docs/page.html?download=1", nil)
+	require.Equal(t, http.StatusOK, r.StatusCode)
+	require.Contains(t, r.Header.Get("Content-Type"), "text/html")
+	require.Equal(t, `attachment; filename=page.html`, r.Header.Get("Content-Disposition"))
+
+	// ZIP archive of a directory
+	r, b = get("docs?format=zip", nil)
+	require.Equal(t, http.StatusOK, r.StatusCode)
+	require.Equal(t, "application/zip", r.Header.Get("Content-Type"))
+
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	require.NoError(t, err)
+
+	var zipNames []string
+	for _, f := range zr.File {
+		zipNames = append(zipNames, f.Name)
+	}
+
+	require.ElementsMatch(t, []string{"page.html", "readme.txt"}, zipNames)
+
+	// TAR archive of a directory
+	r, b = get("many?format=tar", nil)
+	require.Equal(t, http.StatusOK, r.StatusCode)
+
+	tr := tar.NewReader(bytes.NewReader(b))
+	cnt := 0
+
+	for {
+		_, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		require.NoError(t, err)
+
+		cnt++
+	}
+
+	require.Equal(t, 25, cnt)
+
+	r, b = get("docs?format=rar", nil)
+	require.Equal(t, http.StatusBadRequest, r.StatusCode)
+	require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+	require.Contains(t, string(b), string(serverapi.ErrorMalformedRequest))
+
+	r, b = get("docs/no-such-file", nil)
+	require.Equal(t, http.StatusNotFound, r.StatusCode)
+	require.Contains(t, string(b), string(serverapi.ErrorPathNotFound))
+}
@@ -0,0 +1,28 @@
+package server
+
+import (
+	"context"
+
+	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+func handleFileHistory(ctx context.Context, rc requestContext) (any, *apiError) {
+	si := getSnapshotSourceFromURL(rc.req.URL)
+	if si.Host == "" || si.UserName == "" || si.Path == "" {
+		return nil, requestError(serverapi.ErrorMalformedRequest, "host, userName and path must be provided")
+	}
+
+	hist, err := snapshotfs.FindFileHistory(ctx, rc.rep, si)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	resp := &serverapi.HistoryResponse{
+		Sources: []*snapshotfs.SourceFileHistory{},
+	}
+
+	resp.Sources = append(resp.Sources, hist...)
+
+	return resp, nil
+}
@@ -0,0 +1,69 @@
+package server_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/apiclient"
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/internal/servertesting"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/upload"
+)
+
+func TestFileHistory(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	si := env.LocalPathSourceInfo("/dummy/path")
+
+	require.NoError(t, repo.WriteSession(ctx, env.Repository, repo.WriteSessionOptions{Purpose: "Test"}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		u := upload.NewUploader(w)
+
+		dir := mockfs.NewDirectory()
+		dir.AddDir("docs", 0o755).AddFile("notes.md", []byte{1, 2, 3}, 0o644)
+
+		for range 2 {
+			man, err := u.Upload(ctx, dir, nil, si)
+			require.NoError(t, err)
+
+			_, err = snapshot.SaveSnapshot(ctx, w, man)
+			require.NoError(t, err)
+		}
+
+		return nil
+	}))
+
+	srvInfo := servertesting.StartServer(t, env, false)
+
+	cli, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             srvInfo.BaseURL,
+		TrustedServerCertificateFingerprint: srvInfo.TrustedServerCertificateFingerprint,
+		Username:                            servertesting.TestUIUsername,
+		Password:                            servertesting.TestUIPassword,
+	})
+
+	require.NoError(t, err)
+	require.NoError(t, cli.FetchCSRFTokenForTesting(ctx))
+
+	fileSource := si
+	fileSource.Path = "/dummy/path/docs/notes.md"
+
+	resp, err := serverapi.GetFileHistory(ctx, cli, fileSource)
+	require.NoError(t, err)
+	require.Len(t, resp.Sources, 1)
+	require.Equal(t, si, resp.Sources[0].Source)
+	require.Equal(t, "docs/notes.md", resp.Sources[0].Path)
+	require.Len(t, resp.Sources[0].Versions, 1)
+	require.Equal(t, 2, resp.Sources[0].Versions[0].SnapshotCount)
+
+	fileSource.Path = "/other/path"
+
+	resp, err = serverapi.GetFileHistory(ctx, cli, fileSource)
+	require.NoError(t, err)
+	require.Empty(t, resp.Sources)
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/internal/uitask"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/repo/maintenancestats"
+	"github.com/kopia/kopia/snapshot/snapshotmaintenance"
+)
+
+//nolint:gochecknoglobals
+var maintenanceSafetyByName = map[string]maintenance.SafetyParameters{
+	"":     maintenance.SafetyFull,
+	"full": maintenance.SafetyFull,
+	"none": maintenance.SafetyNone,
+}
+
+func handleMaintenanceInfo(ctx context.Context, rc requestContext) (any, *apiError) {
+	dr, ok := rc.rep.(repo.DirectRepository)
+	if !ok {
+		return nil, requestError(serverapi.ErrorStorageConnection, "no direct storage connection")
+	}
+
+	p, err := maintenance.GetParams(ctx, dr)
+	if err != nil {
+		return nil, internalServerError(errors.Wrap(err, "unable to get maintenance params"))
+	}
+
+	s, err := maintenance.GetSchedule(ctx, dr)
+	if err != nil {
+		return nil, internalServerError(errors.Wrap(err, "unable to get maintenance schedule"))
+	}
+
+	resp := &serverapi.MaintenanceInfoResponse{
+		Params:                   *p,
+		NextFullMaintenanceTime:  s.NextFullMaintenanceTime,
+		NextQuickMaintenanceTime: s.NextQuickMaintenanceTime,
+		Runs:                     map[maintenance.TaskType][]serverapi.MaintenanceRunInfo{},
+	}
+
+	for taskType, runs := range s.Runs {
+		for _, r := range runs {
+			resp.Runs[taskType] = append(resp.Runs[taskType], serverapi.MaintenanceRunInfo{
+				RunInfo: r,
+				Summary: maintenanceRunSummaries(r.Extra),
+			})
+		}
+	}
+
+	return resp, nil
+}
+
+func handleMaintenanceSetParams(ctx context.Context, rc requestContext) (any, *apiError) {
+	var req maintenance.Params
+
+	if err := json.Unmarshal(rc.body, &req); err != nil {
+		return nil, unableToDecodeRequest(err)
+	}
+
+	if req.QuickCycle.Interval < 0 || req.FullCycle.Interval < 0 {
+		return nil, requestError(serverapi.ErrorMalformedRequest, "maintenance interval must not be negative")
+	}
+
+	if _, ok := rc.rep.(repo.DirectRepositoryWriter); !ok {
+		return nil, repositoryNotWritableError()
+	}
+
+	if err := repo.WriteSession(ctx, rc.rep, repo.WriteSessionOptions{
+		Purpose: "MaintenanceSetParams",
+	}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		return errors.Wrap(maintenance.SetParams(ctx, w, &req), "unable to set maintenance params")
+	}); err != nil {
+		return nil, internalServerError(err)
+	}
+
+	// pick up new schedule in the background maintenance manager.
+	rc.srv.Refresh()
+
+	return &serverapi.Empty{}, nil
+}
+
+func handleMaintenanceRun(ctx context.Context, rc requestContext) (any, *apiError) {
+	var req serverapi.MaintenanceRunRequest
+
+	if err := json.Unmarshal(rc.body, &req); err != nil {
+		return nil, unableToDecodeRequest(err)
+	}
+
+	safety, ok := maintenanceSafetyByName[req.Safety]
+	if !ok {
+		return nil, requestError(serverapi.ErrorMalformedRequest, "invalid safety level: "+req.Safety)
+	}
+
+	dr, ok := rc.rep.(repo.DirectRepositoryWriter)
+	if !ok {
+		return nil, repositoryNotWritableError()
+	}
+
+	mode := maintenance.ModeQuick
+	description := "Quick maintenance"
+
+	if req.Full {
+		mode = maintenance.ModeFull
+		description = "Full maintenance"
+	}
+
+	taskIDChan := make(chan string)
+
+	// launch a goroutine that will continue the maintenance and can be observed in the Tasks UI.
+
+	//nolint:errcheck
+	go rc.srv.taskManager().Run(ctx, "Maintenance", description, func(ctx context.Context, ctrl uitask.Controller) error {
+		taskIDChan <- ctrl.CurrentTaskID()
+
+		mctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		ctrl.OnCancel(cancel)
+
+		t0 := dr.Time()
+
+		err := repo.DirectWriteSession(mctx, dr, repo.WriteSessionOptions{
+			Purpose: "maintenanceRun",
+		}, func(ctx context.Context, w repo.DirectRepositoryWriter) error {
+			return snapshotmaintenance.Run(ctx, w, mode, false, safety)
+		})
+
+		reportMaintenanceRunResults(ctx, dr, ctrl, t0)
+
+		rc.srv.Refresh()
+
+		return errors.Wrap(err, "error running maintenance")
+	})
+
+	taskID := <-taskIDChan
+
+	task, ok := rc.srv.taskManager().GetTask(taskID)
+	if !ok {
+		return nil, internalServerError(errors.New("task not found"))
+	}
+
+	return task, nil
+}
+
+// reportMaintenanceRunResults logs summaries of maintenance tasks that started at or after the
+// provided time and reports the number of successful and failed tasks as counters.
+func reportMaintenanceRunResults(ctx context.Context, dr repo.DirectRepository, ctrl uitask.Controller, since time.Time) {
+	s, err := maintenance.GetSchedule(ctx, dr)
+	if err != nil {
+		userLog(ctx).Errorf("unable to get maintenance schedule: %v", err)
+		return
+	}
+
+	var succeeded, failed int64
+
+	for taskType, runs := range s.Runs {
+		for _, r := range runs {
+			if r.Start.Before(since) {
+				continue
+			}
+
+			if !r.Success {
+				failed++
+
+				userLog(ctx).Errorf("%v: %v", taskType, r.Error)
+
+				continue
+			}
+
+			succeeded++
+
+			for _, summary := range maintenanceRunSummaries(r.Extra) {
+				userLog(ctx).Infof("%v: %v", taskType, summary)
+			}
+		}
+	}
+
+	ctrl.ReportCounters(map[string]uitask.CounterValue{
+		"Completed Tasks": uitask.SimpleCounter(succeeded),
+		"Failed Tasks":    uitask.ErrorCounter(failed),
+	})
+}
+
+func maintenanceRunSummaries(extra []maintenancestats.Extra) []string {
+	var result []string
+
+	for _, e := range extra {
+		if s, err := maintenancestats.BuildFromExtra(e); err == nil {
+			result = append(result, s.Summary())
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,59 @@
+package server_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/apiclient"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/internal/servertesting"
+	"github.com/kopia/kopia/internal/uitask"
+	"github.com/kopia/kopia/repo/maintenance"
+)
+
+func TestMaintenanceAPI(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+	srvInfo := servertesting.StartServer(t, env, false)
+
+	cli, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             srvInfo.BaseURL,
+		TrustedServerCertificateFingerprint: srvInfo.TrustedServerCertificateFingerprint,
+		Username:                            servertesting.TestUIUsername,
+		Password:                            servertesting.TestUIPassword,
+	})
+
+	require.NoError(t, err)
+	require.NoError(t, cli.FetchCSRFTokenForTesting(ctx))
+
+	info, err := serverapi.GetMaintenanceInfo(ctx, cli)
+	require.NoError(t, err)
+
+	p := info.Params
+	p.Owner = env.Repository.ClientOptions().UsernameAtHost()
+	p.QuickCycle.Interval = 3 * time.Hour
+	require.NoError(t, serverapi.SetMaintenanceParams(ctx, cli, &p))
+
+	info, err = serverapi.GetMaintenanceInfo(ctx, cli)
+	require.NoError(t, err)
+	require.Equal(t, p.Owner, info.Params.Owner)
+	require.Equal(t, 3*time.Hour, info.Params.QuickCycle.Interval)
+
+	p.QuickCycle.Interval = -1
+	require.Error(t, serverapi.SetMaintenanceParams(ctx, cli, &p))
+
+	_, err = serverapi.RunMaintenance(ctx, cli, &serverapi.MaintenanceRunRequest{Safety: "bogus"})
+	require.Error(t, err)
+
+	task, err := serverapi.RunMaintenance(ctx, cli, &serverapi.MaintenanceRunRequest{Full: true})
+	require.NoError(t, err)
+	require.Equal(t, uitask.StatusSuccess, waitForTask(t, cli, task.TaskID, 30*time.Second).Status)
+
+	info, err = serverapi.GetMaintenanceInfo(ctx, cli)
+	require.NoError(t, err)
+	require.NotEmpty(t, info.Runs[maintenance.TaskSnapshotGarbageCollection])
+	require.NotEmpty(t, info.Runs[maintenance.TaskSnapshotGarbageCollection][0].Summary)
+	require.False(t, info.NextFullMaintenanceTime.IsZero())
+}
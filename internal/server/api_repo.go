@@ -297,6 +297,10 @@ func handleRepoSupportedAlgorithms(_ context.Context, _ requestContext) (any, *a
 		SupportedSplitterAlgorithms: toAlgorithmInfo(splitter.SupportedAlgorithms(), neverDeprecated),
 	}
 
+	res.SupportedCompressionAlgorithms = append(res.SupportedCompressionAlgorithms, serverapi.AlgorithmInfo{
+		ID: string(compression.AutoCompressorName),
+	})
+
 	for k := range compression.ByName {
 		res.SupportedCompressionAlgorithms = append(res.SupportedCompressionAlgorithms, serverapi.AlgorithmInfo{
 			ID:         string(k),
@@ -8,8 +8,10 @@
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/fs"
 	"github.com/kopia/kopia/internal/serverapi"
 	"github.com/kopia/kopia/internal/uitask"
+	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/snapshot/restore"
 	"github.com/kopia/kopia/snapshot/snapshotfs"
 )
@@ -23,6 +25,8 @@ func restoreCounters(s restore.Stats) map[string]uitask.CounterValue {
 		"Ignored Errors":       uitask.SimpleCounter(int64(s.IgnoredErrorCount)),
 		"Skipped Files":        uitask.SimpleCounter(int64(s.SkippedCount)),
 		"Skipped Bytes":        uitask.BytesCounter(s.SkippedTotalFileSize),
+		"Resumed Files":        uitask.SimpleCounter(int64(s.ResumedFileCount)),
+		"Resumed Bytes":        uitask.BytesCounter(s.ResumedTotalFileSize),
 	}
 }
 
@@ -44,56 +48,83 @@ func handleRestore(ctx context.Context, rc requestContext) (any, *apiError) {
 		return nil, requestError(serverapi.ErrorMalformedRequest, "invalid root entry")
 	}
 
-	var (
-		out         restore.Output
-		description string
-	)
+	if req.Options.Resumable && req.Filesystem == nil {
+		return nil, requestError(serverapi.ErrorMalformedRequest, "resumable restore is only supported for filesystem output")
+	}
+
+	out, description, aerr := restoreOutput(ctx, &req)
+	if aerr != nil {
+		return nil, aerr
+	}
+
+	var pendingID string
+
+	if req.Options.Resumable {
+		// resumable restores are also resumed after server restart.
+		id, err := rc.srv.getPendingRestores().add(rep, req)
+		if err != nil {
+			return nil, internalServerError(err)
+		}
+
+		pendingID = id
+	}
+
+	taskID := startRestoreTask(ctx, rc.srv, rep, rootEntry, out, description, req.Options, pendingID)
+
+	task, ok := rc.srv.taskManager().GetTask(taskID)
+	if !ok {
+		return nil, internalServerError(errors.New("task not found"))
+	}
+
+	return task, nil
+}
 
+func restoreOutput(ctx context.Context, req *serverapi.RestoreRequest) (restore.Output, string, *apiError) {
 	switch {
 	case req.Filesystem != nil:
 		if err := req.Filesystem.Init(ctx); err != nil {
-			return nil, internalServerError(err)
+			return nil, "", internalServerError(err)
 		}
 
-		out = req.Filesystem
-		description = "Destination: " + req.Filesystem.TargetPath
+		return req.Filesystem, "Destination: " + req.Filesystem.TargetPath, nil
 
 	case req.ZipFile != "":
 		f, err := os.Create(req.ZipFile)
 		if err != nil {
-			return nil, internalServerError(err)
+			return nil, "", internalServerError(err)
 		}
 
 		if req.UncompressedZip {
-			out = restore.NewZipOutput(f, zip.Store)
-			description = "Uncompressed ZIP File: " + req.ZipFile
-		} else {
-			out = restore.NewZipOutput(f, zip.Deflate)
-			description = "ZIP File: " + req.ZipFile
+			return restore.NewZipOutput(f, zip.Store), "Uncompressed ZIP File: " + req.ZipFile, nil
 		}
 
+		return restore.NewZipOutput(f, zip.Deflate), "ZIP File: " + req.ZipFile, nil
+
 	case req.TarFile != "":
 		f, err := os.Create(req.TarFile)
 		if err != nil {
-			return nil, internalServerError(err)
+			return nil, "", internalServerError(err)
 		}
 
-		out = restore.NewTarOutput(f)
-		description = "TAR File: " + req.TarFile
+		return restore.NewTarOutput(f), "TAR File: " + req.TarFile, nil
 
 	default:
-		return nil, requestError(serverapi.ErrorMalformedRequest, "output not specified")
+		return nil, "", requestError(serverapi.ErrorMalformedRequest, "output not specified")
 	}
+}
 
+// startRestoreTask launches a goroutine that will perform the restore and can be observed in the Tasks UI,
+// and returns the ID of the task.
+func startRestoreTask(ctx context.Context, srv serverInterface, rep repo.Repository, rootEntry fs.Entry, out restore.Output, description string, opt restore.Options, pendingID string) string {
 	taskIDChan := make(chan string)
 
-	// launch a goroutine that will continue the restore and can be observed in the Tasks UI.
-
 	//nolint:errcheck
-	go rc.srv.taskManager().Run(ctx, "Restore", description, func(ctx context.Context, ctrl uitask.Controller) error {
+	go srv.taskManager().Run(ctx, "Restore", description, func(ctx context.Context, ctrl uitask.Controller) error {
 		taskIDChan <- ctrl.CurrentTaskID()
 
-		opt := req.Options
+		if pendingID != "" {
+			opt.JournalPath = srv.getPendingRestores().journalPath(pendingID)
+		}
 
 		opt.ProgressCallback = func(_ context.Context, s restore.Stats) {
 			ctrl.ReportCounters(restoreCounters(s))
@@ -111,15 +142,56 @@ func handleRestore(ctx context.Context, rc requestContext) (any, *apiError) {
 			ctrl.ReportCounters(restoreCounters(st))
 		}
 
+		// the restore is no longer pending unless the server stops before getting here.
+		if rerr := srv.getPendingRestores().remove(pendingID); rerr != nil {
+			userLog(ctx).Errorf("unable to remove pending restore: %v", rerr)
+		}
+
 		return errors.Wrap(err, "error restoring")
 	})
 
-	taskID := <-taskIDChan
+	return <-taskIDChan
+}
 
-	task, ok := rc.srv.taskManager().GetTask(taskID)
-	if !ok {
-		return nil, internalServerError(errors.New("task not found"))
+// resumePendingRestores restarts restores into filesystem of the provided repository which have been
+// interrupted by server restart. Restores which are already running are not started again.
+func (s *Server) resumePendingRestores(ctx context.Context, rep repo.Repository) {
+	restores, err := s.pendingRestores.claim(rep)
+	if err != nil {
+		userLog(ctx).Errorf("unable to list pending restores: %v", err)
+		return
 	}
 
-	return task, nil
+	for _, pr := range restores {
+		req := pr.Request
+
+		if req.Filesystem == nil {
+			s.dropPendingRestore(ctx, pr, errors.New("output not specified"))
+			continue
+		}
+
+		rootEntry, err := snapshotfs.FilesystemEntryFromIDWithPath(ctx, rep, req.Root, false)
+		if err != nil {
+			s.dropPendingRestore(ctx, pr, err)
+			continue
+		}
+
+		out, description, aerr := restoreOutput(ctx, &req)
+		if aerr != nil {
+			s.dropPendingRestore(ctx, pr, errors.New(aerr.message))
+			continue
+		}
+
+		userLog(ctx).Infof("resuming restore of %v into %v", req.Root, req.Filesystem.TargetPath)
+
+		startRestoreTask(ctx, s, rep, rootEntry, out, description, req.Options, pr.ID)
+	}
+}
+
+func (s *Server) dropPendingRestore(ctx context.Context, pr pendingRestore, err error) {
+	userLog(ctx).Errorf("unable to resume restore of %v: %v", pr.Request.Root, err)
+
+	if rerr := s.pendingRestores.remove(pr.ID); rerr != nil {
+		userLog(ctx).Errorf("unable to remove pending restore: %v", rerr)
+	}
 }
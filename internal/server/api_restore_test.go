@@ -2,7 +2,9 @@
 
 import (
 	"context"
+	"encoding/json"
 	"math"
+	"os"
 	"path/filepath"
 	"testing"
 	"time"
@@ -11,8 +13,11 @@
 	"github.com/stretchr/testify/require"
 
 	"github.com/kopia/kopia/internal/apiclient"
+	"github.com/kopia/kopia/internal/auth"
 	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/passwordpersist"
 	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/server"
 	"github.com/kopia/kopia/internal/serverapi"
 	"github.com/kopia/kopia/internal/servertesting"
 	"github.com/kopia/kopia/internal/testutil"
@@ -77,6 +82,9 @@ func TestRestoreSnapshots(t *testing.T) {
 		waitForTask(t, cli, restoreTask1.TaskID, 30*time.Second)
 		require.FileExists(t, filepath.Join(targetPath1, "file1"))
 		require.FileExists(t, filepath.Join(targetPath1, "dir1", "file2"))
+
+		// restores are not journaled unless requested.
+		require.NoFileExists(t, restore.JournalPath(targetPath1))
 	})
 
 	t.Run("FilesystemSubdir", func(t *testing.T) {
@@ -207,6 +215,11 @@ func TestRestoreSnapshots(t *testing.T) {
 				Root:    string(id11),
 				TarFile: "/no/such/directory/" + uuid.NewString() + "/test1.tar",
 			},
+			{
+				Root:    string(id11),
+				Options: restore.Options{Resumable: true},
+				ZipFile: filepath.Join(testutil.TempDirectory(t), "test1.zip"),
+			},
 		}
 
 		for _, req := range requests {
@@ -218,3 +231,96 @@ func TestRestoreSnapshots(t *testing.T) {
 		}
 	})
 }
+
+func TestResumePendingRestores(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	dir1 := mockfs.NewDirectory()
+	dir1.AddFile("file1", []byte{1, 2, 3}, 0o644)
+	dir1.AddDir("dir1", 0o755).AddFile("file2", []byte{1, 2, 4}, 0o644)
+
+	man, err := upload.NewUploader(env.RepositoryWriter).Upload(ctx, dir1, nil, env.LocalPathSourceInfo("/dummy/path"))
+	require.NoError(t, err)
+
+	id, err := snapshot.SaveSnapshot(ctx, env.RepositoryWriter, man)
+	require.NoError(t, err)
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	targetPath := testutil.TempDirectory(t)
+	pendingRestoresFile := filepath.Join(testutil.TempDirectory(t), "pending-restores.json")
+
+	// restore interrupted by server restart.
+	b, err := json.Marshal([]any{
+		map[string]any{
+			"id": "interrupted",
+			"request": &serverapi.RestoreRequest{
+				Root: string(id),
+				Options: restore.Options{
+					RestoreDirEntryAtDepth: math.MaxInt32,
+					Resumable:              true,
+				},
+				Filesystem: &restore.FilesystemOutput{
+					TargetPath:      targetPath,
+					SkipOwners:      true,
+					SkipPermissions: true,
+				},
+			},
+		},
+		map[string]any{
+			"id":      "invalid",
+			"request": &serverapi.RestoreRequest{Root: "no-such-snapshot"},
+		},
+		// restores of other repositories are kept until they are connected.
+		map[string]any{
+			"id":         "other-repository",
+			"repository": "repo:0123",
+			"request":    &serverapi.RestoreRequest{Root: "no-such-snapshot"},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(pendingRestoresFile, b, 0o600))
+
+	s, err := server.New(ctx, &server.Options{
+		ConfigFile:          env.ConfigFile(),
+		PasswordPersist:     passwordpersist.File(),
+		Authorizer:          auth.LegacyAuthorizer(),
+		PendingRestoresFile: pendingRestoresFile,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, s.SetRepository(ctx, env.Repository))
+
+	t.Cleanup(func() { s.SetRepository(ctx, nil) })
+
+	remainingIDs := func() []string {
+		b, err := os.ReadFile(pendingRestoresFile)
+		require.NoError(t, err)
+
+		var remaining []struct {
+			ID string `json:"id"`
+		}
+
+		require.NoError(t, json.Unmarshal(b, &remaining))
+
+		var ids []string
+
+		for _, pr := range remaining {
+			ids = append(ids, pr.ID)
+		}
+
+		return ids
+	}
+
+	require.Eventually(t, func() bool {
+		return len(remainingIDs()) == 1
+	}, 30*time.Second, 100*time.Millisecond)
+
+	require.Equal(t, []string{"other-repository"}, remainingIDs())
+
+	require.FileExists(t, filepath.Join(targetPath, "file1"))
+	require.FileExists(t, filepath.Join(targetPath, "dir1", "file2"))
+
+	// the journal is stored next to pending restores, not next to the target.
+	require.NoFileExists(t, restore.JournalPath(targetPath))
+	require.NoFileExists(t, filepath.Join(filepath.Dir(pendingRestoresFile), "restore-journals", "interrupted.journal"))
+}
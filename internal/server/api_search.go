@@ -0,0 +1,30 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/snapshot/snapshotsearch"
+)
+
+func handleSearch(ctx context.Context, rc requestContext) (any, *apiError) {
+	var req serverapi.SearchRequest
+
+	if err := json.Unmarshal(rc.body, &req); err != nil {
+		return nil, unableToDecodeRequest(err)
+	}
+
+	res, err := snapshotsearch.Search(ctx, rc.rep, req.Query)
+	if errors.Is(err, snapshotsearch.ErrInvalidQuery) {
+		return nil, requestError(serverapi.ErrorMalformedRequest, err.Error())
+	}
+
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	return &serverapi.SearchResponse{Results: *res}, nil
+}
@@ -0,0 +1,66 @@
+package server_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/apiclient"
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/internal/servertesting"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotsearch"
+	"github.com/kopia/kopia/snapshot/upload"
+)
+
+func TestSearch(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	si := env.LocalPathSourceInfo("/dummy/path")
+
+	require.NoError(t, repo.WriteSession(ctx, env.Repository, repo.WriteSessionOptions{Purpose: "Test"}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		u := upload.NewUploader(w)
+
+		dir := mockfs.NewDirectory()
+		dir.AddFile("budget-2023.xlsx", []byte{1, 2, 3}, 0o644)
+		dir.AddFile("other.txt", []byte{1, 2, 4}, 0o644)
+
+		man, err := u.Upload(ctx, dir, nil, si)
+		require.NoError(t, err)
+
+		_, err = snapshot.SaveSnapshot(ctx, w, man)
+		require.NoError(t, err)
+
+		_, err = snapshotsearch.BuildIndex(ctx, w, man)
+		require.NoError(t, err)
+
+		return nil
+	}))
+
+	srvInfo := servertesting.StartServer(t, env, false)
+
+	cli, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             srvInfo.BaseURL,
+		TrustedServerCertificateFingerprint: srvInfo.TrustedServerCertificateFingerprint,
+		Username:                            servertesting.TestUIUsername,
+		Password:                            servertesting.TestUIPassword,
+	})
+
+	require.NoError(t, err)
+	require.NoError(t, cli.FetchCSRFTokenForTesting(ctx))
+
+	resp, err := serverapi.Search(ctx, cli, &serverapi.SearchRequest{Query: snapshotsearch.Query{Pattern: "budget*"}})
+	require.NoError(t, err)
+	require.Equal(t, 1, resp.SnapshotsSearched)
+	require.Len(t, resp.Files, 1)
+	require.Equal(t, "budget-2023.xlsx", resp.Files[0].Path)
+	require.Equal(t, si, resp.Files[0].Source)
+	require.Len(t, resp.Files[0].Versions, 1)
+
+	_, err = serverapi.Search(ctx, cli, &serverapi.SearchRequest{Query: snapshotsearch.Query{Pattern: "(", Regex: true}})
+	require.Error(t, err)
+}
@@ -55,13 +55,16 @@ func handleSourcesCreate(ctx context.Context, rc requestContext) (any, *apiError
 
 	req.Path = ospath.ResolveUserFriendlyPath(req.Path, true)
 
-	_, err := os.Stat(req.Path)
-	if os.IsNotExist(err) {
-		return nil, requestError(serverapi.ErrorPathNotFound, "path does not exist")
-	}
+	// sources defined by commands do not correspond to local directories.
+	if !req.Policy.SourceCommands.IsCommandSource() {
+		_, err := os.Stat(req.Path)
+		if os.IsNotExist(err) {
+			return nil, requestError(serverapi.ErrorPathNotFound, "path does not exist")
+		}
 
-	if err != nil {
-		return nil, internalServerError(err)
+		if err != nil {
+			return nil, internalServerError(err)
+		}
 	}
 
 	sourceInfo := snapshot.SourceInfo{
@@ -72,7 +75,7 @@ func handleSourcesCreate(ctx context.Context, rc requestContext) (any, *apiError
 
 	resp := &serverapi.CreateSnapshotSourceResponse{}
 
-	if err = repo.WriteSession(ctx, rc.rep, repo.WriteSessionOptions{
+	if err := repo.WriteSession(ctx, rc.rep, repo.WriteSessionOptions{
 		Purpose: "handleSourcesCreate",
 	}, func(ctx context.Context, w repo.RepositoryWriter) error {
 		return policy.SetPolicy(ctx, w, sourceInfo, req.Policy)
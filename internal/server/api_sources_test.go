@@ -3,6 +3,7 @@
 import (
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 	"time"
 
@@ -15,6 +16,7 @@
 	"github.com/kopia/kopia/internal/servertesting"
 	"github.com/kopia/kopia/internal/testutil"
 	"github.com/kopia/kopia/internal/uitask"
+	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/policy"
 )
@@ -162,3 +164,61 @@ func TestSourceRefreshesAfterPolicy(t *testing.T) {
 
 	require.True(t, match)
 }
+
+func TestCommandSource(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires unix shell")
+	}
+
+	// source commands are only run when actions are enabled.
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant, repotesting.Options{
+		ConnectOptions: func(o *repo.ConnectOptions) {
+			o.EnableActions = true
+		},
+	})
+	srvInfo := servertesting.StartServer(t, env, false)
+
+	cli, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             srvInfo.BaseURL,
+		TrustedServerCertificateFingerprint: srvInfo.TrustedServerCertificateFingerprint,
+		Username:                            servertesting.TestUIUsername,
+		Password:                            servertesting.TestUIPassword,
+	})
+
+	require.NoError(t, err)
+	require.NoError(t, cli.FetchCSRFTokenForTesting(ctx))
+
+	// command sources do not need a local directory.
+	dir := filepath.Join(testutil.TempDirectory(t), "no-such-dir")
+
+	_, err = serverapi.CreateSnapshotSource(ctx, cli, &serverapi.CreateSnapshotSourceRequest{
+		Path:           dir,
+		CreateSnapshot: true,
+		Policy: &policy.Policy{
+			SourceCommands: policy.SourceCommandsPolicy{
+				Commands: []policy.SourceCommand{
+					{FileName: "dump.sql", Command: "sh", Arguments: []string{"-c", "echo dump"}},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	deadline := clock.Now().Add(30 * time.Second)
+
+	var sources []*serverapi.SourceStatus
+
+	for clock.Now().Before(deadline) {
+		sources = mustListSources(t, cli, &snapshot.SourceInfo{})
+		if len(sources) == 1 && sources[0].LastSnapshot != nil {
+			break
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	require.Len(t, sources, 1)
+	require.Equal(t, "commands", sources[0].InitialSourceType)
+	require.NotNil(t, sources[0].LastSnapshot)
+	require.Equal(t, int64(5), sources[0].LastSnapshot.Stats.TotalFileSize)
+}
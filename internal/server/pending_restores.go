@@ -0,0 +1,188 @@
+package server
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/natefinch/atomic"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/repo"
+)
+
+// pendingRestore is a restore into filesystem which is resumed if the server restarts before it completes.
+type pendingRestore struct {
+	ID string `json:"id"`
+
+	// Repository identifies the repository containing the restored snapshot, restores written
+	// before it was recorded are resumed in the first connected repository.
+	Repository string                   `json:"repository,omitempty"`
+	Request    serverapi.RestoreRequest `json:"request"`
+}
+
+// pendingRestoreStore persists pending restores in a JSON file and keeps track of the ones being restored.
+// Journals of pending restores are stored in a directory next to the file.
+type pendingRestoreStore struct {
+	filename   string
+	configFile string // identifies repositories which don't expose their unique ID
+
+	mu sync.Mutex
+	// +checklocks:mu
+	running map[string]bool
+}
+
+// repositoryIdentity returns the identity of the provided repository recorded in pending restores.
+func (s *pendingRestoreStore) repositoryIdentity(rep repo.Repository) string {
+	if dr, ok := rep.(repo.DirectRepository); ok {
+		return "repo:" + hex.EncodeToString(dr.UniqueID())
+	}
+
+	// repositories accessed through the API server don't expose their unique ID.
+	return "config:" + s.configFile
+}
+
+// journalPath returns the path of the journal of the restore with the provided identifier.
+func (s *pendingRestoreStore) journalPath(id string) string {
+	if s == nil || id == "" {
+		return ""
+	}
+
+	return filepath.Join(filepath.Dir(s.filename), "restore-journals", id+".journal")
+}
+
+// +checklocks:s.mu
+func (s *pendingRestoreStore) loadLocked() ([]pendingRestore, error) {
+	var result []pendingRestore
+
+	b, err := os.ReadFile(s.filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read pending restores")
+	}
+
+	if err := json.Unmarshal(b, &result); err != nil {
+		return nil, errors.Wrap(err, "invalid pending restores file")
+	}
+
+	return result, nil
+}
+
+// +checklocks:s.mu
+func (s *pendingRestoreStore) saveLocked(restores []pendingRestore) error {
+	if len(restores) == 0 {
+		if err := os.Remove(s.filename); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "unable to remove pending restores")
+		}
+
+		return nil
+	}
+
+	b, err := json.Marshal(restores)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal pending restores")
+	}
+
+	return errors.Wrap(atomic.WriteFile(s.filename, bytes.NewReader(b)), "unable to write pending restores")
+}
+
+// claim returns pending restores of the provided repository which are not running
+// and marks them as running until they are removed.
+func (s *pendingRestoreStore) claim(rep repo.Repository) ([]pendingRestore, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	repositoryID := s.repositoryIdentity(rep)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	restores, err := s.loadLocked()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []pendingRestore
+
+	for _, pr := range restores {
+		if s.running[pr.ID] || (pr.Repository != "" && pr.Repository != repositoryID) {
+			continue
+		}
+
+		s.markRunningLocked(pr.ID)
+
+		result = append(result, pr)
+	}
+
+	return result, nil
+}
+
+// add persists the restore request of the provided repository, marks it as running and returns its identifier.
+func (s *pendingRestoreStore) add(rep repo.Repository, req serverapi.RestoreRequest) (string, error) {
+	if s == nil {
+		return "", nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	restores, err := s.loadLocked()
+	if err != nil {
+		return "", err
+	}
+
+	pr := pendingRestore{ID: uuid.NewString(), Repository: s.repositoryIdentity(rep), Request: req}
+
+	if err := s.saveLocked(append(restores, pr)); err != nil {
+		return "", err
+	}
+
+	s.markRunningLocked(pr.ID)
+
+	return pr.ID, nil
+}
+
+// +checklocks:s.mu
+func (s *pendingRestoreStore) markRunningLocked(id string) {
+	if s.running == nil {
+		s.running = map[string]bool{}
+	}
+
+	s.running[id] = true
+}
+
+// remove removes the restore with the provided identifier.
+func (s *pendingRestoreStore) remove(id string) error {
+	if s == nil || id == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.running, id)
+
+	// the journal is kept when the restore did not complete, but it will not be resumed anymore.
+	if err := os.Remove(s.journalPath(id)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "unable to remove restore journal")
+	}
+
+	restores, err := s.loadLocked()
+	if err != nil {
+		return err
+	}
+
+	return s.saveLocked(slices.DeleteFunc(restores, func(pr pendingRestore) bool {
+		return pr.ID == id
+	}))
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/internal/testutil"
+)
+
+func TestPendingRestoreStore(t *testing.T) {
+	filename := filepath.Join(testutil.TempDirectory(t), "pending-restores.json")
+
+	s := &pendingRestoreStore{filename: filename, configFile: "repo1.config"}
+
+	id1, err := s.add(nil, serverapi.RestoreRequest{Root: "root1"})
+	require.NoError(t, err)
+
+	// restores started by this server are running until removed.
+	claimed, err := s.claim(nil)
+	require.NoError(t, err)
+	require.Empty(t, claimed)
+
+	// after restart, pending restores are claimed only once and only by their repository.
+	s = &pendingRestoreStore{filename: filename, configFile: "repo1.config"}
+
+	other := &pendingRestoreStore{filename: filename, configFile: "repo2.config"}
+
+	claimed, err = other.claim(nil)
+	require.NoError(t, err)
+	require.Empty(t, claimed)
+
+	claimed, err = s.claim(nil)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	require.Equal(t, id1, claimed[0].ID)
+
+	claimed, err = s.claim(nil)
+	require.NoError(t, err)
+	require.Empty(t, claimed)
+
+	require.NoError(t, s.remove(id1))
+	require.NoFileExists(t, filename)
+}
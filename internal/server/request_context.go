@@ -37,6 +37,7 @@ type serverInterface interface {
 	SetRepository(ctx context.Context, rep repo.Repository) error
 	InitRepositoryAsync(ctx context.Context, mode string, initializer InitRepositoryFunc, wait bool) (string, error)
 	rootContext() context.Context
+	getPendingRestores() *pendingRestoreStore
 }
 
 type requestContext struct {
@@ -101,6 +101,7 @@ type Server struct {
 	mounts map[object.ID]mount.Controller
 
 	taskmgr              *uitask.Manager
+	pendingRestores      *pendingRestoreStore
 	authCookieSigningKey []byte
 
 	// channel to which we can post to trigger scheduler re-evaluation.
@@ -137,8 +138,12 @@ func (s *Server) SetupHTMLUIAPIHandlers(m *mux.Router) {
 	m.HandleFunc("/api/v1/policies", s.handleUI(handlePolicyList)).Methods(http.MethodGet)
 	m.HandleFunc("/api/v1/refresh", s.handleUI(handleRefresh)).Methods(http.MethodPost)
 	m.HandleFunc("/api/v1/objects/{objectID}", s.requireAuth(csrfTokenNotRequired, handleObjectGet)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/snapshots/{snapshotID}/fs", s.requireAuth(csrfTokenNotRequired, handleSnapshotBrowse)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/snapshots/{snapshotID}/fs/{path:.*}", s.requireAuth(csrfTokenNotRequired, handleSnapshotBrowse)).Methods(http.MethodGet)
 	m.HandleFunc("/api/v1/restore", s.handleUI(handleRestore)).Methods(http.MethodPost)
 	m.HandleFunc("/api/v1/estimate", s.handleUI(handleEstimate)).Methods(http.MethodPost)
+	m.HandleFunc("/api/v1/search", s.handleUI(handleSearch)).Methods(http.MethodPost)
+	m.HandleFunc("/api/v1/history", s.handleUI(handleFileHistory)).Methods(http.MethodGet)
 	m.HandleFunc("/api/v1/paths/resolve", s.handleUI(handlePathResolve)).Methods(http.MethodPost)
 	m.HandleFunc("/api/v1/cli", s.handleUI(handleCLIInfo)).Methods(http.MethodGet)
 	m.HandleFunc("/api/v1/repo/status", s.handleUIPossiblyNotConnected(handleRepoStatus)).Methods(http.MethodGet)
@@ -152,6 +157,10 @@ func (s *Server) SetupHTMLUIAPIHandlers(m *mux.Router) {
 	m.HandleFunc("/api/v1/repo/throttle", s.handleUI(handleRepoGetThrottle)).Methods(http.MethodGet)
 	m.HandleFunc("/api/v1/repo/throttle", s.handleUI(handleRepoSetThrottle)).Methods(http.MethodPut)
 
+	m.HandleFunc("/api/v1/maintenance", s.handleUI(handleMaintenanceInfo)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/maintenance", s.handleUI(handleMaintenanceSetParams)).Methods(http.MethodPut)
+	m.HandleFunc("/api/v1/maintenance/run", s.handleUI(handleMaintenanceRun)).Methods(http.MethodPost)
+
 	m.HandleFunc("/api/v1/mounts", s.handleUI(handleMountCreate)).Methods(http.MethodPost)
 	m.HandleFunc("/api/v1/mounts/{rootObjectID}", s.handleUI(handleMountDelete)).Methods(http.MethodDelete)
 	m.HandleFunc("/api/v1/mounts/{rootObjectID}", s.handleUI(handleMountGet)).Methods(http.MethodGet)
@@ -191,6 +200,10 @@ func (s *Server) SetupControlAPIHandlers(m *mux.Router) {
 	m.HandleFunc("/api/v1/control/throttle", s.handleServerControlAPI(handleRepoSetThrottle)).Methods(http.MethodPut)
 }
 
+func (s *Server) getPendingRestores() *pendingRestoreStore {
+	return s.pendingRestores
+}
+
 func (s *Server) rootContext() context.Context {
 	return s.rootctx
 }
@@ -654,6 +667,8 @@ func (s *Server) SetRepository(ctx context.Context, rep repo.Repository) error {
 		RefreshChannel: s.schedulerRefresh,
 	})
 
+	go s.resumePendingRestores(context.WithoutCancel(ctx), rep)
+
 	return nil
 }
 
@@ -852,6 +867,7 @@ type Options struct {
 	LogRequests              bool
 	UIUser                   string // name of the user allowed to access the UI API
 	UIPreferencesFile        string // name of the JSON file storing UI preferences
+	PendingRestoresFile      string // name of the JSON file storing restores to be resumed after restart
 	ServerControlUser        string // name of the user allowed to access the server control API
 	DisableCSRFTokenChecks   bool
 	PersistentLogs           bool
@@ -1138,5 +1154,9 @@ func New(ctx context.Context, options *Options) (*Server, error) {
 
 	s.parallelSnapshotsChanged = sync.NewCond(&s.parallelSnapshotsMutex)
 
+	if options.PendingRestoresFile != "" {
+		s.pendingRestores = &pendingRestoreStore{filename: options.PendingRestoresFile, configFile: options.ConfigFile}
+	}
+
 	return s, nil
 }
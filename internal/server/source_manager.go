@@ -14,21 +14,29 @@
 	"github.com/kopia/kopia/internal/serverapi"
 	"github.com/kopia/kopia/internal/uitask"
 	"github.com/kopia/kopia/notification/notifydata"
+	"github.com/kopia/kopia/notification/notifytemplate"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/commandsource"
 	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/kopia/kopia/snapshot/snapshotanomaly"
+	"github.com/kopia/kopia/snapshot/snapshotsearch"
 	"github.com/kopia/kopia/snapshot/upload"
 )
 
 const (
 	failedSnapshotRetryInterval = 5 * time.Minute
 	refreshTimeout              = 30 * time.Second // max amount of time to refresh a single source
+
+	// commandSourceType is reported as the source type of sources defined by commands.
+	commandSourceType = "commands"
 )
 
 type sourceManagerServerInterface interface {
 	runSnapshotTask(ctx context.Context, src snapshot.SourceInfo, inner func(ctx context.Context, ctrl uitask.Controller, result *notifydata.ManifestWithError) error) error
 	refreshScheduler(reason string)
 	taskManager() *uitask.Manager
+	notificationTemplateOptions() notifytemplate.Options
 }
 
 // sourceManager manages the state machine of each source
@@ -342,11 +350,6 @@ func (s *sourceManager) snapshotInternal(ctx context.Context, ctrl uitask.Contro
 	default:
 	}
 
-	localEntry, err := localfs.NewEntry(s.src.Path)
-	if err != nil {
-		return errors.Wrap(err, "unable to create local filesystem")
-	}
-
 	onUpload := func(int64) {}
 
 	s.sourceMutex.Lock()
@@ -378,6 +381,11 @@ func (s *sourceManager) snapshotInternal(ctx context.Context, ctrl uitask.Contro
 			return errors.Wrap(err, "unable to create policy getter")
 		}
 
+		sourceEntry, err := s.sourceEntry(ctx, policyTree.EffectivePolicy(), u.EnableActions)
+		if err != nil {
+			return err
+		}
+
 		// set up progress that will keep counters and report to the uitask.
 		prog := &uitaskProgress{
 			p:    s.progress,
@@ -391,7 +399,7 @@ func (s *sourceManager) snapshotInternal(ctx context.Context, ctrl uitask.Contro
 		userLog(ctx).Debugf("starting upload of %v", s.src)
 		s.setUploader(u)
 
-		manifest, err := u.Upload(ctx, localEntry, policyTree, s.src, manifestsSinceLastCompleteSnapshot...)
+		manifest, err := u.Upload(ctx, sourceEntry, policyTree, s.src, manifestsSinceLastCompleteSnapshot...)
 
 		prog.report(true)
 		s.setUploader(nil)
@@ -400,6 +408,12 @@ func (s *sourceManager) snapshotInternal(ctx context.Context, ctrl uitask.Contro
 			return errors.Wrap(err, "upload error")
 		}
 
+		if cd, ok := sourceEntry.(*commandsource.Directory); ok {
+			if err := cd.Err(); err != nil {
+				return errors.Wrap(err, "source command error")
+			}
+		}
+
 		result.Manifest = *manifest
 
 		ignoreIdenticalSnapshot := policyTree.EffectivePolicy().RetentionPolicy.IgnoreIdenticalSnapshots.OrDefault(false)
@@ -415,6 +429,17 @@ func (s *sourceManager) snapshotInternal(ctx context.Context, ctrl uitask.Contro
 			return errors.Wrap(err, "unable to save snapshot")
 		}
 
+		if policyTree.EffectivePolicy().UploadPolicy.BuildSearchIndex.OrDefault(false) {
+			if _, err := snapshotsearch.BuildIndex(ctx, w, manifest); err != nil {
+				userLog(ctx).Errorf("unable to build search index: %v", err)
+			}
+		}
+
+		// must be done before applying retention policy, which could otherwise expire the last good snapshot.
+		if err := snapshotanomaly.Report(ctx, w, manifest, manifestsSinceLastCompleteSnapshot, policyTree.EffectivePolicy(), s.server.notificationTemplateOptions()); err != nil {
+			userLog(ctx).Errorf("unable to report snapshot anomalies: %v", err)
+		}
+
 		if _, err := policy.ApplyRetentionPolicy(ctx, w, s.src, true); err != nil {
 			return errors.Wrap(err, "unable to apply retention policy")
 		}
@@ -425,6 +450,25 @@ func (s *sourceManager) snapshotInternal(ctx context.Context, ctrl uitask.Contro
 	})
 }
 
+// sourceEntry returns the entry to be snapshotted, which is either the local directory
+// or a synthetic directory with the output of source commands.
+func (s *sourceManager) sourceEntry(ctx context.Context, pol *policy.Policy, actionsEnabled bool) (fs.Entry, error) {
+	if pol.SourceCommands.IsCommandSource() {
+		if !actionsEnabled {
+			return nil, commandsource.ErrActionsDisabled
+		}
+
+		return commandsource.NewDirectory(ctx, s.src.Path, pol.SourceCommands.Commands), nil
+	}
+
+	localEntry, err := localfs.NewEntry(s.src.Path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create local filesystem")
+	}
+
+	return localEntry, nil
+}
+
 // +checklocksread:s.sourceMutex
 func (s *sourceManager) findClosestNextSnapshotTimeReadLocked() *time.Time {
 	var previousSnapshotTime fs.UTCTimestamp
@@ -467,6 +511,11 @@ func (s *sourceManager) refreshStatus(ctx context.Context) {
 	s.name = pol.Name
 	s.emoji = pol.Emoji
 	s.initialSourceType = pol.InitialSourceType
+
+	if s.initialSourceType == "" && pol.SourceCommands.IsCommandSource() {
+		s.initialSourceType = commandSourceType
+	}
+
 	s.pol = pol.SchedulingPolicy
 	s.manifestsSinceLastCompleteSnapshot = nil
 	s.lastCompleteSnapshot = nil
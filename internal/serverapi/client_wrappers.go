@@ -3,6 +3,8 @@
 import (
 	"context"
 	"fmt"
+	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -10,6 +12,7 @@
 	"github.com/kopia/kopia/internal/apiclient"
 	"github.com/kopia/kopia/internal/uitask"
 	"github.com/kopia/kopia/repo/blob/throttling"
+	"github.com/kopia/kopia/repo/maintenance"
 	"github.com/kopia/kopia/repo/object"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/policy"
@@ -45,6 +48,86 @@ func Restore(ctx context.Context, c *apiclient.KopiaAPIClient, req *RestoreReque
 	return resp, nil
 }
 
+// GetMaintenanceInfo returns maintenance parameters, schedule and history of recent runs.
+func GetMaintenanceInfo(ctx context.Context, c *apiclient.KopiaAPIClient) (*MaintenanceInfoResponse, error) {
+	resp := &MaintenanceInfoResponse{}
+	if err := c.Get(ctx, "maintenance", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "GetMaintenanceInfo")
+	}
+
+	return resp, nil
+}
+
+// SetMaintenanceParams sets repository maintenance parameters.
+func SetMaintenanceParams(ctx context.Context, c *apiclient.KopiaAPIClient, p *maintenance.Params) error {
+	if err := c.Put(ctx, "maintenance", p, &Empty{}); err != nil {
+		return errors.Wrap(err, "SetMaintenanceParams")
+	}
+
+	return nil
+}
+
+// RunMaintenance starts maintenance task.
+func RunMaintenance(ctx context.Context, c *apiclient.KopiaAPIClient, req *MaintenanceRunRequest) (*uitask.Info, error) {
+	resp := &uitask.Info{}
+	if err := c.Post(ctx, "maintenance/run", req, resp); err != nil {
+		return nil, errors.Wrap(err, "RunMaintenance")
+	}
+
+	return resp, nil
+}
+
+// Search finds files in snapshot search indexes.
+func Search(ctx context.Context, c *apiclient.KopiaAPIClient, req *SearchRequest) (*SearchResponse, error) {
+	resp := &SearchResponse{}
+	if err := c.Post(ctx, "search", req, resp); err != nil {
+		return nil, errors.Wrap(err, "Search")
+	}
+
+	return resp, nil
+}
+
+// GetFileHistory returns distinct versions of the provided file or directory in snapshots.
+func GetFileHistory(ctx context.Context, c *apiclient.KopiaAPIClient, si snapshot.SourceInfo) (*HistoryResponse, error) {
+	resp := &HistoryResponse{}
+	q := url.Values{
+		"host":     {si.Host},
+		"userName": {si.UserName},
+		"path":     {si.Path},
+	}
+
+	if err := c.Get(ctx, "history?"+q.Encode(), nil, resp); err != nil {
+		return nil, errors.Wrap(err, "GetFileHistory")
+	}
+
+	return resp, nil
+}
+
+// BrowseSnapshotDirectory returns a page of entries of a directory in a snapshot.
+func BrowseSnapshotDirectory(ctx context.Context, c *apiclient.KopiaAPIClient, snapshotID, dirPath string, offset, limit int) (*BrowseDirectoryResponse, error) {
+	resp := &BrowseDirectoryResponse{}
+
+	q := url.Values{
+		"offset": {strconv.Itoa(offset)},
+		"limit":  {strconv.Itoa(limit)},
+	}
+
+	if err := c.Get(ctx, "snapshots/"+url.PathEscape(snapshotID)+"/fs/"+escapePath(dirPath)+"?"+q.Encode(), nil, resp); err != nil {
+		return nil, errors.Wrap(err, "BrowseSnapshotDirectory")
+	}
+
+	return resp, nil
+}
+
+func escapePath(p string) string {
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+
+	return strings.Join(parts, "/")
+}
+
 // GetTask starts snapshot estimation task for a given directory.
 func GetTask(ctx context.Context, c *apiclient.KopiaAPIClient, taskID string) (*uitask.Info, error) {
 	resp := &uitask.Info{}
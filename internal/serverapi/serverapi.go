@@ -10,11 +10,14 @@
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/format"
+	"github.com/kopia/kopia/repo/maintenance"
 	"github.com/kopia/kopia/repo/manifest"
 	"github.com/kopia/kopia/repo/object"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/policy"
 	"github.com/kopia/kopia/snapshot/restore"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/kopia/kopia/snapshot/snapshotsearch"
 	"github.com/kopia/kopia/snapshot/upload"
 )
 
@@ -276,6 +279,56 @@ type EstimateRequest struct {
 	PolicyOverride       *policy.Policy `json:"policyOverride"`
 }
 
+// MaintenanceRunInfo describes a single run of a maintenance task, including human-readable
+// summaries of statistics reported by the task.
+type MaintenanceRunInfo struct {
+	maintenance.RunInfo
+
+	Summary []string `json:"summary,omitempty"`
+}
+
+// MaintenanceInfoResponse is the response of 'maintenance' HTTP API command.
+type MaintenanceInfoResponse struct {
+	Params                   maintenance.Params                            `json:"params"`
+	NextFullMaintenanceTime  time.Time                                     `json:"nextFullMaintenance"`
+	NextQuickMaintenanceTime time.Time                                     `json:"nextQuickMaintenance"`
+	Runs                     map[maintenance.TaskType][]MaintenanceRunInfo `json:"runs"`
+}
+
+// MaintenanceRunRequest contains request to run maintenance.
+type MaintenanceRunRequest struct {
+	Full bool `json:"full"`
+
+	// Safety is the name of safety level to use ("full" or "none"), defaults to "full".
+	Safety string `json:"safety,omitempty"`
+}
+
+// SearchRequest contains request to find files in snapshot search indexes.
+type SearchRequest struct {
+	snapshotsearch.Query
+}
+
+// SearchResponse contains files found in snapshot search indexes.
+type SearchResponse struct {
+	snapshotsearch.Results
+}
+
+// BrowseDirectoryResponse contains a page of entries of a directory in a snapshot, in the order
+// in which they are stored: directories first, then ordered by name.
+type BrowseDirectoryResponse struct {
+	Path    string               `json:"path"`
+	Entries []*snapshot.DirEntry `json:"entries"`
+	Offset  int                  `json:"offset"`
+
+	// NextOffset is the offset of the next page or zero if there are no more entries.
+	NextOffset int `json:"nextOffset,omitempty"`
+}
+
+// HistoryResponse contains distinct versions of a file in snapshots of sources containing it.
+type HistoryResponse struct {
+	Sources []*snapshotfs.SourceFileHistory `json:"sources"`
+}
+
 // ResolvePolicyRequest contains request structure to ResolvePolicy.
 type ResolvePolicyRequest struct {
 	Updates                  *policy.Policy `json:"updates"`
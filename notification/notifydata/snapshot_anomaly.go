@@ -0,0 +1,30 @@
+package notifydata
+
+import (
+	"time"
+
+	"github.com/kopia/kopia/internal/grpcapi"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// SnapshotAnomaly represents information about mass changes of files detected in a snapshot,
+// which may indicate that the files were encrypted by ransomware.
+type SnapshotAnomaly struct {
+	Source     snapshot.SourceInfo `json:"source"`
+	SnapshotID string              `json:"snapshotID"`
+	StartTime  time.Time           `json:"start"`
+	Anomalies  []snapshot.Anomaly  `json:"anomalies"`
+
+	// ID of the previous snapshot, which was pinned to protect it from expiration, if any.
+	PinnedSnapshotID string `json:"pinnedSnapshotID,omitempty"`
+}
+
+// EventArgsType returns the type of event arguments for SnapshotAnomaly.
+func (e *SnapshotAnomaly) EventArgsType() grpcapi.NotificationEventArgType {
+	return grpcapi.NotificationEventArgType_ARG_TYPE_SNAPSHOT_ANOMALY
+}
+
+// StartTimestamp returns the start time of the snapshot.
+func (e *SnapshotAnomaly) StartTimestamp() time.Time {
+	return e.StartTime.Truncate(time.Second)
+}
@@ -0,0 +1,35 @@
+package notifydata
+
+import (
+	"time"
+
+	"github.com/kopia/kopia/internal/grpcapi"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// SourceBitRotFile describes a source file whose contents have changed without a change of its size or modification time.
+type SourceBitRotFile struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+}
+
+// SourceBitRot represents information about source files with probable silent corruption,
+// detected by re-hashing them and comparing with the snapshot.
+type SourceBitRot struct {
+	Source      snapshot.SourceInfo `json:"source"`
+	SnapshotID  string              `json:"snapshotID"`
+	StartTime   time.Time           `json:"start"`
+	HashedFiles int64               `json:"hashedFiles"`
+	Files       []SourceBitRotFile  `json:"files"`
+}
+
+// EventArgsType returns the type of event arguments for SourceBitRot.
+func (e *SourceBitRot) EventArgsType() grpcapi.NotificationEventArgType {
+	return grpcapi.NotificationEventArgType_ARG_TYPE_SOURCE_BIT_ROT
+}
+
+// StartTimestamp returns the start time of the snapshot which the source was compared with.
+func (e *SourceBitRot) StartTimestamp() time.Time {
+	return e.StartTime.Truncate(time.Second)
+}
@@ -28,6 +28,12 @@ func UnmarshalEventArgs(data []byte, notificationEventArgType grpcapi.Notificati
 	case grpcapi.NotificationEventArgType_ARG_TYPE_ERROR_INFO:
 		payload = &ErrorInfo{}
 
+	case grpcapi.NotificationEventArgType_ARG_TYPE_SNAPSHOT_ANOMALY:
+		payload = &SnapshotAnomaly{}
+
+	case grpcapi.NotificationEventArgType_ARG_TYPE_SOURCE_BIT_ROT:
+		payload = &SourceBitRot{}
+
 	default:
 		return nil, errors.Errorf("unsupported notification event arg type: %v", notificationEventArgType)
 	}
@@ -23,6 +23,8 @@
 // Template names.
 const (
 	TestNotification = "test-notification"
+	SnapshotAnomaly  = "snapshot-anomaly"
+	SourceBitRot     = "source-bit-rot"
 )
 
 // Options provides options for template rendering.
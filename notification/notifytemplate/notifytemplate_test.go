@@ -215,6 +215,48 @@ func TestNotifyTemplate_snapshot_report_single_success(t *testing.T) {
 	verifyTemplate(t, "snapshot-report.html", ".success", args, defaultTestOptions)
 }
 
+func TestNotifyTemplate_snapshot_anomaly(t *testing.T) {
+	args := notification.MakeTemplateArgs(&notifydata.SnapshotAnomaly{
+		Source:     snapshot.SourceInfo{Host: "some-host", UserName: "some-user", Path: "/some/path"},
+		SnapshotID: "some-snapshot-id",
+		StartTime:  time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC),
+		Anomalies: []snapshot.Anomaly{
+			{Kind: snapshot.AnomalyExtensionChurn, Description: "90% of files were renamed with the \".locked\" extension appended (900 of 1000), threshold is 20%"},
+			{Kind: snapshot.AnomalyModifiedFiles, Description: "95% of files were modified (950 of 1000), threshold is 50%"},
+		},
+		PinnedSnapshotID: "some-previous-snapshot-id",
+	})
+
+	args.EventTime = time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	args.Hostname = "some-host"
+
+	verifyTemplate(t, "snapshot-anomaly.txt", ".default", args, defaultTestOptions)
+	verifyTemplate(t, "snapshot-anomaly.html", ".default", args, defaultTestOptions)
+	verifyTemplate(t, "snapshot-anomaly.txt", ".alt", args, altTestOptions)
+	verifyTemplate(t, "snapshot-anomaly.html", ".alt", args, altTestOptions)
+}
+
+func TestNotifyTemplate_source_bit_rot(t *testing.T) {
+	args := notification.MakeTemplateArgs(&notifydata.SourceBitRot{
+		Source:      snapshot.SourceInfo{Host: "some-host", UserName: "some-user", Path: "/some/path"},
+		SnapshotID:  "some-snapshot-id",
+		StartTime:   time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC),
+		HashedFiles: 12345,
+		Files: []notifydata.SourceBitRotFile{
+			{Path: "photos/a.jpg", Size: 3000000, ModTime: time.Date(2019, 5, 6, 7, 8, 9, 0, time.UTC)},
+			{Path: "photos/b.jpg", Size: 4000000, ModTime: time.Date(2019, 5, 6, 7, 8, 10, 0, time.UTC)},
+		},
+	})
+
+	args.EventTime = time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	args.Hostname = "some-host"
+
+	verifyTemplate(t, "source-bit-rot.txt", ".default", args, defaultTestOptions)
+	verifyTemplate(t, "source-bit-rot.html", ".default", args, defaultTestOptions)
+	verifyTemplate(t, "source-bit-rot.txt", ".alt", args, altTestOptions)
+	verifyTemplate(t, "source-bit-rot.html", ".alt", args, altTestOptions)
+}
+
 func verifyTemplate(t *testing.T, embeddedTemplateName, expectedSuffix string, args any, opt notifytemplate.Options) {
 	t.Helper()
 
@@ -0,0 +1,45 @@
+package archive
+
+import (
+	"time"
+
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// The archive is a single file consisting of:
+//
+//	header: magic (8 bytes), format version (4 bytes, big endian)
+//	blob data, stored one after another
+//	index: JSON-encoded archiveIndex
+//	footer: index offset (8 bytes, big endian), index length (8 bytes, big endian), magic (8 bytes)
+//
+// The footer allows the index to be located without scanning the file, which makes it possible to
+// serve blobs directly from the archive.
+const (
+	archiveMagic   = "KOPIAARC"
+	archiveVersion = 1
+
+	headerLength = len(archiveMagic) + 4     //nolint:mnd
+	footerLength = 8 + 8 + len(archiveMagic) //nolint:mnd
+)
+
+// archiveIndex describes blobs stored in the archive.
+type archiveIndex struct {
+	Blobs []indexEntry `json:"blobs"`
+}
+
+// indexEntry describes a single blob stored in the archive.
+type indexEntry struct {
+	BlobID    blob.ID   `json:"id"`
+	Offset    int64     `json:"offset"`
+	Length    int64     `json:"length"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func (e indexEntry) metadata() blob.Metadata {
+	return blob.Metadata{
+		BlobID:    e.BlobID,
+		Length:    e.Length,
+		Timestamp: e.Timestamp,
+	}
+}
@@ -0,0 +1,6 @@
+package archive
+
+// Options defines options for archive-backed storage.
+type Options struct {
+	Path string `json:"path"`
+}
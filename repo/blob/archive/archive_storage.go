@@ -0,0 +1,235 @@
+// Package archive implements read-only Storage backed by a single-file archive of blobs.
+package archive
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/iocopy"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/readonly"
+)
+
+const archiveStorageType = "archive"
+
+type archiveStorage struct {
+	blob.DefaultProviderImplementation
+
+	Options
+
+	f *os.File
+
+	// entries sorted by blob ID and the same entries indexed by blob ID.
+	entries []indexEntry
+	byID    map[blob.ID]indexEntry
+}
+
+func (s *archiveStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64, output blob.OutputBuffer) error {
+	if err := ctx.Err(); err != nil {
+		return errors.Wrap(err, "get blob failed")
+	}
+
+	output.Reset()
+
+	e, ok := s.byID[id]
+	if !ok {
+		return blob.ErrBlobNotFound
+	}
+
+	if length < 0 {
+		offset, length = 0, e.Length
+	}
+
+	if offset < 0 || offset > e.Length {
+		return errors.Wrapf(blob.ErrInvalidRange, "invalid offset: %v", offset)
+	}
+
+	if offset+length > e.Length {
+		return errors.Wrapf(blob.ErrInvalidRange, "invalid length: %v", length)
+	}
+
+	if err := iocopy.JustCopy(output, io.NewSectionReader(s.f, e.Offset+offset, length)); err != nil {
+		return errors.Wrapf(err, "error reading blob %v", id)
+	}
+
+	//nolint:wrapcheck
+	return blob.EnsureLengthExactly(output.Length(), length)
+}
+
+func (s *archiveStorage) GetMetadata(ctx context.Context, id blob.ID) (blob.Metadata, error) {
+	if err := ctx.Err(); err != nil {
+		return blob.Metadata{}, errors.Wrap(err, "get metadata failed")
+	}
+
+	e, ok := s.byID[id]
+	if !ok {
+		return blob.Metadata{}, blob.ErrBlobNotFound
+	}
+
+	return e.metadata(), nil
+}
+
+func (s *archiveStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	first := sort.Search(len(s.entries), func(i int) bool {
+		return s.entries[i].BlobID >= prefix
+	})
+
+	for _, e := range s.entries[first:] {
+		if !strings.HasPrefix(string(e.BlobID), string(prefix)) {
+			break
+		}
+
+		if err := ctx.Err(); err != nil {
+			return errors.Wrap(err, "list blobs failed")
+		}
+
+		if err := callback(e.metadata()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//nolint:revive
+func (s *archiveStorage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes, opts blob.PutOptions) error {
+	return readonly.ErrReadonly
+}
+
+//nolint:revive
+func (s *archiveStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
+	return readonly.ErrReadonly
+}
+
+func (s *archiveStorage) IsReadOnly() bool {
+	return true
+}
+
+func (s *archiveStorage) Close(_ context.Context) error {
+	return errors.Wrap(s.f.Close(), "error closing archive")
+}
+
+func (s *archiveStorage) ConnectionInfo() blob.ConnectionInfo {
+	return blob.ConnectionInfo{
+		Type:   archiveStorageType,
+		Config: &s.Options,
+	}
+}
+
+func (s *archiveStorage) DisplayName() string {
+	return fmt.Sprintf("Archive: %v", s.Path)
+}
+
+// readIndex reads and validates the header, footer and index of the archive.
+func readIndex(f *os.File) ([]indexEntry, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to stat archive")
+	}
+
+	if fi.Size() < int64(headerLength+footerLength) {
+		return nil, errors.New("archive is too short")
+	}
+
+	var header [headerLength]byte
+
+	if _, err := f.ReadAt(header[:], 0); err != nil {
+		return nil, errors.Wrap(err, "unable to read archive header")
+	}
+
+	if string(header[:len(archiveMagic)]) != archiveMagic {
+		return nil, errors.New("not an archive")
+	}
+
+	if v := binary.BigEndian.Uint32(header[len(archiveMagic):]); v != archiveVersion {
+		return nil, errors.Errorf("unsupported archive version %v", v)
+	}
+
+	var footer [footerLength]byte
+
+	if _, err := f.ReadAt(footer[:], fi.Size()-int64(footerLength)); err != nil {
+		return nil, errors.Wrap(err, "unable to read archive footer")
+	}
+
+	if string(footer[16:]) != archiveMagic {
+		return nil, errors.New("archive footer is corrupted, the archive may be truncated")
+	}
+
+	indexOffset := int64(binary.BigEndian.Uint64(footer[0:])) //nolint:gosec
+	indexLength := int64(binary.BigEndian.Uint64(footer[8:])) //nolint:gosec
+
+	if indexOffset < int64(headerLength) || indexLength < 0 || indexLength > fi.Size()-int64(footerLength)-indexOffset {
+		return nil, errors.New("invalid archive index location")
+	}
+
+	indexBytes := make([]byte, indexLength)
+
+	if _, err := f.ReadAt(indexBytes, indexOffset); err != nil {
+		return nil, errors.Wrap(err, "unable to read archive index")
+	}
+
+	var ind archiveIndex
+
+	if err := json.NewDecoder(bytes.NewReader(indexBytes)).Decode(&ind); err != nil {
+		return nil, errors.Wrap(err, "invalid archive index")
+	}
+
+	for _, e := range ind.Blobs {
+		if e.Offset < int64(headerLength) || e.Length < 0 || e.Length > indexOffset-e.Offset {
+			return nil, errors.Errorf("invalid location of blob %v", e.BlobID)
+		}
+	}
+
+	sort.Slice(ind.Blobs, func(i, j int) bool {
+		return ind.Blobs[i].BlobID < ind.Blobs[j].BlobID
+	})
+
+	return ind.Blobs, nil
+}
+
+// New opens the archive as read-only storage.
+func New(ctx context.Context, opts *Options, isCreate bool) (blob.Storage, error) {
+	_ = ctx
+
+	if isCreate {
+		return nil, errors.Wrap(readonly.ErrReadonly, "archives can only be created by exporting snapshots")
+	}
+
+	f, err := os.Open(opts.Path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open archive")
+	}
+
+	entries, err := readIndex(f)
+	if err != nil {
+		f.Close() //nolint:errcheck
+
+		return nil, errors.Wrapf(err, "unable to open archive %v", opts.Path)
+	}
+
+	s := &archiveStorage{
+		Options: *opts,
+		f:       f,
+		entries: entries,
+		byID:    map[blob.ID]indexEntry{},
+	}
+
+	for _, e := range entries {
+		s.byID[e.BlobID] = e
+	}
+
+	return s, nil
+}
+
+func init() {
+	blob.AddSupportedStorage(archiveStorageType, Options{}, New)
+}
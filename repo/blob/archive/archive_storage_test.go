@@ -0,0 +1,152 @@
+package archive_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/archive"
+	"github.com/kopia/kopia/repo/blob/readonly"
+)
+
+func TestArchiveStorage(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	src := blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil)
+
+	blobs := map[blob.ID][]byte{
+		"abc1": []byte("first blob"),
+		"abc2": bytes.Repeat([]byte{1, 2, 3}, 10000),
+		"def1": {},
+		"xyz":  []byte("the last blob."),
+	}
+
+	for id, data := range blobs {
+		require.NoError(t, src.PutBlob(ctx, id, gather.FromSlice(data), blob.PutOptions{}))
+	}
+
+	fname := filepath.Join(testutil.TempDirectory(t), "test.archive")
+
+	var buf bytes.Buffer
+
+	require.NoError(t, archive.Write(ctx, &buf, src))
+	require.NoError(t, os.WriteFile(fname, buf.Bytes(), 0o600))
+
+	st, err := archive.New(ctx, &archive.Options{Path: fname}, false)
+	require.NoError(t, err)
+
+	defer st.Close(ctx)
+
+	require.True(t, st.IsReadOnly())
+
+	for id, data := range blobs {
+		blobtesting.AssertGetBlob(ctx, t, st, id, data)
+
+		want, err := src.GetMetadata(ctx, id)
+		require.NoError(t, err)
+
+		got, err := st.GetMetadata(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, want.Length, got.Length)
+		require.True(t, want.Timestamp.Equal(got.Timestamp))
+	}
+
+	blobtesting.AssertGetBlobNotFound(ctx, t, st, "no-such-blob")
+	blobtesting.AssertGetMetadataNotFound(ctx, t, st, "no-such-blob")
+	blobtesting.AssertInvalidOffsetLength(ctx, t, st, "abc1", 5, 100)
+
+	blobtesting.AssertListResultsIDs(ctx, t, st, "", "abc1", "abc2", "def1", "xyz")
+	blobtesting.AssertListResultsIDs(ctx, t, st, "abc", "abc1", "abc2")
+	blobtesting.AssertListResultsIDs(ctx, t, st, "d", "def1")
+	blobtesting.AssertListResultsIDs(ctx, t, st, "q")
+
+	require.ErrorIs(t, st.PutBlob(ctx, "new", gather.FromSlice([]byte{1}), blob.PutOptions{}), readonly.ErrReadonly)
+	require.ErrorIs(t, st.DeleteBlob(ctx, "abc1"), readonly.ErrReadonly)
+
+	// archive can be reopened based on its connection info.
+	st2, err := blob.NewStorage(ctx, st.ConnectionInfo(), false)
+	require.NoError(t, err)
+	blobtesting.AssertGetBlob(ctx, t, st2, "xyz", blobs["xyz"])
+	require.NoError(t, st2.Close(ctx))
+
+	_, err = archive.New(ctx, &archive.Options{Path: fname}, true)
+	require.ErrorIs(t, err, readonly.ErrReadonly)
+}
+
+func TestArchiveStorageInvalid(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	src := blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil)
+	require.NoError(t, src.PutBlob(ctx, "abc", gather.FromSlice([]byte("some data")), blob.PutOptions{}))
+
+	var buf bytes.Buffer
+
+	require.NoError(t, archive.Write(ctx, &buf, src))
+
+	dir := testutil.TempDirectory(t)
+
+	cases := map[string][]byte{
+		"empty":          nil,
+		"truncated":      buf.Bytes()[:buf.Len()-5],
+		"not-an-archive": bytes.Repeat([]byte("x"), 100),
+
+		// offsets and lengths in malformed footers and indexes must not overflow when validated.
+		"index-length-overflow":     craftedArchiveWithFooter(12, math.MaxInt64, `{"blobs":[]}`),
+		"index-offset-overflow":     craftedArchiveWithFooter(math.MaxInt64, 12, `{"blobs":[]}`),
+		"negative-index-length":     craftedArchiveWithFooter(12, -1, `{"blobs":[]}`),
+		"index-past-footer":         craftedArchiveWithFooter(12, 100, `{"blobs":[]}`),
+		"blob-length-overflow":      craftedArchive(`{"blobs":[{"id":"abc","offset":12,"length":9223372036854775807}]}`),
+		"blob-offset-overflow":      craftedArchive(`{"blobs":[{"id":"abc","offset":9223372036854775807,"length":1}]}`),
+		"blob-overlapping-index":    craftedArchive(`{"blobs":[{"id":"abc","offset":12,"length":1}]}`),
+		"negative-blob-length":      craftedArchive(`{"blobs":[{"id":"abc","offset":12,"length":-1}]}`),
+		"blob-offset-inside-header": craftedArchive(`{"blobs":[{"id":"abc","offset":0,"length":0}]}`),
+	}
+
+	for name, data := range cases {
+		fname := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(fname, data, 0o600))
+
+		_, err := archive.New(ctx, &archive.Options{Path: fname}, false)
+		require.Error(t, err, name)
+	}
+
+	_, err := archive.New(ctx, &archive.Options{Path: filepath.Join(dir, "no-such-file")}, false)
+	require.Error(t, err)
+
+	// sanity check, a well-formed crafted archive can be opened.
+	fname := filepath.Join(dir, "crafted")
+	require.NoError(t, os.WriteFile(fname, craftedArchive(`{"blobs":[]}`), 0o600))
+
+	st, err := archive.New(ctx, &archive.Options{Path: fname}, false)
+	require.NoError(t, err)
+	require.NoError(t, st.Close(ctx))
+}
+
+// craftedArchive returns an archive consisting of the header, the provided index and the footer.
+func craftedArchive(index string) []byte {
+	return craftedArchiveWithFooter(12, int64(len(index)), index)
+}
+
+// craftedArchiveWithFooter returns an archive consisting of the header, the index and the footer pointing at the provided index location.
+func craftedArchiveWithFooter(indexOffset, indexLength int64, index string) []byte {
+	var b bytes.Buffer
+
+	b.WriteString("KOPIAARC")
+	binary.Write(&b, binary.BigEndian, uint32(1)) //nolint:errcheck
+	b.WriteString(index)
+	binary.Write(&b, binary.BigEndian, indexOffset) //nolint:errcheck
+	binary.Write(&b, binary.BigEndian, indexLength) //nolint:errcheck
+	b.WriteString("KOPIAARC")
+
+	return b.Bytes()
+}
@@ -0,0 +1,92 @@
+package archive
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// Write writes all blobs from the provided storage to an archive, which can be later opened using New().
+func Write(ctx context.Context, w io.Writer, src blob.Reader) error {
+	blobs, err := blob.ListAllBlobs(ctx, src, "")
+	if err != nil {
+		return errors.Wrap(err, "unable to list blobs")
+	}
+
+	sort.Slice(blobs, func(i, j int) bool {
+		return blobs[i].BlobID < blobs[j].BlobID
+	})
+
+	cw := &countingWriter{w: w}
+
+	var header [headerLength]byte
+
+	copy(header[:], archiveMagic)
+	binary.BigEndian.PutUint32(header[len(archiveMagic):], archiveVersion)
+
+	if _, err := cw.Write(header[:]); err != nil {
+		return errors.Wrap(err, "error writing archive header")
+	}
+
+	var (
+		ind  archiveIndex
+		data gather.WriteBuffer
+	)
+
+	defer data.Close()
+
+	for _, bm := range blobs {
+		if err := src.GetBlob(ctx, bm.BlobID, 0, -1, &data); err != nil {
+			return errors.Wrapf(err, "error reading blob %v", bm.BlobID)
+		}
+
+		ind.Blobs = append(ind.Blobs, indexEntry{
+			BlobID:    bm.BlobID,
+			Offset:    cw.n,
+			Length:    int64(data.Length()),
+			Timestamp: bm.Timestamp,
+		})
+
+		if _, err := data.Bytes().WriteTo(cw); err != nil {
+			return errors.Wrapf(err, "error writing blob %v", bm.BlobID)
+		}
+	}
+
+	indexOffset := cw.n
+
+	if err := json.NewEncoder(cw).Encode(ind); err != nil {
+		return errors.Wrap(err, "error writing archive index")
+	}
+
+	var footer [footerLength]byte
+
+	binary.BigEndian.PutUint64(footer[0:], uint64(indexOffset))      //nolint:gosec
+	binary.BigEndian.PutUint64(footer[8:], uint64(cw.n-indexOffset)) //nolint:gosec
+	copy(footer[16:], archiveMagic)
+
+	if _, err := cw.Write(footer[:]); err != nil {
+		return errors.Wrap(err, "error writing archive footer")
+	}
+
+	return nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+
+	//nolint:wrapcheck
+	return n, err
+}
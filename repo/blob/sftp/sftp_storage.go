@@ -541,6 +541,17 @@ func getSFTPClient(ctx context.Context, opt *Options) (*sftpConnection, error) {
 	}, nil
 }
 
+// NewClient connects to the SFTP server using the provided options and returns the client along with
+// the function that closes the connection.
+func NewClient(ctx context.Context, opts *Options) (*sftp.Client, func() error, error) {
+	conn, err := getSFTPClient(ctx, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return conn.currentClient, conn.Close, nil
+}
+
 // New creates new ssh-backed storage in a specified host.
 func New(ctx context.Context, opts *Options, isCreate bool) (blob.Storage, error) {
 	impl := &sftpImpl{
@@ -257,17 +257,24 @@ func isRetriable(err error) bool {
 	}
 }
 
-// New creates new WebDAV-backed storage in a specified URL.
-func New(_ context.Context, opts *Options, isCreate bool) (blob.Storage, error) {
+// NewClient returns WebDAV client for the server at the URL specified in the provided options.
+func NewClient(opts *Options) *gowebdav.Client {
 	cli := gowebdav.NewClient(opts.URL, opts.Username, opts.Password)
 
-	// Since we're handling encrypted data, there's no point compressing it server-side.
-	cli.SetHeader("Accept-Encoding", "identity")
-
 	if opts.TrustedServerCertificateFingerprint != "" {
 		cli.SetTransport(tlsutil.TransportTrustingSingleCertificate(opts.TrustedServerCertificateFingerprint))
 	}
 
+	return cli
+}
+
+// New creates new WebDAV-backed storage in a specified URL.
+func New(_ context.Context, opts *Options, isCreate bool) (blob.Storage, error) {
+	cli := NewClient(opts)
+
+	// Since we're handling encrypted data, there's no point compressing it server-side.
+	cli.SetHeader("Accept-Encoding", "identity")
+
 	s := retrying.NewWrapper(&davStorage{
 		Storage: sharded.New(&davStorageImpl{
 			Options: *opts,
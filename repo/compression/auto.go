@@ -0,0 +1,188 @@
+package compression
+
+import (
+	"io"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// AutoCompressorName is the name of a pseudo-compressor that selects between no compression,
+// fast and strong compression based on estimated entropy of the data being compressed.
+const AutoCompressorName Name = "auto"
+
+// Compressors selected by AutoSelector.
+const (
+	AutoFastCompressor   Name = "zstd-fastest"
+	AutoStrongCompressor Name = "zstd"
+)
+
+const (
+	// maximum number of bytes sampled to estimate entropy.
+	autoSampleSize = 64 << 10
+
+	// number of evenly-spaced slices taken from data larger than autoSampleSize.
+	autoSampleSlices = 16
+
+	// samples shorter than this don't provide reliable entropy estimate and their decisions are not cached.
+	autoMinCachedSampleSize = 4 << 10
+
+	// data with entropy (in bits per byte) above this threshold is considered incompressible,
+	// typically because it's already compressed or encrypted.
+	autoIncompressibleEntropy = 7.5
+
+	// data with entropy below this threshold compresses well enough to use strong compression.
+	autoStrongCompressionEntropy = 5.5
+)
+
+// EstimateEntropy returns Shannon entropy of byte distribution in the provided data in bits per byte.
+func EstimateEntropy(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+
+	var counts [256]int
+
+	for _, b := range data {
+		counts[b]++
+	}
+
+	var (
+		entropy float64
+		total   = float64(len(data))
+	)
+
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+
+		p := float64(c) / total
+		entropy -= p * math.Log2(p)
+	}
+
+	return entropy
+}
+
+// sampleForEntropy returns up to autoSampleSize bytes sampled evenly from the provided data.
+func sampleForEntropy(data io.ReaderAt, length int) []byte {
+	if length <= autoSampleSize {
+		sample := make([]byte, length)
+		n, _ := data.ReadAt(sample, 0)
+
+		return sample[:n]
+	}
+
+	sliceLen := autoSampleSize / autoSampleSlices
+	stride := length / autoSampleSlices
+
+	sample := make([]byte, autoSampleSize)
+	total := 0
+
+	for i := range autoSampleSlices {
+		n, _ := data.ReadAt(sample[total:total+sliceLen], int64(i*stride))
+		total += n
+	}
+
+	return sample[:total]
+}
+
+// AutoSelectionStats contains the number of decisions made by AutoSelector.
+type AutoSelectionStats struct {
+	None   int32 `json:"none"`
+	Fast   int32 `json:"fast"`
+	Strong int32 `json:"strong"`
+}
+
+// AutoSelector selects compression based on entropy estimated from a sample of data
+// and remembers the decision for each key (typically a file extension).
+type AutoSelector struct {
+	mu sync.Mutex
+	// +checklocks:mu
+	decisions map[string]Name
+
+	// set for selectors returned by ForFile()
+	file *fileSelection
+
+	noneCount   atomic.Int32
+	fastCount   atomic.Int32
+	strongCount atomic.Int32
+}
+
+// NewAutoSelector returns new AutoSelector.
+func NewAutoSelector() *AutoSelector {
+	return &AutoSelector{
+		decisions: map[string]Name{},
+	}
+}
+
+// fileSelection is the single decision made for all objects of a file.
+type fileSelection struct {
+	parent   *AutoSelector
+	once     sync.Once
+	decision Name
+}
+
+// ForFile returns a selector for all objects written for a single file, such as parts of a large file
+// uploaded in parallel. The decision made for the first object is used for all of them and is counted
+// once in the stats of s, which also remembers it as usual.
+func (s *AutoSelector) ForFile() *AutoSelector {
+	return &AutoSelector{file: &fileSelection{parent: s}}
+}
+
+// Select returns the name of the compressor to use for data identified by the provided key, sampling the
+// provided data unless a decision has already been made for the key. Empty name means no compression.
+func (s *AutoSelector) Select(key string, data io.ReaderAt, length int) Name {
+	if f := s.file; f != nil {
+		f.once.Do(func() {
+			f.decision = f.parent.Select(key, data, length)
+		})
+
+		return f.decision
+	}
+
+	s.mu.Lock()
+	decision, ok := s.decisions[key]
+	s.mu.Unlock()
+
+	if !ok {
+		decision = selectByEntropy(EstimateEntropy(sampleForEntropy(data, length)))
+
+		if key != "" && length >= autoMinCachedSampleSize {
+			s.mu.Lock()
+			s.decisions[key] = decision
+			s.mu.Unlock()
+		}
+	}
+
+	switch decision {
+	case "":
+		s.noneCount.Add(1)
+	case AutoFastCompressor:
+		s.fastCount.Add(1)
+	default:
+		s.strongCount.Add(1)
+	}
+
+	return decision
+}
+
+// Stats returns the number of decisions made so far.
+func (s *AutoSelector) Stats() AutoSelectionStats {
+	return AutoSelectionStats{
+		None:   s.noneCount.Load(),
+		Fast:   s.fastCount.Load(),
+		Strong: s.strongCount.Load(),
+	}
+}
+
+func selectByEntropy(entropy float64) Name {
+	switch {
+	case entropy >= autoIncompressibleEntropy:
+		return ""
+	case entropy >= autoStrongCompressionEntropy:
+		return AutoFastCompressor
+	default:
+		return AutoStrongCompressor
+	}
+}
@@ -0,0 +1,64 @@
+package compression
+
+import (
+	"bytes"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateEntropy(t *testing.T) {
+	require.Zero(t, EstimateEntropy(nil))
+	require.Zero(t, EstimateEntropy(bytes.Repeat([]byte{7}, 1000)))
+	require.InDelta(t, 1.0, EstimateEntropy(bytes.Repeat([]byte{1, 2}, 1000)), 1e-9)
+
+	rnd := make([]byte, 1<<20)
+	rand.Read(rnd)
+
+	require.Greater(t, EstimateEntropy(rnd), 7.9)
+
+	text := []byte(strings.Repeat("the quick brown fox jumps over the lazy dog. ", 1000))
+	require.Less(t, EstimateEntropy(text), 5.0)
+}
+
+func TestAutoSelector(t *testing.T) {
+	rnd := make([]byte, 1<<20)
+	rand.Read(rnd)
+
+	text := []byte(strings.Repeat("the quick brown fox jumps over the lazy dog. ", 1000))
+
+	// 64 distinct byte values, uniformly distributed has entropy of exactly 6 bits per byte.
+	medium := make([]byte, 100000)
+	for i := range medium {
+		medium[i] = byte(rnd[i] % 64)
+	}
+
+	s := NewAutoSelector()
+
+	require.Equal(t, Name(""), s.Select("", bytes.NewReader(rnd), len(rnd)))
+	require.Equal(t, AutoStrongCompressor, s.Select("", bytes.NewReader(text), len(text)))
+	require.Equal(t, AutoFastCompressor, s.Select("", bytes.NewReader(medium), len(medium)))
+	require.Equal(t, AutoSelectionStats{None: 1, Fast: 1, Strong: 1}, s.Stats())
+
+	// decision is remembered per key.
+	require.Equal(t, Name(""), s.Select(".zip", bytes.NewReader(rnd), len(rnd)))
+	require.Equal(t, Name(""), s.Select(".zip", bytes.NewReader(text), len(text)))
+	require.Equal(t, AutoStrongCompressor, s.Select(".txt", bytes.NewReader(text), len(text)))
+	require.Equal(t, AutoSelectionStats{None: 3, Fast: 1, Strong: 2}, s.Stats())
+
+	// short data is sampled, but the decision is not remembered.
+	require.Equal(t, AutoStrongCompressor, s.Select(".dat", bytes.NewReader(text[0:100]), 100))
+	require.Equal(t, Name(""), s.Select(".dat", bytes.NewReader(rnd), len(rnd)))
+
+	// all objects of a file use the decision made for the first one, which is counted once.
+	s = NewAutoSelector()
+	f := s.ForFile()
+
+	require.Equal(t, AutoStrongCompressor, f.Select(".bin", bytes.NewReader(text), len(text)))
+	require.Equal(t, AutoStrongCompressor, f.Select(".bin", bytes.NewReader(rnd), len(rnd)))
+	require.Equal(t, AutoStrongCompressor, s.Select(".bin", bytes.NewReader(rnd), len(rnd)))
+	require.Equal(t, AutoSelectionStats{Strong: 2}, s.Stats())
+	require.Equal(t, AutoSelectionStats{}, f.Stats())
+}
@@ -28,4 +28,8 @@
 	headerDeflateDefault         HeaderID = 0x1500
 	headerDeflateBestSpeed       HeaderID = 0x1501
 	headerDeflateBestCompression HeaderID = 0x1502
+
+	HeaderZstdDictDefault           HeaderID = 0x1600
+	HeaderZstdDictFastest           HeaderID = 0x1601
+	HeaderZstdDictBetterCompression HeaderID = 0x1602
 )
@@ -0,0 +1,133 @@
+package compression
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/iocopy"
+)
+
+const dictionaryIDSize = 4
+
+func init() {
+	RegisterCompressor("zstd-dict", newZstdDictCompressor(HeaderZstdDictDefault, HeaderZstdDefault, zstd.SpeedDefault))
+	RegisterCompressor("zstd-fastest-dict", newZstdDictCompressor(HeaderZstdDictFastest, HeaderZstdFastest, zstd.SpeedFastest))
+	RegisterCompressor("zstd-better-compression-dict", newZstdDictCompressor(HeaderZstdDictBetterCompression, HeaderZstdBetterCompression, zstd.SpeedBetterCompression))
+}
+
+// zstdDictCompressor is a zstd compressor that references a trained dictionary. The compressed
+// data consists of the compression header, 32-bit dictionary ID (0 when no dictionary was used)
+// followed by zstd frame.
+type zstdDictCompressor struct {
+	zstdCompressor
+
+	level zstd.EncoderLevel
+	plain HeaderID
+}
+
+func newZstdDictCompressor(id, plain HeaderID, level zstd.EncoderLevel) Compressor {
+	return &zstdDictCompressor{
+		zstdCompressor: zstdCompressor{id, compressionHeader(id), sync.Pool{
+			New: func() any {
+				w, err := zstd.NewWriter(io.Discard, zstd.WithEncoderLevel(level))
+				mustSucceed(err)
+
+				return w
+			},
+		}},
+		level: level,
+		plain: plain,
+	}
+}
+
+func (c *zstdDictCompressor) HeaderIDWithoutDictionary() HeaderID {
+	return c.plain
+}
+
+func (c *zstdDictCompressor) Compress(output io.Writer, input io.Reader) error {
+	return c.CompressWithDictionary(output, input, nil)
+}
+
+func (c *zstdDictCompressor) CompressWithDictionary(output io.Writer, input io.Reader, d *Dictionary) error {
+	var hdr [compressionHeaderSize + dictionaryIDSize]byte
+
+	copy(hdr[:], c.header)
+
+	pool := &c.pool
+
+	if d != nil {
+		binary.BigEndian.PutUint32(hdr[compressionHeaderSize:], d.ID)
+
+		pool = d.encoderPool(c.level)
+	}
+
+	if _, err := output.Write(hdr[:]); err != nil {
+		return errors.Wrap(err, "unable to write header")
+	}
+
+	//nolint:forcetypeassert
+	w := pool.Get().(*zstd.Encoder)
+	defer pool.Put(w)
+
+	w.Reset(output)
+
+	if err := iocopy.JustCopy(w, input); err != nil {
+		return errors.Wrap(err, "compression error")
+	}
+
+	if err := w.Close(); err != nil {
+		return errors.Wrap(err, "compression close error")
+	}
+
+	return nil
+}
+
+func (c *zstdDictCompressor) Decompress(output io.Writer, input io.Reader, withHeader bool) error {
+	return c.DecompressWithDictionaries(context.Background(), output, input, withHeader, nil)
+}
+
+func (c *zstdDictCompressor) DecompressWithDictionaries(ctx context.Context, output io.Writer, input io.Reader, withHeader bool, dp DictionaryProvider) error {
+	if withHeader {
+		if err := verifyCompressionHeader(input, c.header); err != nil {
+			return err
+		}
+	}
+
+	var idbuf [dictionaryIDSize]byte
+
+	if _, err := io.ReadFull(input, idbuf[:]); err != nil {
+		return errors.Wrap(err, "error reading dictionary ID")
+	}
+
+	dictID := binary.BigEndian.Uint32(idbuf[:])
+	if dictID == 0 {
+		return c.zstdCompressor.Decompress(output, input, false)
+	}
+
+	if dp == nil {
+		return errors.Wrapf(ErrDictionaryRequired, "dictionary %v", dictID)
+	}
+
+	d, err := dp.GetCompressionDictionary(ctx, dictID)
+	if err != nil {
+		return errors.Wrapf(err, "unable to get dictionary %v", dictID)
+	}
+
+	dec := d.decoders.Take()
+	defer d.decoders.Return(dec)
+
+	if err := dec.Reset(input); err != nil {
+		return errors.Wrap(err, "decompression reset error")
+	}
+
+	if err := iocopy.JustCopy(output, dec); err != nil {
+		return errors.Wrap(err, "decompression error")
+	}
+
+	return nil
+}
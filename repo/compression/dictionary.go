@@ -0,0 +1,123 @@
+package compression
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/dict"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/freepool"
+)
+
+// DefaultMaxDictionarySize is the default maximum size of a trained dictionary.
+const DefaultMaxDictionarySize = 112 << 10
+
+// ErrDictionaryRequired is returned when decompressing data that was compressed using a dictionary
+// without providing access to dictionaries.
+var ErrDictionaryRequired = errors.New("compression dictionary required")
+
+// Dictionary is a trained zstd compression dictionary identified by a repository-unique ID.
+type Dictionary struct {
+	ID   uint32
+	Data []byte
+
+	mu       sync.Mutex
+	encoders map[zstd.EncoderLevel]*sync.Pool
+	decoders *freepool.Pool[zstd.Decoder]
+}
+
+// DictionaryProvider provides access to compression dictionaries.
+type DictionaryProvider interface {
+	// CurrentCompressionDictionary returns the dictionary that should be used for compressing new data or nil if none.
+	CurrentCompressionDictionary(ctx context.Context) (*Dictionary, error)
+
+	// GetCompressionDictionary returns the dictionary with a given ID.
+	GetCompressionDictionary(ctx context.Context, id uint32) (*Dictionary, error)
+}
+
+// DictionaryCompressor is implemented by compressors that can use trained dictionaries.
+// When used through Compressor interface, such compressors don't use any dictionary.
+type DictionaryCompressor interface {
+	Compressor
+
+	CompressWithDictionary(output io.Writer, input io.Reader, d *Dictionary) error
+	DecompressWithDictionaries(ctx context.Context, output io.Writer, input io.Reader, withHeader bool, dp DictionaryProvider) error
+
+	// HeaderIDWithoutDictionary returns the header ID of the equivalent compressor which does not use dictionaries.
+	HeaderIDWithoutDictionary() HeaderID
+}
+
+// NewDictionary returns a dictionary with the provided ID and contents in zstd dictionary format.
+func NewDictionary(id uint32, data []byte) (*Dictionary, error) {
+	if id == 0 {
+		return nil, errors.New("invalid dictionary ID")
+	}
+
+	// make sure the dictionary is valid before using it.
+	dec, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1), zstd.WithDecoderDicts(data))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid dictionary")
+	}
+
+	dec.Close()
+
+	d := &Dictionary{
+		ID:       id,
+		Data:     data,
+		encoders: map[zstd.EncoderLevel]*sync.Pool{},
+	}
+
+	d.decoders = freepool.New(func() *zstd.Decoder {
+		r, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1), zstd.WithDecoderDicts(data))
+		mustSucceed(err)
+
+		return r
+	}, func(v *zstd.Decoder) {
+		mustSucceed(v.Reset(nil))
+	})
+
+	return d, nil
+}
+
+func (d *Dictionary) encoderPool(level zstd.EncoderLevel) *sync.Pool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	p := d.encoders[level]
+	if p == nil {
+		p = &sync.Pool{
+			New: func() any {
+				w, err := zstd.NewWriter(io.Discard, zstd.WithEncoderLevel(level), zstd.WithEncoderDict(d.Data))
+				mustSucceed(err)
+
+				return w
+			},
+		}
+
+		d.encoders[level] = p
+	}
+
+	return p
+}
+
+// TrainDictionary builds a dictionary with a given ID from the provided samples.
+func TrainDictionary(id uint32, samples [][]byte, maxSize int) (*Dictionary, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxDictionarySize
+	}
+
+	data, err := dict.BuildZstdDict(samples, dict.Options{
+		MaxDictSize: maxSize,
+		HashBytes:   6, //nolint:mnd
+		ZstdDictID:  id,
+		ZstdLevel:   zstd.SpeedDefault,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error training dictionary")
+	}
+
+	return NewDictionary(id, data)
+}
@@ -0,0 +1,90 @@
+package compression
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type testDictionaryProvider map[uint32]*Dictionary
+
+func (p testDictionaryProvider) CurrentCompressionDictionary(_ context.Context) (*Dictionary, error) {
+	//nolint:nilnil
+	return nil, nil
+}
+
+func (p testDictionaryProvider) GetCompressionDictionary(_ context.Context, id uint32) (*Dictionary, error) {
+	if d := p[id]; d != nil {
+		return d, nil
+	}
+
+	return nil, errors.Errorf("dictionary %v not found", id)
+}
+
+func dictionaryTestSample(i int) []byte {
+	var b bytes.Buffer
+
+	for j := range 10 {
+		fmt.Fprintf(&b, `{"name":"document-%v-%v.txt","type":"f","mode":"0644","mtime":"2023-07-%02dT12:34:56Z","size":%v}`, i, j, j%28+1, i*j)
+	}
+
+	return b.Bytes()
+}
+
+func TestDictionaryCompression(t *testing.T) {
+	ctx := context.Background()
+
+	var samples [][]byte
+	for i := range 300 {
+		samples = append(samples, dictionaryTestSample(i))
+	}
+
+	d, err := TrainDictionary(7, samples, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint32(7), d.ID)
+
+	dp := testDictionaryProvider{7: d}
+	input := dictionaryTestSample(12345)
+
+	for _, name := range []Name{"zstd-dict", "zstd-fastest-dict", "zstd-better-compression-dict"} {
+		t.Run(string(name), func(t *testing.T) {
+			dc, ok := ByName[name].(DictionaryCompressor)
+			require.True(t, ok)
+
+			var plain, withDict bytes.Buffer
+
+			require.NoError(t, dc.Compress(&plain, bytes.NewReader(input)))
+			require.NoError(t, dc.CompressWithDictionary(&withDict, bytes.NewReader(input), d))
+			require.Less(t, withDict.Len(), plain.Len())
+
+			// data compressed without a dictionary can be decompressed without a provider.
+			var out bytes.Buffer
+
+			require.NoError(t, DecompressByHeader(&out, bytes.NewReader(plain.Bytes())))
+			require.Equal(t, input, out.Bytes())
+
+			// data compressed with a dictionary requires a provider.
+			out.Reset()
+			require.ErrorIs(t, dc.Decompress(&out, bytes.NewReader(withDict.Bytes()), true), ErrDictionaryRequired)
+
+			out.Reset()
+			require.NoError(t, dc.DecompressWithDictionaries(ctx, &out, bytes.NewReader(withDict.Bytes()), true, dp))
+			require.Equal(t, input, out.Bytes())
+
+			out.Reset()
+			require.Error(t, dc.DecompressWithDictionaries(ctx, &out, bytes.NewReader(withDict.Bytes()), true, testDictionaryProvider{}))
+		})
+	}
+}
+
+func TestNewDictionaryInvalid(t *testing.T) {
+	_, err := NewDictionary(0, []byte{1, 2, 3})
+	require.Error(t, err)
+
+	_, err = NewDictionary(1, []byte{1, 2, 3})
+	require.Error(t, err)
+}
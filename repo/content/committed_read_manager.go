@@ -27,6 +27,7 @@
 	"github.com/kopia/kopia/repo/blob/sharded"
 	"github.com/kopia/kopia/repo/compression"
 	"github.com/kopia/kopia/repo/content/indexblob"
+	"github.com/kopia/kopia/repo/encryption"
 	"github.com/kopia/kopia/repo/format"
 	"github.com/kopia/kopia/repo/hashing"
 	"github.com/kopia/kopia/repo/logging"
@@ -100,6 +101,9 @@ type SharedManager struct {
 
 	format format.Provider
 
+	// trained compression dictionaries loaded on demand.
+	dictionaries compressionDictionaries
+
 	checkInvariantsOnUnlock bool
 	minPreambleLength       int
 	maxPreambleLength       int
@@ -203,7 +207,7 @@ func (sm *SharedManager) attemptReadPackFileLocalIndex(ctx context.Context, pack
 	}
 
 	return errors.Wrap(
-		sm.decryptAndVerify(encryptedLocalIndexBytes.Bytes(), postamble.localIndexIV, output),
+		sm.decryptAndVerify(sm.format.Encryptor(), encryptedLocalIndexBytes.Bytes(), postamble.localIndexIV, output),
 		"unable to decrypt local index")
 }
 
@@ -300,29 +304,29 @@ func (sm *SharedManager) indexBlobManager(ctx context.Context) (indexblob.Manage
 	return q, nil
 }
 
-func (sm *SharedManager) decryptContentAndVerify(payload gather.Bytes, bi Info, output *gather.WriteBuffer) error {
+func (sm *SharedManager) decryptContentAndVerify(ctx context.Context, payload gather.Bytes, bi Info, output *gather.WriteBuffer) error {
 	sm.Stats.readContent(payload.Length())
 
 	var hashBuf [hashing.MaxHashSize]byte
 
 	iv := getPackedContentIV(hashBuf[:0], bi.ContentID)
 
-	// reserved for future use
-	if k := bi.EncryptionKeyID; k != 0 {
-		return errors.Errorf("unsupported encryption key ID: %v", k)
+	enc, err := sm.format.EncryptorForKeyID(bi.EncryptionKeyID)
+	if err != nil {
+		return errors.Wrapf(err, "unable to decrypt %v", bi.ContentID)
 	}
 
 	h := bi.CompressionHeaderID
 	if h == 0 {
 		return errors.Wrapf(
-			sm.decryptAndVerify(payload, iv, output),
+			sm.decryptAndVerify(enc, payload, iv, output),
 			"invalid checksum at %v offset %v length %v/%v", bi.PackBlobID, bi.PackOffset, bi.PackedLength, payload.Length())
 	}
 
 	var tmp gather.WriteBuffer
 	defer tmp.Close()
 
-	if err := sm.decryptAndVerify(payload, iv, &tmp); err != nil {
+	if err := sm.decryptAndVerify(enc, payload, iv, &tmp); err != nil {
 		return errors.Wrapf(err, "invalid checksum at %v offset %v length %v/%v", bi.PackBlobID, bi.PackOffset, bi.PackedLength, payload.Length())
 	}
 
@@ -333,7 +337,7 @@ func (sm *SharedManager) decryptContentAndVerify(payload gather.Bytes, bi Info,
 
 	t0 := timetrack.StartTimer()
 
-	if err := c.Decompress(output, tmp.Bytes().Reader(), true); err != nil {
+	if err := sm.decompress(ctx, c, output, tmp.Bytes()); err != nil {
 		return errors.Wrap(err, "error decompressing")
 	}
 
@@ -342,10 +346,20 @@ func (sm *SharedManager) decryptContentAndVerify(payload gather.Bytes, bi Info,
 	return nil
 }
 
-func (sm *SharedManager) decryptAndVerify(encrypted gather.Bytes, iv []byte, output *gather.WriteBuffer) error {
+func (sm *SharedManager) decompress(ctx context.Context, c compression.Compressor, output *gather.WriteBuffer, compressed gather.Bytes) error {
+	if dc, ok := c.(compression.DictionaryCompressor); ok {
+		//nolint:wrapcheck
+		return dc.DecompressWithDictionaries(ctx, output, compressed.Reader(), true, sm)
+	}
+
+	//nolint:wrapcheck
+	return c.Decompress(output, compressed.Reader(), true)
+}
+
+func (sm *SharedManager) decryptAndVerify(enc encryption.Encryptor, encrypted gather.Bytes, iv []byte, output *gather.WriteBuffer) error {
 	t0 := timetrack.StartTimer()
 
-	if err := sm.format.Encryptor().Decrypt(encrypted, iv, output); err != nil {
+	if err := enc.Decrypt(encrypted, iv, output); err != nil {
 		sm.Stats.foundInvalidContent()
 		return errors.Wrap(err, "decrypt")
 	}
@@ -0,0 +1,337 @@
+package content
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/blobcrypto"
+	"github.com/kopia/kopia/internal/feature"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/compression"
+	"github.com/kopia/kopia/repo/format"
+)
+
+// BlobIDPrefixCompressionDictionary is the prefix for blob IDs storing trained compression dictionaries.
+const BlobIDPrefixCompressionDictionary blob.ID = "d"
+
+// ErrCompressionDictionaryNotFound is returned when a compression dictionary cannot be found.
+var ErrCompressionDictionaryNotFound = errors.New("compression dictionary not found")
+
+// ErrCompressionDictionariesNotEnabled is returned when writing contents using a dictionary compressor
+// to a repository that does not require the compression dictionaries feature.
+var ErrCompressionDictionariesNotEnabled = errors.Errorf("compression dictionaries are not enabled for this repository, use 'kopia repository set-parameters --enable-compression-dictionaries'")
+
+// errCompressionDictionaryIDCollision is returned when another client has written a dictionary with the same ID.
+var errCompressionDictionaryIDCollision = errors.New("compression dictionary ID collision")
+
+// CompressionDictionaryInfo describes a compression dictionary stored in the repository.
+type CompressionDictionaryInfo struct {
+	ID        uint32    `json:"id"`
+	BlobID    blob.ID   `json:"blobID"`
+	Length    int64     `json:"length"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// compressionDictionaries caches dictionaries loaded from the repository. Dictionaries are immutable
+// and never deleted, so once loaded they never need to be refreshed.
+type compressionDictionaries struct {
+	mu sync.Mutex
+
+	// +checklocks:mu
+	listed bool
+	// +checklocks:mu
+	infos map[uint32]CompressionDictionaryInfo
+	// +checklocks:mu
+	loaded map[uint32]*compression.Dictionary
+	// +checklocks:mu
+	currentID uint32
+	// +checklocks:mu
+	colliding map[uint32]bool
+}
+
+// requiredFeaturesProvider is implemented by format providers which know the features required by the repository.
+type requiredFeaturesProvider interface {
+	RequiredFeatures(ctx context.Context) ([]feature.Required, error)
+}
+
+// CompressionDictionariesEnabled returns true if the repository requires the compression dictionaries feature,
+// which allows dictionary compressors to be used, since older clients refuse to open such repositories.
+func (sm *SharedManager) CompressionDictionariesEnabled(ctx context.Context) (bool, error) {
+	rfp, ok := sm.format.(requiredFeaturesProvider)
+	if !ok {
+		// static formats used in tests don't track required features.
+		return true, nil
+	}
+
+	required, err := rfp.RequiredFeatures(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "unable to get required features")
+	}
+
+	return feature.IsRequired(required, format.FeatureCompressionDictionaries), nil
+}
+
+// checkCompressorAllowed ensures that dictionary compressors are only used when compression dictionaries are enabled.
+func (sm *SharedManager) checkCompressorAllowed(ctx context.Context, c compression.Compressor) error {
+	if _, ok := c.(compression.DictionaryCompressor); !ok {
+		return nil
+	}
+
+	enabled, err := sm.CompressionDictionariesEnabled(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !enabled {
+		return ErrCompressionDictionariesNotEnabled
+	}
+
+	return nil
+}
+
+func compressionDictionaryIDFromBlobID(b blob.ID) (uint32, bool) {
+	p := strings.LastIndex(string(b), "-")
+	if p < 0 {
+		return 0, false
+	}
+
+	v, err := strconv.ParseUint(string(b[p+1:]), 16, 32)
+	if err != nil || v == 0 {
+		return 0, false
+	}
+
+	return uint32(v), true
+}
+
+// newerCompressionDictionary determines whether dictionary a is newer than b.
+func newerCompressionDictionary(a, b CompressionDictionaryInfo) bool {
+	if !a.Timestamp.Equal(b.Timestamp) {
+		return a.Timestamp.After(b.Timestamp)
+	}
+
+	return a.BlobID > b.BlobID
+}
+
+// +checklocks:sm.dictionaries.mu
+func (sm *SharedManager) listCompressionDictionariesLocked(ctx context.Context) error {
+	infos := map[uint32]CompressionDictionaryInfo{}
+	colliding := map[uint32]bool{}
+
+	if err := sm.st.ListBlobs(ctx, BlobIDPrefixCompressionDictionary, func(bm blob.Metadata) error {
+		id, ok := compressionDictionaryIDFromBlobID(bm.BlobID)
+		if !ok {
+			return nil
+		}
+
+		info := CompressionDictionaryInfo{id, bm.BlobID, bm.Length, bm.Timestamp}
+
+		existing, ok := infos[id]
+		if ok && existing.BlobID != bm.BlobID {
+			colliding[id] = true
+
+			// the earliest dictionary with a given ID wins, writers of later ones detect the collision
+			// and never use them, even if they fail to delete them.
+			if newerCompressionDictionary(info, existing) {
+				return nil
+			}
+		}
+
+		infos[id] = info
+
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "error listing compression dictionaries")
+	}
+
+	for id := range colliding {
+		log(ctx).Debugf("multiple compression dictionaries with ID %v, using %v", id, infos[id].BlobID)
+	}
+
+	var current CompressionDictionaryInfo
+
+	for _, v := range infos {
+		if current.ID == 0 || newerCompressionDictionary(v, current) {
+			current = v
+		}
+	}
+
+	sm.dictionaries.infos = infos
+	sm.dictionaries.colliding = colliding
+	sm.dictionaries.currentID = current.ID
+	sm.dictionaries.listed = true
+
+	return nil
+}
+
+// ListCompressionDictionaries returns information about compression dictionaries stored in the repository from oldest to newest.
+func (sm *SharedManager) ListCompressionDictionaries(ctx context.Context) ([]CompressionDictionaryInfo, error) {
+	sm.dictionaries.mu.Lock()
+	defer sm.dictionaries.mu.Unlock()
+
+	if err := sm.listCompressionDictionariesLocked(ctx); err != nil {
+		return nil, err
+	}
+
+	var result []CompressionDictionaryInfo
+
+	for _, v := range sm.dictionaries.infos {
+		result = append(result, v)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return newerCompressionDictionary(result[j], result[i])
+	})
+
+	return result, nil
+}
+
+// CurrentCompressionDictionary returns the newest compression dictionary or nil if the repository does not have any.
+func (sm *SharedManager) CurrentCompressionDictionary(ctx context.Context) (*compression.Dictionary, error) {
+	sm.dictionaries.mu.Lock()
+	defer sm.dictionaries.mu.Unlock()
+
+	if !sm.dictionaries.listed {
+		if err := sm.listCompressionDictionariesLocked(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if sm.dictionaries.currentID == 0 {
+		//nolint:nilnil
+		return nil, nil
+	}
+
+	return sm.getCompressionDictionaryLocked(ctx, sm.dictionaries.currentID)
+}
+
+// GetCompressionDictionary returns the compression dictionary with a given ID, loading it from the repository if needed.
+func (sm *SharedManager) GetCompressionDictionary(ctx context.Context, id uint32) (*compression.Dictionary, error) {
+	sm.dictionaries.mu.Lock()
+	defer sm.dictionaries.mu.Unlock()
+
+	return sm.getCompressionDictionaryLocked(ctx, id)
+}
+
+// +checklocks:sm.dictionaries.mu
+func (sm *SharedManager) getCompressionDictionaryLocked(ctx context.Context, id uint32) (*compression.Dictionary, error) {
+	if d := sm.dictionaries.loaded[id]; d != nil {
+		return d, nil
+	}
+
+	if _, ok := sm.dictionaries.infos[id]; !ok {
+		// dictionary may have been written by another client since we last listed them.
+		if err := sm.listCompressionDictionariesLocked(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	info, ok := sm.dictionaries.infos[id]
+	if !ok {
+		return nil, errors.Wrapf(ErrCompressionDictionaryNotFound, "dictionary %v", id)
+	}
+
+	var payload, decrypted gather.WriteBuffer
+	defer payload.Close()
+	defer decrypted.Close()
+
+	if err := sm.st.GetBlob(ctx, info.BlobID, 0, -1, &payload); err != nil {
+		return nil, errors.Wrapf(err, "error reading compression dictionary %v", info.BlobID)
+	}
+
+	if err := blobcrypto.Decrypt(sm.format, payload.Bytes(), info.BlobID, &decrypted); err != nil {
+		return nil, errors.Wrapf(err, "error decrypting compression dictionary %v", info.BlobID)
+	}
+
+	d, err := compression.NewDictionary(id, decrypted.ToByteSlice())
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid compression dictionary %v", info.BlobID)
+	}
+
+	if sm.dictionaries.loaded == nil {
+		sm.dictionaries.loaded = map[uint32]*compression.Dictionary{}
+	}
+
+	sm.dictionaries.loaded[id] = d
+
+	return d, nil
+}
+
+// NextCompressionDictionaryID returns a random ID for the next trained dictionary, which is not used by
+// any existing dictionary. Random IDs make it unlikely for concurrent writers to pick the same ID,
+// WriteCompressionDictionary detects when they do.
+func (sm *SharedManager) NextCompressionDictionaryID(ctx context.Context) (uint32, error) {
+	sm.dictionaries.mu.Lock()
+	defer sm.dictionaries.mu.Unlock()
+
+	if err := sm.listCompressionDictionariesLocked(ctx); err != nil {
+		return 0, err
+	}
+
+	var b [4]byte
+
+	for {
+		if _, err := rand.Read(b[:]); err != nil {
+			return 0, errors.Wrap(err, "unable to generate dictionary ID")
+		}
+
+		id := binary.BigEndian.Uint32(b[:])
+		if _, exists := sm.dictionaries.infos[id]; id != 0 && !exists {
+			return id, nil
+		}
+	}
+}
+
+// WriteCompressionDictionary encrypts and stores the provided dictionary in the repository and makes
+// it the current dictionary used for compression.
+func (bm *WriteManager) WriteCompressionDictionary(ctx context.Context, d *compression.Dictionary) (blob.ID, error) {
+	var encrypted gather.WriteBuffer
+	defer encrypted.Close()
+
+	blobID, err := blobcrypto.Encrypt(bm.format, gather.FromSlice(d.Data), BlobIDPrefixCompressionDictionary, blob.ID(fmt.Sprintf("%08x", d.ID)), &encrypted)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to encrypt compression dictionary")
+	}
+
+	if err := bm.st.PutBlob(ctx, blobID, encrypted.Bytes(), blob.PutOptions{}); err != nil {
+		return "", errors.Wrap(err, "unable to write compression dictionary")
+	}
+
+	bm.dictionaries.mu.Lock()
+	defer bm.dictionaries.mu.Unlock()
+
+	// the dictionary is not used until the listing confirms that no other client wrote a dictionary with the same ID.
+	// Clients which see a collision always back off, so at most the earliest dictionary, which is used
+	// by readers, may have been used for compression.
+	if err := bm.listCompressionDictionariesLocked(ctx); err != nil {
+		return "", err
+	}
+
+	if info, ok := bm.dictionaries.infos[d.ID]; !ok || info.BlobID != blobID || bm.dictionaries.colliding[d.ID] {
+		if err := bm.st.DeleteBlob(ctx, blobID); err != nil {
+			log(ctx).Errorf("unable to delete colliding compression dictionary %v: %v", blobID, err)
+		}
+
+		return "", errors.Wrapf(errCompressionDictionaryIDCollision, "dictionary %v", d.ID)
+	}
+
+	if bm.dictionaries.loaded == nil {
+		bm.dictionaries.loaded = map[uint32]*compression.Dictionary{}
+	}
+
+	bm.dictionaries.loaded[d.ID] = d
+	bm.dictionaries.currentID = d.ID
+
+	return blobID, nil
+}
+
+var _ compression.DictionaryProvider = (*SharedManager)(nil)
@@ -266,7 +266,7 @@ func (bm *WriteManager) addToPackUnlocked(ctx context.Context, contentID ID, dat
 	defer compressedAndEncrypted.Close()
 
 	// encrypt and compress before taking lock
-	actualComp, err := bm.maybeCompressAndEncryptDataForPacking(data, contentID, comp, &compressedAndEncrypted, mp)
+	actualComp, err := bm.maybeCompressAndEncryptDataForPacking(ctx, data, contentID, comp, &compressedAndEncrypted, mp)
 	if err != nil {
 		return errors.Wrapf(err, "unable to encrypt %q", contentID)
 	}
@@ -318,6 +318,7 @@ func (bm *WriteManager) addToPackUnlocked(ctx context.Context, contentID ID, dat
 		PackOffset:       uint32(pp.currentPackData.Length()), //nolint:gosec
 		TimestampSeconds: bm.contentWriteTime(previousWriteTime),
 		FormatVersion:    byte(mp.Version),
+		EncryptionKeyID:  bm.format.GetEncryptionKeyID(),
 		OriginalLength:   uint32(data.Length()), //nolint:gosec
 	}
 
@@ -27,7 +27,24 @@
 
 const indexBlobCompactionWarningThreshold = 1000
 
-func (sm *SharedManager) maybeCompressAndEncryptDataForPacking(data gather.Bytes, contentID ID, comp compression.HeaderID, output *gather.WriteBuffer, mp format.MutableParameters) (compression.HeaderID, error) {
+// compress compresses the provided data using the current compression dictionary if the compressor supports it.
+func (sm *SharedManager) compress(ctx context.Context, c compression.Compressor, output *gather.WriteBuffer, data gather.Bytes) error {
+	dc, ok := c.(compression.DictionaryCompressor)
+	if !ok {
+		//nolint:wrapcheck
+		return c.Compress(output, data.Reader())
+	}
+
+	d, err := sm.CurrentCompressionDictionary(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to get compression dictionary")
+	}
+
+	//nolint:wrapcheck
+	return dc.CompressWithDictionary(output, data.Reader(), d)
+}
+
+func (sm *SharedManager) maybeCompressAndEncryptDataForPacking(ctx context.Context, data gather.Bytes, contentID ID, comp compression.HeaderID, output *gather.WriteBuffer, mp format.MutableParameters) (compression.HeaderID, error) {
 	var hashOutput [hashing.MaxHashSize]byte
 
 	iv := getPackedContentIV(hashOutput[:0], contentID)
@@ -53,9 +70,13 @@ func (sm *SharedManager) maybeCompressAndEncryptDataForPacking(data gather.Bytes
 			return NoCompression, errors.Errorf("unsupported compressor %x", comp)
 		}
 
+		if err := sm.checkCompressorAllowed(ctx, c); err != nil {
+			return NoCompression, err
+		}
+
 		t0 := timetrack.StartTimer()
 
-		if err := c.Compress(&tmp, data.Reader()); err != nil {
+		if err := sm.compress(ctx, c, &tmp, data); err != nil {
 			return NoCompression, errors.Wrap(err, "compression error")
 		}
 
@@ -120,7 +141,7 @@ func (sm *SharedManager) getContentDataReadLocked(ctx context.Context, pp *pendi
 		return errors.Wrapf(err, "error getting cached content from blob %q", bi.PackBlobID)
 	}
 
-	return sm.decryptContentAndVerify(payload.Bytes(), bi, output)
+	return sm.decryptContentAndVerify(ctx, payload.Bytes(), bi, output)
 }
 
 func (sm *SharedManager) preparePackDataContent(ctx context.Context, mp format.MutableParameters, pp *pendingPackInfo) (index.Builder, error) {
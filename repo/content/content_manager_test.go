@@ -2110,6 +2110,132 @@ func (s *contentManagerSuite) TestCompression_CompressibleData(t *testing.T) {
 	verifyContent(ctx, t, bm2, cid, compressibleData)
 }
 
+func (s *contentManagerSuite) TestCompression_Dictionary(t *testing.T) {
+	data := blobtesting.DataMap{}
+	st := blobtesting.NewMapStorage(data, nil, nil)
+	bm := s.newTestContentManagerWithTweaks(t, st, &contentManagerTestTweaks{
+		indexVersion: index.Version2,
+	})
+
+	ctx := testlogging.Context(t)
+	headerID := compression.ByName["zstd-dict"].HeaderID()
+
+	sample := func(i int) []byte {
+		var b bytes.Buffer
+
+		for j := range 20 {
+			fmt.Fprintf(&b, `{"name":"file-%v-%v.txt","type":"f","mode":"0644","mtime":"2024-01-%02dT10:00:00Z","uid":1000,"gid":1000,"size":%v},`, i, j, j%28+1, i*j)
+		}
+
+		return b.Bytes()
+	}
+
+	// content written before any dictionary exists
+	beforeData := sample(1000)
+	beforeID, err := bm.WriteContent(ctx, gather.FromSlice(beforeData), "", headerID)
+	require.NoError(t, err)
+
+	var samples [][]byte
+	for i := range 200 {
+		samples = append(samples, sample(i))
+	}
+
+	id, err := bm.NextCompressionDictionaryID(ctx)
+	require.NoError(t, err)
+	require.NotZero(t, id)
+
+	d, err := compression.TrainDictionary(id, samples, 0)
+	require.NoError(t, err)
+
+	dictBlobID, err := bm.WriteCompressionDictionary(ctx, d)
+	require.NoError(t, err)
+	require.Equal(t, BlobIDPrefixCompressionDictionary, dictBlobID[0:1])
+
+	afterData := sample(1001)
+	afterID, err := bm.WriteContent(ctx, gather.FromSlice(afterData), "", headerID)
+	require.NoError(t, err)
+
+	before, err := bm.ContentInfo(ctx, beforeID)
+	require.NoError(t, err)
+
+	after, err := bm.ContentInfo(ctx, afterID)
+	require.NoError(t, err)
+	require.Equal(t, headerID, after.CompressionHeaderID)
+	require.Less(t, after.PackedLength, before.PackedLength)
+
+	verifyContent(ctx, t, bm, beforeID, beforeData)
+	verifyContent(ctx, t, bm, afterID, afterData)
+	require.NoError(t, bm.Flush(ctx))
+
+	// new manager loads the dictionary on demand
+	bm2 := s.newTestContentManagerWithTweaks(t, st, &contentManagerTestTweaks{
+		indexVersion: index.Version2,
+	})
+	verifyContent(ctx, t, bm2, beforeID, beforeData)
+	verifyContent(ctx, t, bm2, afterID, afterData)
+
+	dicts, err := bm2.ListCompressionDictionaries(ctx)
+	require.NoError(t, err)
+	require.Len(t, dicts, 1)
+	require.Equal(t, id, dicts[0].ID)
+	require.Equal(t, dictBlobID, dicts[0].BlobID)
+
+	cur, err := bm2.CurrentCompressionDictionary(ctx)
+	require.NoError(t, err)
+	require.Equal(t, d.Data, cur.Data)
+
+	_, err = bm2.GetCompressionDictionary(ctx, id+1)
+	require.ErrorIs(t, err, ErrCompressionDictionaryNotFound)
+
+	// dictionary with the same ID written by another client is detected and not used.
+	d2, err := compression.TrainDictionary(id, samples[100:], 0)
+	require.NoError(t, err)
+
+	_, err = bm2.WriteCompressionDictionary(ctx, d2)
+	require.ErrorIs(t, err, errCompressionDictionaryIDCollision)
+
+	dicts, err = bm2.ListCompressionDictionaries(ctx)
+	require.NoError(t, err)
+	require.Len(t, dicts, 1)
+	require.Equal(t, dictBlobID, dicts[0].BlobID)
+
+	// colliding dictionary which can't be deleted is ignored in favor of the earliest one.
+	faulty := blobtesting.NewFaultyStorage(st)
+	faulty.AddFault(blobtesting.MethodDeleteBlob).ErrorInstead(errors.New("delete failed"))
+
+	bm3 := s.newTestContentManagerWithTweaks(t, faulty, &contentManagerTestTweaks{
+		indexVersion: index.Version2,
+	})
+
+	_, err = bm3.WriteCompressionDictionary(ctx, d2)
+	require.ErrorIs(t, err, errCompressionDictionaryIDCollision)
+	faulty.VerifyAllFaultsExercised(t)
+
+	var dictBlobs []blob.Metadata
+
+	require.NoError(t, st.ListBlobs(ctx, BlobIDPrefixCompressionDictionary, func(bm blob.Metadata) error {
+		dictBlobs = append(dictBlobs, bm)
+		return nil
+	}))
+	require.Len(t, dictBlobs, 2)
+
+	bm4 := s.newTestContentManagerWithTweaks(t, st, &contentManagerTestTweaks{
+		indexVersion: index.Version2,
+	})
+
+	dicts, err = bm4.ListCompressionDictionaries(ctx)
+	require.NoError(t, err)
+	require.Len(t, dicts, 1)
+	require.Equal(t, dictBlobID, dicts[0].BlobID)
+
+	verifyContent(ctx, t, bm4, afterID, afterData)
+
+	// the client which wrote the later dictionary never uses it.
+	cur, err = bm3.CurrentCompressionDictionary(ctx)
+	require.NoError(t, err)
+	require.Equal(t, d.Data, cur.Data)
+}
+
 func (s *contentManagerSuite) TestCompression_NonCompressibleData(t *testing.T) {
 	data := blobtesting.DataMap{}
 	st := blobtesting.NewMapStorage(data, nil, nil)
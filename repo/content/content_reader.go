@@ -4,6 +4,7 @@
 	"context"
 
 	"github.com/kopia/kopia/internal/epoch"
+	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/format"
 )
 
@@ -21,4 +22,5 @@ type Reader interface {
 	ListActiveSessions(ctx context.Context) (map[SessionID]*SessionInfo, error)
 	EpochManager(ctx context.Context) (*epoch.Manager, bool, error)
 	VerifyContents(ctx context.Context, o VerifyOptions) error
+	ReadPackContents(ctx context.Context, packBlobID blob.ID, contents []Info, maxGap int64, cb PackContentsCallback) error
 }
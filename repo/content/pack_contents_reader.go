@@ -0,0 +1,82 @@
+package content
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// DefaultPackReadMaxGap is the default maximum number of unused bytes between contents
+// of a pack blob which will be read together using a single storage request.
+const DefaultPackReadMaxGap = 1 << 20
+
+// PackContentsCallback receives the contents of a single content read by ReadPackContents.
+// The data is only valid for the duration of the callback.
+type PackContentsCallback func(ci Info, data gather.Bytes) error
+
+// ReadPackContents reads the provided contents stored in a single pack blob directly from the storage,
+// bypassing caches. Contents are read in the order of their offsets and neighboring contents separated
+// by no more than maxGap bytes are fetched using a single range request.
+func (sm *SharedManager) ReadPackContents(ctx context.Context, packBlobID blob.ID, contents []Info, maxGap int64, cb PackContentsCallback) error {
+	sorted := slices.Clone(contents)
+
+	for _, ci := range sorted {
+		if ci.PackBlobID != packBlobID {
+			return errors.Errorf("content %v is not stored in pack %v", ci.ContentID, packBlobID)
+		}
+	}
+
+	slices.SortFunc(sorted, func(a, b Info) int {
+		return cmp.Compare(a.PackOffset, b.PackOffset)
+	})
+
+	var (
+		data gather.WriteBuffer
+		tmp  gather.WriteBuffer
+	)
+
+	defer data.Close()
+	defer tmp.Close()
+
+	for len(sorted) > 0 {
+		start := int64(sorted[0].PackOffset)
+		end := start + int64(sorted[0].PackedLength)
+		n := 1
+
+		for n < len(sorted) && int64(sorted[n].PackOffset)-end <= maxGap {
+			end = max(end, int64(sorted[n].PackOffset)+int64(sorted[n].PackedLength))
+			n++
+		}
+
+		data.Reset()
+
+		if err := sm.st.GetBlob(ctx, packBlobID, start, end-start, &data); err != nil {
+			return errors.Wrapf(err, "error reading pack %v range %v-%v", packBlobID, start, end)
+		}
+
+		rangeData := data.ToByteSlice()
+
+		for _, ci := range sorted[:n] {
+			off := int64(ci.PackOffset) - start
+
+			tmp.Reset()
+
+			if err := sm.decryptContentAndVerify(ctx, gather.FromSlice(rangeData[off:off+int64(ci.PackedLength)]), ci, &tmp); err != nil {
+				return errors.Wrapf(err, "error decrypting content %v", ci.ContentID)
+			}
+
+			if err := cb(ci, tmp.Bytes()); err != nil {
+				return err
+			}
+		}
+
+		sorted = sorted[n:]
+	}
+
+	return nil
+}
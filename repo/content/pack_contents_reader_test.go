@@ -0,0 +1,108 @@
+package content
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+type getBlobCountingStorage struct {
+	blob.Storage
+
+	getBlobCount atomic.Int32
+}
+
+func (s *getBlobCountingStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64, output blob.OutputBuffer) error {
+	s.getBlobCount.Add(1)
+
+	//nolint:wrapcheck
+	return s.Storage.GetBlob(ctx, id, offset, length, output)
+}
+
+func TestReadPackContents(t *testing.T) {
+	st := &getBlobCountingStorage{Storage: newTestingMapStorage()}
+	bm := newTestWriteManager(t, st)
+	ctx := testlogging.Context(t)
+
+	payloads := map[ID][]byte{}
+
+	var infos []Info
+
+	for i := range 5 {
+		data := seededRandomData(i, 1000)
+
+		cid, err := bm.WriteContent(ctx, gather.FromSlice(data), "", NoCompression)
+		require.NoError(t, err)
+
+		payloads[cid] = data
+	}
+
+	require.NoError(t, bm.Flush(ctx))
+
+	for cid := range payloads {
+		ci, err := bm.ContentInfo(ctx, cid)
+		require.NoError(t, err)
+
+		infos = append(infos, ci)
+	}
+
+	packID := infos[0].PackBlobID
+
+	sorted := sortedInfos(infos)
+
+	cases := []struct {
+		contents         []Info
+		maxGap           int64
+		wantGetBlobCount int32
+	}{
+		{infos, DefaultPackReadMaxGap, 1},
+		{infos, -1, 5},
+		// skipping every other content leaves gaps larger than a content.
+		{[]Info{sorted[0], sorted[2], sorted[4]}, 100, 3},
+		{[]Info{sorted[0], sorted[2], sorted[4]}, 2000, 1},
+	}
+
+	for _, tc := range cases {
+		st.getBlobCount.Store(0)
+
+		var (
+			got        = map[ID][]byte{}
+			lastOffset uint32
+		)
+
+		require.NoError(t, bm.ReadPackContents(ctx, packID, tc.contents, tc.maxGap, func(ci Info, data gather.Bytes) error {
+			require.GreaterOrEqual(t, ci.PackOffset, lastOffset, "contents not delivered in pack order")
+			lastOffset = ci.PackOffset
+
+			got[ci.ContentID] = data.ToByteSlice()
+
+			return nil
+		}))
+
+		require.Len(t, got, len(tc.contents))
+
+		for cid, data := range got {
+			require.Equal(t, payloads[cid], data)
+		}
+
+		require.Equal(t, tc.wantGetBlobCount, st.getBlobCount.Load())
+	}
+
+	require.Error(t, bm.ReadPackContents(ctx, "pnosuchpack", infos, DefaultPackReadMaxGap, func(Info, gather.Bytes) error {
+		return nil
+	}))
+}
+
+func sortedInfos(infos []Info) []Info {
+	return slices.SortedFunc(slices.Values(infos), func(a, b Info) int {
+		return cmp.Compare(a.PackOffset, b.PackOffset)
+	})
+}
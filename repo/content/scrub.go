@@ -0,0 +1,77 @@
+package content
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/ecc"
+)
+
+// PackScrubResult describes the results of scrubbing a single pack blob.
+type PackScrubResult struct {
+	PackLength int64
+
+	// contents that could be read only after correcting corrupted shards using error correction.
+	CorrectedContents []Info
+
+	// contents that could not be read at all.
+	UnreadableContents []Info
+
+	CorruptedShardCount int
+}
+
+// ScrubPack reads the provided pack blob directly from the storage bypassing caches and verifies that
+// all provided contents stored in it can be read, detecting contents that required error correction.
+func (sm *SharedManager) ScrubPack(ctx context.Context, packBlobID blob.ID, contents []Info) (PackScrubResult, error) {
+	var (
+		result PackScrubResult
+		data   gather.WriteBuffer
+		tmp    gather.WriteBuffer
+	)
+
+	defer data.Close()
+	defer tmp.Close()
+
+	if err := sm.st.GetBlob(ctx, packBlobID, 0, -1, &data); err != nil {
+		return result, errors.Wrapf(err, "error reading pack %v", packBlobID)
+	}
+
+	packData := data.ToByteSlice()
+	result.PackLength = int64(len(packData))
+
+	si, _ := sm.format.Encryptor().(ecc.ShardInspector)
+
+	for _, ci := range contents {
+		if ci.PackBlobID != packBlobID {
+			return result, errors.Errorf("content %v is not stored in pack %v", ci.ContentID, packBlobID)
+		}
+
+		if int64(ci.PackOffset)+int64(ci.PackedLength) > result.PackLength {
+			result.UnreadableContents = append(result.UnreadableContents, ci)
+			continue
+		}
+
+		payload := gather.FromSlice(packData[ci.PackOffset : ci.PackOffset+ci.PackedLength])
+
+		tmp.Reset()
+
+		if err := sm.decryptContentAndVerify(ctx, payload, ci, &tmp); err != nil {
+			result.UnreadableContents = append(result.UnreadableContents, ci)
+			continue
+		}
+
+		if si == nil {
+			continue
+		}
+
+		if n := si.CountCorruptedShards(payload); n > 0 {
+			result.CorruptedShardCount += n
+			result.CorrectedContents = append(result.CorrectedContents, ci)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,119 @@
+package content
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/epoch"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content/index"
+	"github.com/kopia/kopia/repo/ecc"
+	"github.com/kopia/kopia/repo/format"
+)
+
+func newTestScrubManager(t *testing.T, st blob.Storage) *WriteManager {
+	t.Helper()
+
+	fp := mustCreateFormatProvider(t, &format.ContentFormat{
+		Hash:               "HMAC-SHA256-128",
+		Encryption:         "AES256-GCM-HMAC-SHA256",
+		HMACSecret:         []byte("test-hmac"),
+		MasterKey:          []byte("0123456789abcdef0123456789abcdef"),
+		ECC:                ecc.DefaultAlgorithm,
+		ECCOverheadPercent: 10,
+		MutableParameters: format.MutableParameters{
+			Version:         2,
+			EpochParameters: epoch.DefaultParameters(),
+			IndexVersion:    index.Version2,
+			MaxPackSize:     1024 * 1024, // 1 MB
+		},
+	})
+
+	bm, err := NewManagerForTesting(testlogging.Context(t), st, fp, nil, nil)
+	require.NoError(t, err, "cannot create content write manager")
+
+	t.Cleanup(func() {
+		bm.CloseShared(testlogging.Context(t))
+	})
+
+	return bm
+}
+
+func TestScrubPack(t *testing.T) {
+	data := blobtesting.DataMap{}
+	faulty := blobtesting.NewFaultyStorage(blobtesting.NewMapStorage(data, nil, nil))
+	bm := newTestScrubManager(t, faulty)
+	ctx := testlogging.Context(t)
+
+	var cids []ID
+
+	for i := range 3 {
+		cid, err := bm.WriteContent(ctx, gather.FromSlice(seededRandomData(i, 20000)), "", NoCompression)
+		require.NoError(t, err)
+
+		cids = append(cids, cid)
+	}
+
+	require.NoError(t, bm.Flush(ctx))
+
+	var infos []Info
+
+	for _, cid := range cids {
+		ci, err := bm.ContentInfo(ctx, cid)
+		require.NoError(t, err)
+
+		infos = append(infos, ci)
+	}
+
+	packID := infos[0].PackBlobID
+
+	res, err := bm.ScrubPack(ctx, packID, infos)
+	require.NoError(t, err)
+	require.Empty(t, res.CorrectedContents)
+	require.Empty(t, res.UnreadableContents)
+	require.Zero(t, res.CorruptedShardCount)
+	require.Equal(t, int64(len(data[packID])), res.PackLength)
+
+	// flip a byte in the first content (correctable) and wipe the second one (not correctable).
+	data[packID][infos[0].PackOffset+100] ^= 0xff
+	clear(data[packID][infos[1].PackOffset : infos[1].PackOffset+infos[1].PackedLength])
+
+	res, err = bm.ScrubPack(ctx, packID, infos)
+	require.NoError(t, err)
+	require.Equal(t, []Info{infos[0]}, res.CorrectedContents)
+	require.Equal(t, []Info{infos[1]}, res.UnreadableContents)
+	require.Positive(t, res.CorruptedShardCount)
+
+	// storage errors are propagated to the caller.
+	someErr := errors.New("some error")
+
+	faulty.AddFault(blobtesting.MethodGetBlob).ErrorInstead(someErr)
+
+	_, err = bm.ScrubPack(ctx, packID, infos)
+	require.ErrorIs(t, err, someErr)
+
+	faulty.VerifyAllFaultsExercised(t)
+
+	// damage the third content right before the pack is read.
+	faulty.AddFault(blobtesting.MethodGetBlob).Before(func() {
+		data[packID][infos[2].PackOffset+100] ^= 0xff
+	})
+
+	res, err = bm.ScrubPack(ctx, packID, infos)
+	require.NoError(t, err)
+	require.Equal(t, []Info{infos[0], infos[2]}, res.CorrectedContents)
+	require.Equal(t, []Info{infos[1]}, res.UnreadableContents)
+
+	faulty.VerifyAllFaultsExercised(t)
+
+	_, err = bm.ScrubPack(ctx, "pnosuchpack", nil)
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+
+	_, err = bm.ScrubPack(ctx, "pnosuchpack", infos)
+	require.Error(t, err)
+}
@@ -6,6 +6,7 @@
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/repo/encryption"
 )
 
@@ -45,6 +46,14 @@ func CreateAlgorithm(opts *Options) (encryption.Encryptor, error) {
 	return factory(opts)
 }
 
+// ShardInspector is implemented by encryptors with error correction that can detect corrupted shards
+// in stored data. Such data can still be decrypted as long as there are few enough corrupted shards,
+// but should be rewritten before the damage accumulates.
+type ShardInspector interface {
+	// CountCorruptedShards returns the number of shards in the provided stored data that fail validation.
+	CountCorruptedShards(cipherText gather.Bytes) int
+}
+
 // Parameters encapsulates all ECC parameters.
 type Parameters interface {
 	GetECCAlgorithm() string
@@ -253,70 +253,23 @@ func (r *ReedSolomonCrcECC) Encrypt(input gather.Bytes, _ []byte, output *gather
 // See Encrypt comments for a description of the layout.
 func (r *ReedSolomonCrcECC) Decrypt(input gather.Bytes, _ []byte, output *gather.WriteBuffer) error {
 	sizes := r.computeSizesFromStored(input.Length())
-	dataPlusCrcSizeInBlock := sizes.DataShards * (crcSize + sizes.ShardSize)
-	parityPlusCrcSizeInBlock := sizes.ParityShards * (crcSize + sizes.ShardSize)
 
 	// Allocate space for the input + padding
 	var inputBuffer gather.WriteBuffer
 	defer inputBuffer.Close()
 
-	inputBytes := inputBuffer.MakeContiguous((dataPlusCrcSizeInBlock + parityPlusCrcSizeInBlock) * sizes.Blocks)
-
-	copied := input.AppendToSlice(inputBytes[:0])
-
-	// WriteBuffer does not clear the data, so we must clear the padding
-	if len(copied) < len(inputBytes) {
-		fillWithZeros(inputBytes[len(copied):])
-	}
-
-	eccBytes := inputBytes[:parityPlusCrcSizeInBlock*sizes.Blocks]
-	dataBytes := inputBytes[parityPlusCrcSizeInBlock*sizes.Blocks:]
+	inputBytes := readPaddedInput(input, &sizes, &inputBuffer)
 
 	var maxShards [256][]byte
 
 	shards := maxShards[:sizes.DataShards+sizes.ParityShards]
 
-	dataPos := 0
-	eccPos := 0
-
 	var originalSize int
 
 	writeOriginalPos := 0
-	paddingStartPos := len(copied) - parityPlusCrcSizeInBlock*sizes.Blocks
 
 	for b := range sizes.Blocks {
-		for i := range sizes.DataShards {
-			initialDataPos := dataPos
-
-			crc := binary.BigEndian.Uint32(dataBytes[dataPos : dataPos+crcSize])
-			dataPos += crcSize
-
-			shards[i] = dataBytes[dataPos : dataPos+sizes.ShardSize]
-			dataPos += sizes.ShardSize
-
-			// We don't need to compute the crc inside the padding
-			if initialDataPos < paddingStartPos {
-				if crc != crc32.ChecksumIEEE(shards[i]) {
-					// The data was corrupted, so we need to reconstruct it
-					shards[i] = nil
-				}
-			}
-		}
-
-		for i := range sizes.ParityShards {
-			s := sizes.DataShards + i
-
-			crc := binary.BigEndian.Uint32(eccBytes[eccPos : eccPos+crcSize])
-			eccPos += crcSize
-
-			shards[s] = eccBytes[eccPos : eccPos+sizes.ShardSize]
-			eccPos += sizes.ShardSize
-
-			if crc != crc32.ChecksumIEEE(shards[s]) {
-				// The data was corrupted, so we need to reconstruct it
-				shards[s] = nil
-			}
-		}
+		loadBlockShards(inputBytes, input.Length(), &sizes, b, shards)
 
 		if r.DeleteFirstShardForTests {
 			shards[0] = nil
@@ -349,6 +302,82 @@ func (r *ReedSolomonCrcECC) Decrypt(input gather.Bytes, _ []byte, output *gather
 	return nil
 }
 
+// CountCorruptedShards implements ShardInspector.
+func (r *ReedSolomonCrcECC) CountCorruptedShards(input gather.Bytes) int {
+	sizes := r.computeSizesFromStored(input.Length())
+
+	var inputBuffer gather.WriteBuffer
+	defer inputBuffer.Close()
+
+	inputBytes := readPaddedInput(input, &sizes, &inputBuffer)
+
+	var maxShards [256][]byte
+
+	shards := maxShards[:sizes.DataShards+sizes.ParityShards]
+	corrupted := 0
+
+	for b := range sizes.Blocks {
+		corrupted += loadBlockShards(inputBytes, input.Length(), &sizes, b, shards)
+	}
+
+	return corrupted
+}
+
+// readPaddedInput copies stored data into a buffer large enough to hold all shards including padding.
+func readPaddedInput(input gather.Bytes, sizes *sizesInfo, buf *gather.WriteBuffer) []byte {
+	inputBytes := buf.MakeContiguous((sizes.DataShards + sizes.ParityShards) * (crcSize + sizes.ShardSize) * sizes.Blocks)
+
+	copied := input.AppendToSlice(inputBytes[:0])
+
+	// WriteBuffer does not clear the data, so we must clear the padding
+	if len(copied) < len(inputBytes) {
+		fillWithZeros(inputBytes[len(copied):])
+	}
+
+	return inputBytes
+}
+
+// loadBlockShards points shards at data and parity shards of a given block, setting the ones that fail CRC
+// validation to nil so that they get reconstructed. Returns the number of shards that failed validation.
+func loadBlockShards(inputBytes []byte, storedLength int, sizes *sizesInfo, block int, shards [][]byte) int {
+	shardPlusCrcSize := crcSize + sizes.ShardSize
+
+	eccBytes := inputBytes[:sizes.ParityShards*shardPlusCrcSize*sizes.Blocks]
+	dataBytes := inputBytes[len(eccBytes):]
+
+	// We don't need to compute the crc inside the padding
+	paddingStartPos := storedLength - len(eccBytes)
+	corrupted := 0
+
+	for i := range sizes.DataShards {
+		pos := (block*sizes.DataShards + i) * shardPlusCrcSize
+
+		crc := binary.BigEndian.Uint32(dataBytes[pos : pos+crcSize])
+		shards[i] = dataBytes[pos+crcSize : pos+shardPlusCrcSize]
+
+		if pos < paddingStartPos && crc != crc32.ChecksumIEEE(shards[i]) {
+			// The data was corrupted, so we need to reconstruct it
+			shards[i] = nil
+			corrupted++
+		}
+	}
+
+	for i := range sizes.ParityShards {
+		pos := (block*sizes.ParityShards + i) * shardPlusCrcSize
+
+		crc := binary.BigEndian.Uint32(eccBytes[pos : pos+crcSize])
+		shards[sizes.DataShards+i] = eccBytes[pos+crcSize : pos+shardPlusCrcSize]
+
+		if crc != crc32.ChecksumIEEE(shards[sizes.DataShards+i]) {
+			// The data was corrupted, so we need to reconstruct it
+			shards[sizes.DataShards+i] = nil
+			corrupted++
+		}
+	}
+
+	return corrupted
+}
+
 func readLength(shards [][]byte, sizes *sizesInfo) (originalSize, startShard, startByte int) {
 	var lengthBuffer [lengthSize]byte
 
@@ -416,3 +445,5 @@ func init() {
 		return newReedSolomonCrcECC(opts)
 	})
 }
+
+var _ ShardInspector = (*ReedSolomonCrcECC)(nil)
@@ -1,11 +1,13 @@
 package ecc
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 
 	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testutil"
 	"github.com/kopia/kopia/repo/encryption"
 )
 
@@ -49,8 +51,17 @@ func testPutAndGet(t *testing.T, opts *Options, originalSize,
 	result := output.ToByteSlice()
 	require.Len(t, result, originalSize+expectedEccSize)
 
+	unchanged := bytes.Clone(result)
+
 	makeChanges(impl, result)
 
+	corrupted := testutil.EnsureType[ShardInspector](t, impl).CountCorruptedShards(gather.FromSlice(result))
+	if bytes.Equal(unchanged, result) {
+		require.Zero(t, corrupted)
+	} else {
+		require.Positive(t, corrupted)
+	}
+
 	output = gather.NewWriteBuffer()
 
 	err = impl.Decrypt(gather.FromSlice(result), nil, output)
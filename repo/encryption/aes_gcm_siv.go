@@ -0,0 +1,311 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"math/bits"
+
+	"github.com/pkg/errors"
+)
+
+// AES-GCM-SIV as specified in RFC 8452.
+const (
+	gcmSIVNonceSize = 12
+	gcmSIVTagSize   = 16
+
+	// maximum plaintext length permitted by RFC 8452 (2^36 bytes).
+	gcmSIVMaxPlaintextSize = 1 << 36
+)
+
+var errGCMSIVOpen = errors.New("message authentication failed")
+
+type aesGCMSIV struct {
+	keyGenerator cipher.Block
+	keySize      int
+}
+
+// newAESGCMSIV returns cipher.AEAD implementing AES-GCM-SIV with the provided 16-byte or 32-byte key.
+func newAESGCMSIV(key []byte) (cipher.AEAD, error) {
+	if len(key) != 16 && len(key) != 32 { //nolint:mnd
+		return nil, errors.Errorf("invalid AES-GCM-SIV key size: %v", len(key))
+	}
+
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create AES cipher")
+	}
+
+	return &aesGCMSIV{b, len(key)}, nil
+}
+
+func (a *aesGCMSIV) NonceSize() int {
+	return gcmSIVNonceSize
+}
+
+func (a *aesGCMSIV) Overhead() int {
+	return gcmSIVTagSize
+}
+
+// deriveKeys derives per-nonce message authentication and encryption keys.
+func (a *aesGCMSIV) deriveKeys(nonce []byte) (authKey [16]byte, enc cipher.Block) {
+	var (
+		in, out [16]byte
+		encKey  [32]byte
+	)
+
+	copy(in[4:], nonce)
+
+	for i := range 2 + a.keySize/8 {
+		binary.LittleEndian.PutUint32(in[0:4], uint32(i)) //nolint:gosec
+		a.keyGenerator.Encrypt(out[:], in[:])
+
+		if i < 2 { //nolint:mnd
+			copy(authKey[i*8:], out[0:8])
+		} else {
+			copy(encKey[(i-2)*8:], out[0:8])
+		}
+	}
+
+	enc, err := aes.NewCipher(encKey[:a.keySize])
+	if err != nil {
+		// impossible, key size is validated in newAESGCMSIV
+		panic(err)
+	}
+
+	return authKey, enc
+}
+
+func (a *aesGCMSIV) computeTag(authKey [16]byte, enc cipher.Block, nonce, plaintext, additionalData []byte) [16]byte {
+	var (
+		p           polyval
+		lengthBlock [16]byte
+		tag         [16]byte
+	)
+
+	p.init(authKey)
+	p.update(additionalData)
+	p.update(plaintext)
+
+	binary.LittleEndian.PutUint64(lengthBlock[0:8], uint64(len(additionalData))*8) //nolint:mnd
+	binary.LittleEndian.PutUint64(lengthBlock[8:16], uint64(len(plaintext))*8)     //nolint:mnd
+	p.update(lengthBlock[:])
+
+	s := p.sum()
+
+	for i := range gcmSIVNonceSize {
+		s[i] ^= nonce[i]
+	}
+
+	s[15] &= 0x7f
+
+	enc.Encrypt(tag[:], s[:])
+
+	return tag
+}
+
+// ctr performs AES-CTR using 32-bit little-endian counter in the first 4 bytes of the block.
+func ctr(enc cipher.Block, tag [16]byte, dst, src []byte) {
+	var (
+		counter   = tag
+		keystream [16]byte
+	)
+
+	counter[15] |= 0x80
+
+	for len(src) > 0 {
+		enc.Encrypt(keystream[:], counter[:])
+		binary.LittleEndian.PutUint32(counter[0:4], binary.LittleEndian.Uint32(counter[0:4])+1)
+
+		n := subtle.XORBytes(dst, src, keystream[:])
+		dst, src = dst[n:], src[n:]
+	}
+}
+
+func (a *aesGCMSIV) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != gcmSIVNonceSize {
+		panic("aes-gcm-siv: incorrect nonce length")
+	}
+
+	if uint64(len(plaintext)) > gcmSIVMaxPlaintextSize {
+		panic("aes-gcm-siv: message too large")
+	}
+
+	authKey, enc := a.deriveKeys(nonce)
+	tag := a.computeTag(authKey, enc, nonce, plaintext, additionalData)
+
+	ret, out := sliceForAppend(dst, len(plaintext)+gcmSIVTagSize)
+
+	ctr(enc, tag, out, plaintext)
+	copy(out[len(plaintext):], tag[:])
+
+	return ret
+}
+
+func (a *aesGCMSIV) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != gcmSIVNonceSize {
+		panic("aes-gcm-siv: incorrect nonce length")
+	}
+
+	if len(ciphertext) < gcmSIVTagSize || uint64(len(ciphertext)) > gcmSIVMaxPlaintextSize+gcmSIVTagSize {
+		return nil, errGCMSIVOpen
+	}
+
+	var tag [16]byte
+
+	copy(tag[:], ciphertext[len(ciphertext)-gcmSIVTagSize:])
+	ciphertext = ciphertext[:len(ciphertext)-gcmSIVTagSize]
+
+	authKey, enc := a.deriveKeys(nonce)
+
+	ret, out := sliceForAppend(dst, len(ciphertext))
+
+	ctr(enc, tag, out, ciphertext)
+
+	expected := a.computeTag(authKey, enc, nonce, out, additionalData)
+	if subtle.ConstantTimeCompare(expected[:], tag[:]) != 1 {
+		clear(out)
+
+		return nil, errGCMSIVOpen
+	}
+
+	return ret, nil
+}
+
+// sliceForAppend extends the provided slice by n bytes returning the extended slice and the tail.
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+
+	tail = head[len(in):]
+
+	return head, tail
+}
+
+// polyval implements POLYVAL universal hash over GF(2^128) defined by
+// x^128 + x^127 + x^126 + x^121 + 1, see RFC 8452 section 3.
+type polyval struct {
+	h [2]uint64
+	s [2]uint64
+}
+
+func (p *polyval) init(key [16]byte) {
+	p.h = [2]uint64{binary.LittleEndian.Uint64(key[0:8]), binary.LittleEndian.Uint64(key[8:16])}
+	p.s = [2]uint64{}
+}
+
+// update processes the provided data zero-padded to a multiple of 16 bytes.
+func (p *polyval) update(data []byte) {
+	for len(data) >= 16 {
+		p.block(data[0:16])
+		data = data[16:]
+	}
+
+	if len(data) > 0 {
+		var last [16]byte
+
+		copy(last[:], data)
+		p.block(last[:])
+	}
+}
+
+func (p *polyval) block(b []byte) {
+	p.s[0] ^= binary.LittleEndian.Uint64(b[0:8])
+	p.s[1] ^= binary.LittleEndian.Uint64(b[8:16])
+	p.s = polyvalDot(p.s, p.h)
+}
+
+func (p *polyval) sum() [16]byte {
+	var out [16]byte
+
+	binary.LittleEndian.PutUint64(out[0:8], p.s[0])
+	binary.LittleEndian.PutUint64(out[8:16], p.s[1])
+
+	return out
+}
+
+// polyvalDot returns a*b*x^-128 in POLYVAL field using Karatsuba multiplication followed by Montgomery reduction.
+func polyvalDot(a, b [2]uint64) [2]uint64 {
+	d1, d0 := clmul(a[0], b[0])
+	e1, e0 := clmul(a[1], b[1])
+	f1, f0 := clmul(a[0]^a[1], b[0]^b[1])
+
+	f0 ^= d0 ^ e0
+	f1 ^= d1 ^ e1
+
+	// 256-bit product
+	v0, v1, v2, v3 := d0, d1^f0, e0^f1, e1
+
+	// cancel lower 128 bits by adding multiples of x^128 + x^127 + x^126 + x^121 + 1, 64 bits at a time.
+	v1 ^= v0<<63 ^ v0<<62 ^ v0<<57
+	v2 ^= v0 ^ v0>>1 ^ v0>>2 ^ v0>>7
+	v2 ^= v1<<63 ^ v1<<62 ^ v1<<57
+	v3 ^= v1 ^ v1>>1 ^ v1>>2 ^ v1>>7
+
+	return [2]uint64{v2, v3}
+}
+
+// clmul returns 128-bit carry-less product of x and y in constant time by using integer multiplication
+// of operands with holes between bits. With 5-bit spacing at most 13 terms contribute to each bit,
+// so carries never propagate into bits that are kept.
+func clmul(x, y uint64) (hi, lo uint64) {
+	const (
+		m0 = 0x1084210842108421
+		m1 = 0x2108421084210842
+		m2 = 0x4210842108421084
+		m3 = 0x8421084210842108
+		m4 = 0x0842108421084210
+	)
+
+	x0, x1, x2, x3, x4 := x&m0, x&m1, x&m2, x&m3, x&m4
+	y0, y1, y2, y3, y4 := y&m0, y&m1, y&m2, y&m3, y&m4
+
+	h00, l00 := bits.Mul64(x0, y0)
+	h01, l01 := bits.Mul64(x1, y4)
+	h02, l02 := bits.Mul64(x2, y3)
+	h03, l03 := bits.Mul64(x3, y2)
+	h04, l04 := bits.Mul64(x4, y1)
+
+	h10, l10 := bits.Mul64(x0, y1)
+	h11, l11 := bits.Mul64(x1, y0)
+	h12, l12 := bits.Mul64(x2, y4)
+	h13, l13 := bits.Mul64(x3, y3)
+	h14, l14 := bits.Mul64(x4, y2)
+
+	h20, l20 := bits.Mul64(x0, y2)
+	h21, l21 := bits.Mul64(x1, y1)
+	h22, l22 := bits.Mul64(x2, y0)
+	h23, l23 := bits.Mul64(x3, y4)
+	h24, l24 := bits.Mul64(x4, y3)
+
+	h30, l30 := bits.Mul64(x0, y3)
+	h31, l31 := bits.Mul64(x1, y2)
+	h32, l32 := bits.Mul64(x2, y1)
+	h33, l33 := bits.Mul64(x3, y0)
+	h34, l34 := bits.Mul64(x4, y4)
+
+	h40, l40 := bits.Mul64(x0, y4)
+	h41, l41 := bits.Mul64(x1, y3)
+	h42, l42 := bits.Mul64(x2, y2)
+	h43, l43 := bits.Mul64(x3, y1)
+	h44, l44 := bits.Mul64(x4, y0)
+
+	// bit 64+j of the product belongs to group (j+4)%5, so the high word uses masks shifted by one.
+	lo = (l00^l01^l02^l03^l04)&m0 |
+		(l10^l11^l12^l13^l14)&m1 |
+		(l20^l21^l22^l23^l24)&m2 |
+		(l30^l31^l32^l33^l34)&m3 |
+		(l40^l41^l42^l43^l44)&m4
+	hi = (h00^h01^h02^h03^h04)&m1 |
+		(h10^h11^h12^h13^h14)&m2 |
+		(h20^h21^h22^h23^h24)&m3 |
+		(h30^h31^h32^h33^h34)&m4 |
+		(h40^h41^h42^h43^h44)&m0
+
+	return hi, lo
+}
@@ -0,0 +1,164 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/hex"
+	mathrand "math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+type aeadTestVector struct {
+	key, nonce, plaintext, aad, result string
+}
+
+func verifyAEADTestVectors(t *testing.T, newAEAD func(key []byte) (cipher.AEAD, error), vectors []aeadTestVector) {
+	t.Helper()
+
+	for _, tc := range vectors {
+		a, err := newAEAD(mustDecodeHex(t, tc.key))
+		require.NoError(t, err)
+
+		nonce := mustDecodeHex(t, tc.nonce)
+		plaintext := mustDecodeHex(t, tc.plaintext)
+		aad := mustDecodeHex(t, tc.aad)
+		result := mustDecodeHex(t, tc.result)
+
+		require.Equal(t, tc.result, hex.EncodeToString(a.Seal(nil, nonce, plaintext, aad)))
+
+		got, err := a.Open(nil, nonce, result, aad)
+		require.NoError(t, err)
+		require.True(t, bytes.Equal(plaintext, got))
+
+		// in-place
+		buf := append([]byte{}, plaintext...)
+		require.Equal(t, result, a.Seal(buf[:0], nonce, buf, aad))
+
+		for i := range result {
+			corrupted := append([]byte{}, result...)
+			corrupted[i] ^= 1
+
+			_, err := a.Open(nil, nonce, corrupted, aad)
+			require.Error(t, err)
+		}
+	}
+}
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+
+	return b
+}
+
+func TestPOLYVAL(t *testing.T) {
+	// RFC 8452 Appendix A
+	var (
+		p   polyval
+		key [16]byte
+	)
+
+	copy(key[:], mustDecodeHex(t, "25629347589242761d31f826ba4b757b"))
+
+	p.init(key)
+	p.update(mustDecodeHex(t, "4f4f95668c83dfb6401762bb2d01a262d1a24ddd2721d006bbe45f20d3c9f362"))
+
+	sum := p.sum()
+	require.Equal(t, "f7a3b47b846119fae5b7866cf5e5b77e", hex.EncodeToString(sum[:]))
+}
+
+func TestAESGCMSIV(t *testing.T) {
+	// RFC 8452 Appendix C
+	verifyAEADTestVectors(t, newAESGCMSIV, []aeadTestVector{
+		{
+			key:    "01000000000000000000000000000000",
+			nonce:  "030000000000000000000000",
+			result: "dc20e2d83f25705bb49e439eca56de25",
+		},
+		{
+			key:       "01000000000000000000000000000000",
+			nonce:     "030000000000000000000000",
+			plaintext: "0100000000000000",
+			result:    "b5d839330ac7b786578782fff6013b815b287c22493a364c",
+		},
+		{
+			key:       "01000000000000000000000000000000",
+			nonce:     "030000000000000000000000",
+			plaintext: "010000000000000000000000",
+			result:    "7323ea61d05932260047d942a4978db357391a0bc4fdec8b0d106639",
+		},
+		{
+			key:    "0100000000000000000000000000000000000000000000000000000000000000",
+			nonce:  "030000000000000000000000",
+			result: "07f5f4169bbf55a8400cd47ea6fd400f",
+		},
+		{
+			key:       "0100000000000000000000000000000000000000000000000000000000000000",
+			nonce:     "030000000000000000000000",
+			plaintext: "0100000000000000",
+			result:    "c2ef328e5c71c83b843122130f7364b761e0b97427e3df28",
+		},
+		{
+			key:       "0100000000000000000000000000000000000000000000000000000000000000",
+			nonce:     "030000000000000000000000",
+			plaintext: "010000000000000000000000",
+			result:    "9aab2aeb3faa0a34aea8e2b18ca50da9ae6559e48fd10f6e5c9ca17e",
+		},
+	})
+
+	_, err := newAESGCMSIV(make([]byte, 24))
+	require.Error(t, err)
+}
+
+func TestXChaCha20Poly1305(t *testing.T) {
+	// draft-irtf-cfrg-xchacha-03 Appendix A.3.1
+	verifyAEADTestVectors(t, chacha20poly1305.NewX, []aeadTestVector{
+		{
+			key:       "808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9f",
+			nonce:     "404142434445464748494a4b4c4d4e4f5051525354555657",
+			aad:       "50515253c0c1c2c3c4c5c6c7",
+			plaintext: hex.EncodeToString([]byte("Ladies and Gentlemen of the class of '99: If I could offer you only one tip for the future, sunscreen would be it.")),
+			result:    "bd6d179d3e83d43b9576579493c0e939572a1700252bfaccbed2902c21396cbb731c7f1b0b4aa6440bf3a82f4eda7e39ae64c6708c54c216cb96b72e1213b4522f8c9ba40db5d945b11b69b982c1bb9e3f3fac2bc369488f76b2383565d3fff921f9664c97637da9768812f615c68b13b52ec0875924c1c7987947deafd8780acf49",
+		},
+	})
+}
+
+func TestPOLYVALDotMatchesBitSerial(t *testing.T) {
+	// straightforward bit-serial multiplication by x^-1 = x^127 + x^126 + x^125 + x^120
+	reference := func(a, b [2]uint64) [2]uint64 {
+		var r0, r1 uint64
+
+		for i := range 128 {
+			if (b[i/64]>>(i%64))&1 != 0 {
+				r0 ^= a[0]
+				r1 ^= a[1]
+			}
+
+			lowBit := r0 & 1
+			r0 = r0>>1 | r1<<63
+			r1 >>= 1
+
+			if lowBit != 0 {
+				r1 ^= 1<<63 | 1<<62 | 1<<61 | 1<<56
+			}
+		}
+
+		return [2]uint64{r0, r1}
+	}
+
+	rnd := mathrand.New(mathrand.NewSource(1))
+
+	for range 10000 {
+		a := [2]uint64{rnd.Uint64(), rnd.Uint64()}
+		b := [2]uint64{rnd.Uint64(), rnd.Uint64()}
+
+		require.Equal(t, reference(a, b), polyvalDot(a, b))
+	}
+
+	require.Equal(t, reference([2]uint64{^uint64(0), ^uint64(0)}, [2]uint64{^uint64(0), ^uint64(0)}), polyvalDot([2]uint64{^uint64(0), ^uint64(0)}, [2]uint64{^uint64(0), ^uint64(0)}))
+}
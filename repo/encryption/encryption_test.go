@@ -108,8 +108,10 @@ func TestCiphertextSamples(t *testing.T) {
 
 			// samples of base16-encoded ciphertexts of payload encrypted with masterKey & contentID
 			samples: map[string]string{
-				"AES256-GCM-HMAC-SHA256":        "e43ba07f85a6d70c5f1102ca06cf19c597e5f91e527b21f00fb76e8bec3fd1",
-				"CHACHA20-POLY1305-HMAC-SHA256": "118359f3d4d589d939efbbc3168ae4c77c51bcebce6845fe6ef5d11342faa6",
+				"AES256-GCM-HMAC-SHA256":         "e43ba07f85a6d70c5f1102ca06cf19c597e5f91e527b21f00fb76e8bec3fd1",
+				"AES256-GCM-SIV-HMAC-SHA256":     "68ab2cacef241af7a6f5781b118a53ac24881d73743e28e3e902bdbea2c8dc",
+				"CHACHA20-POLY1305-HMAC-SHA256":  "118359f3d4d589d939efbbc3168ae4c77c51bcebce6845fe6ef5d11342faa6",
+				"XCHACHA20-POLY1305-HMAC-SHA256": "f567ef45d008c5f8809fa62111d1b7e10a0f1df09bfd6ba74b3e75e700254ae311d3982f7c42fee4302e7c",
 			},
 		},
 		{
@@ -119,8 +121,10 @@ func TestCiphertextSamples(t *testing.T) {
 
 			// samples of base16-encoded ciphertexts of payload encrypted with masterKey & contentID
 			samples: map[string]string{
-				"AES256-GCM-HMAC-SHA256":        "eaad755a238f1daa4052db2e5ccddd934790b6cca415b3ccfd46ac5746af33d9d30f4400ffa9eb3a64fb1ce21b888c12c043bf6787d4a5c15ad10f21f6a6027ee3afe0",
-				"CHACHA20-POLY1305-HMAC-SHA256": "836d2ba87892711077adbdbe1452d3b2c590bbfdf6fd3387dc6810220a32ec19de862e1a4f865575e328424b5f178afac1b7eeff11494f719d119b7ebb924d1d0846a3",
+				"AES256-GCM-HMAC-SHA256":         "eaad755a238f1daa4052db2e5ccddd934790b6cca415b3ccfd46ac5746af33d9d30f4400ffa9eb3a64fb1ce21b888c12c043bf6787d4a5c15ad10f21f6a6027ee3afe0",
+				"AES256-GCM-SIV-HMAC-SHA256":     "037f82cee87bd5818c5637c2057f39570287ee843c138fcd0389b0a3baa3d352dc7486ab36473f8f437a6681afed6b3f205120aeeae24c14ad66475f91b499d72ab881",
+				"CHACHA20-POLY1305-HMAC-SHA256":  "836d2ba87892711077adbdbe1452d3b2c590bbfdf6fd3387dc6810220a32ec19de862e1a4f865575e328424b5f178afac1b7eeff11494f719d119b7ebb924d1d0846a3",
+				"XCHACHA20-POLY1305-HMAC-SHA256": "0923c7205d6b0ee4a67a4d7044f11901871f567cdf345aa2880bf6b451c86ae5b57397b3fb235bddc5976f186c2aa1fd442c65ea35cd28be4d6d7cd39262bb2024b9969db530fc76aa6651da1a37f1",
 			},
 		},
 	}
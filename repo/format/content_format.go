@@ -18,6 +18,12 @@ type ContentFormat struct {
 	ECCOverheadPercent int    `json:"eccOverheadPercent,omitempty"`          // space overhead for ecc
 	HMACSecret         []byte `json:"secret,omitempty" kopia:"sensitive"`    // HMAC secret used to generate encryption keys
 	MasterKey          []byte `json:"masterKey,omitempty" kopia:"sensitive"` // master encryption key (SIV-mode encryption only)
+
+	// EncryptionKeyID is stored in index entries of new contents and is incremented each time the encryption
+	// algorithm changes. PreviousEncryption holds algorithms of lower key IDs, indexed by key ID.
+	EncryptionKeyID    byte     `json:"encryptionKeyID,omitempty"`
+	PreviousEncryption []string `json:"previousEncryption,omitempty"`
+
 	MutableParameters
 
 	EnablePasswordChange bool `json:"enablePasswordChange"` // disables replication of kopia.repository blob in packs
@@ -95,6 +101,11 @@ func (f *ContentFormat) GetEncryptionAlgorithm() string {
 	return f.Encryption
 }
 
+// GetEncryptionKeyID implements FormattingOptionsProvider.
+func (f *ContentFormat) GetEncryptionKeyID() byte {
+	return f.EncryptionKeyID
+}
+
 // GetMasterKey implements encryption.Parameters.
 func (f *ContentFormat) GetMasterKey() []byte {
 	return f.MasterKey
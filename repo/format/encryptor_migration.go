@@ -0,0 +1,51 @@
+package format
+
+import (
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo/ecc"
+	"github.com/kopia/kopia/repo/encryption"
+)
+
+// migratingEncryptor encrypts using the current encryption algorithm and decrypts using the current or
+// previous algorithms, which is needed for blobs that, unlike contents, don't record their encryption key ID.
+type migratingEncryptor struct {
+	current  encryption.Encryptor
+	previous []encryption.Encryptor // most recent first
+}
+
+func (p *migratingEncryptor) Encrypt(plainText gather.Bytes, contentID []byte, output *gather.WriteBuffer) error {
+	//nolint:wrapcheck
+	return p.current.Encrypt(plainText, contentID, output)
+}
+
+func (p *migratingEncryptor) Decrypt(cipherText gather.Bytes, contentID []byte, output *gather.WriteBuffer) error {
+	err := p.current.Decrypt(cipherText, contentID, output)
+	if err == nil {
+		return nil
+	}
+
+	// all encryption algorithms are authenticated, so failed attempts don't produce any output.
+	for _, e := range p.previous {
+		if e.Decrypt(cipherText, contentID, output) == nil {
+			return nil
+		}
+	}
+
+	//nolint:wrapcheck
+	return err
+}
+
+func (p *migratingEncryptor) Overhead() int {
+	return p.current.Overhead()
+}
+
+// CountCorruptedShards implements ecc.ShardInspector.
+func (p *migratingEncryptor) CountCorruptedShards(cipherText gather.Bytes) int {
+	if si, ok := p.current.(ecc.ShardInspector); ok {
+		return si.CountCorruptedShards(cipherText)
+	}
+
+	return 0
+}
+
+var _ ecc.ShardInspector = (*migratingEncryptor)(nil)
@@ -2,6 +2,7 @@
 
 import (
 	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo/ecc"
 	"github.com/kopia/kopia/repo/encryption"
 )
 
@@ -39,3 +40,14 @@ func (p *encryptorWrapper) Decrypt(cipherText gather.Bytes, contentID []byte, ou
 func (p *encryptorWrapper) Overhead() int {
 	panic("Should not be called")
 }
+
+// CountCorruptedShards implements ecc.ShardInspector.
+func (p *encryptorWrapper) CountCorruptedShards(cipherText gather.Bytes) int {
+	if si, ok := p.next.(ecc.ShardInspector); ok {
+		return si.CountCorruptedShards(cipherText)
+	}
+
+	return 0
+}
+
+var _ ecc.ShardInspector = (*encryptorWrapper)(nil)
@@ -0,0 +1,56 @@
+package format
+
+import (
+	"context"
+	"slices"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/feature"
+	"github.com/kopia/kopia/repo/content/index"
+)
+
+// maxEncryptionKeyID is the highest encryption key ID, 0xFF is reserved by index format v2.
+const maxEncryptionKeyID = 0xFE
+
+// ChangeEncryption changes the encryption algorithm of new contents and rewrites `kopia.repository`.
+// Existing contents remain readable using their encryption key IDs until they are rewritten.
+func (m *Manager) ChangeEncryption(ctx context.Context, algorithm string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cf := m.repoConfig.ContentFormat
+
+	if algorithm == cf.Encryption {
+		return errors.Errorf("repository is already encrypted using %v", algorithm)
+	}
+
+	if cf.IndexVersion < index.Version2 {
+		return errors.New("changing encryption requires index version 2")
+	}
+
+	if cf.EncryptionKeyID >= maxEncryptionKeyID {
+		return errors.New("too many encryption changes")
+	}
+
+	cf.PreviousEncryption = append(slices.Clone(cf.PreviousEncryption), cf.Encryption)
+	cf.Encryption = algorithm
+	cf.EncryptionKeyID++
+
+	if _, err := NewFormattingOptionsProvider(&cf, nil); err != nil {
+		return errors.Wrap(err, "invalid encryption")
+	}
+
+	m.repoConfig.ContentFormat = cf
+
+	if !feature.IsRequired(m.repoConfig.RequiredFeatures, FeatureEncryptionMigration) {
+		m.repoConfig.RequiredFeatures = append(slices.Clone(m.repoConfig.RequiredFeatures), feature.Required{
+			Feature: FeatureEncryptionMigration,
+			IfNotUnderstood: feature.IfNotUnderstood{
+				Message: "The repository contains contents encrypted using different encryption algorithms.",
+			},
+		})
+	}
+
+	return m.updateRepoConfigLocked(ctx)
+}
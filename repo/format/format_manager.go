@@ -236,6 +236,17 @@ func (m *Manager) Encryptor() encryption.Encryptor {
 	return m.immutable.Encryptor()
 }
 
+// GetEncryptionKeyID returns the encryption key ID of new contents.
+func (m *Manager) GetEncryptionKeyID() byte {
+	return m.immutable.GetEncryptionKeyID()
+}
+
+// EncryptorForKeyID returns the encryptor of contents with the provided encryption key ID.
+func (m *Manager) EncryptorForKeyID(keyID byte) (encryption.Encryptor, error) {
+	//nolint:wrapcheck
+	return m.immutable.EncryptorForKeyID(keyID)
+}
+
 // GetMasterKey gets the master key.
 func (m *Manager) GetMasterKey() []byte {
 	return m.immutable.GetMasterKey()
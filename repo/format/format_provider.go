@@ -56,6 +56,11 @@ type Provider interface {
 	HashFunc() hashing.HashFunc
 	Encryptor() encryption.Encryptor
 
+	// GetEncryptionKeyID returns the encryption key ID of new contents, EncryptorForKeyID returns
+	// the encryptor of contents with the provided encryption key ID.
+	GetEncryptionKeyID() byte
+	EncryptorForKeyID(keyID byte) (encryption.Encryptor, error)
+
 	// this is typically cached, but sometimes refreshes MutableParameters from
 	// the repository so the results should not be cached.
 	GetMutableParameters(ctx context.Context) (MutableParameters, error)
@@ -71,6 +76,7 @@ type formattingOptionsProvider struct {
 
 	h           hashing.HashFunc
 	e           encryption.Encryptor
+	keyEncs     []encryption.Encryptor // indexed by encryption key ID
 	formatBytes []byte
 }
 
@@ -108,21 +114,35 @@ func NewFormattingOptionsProvider(f0 *ContentFormat, formatBytes []byte) (Provid
 		return nil, errors.Wrap(err, "unable to create hash")
 	}
 
-	e, err := encryption.CreateEncryptor(f)
-	if err != nil {
-		return nil, errors.Wrap(err, "unable to create encryptor")
+	if len(f.PreviousEncryption) != int(f.EncryptionKeyID) {
+		return nil, errors.Errorf("invalid encryption key ID %v", f.EncryptionKeyID)
 	}
 
-	if f.GetECCAlgorithm() != "" && f.GetECCOverheadPercent() > 0 {
-		eccEncryptor, err := ecc.CreateEncryptor(f)
+	if f.EncryptionKeyID != 0 && f.IndexVersion < index.Version2 {
+		return nil, errors.New("encryption key IDs require index version 2")
+	}
+
+	var keyEncs []encryption.Encryptor
+
+	for _, alg := range append(append([]string(nil), f.PreviousEncryption...), f.Encryption) {
+		ke, err := newContentEncryptor(f, alg)
 		if err != nil {
-			return nil, errors.Wrap(err, "unable to create ECC")
+			return nil, err
 		}
 
-		e = &encryptorWrapper{
-			impl: e,
-			next: eccEncryptor,
+		keyEncs = append(keyEncs, ke)
+	}
+
+	e := keyEncs[f.EncryptionKeyID]
+
+	if f.EncryptionKeyID != 0 {
+		me := &migratingEncryptor{current: e}
+
+		for i := int(f.EncryptionKeyID) - 1; i >= 0; i-- {
+			me.previous = append(me.previous, keyEncs[i])
 		}
+
+		e = me
 	}
 
 	contentID := h(nil, gather.FromSlice(nil))
@@ -140,14 +160,49 @@ func NewFormattingOptionsProvider(f0 *ContentFormat, formatBytes []byte) (Provid
 
 		h:           h,
 		e:           e,
+		keyEncs:     keyEncs,
 		formatBytes: formatBytes,
 	}, nil
 }
 
+// newContentEncryptor creates the encryptor of contents using the provided encryption algorithm
+// and error correction configured in the format.
+func newContentEncryptor(f *ContentFormat, algorithm string) (encryption.Encryptor, error) {
+	p := *f
+	p.Encryption = algorithm
+
+	e, err := encryption.CreateEncryptor(&p)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create encryptor")
+	}
+
+	if f.GetECCAlgorithm() != "" && f.GetECCOverheadPercent() > 0 {
+		eccEncryptor, err := ecc.CreateEncryptor(f)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to create ECC")
+		}
+
+		e = &encryptorWrapper{
+			impl: e,
+			next: eccEncryptor,
+		}
+	}
+
+	return e, nil
+}
+
 func (f *formattingOptionsProvider) Encryptor() encryption.Encryptor {
 	return f.e
 }
 
+func (f *formattingOptionsProvider) EncryptorForKeyID(keyID byte) (encryption.Encryptor, error) {
+	if int(keyID) >= len(f.keyEncs) {
+		return nil, errors.Errorf("unsupported encryption key ID: %v", keyID)
+	}
+
+	return f.keyEncs[keyID], nil
+}
+
 func (f *formattingOptionsProvider) HashFunc() hashing.HashFunc {
 	return f.h
 }
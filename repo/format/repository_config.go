@@ -8,6 +8,14 @@
 	"github.com/kopia/kopia/internal/feature"
 )
 
+// FeatureCompressionDictionaries is required by repositories whose contents may be compressed using
+// dictionary compressors, which use compression header IDs not understood by older clients.
+const FeatureCompressionDictionaries feature.Feature = "compression-dictionaries"
+
+// FeatureEncryptionMigration is required by repositories whose encryption algorithm has been changed, which
+// store contents encrypted using different algorithms distinguished by their encryption key IDs.
+const FeatureEncryptionMigration feature.Feature = "encryption-migration"
+
 // RepositoryConfig describes the format of objects in a repository.
 // The contents of this object are stored encrypted since they contain sensitive key material.
 type RepositoryConfig struct {
@@ -0,0 +1,128 @@
+package maintenance
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/compression"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/maintenancestats"
+)
+
+const (
+	// only small contents benefit from dictionary compression, large ones build enough context on their own.
+	dictionarySampleMaxContentSize = 64 << 10
+	dictionaryMaxSamples           = 4096
+	dictionaryMaxSampleBytes       = 16 << 20
+	dictionaryMinSamples           = 64
+
+	// DefaultCompressionDictionaryRetrainInterval is the minimum time between training of compression dictionaries.
+	DefaultCompressionDictionaryRetrainInterval = 30 * 24 * time.Hour
+)
+
+// TrainCompressionDictionaryOptions provides options for training compression dictionaries.
+type TrainCompressionDictionaryOptions struct {
+	// RetrainInterval is the minimum age of the current dictionary before a new one is trained.
+	RetrainInterval time.Duration
+	MaxSize         int
+}
+
+// TrainCompressionDictionary trains a new compression dictionary from a sample of small contents that were
+// written using compressors supporting dictionaries and stores it in the repository. Nothing is trained when
+// the current dictionary is recent or there are not enough eligible contents.
+func TrainCompressionDictionary(ctx context.Context, rep repo.DirectRepositoryWriter, opt TrainCompressionDictionaryOptions) (*maintenancestats.TrainCompressionDictionaryStats, error) {
+	cm := rep.ContentManager()
+
+	if opt.RetrainInterval == 0 {
+		opt.RetrainInterval = DefaultCompressionDictionaryRetrainInterval
+	}
+
+	dicts, err := cm.ListCompressionDictionaries(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing compression dictionaries")
+	}
+
+	if n := len(dicts); n > 0 && rep.Time().Sub(dicts[n-1].Timestamp) < opt.RetrainInterval {
+		userLog(ctx).Debugf("compression dictionary %v is recent, not retraining", dicts[n-1].ID)
+		return &maintenancestats.TrainCompressionDictionaryStats{}, nil
+	}
+
+	stats := &maintenancestats.TrainCompressionDictionaryStats{}
+
+	// pick a uniform sample of eligible contents using reservoir sampling.
+	var sampleIDs []content.ID
+
+	rnd := rand.New(rand.NewSource(rep.Time().UnixNano())) //nolint:gosec
+
+	if err := cm.IterateContents(ctx, content.IterateOptions{}, func(ci content.Info) error {
+		if ci.OriginalLength > dictionarySampleMaxContentSize {
+			return nil
+		}
+
+		if _, ok := compression.ByHeaderID[ci.CompressionHeaderID].(compression.DictionaryCompressor); !ok {
+			return nil
+		}
+
+		stats.EligibleContentCount++
+
+		if len(sampleIDs) < dictionaryMaxSamples {
+			sampleIDs = append(sampleIDs, ci.ContentID)
+		} else if j := rnd.Intn(stats.EligibleContentCount); j < dictionaryMaxSamples {
+			sampleIDs[j] = ci.ContentID
+		}
+
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "error iterating contents")
+	}
+
+	if len(sampleIDs) < dictionaryMinSamples {
+		userLog(ctx).Debugf("not enough contents to train compression dictionary: %v", len(sampleIDs))
+		return stats, nil
+	}
+
+	var samples [][]byte
+
+	for _, cid := range sampleIDs {
+		if stats.SampleBytes >= dictionaryMaxSampleBytes {
+			break
+		}
+
+		data, err := cm.GetContent(ctx, cid)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading content %v", cid)
+		}
+
+		samples = append(samples, data)
+		stats.SampleCount++
+		stats.SampleBytes += int64(len(data))
+	}
+
+	id, err := cm.NextCompressionDictionaryID(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error determining dictionary ID")
+	}
+
+	d, err := compression.TrainDictionary(id, samples, opt.MaxSize)
+	if err != nil {
+		// training fails when samples don't have enough in common, this is not an error.
+		userLog(ctx).Infof("Unable to train compression dictionary: %v", err)
+		return stats, nil
+	}
+
+	blobID, err := cm.WriteCompressionDictionary(ctx, d)
+	if err != nil {
+		return nil, errors.Wrap(err, "error writing compression dictionary")
+	}
+
+	stats.DictionaryID = d.ID
+	stats.DictionarySize = len(d.Data)
+
+	userLog(ctx).Infof("Wrote compression dictionary %v (%v bytes) to %v.", d.ID, len(d.Data), blobID)
+
+	return stats, nil
+}
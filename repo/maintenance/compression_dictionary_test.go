@@ -0,0 +1,81 @@
+package maintenance_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/feature"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo/compression"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/format"
+	"github.com/kopia/kopia/repo/maintenance"
+)
+
+func TestTrainCompressionDictionary(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	cm := env.RepositoryWriter.ContentManager()
+
+	sampleContent := func(comp compression.Name, i int) []byte {
+		var b []byte
+
+		for j := range 10 {
+			b = fmt.Appendf(b, `{"name":"%v-file-%v-%v","type":"f","mode":"0644","mtime":"2024-02-%02dT08:00:00Z","size":%v}`, comp, i, j, j%28+1, i*j)
+		}
+
+		return b
+	}
+
+	write := func(n int, comp compression.Name) {
+		t.Helper()
+
+		for i := range n {
+			_, err := cm.WriteContent(ctx, gather.FromSlice(sampleContent(comp, i)), "k", compression.ByName[comp].HeaderID())
+			require.NoError(t, err)
+		}
+	}
+
+	// contents compressed without dictionary support are not eligible
+	write(500, "zstd")
+
+	stats, err := maintenance.TrainCompressionDictionary(ctx, env.RepositoryWriter, maintenance.TrainCompressionDictionaryOptions{})
+	require.NoError(t, err)
+	require.Equal(t, 0, stats.EligibleContentCount)
+	require.Equal(t, uint32(0), stats.DictionaryID)
+
+	// dictionary compressors can't be used until the repository requires compression dictionaries.
+	_, err = cm.WriteContent(ctx, gather.FromSlice(sampleContent("zstd-dict", 0)), "k", compression.ByName["zstd-dict"].HeaderID())
+	require.ErrorIs(t, err, content.ErrCompressionDictionariesNotEnabled)
+
+	fm := env.RepositoryWriter.FormatManager()
+
+	mp, err := fm.GetMutableParameters(ctx)
+	require.NoError(t, err)
+
+	blobcfg, err := fm.BlobCfgBlob(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, fm.SetParameters(ctx, mp, blobcfg, []feature.Required{{Feature: format.FeatureCompressionDictionaries}}))
+
+	write(500, "zstd-dict")
+
+	stats, err = maintenance.TrainCompressionDictionary(ctx, env.RepositoryWriter, maintenance.TrainCompressionDictionaryOptions{})
+	require.NoError(t, err)
+	require.Equal(t, 500, stats.EligibleContentCount)
+	require.Equal(t, 500, stats.SampleCount)
+	require.NotZero(t, stats.DictionaryID)
+	require.Positive(t, stats.DictionarySize)
+
+	dicts, err := cm.ListCompressionDictionaries(ctx)
+	require.NoError(t, err)
+	require.Len(t, dicts, 1)
+
+	// current dictionary is recent, nothing to do
+	stats, err = maintenance.TrainCompressionDictionary(ctx, env.RepositoryWriter, maintenance.TrainCompressionDictionaryOptions{})
+	require.NoError(t, err)
+	require.Equal(t, uint32(0), stats.DictionaryID)
+}
@@ -25,13 +25,14 @@
 
 // RewriteContentsOptions provides options for RewriteContents.
 type RewriteContentsOptions struct {
-	Parallel       int
-	ContentIDs     []content.ID
-	ContentIDRange content.IDRange
-	PackPrefix     blob.ID
-	ShortPacks     bool
-	FormatVersion  int
-	DryRun         bool
+	Parallel           int
+	ContentIDs         []content.ID
+	ContentIDRange     content.IDRange
+	PackPrefix         blob.ID
+	ShortPacks         bool
+	FormatVersion      int
+	PreviousEncryption bool // rewrite contents encrypted using previous encryption algorithms
+	DryRun             bool
 }
 
 const shortPackThresholdPercent = 60 // blocks below 60% of max block size are considered to be 'short
@@ -185,6 +186,11 @@ func getContentToRewrite(ctx context.Context, rep repo.DirectRepository, opt *Re
 		if opt.FormatVersion != 0 {
 			findContentWithFormatVersion(ctx, rep, ch, opt)
 		}
+
+		// add all contents not encrypted using the current encryption algorithm
+		if opt.PreviousEncryption {
+			findContentWithPreviousEncryption(ctx, rep, ch, opt)
+		}
 	}()
 
 	return ch
@@ -217,6 +223,24 @@ func(b content.Info) error {
 		})
 }
 
+func findContentWithPreviousEncryption(ctx context.Context, rep repo.DirectRepository, ch chan contentInfoOrError, opt *RewriteContentsOptions) {
+	current := rep.ContentReader().ContentFormat().GetEncryptionKeyID()
+
+	_ = rep.ContentReader().IterateContents(
+		ctx,
+		content.IterateOptions{
+			Range:          opt.ContentIDRange,
+			IncludeDeleted: true,
+		},
+		func(b content.Info) error {
+			if b.EncryptionKeyID != current && strings.HasPrefix(string(b.PackBlobID), string(opt.PackPrefix)) {
+				ch <- contentInfoOrError{Info: b}
+			}
+
+			return nil
+		})
+}
+
 func findContentInShortPacks(ctx context.Context, rep repo.DirectRepository, ch chan contentInfoOrError, threshold int64, opt *RewriteContentsOptions) {
 	var prefixes []blob.ID
 
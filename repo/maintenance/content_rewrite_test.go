@@ -8,9 +8,13 @@
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 
+	"github.com/kopia/kopia/internal/feature"
+	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/internal/repotesting"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/format"
 	"github.com/kopia/kopia/repo/maintenance"
 	"github.com/kopia/kopia/repo/maintenancestats"
 	"github.com/kopia/kopia/repo/object"
@@ -147,3 +151,68 @@ func (s *formatSpecificTestSuite) TestContentRewrite(t *testing.T) {
 		})
 	}
 }
+
+func TestContentRewritePreviousEncryption(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, format.FormatVersion3)
+
+	write := func(prefix content.IDPrefix, n int) map[content.ID][]byte {
+		t.Helper()
+
+		written := map[content.ID][]byte{}
+
+		for i := range n {
+			data := fmt.Appendf(nil, "%v-%v", uuid.NewString(), i)
+
+			cid, err := env.RepositoryWriter.ContentManager().WriteContent(ctx, gather.FromSlice(data), prefix, content.NoCompression)
+			require.NoError(t, err)
+
+			written[cid] = data
+		}
+
+		require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+		return written
+	}
+
+	verify := func(written map[content.ID][]byte, wantKeyID byte) {
+		t.Helper()
+
+		for cid, data := range written {
+			got, err := env.RepositoryWriter.ContentReader().GetContent(ctx, cid)
+			require.NoError(t, err)
+			require.Equal(t, data, got)
+
+			ci, err := env.RepositoryWriter.ContentInfo(ctx, cid)
+			require.NoError(t, err)
+			require.Equal(t, wantKeyID, ci.EncryptionKeyID)
+		}
+	}
+
+	before := write("", 5)
+	before2 := write("k", 5)
+
+	require.NoError(t, env.RepositoryWriter.FormatManager().ChangeEncryption(ctx, "XCHACHA20-POLY1305-HMAC-SHA256"))
+
+	required, err := env.RepositoryWriter.FormatManager().RequiredFeatures(ctx)
+	require.NoError(t, err)
+	require.True(t, feature.IsRequired(required, format.FeatureEncryptionMigration))
+
+	env.MustReopen(t)
+
+	// contents and indexes written before the change remain readable.
+	verify(before, 0)
+	verify(before2, 0)
+
+	after := write("", 5)
+	verify(after, 1)
+
+	stats, err := maintenance.RewriteContents(ctx, env.RepositoryWriter, &maintenance.RewriteContentsOptions{PreviousEncryption: true}, maintenance.SafetyNone)
+	require.NoError(t, err)
+	require.Equal(t, 10, stats.RewrittenContentCount)
+
+	env.MustReopen(t)
+
+	verify(before, 1)
+	verify(before2, 1)
+	verify(after, 1)
+}
@@ -26,6 +26,8 @@ type Params struct {
 
 	ExtendObjectLocks bool `json:"extendObjectLocks"`
 
+	ScrubContents bool `json:"scrubContents"`
+
 	ListParallelism int `json:"listParallelism"`
 }
 
@@ -51,6 +53,9 @@ func DefaultParams() Params {
 		// supported by S3 backend) and may cause data to be kept longer than
 		// desired if the retention period is relatively long.
 		ExtendObjectLocks: false,
+		// Scrubbing reads all pack blobs during each full maintenance, which can be slow and costly
+		// on remote storage, so it must be explicitly enabled.
+		ScrubContents: false,
 	}
 }
 
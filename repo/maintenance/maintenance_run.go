@@ -55,6 +55,8 @@
 	TaskEpochCleanupMarkers          = "cleanup-epoch-markers"
 	TaskEpochGenerateRange           = "generate-epoch-range-index"
 	TaskEpochCompactSingle           = "compact-single-epoch"
+	TaskTrainCompressionDictionary   = "train-compression-dictionary"
+	TaskScrubContents                = "scrub-contents"
 )
 
 // shouldRun returns Mode if repository is due for periodic maintenance.
@@ -345,6 +347,18 @@ func runTaskCleanupLogs(ctx context.Context, runParams RunParameters, s *Schedul
 	})
 }
 
+func runTaskTrainCompressionDictionary(ctx context.Context, runParams RunParameters, s *Schedule) error {
+	return ReportRun(ctx, runParams.rep, TaskTrainCompressionDictionary, s, func() (maintenancestats.Kind, error) {
+		return TrainCompressionDictionary(ctx, runParams.rep, TrainCompressionDictionaryOptions{})
+	})
+}
+
+func runTaskScrubContents(ctx context.Context, runParams RunParameters, s *Schedule) error {
+	return ReportRun(ctx, runParams.rep, TaskScrubContents, s, func() (maintenancestats.Kind, error) {
+		return ScrubContents(ctx, runParams.rep, ScrubContentsOptions{})
+	})
+}
+
 func runTaskEpochAdvance(ctx context.Context, em *epoch.Manager, runParams RunParameters, s *Schedule) error {
 	return reportRunAndMaybeCheckContentIndex(ctx, runParams.rep, TaskEpochAdvance, s, func() (maintenancestats.Kind, error) {
 		userLog(ctx).Info("Advancing epoch markers...")
@@ -506,6 +520,16 @@ func runFullMaintenance(ctx context.Context, runParams RunParameters, safety Saf
 		return errors.Wrap(err, "unable to get schedule")
 	}
 
+	// repair contents damaged by bit rot first, so that damaged packs get orphaned and deleted
+	// during the remainder of this and subsequent maintenance cycles.
+	if runParams.Params.ScrubContents {
+		if err := runTaskScrubContents(ctx, runParams, s); err != nil {
+			return errors.Wrap(err, "error scrubbing contents")
+		}
+	} else {
+		userLog(ctx).Debug("Scrubbing contents is disabled.")
+	}
+
 	if shouldFullRewriteContents(s, safety) {
 		// find packs that are less than 80% full and rewrite contents in them into
 		// new consolidated packs, orphaning old packs in the process.
@@ -544,6 +568,10 @@ func runFullMaintenance(ctx context.Context, runParams RunParameters, safety Saf
 		return errors.Wrap(err, "error cleaning up epoch manager")
 	}
 
+	if err := runTaskTrainCompressionDictionary(ctx, runParams, s); err != nil {
+		return errors.Wrap(err, "error training compression dictionary")
+	}
+
 	// clean up logs last
 	if err := runTaskCleanupLogs(ctx, runParams, s); err != nil {
 		return errors.Wrap(err, "error cleaning up logs")
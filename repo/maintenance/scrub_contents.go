@@ -0,0 +1,148 @@
+package maintenance
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/kopia/kopia/internal/blobparam"
+	"github.com/kopia/kopia/internal/contentlog"
+	"github.com/kopia/kopia/internal/contentlog/logparam"
+	"github.com/kopia/kopia/internal/contentparam"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/maintenancestats"
+)
+
+const defaultScrubParallelism = 8
+
+// ScrubContentsOptions provides options for ScrubContents.
+type ScrubContentsOptions struct {
+	Parallel       int
+	ContentIDRange content.IDRange
+	DryRun         bool
+}
+
+// ScrubContents reads all pack blobs directly from the storage and verifies all contents stored in them.
+// Contents that can only be read after correcting corrupted shards using error correction are rewritten
+// into new packs before the damage accumulates beyond what can be corrected, orphaning the damaged copies
+// which get removed by subsequent maintenance. Contents that cannot be read are reported but not considered
+// an error, the caller should examine UnreadableContentCount.
+//
+//nolint:funlen
+func ScrubContents(ctx context.Context, rep repo.DirectRepositoryWriter, opt ScrubContentsOptions) (*maintenancestats.ScrubContentsStats, error) {
+	ctx = contentlog.WithParams(ctx,
+		logparam.String("span:scrub-contents", contentlog.RandomSpanID()))
+
+	log := rep.LogManager().NewLogger("maintenance-scrub-contents")
+
+	if opt.Parallel == 0 {
+		opt.Parallel = defaultScrubParallelism
+	}
+
+	cm := rep.ContentManager()
+
+	contentsByPack := map[blob.ID][]content.Info{}
+
+	if err := cm.IterateContents(ctx, content.IterateOptions{Range: opt.ContentIDRange}, func(ci content.Info) error {
+		contentsByPack[ci.PackBlobID] = append(contentsByPack[ci.PackBlobID], ci)
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "error iterating contents")
+	}
+
+	contentlog.Log1(ctx, log, "Scrubbing packs...", logparam.Int("packCount", len(contentsByPack)))
+
+	var (
+		mu    sync.Mutex
+		stats maintenancestats.ScrubContentsStats
+	)
+
+	eg, egctx := errgroup.WithContext(ctx)
+
+	work := make(chan blob.ID)
+
+	for range opt.Parallel {
+		eg.Go(func() error {
+			for packID := range work {
+				contents := contentsByPack[packID]
+
+				res, err := cm.ScrubPack(egctx, packID, contents)
+				if errors.Is(err, blob.ErrBlobNotFound) {
+					// missing pack is reported by content verification, treat all contents as unreadable.
+					res.UnreadableContents = contents
+				} else if err != nil {
+					return errors.Wrapf(err, "error scrubbing pack %v", packID)
+				}
+
+				for _, ci := range res.UnreadableContents {
+					contentlog.Log2(ctx, log, "unreadable content",
+						contentparam.ContentID("contentID", ci.ContentID),
+						blobparam.BlobID("packBlobID", ci.PackBlobID))
+					userLog(ctx).Warnf("Content %v in pack %v is unreadable.", ci.ContentID, ci.PackBlobID)
+				}
+
+				repaired := 0
+
+				for _, ci := range res.CorrectedContents {
+					contentlog.Log2(ctx, log, "corrected content",
+						contentparam.ContentID("contentID", ci.ContentID),
+						blobparam.BlobID("packBlobID", ci.PackBlobID))
+
+					if opt.DryRun {
+						continue
+					}
+
+					if err := cm.RewriteContent(egctx, ci.ContentID); err != nil {
+						return errors.Wrapf(err, "error rewriting corrected content %v", ci.ContentID)
+					}
+
+					repaired++
+				}
+
+				mu.Lock()
+				stats.ScrubbedPackCount++
+				stats.ScrubbedPackSize += res.PackLength
+				stats.ScrubbedContentCount += len(contents)
+				stats.CorruptedShardCount += res.CorruptedShardCount
+				stats.CorrectedContentCount += len(res.CorrectedContents)
+				stats.RepairedContentCount += repaired
+				stats.UnreadableContentCount += len(res.UnreadableContents)
+				mu.Unlock()
+			}
+
+			return nil
+		})
+	}
+
+	eg.Go(func() error {
+		defer close(work)
+
+		for packID := range contentsByPack {
+			select {
+			case work <- packID:
+			case <-egctx.Done():
+				return errors.Wrap(egctx.Err(), "context canceled")
+			}
+		}
+
+		return nil
+	})
+
+	if err := eg.Wait(); err != nil {
+		return nil, errors.Wrap(err, "error scrubbing contents")
+	}
+
+	if stats.RepairedContentCount > 0 {
+		if err := rep.Flush(ctx); err != nil {
+			return nil, errors.Wrap(err, "error flushing repaired contents")
+		}
+	}
+
+	userLog(ctx).Infof("%v", stats.Summary())
+
+	return &stats, nil
+}
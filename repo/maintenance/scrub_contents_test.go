@@ -0,0 +1,212 @@
+package maintenance_test
+
+import (
+	"context"
+	"crypto/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/ecc"
+	"github.com/kopia/kopia/repo/format"
+	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/repo/maintenancestats"
+	"github.com/kopia/kopia/repo/object"
+)
+
+func TestScrubContents(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant, repotesting.Options{
+		NewRepositoryOptions: func(nro *repo.NewRepositoryOptions) {
+			nro.BlockFormat.ECC = ecc.DefaultAlgorithm
+			nro.BlockFormat.ECCOverheadPercent = 10
+		},
+	})
+
+	var payloads [3][]byte
+
+	var cids [3]content.ID
+
+	require.NoError(t, repo.WriteSession(ctx, env.Repository, repo.WriteSessionOptions{}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		for i := range payloads {
+			payloads[i] = make([]byte, 65536)
+			rand.Read(payloads[i])
+
+			ow := w.NewObjectWriter(ctx, object.WriterOptions{})
+
+			_, err := ow.Write(payloads[i])
+			require.NoError(t, err)
+
+			oid, err := ow.Result()
+			require.NoError(t, err)
+
+			cid, _, ok := oid.ContentID()
+			require.True(t, ok)
+
+			cids[i] = cid
+		}
+
+		return nil
+	}))
+
+	env.MustReopen(t)
+
+	var infos [3]content.Info
+
+	for i, cid := range cids {
+		ci, err := env.RepositoryWriter.ContentInfo(ctx, cid)
+		require.NoError(t, err)
+
+		infos[i] = ci
+	}
+
+	// flip a single byte in the first content, which is correctable
+	corruptPackBytes(ctx, t, env, infos[0], func(b []byte) {
+		b[len(b)/2] ^= 0xff
+	})
+
+	// wipe the second content entirely, which is not
+	corruptPackBytes(ctx, t, env, infos[1], func(b []byte) {
+		clear(b)
+	})
+
+	stats := runScrubContents(ctx, t, env, maintenance.ScrubContentsOptions{DryRun: true})
+	require.Equal(t, 1, stats.CorrectedContentCount)
+	require.Equal(t, 0, stats.RepairedContentCount)
+	require.Equal(t, 1, stats.UnreadableContentCount)
+	require.Positive(t, stats.CorruptedShardCount)
+	require.GreaterOrEqual(t, stats.ScrubbedContentCount, len(cids))
+
+	stats = runScrubContents(ctx, t, env, maintenance.ScrubContentsOptions{})
+	require.Equal(t, 1, stats.CorrectedContentCount)
+	require.Equal(t, 1, stats.RepairedContentCount)
+	require.Equal(t, 1, stats.UnreadableContentCount)
+
+	env.MustReopen(t)
+
+	ci, err := env.RepositoryWriter.ContentInfo(ctx, cids[0])
+	require.NoError(t, err)
+	require.NotEqual(t, infos[0].PackBlobID, ci.PackBlobID, "repaired content was not moved to a new pack")
+
+	data, err := env.RepositoryWriter.ContentReader().GetContent(ctx, cids[0])
+	require.NoError(t, err)
+	require.Equal(t, payloads[0], data)
+
+	ci, err = env.RepositoryWriter.ContentInfo(ctx, cids[2])
+	require.NoError(t, err)
+	require.Equal(t, infos[2].PackBlobID, ci.PackBlobID, "intact content was rewritten")
+
+	// once repaired, the content no longer requires error correction.
+	stats = runScrubContents(ctx, t, env, maintenance.ScrubContentsOptions{})
+	require.Equal(t, 0, stats.CorrectedContentCount)
+	require.Equal(t, 0, stats.RepairedContentCount)
+	require.Equal(t, 1, stats.UnreadableContentCount)
+}
+
+func TestScrubContents_FaultyStorage(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	faulty := blobtesting.NewFaultyStorage(blobtesting.NewMapStorage(data, nil, nil))
+	st := repotesting.NewReconnectableStorage(t, faulty)
+
+	require.NoError(t, repo.Initialize(ctx, st, &repo.NewRepositoryOptions{
+		BlockFormat: format.ContentFormat{
+			ECC:                ecc.DefaultAlgorithm,
+			ECCOverheadPercent: 10,
+		},
+	}, repotesting.DefaultPasswordForTesting))
+
+	configFile := filepath.Join(testutil.TempDirectory(t), "kopia.config")
+	require.NoError(t, repo.Connect(ctx, configFile, st, repotesting.DefaultPasswordForTesting, nil))
+
+	rep, err := repo.Open(ctx, configFile, repotesting.DefaultPasswordForTesting, nil)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { rep.Close(ctx) })
+
+	_, w, err := testutil.EnsureType[repo.DirectRepository](t, rep).NewDirectWriter(ctx, repo.WriteSessionOptions{Purpose: "test"})
+	require.NoError(t, err)
+
+	t.Cleanup(func() { w.Close(ctx) })
+
+	payload := make([]byte, 65536)
+	rand.Read(payload)
+
+	cid, err := w.ContentManager().WriteContent(ctx, gather.FromSlice(payload), "", content.NoCompression)
+	require.NoError(t, err)
+	require.NoError(t, w.Flush(ctx))
+
+	ci, err := w.ContentInfo(ctx, cid)
+	require.NoError(t, err)
+
+	scrub := func() (*maintenancestats.ScrubContentsStats, error) {
+		return maintenance.ScrubContents(ctx, w, maintenance.ScrubContentsOptions{DryRun: true})
+	}
+
+	// storage read errors are propagated to the caller.
+	someErr := errors.New("some read error")
+
+	faulty.AddFault(blobtesting.MethodGetBlob).ErrorInstead(someErr)
+
+	_, err = scrub()
+	require.ErrorIs(t, err, someErr)
+	faulty.VerifyAllFaultsExercised(t)
+
+	// damage the pack right before it is read, the content can still be corrected using ECC.
+	faulty.AddFault(blobtesting.MethodGetBlob).Before(func() {
+		data[ci.PackBlobID][ci.PackOffset+ci.PackedLength/2] ^= 0xff
+	})
+
+	stats, err := scrub()
+	require.NoError(t, err)
+	faulty.VerifyAllFaultsExercised(t)
+	require.Equal(t, 1, stats.ScrubbedPackCount)
+	require.Equal(t, 1, stats.CorrectedContentCount)
+	require.Zero(t, stats.UnreadableContentCount)
+	require.Positive(t, stats.CorruptedShardCount)
+
+	got, err := w.ContentReader().GetContent(ctx, cid)
+	require.NoError(t, err)
+	require.Equal(t, payload, got)
+}
+
+func runScrubContents(ctx context.Context, t *testing.T, env *repotesting.Environment, opt maintenance.ScrubContentsOptions) *maintenancestats.ScrubContentsStats {
+	t.Helper()
+
+	var stats *maintenancestats.ScrubContentsStats
+
+	require.NoError(t, repo.DirectWriteSession(ctx, env.RepositoryWriter, repo.WriteSessionOptions{}, func(ctx context.Context, w repo.DirectRepositoryWriter) error {
+		var err error
+
+		stats, err = maintenance.ScrubContents(ctx, w, opt)
+
+		return err
+	}))
+
+	return stats
+}
+
+func corruptPackBytes(ctx context.Context, t *testing.T, env *repotesting.Environment, ci content.Info, corrupt func(b []byte)) {
+	t.Helper()
+
+	var tmp gather.WriteBuffer
+	defer tmp.Close()
+
+	st := env.RootStorage()
+
+	require.NoError(t, st.GetBlob(ctx, ci.PackBlobID, 0, -1, &tmp))
+
+	b := tmp.ToByteSlice()
+	corrupt(b[ci.PackOffset : ci.PackOffset+ci.PackedLength])
+
+	require.NoError(t, st.PutBlob(ctx, ci.PackBlobID, gather.FromSlice(b), blob.PutOptions{}))
+}
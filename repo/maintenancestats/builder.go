@@ -70,6 +70,10 @@ func BuildFromExtra(stats Extra) (Summarizer, error) {
 		result = &RewriteContentsStats{}
 	case snapshotGCStatsKind:
 		result = &SnapshotGCStats{}
+	case trainCompressionDictionaryStatsKind:
+		result = &TrainCompressionDictionaryStats{}
+	case scrubContentsStatsKind:
+		result = &ScrubContentsStats{}
 	default:
 		return nil, errors.Wrapf(ErrUnSupportedStatKindError, "invalid kind for stats %v", stats)
 	}
@@ -160,6 +160,36 @@ func TestBuildExtraSuccess(t *testing.T) {
 					`"recoveredContentCount":40,"recoveredContentSize":4096}`),
 			},
 		},
+		{
+			name: "TrainCompressionDictionaryStats",
+			stats: &TrainCompressionDictionaryStats{
+				EligibleContentCount: 100,
+				SampleCount:          50,
+				SampleBytes:          4096,
+				DictionaryID:         2,
+				DictionarySize:       1024,
+			},
+			expected: Extra{
+				Kind: trainCompressionDictionaryStatsKind,
+				Data: []byte(`{"eligibleContentCount":100,"sampleCount":50,"sampleBytes":4096,"dictionaryID":2,"dictionarySize":1024}`),
+			},
+		},
+		{
+			name: "ScrubContentsStats",
+			stats: &ScrubContentsStats{
+				ScrubbedPackCount:      3,
+				ScrubbedPackSize:       4096,
+				ScrubbedContentCount:   30,
+				CorruptedShardCount:    5,
+				CorrectedContentCount:  2,
+				RepairedContentCount:   2,
+				UnreadableContentCount: 1,
+			},
+			expected: Extra{
+				Kind: scrubContentsStatsKind,
+				Data: []byte(`{"scrubbedPackCount":3,"scrubbedPackSize":4096,"scrubbedContentCount":30,"corruptedShardCount":5,"correctedContentCount":2,"repairedContentCount":2,"unreadableContentCount":1}`),
+			},
+		},
 	}
 
 	for _, tc := range cases {
@@ -355,6 +385,36 @@ func TestBuildFromExtraSuccess(t *testing.T) {
 				RecoveredContentSize:           4096,
 			},
 		},
+		{
+			name: "TrainCompressionDictionaryStats",
+			stats: Extra{
+				Kind: trainCompressionDictionaryStatsKind,
+				Data: []byte(`{"eligibleContentCount":100,"sampleCount":50,"sampleBytes":4096,"dictionaryID":2,"dictionarySize":1024}`),
+			},
+			expected: &TrainCompressionDictionaryStats{
+				EligibleContentCount: 100,
+				SampleCount:          50,
+				SampleBytes:          4096,
+				DictionaryID:         2,
+				DictionarySize:       1024,
+			},
+		},
+		{
+			name: "ScrubContentsStats",
+			stats: Extra{
+				Kind: scrubContentsStatsKind,
+				Data: []byte(`{"scrubbedPackCount":3,"scrubbedPackSize":4096,"scrubbedContentCount":30,"corruptedShardCount":5,"correctedContentCount":2,"repairedContentCount":2,"unreadableContentCount":1}`),
+			},
+			expected: &ScrubContentsStats{
+				ScrubbedPackCount:      3,
+				ScrubbedPackSize:       4096,
+				ScrubbedContentCount:   30,
+				CorruptedShardCount:    5,
+				CorrectedContentCount:  2,
+				RepairedContentCount:   2,
+				UnreadableContentCount: 1,
+			},
+		},
 	}
 
 	for _, tc := range cases {
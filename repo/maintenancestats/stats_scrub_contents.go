@@ -0,0 +1,44 @@
+package maintenancestats
+
+import (
+	"fmt"
+
+	"github.com/kopia/kopia/internal/contentlog"
+)
+
+const scrubContentsStatsKind = "scrubContentsStats"
+
+// ScrubContentsStats are the stats for scrubbing contents.
+type ScrubContentsStats struct {
+	ScrubbedPackCount      int   `json:"scrubbedPackCount"`
+	ScrubbedPackSize       int64 `json:"scrubbedPackSize"`
+	ScrubbedContentCount   int   `json:"scrubbedContentCount"`
+	CorruptedShardCount    int   `json:"corruptedShardCount"`
+	CorrectedContentCount  int   `json:"correctedContentCount"`
+	RepairedContentCount   int   `json:"repairedContentCount"`
+	UnreadableContentCount int   `json:"unreadableContentCount"`
+}
+
+// WriteValueTo writes the stats to JSONWriter.
+func (ss *ScrubContentsStats) WriteValueTo(jw *contentlog.JSONWriter) {
+	jw.BeginObjectField(ss.Kind())
+	jw.IntField("scrubbedPackCount", ss.ScrubbedPackCount)
+	jw.Int64Field("scrubbedPackSize", ss.ScrubbedPackSize)
+	jw.IntField("scrubbedContentCount", ss.ScrubbedContentCount)
+	jw.IntField("corruptedShardCount", ss.CorruptedShardCount)
+	jw.IntField("correctedContentCount", ss.CorrectedContentCount)
+	jw.IntField("repairedContentCount", ss.RepairedContentCount)
+	jw.IntField("unreadableContentCount", ss.UnreadableContentCount)
+	jw.EndObject()
+}
+
+// Summary generates a human readable summary for the stats.
+func (ss *ScrubContentsStats) Summary() string {
+	return fmt.Sprintf("Scrubbed %v contents in %v(%v) packs. Found %v contents with %v corrupted shards and repaired %v of them. Found %v unreadable contents.",
+		ss.ScrubbedContentCount, ss.ScrubbedPackCount, ss.ScrubbedPackSize, ss.CorrectedContentCount, ss.CorruptedShardCount, ss.RepairedContentCount, ss.UnreadableContentCount)
+}
+
+// Kind returns the kind name for the stats.
+func (ss *ScrubContentsStats) Kind() string {
+	return scrubContentsStatsKind
+}
@@ -0,0 +1,43 @@
+package maintenancestats
+
+import (
+	"fmt"
+
+	"github.com/kopia/kopia/internal/contentlog"
+)
+
+const trainCompressionDictionaryStatsKind = "trainCompressionDictionaryStats"
+
+// TrainCompressionDictionaryStats are the stats for training compression dictionary.
+type TrainCompressionDictionaryStats struct {
+	EligibleContentCount int    `json:"eligibleContentCount"`
+	SampleCount          int    `json:"sampleCount"`
+	SampleBytes          int64  `json:"sampleBytes"`
+	DictionaryID         uint32 `json:"dictionaryID"`
+	DictionarySize       int    `json:"dictionarySize"`
+}
+
+// WriteValueTo writes the stats to JSONWriter.
+func (ts *TrainCompressionDictionaryStats) WriteValueTo(jw *contentlog.JSONWriter) {
+	jw.BeginObjectField(ts.Kind())
+	jw.IntField("eligibleContentCount", ts.EligibleContentCount)
+	jw.IntField("sampleCount", ts.SampleCount)
+	jw.Int64Field("sampleBytes", ts.SampleBytes)
+	jw.UInt32Field("dictionaryID", ts.DictionaryID)
+	jw.IntField("dictionarySize", ts.DictionarySize)
+	jw.EndObject()
+}
+
+// Summary generates a human readable summary for the stats.
+func (ts *TrainCompressionDictionaryStats) Summary() string {
+	if ts.DictionaryID == 0 {
+		return fmt.Sprintf("Found %v contents eligible for dictionary compression, no dictionary was trained.", ts.EligibleContentCount)
+	}
+
+	return fmt.Sprintf("Trained compression dictionary %v (%v bytes) from %v(%v) samples out of %v eligible contents.", ts.DictionaryID, ts.DictionarySize, ts.SampleCount, ts.SampleBytes, ts.EligibleContentCount)
+}
+
+// Kind returns the kind name for the stats.
+func (ts *TrainCompressionDictionaryStats) Kind() string {
+	return trainCompressionDictionaryStatsKind
+}
@@ -10,6 +10,7 @@
 	"os"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -35,6 +36,16 @@ type committedManifestManager struct {
 	committedEntries map[ID]*manifestEntry
 	// +checklocks:cmmu
 	committedContentIDs map[content.ID]struct{}
+	// +checklocks:cmmu
+	byLabel labelIndex
+	// committed entries whose payloads have not been loaded yet.
+	// +checklocks:cmmu
+	unloaded map[ID]unloadedEntry
+
+	// +checklocks:cmmu
+	persistent *persistentIndex
+	// +checklocks:cmmu
+	persistentLoaded bool
 
 	// autoCompactionThreshold controls the threshold after which the manager auto-compacts
 	// manifest contents
@@ -42,6 +53,13 @@ type committedManifestManager struct {
 	autoCompactionThreshold int
 }
 
+// unloadedEntry describes the location of a payload of an entry loaded from the persistent index.
+type unloadedEntry struct {
+	contentID content.ID
+	modTime   time.Time
+	length    int
+}
+
 func (m *committedManifestManager) getCommittedEntryOrNil(ctx context.Context, id ID) (*manifestEntry, error) {
 	m.lock()
 	defer m.unlock()
@@ -50,9 +68,76 @@ func (m *committedManifestManager) getCommittedEntryOrNil(ctx context.Context, i
 		return nil, err
 	}
 
+	u, ok := m.unloaded[id]
+	if !ok {
+		return m.committedEntries[id], nil
+	}
+
+	err := m.loadPayloadsLocked(ctx, map[content.ID]struct{}{u.contentID: {}})
+	if errors.Is(err, content.ErrContentNotFound) {
+		// lost a race with another manifest manager which just did compaction, reload and try again.
+		if err = m.loadCommittedContentsLocked(ctx); err != nil {
+			return nil, err
+		}
+
+		if u, ok = m.unloaded[id]; !ok {
+			return m.committedEntries[id], nil
+		}
+
+		err = m.loadPayloadsLocked(ctx, map[content.ID]struct{}{u.contentID: {}})
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
 	return m.committedEntries[id], nil
 }
 
+// loadPayloadsLocked fetches the provided manifest contents and fills in payloads of
+// committed entries that were loaded from the persistent index without them.
+// Entries are replaced rather than modified, since callers may hold references to them.
+// +checklocks:m.cmmu
+func (m *committedManifestManager) loadPayloadsLocked(ctx context.Context, contentIDs map[content.ID]struct{}) error {
+	for cid := range contentIDs {
+		man, err := loadManifestContent(ctx, m.b, cid)
+		if err != nil {
+			return errors.Wrap(err, "unable to load manifest payloads")
+		}
+
+		for _, e := range man.Entries {
+			if u, ok := m.unloaded[e.ID]; ok && u.contentID == cid && u.modTime.Equal(e.ModTime) {
+				m.committedEntries[e.ID] = e
+				delete(m.unloaded, e.ID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// +checklocks:m.cmmu
+func (m *committedManifestManager) loadAllPayloadsLocked(ctx context.Context) error {
+	missing := map[content.ID]struct{}{}
+
+	for _, u := range m.unloaded {
+		missing[u.contentID] = struct{}{}
+	}
+
+	return m.loadPayloadsLocked(ctx, missing)
+}
+
+// +checklocks:m.cmmu
+func (m *committedManifestManager) entryMetadataLocked(e *manifestEntry) *EntryMetadata {
+	md := cloneEntryMetadata(e)
+
+	if u, ok := m.unloaded[e.ID]; ok {
+		md.Length = u.length
+	}
+
+	return md
+}
+
 // +checklocks:m.cmmu
 func (m *committedManifestManager) dump(ctx context.Context, prefix string) {
 	if m.debugID == "" {
@@ -70,7 +155,13 @@ func (m *committedManifestManager) dump(ctx context.Context, prefix string) {
 	log(ctx).Debugf(prefix+"["+m.debugID+"] committed keys %v: %v rev=%v", len(keys), keys, m.lastRevision)
 }
 
-func (m *committedManifestManager) findCommittedEntries(ctx context.Context, labels map[string]string) (map[ID]*manifestEntry, error) {
+func (m *committedManifestManager) findCommittedEntries(ctx context.Context, labels map[string]string) ([]*EntryMetadata, error) {
+	return m.queryCommittedEntries(ctx, Query{Labels: labels}, nil)
+}
+
+// queryCommittedEntries returns metadata of committed entries matching the provided query, ordered by
+// modification time, skipping entries with the provided IDs.
+func (m *committedManifestManager) queryCommittedEntries(ctx context.Context, q Query, skip map[ID]struct{}) ([]*EntryMetadata, error) {
 	m.lock()
 	defer m.unlock()
 
@@ -78,7 +169,45 @@ func (m *committedManifestManager) findCommittedEntries(ctx context.Context, lab
 		return nil, err
 	}
 
-	return findEntriesMatchingLabels(m.committedEntries, labels), nil
+	var found []*manifestEntry
+
+	addIfMatches := func(e *manifestEntry) {
+		if _, ok := skip[e.ID]; ok {
+			return
+		}
+
+		if q.matches(e.Labels, e.ModTime) {
+			found = append(found, e)
+		}
+	}
+
+	if len(q.Labels) == 0 {
+		for _, e := range m.committedEntries {
+			addIfMatches(e)
+		}
+	} else {
+		for id := range m.byLabel.candidates(q.Labels) {
+			if e := m.committedEntries[id]; e != nil {
+				addIfMatches(e)
+			}
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].ModTime.Before(found[j].ModTime)
+	})
+
+	if q.Newest > 0 && len(found) > q.Newest {
+		found = found[len(found)-q.Newest:]
+	}
+
+	matches := make([]*EntryMetadata, 0, len(found))
+
+	for _, e := range found {
+		matches = append(matches, m.entryMetadataLocked(e))
+	}
+
+	return matches, nil
 }
 
 func (m *committedManifestManager) commitEntries(ctx context.Context, entries map[ID]*manifestEntry) (map[content.ID]struct{}, error) {
@@ -89,7 +218,17 @@ func (m *committedManifestManager) commitEntries(ctx context.Context, entries ma
 	m.lock()
 	defer m.unlock()
 
-	return m.writeEntriesLocked(ctx, entries)
+	m.ensurePersistentLoadedLocked(ctx)
+
+	written, err := m.writeEntriesLocked(ctx, entries)
+	if err != nil {
+		return nil, err
+	}
+
+	// persist metadata of the new contents, so that the next session does not need to read them.
+	m.persistent.save(ctx)
+
+	return written, nil
 }
 
 // writeEntriesLocked writes entries in the provided map as manifest contents
@@ -127,13 +266,26 @@ func (m *committedManifestManager) writeEntriesLocked(ctx context.Context, entri
 
 	for _, e := range entries {
 		m.committedEntries[e.ID] = e
+		m.byLabel.add(e)
+		delete(m.unloaded, e.ID)
 	}
 
 	m.committedContentIDs[contentID] = struct{}{}
+	m.persistent.put(contentID, man.Entries)
 
 	return map[content.ID]struct{}{contentID: {}}, nil
 }
 
+// ensurePersistentLoadedLocked loads the persistent index once, before it is first read or updated,
+// so that saving it does not drop metadata persisted by earlier sessions.
+// +checklocks:m.cmmu
+func (m *committedManifestManager) ensurePersistentLoadedLocked(ctx context.Context) {
+	if !m.persistentLoaded {
+		m.persistent.load(ctx)
+		m.persistentLoaded = true
+	}
+}
+
 // +checklocks:m.cmmu
 func (m *committedManifestManager) loadCommittedContentsLocked(ctx context.Context) error {
 	m.verifyLocked()
@@ -141,15 +293,30 @@ func (m *committedManifestManager) loadCommittedContentsLocked(ctx context.Conte
 	var (
 		mu        sync.Mutex
 		manifests map[content.ID]manifest
+		lengths   map[*manifestEntry]int
 	)
 
+	m.ensurePersistentLoadedLocked(ctx)
+
 	for {
 		manifests = map[content.ID]manifest{}
+		lengths = map[*manifestEntry]int{}
 
 		err := m.b.IterateContents(ctx, content.IterateOptions{
 			Range:    index.PrefixRange(ContentPrefix),
 			Parallel: manifestLoadParallelism,
 		}, func(ci content.Info) error {
+			mu.Lock()
+			cached, ok := m.persistent.get(ci.ContentID, lengths)
+			if ok {
+				manifests[ci.ContentID] = cached
+			}
+			mu.Unlock()
+
+			if ok {
+				return nil
+			}
+
 			man, err := loadManifestContent(ctx, m.b, ci.ContentID)
 			if err != nil {
 				// this can be used to allow corrupted repositories to still open and see the
@@ -166,6 +333,7 @@ func (m *committedManifestManager) loadCommittedContentsLocked(ctx context.Conte
 			mu.Lock()
 
 			manifests[ci.ContentID] = man
+			m.persistent.put(ci.ContentID, man.Entries)
 
 			mu.Unlock()
 
@@ -184,7 +352,10 @@ func (m *committedManifestManager) loadCommittedContentsLocked(ctx context.Conte
 		return errors.Wrap(err, "unable to load manifest contents")
 	}
 
-	m.loadManifestContentsLocked(manifests)
+	m.loadManifestContentsLocked(manifests, lengths)
+
+	m.persistent.retainOnly(m.committedContentIDs)
+	m.persistent.save(ctx)
 
 	if err := m.maybeCompactLocked(ctx); err != nil {
 		return errors.Wrap(err, "error auto-compacting contents")
@@ -193,17 +364,22 @@ func (m *committedManifestManager) loadCommittedContentsLocked(ctx context.Conte
 	return nil
 }
 
+// lengths has payload lengths of entries that were loaded from the persistent index without payloads.
 // +checklocks:m.cmmu
-func (m *committedManifestManager) loadManifestContentsLocked(manifests map[content.ID]manifest) {
+func (m *committedManifestManager) loadManifestContentsLocked(manifests map[content.ID]manifest, lengths map[*manifestEntry]int) {
 	m.committedEntries = map[ID]*manifestEntry{}
 	m.committedContentIDs = map[content.ID]struct{}{}
+	m.unloaded = map[ID]unloadedEntry{}
 
 	for contentID := range manifests {
 		m.committedContentIDs[contentID] = struct{}{}
 	}
 
-	for _, man := range manifests {
+	sources := map[*manifestEntry]content.ID{}
+
+	for contentID, man := range manifests {
 		for _, e := range man.Entries {
+			sources[e] = contentID
 			m.mergeEntryLocked(e)
 		}
 	}
@@ -214,6 +390,16 @@ func (m *committedManifestManager) loadManifestContentsLocked(manifests map[cont
 			delete(m.committedEntries, k)
 		}
 	}
+
+	m.byLabel = labelIndex{}
+
+	for _, e := range m.committedEntries {
+		m.byLabel.add(e)
+
+		if length, ok := lengths[e]; ok {
+			m.unloaded[e.ID] = unloadedEntry{sources[e], e.ModTime, length}
+		}
+	}
 }
 
 func (m *committedManifestManager) compact(ctx context.Context) error {
@@ -261,6 +447,11 @@ func (m *committedManifestManager) compactLocked(ctx context.Context) error {
 	m.b.DisableIndexFlush(ctx)
 	defer m.b.EnableIndexFlush(ctx)
 
+	// entries loaded from the persistent index need their payloads before they can be rewritten.
+	if err := m.loadAllPayloadsLocked(ctx); err != nil {
+		return err
+	}
+
 	written, err := m.writeEntriesLocked(ctx, m.committedEntries)
 	if err != nil {
 		return err
@@ -280,6 +471,9 @@ func (m *committedManifestManager) compactLocked(ctx context.Context) error {
 		delete(m.committedContentIDs, b)
 	}
 
+	m.persistent.retainOnly(m.committedContentIDs)
+	m.persistent.save(ctx)
+
 	return nil
 }
 
@@ -363,7 +557,7 @@ func loadManifestContent(ctx context.Context, b contentManager, contentID conten
 	return man, errors.Wrapf(err, "unable to parse manifest %q", contentID)
 }
 
-func newCommittedManager(b contentManager, autoCompactionThreshold int) *committedManifestManager {
+func newCommittedManager(b contentManager, autoCompactionThreshold int, persistent *persistentIndex) *committedManifestManager {
 	debugID := ""
 	if os.Getenv("KOPIA_DEBUG_MANIFEST_MANAGER") != "" {
 		debugID = fmt.Sprintf("%x", rand.Int63()) //nolint:gosec
@@ -374,6 +568,9 @@ func newCommittedManager(b contentManager, autoCompactionThreshold int) *committ
 		debugID:                 debugID,
 		committedEntries:        map[ID]*manifestEntry{},
 		committedContentIDs:     map[content.ID]struct{}{},
+		byLabel:                 labelIndex{},
+		unloaded:                map[ID]unloadedEntry{},
+		persistent:              persistent,
 		autoCompactionThreshold: autoCompactionThreshold,
 	}
 }
@@ -0,0 +1,41 @@
+package manifest
+
+// labelIndex maps each label key and value to the set of IDs of entries having that label.
+type labelIndex map[string]map[string]map[ID]struct{}
+
+func (li labelIndex) add(e *manifestEntry) {
+	for k, v := range e.Labels {
+		byValue := li[k]
+		if byValue == nil {
+			byValue = map[string]map[ID]struct{}{}
+			li[k] = byValue
+		}
+
+		ids := byValue[v]
+		if ids == nil {
+			ids = map[ID]struct{}{}
+			byValue[v] = ids
+		}
+
+		ids[e.ID] = struct{}{}
+	}
+}
+
+// candidates returns the smallest set of IDs of entries which may match all provided labels.
+// The index is only ever added to, so callers must verify the labels of returned entries.
+func (li labelIndex) candidates(labels map[string]string) map[ID]struct{} {
+	var best map[ID]struct{}
+
+	for k, v := range labels {
+		ids := li[k][v]
+		if len(ids) == 0 {
+			return nil
+		}
+
+		if best == nil || len(ids) < len(best) {
+			best = ids
+		}
+	}
+
+	return best
+}
@@ -13,6 +13,7 @@
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/cacheprot"
 	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/internal/metrics"
@@ -180,7 +181,7 @@ func (m *Manager) Find(ctx context.Context, labels map[string]string) ([]*EntryM
 			continue
 		}
 
-		matches = append(matches, cloneEntryMetadata(e))
+		matches = append(matches, e)
 	}
 
 	sort.Slice(matches, func(i, j int) bool {
@@ -277,6 +278,11 @@ func IDsFromStrings(input []string) []ID {
 type ManagerOptions struct {
 	TimeNow                 func() time.Time // Time provider
 	AutoCompactionThreshold int
+
+	// IndexDirectory, when set, is the local directory where metadata of committed manifests
+	// is persisted between sessions, IndexProtection protects the persisted data.
+	IndexDirectory  string
+	IndexProtection cacheprot.StorageProtection
 }
 
 // NewManager returns new manifest manager for the provided content manager.
@@ -297,7 +303,7 @@ func NewManager(_ context.Context, b contentManager, options ManagerOptions, mr
 		b:              b,
 		pendingEntries: map[ID]*manifestEntry{},
 		timeNow:        timeNow,
-		committed:      newCommittedManager(b, autoCompactionThreshold),
+		committed:      newCommittedManager(b, autoCompactionThreshold, newPersistentIndex(options.IndexDirectory, options.IndexProtection)),
 	}
 
 	return m, nil
@@ -0,0 +1,88 @@
+package manifest
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// Query describes a set of manifest entries to be returned by Manager.Query.
+type Query struct {
+	// Labels that must all be present on matching entries, including tags.
+	Labels map[string]string `json:"labels"`
+
+	// MinModTime and MaxModTime limit the results to entries modified in the [MinModTime, MaxModTime)
+	// time window, zero values mean no limit.
+	MinModTime time.Time `json:"minModTime"`
+	MaxModTime time.Time `json:"maxModTime"`
+
+	// Newest, when positive, limits the results to the given number of most recently modified entries.
+	Newest int `json:"newest,omitempty"`
+}
+
+func (q Query) matches(labels map[string]string, modTime time.Time) bool {
+	if !matchesLabels(labels, q.Labels) {
+		return false
+	}
+
+	if !q.MinModTime.IsZero() && modTime.Before(q.MinModTime) {
+		return false
+	}
+
+	if !q.MaxModTime.IsZero() && !modTime.Before(q.MaxModTime) {
+		return false
+	}
+
+	return true
+}
+
+// Apply returns the entries from the provided list that satisfy the query, ordered by modification time.
+// It is used to evaluate queries against results of Find() by clients which can't query the index directly.
+func (q Query) Apply(entries []*EntryMetadata) []*EntryMetadata {
+	var result []*EntryMetadata
+
+	for _, e := range entries {
+		if q.matches(e.Labels, e.ModTime) {
+			result = append(result, e)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].ModTime.Before(result[j].ModTime)
+	})
+
+	if q.Newest > 0 && len(result) > q.Newest {
+		result = result[len(result)-q.Newest:]
+	}
+
+	return result
+}
+
+// Query returns the list of EntryMetadata for manifest entries matching the provided query,
+// ordered by modification time. Committed entries are looked up in the label index and filtered
+// before their metadata is copied, so only the returned entries are cloned.
+func (m *Manager) Query(ctx context.Context, q Query) ([]*EntryMetadata, error) {
+	m.mu.Lock()
+
+	var matches []*EntryMetadata
+
+	// pending entries supersede committed entries with the same ID.
+	pending := map[ID]struct{}{}
+
+	for id, e := range m.pendingEntries {
+		pending[id] = struct{}{}
+
+		if q.matches(e.Labels, e.ModTime) {
+			matches = append(matches, cloneEntryMetadata(e))
+		}
+	}
+
+	m.mu.Unlock()
+
+	committedMatches, err := m.committed.queryCommittedEntries(ctx, q, pending)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.Apply(append(matches, committedMatches...)), nil
+}
@@ -0,0 +1,65 @@
+package manifest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/faketime"
+	"github.com/kopia/kopia/internal/testlogging"
+)
+
+func TestManifestQuery(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	t0 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	ta := faketime.NewTimeAdvance(t0)
+
+	mgr := newManagerForTesting(ctx, t, data, ManagerOptions{TimeNow: ta.NowFunc()})
+
+	var ids []ID
+
+	for i := range 10 {
+		labels := map[string]string{"type": "item", "source": "a"}
+		if i%2 == 0 {
+			labels["tag:even"] = "yes"
+		}
+
+		ids = append(ids, addAndVerify(ctx, t, mgr, labels, map[string]int{"i": i}))
+
+		ta.Advance(time.Hour)
+
+		if i == 4 {
+			// make sure we query both pending and committed entries
+			require.NoError(t, mgr.Flush(ctx))
+		}
+	}
+
+	addAndVerify(ctx, t, mgr, map[string]string{"type": "item", "source": "b"}, map[string]int{})
+
+	cases := []struct {
+		q    Query
+		want []ID
+	}{
+		{Query{Labels: map[string]string{"source": "a"}}, ids},
+		{Query{Labels: map[string]string{"source": "a"}, Newest: 3}, ids[7:]},
+		{Query{Labels: map[string]string{"source": "a"}, MinModTime: t0.Add(3 * time.Hour), MaxModTime: t0.Add(6 * time.Hour)}, ids[3:6]},
+		{Query{Labels: map[string]string{"source": "a", "tag:even": "yes"}, Newest: 2}, []ID{ids[6], ids[8]}},
+		{Query{Labels: map[string]string{"source": "a", "tag:even": "yes"}, MaxModTime: t0.Add(3 * time.Hour)}, []ID{ids[0], ids[2]}},
+		{Query{Labels: map[string]string{"source": "c"}}, nil},
+	}
+
+	for _, tc := range cases {
+		got, err := mgr.Query(ctx, tc.q)
+		require.NoError(t, err)
+
+		var gotIDs []ID
+		for _, e := range got {
+			gotIDs = append(gotIDs, e.ID)
+		}
+
+		require.Equal(t, tc.want, gotIDs, "query: %+v", tc.q)
+	}
+}
@@ -0,0 +1,314 @@
+package manifest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/atomicfile"
+	"github.com/kopia/kopia/internal/cacheprot"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/impossible"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/content/index"
+)
+
+const (
+	persistentIndexFileName        = "manifest-index"
+	persistentIndexSegmentsDirName = "manifest-index-segments"
+	persistentIndexVersion         = 1
+	persistentIndexDirMode         = 0o700
+
+	// maxPersistentIndexSegments is the number of segments after which they are merged into the index file.
+	maxPersistentIndexSegments = 64
+)
+
+// persistentIndexEntry is the metadata of a single manifest entry stored in the persistent index.
+type persistentIndexEntry struct {
+	ID      ID                `json:"id"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	ModTime time.Time         `json:"modified"`
+	Deleted bool              `json:"deleted,omitempty"`
+	Length  int               `json:"length"`
+}
+
+type persistentIndexData struct {
+	Version  int                               `json:"version"`
+	Contents map[string][]persistentIndexEntry `json:"contents"`
+}
+
+// persistentIndex keeps metadata (labels and times) of committed manifest entries in a local
+// directory, so that manifest contents don't have to be fetched and decoded every time the
+// repository is opened. Manifest contents are immutable and content IDs are derived from their
+// data, so cached metadata never goes stale and only needs to be pruned when contents go away.
+//
+// Metadata of newly written contents is appended as small segment files, which are occasionally
+// merged into the index file together with removals of contents that went away.
+// The index can always be rebuilt from manifest contents by removing the files.
+type persistentIndex struct {
+	filename    string
+	segmentsDir string
+	prot        cacheprot.StorageProtection
+
+	contents map[content.ID][]persistentIndexEntry
+
+	// added has metadata of contents added since the index was saved, which is written as a new segment.
+	added map[content.ID][]persistentIndexEntry
+
+	// segments are files of segments that were loaded or written, which are removed once they are merged.
+	segments []string
+
+	// needsCompaction is set when the index file needs to be rewritten, because contents were removed
+	// or the file could not be loaded.
+	needsCompaction bool
+}
+
+// get returns entries of the provided manifest content without payloads and records
+// their payload lengths in the provided map.
+func (pi *persistentIndex) get(contentID content.ID, lengths map[*manifestEntry]int) (manifest, bool) {
+	entries, ok := pi.contents[contentID]
+	if !ok {
+		return manifest{}, false
+	}
+
+	man := manifest{}
+
+	for _, ie := range entries {
+		e := &manifestEntry{
+			ID:      ie.ID,
+			Labels:  ie.Labels,
+			ModTime: ie.ModTime,
+			Deleted: ie.Deleted,
+		}
+
+		man.Entries = append(man.Entries, e)
+		lengths[e] = ie.Length
+	}
+
+	return man, true
+}
+
+func (pi *persistentIndex) put(contentID content.ID, entries []*manifestEntry) {
+	var ies []persistentIndexEntry
+
+	for _, e := range entries {
+		ies = append(ies, persistentIndexEntry{
+			ID:      e.ID,
+			Labels:  e.Labels,
+			ModTime: e.ModTime,
+			Deleted: e.Deleted,
+			Length:  len(e.Content),
+		})
+	}
+
+	pi.contents[contentID] = ies
+	pi.added[contentID] = ies
+}
+
+// retainOnly removes metadata of contents that are not in the provided set.
+func (pi *persistentIndex) retainOnly(contentIDs map[content.ID]struct{}) {
+	for cid := range pi.contents {
+		if _, ok := contentIDs[cid]; !ok {
+			delete(pi.contents, cid)
+			delete(pi.added, cid)
+
+			pi.needsCompaction = true
+		}
+	}
+}
+
+func (pi *persistentIndex) load(ctx context.Context) {
+	pi.contents = map[content.ID][]persistentIndexEntry{}
+	pi.added = map[content.ID][]persistentIndexEntry{}
+	pi.segments = nil
+	pi.needsCompaction = false
+
+	if pi.filename == "" {
+		return
+	}
+
+	if err := pi.loadFromFile(pi.filename, pi.contents); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log(ctx).Debugf("unable to load persistent manifest index, will rebuild: %v", err)
+		}
+
+		pi.contents = map[content.ID][]persistentIndexEntry{}
+		pi.needsCompaction = true
+	}
+
+	// segments only add metadata of immutable contents, so they can be applied in any order.
+	segments, err := os.ReadDir(pi.segmentsDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log(ctx).Debugf("unable to list persistent manifest index segments: %v", err)
+	}
+
+	for _, seg := range segments {
+		if _, err := uuid.Parse(seg.Name()); err != nil {
+			// temporary files of segments being written.
+			continue
+		}
+
+		fname := filepath.Join(pi.segmentsDir, seg.Name())
+
+		if err := pi.loadFromFile(fname, pi.contents); err != nil {
+			log(ctx).Debugf("unable to load persistent manifest index segment %v: %v", seg.Name(), err)
+		}
+
+		pi.segments = append(pi.segments, fname)
+	}
+}
+
+// loadFromFile adds contents stored in the provided index or segment file to the map.
+func (pi *persistentIndex) loadFromFile(filename string, contents map[content.ID][]persistentIndexEntry) error {
+	b, err := os.ReadFile(filename) //nolint:gosec
+	if err != nil {
+		return errors.Wrap(err, "error reading index file")
+	}
+
+	var plain gather.WriteBuffer
+	defer plain.Close()
+
+	if err := pi.prot.Verify(filepath.Base(filename), gather.FromSlice(b), &plain); err != nil {
+		return errors.Wrap(err, "index file verification failed")
+	}
+
+	gz, err := gzip.NewReader(plain.Bytes().Reader())
+	if err != nil {
+		return errors.Wrap(err, "unable to open gzip stream")
+	}
+
+	defer gz.Close() //nolint:errcheck
+
+	var d persistentIndexData
+
+	if err := json.NewDecoder(gz).Decode(&d); err != nil {
+		return errors.Wrap(err, "unable to decode index")
+	}
+
+	if d.Version != persistentIndexVersion {
+		return errors.Errorf("unsupported index version %v", d.Version)
+	}
+
+	parsed := map[content.ID][]persistentIndexEntry{}
+
+	for k, v := range d.Contents {
+		cid, err := index.ParseID(k)
+		if err != nil {
+			return errors.Wrapf(err, "invalid content ID %q", k)
+		}
+
+		parsed[cid] = v
+	}
+
+	maps.Copy(contents, parsed)
+
+	return nil
+}
+
+// save writes metadata added since the last save as a new segment or rewrites the index file
+// when it needs compaction or there are too many segments.
+func (pi *persistentIndex) save(ctx context.Context) {
+	if pi.filename == "" {
+		return
+	}
+
+	switch {
+	case pi.needsCompaction || (len(pi.added) > 0 && len(pi.segments) >= maxPersistentIndexSegments):
+		if err := pi.compact(); err != nil {
+			log(ctx).Debugf("unable to save persistent manifest index: %v", err)
+			return
+		}
+
+	case len(pi.added) > 0:
+		fname := filepath.Join(pi.segmentsDir, uuid.NewString())
+
+		if err := pi.saveToFile(fname, pi.added); err != nil {
+			log(ctx).Debugf("unable to save persistent manifest index segment: %v", err)
+			return
+		}
+
+		pi.segments = append(pi.segments, fname)
+
+	default:
+		return
+	}
+
+	pi.added = map[content.ID][]persistentIndexEntry{}
+}
+
+// compact rewrites the index file with all contents and removes merged segments.
+// Segments written concurrently by other processes are kept and merged later.
+func (pi *persistentIndex) compact() error {
+	if err := pi.saveToFile(pi.filename, pi.contents); err != nil {
+		return err
+	}
+
+	for _, fname := range pi.segments {
+		if err := os.Remove(fname); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return errors.Wrap(err, "unable to remove merged index segment")
+		}
+	}
+
+	pi.segments = nil
+	pi.needsCompaction = false
+
+	return nil
+}
+
+func (pi *persistentIndex) saveToFile(filename string, contents map[content.ID][]persistentIndexEntry) error {
+	d := persistentIndexData{
+		Version:  persistentIndexVersion,
+		Contents: map[string][]persistentIndexEntry{},
+	}
+
+	for cid, v := range contents {
+		d.Contents[cid.String()] = v
+	}
+
+	var buf gather.WriteBuffer
+	defer buf.Close()
+
+	gz := gzip.NewWriter(&buf)
+	impossible.PanicOnError(json.NewEncoder(gz).Encode(d))
+	impossible.PanicOnError(gz.Close())
+
+	var protected gather.WriteBuffer
+	defer protected.Close()
+
+	pi.prot.Protect(filepath.Base(filename), buf.Bytes(), &protected)
+
+	if err := os.MkdirAll(filepath.Dir(filename), persistentIndexDirMode); err != nil {
+		return errors.Wrap(err, "unable to create index directory")
+	}
+
+	return errors.Wrap(atomicfile.Write(filename, bytes.NewReader(protected.ToByteSlice())), "unable to write index file")
+}
+
+func newPersistentIndex(dir string, prot cacheprot.StorageProtection) *persistentIndex {
+	if prot == nil {
+		prot = cacheprot.NoProtection()
+	}
+
+	var filename, segmentsDir string
+
+	if dir != "" {
+		filename = filepath.Join(dir, persistentIndexFileName)
+		segmentsDir = filepath.Join(dir, persistentIndexSegmentsDirName)
+	}
+
+	return &persistentIndex{
+		filename:    filename,
+		segmentsDir: segmentsDir,
+		prot:        prot,
+		contents:    map[content.ID][]persistentIndexEntry{},
+		added:       map[content.ID][]persistentIndexEntry{},
+	}
+}
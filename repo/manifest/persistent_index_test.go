@@ -0,0 +1,185 @@
+package manifest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/cacheprot"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo/content"
+)
+
+type countingContentManager struct {
+	contentManager
+
+	getContentCount atomic.Int32
+}
+
+func (c *countingContentManager) GetContent(ctx context.Context, contentID content.ID) ([]byte, error) {
+	c.getContentCount.Add(1)
+
+	//nolint:wrapcheck
+	return c.contentManager.GetContent(ctx, contentID)
+}
+
+func TestManifestPersistentIndex(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	indexDir := testutil.TempDirectory(t)
+
+	prot, err := cacheprot.AuthenticatedEncryptionProtection([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+
+	opts := ManagerOptions{
+		IndexDirectory:  indexDir,
+		IndexProtection: prot,
+	}
+
+	mgr := newManagerForTesting(ctx, t, data, opts)
+
+	labels1 := map[string]string{"type": "item", "color": "red"}
+	labels2 := map[string]string{"type": "item", "color": "blue"}
+
+	id1 := addAndVerify(ctx, t, mgr, labels1, map[string]int{"foo": 1})
+	id2 := addAndVerify(ctx, t, mgr, labels2, map[string]int{"bar": 2})
+
+	require.NoError(t, mgr.Flush(ctx))
+	require.NoError(t, mgr.b.Flush(ctx))
+
+	// committing entries persists their metadata.
+	require.FileExists(t, filepath.Join(indexDir, persistentIndexFileName))
+	verifyMatches(ctx, t, mgr, labels1, []ID{id1})
+
+	cm := &countingContentManager{contentManager: newContentManagerForTesting(ctx, t, data, contentManagerOpts{})}
+
+	mgr2, err := NewManager(ctx, cm, opts, nil)
+	require.NoError(t, err)
+
+	verifyMatches(ctx, t, mgr2, labels1, []ID{id1})
+	verifyMatches(ctx, t, mgr2, map[string]string{"type": "item"}, []ID{id1, id2})
+	require.Zero(t, cm.getContentCount.Load(), "manifest contents should not be read when listing")
+
+	md, err := mgr2.Find(ctx, labels2)
+	require.NoError(t, err)
+	require.Len(t, md, 1)
+
+	// metadata must be the same as for loaded entries.
+	md1, err := mgr.Find(ctx, labels2)
+	require.NoError(t, err)
+	require.Equal(t, md1, md)
+
+	verifyItem(ctx, t, mgr2, id2, labels2, map[string]int{"bar": 2})
+	require.Equal(t, int32(1), cm.getContentCount.Load())
+
+	// entries not loaded from the index can still be compacted.
+	id3 := addAndVerify(ctx, t, mgr2, labels1, map[string]int{"baz": 3})
+	require.NoError(t, mgr2.Flush(ctx))
+	require.NoError(t, mgr2.Compact(ctx))
+	require.NoError(t, mgr2.b.Flush(ctx))
+
+	mgr3 := newManagerForTesting(ctx, t, data, ManagerOptions{})
+	verifyItem(ctx, t, mgr3, id1, labels1, map[string]int{"foo": 1})
+	verifyItem(ctx, t, mgr3, id3, labels1, map[string]int{"baz": 3})
+}
+
+func TestManifestPersistentIndexCorrupted(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	indexDir := testutil.TempDirectory(t)
+	opts := ManagerOptions{IndexDirectory: indexDir}
+
+	mgr := newManagerForTesting(ctx, t, data, opts)
+
+	labels1 := map[string]string{"type": "item", "color": "red"}
+	id1 := addAndVerify(ctx, t, mgr, labels1, map[string]int{"foo": 1})
+
+	require.NoError(t, mgr.Flush(ctx))
+	require.NoError(t, mgr.b.Flush(ctx))
+	verifyMatches(ctx, t, mgr, labels1, []ID{id1})
+
+	require.NoError(t, os.WriteFile(filepath.Join(indexDir, persistentIndexFileName), []byte("garbage"), 0o600))
+
+	// the index gets rebuilt from manifest contents.
+	mgr2 := newManagerForTesting(ctx, t, data, opts)
+	verifyMatches(ctx, t, mgr2, labels1, []ID{id1})
+	verifyItem(ctx, t, mgr2, id1, labels1, map[string]int{"foo": 1})
+
+	mgr3 := newManagerForTesting(ctx, t, data, opts)
+	verifyMatches(ctx, t, mgr3, labels1, []ID{id1})
+}
+
+func TestManifestPersistentIndexSegments(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	indexDir := testutil.TempDirectory(t)
+	opts := ManagerOptions{IndexDirectory: indexDir, AutoCompactionThreshold: 1000}
+
+	segmentCount := func() int {
+		entries, err := os.ReadDir(filepath.Join(indexDir, persistentIndexSegmentsDirName))
+		if os.IsNotExist(err) {
+			return 0
+		}
+
+		require.NoError(t, err)
+
+		return len(entries)
+	}
+
+	indexFile := filepath.Join(indexDir, persistentIndexFileName)
+
+	mgr := newManagerForTesting(ctx, t, data, opts)
+
+	labels := map[string]string{"type": "item"}
+	ids := []ID{addAndVerify(ctx, t, mgr, labels, map[string]int{"foo": 0})}
+
+	require.NoError(t, mgr.Flush(ctx))
+	require.FileExists(t, indexFile)
+	require.Zero(t, segmentCount())
+
+	before, err := os.ReadFile(indexFile)
+	require.NoError(t, err)
+
+	// new contents are appended as segments without rewriting the index file.
+	ids = append(ids, addAndVerify(ctx, t, mgr, labels, map[string]int{"foo": 1}))
+	require.NoError(t, mgr.Flush(ctx))
+	require.Equal(t, 1, segmentCount())
+
+	after, err := os.ReadFile(indexFile)
+	require.NoError(t, err)
+	require.Equal(t, before, after)
+
+	require.NoError(t, mgr.b.Flush(ctx))
+
+	cm := &countingContentManager{contentManager: newContentManagerForTesting(ctx, t, data, contentManagerOpts{})}
+
+	mgr2, err := NewManager(ctx, cm, opts, nil)
+	require.NoError(t, err)
+
+	verifyMatches(ctx, t, mgr2, labels, ids)
+	require.Zero(t, cm.getContentCount.Load(), "manifest contents should not be read when listing")
+
+	// segments are merged into the index file once there are too many of them.
+	for i := 2; i <= maxPersistentIndexSegments+1; i++ {
+		ids = append(ids, addAndVerify(ctx, t, mgr, labels, map[string]int{"foo": i}))
+		require.NoError(t, mgr.Flush(ctx))
+	}
+
+	require.Less(t, segmentCount(), maxPersistentIndexSegments)
+
+	require.NoError(t, mgr.b.Flush(ctx))
+
+	cm3 := &countingContentManager{contentManager: newContentManagerForTesting(ctx, t, data, contentManagerOpts{})}
+
+	mgr3, err := NewManager(ctx, cm3, opts, nil)
+	require.NoError(t, err)
+
+	verifyMatches(ctx, t, mgr3, labels, ids)
+	require.Zero(t, cm3.getContentCount.Load(), "manifest contents should not be read when listing")
+}
@@ -71,6 +71,18 @@ func (om *Manager) NewWriter(ctx context.Context, opt WriterOptions) Writer {
 	w.prefix = opt.Prefix
 	w.compressor = compression.ByName[opt.Compressor]
 	w.metadataCompressor = compression.ByName[opt.MetadataCompressor]
+	w.autoCompression = nil
+	w.autoCompressionKey = ""
+
+	if opt.Compressor == compression.AutoCompressorName {
+		w.autoCompression = opt.AutoCompression
+		w.autoCompressionKey = opt.AutoCompressionKey
+
+		if w.autoCompression == nil {
+			w.autoCompression = compression.NewAutoSelector()
+		}
+	}
+
 	w.totalLength = 0
 	w.currentPosition = 0
 
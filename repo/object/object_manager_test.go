@@ -1042,3 +1042,39 @@ func TestWriterFailure_OnCompression(t *testing.T) {
 	_, err := w.Write(bytes.Repeat([]byte{1, 2, 3, 4}, 1e6))
 	require.ErrorIs(t, err, errSomeError)
 }
+
+func TestCompression_AutoCompression(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	cmap := map[content.ID]compression.HeaderID{}
+	_, _, om := setupTest(t, cmap)
+
+	sel := compression.NewAutoSelector()
+
+	rnd := make([]byte, 100000)
+	cryptorand.Read(rnd)
+
+	cases := []struct {
+		data []byte
+		want compression.HeaderID
+	}{
+		{rnd, content.NoCompression},
+		{bytes.Repeat([]byte("hello world "), 10000), compression.ByName[compression.AutoStrongCompressor].HeaderID()},
+	}
+
+	for _, tc := range cases {
+		w := om.NewWriter(ctx, WriterOptions{
+			Compressor:      compression.AutoCompressorName,
+			AutoCompression: sel,
+		})
+		w.Write(tc.data)
+		oid, err := w.Result()
+		require.NoError(t, err)
+
+		cid, _, ok := oid.ContentID()
+		require.True(t, ok)
+		require.Equal(t, tc.want, cmap[cid])
+	}
+
+	require.Equal(t, compression.AutoSelectionStats{None: 1, Strong: 1}, sel.Stats())
+}
@@ -0,0 +1,82 @@
+package object
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/compression"
+	"github.com/kopia/kopia/repo/content"
+)
+
+// ContentTranslator returns the ID of the content equivalent to the provided one, typically after copying it
+// to another repository.
+type ContentTranslator func(ctx context.Context, contentID content.ID) (content.ID, error)
+
+// WriterFactory creates object writers.
+type WriterFactory func(ctx context.Context, opt WriterOptions) Writer
+
+// TranslateObject recreates the object using contents returned by the translator and returns the ID of the
+// recreated object. Index objects are rewritten using the provided writer factory only when IDs of objects
+// they refer to change, otherwise their contents are translated as well.
+func TranslateObject(ctx context.Context, cr contentReader, oid ID, translate ContentTranslator, newWriter WriterFactory, metadataComp compression.Name) (ID, error) {
+	indexObjectID, ok := oid.IndexObjectID()
+	if !ok {
+		cid, compressed, _ := oid.ContentID()
+
+		newCID, err := translate(ctx, cid)
+		if err != nil {
+			return EmptyID, errors.Wrapf(err, "error translating content %v", cid)
+		}
+
+		return ID{cid: newCID, compression: compressed}, nil
+	}
+
+	entries, err := LoadIndexObject(ctx, cr, indexObjectID)
+	if err != nil {
+		return EmptyID, errors.Wrapf(err, "error reading index of %v", oid)
+	}
+
+	changed := false
+
+	for i, e := range entries {
+		newOID, err := TranslateObject(ctx, cr, e.Object, translate, newWriter, metadataComp)
+		if err != nil {
+			return EmptyID, err
+		}
+
+		if newOID != e.Object {
+			changed = true
+		}
+
+		entries[i].Object = newOID
+	}
+
+	if !changed {
+		newIndexObjectID, err := TranslateObject(ctx, cr, indexObjectID, translate, newWriter, metadataComp)
+		if err != nil {
+			return EmptyID, err
+		}
+
+		return indirectObjectID(newIndexObjectID), nil
+	}
+
+	w := newWriter(ctx, WriterOptions{
+		Prefix:             indirectContentPrefix,
+		Description:        "TRANSLATED INDEX",
+		Compressor:         metadataComp,
+		MetadataCompressor: metadataComp,
+	})
+	defer w.Close() //nolint:errcheck
+
+	if err := writeIndirectObject(w, entries); err != nil {
+		return EmptyID, err
+	}
+
+	newIndexObjectID, err := w.Result()
+	if err != nil {
+		return EmptyID, errors.Wrap(err, "error writing translated index")
+	}
+
+	return indirectObjectID(newIndexObjectID), nil
+}
@@ -0,0 +1,89 @@
+package object
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/splitter"
+)
+
+func TestTranslateObject(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	for _, dataLength := range []int{500, 3500, 100000} {
+		_, src, srcManager := setupTest(t, nil)
+
+		payload := make([]byte, dataLength)
+		rand.Read(payload)
+
+		w := srcManager.NewWriter(ctx, WriterOptions{})
+		testutil.EnsureType[*objectWriter](t, w).splitter = splitter.Fixed(100)()
+
+		_, err := w.Write(payload)
+		require.NoError(t, err)
+
+		oid, err := w.Result()
+		require.NoError(t, err)
+
+		t.Run("SameContentIDs", func(t *testing.T) {
+			_, dst, dstManager := setupTest(t, nil)
+
+			copyContent := func(ctx context.Context, cid content.ID) (content.ID, error) {
+				data, err := src.GetContent(ctx, cid)
+				if err != nil {
+					return content.EmptyID, err
+				}
+
+				return dst.WriteContent(ctx, gather.FromSlice(data), cid.Prefix(), content.NoCompression)
+			}
+
+			newOID, err := TranslateObject(ctx, src, oid, copyContent, dstManager.NewWriter, "")
+			require.NoError(t, err)
+			require.Equal(t, oid, newOID)
+
+			verifyTranslatedObject(ctx, t, dst, newOID, payload)
+		})
+
+		t.Run("DifferentContentIDs", func(t *testing.T) {
+			_, dst, dstManager := setupTest(t, nil)
+
+			rewriteContent := func(ctx context.Context, cid content.ID) (content.ID, error) {
+				data, err := src.GetContent(ctx, cid)
+				if err != nil {
+					return content.EmptyID, err
+				}
+
+				return dst.WriteContent(ctx, gather.FromSlice(data), "g", content.NoCompression)
+			}
+
+			newOID, err := TranslateObject(ctx, src, oid, rewriteContent, dstManager.NewWriter, "")
+			require.NoError(t, err)
+			require.NotEqual(t, oid, newOID)
+			require.Equal(t, indirectionLevel(oid), indirectionLevel(newOID))
+
+			verifyTranslatedObject(ctx, t, dst, newOID, payload)
+		})
+	}
+}
+
+func verifyTranslatedObject(ctx context.Context, t *testing.T, cr contentReader, oid ID, want []byte) {
+	t.Helper()
+
+	r, err := Open(ctx, cr, oid)
+	require.NoError(t, err)
+
+	defer r.Close()
+
+	got, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.True(t, bytes.Equal(want, got))
+}
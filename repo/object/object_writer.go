@@ -71,6 +71,10 @@ type objectWriter struct {
 	compressor         compression.Compressor
 	metadataCompressor compression.Compressor
 
+	// when set, the compressor is selected based on contents of the first chunk.
+	autoCompression    *compression.AutoSelector
+	autoCompressionKey string
+
 	prefix      content.IDPrefix
 	buffer      gather.WriteBuffer
 	totalLength int64
@@ -142,6 +146,11 @@ func (w *objectWriter) Write(data []byte) (n int, err error) {
 func (w *objectWriter) flushBufferLocked() error {
 	length := w.buffer.Length()
 
+	if w.autoCompression != nil {
+		w.compressor = compression.ByName[w.autoCompression.Select(w.autoCompressionKey, w.buffer.Bytes(), length)]
+		w.autoCompression = nil
+	}
+
 	// hold a lock as we may grow the index
 	w.indirectIndexGrowMutex.Lock()
 	chunkID := len(w.indirectIndex)
@@ -352,4 +361,9 @@ type WriterOptions struct {
 	MetadataCompressor compression.Name
 	Splitter           string // use particular splitter instead of default
 	AsyncWrites        int    // allow up to N content writes to be asynchronous
+
+	// AutoCompression selects the compressor when Compressor is compression.AutoCompressorName,
+	// remembering decisions by AutoCompressionKey (typically a file extension).
+	AutoCompression    *compression.AutoSelector
+	AutoCompressionKey string
 }
@@ -50,6 +50,8 @@
 var supportedFeatures = []feature.Feature{
 	"index-v1",
 	"index-v2",
+	format.FeatureCompressionDictionaries,
+	format.FeatureEncryptionMigration,
 }
 
 // throttlingWindow is the duration window during which the throttling token bucket fully replenishes.
@@ -59,6 +61,15 @@
 // start with 10% of tokens in the bucket.
 const throttleBucketInitialFill = 0.1
 
+// manifestIndexCacheSubdir is the name of the cache subdirectory where manifest manager persists its index.
+const manifestIndexCacheSubdir = "manifests"
+
+// manifestIndexCachePurpose is the purpose used to derive the key protecting the persisted manifest index.
+const manifestIndexCachePurpose = "manifest-index-cache"
+
+// manifestIndexCacheKeyLength is the length of the key protecting the persisted manifest index.
+const manifestIndexCacheKeyLength = 32
+
 // localCacheIntegrityHMACSecretLength length of HMAC secret protecting local cache items.
 const localCacheIntegrityHMACSecretLength = 16
 
@@ -346,7 +357,12 @@ func openWithConfig(ctx context.Context, st blob.Storage, cliOpts ClientOptions,
 		return nil, errors.Wrap(ferr, "unable to open object manager")
 	}
 
-	manifests, ferr := manifest.NewManager(ctx, cm, manifest.ManagerOptions{TimeNow: cmOpts.TimeNow}, mr)
+	mopts, ferr := manifestManagerOptions(cm, fmgr, cacheOpts, cmOpts.TimeNow)
+	if ferr != nil {
+		return nil, ferr
+	}
+
+	manifests, ferr := manifest.NewManager(ctx, cm, mopts, mr)
 	if ferr != nil {
 		return nil, errors.Wrap(ferr, "unable to open manifests")
 	}
@@ -398,6 +414,31 @@ func deriveHMACSecret(fmgr *format.Manager) ([]byte, error) {
 	return k, nil
 }
 
+// manifestManagerOptions returns options of the manifest manager, which persists the index of
+// manifest labels in the cache directory, encrypted with a key derived from the master key.
+func manifestManagerOptions(cm *content.WriteManager, fmgr *format.Manager, caching *content.CachingOptions, timeNow func() time.Time) (manifest.ManagerOptions, error) {
+	opts := manifest.ManagerOptions{
+		TimeNow:        timeNow,
+		IndexDirectory: caching.CacheSubdirOrEmpty(manifestIndexCacheSubdir),
+	}
+
+	if opts.IndexDirectory == "" {
+		return opts, nil
+	}
+
+	k, err := deriveKey(cm.ContentFormat(), fmgr, manifestIndexCachePurpose, manifestIndexCacheKeyLength)
+	if err != nil {
+		return opts, errors.Wrap(err, "unable to derive manifest index key")
+	}
+
+	opts.IndexProtection, err = cacheprot.AuthenticatedEncryptionProtection(k)
+	if err != nil {
+		return opts, errors.Wrap(err, "unable to initialize manifest index protection")
+	}
+
+	return opts, nil
+}
+
 func handleMissingRequiredFeatures(ctx context.Context, fmgr *format.Manager, ignoreErrors bool) error {
 	required, err := fmgr.RequiredFeatures(ctx)
 	if err != nil {
@@ -64,6 +64,27 @@ type RemoteRetentionPolicy interface {
 	ApplyRetentionPolicy(ctx context.Context, sourcePath string, reallyDelete bool) ([]manifest.ID, error)
 }
 
+// ManifestQuerier is an interface implemented by repository clients that can efficiently evaluate manifest queries.
+type ManifestQuerier interface {
+	QueryManifests(ctx context.Context, q manifest.Query) ([]*manifest.EntryMetadata, error)
+}
+
+// QueryManifests returns metadata for manifests matching the provided query, ordered by modification time.
+// Repositories that don't implement ManifestQuerier evaluate the query on the results of FindManifests.
+func QueryManifests(ctx context.Context, rep Repository, q manifest.Query) ([]*manifest.EntryMetadata, error) {
+	if mq, ok := rep.(ManifestQuerier); ok {
+		//nolint:wrapcheck
+		return mq.QueryManifests(ctx, q)
+	}
+
+	entries, err := rep.FindManifests(ctx, q.Labels)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to find manifests")
+	}
+
+	return q.Apply(entries), nil
+}
+
 // RemoteNotifications is an interface implemented by repository clients that support remote notifications.
 type RemoteNotifications interface {
 	SendNotification(ctx context.Context, templateName string, templateDataJSON []byte, templateDataType grpcapi.NotificationEventArgType, severity int32) error
@@ -142,8 +163,12 @@ type directRepository struct {
 
 // DeriveKey derives encryption key of the provided length from the master key.
 func (r *directRepository) DeriveKey(purpose string, keyLength int) (derivedKey []byte, err error) {
-	if r.cmgr.ContentFormat().SupportsPasswordChange() {
-		derivedKey, err = crypto.DeriveKeyFromMasterKey(r.cmgr.ContentFormat().GetMasterKey(), r.UniqueID(), purpose, keyLength)
+	return deriveKey(r.cmgr.ContentFormat(), r.fmgr, purpose, keyLength)
+}
+
+func deriveKey(cf format.Provider, fmgr *format.Manager, purpose string, keyLength int) (derivedKey []byte, err error) {
+	if cf.SupportsPasswordChange() {
+		derivedKey, err = crypto.DeriveKeyFromMasterKey(cf.GetMasterKey(), fmgr.UniqueID(), purpose, keyLength)
 		if err != nil {
 			return nil, errors.Wrap(err, "key derivation error")
 		}
@@ -154,7 +179,7 @@ func (r *directRepository) DeriveKey(purpose string, keyLength int) (derivedKey
 	// version of kopia <v0.9 had a bug where certain keys were derived directly from
 	// the password and not from the random master key. This made it impossible to change
 	// password.
-	derivedKey, err = crypto.DeriveKeyFromMasterKey(r.fmgr.FormatEncryptionKey(), r.UniqueID(), purpose, keyLength)
+	derivedKey, err = crypto.DeriveKeyFromMasterKey(fmgr.FormatEncryptionKey(), fmgr.UniqueID(), purpose, keyLength)
 	if err != nil {
 		return nil, errors.Wrap(err, "key derivation error")
 	}
@@ -248,6 +273,12 @@ func (r *directRepository) FindManifests(ctx context.Context, labels map[string]
 	return r.mmgr.Find(ctx, labels)
 }
 
+// QueryManifests returns metadata for manifests matching the provided query.
+func (r *directRepository) QueryManifests(ctx context.Context, q manifest.Query) ([]*manifest.EntryMetadata, error) {
+	//nolint:wrapcheck
+	return r.mmgr.Query(ctx, q)
+}
+
 // DeleteManifest deletes the manifest with a given ID.
 func (r *directRepository) DeleteManifest(ctx context.Context, id manifest.ID) error {
 	//nolint:wrapcheck
@@ -297,9 +328,12 @@ func (r *directRepository) NewDirectWriter(ctx context.Context, opt WriteSession
 		OnUpload:    opt.OnUpload,
 	}, writeManagerID)
 
-	mmgr, err := manifest.NewManager(ctx, cmgr, manifest.ManagerOptions{
-		TimeNow: r.timeNow,
-	}, r.metricsRegistry)
+	mopts, err := manifestManagerOptions(cmgr, r.fmgr, &r.cachingOptions, r.timeNow)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mmgr, err := manifest.NewManager(ctx, cmgr, mopts, r.metricsRegistry)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "error creating manifest manager")
 	}
@@ -72,6 +72,14 @@ func SupportedAlgorithms() []string {
 	"DYNAMIC-4M-RABINKARP":   pooled(newRabinKarp64SplitterFactory(splitterSize4MB)),
 	"DYNAMIC-8M-RABINKARP":   pooled(newRabinKarp64SplitterFactory(splitterSize8MB)),
 
+	"DYNAMIC-128K-FASTCDC": pooled(newFastCDCSplitterFactory(splitterSize128KB)),
+	"DYNAMIC-256K-FASTCDC": pooled(newFastCDCSplitterFactory(splitterSize256KB)),
+	"DYNAMIC-512K-FASTCDC": pooled(newFastCDCSplitterFactory(splitterSize512KB)),
+	"DYNAMIC-1M-FASTCDC":   pooled(newFastCDCSplitterFactory(splitterSize1MB)),
+	"DYNAMIC-2M-FASTCDC":   pooled(newFastCDCSplitterFactory(splitterSize2MB)),
+	"DYNAMIC-4M-FASTCDC":   pooled(newFastCDCSplitterFactory(splitterSize4MB)),
+	"DYNAMIC-8M-FASTCDC":   pooled(newFastCDCSplitterFactory(splitterSize8MB)),
+
 	// handle deprecated legacy names to splitters of arbitrary size
 	"FIXED": Fixed(splitterSize4MB),
 
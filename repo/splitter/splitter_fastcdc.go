@@ -0,0 +1,156 @@
+package splitter
+
+import (
+	"math/bits"
+)
+
+// fastCDCNormalizationLevel determines how many bits are added to (or removed from) the mask
+// before (or after) reaching the average chunk size, which narrows the chunk size distribution.
+const fastCDCNormalizationLevel = 2
+
+// fastCDCGearSeed is the seed used to generate gear hash table, changing it changes split points
+// and therefore breaks deduplication with existing data.
+const fastCDCGearSeed = 0x6b6f706961666364
+
+// gearTable maps each byte value to a pseudo-random 64-bit value used by the gear hash.
+//
+//nolint:gochecknoglobals
+var gearTable = generateGearTable(fastCDCGearSeed)
+
+// generateGearTable deterministically generates gear table using splitmix64.
+func generateGearTable(seed uint64) *[256]uint64 {
+	var t [256]uint64
+
+	x := seed
+
+	for i := range t {
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9 //nolint:mnd
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb //nolint:mnd
+		t[i] = z ^ (z >> 31)                     //nolint:mnd
+	}
+
+	return &t
+}
+
+// fastCDCSplitter implements FastCDC content-defined chunking with gear hash and normalized chunking
+// as described in "FastCDC: a Fast and Efficient Content-Defined Chunking Approach for Data Deduplication"
+// by Wen Xia et al.
+type fastCDCSplitter struct {
+	gear *[256]uint64
+
+	// maskS is used until the chunk reaches normalSize and has more bits than maskL, making
+	// split points less likely for small chunks and more likely for large ones.
+	maskS uint64
+	maskL uint64
+
+	hash       uint64
+	count      int
+	minSize    int
+	normalSize int
+	maxSize    int
+}
+
+func (rs *fastCDCSplitter) Close() {
+}
+
+func (rs *fastCDCSplitter) Reset() {
+	rs.hash = 0
+	rs.count = 0
+}
+
+func (rs *fastCDCSplitter) NextSplitPoint(b []byte) int {
+	var consumed int
+
+	// until minSize, only hash the last 64 bytes, which are the only ones affecting gear hash.
+	if left := rs.minSize - rs.count; left > 0 {
+		n := min(left, len(b))
+		h := rs.hash
+
+		for i := max(rs.minSize-splitterSlidingWindowSize-rs.count, 0); i < n; i++ {
+			h = (h << 1) + rs.gear[b[i]]
+		}
+
+		rs.hash = h
+		rs.count += n
+		consumed += n
+		b = b[n:]
+	}
+
+	if left := rs.normalSize - rs.count; left > 0 {
+		if n := rs.scan(b[:min(left, len(b))], rs.maskS); n > 0 {
+			return consumed + n
+		}
+
+		n := min(left, len(b))
+		consumed += n
+		b = b[n:]
+	}
+
+	if left := rs.maxSize - rs.count; left > 0 {
+		if n := rs.scan(b[:min(left, len(b))], rs.maskL); n > 0 {
+			return consumed + n
+		}
+
+		consumed += min(left, len(b))
+	}
+
+	// if we're over the max size, split
+	if rs.count >= rs.maxSize {
+		rs.Reset()
+		return consumed
+	}
+
+	return -1
+}
+
+// scan hashes the provided bytes and returns the number of bytes consumed up to and including the
+// split point or 0 if there's no split point.
+func (rs *fastCDCSplitter) scan(b []byte, mask uint64) int {
+	h := rs.hash
+
+	for i, c := range b {
+		h = (h << 1) + rs.gear[c]
+
+		if h&mask == 0 {
+			rs.Reset()
+			return i + 1
+		}
+	}
+
+	rs.hash = h
+	rs.count += len(b)
+
+	return 0
+}
+
+func (rs *fastCDCSplitter) MaxSegmentSize() int {
+	return rs.maxSize
+}
+
+// fastCDCMask returns a mask with the specified number of most significant bits set,
+// since the high bits of gear hash are influenced by the largest number of input bytes.
+func fastCDCMask(numBits int) uint64 {
+	return ^uint64(0) << (64 - numBits) //nolint:mnd
+}
+
+func newFastCDCSplitterFactory(avgSize int) Factory {
+	// avgSize must be a power of two
+	avgBits := bits.Len(uint(avgSize)) - 1 //nolint:gosec
+	maskS := fastCDCMask(avgBits + fastCDCNormalizationLevel)
+	maskL := fastCDCMask(avgBits - fastCDCNormalizationLevel)
+	maxSize := avgSize * 2 //nolint:mnd
+	minSize := avgSize / 4 //nolint:mnd
+
+	return func() Splitter {
+		return &fastCDCSplitter{
+			gear:       gearTable,
+			maskS:      maskS,
+			maskL:      maskL,
+			minSize:    minSize,
+			normalSize: avgSize,
+			maxSize:    maxSize,
+		}
+	}
+}
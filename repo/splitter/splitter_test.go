@@ -37,6 +37,10 @@ func TestSplitterStability(t *testing.T) {
 		{newRabinKarp64SplitterFactory(2048), 1887, 2649, 1028, 4096},
 		{newRabinKarp64SplitterFactory(32768), 121, 41322, 16896, 65536},
 		{newRabinKarp64SplitterFactory(65536), 53, 94339, 35875, 131072},
+		{newFastCDCSplitterFactory(32), 137257, 36, 9, 64},
+		{newFastCDCSplitterFactory(1024), 4295, 1164, 257, 2048},
+		{newFastCDCSplitterFactory(2048), 2161, 2313, 514, 4096},
+		{newFastCDCSplitterFactory(32768), 136, 36764, 8767, 65536},
 
 		{pooled(Fixed(1000)), 5000, 1000, 1000, 1000},
 
@@ -50,6 +54,8 @@ func TestSplitterStability(t *testing.T) {
 		{pooled(newRabinKarp64SplitterFactory(2048)), 1887, 2649, 1028, 4096},
 		{pooled(newRabinKarp64SplitterFactory(32768)), 121, 41322, 16896, 65536},
 		{pooled(newRabinKarp64SplitterFactory(65536)), 53, 94339, 35875, 131072},
+		{pooled(newFastCDCSplitterFactory(1024)), 4295, 1164, 257, 2048},
+		{pooled(newFastCDCSplitterFactory(32768)), 136, 36764, 8767, 65536},
 	}
 
 	// run each test twice to rule out the possibility of some state leaking through splitter reuse
@@ -209,3 +215,53 @@ func getSplitPointsRandomSlices(data []byte, s Splitter) (minSplit, maxSplit, co
 
 	return minSplit, maxSplit, count
 }
+
+func TestSplitterShiftResistance(t *testing.T) {
+	r := rand.New(rand.NewSource(7))
+	data := make([]byte, 4<<20)
+
+	if n, err := r.Read(data); n != len(data) || err != nil {
+		t.Fatalf("can't initialize random data: %v", err)
+	}
+
+	segments := func(f Factory, d []byte) map[string]bool {
+		s := f()
+		defer s.Close()
+
+		result := map[string]bool{}
+
+		for len(d) > 0 {
+			n := s.NextSplitPoint(d)
+			if n < 0 {
+				n = len(d)
+			}
+
+			result[string(d[:n])] = true
+			d = d[n:]
+		}
+
+		return result
+	}
+
+	for _, name := range []string{"DYNAMIC-128K-BUZHASH", "DYNAMIC-128K-FASTCDC"} {
+		t.Run(name, func(t *testing.T) {
+			f := GetFactory(name)
+
+			before := segments(f, data)
+			after := segments(f, append([]byte("some inserted prefix"), data...))
+
+			var shared int
+
+			for k := range after {
+				if before[k] {
+					shared++
+				}
+			}
+
+			// only segments near the beginning should be affected by the shift.
+			if shared*10 < len(before)*8 {
+				t.Errorf("too few shared segments after shift: %v out of %v", shared, len(before))
+			}
+		})
+	}
+}
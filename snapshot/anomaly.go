@@ -0,0 +1,55 @@
+package snapshot
+
+import (
+	"sort"
+	"strings"
+)
+
+// Tags set on snapshots in which mass changes of files were detected, which are typical for ransomware.
+// The tags can be used to find such snapshots with 'kopia snapshot list --tags anomaly:detected'.
+const (
+	AnomalyTag      = "tag:anomaly"
+	AnomalyTagValue = "detected"
+
+	anomalyKindTagPrefix = "tag:anomaly-"
+)
+
+// Kinds of anomalies.
+const (
+	AnomalyModifiedFiles   = "modified-files"   // large fraction of files was modified
+	AnomalyExtensionChurn  = "extension-churn"  // large fraction of files was renamed with the same extension appended, e.g. ".locked"
+	AnomalyEntropyIncrease = "entropy-increase" // large fraction of modified compressible files became incompressible
+)
+
+// Anomaly describes a change signal which exceeded its threshold.
+type Anomaly struct {
+	Kind        string `json:"kind"`
+	Description string `json:"description"`
+}
+
+// AddAnomaly records the anomaly in the snapshot tags.
+func (m *Manifest) AddAnomaly(kind, description string) {
+	if m.Tags == nil {
+		m.Tags = map[string]string{}
+	}
+
+	m.Tags[AnomalyTag] = AnomalyTagValue
+	m.Tags[anomalyKindTagPrefix+kind] = description
+}
+
+// Anomalies returns the anomalies recorded in the snapshot tags sorted by kind.
+func (m *Manifest) Anomalies() []Anomaly {
+	var result []Anomaly
+
+	for k, v := range m.Tags {
+		if kind, ok := strings.CutPrefix(k, anomalyKindTagPrefix); ok {
+			result = append(result, Anomaly{kind, v})
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Kind < result[j].Kind
+	})
+
+	return result
+}
@@ -0,0 +1,191 @@
+// Package commandsource implements snapshot sources made of the output of commands,
+// such as database dumps.
+package commandsource
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/virtualfs"
+	"github.com/kopia/kopia/repo/logging"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+var log = logging.Module("commandsource")
+
+// ErrActionsDisabled is returned when snapshotting a command source on a client which does not allow actions.
+// Source commands come from the policy stored in the repository, so they are subject to the same gate as actions.
+var ErrActionsDisabled = errors.New("source commands require actions to be enabled on this client (connect with --enable-actions or pass --force-enable-actions)")
+
+const (
+	// maxStderrLength is the maximum length of standard error output included in errors.
+	maxStderrLength = 4096
+
+	// waitDelay is how long to wait for output pipes to be closed after the command is killed,
+	// which may take forever when the command has spawned child processes.
+	waitDelay = 5 * time.Second
+)
+
+// Directory is a synthetic directory which contains the standard output of each source command
+// as a streaming file. Commands are started when their output is read.
+type Directory struct {
+	fs.Directory
+
+	mu   sync.Mutex
+	errs []error
+}
+
+// Err returns an error describing commands which have failed, nil if all commands succeeded.
+func (d *Directory) Err() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch len(d.errs) {
+	case 0:
+		return nil
+	case 1:
+		return d.errs[0]
+	default:
+		return errors.Errorf("%v commands failed, first error: %v", len(d.errs), d.errs[0])
+	}
+}
+
+func (d *Directory) addError(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.errs = append(d.errs, err)
+}
+
+// NewDirectory returns a synthetic directory with the provided name and the output of the commands.
+//
+// The commands are bound to the provided context, which must remain valid until the directory is uploaded.
+func NewDirectory(ctx context.Context, name string, commands []policy.SourceCommand) *Directory {
+	d := &Directory{}
+
+	var entries []fs.Entry
+
+	for _, c := range commands {
+		entries = append(entries, virtualfs.StreamingFileFromReader(c.FileName, &commandReader{
+			ctx:   ctx,
+			cmd:   c,
+			owner: d,
+		}))
+	}
+
+	d.Directory = virtualfs.NewStaticDirectory(name, entries)
+
+	return d
+}
+
+// commandReader starts the command on first read and returns its standard output.
+// A non-zero exit code is returned as a read error once the output is exhausted.
+type commandReader struct {
+	ctx   context.Context //nolint:containedctx
+	cmd   policy.SourceCommand
+	owner *Directory
+
+	c      *exec.Cmd
+	stdout io.ReadCloser
+	stderr bytes.Buffer
+	done   bool
+}
+
+func (r *commandReader) start() error {
+	r.c = exec.CommandContext(r.ctx, r.cmd.Command, r.cmd.Arguments...) //nolint:gosec
+	r.c.Stderr = &limitedBuffer{&r.stderr, maxStderrLength}
+	r.c.WaitDelay = waitDelay
+
+	stdout, err := r.c.StdoutPipe()
+	if err != nil {
+		return errors.Wrap(err, "unable to create pipe")
+	}
+
+	log(r.ctx).Debugf("running %v %v for %v", r.cmd.Command, strings.Join(r.cmd.Arguments, " "), r.cmd.FileName)
+
+	if err := r.c.Start(); err != nil {
+		return errors.Wrapf(err, "unable to start command for %v", r.cmd.FileName)
+	}
+
+	r.stdout = stdout
+
+	return nil
+}
+
+func (r *commandReader) Read(p []byte) (int, error) {
+	if r.done {
+		return 0, io.EOF
+	}
+
+	if r.c == nil {
+		if err := r.start(); err != nil {
+			r.done = true
+			r.owner.addError(err)
+
+			return 0, err
+		}
+	}
+
+	n, err := r.stdout.Read(p)
+	if !errors.Is(err, io.EOF) {
+		//nolint:wrapcheck
+		return n, err
+	}
+
+	r.done = true
+
+	if werr := r.c.Wait(); werr != nil {
+		werr = errors.Wrapf(werr, "command for %v failed: %v", r.cmd.FileName, strings.TrimSpace(r.stderr.String()))
+		r.owner.addError(werr)
+
+		return n, werr
+	}
+
+	return n, io.EOF
+}
+
+func (r *commandReader) Close() error {
+	if r.c == nil || r.done {
+		return nil
+	}
+
+	// the output was not fully consumed, stop the command.
+	r.done = true
+
+	if r.c.Process != nil {
+		r.c.Process.Kill() //nolint:errcheck
+	}
+
+	r.c.Wait() //nolint:errcheck
+
+	err := errors.Errorf("output of command for %v was not fully read", r.cmd.FileName)
+	r.owner.addError(err)
+
+	return err
+}
+
+// limitedBuffer stores up to a given number of bytes and discards the rest.
+type limitedBuffer struct {
+	buf   *bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.buf.Len(); remaining > 0 {
+		if len(p) > remaining {
+			b.buf.Write(p[:remaining])
+		} else {
+			b.buf.Write(p)
+		}
+	}
+
+	return len(p), nil
+}
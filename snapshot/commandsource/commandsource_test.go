@@ -0,0 +1,94 @@
+package commandsource_test
+
+import (
+	"io"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/snapshot/commandsource"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+func readFile(t *testing.T, d fs.Directory, name string) (string, error) {
+	t.Helper()
+
+	ctx := testlogging.Context(t)
+
+	e, err := d.Child(ctx, name)
+	require.NoError(t, err)
+
+	sf, ok := e.(fs.StreamingFile)
+	require.True(t, ok)
+
+	r, err := sf.GetReader(ctx)
+	require.NoError(t, err)
+
+	defer r.Close()
+
+	b, err := io.ReadAll(r)
+
+	return string(b), err
+}
+
+func TestDirectory(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires unix shell")
+	}
+
+	ctx := testlogging.Context(t)
+
+	d := commandsource.NewDirectory(ctx, "/dumps", []policy.SourceCommand{
+		{FileName: "a.txt", Command: "sh", Arguments: []string{"-c", "echo hello"}},
+		{FileName: "b.txt", Command: "sh", Arguments: []string{"-c", "echo partial; echo broken pipe >&2; exit 3"}},
+		{FileName: "c.txt", Command: "no-such-command-kopia"},
+	})
+
+	entries, err := fs.GetAllEntries(ctx, d)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	require.Equal(t, "/dumps", d.Name())
+
+	v, err := readFile(t, d, "a.txt")
+	require.NoError(t, err)
+	require.Equal(t, "hello\n", v)
+	require.NoError(t, d.Err())
+
+	v, err = readFile(t, d, "b.txt")
+	require.ErrorContains(t, err, "broken pipe")
+	require.Equal(t, "partial\n", v)
+	require.ErrorContains(t, d.Err(), "command for b.txt failed")
+
+	_, err = readFile(t, d, "c.txt")
+	require.ErrorContains(t, err, "unable to start command for c.txt")
+	require.ErrorContains(t, d.Err(), "2 commands failed")
+}
+
+func TestDirectory_ClosedBeforeEOF(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires unix shell")
+	}
+
+	ctx := testlogging.Context(t)
+
+	d := commandsource.NewDirectory(ctx, "/dumps", []policy.SourceCommand{
+		{FileName: "big", Command: "sh", Arguments: []string{"-c", "exec yes"}},
+	})
+
+	e, err := d.Child(ctx, "big")
+	require.NoError(t, err)
+
+	r, err := e.(fs.StreamingFile).GetReader(ctx)
+	require.NoError(t, err)
+
+	var buf [10]byte
+
+	_, err = io.ReadFull(r, buf[:])
+	require.NoError(t, err)
+
+	require.Error(t, r.Close())
+	require.ErrorContains(t, d.Err(), "not fully read")
+}
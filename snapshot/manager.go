@@ -4,6 +4,7 @@
 import (
 	"context"
 	"maps"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -78,9 +79,43 @@ func sourceInfoToLabels(si SourceInfo) map[string]string {
 	return m
 }
 
+// ListOptions specifies optional filters applied when listing snapshots.
+type ListOptions struct {
+	// Tags that must be present on returned snapshots.
+	Tags map[string]string
+
+	// MinTime and MaxTime limit the results to snapshots whose manifests were written
+	// in the [MinTime, MaxTime) time window, zero values mean no limit.
+	MinTime time.Time
+	MaxTime time.Time
+
+	// Newest, when positive, limits the results to the given number of most recently written snapshots.
+	Newest int
+}
+
 // ListSnapshots lists all snapshots for a given source.
 func ListSnapshots(ctx context.Context, rep repo.Repository, si SourceInfo) ([]*Manifest, error) {
-	entries, err := rep.FindManifests(ctx, sourceInfoToLabels(si))
+	return ListSnapshotsWithOptions(ctx, rep, si, ListOptions{})
+}
+
+// ListSnapshotsWithOptions lists snapshots for a given source matching provided options.
+func ListSnapshotsWithOptions(ctx context.Context, rep repo.Repository, si SourceInfo, opt ListOptions) ([]*Manifest, error) {
+	labels := sourceInfoToLabels(si)
+
+	for k, v := range opt.Tags {
+		if _, ok := labels[k]; ok {
+			return nil, errors.Errorf("invalid tag %q", k)
+		}
+
+		labels[k] = v
+	}
+
+	entries, err := repo.QueryManifests(ctx, rep, manifest.Query{
+		Labels:     labels,
+		MinModTime: opt.MinTime,
+		MaxModTime: opt.MaxTime,
+		Newest:     opt.Newest,
+	})
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to find manifest entries")
 	}
@@ -128,6 +128,17 @@ type DirEntry struct {
 	GroupID     uint32               `json:"gid,omitempty"`
 	ObjectID    object.ID            `json:"obj"`
 	DirSummary  *fs.DirectorySummary `json:"summ,omitempty"`
+
+	// ArchiveFormat is set on directories with members of archive files, which were expanded during snapshot.
+	ArchiveFormat string `json:"archive,omitempty"`
+
+	// ArchiveFile is the original file of an expanded archive, which is restored unless archives are extracted.
+	ArchiveFile *DirEntry `json:"archiveFile,omitempty"`
+
+	// Device, Inode and Ctime identify the source file, they are only recorded when detection of moved files is enabled.
+	Device uint64 `json:"dev,omitempty"`
+	Inode  uint64 `json:"ino,omitempty"`
+	Ctime  int64  `json:"ctime,omitempty"`
 }
 
 // Clone returns a clone of the entry.
@@ -140,6 +151,10 @@ func (e *DirEntry) Clone() *DirEntry {
 		e2.DirSummary = &s2
 	}
 
+	if e2.ArchiveFile != nil {
+		e2.ArchiveFile = e2.ArchiveFile.Clone()
+	}
+
 	return &e2
 }
 
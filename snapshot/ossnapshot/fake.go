@@ -0,0 +1,155 @@
+package ossnapshot
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+// FakeProvider is a Provider which simulates snapshots by copying the directory tree to a temporary location.
+// It is meant for tests which exercise the snapshot flow without privileges.
+type FakeProvider struct {
+	// CreateErr, when set, is returned from Create.
+	CreateErr error
+
+	mu      sync.Mutex
+	created []string
+	removed []string
+}
+
+// Name implements Provider.
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+// Create implements Provider.
+func (p *FakeProvider) Create(_ context.Context, path string) (*Snapshot, error) {
+	if p.CreateErr != nil {
+		return nil, p.CreateErr
+	}
+
+	dir, err := os.MkdirTemp("", "kopia-fake-snapshot")
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create snapshot directory")
+	}
+
+	if err := copyTree(path, dir); err != nil {
+		os.RemoveAll(dir) //nolint:errcheck
+
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.created = append(p.created, dir)
+	p.mu.Unlock()
+
+	return &Snapshot{
+		Path: dir,
+		remove: func(_ context.Context) error {
+			p.mu.Lock()
+			p.removed = append(p.removed, dir)
+			p.mu.Unlock()
+
+			return errors.Wrap(os.RemoveAll(dir), "unable to remove snapshot")
+		},
+	}, nil
+}
+
+// Created returns the paths of snapshots created so far.
+func (p *FakeProvider) Created() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]string(nil), p.created...)
+}
+
+// Removed returns the paths of snapshots removed so far.
+func (p *FakeProvider) Removed() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]string(nil), p.removed...)
+}
+
+// Factory returns a ProviderFactory which always returns the provider, suitable for RegisterProvider.
+func (p *FakeProvider) Factory() ProviderFactory {
+	return func(*policy.FilesystemSnapshotPolicy, Runner) (Provider, error) {
+		return p, nil
+	}
+}
+
+// copyTree copies the directories, regular files and symbolic links from src to dst, preserving modification times.
+func copyTree(src, dst string) error {
+	//nolint:wrapcheck
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dst, rel)
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			if err := os.MkdirAll(target, fi.Mode().Perm()|0o700); err != nil { //nolint:mnd
+				return err
+			}
+
+		case d.Type()&fs.ModeSymlink != 0:
+			l, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+
+			return os.Symlink(l, target)
+
+		case d.Type().IsRegular():
+			if err := copyFile(path, target, fi.Mode().Perm()); err != nil {
+				return err
+			}
+
+		default:
+			return nil
+		}
+
+		return os.Chtimes(target, fi.ModTime(), fi.ModTime())
+	})
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src) //nolint:gosec
+	if err != nil {
+		return errors.Wrap(err, "unable to open source file")
+	}
+
+	defer in.Close() //nolint:errcheck
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode) //nolint:gosec
+	if err != nil {
+		return errors.Wrap(err, "unable to create file")
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close() //nolint:errcheck
+
+		return errors.Wrap(err, "unable to copy file")
+	}
+
+	return errors.Wrap(out.Close(), "unable to close file")
+}
@@ -0,0 +1,25 @@
+//go:build !windows
+
+package ossnapshot
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+//nolint:gochecknoglobals
+var inodeNumber = func(path string) (uint64, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to stat")
+	}
+
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, errors.Errorf("unable to determine inode number of %v", path)
+	}
+
+	return uint64(st.Ino), nil //nolint:unconvert,nolintlint
+}
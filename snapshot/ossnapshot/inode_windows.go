@@ -0,0 +1,10 @@
+package ossnapshot
+
+import (
+	"github.com/pkg/errors"
+)
+
+//nolint:gochecknoglobals
+var inodeNumber = func(string) (uint64, error) {
+	return 0, errors.New("not supported on this platform")
+}
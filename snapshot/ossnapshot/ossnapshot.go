@@ -0,0 +1,181 @@
+// Package ossnapshot creates read-only file system snapshots used as consistent sources of uploads.
+package ossnapshot
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/logging"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+var log = logging.Module("ossnapshot")
+
+// Provider creates read-only snapshots of local file systems.
+type Provider interface {
+	// Name returns the name of the provider.
+	Name() string
+
+	// Create creates a read-only snapshot of the file system containing the provided path.
+	Create(ctx context.Context, path string) (*Snapshot, error)
+}
+
+// Snapshot is a read-only file system snapshot created by a Provider.
+type Snapshot struct {
+	// Path is the location of the snapshotted path within the snapshot.
+	Path string
+
+	remove func(ctx context.Context) error
+}
+
+// Remove removes the snapshot.
+func (s *Snapshot) Remove(ctx context.Context) error {
+	return s.remove(ctx)
+}
+
+// Runner executes the command with the provided additional environment variables and returns its standard output.
+type Runner func(ctx context.Context, env []string, name string, args ...string) ([]byte, error)
+
+// ProviderFactory creates a Provider for the provided policy, which executes commands using the provided runner.
+type ProviderFactory func(p *policy.FilesystemSnapshotPolicy, run Runner) (Provider, error)
+
+//nolint:gochecknoglobals
+var (
+	providersMutex sync.Mutex
+	providers      = map[string]ProviderFactory{}
+)
+
+// RegisterProvider registers the provider factory with a given name used in FilesystemSnapshotPolicy.Provider.
+func RegisterProvider(name string, factory ProviderFactory) {
+	providersMutex.Lock()
+	defer providersMutex.Unlock()
+
+	providers[name] = factory
+}
+
+// NewProvider returns the Provider selected by the policy.
+func NewProvider(p *policy.FilesystemSnapshotPolicy) (Provider, error) {
+	return NewProviderWithRunner(p, execRunner)
+}
+
+// NewProviderWithRunner returns the Provider selected by the policy, which executes commands using the provided runner.
+func NewProviderWithRunner(p *policy.FilesystemSnapshotPolicy, run Runner) (Provider, error) {
+	providersMutex.Lock()
+	factory := providers[p.ProviderOrDefault()]
+	providersMutex.Unlock()
+
+	if factory == nil {
+		return nil, errors.Errorf("unknown file system snapshot provider %q", p.ProviderOrDefault())
+	}
+
+	return factory(p, run)
+}
+
+func execRunner(ctx context.Context, env []string, name string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+
+	c := exec.CommandContext(ctx, name, args...)
+	c.Env = append(os.Environ(), env...)
+	c.Stderr = &stderr
+
+	log(ctx).Debugf("running %v %v", name, strings.Join(args, " "))
+
+	out, err := c.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "error running %v: %v", name, strings.TrimSpace(stderr.String()))
+	}
+
+	return out, nil
+}
+
+// newSnapshotName returns a unique name of a snapshot.
+func newSnapshotName() (string, error) {
+	var b [8]byte
+
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", errors.Wrap(err, "error reading random bytes")
+	}
+
+	return "kopia-snapshot-" + hex.EncodeToString(b[:]), nil
+}
+
+// pathWithin returns the location of the path under the root after the root is mapped to newRoot.
+func pathWithin(root, path, newRoot string) (string, error) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errors.Errorf("%v is not within %v", path, root)
+	}
+
+	return filepath.Join(newRoot, rel), nil
+}
+
+// mountInfo describes the file system mounted at a given location.
+type mountInfo struct {
+	Target string
+	FSType string
+	Source string
+}
+
+var findmntPairRegexp = regexp.MustCompile(`([A-Z]+)="([^"]*)"`)
+
+// findMount returns information about the file system containing the provided path.
+func findMount(ctx context.Context, run Runner, path string) (mountInfo, error) {
+	out, err := run(ctx, nil, "findmnt", "--noheadings", "--pairs", "--output", "TARGET,FSTYPE,SOURCE", "--target", path)
+	if err != nil {
+		return mountInfo{}, errors.Wrapf(err, "unable to find file system of %v", path)
+	}
+
+	var mi mountInfo
+
+	for _, m := range findmntPairRegexp.FindAllStringSubmatch(string(out), -1) {
+		v := unescapeFindmnt(m[2])
+
+		switch m[1] {
+		case "TARGET":
+			mi.Target = v
+		case "FSTYPE":
+			mi.FSType = v
+		case "SOURCE":
+			mi.Source = v
+		}
+	}
+
+	if mi.Target == "" || mi.FSType == "" {
+		return mountInfo{}, errors.Errorf("unexpected findmnt output: %q", strings.TrimSpace(string(out)))
+	}
+
+	return mi, nil
+}
+
+var findmntEscapeRegexp = regexp.MustCompile(`\\x[0-9a-fA-F]{2}`)
+
+// unescapeFindmnt decodes \xNN sequences used by findmnt to escape special characters.
+func unescapeFindmnt(s string) string {
+	return findmntEscapeRegexp.ReplaceAllStringFunc(s, func(m string) string {
+		v, err := strconv.ParseUint(m[2:], 16, 8)
+		if err != nil {
+			return m
+		}
+
+		return string([]byte{byte(v)})
+	})
+}
+
+func init() {
+	RegisterProvider(policy.FilesystemSnapshotProviderAuto, newAutoProvider)
+	RegisterProvider(policy.FilesystemSnapshotProviderBtrfs, newBtrfsProvider)
+	RegisterProvider(policy.FilesystemSnapshotProviderLVM, newLVMProvider)
+	RegisterProvider(policy.FilesystemSnapshotProviderZFS, newZFSProvider)
+	RegisterProvider(policy.FilesystemSnapshotProviderCommand, newCommandProvider)
+}
@@ -0,0 +1,300 @@
+package ossnapshot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+// fakeRunner records executed commands and returns canned outputs keyed by command name.
+type fakeRunner struct {
+	outputs  map[string]string
+	failures map[string]error
+	commands []string
+	envs     [][]string
+}
+
+func (r *fakeRunner) run(_ context.Context, env []string, name string, args ...string) ([]byte, error) {
+	r.commands = append(r.commands, strings.Join(append([]string{name}, args...), " "))
+	r.envs = append(r.envs, env)
+
+	if err := r.failures[name]; err != nil {
+		return nil, err
+	}
+
+	return []byte(r.outputs[name]), nil
+}
+
+func TestZFSProvider(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	r := &fakeRunner{
+		outputs: map[string]string{
+			"findmnt": `TARGET="/tank/home" FSTYPE="zfs" SOURCE="tank/home"` + "\n",
+		},
+	}
+
+	prov, err := NewProviderWithRunner(&policy.FilesystemSnapshotPolicy{Provider: policy.FilesystemSnapshotProviderZFS}, r.run)
+	require.NoError(t, err)
+
+	snap, err := prov.Create(ctx, "/tank/home/user/docs")
+	require.NoError(t, err)
+
+	require.Len(t, r.commands, 2)
+
+	snapshotName := strings.TrimPrefix(r.commands[1], "zfs snapshot tank/home@")
+	require.NotEqual(t, r.commands[1], snapshotName)
+	require.Equal(t, filepath.Join("/tank/home/.zfs/snapshot", snapshotName, "user/docs"), snap.Path)
+
+	require.NoError(t, snap.Remove(ctx))
+	require.Equal(t, "zfs destroy tank/home@"+snapshotName, r.commands[2])
+}
+
+func TestZFSProvider_WrongFilesystem(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	r := &fakeRunner{
+		outputs: map[string]string{
+			"findmnt": `TARGET="/" FSTYPE="ext4" SOURCE="/dev/sda1"`,
+		},
+	}
+
+	prov, err := NewProviderWithRunner(&policy.FilesystemSnapshotPolicy{Provider: policy.FilesystemSnapshotProviderZFS}, r.run)
+	require.NoError(t, err)
+
+	_, err = prov.Create(ctx, "/home")
+	require.ErrorContains(t, err, "not on a ZFS dataset")
+	require.Len(t, r.commands, 1)
+}
+
+func TestLVMProvider(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	r := &fakeRunner{
+		outputs: map[string]string{
+			"findmnt": `TARGET="/mnt/my\x20data" FSTYPE="xfs" SOURCE="/dev/mapper/vg0-data"`,
+			"lvs":     "  vg0|data|pool0\n",
+		},
+	}
+
+	prov, err := NewProviderWithRunner(&policy.FilesystemSnapshotPolicy{Provider: policy.FilesystemSnapshotProviderLVM}, r.run)
+	require.NoError(t, err)
+
+	snap, err := prov.Create(ctx, "/mnt/my data/projects")
+	require.NoError(t, err)
+
+	require.Len(t, r.commands, 4)
+	require.Equal(t, "lvs --noheadings --separator | --options vg_name,lv_name,pool_lv /dev/mapper/vg0-data", r.commands[1])
+	require.True(t, strings.HasPrefix(r.commands[2], "lvcreate --snapshot --setactivationskip n --name kopia-snapshot-"), r.commands[2])
+	require.True(t, strings.HasPrefix(r.commands[3], "mount -o ro,nouuid /dev/vg0/kopia-snapshot-"), r.commands[3])
+
+	mountDir := filepath.Dir(snap.Path)
+	require.Equal(t, "projects", filepath.Base(snap.Path))
+	require.DirExists(t, mountDir)
+
+	require.NoError(t, snap.Remove(ctx))
+	require.Len(t, r.commands, 6)
+	require.Equal(t, "umount "+mountDir, r.commands[4])
+	require.True(t, strings.HasPrefix(r.commands[5], "lvremove --force vg0/kopia-snapshot-"), r.commands[5])
+	require.NoDirExists(t, mountDir)
+}
+
+func TestLVMProvider_NotThin(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	r := &fakeRunner{
+		outputs: map[string]string{
+			"findmnt": `TARGET="/data" FSTYPE="ext4" SOURCE="/dev/mapper/vg0-data"`,
+			"lvs":     "  vg0|data|\n",
+		},
+	}
+
+	prov, err := NewProviderWithRunner(&policy.FilesystemSnapshotPolicy{Provider: policy.FilesystemSnapshotProviderLVM}, r.run)
+	require.NoError(t, err)
+
+	_, err = prov.Create(ctx, "/data")
+	require.ErrorContains(t, err, "not a thin volume")
+	require.Len(t, r.commands, 2)
+}
+
+func TestLVMProvider_MountFailureCleansUp(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	r := &fakeRunner{
+		outputs: map[string]string{
+			"findmnt": `TARGET="/data" FSTYPE="ext4" SOURCE="/dev/mapper/vg0-data"`,
+			"lvs":     "vg0|data|pool0",
+		},
+		failures: map[string]error{
+			"mount": errors.New("mount failed"),
+		},
+	}
+
+	prov, err := NewProviderWithRunner(&policy.FilesystemSnapshotPolicy{Provider: policy.FilesystemSnapshotProviderLVM}, r.run)
+	require.NoError(t, err)
+
+	_, err = prov.Create(ctx, "/data")
+	require.ErrorContains(t, err, "mount failed")
+
+	// the logical volume must be removed, but there is nothing to unmount.
+	require.Len(t, r.commands, 5)
+	require.True(t, strings.HasPrefix(r.commands[4], "lvremove --force vg0/kopia-snapshot-"), r.commands[4])
+}
+
+func TestBtrfsProvider(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	root := t.TempDir()
+	path := filepath.Join(root, "a", "b")
+
+	require.NoError(t, os.MkdirAll(path, 0o755))
+
+	old := inodeNumber
+
+	t.Cleanup(func() { inodeNumber = old })
+
+	inodeNumber = func(p string) (uint64, error) {
+		if p == root {
+			return btrfsSubvolumeRootInode, nil
+		}
+
+		return 1000, nil
+	}
+
+	r := &fakeRunner{}
+
+	prov, err := NewProviderWithRunner(&policy.FilesystemSnapshotPolicy{Provider: policy.FilesystemSnapshotProviderBtrfs}, r.run)
+	require.NoError(t, err)
+
+	snap, err := prov.Create(ctx, path)
+	require.NoError(t, err)
+
+	snapshotDir := filepath.Dir(filepath.Dir(snap.Path))
+	require.Equal(t, root, filepath.Dir(snapshotDir))
+	require.Equal(t, filepath.Join(snapshotDir, "a", "b"), snap.Path)
+	require.Equal(t, []string{"btrfs subvolume snapshot -r " + root + " " + snapshotDir}, r.commands)
+
+	require.NoError(t, snap.Remove(ctx))
+	require.Equal(t, "btrfs subvolume delete "+snapshotDir, r.commands[1])
+}
+
+func TestAutoProvider(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	r := &fakeRunner{
+		outputs: map[string]string{
+			"findmnt": `TARGET="/tank" FSTYPE="zfs" SOURCE="tank"`,
+		},
+	}
+
+	prov, err := NewProviderWithRunner(&policy.FilesystemSnapshotPolicy{}, r.run)
+	require.NoError(t, err)
+	require.Equal(t, policy.FilesystemSnapshotProviderAuto, prov.Name())
+
+	snap, err := prov.Create(ctx, "/tank/x")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(snap.Path, "/tank/.zfs/snapshot/kopia-snapshot-"), snap.Path)
+
+	r.outputs["findmnt"] = `TARGET="/proc" FSTYPE="proc" SOURCE="proc"`
+
+	_, err = prov.Create(ctx, "/proc/x")
+	require.ErrorContains(t, err, "not supported")
+}
+
+func TestCommandProvider(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	_, err := NewProviderWithRunner(&policy.FilesystemSnapshotPolicy{Provider: policy.FilesystemSnapshotProviderCommand}, nil)
+	require.ErrorContains(t, err, "create command not specified")
+
+	r := &fakeRunner{
+		outputs: map[string]string{
+			"sh": "some output\nKOPIA_SNAPSHOT_PATH=/snapshots/x\n",
+		},
+	}
+
+	prov, err := NewProviderWithRunner(&policy.FilesystemSnapshotPolicy{
+		Provider:      policy.FilesystemSnapshotProviderCommand,
+		CreateCommand: "create-it",
+		RemoveCommand: "remove-it",
+	}, r.run)
+	require.NoError(t, err)
+
+	snap, err := prov.Create(ctx, "/some/path")
+	require.NoError(t, err)
+	require.Equal(t, "/snapshots/x", snap.Path)
+	require.Equal(t, []string{"sh -c create-it"}, r.commands)
+	require.Contains(t, r.envs[0], "KOPIA_SOURCE_PATH=/some/path")
+
+	require.NoError(t, snap.Remove(ctx))
+	require.Equal(t, "sh -c remove-it", r.commands[1])
+	require.Contains(t, r.envs[1], "KOPIA_SNAPSHOT_PATH=/snapshots/x")
+
+	// missing snapshot path fails and runs the remove command.
+	r.outputs["sh"] = "nothing\n"
+
+	_, err = prov.Create(ctx, "/some/path")
+	require.ErrorContains(t, err, "did not print KOPIA_SNAPSHOT_PATH")
+	require.Equal(t, "sh -c remove-it", r.commands[3])
+}
+
+func TestCommandProvider_Exec(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no shell")
+	}
+
+	ctx := testlogging.Context(t)
+	marker := filepath.Join(t.TempDir(), "removed")
+
+	prov, err := NewProvider(&policy.FilesystemSnapshotPolicy{
+		Provider:      policy.FilesystemSnapshotProviderCommand,
+		CreateCommand: `echo "KOPIA_SNAPSHOT_PATH=$KOPIA_SOURCE_PATH/$KOPIA_OS_SNAPSHOT_ID"`,
+		RemoveCommand: `echo "$KOPIA_SNAPSHOT_PATH" > ` + marker,
+	})
+	require.NoError(t, err)
+
+	snap, err := prov.Create(ctx, "/src")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(snap.Path, "/src/kopia-snapshot-"), snap.Path)
+
+	require.NoError(t, snap.Remove(ctx))
+
+	b, err := os.ReadFile(marker)
+	require.NoError(t, err)
+	require.Equal(t, snap.Path+"\n", string(b))
+}
+
+func TestUnknownProvider(t *testing.T) {
+	_, err := NewProvider(&policy.FilesystemSnapshotPolicy{Provider: "no-such-provider"})
+	require.ErrorContains(t, err, "unknown file system snapshot provider")
+}
+
+func TestFakeProvider(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	src := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "d"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "d", "f"), []byte("hello"), 0o600))
+
+	p := &FakeProvider{}
+
+	snap, err := p.Create(ctx, src)
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(filepath.Join(snap.Path, "d", "f"))
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(b))
+	require.Equal(t, []string{snap.Path}, p.Created())
+
+	require.NoError(t, snap.Remove(ctx))
+	require.Equal(t, []string{snap.Path}, p.Removed())
+	require.NoDirExists(t, snap.Path)
+}
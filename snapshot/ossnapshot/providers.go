@@ -0,0 +1,355 @@
+package ossnapshot
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+// btrfsSubvolumeRootInode is the inode number of the root directory of each btrfs subvolume.
+const btrfsSubvolumeRootInode = 256
+
+// autoProvider selects the provider based on the type of the file system.
+type autoProvider struct {
+	p   *policy.FilesystemSnapshotPolicy
+	run Runner
+}
+
+func (p *autoProvider) Name() string {
+	return policy.FilesystemSnapshotProviderAuto
+}
+
+func (p *autoProvider) Create(ctx context.Context, path string) (*Snapshot, error) {
+	mi, err := findMount(ctx, p.run, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var prov Provider
+
+	switch {
+	case mi.FSType == "btrfs":
+		prov = &btrfsProvider{p.run}
+	case mi.FSType == "zfs":
+		prov = &zfsProvider{p.run}
+	case strings.HasPrefix(mi.Source, "/dev/"):
+		prov = &lvmProvider{p.run}
+	default:
+		return nil, errors.Errorf("snapshots of %v file system at %v are not supported", mi.FSType, mi.Target)
+	}
+
+	log(ctx).Debugf("using %v snapshot of %v", prov.Name(), mi.Target)
+
+	return prov.Create(ctx, path)
+}
+
+func newAutoProvider(p *policy.FilesystemSnapshotPolicy, run Runner) (Provider, error) {
+	return &autoProvider{p, run}, nil
+}
+
+// btrfsProvider creates read-only snapshots of btrfs subvolumes, which are placed in the root of the subvolume.
+type btrfsProvider struct {
+	run Runner
+}
+
+func (p *btrfsProvider) Name() string {
+	return policy.FilesystemSnapshotProviderBtrfs
+}
+
+func (p *btrfsProvider) Create(ctx context.Context, path string) (*Snapshot, error) {
+	root, err := btrfsSubvolumeRoot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := newSnapshotName()
+	if err != nil {
+		return nil, err
+	}
+
+	// the snapshot does not include itself, because snapshots do not descend into nested subvolumes.
+	snapshotDir := filepath.Join(root, "."+name)
+
+	newPath, err := pathWithin(root, path, snapshotDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := p.run(ctx, nil, "btrfs", "subvolume", "snapshot", "-r", root, snapshotDir); err != nil {
+		return nil, errors.Wrapf(err, "unable to create snapshot of subvolume %v", root)
+	}
+
+	return &Snapshot{
+		Path: newPath,
+		remove: func(ctx context.Context) error {
+			_, err := p.run(ctx, nil, "btrfs", "subvolume", "delete", snapshotDir)
+
+			return errors.Wrapf(err, "unable to delete snapshot %v", snapshotDir)
+		},
+	}, nil
+}
+
+// btrfsSubvolumeRoot returns the root directory of the subvolume containing the provided path.
+func btrfsSubvolumeRoot(path string) (string, error) {
+	for dir := filepath.Clean(path); ; dir = filepath.Dir(dir) {
+		ino, err := inodeNumber(dir)
+		if err != nil {
+			return "", errors.Wrap(err, "unable to determine subvolume")
+		}
+
+		if ino == btrfsSubvolumeRootInode {
+			return dir, nil
+		}
+
+		if filepath.Dir(dir) == dir {
+			return "", errors.Errorf("%v is not within a btrfs subvolume", path)
+		}
+	}
+}
+
+func newBtrfsProvider(_ *policy.FilesystemSnapshotPolicy, run Runner) (Provider, error) {
+	return &btrfsProvider{run}, nil
+}
+
+// lvmProvider creates snapshots of LVM thin volumes and mounts them read-only in a temporary directory.
+type lvmProvider struct {
+	run Runner
+}
+
+func (p *lvmProvider) Name() string {
+	return policy.FilesystemSnapshotProviderLVM
+}
+
+func (p *lvmProvider) Create(ctx context.Context, path string) (snap *Snapshot, err error) {
+	mi, err := findMount(ctx, p.run, path)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := p.run(ctx, nil, "lvs", "--noheadings", "--separator", "|", "--options", "vg_name,lv_name,pool_lv", mi.Source)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%v is not a logical volume", mi.Source)
+	}
+
+	parts := strings.Split(strings.TrimSpace(string(out)), "|")
+	if len(parts) != 3 { //nolint:mnd
+		return nil, errors.Errorf("unexpected lvs output: %q", strings.TrimSpace(string(out)))
+	}
+
+	vg, lv, pool := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), strings.TrimSpace(parts[2])
+	if pool == "" {
+		return nil, errors.Errorf("%v/%v is not a thin volume", vg, lv)
+	}
+
+	name, err := newSnapshotName()
+	if err != nil {
+		return nil, err
+	}
+
+	var cleanups cleanupStack
+
+	defer func() {
+		if err != nil {
+			cleanups.run(ctx) //nolint:errcheck
+		}
+	}()
+
+	if _, err := p.run(ctx, nil, "lvcreate", "--snapshot", "--setactivationskip", "n", "--name", name, vg+"/"+lv); err != nil {
+		return nil, errors.Wrapf(err, "unable to create snapshot of %v/%v", vg, lv)
+	}
+
+	cleanups.push(func(ctx context.Context) error {
+		_, err := p.run(ctx, nil, "lvremove", "--force", vg+"/"+name)
+
+		return errors.Wrapf(err, "unable to remove snapshot %v/%v", vg, name)
+	})
+
+	mountDir, err := os.MkdirTemp("", "kopia-lvm-snapshot")
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create mount point")
+	}
+
+	cleanups.push(func(_ context.Context) error {
+		return errors.Wrap(os.Remove(mountDir), "unable to remove mount point")
+	})
+
+	mountOptions := "ro"
+	if mi.FSType == "xfs" {
+		// XFS refuses to mount file systems with duplicate UUIDs.
+		mountOptions += ",nouuid"
+	}
+
+	if _, err := p.run(ctx, nil, "mount", "-o", mountOptions, "/dev/"+vg+"/"+name, mountDir); err != nil {
+		return nil, errors.Wrapf(err, "unable to mount snapshot %v/%v", vg, name)
+	}
+
+	cleanups.push(func(ctx context.Context) error {
+		_, err := p.run(ctx, nil, "umount", mountDir)
+
+		return errors.Wrapf(err, "unable to unmount %v", mountDir)
+	})
+
+	newPath, err := pathWithin(mi.Target, path, mountDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Snapshot{Path: newPath, remove: cleanups.run}, nil
+}
+
+func newLVMProvider(_ *policy.FilesystemSnapshotPolicy, run Runner) (Provider, error) {
+	return &lvmProvider{run}, nil
+}
+
+// zfsProvider creates ZFS snapshots, which are accessed through the .zfs directory of the dataset.
+type zfsProvider struct {
+	run Runner
+}
+
+func (p *zfsProvider) Name() string {
+	return policy.FilesystemSnapshotProviderZFS
+}
+
+func (p *zfsProvider) Create(ctx context.Context, path string) (*Snapshot, error) {
+	mi, err := findMount(ctx, p.run, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if mi.FSType != "zfs" {
+		return nil, errors.Errorf("%v is not on a ZFS dataset", path)
+	}
+
+	name, err := newSnapshotName()
+	if err != nil {
+		return nil, err
+	}
+
+	newPath, err := pathWithin(mi.Target, path, filepath.Join(mi.Target, ".zfs", "snapshot", name))
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotName := mi.Source + "@" + name
+
+	if _, err := p.run(ctx, nil, "zfs", "snapshot", snapshotName); err != nil {
+		return nil, errors.Wrapf(err, "unable to create snapshot of %v", mi.Source)
+	}
+
+	return &Snapshot{
+		Path: newPath,
+		remove: func(ctx context.Context) error {
+			_, err := p.run(ctx, nil, "zfs", "destroy", snapshotName)
+
+			return errors.Wrapf(err, "unable to destroy snapshot %v", snapshotName)
+		},
+	}, nil
+}
+
+func newZFSProvider(_ *policy.FilesystemSnapshotPolicy, run Runner) (Provider, error) {
+	return &zfsProvider{run}, nil
+}
+
+// commandProvider creates snapshots using external commands.
+//
+// The create command receives KOPIA_SOURCE_PATH and KOPIA_OS_SNAPSHOT_ID environment variables and must print
+// KOPIA_SNAPSHOT_PATH=<path> with the location of the source path within the snapshot. The remove command
+// receives the same variables along with KOPIA_SNAPSHOT_PATH.
+type commandProvider struct {
+	createCommand string
+	removeCommand string
+	run           Runner
+}
+
+func (p *commandProvider) Name() string {
+	return policy.FilesystemSnapshotProviderCommand
+}
+
+func (p *commandProvider) Create(ctx context.Context, path string) (*Snapshot, error) {
+	id, err := newSnapshotName()
+	if err != nil {
+		return nil, err
+	}
+
+	env := []string{
+		"KOPIA_SOURCE_PATH=" + path,
+		"KOPIA_OS_SNAPSHOT_ID=" + id,
+	}
+
+	out, err := p.run(ctx, env, "sh", "-c", p.createCommand)
+	if err != nil {
+		return nil, errors.Wrap(err, "error running snapshot create command")
+	}
+
+	var snapshotPath string
+
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		if v, ok := strings.CutPrefix(s.Text(), "KOPIA_SNAPSHOT_PATH="); ok {
+			snapshotPath = strings.TrimSpace(v)
+		}
+	}
+
+	snap := &Snapshot{
+		Path: snapshotPath,
+		remove: func(ctx context.Context) error {
+			if p.removeCommand == "" {
+				return nil
+			}
+
+			_, err := p.run(ctx, append(env, "KOPIA_SNAPSHOT_PATH="+snapshotPath), "sh", "-c", p.removeCommand)
+
+			return errors.Wrap(err, "error running snapshot remove command")
+		},
+	}
+
+	if snapshotPath == "" {
+		// the command may have created something, give it a chance to clean up.
+		if err := snap.Remove(ctx); err != nil {
+			log(ctx).Errorf("unable to remove snapshot: %v", err)
+		}
+
+		return nil, errors.New("snapshot create command did not print KOPIA_SNAPSHOT_PATH")
+	}
+
+	return snap, nil
+}
+
+func newCommandProvider(p *policy.FilesystemSnapshotPolicy, run Runner) (Provider, error) {
+	if p.CreateCommand == "" {
+		return nil, errors.New("snapshot create command not specified")
+	}
+
+	return &commandProvider{p.CreateCommand, p.RemoveCommand, run}, nil
+}
+
+// cleanupStack runs cleanup functions in reverse order of registration.
+type cleanupStack []func(ctx context.Context) error
+
+func (s *cleanupStack) push(f func(ctx context.Context) error) {
+	*s = append(*s, f)
+}
+
+// run runs all cleanup functions, even if some of them fail, and returns the first error.
+func (s cleanupStack) run(ctx context.Context) error {
+	var firstErr error
+
+	for i := len(s) - 1; i >= 0; i-- {
+		if err := s[i](ctx); err != nil {
+			log(ctx).Errorf("%v", err)
+
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	return firstErr
+}
@@ -0,0 +1,76 @@
+package policy
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/snapshot"
+)
+
+// Default thresholds of anomaly detection.
+const (
+	DefaultAnomalyMinFiles               = 100
+	DefaultAnomalyModifiedFilesPercent   = 50
+	DefaultAnomalyExtensionChurnPercent  = 20
+	DefaultAnomalyEntropyIncreasePercent = 30
+)
+
+// AnomalyDetectionPolicy describes the detection of mass changes of files compared to the previous snapshot,
+// such as files being encrypted by ransomware.
+type AnomalyDetectionPolicy struct {
+	Enabled *OptionalBool `json:"enabled,omitempty"`
+
+	// minimum number of files found in the previous snapshot for the percentages to be meaningful.
+	MinFiles *OptionalInt `json:"minFiles,omitempty"`
+
+	// thresholds in percent of files found in the previous snapshot.
+	ModifiedFilesPercent   *OptionalInt `json:"modifiedFilesPercent,omitempty"`
+	ExtensionChurnPercent  *OptionalInt `json:"extensionChurnPercent,omitempty"`
+	EntropyIncreasePercent *OptionalInt `json:"entropyIncreasePercent,omitempty"`
+
+	// pin the previous snapshot when an anomaly is detected, so that retention can't expire it.
+	PinPreviousSnapshot *OptionalBool `json:"pinPreviousSnapshot,omitempty"`
+}
+
+// AnomalyDetectionPolicyDefinition specifies which policy definition provided the value of a particular field.
+type AnomalyDetectionPolicyDefinition struct {
+	Enabled                snapshot.SourceInfo `json:"enabled,omitempty"`
+	MinFiles               snapshot.SourceInfo `json:"minFiles,omitempty"`
+	ModifiedFilesPercent   snapshot.SourceInfo `json:"modifiedFilesPercent,omitempty"`
+	ExtensionChurnPercent  snapshot.SourceInfo `json:"extensionChurnPercent,omitempty"`
+	EntropyIncreasePercent snapshot.SourceInfo `json:"entropyIncreasePercent,omitempty"`
+	PinPreviousSnapshot    snapshot.SourceInfo `json:"pinPreviousSnapshot,omitempty"`
+}
+
+// Merge applies default values from the provided policy.
+func (p *AnomalyDetectionPolicy) Merge(src AnomalyDetectionPolicy, def *AnomalyDetectionPolicyDefinition, si snapshot.SourceInfo) {
+	mergeOptionalBool(&p.Enabled, src.Enabled, &def.Enabled, si)
+	mergeOptionalInt(&p.MinFiles, src.MinFiles, &def.MinFiles, si)
+	mergeOptionalInt(&p.ModifiedFilesPercent, src.ModifiedFilesPercent, &def.ModifiedFilesPercent, si)
+	mergeOptionalInt(&p.ExtensionChurnPercent, src.ExtensionChurnPercent, &def.ExtensionChurnPercent, si)
+	mergeOptionalInt(&p.EntropyIncreasePercent, src.EntropyIncreasePercent, &def.EntropyIncreasePercent, si)
+	mergeOptionalBool(&p.PinPreviousSnapshot, src.PinPreviousSnapshot, &def.PinPreviousSnapshot, si)
+}
+
+// ValidateAnomalyDetectionPolicy returns an error if the anomaly detection thresholds are invalid.
+func ValidateAnomalyDetectionPolicy(p AnomalyDetectionPolicy) error {
+	if p.MinFiles != nil && *p.MinFiles < 0 {
+		return errors.New("minimum number of files cannot be negative")
+	}
+
+	thresholds := []struct {
+		desc  string
+		value *OptionalInt
+	}{
+		{"modified files", p.ModifiedFilesPercent},
+		{"extension churn", p.ExtensionChurnPercent},
+		{"entropy increase", p.EntropyIncreasePercent},
+	}
+
+	for _, t := range thresholds {
+		if v := t.value; v != nil && (*v < 1 || *v > 100) { //nolint:mnd
+			return errors.Errorf("%v threshold must be between 1 and 100 percent", t.desc)
+		}
+	}
+
+	return nil
+}
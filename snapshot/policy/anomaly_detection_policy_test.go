@@ -0,0 +1,30 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateAnomalyDetectionPolicy(t *testing.T) {
+	cases := []struct {
+		pol     AnomalyDetectionPolicy
+		wantErr string
+	}{
+		{pol: AnomalyDetectionPolicy{}},
+		{pol: AnomalyDetectionPolicy{MinFiles: newOptionalInt(0), ModifiedFilesPercent: newOptionalInt(1), ExtensionChurnPercent: newOptionalInt(100)}},
+		{pol: AnomalyDetectionPolicy{MinFiles: newOptionalInt(-1)}, wantErr: "minimum number of files cannot be negative"},
+		{pol: AnomalyDetectionPolicy{ModifiedFilesPercent: newOptionalInt(0)}, wantErr: "modified files threshold"},
+		{pol: AnomalyDetectionPolicy{ExtensionChurnPercent: newOptionalInt(101)}, wantErr: "extension churn threshold"},
+		{pol: AnomalyDetectionPolicy{EntropyIncreasePercent: newOptionalInt(-5)}, wantErr: "entropy increase threshold"},
+	}
+
+	for _, tc := range cases {
+		err := ValidateAnomalyDetectionPolicy(tc.pol)
+		if tc.wantErr == "" {
+			require.NoError(t, err)
+		} else {
+			require.ErrorContains(t, err, tc.wantErr)
+		}
+	}
+}
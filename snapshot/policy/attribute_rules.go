@@ -0,0 +1,223 @@
+package policy
+
+import (
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Kinds of attribute rules.
+const (
+	AttributeRuleOlderThan = "older-than" // files not modified within the duration, e.g. "older-than:90d"
+	AttributeRuleSize      = "size"       // files with size in the inclusive range, e.g. "size:1GB-", "size:-1KB", "size:1MB-10MB"
+	AttributeRuleNoDump    = "nodump"     // files and directories with the 'nodump' attribute set with chattr (Linux only)
+	AttributeRuleType      = "type"       // special files of the type: "socket", "fifo" or "device"
+	AttributeRuleUID       = "uid"        // files and directories owned by the numeric user ID, e.g. "uid:1000"
+	AttributeRuleMIME      = "mime"       // files with the sniffed MIME type matching the pattern, e.g. "mime:video/*"
+)
+
+// Special file types matched by AttributeRuleType.
+const (
+	FileTypeSocket = "socket"
+	FileTypeFIFO   = "fifo"
+	FileTypeDevice = "device"
+)
+
+// AttributeRule is a parsed rule excluding entries based on their attributes rather than their names.
+type AttributeRule struct {
+	Kind string
+
+	OlderThan   time.Duration // for AttributeRuleOlderThan
+	MinSize     int64         // for AttributeRuleSize
+	MaxSize     int64         // for AttributeRuleSize, negative if unbounded
+	FileType    string        // for AttributeRuleType
+	UID         uint32        // for AttributeRuleUID
+	MIMEPattern string        // for AttributeRuleMIME
+
+	text string
+}
+
+func (r AttributeRule) String() string {
+	return r.text
+}
+
+// ParseAttributeRule parses the attribute rule in the "kind" or "kind:argument" format.
+func ParseAttributeRule(s string) (AttributeRule, error) {
+	s = strings.TrimSpace(s)
+	kind, arg, hasArg := strings.Cut(s, ":")
+
+	r := AttributeRule{Kind: kind, text: s}
+
+	switch kind {
+	case AttributeRuleNoDump:
+		if hasArg {
+			return AttributeRule{}, errors.Errorf("attribute rule %q does not take an argument", kind)
+		}
+
+		return r, nil
+
+	case AttributeRuleOlderThan, AttributeRuleSize, AttributeRuleType, AttributeRuleUID, AttributeRuleMIME:
+		// parsed below
+
+	default:
+		return AttributeRule{}, errors.Errorf("unsupported attribute rule %q", s)
+	}
+
+	if !hasArg || arg == "" {
+		return AttributeRule{}, errors.Errorf("attribute rule %q requires an argument", kind)
+	}
+
+	var err error
+
+	switch kind {
+	case AttributeRuleOlderThan:
+		r.OlderThan, err = parseRuleDuration(arg)
+
+	case AttributeRuleSize:
+		r.MinSize, r.MaxSize, err = parseSizeRange(arg)
+
+	case AttributeRuleType:
+		switch arg {
+		case FileTypeSocket, FileTypeFIFO, FileTypeDevice:
+			r.FileType = arg
+		default:
+			err = errors.Errorf("unsupported file type %q, must be %q, %q or %q", arg, FileTypeSocket, FileTypeFIFO, FileTypeDevice)
+		}
+
+	case AttributeRuleUID:
+		var v uint64
+
+		v, err = strconv.ParseUint(arg, 10, 32)
+		r.UID = uint32(v)
+
+	case AttributeRuleMIME:
+		r.MIMEPattern = arg
+		_, err = path.Match(arg, "")
+	}
+
+	if err != nil {
+		return AttributeRule{}, errors.Wrapf(err, "invalid attribute rule %q", s)
+	}
+
+	return r, nil
+}
+
+// ParseAttributeRules parses all provided attribute rules.
+func ParseAttributeRules(rules []string) ([]AttributeRule, error) {
+	var result []AttributeRule
+
+	for _, s := range rules {
+		r, err := ParseAttributeRule(s)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, r)
+	}
+
+	return result, nil
+}
+
+// parseRuleDuration parses durations which in addition to time.ParseDuration() may use days and weeks, e.g. "30d" or "2w".
+func parseRuleDuration(s string) (time.Duration, error) {
+	const (
+		day  = 24 * time.Hour
+		week = 7 * day
+	)
+
+	var (
+		d   time.Duration
+		err error
+	)
+
+	if n, ok := strings.CutSuffix(s, "d"); ok {
+		d, err = parseDurationUnits(n, day)
+	} else if n, ok := strings.CutSuffix(s, "w"); ok {
+		d, err = parseDurationUnits(n, week)
+	} else {
+		d, err = time.ParseDuration(s)
+	}
+
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid duration %q", s)
+	}
+
+	if d <= 0 {
+		return 0, errors.Errorf("duration must be positive: %q", s)
+	}
+
+	return d, nil
+}
+
+func parseDurationUnits(n string, unit time.Duration) (time.Duration, error) {
+	v, err := strconv.ParseUint(n, 10, 16)
+	if err != nil {
+		return 0, errors.Wrap(err, "invalid number")
+	}
+
+	return time.Duration(v) * unit, nil
+}
+
+// parseSizeRange parses "min-max" where either bound may be omitted.
+func parseSizeRange(s string) (minSize, maxSize int64, err error) {
+	lo, hi, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, errors.Errorf("size range %q must be in the 'min-max' format, where either bound may be omitted", s)
+	}
+
+	if lo == "" && hi == "" {
+		return 0, 0, errors.Errorf("size range %q must have at least one bound", s)
+	}
+
+	maxSize = -1
+
+	if lo != "" {
+		if minSize, err = parseRuleSize(lo); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if hi != "" {
+		if maxSize, err = parseRuleSize(hi); err != nil {
+			return 0, 0, err
+		}
+
+		if maxSize < minSize {
+			return 0, 0, errors.Errorf("size range %q is empty", s)
+		}
+	}
+
+	return minSize, maxSize, nil
+}
+
+// parseRuleSize parses the size with an optional decimal (KB, MB, GB, TB) or binary (KiB, MiB, GiB, TiB) unit suffix.
+func parseRuleSize(s string) (int64, error) {
+	units := []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+		{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+		{"B", 1},
+	}
+
+	n := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+
+	for _, u := range units {
+		if rest, ok := strings.CutSuffix(n, strings.ToUpper(u.suffix)); ok {
+			n, multiplier = strings.TrimSpace(rest), u.multiplier
+			break
+		}
+	}
+
+	v, err := strconv.ParseInt(n, 10, 64)
+	if err != nil || v < 0 {
+		return 0, errors.Errorf("invalid size %q", s)
+	}
+
+	return v * multiplier, nil
+}
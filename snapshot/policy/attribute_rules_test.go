@@ -0,0 +1,61 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAttributeRule(t *testing.T) {
+	cases := []struct {
+		input string
+		want  AttributeRule
+	}{
+		{"older-than:30d", AttributeRule{Kind: AttributeRuleOlderThan, OlderThan: 30 * 24 * time.Hour}},
+		{"older-than:2w", AttributeRule{Kind: AttributeRuleOlderThan, OlderThan: 14 * 24 * time.Hour}},
+		{"older-than:36h", AttributeRule{Kind: AttributeRuleOlderThan, OlderThan: 36 * time.Hour}},
+		{"size:1GB-", AttributeRule{Kind: AttributeRuleSize, MinSize: 1e9, MaxSize: -1}},
+		{"size:-1KiB", AttributeRule{Kind: AttributeRuleSize, MinSize: 0, MaxSize: 1024}},
+		{"size:1mb-10MB", AttributeRule{Kind: AttributeRuleSize, MinSize: 1e6, MaxSize: 1e7}},
+		{"size:0-0", AttributeRule{Kind: AttributeRuleSize, MinSize: 0, MaxSize: 0}},
+		{" nodump ", AttributeRule{Kind: AttributeRuleNoDump}},
+		{"type:fifo", AttributeRule{Kind: AttributeRuleType, FileType: FileTypeFIFO}},
+		{"uid:1000", AttributeRule{Kind: AttributeRuleUID, UID: 1000}},
+		{"mime:video/*", AttributeRule{Kind: AttributeRuleMIME, MIMEPattern: "video/*"}},
+	}
+
+	for _, tc := range cases {
+		got, err := ParseAttributeRule(tc.input)
+		require.NoError(t, err, tc.input)
+
+		tc.want.text = got.text
+		require.Equal(t, tc.want, got, tc.input)
+	}
+
+	for _, invalid := range []string{
+		"",
+		"unknown",
+		"older-than",
+		"older-than:0d",
+		"older-than:xd",
+		"older-than:-5h",
+		"size:100",
+		"size:-",
+		"size:10KB-1KB",
+		"size:1XB-",
+		"nodump:1",
+		"type:pipe",
+		"uid:-1",
+		"uid:root",
+		"mime:[",
+	} {
+		_, err := ParseAttributeRule(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestValidateFilesPolicy_AttributeRules(t *testing.T) {
+	require.NoError(t, ValidateFilesPolicy(FilesPolicy{AttributeRules: []string{"nodump", "size:1GB-"}}))
+	require.ErrorContains(t, ValidateFilesPolicy(FilesPolicy{AttributeRules: []string{"nodump", "bad"}}), `unsupported attribute rule "bad"`)
+}
@@ -1,6 +1,13 @@
 package policy
 
-import "github.com/kopia/kopia/snapshot"
+import (
+	"path"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/wcmatch"
+	"github.com/kopia/kopia/snapshot"
+)
 
 // FilesPolicy describes files to be ignored when taking snapshots.
 type FilesPolicy struct {
@@ -11,6 +18,10 @@ type FilesPolicy struct {
 	IgnoreCacheDirectories *OptionalBool `json:"ignoreCacheDirs,omitempty"`
 	MaxFileSize            int64         `json:"maxFileSize,omitempty"`
 	OneFileSystem          *OptionalBool `json:"oneFileSystem,omitempty"`
+	ExpandArchives         []string      `json:"expandArchives,omitempty"`
+	GitIgnore              *OptionalBool `json:"gitIgnore,omitempty"`
+	IncludeRules           []string      `json:"include,omitempty"`
+	AttributeRules         []string      `json:"attributeRules,omitempty"`
 }
 
 // FilesPolicyDefinition specifies which policy definition provided the value of a particular field.
@@ -22,6 +33,10 @@ type FilesPolicyDefinition struct {
 	IgnoreCacheDirectories snapshot.SourceInfo `json:"ignoreCacheDirs,omitempty"`
 	MaxFileSize            snapshot.SourceInfo `json:"maxFileSize,omitempty"`
 	OneFileSystem          snapshot.SourceInfo `json:"oneFileSystem,omitempty"`
+	ExpandArchives         snapshot.SourceInfo `json:"expandArchives,omitempty"`
+	GitIgnore              snapshot.SourceInfo `json:"gitIgnore,omitempty"`
+	IncludeRules           snapshot.SourceInfo `json:"include,omitempty"`
+	AttributeRules         snapshot.SourceInfo `json:"attributeRules,omitempty"`
 }
 
 // Merge applies default values from the provided policy.
@@ -33,4 +48,40 @@ func (p *FilesPolicy) Merge(src FilesPolicy, def *FilesPolicyDefinition, si snap
 	mergeOptionalBool(&p.IgnoreCacheDirectories, src.IgnoreCacheDirectories, &def.IgnoreCacheDirectories, si)
 	mergeInt64(&p.MaxFileSize, src.MaxFileSize, &def.MaxFileSize, si)
 	mergeOptionalBool(&p.OneFileSystem, src.OneFileSystem, &def.OneFileSystem, si)
+	mergeStringsReplace(&p.ExpandArchives, src.ExpandArchives, &def.ExpandArchives, si)
+	mergeOptionalBool(&p.GitIgnore, src.GitIgnore, &def.GitIgnore, si)
+	mergeStringList(&p.IncludeRules, src.IncludeRules, &def.IncludeRules, si)
+	mergeStringList(&p.AttributeRules, src.AttributeRules, &def.AttributeRules, si)
+}
+
+// ShouldExpandArchive returns true if the file with a given name should be snapshotted as a tree of archive members.
+func (p *FilesPolicy) ShouldExpandArchive(name string) bool {
+	for _, pattern := range p.ExpandArchives {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidateFilesPolicy returns an error if the files policy is invalid.
+func ValidateFilesPolicy(p FilesPolicy) error {
+	for _, pattern := range p.ExpandArchives {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.Wrapf(err, "invalid archive pattern %q", pattern)
+		}
+	}
+
+	for _, rule := range p.IncludeRules {
+		if _, err := wcmatch.NewWildcardMatcher(rule); err != nil {
+			return errors.Wrapf(err, "invalid include rule %q", rule)
+		}
+	}
+
+	if _, err := ParseAttributeRules(p.AttributeRules); err != nil {
+		return err
+	}
+
+	return nil
 }
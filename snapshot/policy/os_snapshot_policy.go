@@ -4,17 +4,20 @@
 
 // OSSnapshotPolicy describes settings for OS-level snapshots.
 type OSSnapshotPolicy struct {
-	VolumeShadowCopy VolumeShadowCopyPolicy `json:"volumeShadowCopy,omitempty"`
+	VolumeShadowCopy   VolumeShadowCopyPolicy   `json:"volumeShadowCopy,omitempty"`
+	FilesystemSnapshot FilesystemSnapshotPolicy `json:"filesystemSnapshot,omitempty"`
 }
 
 // OSSnapshotPolicyDefinition specifies which policy definition provided the value of a particular field.
 type OSSnapshotPolicyDefinition struct {
-	VolumeShadowCopy VolumeShadowCopyPolicyDefinition `json:"volumeShadowCopy,omitempty"`
+	VolumeShadowCopy   VolumeShadowCopyPolicyDefinition   `json:"volumeShadowCopy,omitempty"`
+	FilesystemSnapshot FilesystemSnapshotPolicyDefinition `json:"filesystemSnapshot,omitempty"`
 }
 
 // Merge applies default values from the provided policy.
 func (p *OSSnapshotPolicy) Merge(src OSSnapshotPolicy, def *OSSnapshotPolicyDefinition, si snapshot.SourceInfo) {
 	p.VolumeShadowCopy.Merge(src.VolumeShadowCopy, &def.VolumeShadowCopy, si)
+	p.FilesystemSnapshot.Merge(src.FilesystemSnapshot, &def.FilesystemSnapshot, si)
 }
 
 // VolumeShadowCopyPolicy describes settings for Windows Volume Shadow Copy
@@ -34,6 +37,52 @@ func (p *VolumeShadowCopyPolicy) Merge(src VolumeShadowCopyPolicy, def *VolumeSh
 	mergeOSSnapshotMode(&p.Enable, src.Enable, &def.Enable, si)
 }
 
+// Filesystem snapshot providers.
+const (
+	FilesystemSnapshotProviderAuto    = "auto"
+	FilesystemSnapshotProviderBtrfs   = "btrfs"
+	FilesystemSnapshotProviderLVM     = "lvm"
+	FilesystemSnapshotProviderZFS     = "zfs"
+	FilesystemSnapshotProviderCommand = "command"
+)
+
+// FilesystemSnapshotPolicy describes settings for file system snapshots on Linux,
+// which are created using btrfs, LVM thin volumes, ZFS or external commands.
+type FilesystemSnapshotPolicy struct {
+	Enable   *OSSnapshotMode `json:"enable,omitempty"`
+	Provider string          `json:"provider,omitempty"`
+
+	// commands used by the "command" provider.
+	CreateCommand string `json:"createCommand,omitempty"`
+	RemoveCommand string `json:"removeCommand,omitempty"`
+}
+
+// FilesystemSnapshotPolicyDefinition specifies which policy definition provided
+// the value of a particular field.
+type FilesystemSnapshotPolicyDefinition struct {
+	Enable        snapshot.SourceInfo `json:"enable,omitempty"`
+	Provider      snapshot.SourceInfo `json:"provider,omitempty"`
+	CreateCommand snapshot.SourceInfo `json:"createCommand,omitempty"`
+	RemoveCommand snapshot.SourceInfo `json:"removeCommand,omitempty"`
+}
+
+// Merge applies default values from the provided policy.
+func (p *FilesystemSnapshotPolicy) Merge(src FilesystemSnapshotPolicy, def *FilesystemSnapshotPolicyDefinition, si snapshot.SourceInfo) {
+	mergeOSSnapshotMode(&p.Enable, src.Enable, &def.Enable, si)
+	mergeString(&p.Provider, src.Provider, &def.Provider, si)
+	mergeString(&p.CreateCommand, src.CreateCommand, &def.CreateCommand, si)
+	mergeString(&p.RemoveCommand, src.RemoveCommand, &def.RemoveCommand, si)
+}
+
+// ProviderOrDefault returns the name of the provider or "auto" when not set.
+func (p *FilesystemSnapshotPolicy) ProviderOrDefault() string {
+	if p.Provider == "" {
+		return FilesystemSnapshotProviderAuto
+	}
+
+	return p.Provider
+}
+
 // OSSnapshotMode specifies whether OS-level snapshots are used for file systems
 // that support them.
 //
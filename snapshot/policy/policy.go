@@ -36,6 +36,8 @@ type Policy struct {
 	OSSnapshotPolicy          OSSnapshotPolicy          `json:"osSnapshots,omitempty"`
 	LoggingPolicy             LoggingPolicy             `json:"logging,omitempty"`
 	UploadPolicy              UploadPolicy              `json:"upload,omitempty"`
+	SourceCommands            SourceCommandsPolicy      `json:"sourceCommands,omitempty"`
+	AnomalyDetection          AnomalyDetectionPolicy    `json:"anomalyDetection,omitempty"`
 	NoParent                  bool                      `json:"noParent,omitempty"`
 }
 
@@ -53,6 +55,7 @@ type Definition struct {
 	OSSnapshotPolicy          OSSnapshotPolicyDefinition          `json:"osSnapshots,omitempty"`
 	LoggingPolicy             LoggingPolicyDefinition             `json:"logging,omitempty"`
 	UploadPolicy              UploadPolicyDefinition              `json:"upload,omitempty"`
+	AnomalyDetection          AnomalyDetectionPolicyDefinition    `json:"anomalyDetection,omitempty"`
 }
 
 func (p *Policy) String() string {
@@ -83,16 +86,27 @@ func (p *Policy) Target() snapshot.SourceInfo {
 }
 
 // ValidatePolicy returns error if the given policy is invalid.
-// Currently, only SchedulingPolicy is validated.
 func ValidatePolicy(si snapshot.SourceInfo, pol *Policy) error {
 	if err := ValidateSchedulingPolicy(pol.SchedulingPolicy); err != nil {
 		return errors.Wrap(err, "invalid scheduling policy")
 	}
 
+	if err := ValidateFilesPolicy(pol.FilesPolicy); err != nil {
+		return errors.Wrap(err, "invalid files policy")
+	}
+
 	if err := ValidateUploadPolicy(si, pol.UploadPolicy); err != nil {
 		return errors.Wrap(err, "invalid upload policy")
 	}
 
+	if err := ValidateSourceCommandsPolicy(si, pol.SourceCommands); err != nil {
+		return errors.Wrap(err, "invalid source commands policy")
+	}
+
+	if err := ValidateAnomalyDetectionPolicy(pol.AnomalyDetection); err != nil {
+		return errors.Wrap(err, "invalid anomaly detection policy")
+	}
+
 	return nil
 }
 
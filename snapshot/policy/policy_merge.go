@@ -41,6 +41,7 @@ func MergePolicies(policies []*Policy, si snapshot.SourceInfo) (*Policy, *Defini
 		merged.Actions.Merge(p.Actions, &def.Actions, p.Target())
 		merged.OSSnapshotPolicy.Merge(p.OSSnapshotPolicy, &def.OSSnapshotPolicy, p.Target())
 		merged.LoggingPolicy.Merge(p.LoggingPolicy, &def.LoggingPolicy, p.Target())
+		merged.AnomalyDetection.Merge(p.AnomalyDetection, &def.AnomalyDetection, p.Target())
 
 		if p.NoParent {
 			return &merged, &def
@@ -62,6 +63,7 @@ func MergePolicies(policies []*Policy, si snapshot.SourceInfo) (*Policy, *Defini
 
 	if len(policies) > 0 {
 		merged.Actions.MergeNonInheritable(policies[0].Actions)
+		merged.SourceCommands.MergeNonInheritable(policies[0].SourceCommands, policies[0].Target(), si)
 	}
 
 	return &merged, &def
@@ -23,6 +23,7 @@
 	"Definition.Name":                                   true,
 	"Definition.Emoji":                                  true,
 	"Definition.InitialSourceType":                      true,
+	"Definition.SourceCommands":                         true, // non-inheritable field
 }
 
 func TestPolicyDefinition(t *testing.T) {
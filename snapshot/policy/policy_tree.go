@@ -62,6 +62,9 @@
 		VolumeShadowCopy: VolumeShadowCopyPolicy{
 			Enable: NewOSSnapshotMode(OSSnapshotNever),
 		},
+		FilesystemSnapshot: FilesystemSnapshotPolicy{
+			Enable: NewOSSnapshotMode(OSSnapshotNever),
+		},
 	}
 
 	defaultUploadPolicy = UploadPolicy{
@@ -160,6 +163,26 @@ func (t *Tree) Child(name string) *Tree {
 	}
 }
 
+// AnyEffectivePolicy returns true if the provided function returns true for the effective policy
+// of this node or any node below it.
+func (t *Tree) AnyEffectivePolicy(f func(p *Policy) bool) bool {
+	if f(t.EffectivePolicy()) {
+		return true
+	}
+
+	if t == nil {
+		return false
+	}
+
+	for _, ch := range t.children {
+		if ch.AnyEffectivePolicy(f) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // BuildTree builds a policy tree from the given map of paths to policies.
 // Each path must be relative and start with "." and be separated by slashes.
 func BuildTree(defined map[string]*Policy, defaultPolicy *Policy) *Tree {
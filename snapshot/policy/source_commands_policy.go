@@ -0,0 +1,66 @@
+package policy
+
+import (
+	"path"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/snapshot"
+)
+
+// SourceCommandsPolicy describes commands whose output is snapshotted instead of the contents
+// of the source directory (not inherited).
+type SourceCommandsPolicy struct {
+	Commands []SourceCommand `json:"commands,omitempty"`
+}
+
+// SourceCommand configures a command whose standard output is stored as a file in the snapshot.
+type SourceCommand struct {
+	FileName  string   `json:"file"`
+	Command   string   `json:"path"`
+	Arguments []string `json:"args,omitempty"`
+}
+
+// IsCommandSource returns true if the source is defined by commands rather than by a directory.
+func (p *SourceCommandsPolicy) IsCommandSource() bool {
+	return len(p.Commands) > 0
+}
+
+// MergeNonInheritable copies non-inheritable properties from the provided policy, but only
+// when it was defined for the source itself.
+func (p *SourceCommandsPolicy) MergeNonInheritable(src SourceCommandsPolicy, srcTarget, si snapshot.SourceInfo) {
+	if srcTarget == si {
+		p.Commands = src.Commands
+	}
+}
+
+// ValidateSourceCommandsPolicy returns an error if the source commands are not valid.
+func ValidateSourceCommandsPolicy(si snapshot.SourceInfo, p SourceCommandsPolicy) error {
+	if !p.IsCommandSource() {
+		return nil
+	}
+
+	if si.Path == "" {
+		return errors.New("source commands can only be specified for paths")
+	}
+
+	names := map[string]bool{}
+
+	for _, c := range p.Commands {
+		if c.FileName == "" || c.FileName != path.Base(c.FileName) || c.FileName == "." || c.FileName == ".." {
+			return errors.Errorf("invalid file name %q", c.FileName)
+		}
+
+		if names[c.FileName] {
+			return errors.Errorf("duplicate file name %q", c.FileName)
+		}
+
+		names[c.FileName] = true
+
+		if c.Command == "" {
+			return errors.Errorf("missing command for %q", c.FileName)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package policy_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+func TestSourceCommandsNotInherited(t *testing.T) {
+	parent := &policy.Policy{
+		Labels: policy.LabelsForSource(snapshot.SourceInfo{Host: "h", UserName: "u", Path: "/a"}),
+		SourceCommands: policy.SourceCommandsPolicy{
+			Commands: []policy.SourceCommand{{FileName: "parent.sql", Command: "dump"}},
+		},
+	}
+
+	src := snapshot.SourceInfo{Host: "h", UserName: "u", Path: "/a/b"}
+
+	merged, _ := policy.MergePolicies([]*policy.Policy{parent}, src)
+	require.False(t, merged.SourceCommands.IsCommandSource())
+
+	own := &policy.Policy{
+		Labels: policy.LabelsForSource(src),
+		SourceCommands: policy.SourceCommandsPolicy{
+			Commands: []policy.SourceCommand{{FileName: "own.sql", Command: "dump"}},
+		},
+	}
+
+	merged, _ = policy.MergePolicies([]*policy.Policy{own, parent}, src)
+	require.Equal(t, own.SourceCommands, merged.SourceCommands)
+}
+
+func TestValidateSourceCommandsPolicy(t *testing.T) {
+	src := snapshot.SourceInfo{Host: "h", UserName: "u", Path: "/dumps"}
+
+	cases := []struct {
+		si      snapshot.SourceInfo
+		cmds    []policy.SourceCommand
+		wantErr string
+	}{
+		{si: src},
+		{si: src, cmds: []policy.SourceCommand{{FileName: "a.sql", Command: "pg_dump"}, {FileName: "b.xml", Command: "virsh"}}},
+		{si: policy.GlobalPolicySourceInfo, cmds: []policy.SourceCommand{{FileName: "a.sql", Command: "pg_dump"}}, wantErr: "only be specified for paths"},
+		{si: src, cmds: []policy.SourceCommand{{FileName: "", Command: "pg_dump"}}, wantErr: "invalid file name"},
+		{si: src, cmds: []policy.SourceCommand{{FileName: "x/a.sql", Command: "pg_dump"}}, wantErr: "invalid file name"},
+		{si: src, cmds: []policy.SourceCommand{{FileName: "..", Command: "pg_dump"}}, wantErr: "invalid file name"},
+		{si: src, cmds: []policy.SourceCommand{{FileName: "a.sql", Command: "x"}, {FileName: "a.sql", Command: "y"}}, wantErr: "duplicate file name"},
+		{si: src, cmds: []policy.SourceCommand{{FileName: "a.sql"}}, wantErr: "missing command"},
+	}
+
+	for _, tc := range cases {
+		err := policy.ValidateSourceCommandsPolicy(tc.si, policy.SourceCommandsPolicy{Commands: tc.cmds})
+		if tc.wantErr == "" {
+			require.NoError(t, err)
+		} else {
+			require.ErrorContains(t, err, tc.wantErr)
+		}
+	}
+}
@@ -11,6 +11,8 @@ type UploadPolicy struct {
 	MaxParallelSnapshots    *OptionalInt   `json:"maxParallelSnapshots,omitempty"`
 	MaxParallelFileReads    *OptionalInt   `json:"maxParallelFileReads,omitempty"`
 	ParallelUploadAboveSize *OptionalInt64 `json:"parallelUploadAboveSize,omitempty"`
+	BuildSearchIndex        *OptionalBool  `json:"buildSearchIndex,omitempty"`
+	DetectMovedFiles        *OptionalBool  `json:"detectMovedFiles,omitempty"`
 }
 
 // UploadPolicyDefinition specifies which policy definition provided the value of a particular field.
@@ -18,6 +20,8 @@ type UploadPolicyDefinition struct {
 	MaxParallelSnapshots    snapshot.SourceInfo `json:"maxParallelSnapshots,omitempty"`
 	MaxParallelFileReads    snapshot.SourceInfo `json:"maxParallelFileReads,omitempty"`
 	ParallelUploadAboveSize snapshot.SourceInfo `json:"parallelUploadAboveSize,omitempty"`
+	BuildSearchIndex        snapshot.SourceInfo `json:"buildSearchIndex,omitempty"`
+	DetectMovedFiles        snapshot.SourceInfo `json:"detectMovedFiles,omitempty"`
 }
 
 // Merge applies default values from the provided policy.
@@ -25,6 +29,8 @@ func (p *UploadPolicy) Merge(src UploadPolicy, def *UploadPolicyDefinition, si s
 	mergeOptionalInt(&p.MaxParallelSnapshots, src.MaxParallelSnapshots, &def.MaxParallelSnapshots, si)
 	mergeOptionalInt(&p.MaxParallelFileReads, src.MaxParallelFileReads, &def.MaxParallelFileReads, si)
 	mergeOptionalInt64(&p.ParallelUploadAboveSize, src.ParallelUploadAboveSize, &def.ParallelUploadAboveSize, si)
+	mergeOptionalBool(&p.BuildSearchIndex, src.BuildSearchIndex, &def.BuildSearchIndex, si)
+	mergeOptionalBool(&p.DetectMovedFiles, src.DetectMovedFiles, &def.DetectMovedFiles, si)
 }
 
 // ValidateUploadPolicy returns an error if manual field is set along with Upload fields.
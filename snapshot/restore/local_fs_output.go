@@ -174,6 +174,10 @@ func (o *FilesystemOutput) WriteFile(ctx context.Context, relativePath string, f
 	log(ctx).Debugf("WriteFile %v (%v bytes) %v, %v", filepath.Join(o.TargetPath, relativePath), f.Size(), f.Mode(), f.ModTime())
 	path := filepath.Join(o.TargetPath, filepath.FromSlash(relativePath))
 
+	if err := ensureParentDirectory(path); err != nil {
+		return err
+	}
+
 	if err := o.copyFileContent(ctx, path, f, progressCb); err != nil {
 		return errors.Wrap(err, "error creating file")
 	}
@@ -239,6 +243,10 @@ func (o *FilesystemOutput) CreateSymlink(ctx context.Context, relativePath strin
 		return errors.Errorf("unable to create symlink, %q already exists and is not a symlink", path)
 	}
 
+	if err := ensureParentDirectory(path); err != nil {
+		return err
+	}
+
 	if err := os.Symlink(targetPath, path); err != nil {
 		return errors.Wrap(err, "error creating symlink")
 	}
@@ -358,6 +366,18 @@ func isWindows() bool {
 	return runtime.GOOS == "windows"
 }
 
+// ensureParentDirectory creates missing parent directories of the provided path, which is needed
+// when restoring entries into mapped paths.
+func ensureParentDirectory(path string) error {
+	dir := filepath.Dir(path)
+
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		return nil
+	}
+
+	return errors.Wrap(os.MkdirAll(dir, outputDirMode), "error creating parent directory")
+}
+
 func (o *FilesystemOutput) createDirectory(ctx context.Context, path string) error {
 	switch st, err := os.Stat(path); {
 	case os.IsNotExist(err):
@@ -7,6 +7,7 @@
 	"path"
 	"runtime"
 	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -15,6 +16,7 @@
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/logging"
 	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
 )
 
 var log = logging.Module("restore")
@@ -40,6 +42,7 @@ type Stats struct {
 	RestoredTotalFileSize int64
 	EnqueuedTotalFileSize int64
 	SkippedTotalFileSize  int64
+	ResumedTotalFileSize  int64
 
 	RestoredFileCount    int32
 	RestoredDirCount     int32
@@ -52,6 +55,11 @@ type Stats struct {
 	DeletedSymlinkCount  int32
 	DeletedDirCount      int32
 	IgnoredErrorCount    int32
+
+	// ResumedFileCount is the number of files restored by the interrupted restore, which were not rewritten.
+	ResumedFileCount int32
+	// RedoneFileCount is the number of files partially written by the interrupted restore, which were rewritten.
+	RedoneFileCount int32
 }
 
 // stats represents restore statistics.
@@ -59,6 +67,7 @@ type statsInternal struct {
 	RestoredTotalFileSize atomic.Int64
 	EnqueuedTotalFileSize atomic.Int64
 	SkippedTotalFileSize  atomic.Int64
+	ResumedTotalFileSize  atomic.Int64
 
 	RestoredFileCount    atomic.Int32
 	RestoredDirCount     atomic.Int32
@@ -71,6 +80,8 @@ type statsInternal struct {
 	DeletedSymlinkCount  atomic.Int32
 	DeletedDirCount      atomic.Int32
 	IgnoredErrorCount    atomic.Int32
+	ResumedFileCount     atomic.Int32
+	RedoneFileCount      atomic.Int32
 }
 
 func (s *statsInternal) clone() Stats {
@@ -78,6 +89,7 @@ func (s *statsInternal) clone() Stats {
 		RestoredTotalFileSize: s.RestoredTotalFileSize.Load(),
 		EnqueuedTotalFileSize: s.EnqueuedTotalFileSize.Load(),
 		SkippedTotalFileSize:  s.SkippedTotalFileSize.Load(),
+		ResumedTotalFileSize:  s.ResumedTotalFileSize.Load(),
 		RestoredFileCount:     s.RestoredFileCount.Load(),
 		RestoredDirCount:      s.RestoredDirCount.Load(),
 		RestoredSymlinkCount:  s.RestoredSymlinkCount.Load(),
@@ -89,6 +101,8 @@ func (s *statsInternal) clone() Stats {
 		DeletedSymlinkCount:   s.DeletedSymlinkCount.Load(),
 		DeletedDirCount:       s.DeletedDirCount.Load(),
 		IgnoredErrorCount:     s.IgnoredErrorCount.Load(),
+		ResumedFileCount:      s.ResumedFileCount.Load(),
+		RedoneFileCount:       s.RedoneFileCount.Load(),
 	}
 }
 
@@ -106,6 +120,44 @@ type Options struct {
 	RestoreDirEntryAtDepth int32 `json:"restoreDirEntryAtDepth"`
 	MinSizeForPlaceholder  int32 `json:"minSizeForPlaceholder"`
 
+	// PackOrderedReads causes file contents to be fetched in the order in which they are stored in pack blobs,
+	// reading each pack once into a local spool, instead of reading contents of each file separately.
+	// Only supported with direct repository connection.
+	PackOrderedReads bool   `json:"packOrderedReads"`
+	SpoolDirectory   string `json:"spoolDirectory"`
+	MaxSpoolSize     int64  `json:"maxSpoolSize"`
+
+	// IncludePatterns and ExcludePatterns select entries to restore using .gitignore syntax, matched against paths
+	// relative to the restore root. When include patterns are provided, only files matching them or located in
+	// matching directories are restored.
+	IncludePatterns []string `json:"includePatterns,omitempty"`
+	ExcludePatterns []string `json:"excludePatterns,omitempty"`
+
+	// MinFileSize and MaxFileSize limit the size of restored files, zero MaxFileSize means no limit.
+	MinFileSize int64 `json:"minFileSize,omitempty"`
+	MaxFileSize int64 `json:"maxFileSize,omitempty"`
+
+	// ModifiedAfter and ModifiedBefore limit modification times of restored files, zero values mean no limit.
+	ModifiedAfter  time.Time `json:"modifiedAfter,omitzero"`
+	ModifiedBefore time.Time `json:"modifiedBefore,omitzero"`
+
+	// PathMappings, when provided, restores only entries under the provided source paths, placing them
+	// in the corresponding target paths. The most specific mapping applies to each entry.
+	PathMappings []PathMapping `json:"pathMappings,omitempty"`
+
+	// Resumable causes completed files to be recorded in a journal, which allows an interrupted restore
+	// to be resumed by running it again. Files recorded as completed that remain unchanged are not restored
+	// again, other files are written atomically. Only supported by FilesystemOutput.
+	Resumable bool `json:"resumable,omitempty"`
+
+	// JournalPath is the path of the journal of resumable restore, defaults to JournalPath(TargetPath),
+	// which is next to the target directory.
+	JournalPath string `json:"-"`
+
+	// ExtractArchives causes archives which were expanded during snapshot to be restored as directories of their
+	// members instead of their original files. Archives are always extracted when entries are filtered.
+	ExtractArchives bool `json:"extractArchives,omitempty"`
+
 	ProgressCallback ProgressCallback `json:"-"`
 	Cancel           chan struct{}    `json:"-"` // channel that can be externally closed to signal cancellation
 }
@@ -114,7 +166,44 @@ type Options struct {
 //
 //nolint:revive
 func Entry(ctx context.Context, rep repo.Repository, output Output, rootEntry fs.Entry, options Options) (Stats, error) {
+	filter, err := newEntryFilter(options)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	if filter != nil && options.DeleteExtra {
+		return Stats{}, errors.New("deleting extra files cannot be combined with filters or path mappings")
+	}
+
+	var journal *restoreJournal
+
+	if options.Resumable {
+		fso, ok := output.(*FilesystemOutput)
+		if !ok {
+			return Stats{}, errors.New("resumable restore is only supported for filesystem output")
+		}
+
+		journalPath := options.JournalPath
+		if journalPath == "" {
+			journalPath = JournalPath(fso.TargetPath)
+		}
+
+		j, err := openRestoreJournal(ctx, fso.TargetPath, journalPath)
+		if err != nil {
+			return Stats{}, err
+		}
+
+		// files which were not completed may have been partially written, make sure this does not happen again
+		// without changing the output provided by the caller.
+		atomicOutput := *fso
+		atomicOutput.WriteFilesAtomically = true
+
+		output = &atomicOutput
+		journal = j
+	}
+
 	c := copier{
+		filter:           filter,
 		output:           output,
 		shallowoutput:    makeShallowFilesystemOutput(output, options),
 		q:                parallelwork.NewQueue(),
@@ -123,6 +212,8 @@ func Entry(ctx context.Context, rep repo.Repository, output Output, rootEntry fs
 		ignoreErrors:     options.IgnoreErrors,
 		cancel:           options.Cancel,
 		progressCallback: options.ProgressCallback,
+		extractArchives:  options.ExtractArchives || filter != nil,
+		journal:          journal,
 	}
 
 	c.q.ProgressCallback = func(ctx context.Context, enqueued, active, completed int64) {
@@ -132,28 +223,54 @@ func Entry(ctx context.Context, rep repo.Repository, output Output, rootEntry fs
 	// Control the depth of a restore. Default (options.MaxDepth = 0) is to restore to full depth.
 	currentdepth := int32(0)
 
-	c.q.EnqueueFront(ctx, func() error {
-		return errors.Wrap(c.copyEntry(ctx, rootEntry, "", currentdepth, options.RestoreDirEntryAtDepth, func() error { return nil }), "error copying")
-	})
+	if rootLocation, ok := filter.rootLocation(rootEntry); ok {
+		c.q.EnqueueFront(ctx, func() error {
+			return errors.Wrap(c.copyEntry(ctx, rootEntry, rootLocation, currentdepth, options.RestoreDirEntryAtDepth, func() error { return nil }), "error copying")
+		})
+	}
 
 	numWorkers := options.Parallel
 	if numWorkers == 0 {
 		numWorkers = runtime.NumCPU()
 	}
 
+	fetchWorkers := numWorkers
+
 	if !output.Parallelizable() {
 		numWorkers = 1
 	}
 
+	c.planner = newRestorePlanner(rep, options, fetchWorkers, numWorkers)
+
+	if err := c.run(ctx, numWorkers); err != nil {
+		c.closeJournal(ctx, false)
+		return Stats{}, err
+	}
+
+	// the journal is kept when the restore did not complete, so that it can be resumed.
+	c.closeJournal(ctx, !c.isCanceled() && c.stats.IgnoredErrorCount.Load() == 0)
+
+	return c.stats.clone(), nil
+}
+
+func (c *copier) run(ctx context.Context, numWorkers int) error {
 	if err := c.q.Process(ctx, numWorkers); err != nil {
-		return Stats{}, errors.Wrap(err, "restore error")
+		return errors.Wrap(err, "restore error")
 	}
 
-	if err := c.output.Close(ctx); err != nil {
-		return Stats{}, errors.Wrap(err, "error closing output")
+	if c.planner != nil {
+		if err := c.planner.run(ctx); err != nil {
+			return errors.Wrap(err, "restore error")
+		}
 	}
 
-	return c.stats.clone(), nil
+	return errors.Wrap(c.output.Close(ctx), "error closing output")
+}
+
+func (c *copier) closeJournal(ctx context.Context, finished bool) {
+	if c.journal != nil {
+		c.journal.close(ctx, finished)
+	}
 }
 
 type copier struct {
@@ -165,6 +282,11 @@ type copier struct {
 	deleteExtra   bool
 	ignoreErrors  bool
 	cancel        chan struct{}
+	planner       *restorePlanner
+	filter        *entryFilter
+	journal       *restoreJournal
+
+	extractArchives bool
 
 	progressCallback ProgressCallback
 }
@@ -175,13 +297,38 @@ func (c *copier) reportProgress(ctx context.Context) {
 	}
 }
 
-func (c *copier) copyEntry(ctx context.Context, e fs.Entry, targetPath string, currentdepth, maxdepth int32, onCompletion func() error) error {
-	if c.cancel != nil {
-		select {
-		case <-c.cancel:
+func (c *copier) isCanceled() bool {
+	if c.cancel == nil {
+		return false
+	}
+
+	select {
+	case <-c.cancel:
+		return true
+
+	default:
+		return false
+	}
+}
+
+func (c *copier) copyEntry(ctx context.Context, e fs.Entry, loc entryLocation, currentdepth, maxdepth int32, onCompletion func() error) error {
+	targetPath := loc.targetPath
+
+	if c.isCanceled() {
+		return onCompletion()
+	}
+
+	if f, ok := e.(fs.File); ok && c.journal != nil && currentdepth <= maxdepth {
+		if c.journal.isCompleted(targetPath, f) {
+			log(ctx).Debugf("skipping file %v because it has been restored before", targetPath)
+			c.stats.ResumedFileCount.Add(1)
+			c.stats.ResumedTotalFileSize.Add(f.Size())
+
 			return onCompletion()
+		}
 
-		default:
+		if c.journal.exists(targetPath) {
+			c.stats.RedoneFileCount.Add(1)
 		}
 	}
 
@@ -207,7 +354,10 @@ func (c *copier) copyEntry(ctx context.Context, e fs.Entry, targetPath string, c
 		}
 	}
 
-	err := c.copyEntryInternal(ctx, e, targetPath, currentdepth, maxdepth, onCompletion)
+	return c.maybeIgnoreError(ctx, c.copyEntryInternal(ctx, e, loc, currentdepth, maxdepth, onCompletion), targetPath)
+}
+
+func (c *copier) maybeIgnoreError(ctx context.Context, err error, targetPath string) error {
 	if err == nil {
 		return nil
 	}
@@ -222,41 +372,53 @@ func (c *copier) copyEntry(ctx context.Context, e fs.Entry, targetPath string, c
 	return err
 }
 
-func (c *copier) copyEntryInternal(ctx context.Context, e fs.Entry, targetPath string, currentdepth, maxdepth int32, onCompletion func() error) error {
+func (c *copier) copyEntryInternal(ctx context.Context, e fs.Entry, loc entryLocation, currentdepth, maxdepth int32, onCompletion func() error) error {
+	targetPath := loc.targetPath
+
 	switch e := e.(type) {
 	case fs.Directory:
+		if af := c.archiveFileToRestore(e, loc, currentdepth, maxdepth); af != nil {
+			log(ctx).Debugf("archive: '%v'", targetPath)
+			return c.writeFile(ctx, c.output, loc, af, onCompletion)
+		}
+
 		log(ctx).Debugf("dir: '%v'", targetPath)
-		return c.copyDirectory(ctx, e, targetPath, currentdepth, maxdepth, onCompletion)
+
+		return c.copyDirectory(ctx, e, loc, currentdepth, maxdepth, onCompletion)
 	case fs.File:
 		log(ctx).Debugf("file: '%v'", targetPath)
 
-		bytesExpected := e.Size()
-		bytesWritten := int64(0)
-		progressCallback := func(chunkSize int64) {
-			bytesWritten += chunkSize
-			c.stats.RestoredTotalFileSize.Add(chunkSize)
-			c.reportProgress(ctx)
-		}
-
 		if currentdepth > maxdepth {
-			if err := c.shallowoutput.WriteFile(ctx, targetPath, e, progressCallback); err != nil {
-				return errors.Wrap(err, "copy file")
-			}
-		} else {
-			if err := c.output.WriteFile(ctx, targetPath, e, progressCallback); err != nil {
-				return errors.Wrap(err, "copy file")
-			}
+			return c.writeFile(ctx, c.shallowoutput, loc, e, onCompletion)
 		}
 
-		c.stats.RestoredFileCount.Add(1)
-		c.stats.RestoredTotalFileSize.Add(bytesExpected - bytesWritten)
+		if de, ok := e.(snapshot.HasDirEntry); ok && c.planner != nil {
+			// defer writing the file until its contents are fetched by the planner.
+			c.planner.add(&plannedFile{
+				entry:    e,
+				objectID: de.DirEntry().ObjectID,
+				write: func(ctx context.Context, f fs.File) error {
+					if c.isCanceled() {
+						return onCompletion()
+					}
+
+					return c.maybeIgnoreError(ctx, c.writeFile(ctx, c.output, loc, f, onCompletion), targetPath)
+				},
+			})
 
-		return onCompletion()
+			return nil
+		}
+
+		return c.writeFile(ctx, c.output, loc, e, onCompletion)
 
 	case fs.Symlink:
 		c.stats.RestoredSymlinkCount.Add(1)
 		log(ctx).Debugf("symlink: '%v'", targetPath)
 
+		if err := loc.parent.ensureCreated(); err != nil {
+			return err
+		}
+
 		if err := c.output.CreateSymlink(ctx, targetPath, e); err != nil {
 			return errors.Wrap(err, "create symlink")
 		}
@@ -268,15 +430,65 @@ func (c *copier) copyEntryInternal(ctx context.Context, e fs.Entry, targetPath s
 	}
 }
 
-func (c *copier) copyDirectory(ctx context.Context, d fs.Directory, targetPath string, currentdepth, maxdepth int32, onCompletion parallelwork.CallbackFunc) error {
-	c.stats.RestoredDirCount.Add(1)
+func (c *copier) writeFile(ctx context.Context, output Output, loc entryLocation, f fs.File, onCompletion func() error) error {
+	if err := loc.parent.ensureCreated(); err != nil {
+		return err
+	}
+
+	bytesExpected := f.Size()
+	bytesWritten := int64(0)
+	progressCallback := func(chunkSize int64) {
+		bytesWritten += chunkSize
+		c.stats.RestoredTotalFileSize.Add(chunkSize)
+		c.reportProgress(ctx)
+	}
+
+	if err := output.WriteFile(ctx, loc.targetPath, f, progressCallback); err != nil {
+		return errors.Wrap(err, "copy file")
+	}
+
+	if c.journal != nil && output == c.output {
+		if err := c.journal.recordCompleted(loc.targetPath, f); err != nil {
+			return err
+		}
+	}
+
+	c.stats.RestoredFileCount.Add(1)
+	c.stats.RestoredTotalFileSize.Add(bytesExpected - bytesWritten)
+
+	return onCompletion()
+}
+
+// archiveFileToRestore returns the original file of the archive expanded into the directory, which should be
+// restored instead of the directory of its members, or nil if the directory should be restored as-is.
+func (c *copier) archiveFileToRestore(d fs.Directory, loc entryLocation, currentdepth, maxdepth int32) fs.File {
+	if c.extractArchives || !loc.mapped || currentdepth > maxdepth {
+		return nil
+	}
+
+	return snapshotfs.ArchiveFile(d)
+}
+
+func (c *copier) copyDirectory(ctx context.Context, d fs.Directory, loc entryLocation, currentdepth, maxdepth int32, onCompletion parallelwork.CallbackFunc) error {
+	targetPath := loc.targetPath
+
+	if !loc.mapped {
+		// directory is only traversed in search of sources of path mappings.
+		return errors.Wrap(c.copyDirectoryContent(ctx, d, loc, nil, currentdepth+1, maxdepth, onCompletion), "copy directory contents")
+	}
 
 	if SafelySuffixablePath(targetPath) && currentdepth > maxdepth {
+		c.stats.RestoredDirCount.Add(1)
+
 		de, ok := d.(snapshot.HasDirEntry)
 		if !ok {
 			return errors.Errorf("fs.Directory '%s' object is not HasDirEntry?", d.Name())
 		}
 
+		if err := loc.parent.ensureCreated(); err != nil {
+			return err
+		}
+
 		if err := c.shallowoutput.WriteDirEntry(ctx, targetPath, de.DirEntry(), d); err != nil {
 			return errors.Wrap(err, "create directory")
 		}
@@ -284,8 +496,20 @@ func (c *copier) copyDirectory(ctx context.Context, d fs.Directory, targetPath s
 		return onCompletion()
 	}
 
-	if err := c.output.BeginDirectory(ctx, targetPath, d); err != nil {
-		return errors.Wrap(err, "create directory")
+	dir := &lazyDirectory{
+		parent: loc.parent,
+		create: func() error {
+			c.stats.RestoredDirCount.Add(1)
+
+			return errors.Wrap(c.output.BeginDirectory(ctx, targetPath, d), "create directory")
+		},
+	}
+
+	if c.filter == nil {
+		// without filters, directories are restored even if empty.
+		if err := dir.ensureCreated(); err != nil {
+			return err
+		}
 	}
 
 	if c.deleteExtra {
@@ -297,7 +521,12 @@ func (c *copier) copyDirectory(ctx context.Context, d fs.Directory, targetPath s
 		}
 	}
 
-	return errors.Wrap(c.copyDirectoryContent(ctx, d, targetPath, currentdepth+1, maxdepth, func() error {
+	return errors.Wrap(c.copyDirectoryContent(ctx, d, loc, dir, currentdepth+1, maxdepth, func() error {
+		if !dir.wasCreated() {
+			// all entries have been filtered out.
+			return onCompletion()
+		}
+
 		if err := c.output.FinishDirectory(ctx, targetPath, d); err != nil {
 			return errors.Wrap(err, "finish directory")
 		}
@@ -377,24 +606,39 @@ func (c *copier) deleteExtraFilesInDir(ctx context.Context, o *FilesystemOutput,
 	return nil
 }
 
-func (c *copier) copyDirectoryContent(ctx context.Context, d fs.Directory, targetPath string, currentdepth, maxdepth int32, onCompletion parallelwork.CallbackFunc) error {
+func (c *copier) copyDirectoryContent(ctx context.Context, d fs.Directory, loc entryLocation, dir *lazyDirectory, currentdepth, maxdepth int32, onCompletion parallelwork.CallbackFunc) error {
 	entries, err := fs.GetAllEntries(ctx, d)
 	if err != nil {
 		return errors.Wrap(err, "error reading directory")
 	}
 
-	if len(entries) == 0 {
-		return onCompletion()
+	type child struct {
+		entry fs.Entry
+		loc   entryLocation
 	}
 
-	onItemCompletion := parallelwork.OnNthCompletion(len(entries), onCompletion)
+	var children []child
 
 	for _, e := range entries {
+		if childLoc, ok := c.filter.childLocation(loc, dir, e); ok {
+			children = append(children, child{e, childLoc})
+		}
+	}
+
+	if len(children) == 0 {
+		return onCompletion()
+	}
+
+	onItemCompletion := parallelwork.OnNthCompletion(len(children), onCompletion)
+
+	for _, ch := range children {
+		e := ch.entry
+
 		if e.IsDir() {
 			c.stats.EnqueuedDirCount.Add(1)
 			// enqueue directories first, so that we quickly determine the total number and size of items.
 			c.q.EnqueueFront(ctx, func() error {
-				return c.copyEntry(ctx, e, path.Join(targetPath, e.Name()), currentdepth, maxdepth, onItemCompletion)
+				return c.copyEntry(ctx, e, ch.loc, currentdepth, maxdepth, onItemCompletion)
 			})
 		} else {
 			if isSymlink(e) {
@@ -406,7 +650,7 @@ func (c *copier) copyDirectoryContent(ctx context.Context, d fs.Directory, targe
 			c.stats.EnqueuedTotalFileSize.Add(e.Size())
 
 			c.q.EnqueueBack(ctx, func() error {
-				return c.copyEntry(ctx, e, path.Join(targetPath, e.Name()), currentdepth, maxdepth, onItemCompletion)
+				return c.copyEntry(ctx, e, ch.loc, currentdepth, maxdepth, onItemCompletion)
 			})
 		}
 	}
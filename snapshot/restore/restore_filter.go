@@ -0,0 +1,305 @@
+package restore
+
+import (
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/wcmatch"
+)
+
+// PathMapping causes entries under Source path to be restored under Target path instead.
+// Both paths are slash-separated and relative to the restore root and the output root respectively,
+// an empty path denotes the root.
+type PathMapping struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+// entryLocation describes the position of an entry being restored.
+type entryLocation struct {
+	// path relative to the restore root, used for matching filters and path mappings.
+	sourcePath string
+
+	// path relative to the output root.
+	targetPath string
+
+	// set when an ancestor directory matched one of include patterns.
+	included bool
+
+	// set when the entry is restored, as opposed to traversed in search of sources of path mappings,
+	// along with the index of the applied mapping.
+	mapped  bool
+	mapping int
+
+	// directory which must be created in the output before the entry is written, nil if it already exists.
+	parent *lazyDirectory
+}
+
+// lazyDirectory is an output directory that is only created when the first entry is written into it,
+// which prevents creating directories for which filters excluded all entries.
+type lazyDirectory struct {
+	parent *lazyDirectory
+	create func() error
+
+	once    sync.Once
+	err     error
+	created atomic.Bool
+}
+
+func (d *lazyDirectory) ensureCreated() error {
+	if d == nil {
+		return nil
+	}
+
+	if err := d.parent.ensureCreated(); err != nil {
+		return err
+	}
+
+	d.once.Do(func() {
+		d.err = d.create()
+		d.created.Store(d.err == nil)
+	})
+
+	return d.err
+}
+
+func (d *lazyDirectory) wasCreated() bool {
+	return d == nil || d.created.Load()
+}
+
+// entryFilter determines which entries are restored and where.
+type entryFilter struct {
+	include []*wcmatch.WildcardMatcher
+	exclude []*wcmatch.WildcardMatcher
+
+	minSize        int64
+	maxSize        int64
+	modifiedAfter  time.Time
+	modifiedBefore time.Time
+
+	// sorted from the most specific source path.
+	mappings []PathMapping
+}
+
+// newEntryFilter returns the filter for the provided options or nil if all entries are restored in place.
+func newEntryFilter(options Options) (*entryFilter, error) {
+	f := &entryFilter{
+		minSize:        options.MinFileSize,
+		maxSize:        options.MaxFileSize,
+		modifiedAfter:  options.ModifiedAfter,
+		modifiedBefore: options.ModifiedBefore,
+	}
+
+	var err error
+
+	if f.include, err = compilePatterns(options.IncludePatterns); err != nil {
+		return nil, errors.Wrap(err, "invalid include pattern")
+	}
+
+	if f.exclude, err = compilePatterns(options.ExcludePatterns); err != nil {
+		return nil, errors.Wrap(err, "invalid exclude pattern")
+	}
+
+	for _, m := range options.PathMappings {
+		src, err := cleanRelativePath(m.Source)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid path mapping source %q", m.Source)
+		}
+
+		dst, err := cleanRelativePath(m.Target)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid path mapping target %q", m.Target)
+		}
+
+		f.mappings = append(f.mappings, PathMapping{src, dst})
+	}
+
+	slices.SortStableFunc(f.mappings, func(a, b PathMapping) int {
+		return len(b.Source) - len(a.Source)
+	})
+
+	if len(f.include) == 0 && len(f.exclude) == 0 && len(f.mappings) == 0 &&
+		f.minSize == 0 && f.maxSize == 0 && f.modifiedAfter.IsZero() && f.modifiedBefore.IsZero() {
+		return nil, nil
+	}
+
+	return f, nil
+}
+
+func compilePatterns(patterns []string) ([]*wcmatch.WildcardMatcher, error) {
+	var result []*wcmatch.WildcardMatcher
+
+	for _, p := range patterns {
+		// patterns are matched against paths relative to the restore root, using .gitignore semantics.
+		m, err := wcmatch.NewWildcardMatcher(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%q", p)
+		}
+
+		result = append(result, m)
+	}
+
+	return result, nil
+}
+
+func cleanRelativePath(p string) (string, error) {
+	p = path.Clean(strings.Trim(strings.ReplaceAll(p, "\\", "/"), "/"))
+
+	switch {
+	case p == ".":
+		return "", nil
+	case p == ".." || strings.HasPrefix(p, "../"):
+		return "", errors.New("path must not refer to parent directory")
+	default:
+		return p, nil
+	}
+}
+
+func matchesAny(matchers []*wcmatch.WildcardMatcher, sourcePath string, isDir bool) bool {
+	for _, m := range matchers {
+		if m.Match("/"+sourcePath, isDir) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// mapPath returns the output path for the provided source path and the index of the applied mapping,
+// false if the entry is not mapped.
+func (f *entryFilter) mapPath(sourcePath string) (string, int, bool) {
+	if len(f.mappings) == 0 {
+		return sourcePath, -1, true
+	}
+
+	for i, m := range f.mappings {
+		switch {
+		case m.Source == "":
+			return path.Join(m.Target, sourcePath), i, true
+
+		case sourcePath == m.Source:
+			return m.Target, i, true
+
+		case strings.HasPrefix(sourcePath, m.Source+"/"):
+			return path.Join(m.Target, strings.TrimPrefix(sourcePath, m.Source+"/")), i, true
+		}
+	}
+
+	return "", 0, false
+}
+
+// containsMappedPath returns true if the directory contains sources of path mappings.
+func (f *entryFilter) containsMappedPath(sourcePath string) bool {
+	for _, m := range f.mappings {
+		if sourcePath == "" || strings.HasPrefix(m.Source, sourcePath+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// childLocation returns the location of the child entry of a directory and whether the entry should be restored.
+// The dir is the output directory of the parent, nil if the parent is not restored itself.
+func (f *entryFilter) childLocation(parent entryLocation, dir *lazyDirectory, e fs.Entry) (entryLocation, bool) {
+	sourcePath := path.Join(parent.sourcePath, e.Name())
+
+	if f == nil {
+		return entryLocation{
+			sourcePath: sourcePath,
+			targetPath: path.Join(parent.targetPath, e.Name()),
+			mapped:     true,
+			parent:     dir,
+		}, true
+	}
+
+	isDir := e.IsDir()
+
+	if matchesAny(f.exclude, sourcePath, isDir) {
+		return entryLocation{}, false
+	}
+
+	loc := entryLocation{
+		sourcePath: sourcePath,
+		included:   parent.included || matchesAny(f.include, sourcePath, isDir),
+		parent:     dir,
+	}
+
+	targetPath, mapping, mapped := f.mapPath(sourcePath)
+
+	switch {
+	case !mapped && isDir:
+		// directory is traversed without being restored if it contains sources of path mappings.
+		return loc, f.containsMappedPath(sourcePath)
+
+	case !mapped, !isDir && !f.includeFile(loc, e):
+		return entryLocation{}, false
+	}
+
+	loc.targetPath = targetPath
+	loc.mapped = true
+	loc.mapping = mapping
+
+	if !parent.mapped || parent.mapping != mapping {
+		// the entry is the root of a path mapping, which is not restored inside its parent.
+		loc.parent = nil
+	}
+
+	return loc, true
+}
+
+func (f *entryFilter) includeFile(loc entryLocation, e fs.Entry) bool {
+	if len(f.include) > 0 && !loc.included {
+		return false
+	}
+
+	if !e.IsDir() && e.Mode().IsRegular() {
+		if e.Size() < f.minSize {
+			return false
+		}
+
+		if f.maxSize > 0 && e.Size() > f.maxSize {
+			return false
+		}
+	}
+
+	if !f.modifiedAfter.IsZero() && !e.ModTime().After(f.modifiedAfter) {
+		return false
+	}
+
+	if !f.modifiedBefore.IsZero() && !e.ModTime().Before(f.modifiedBefore) {
+		return false
+	}
+
+	return true
+}
+
+// rootLocation returns the location of the restore root and whether it should be restored.
+func (f *entryFilter) rootLocation(e fs.Entry) (entryLocation, bool) {
+	if f == nil {
+		return entryLocation{mapped: true}, true
+	}
+
+	targetPath, mapping, mapped := f.mapPath("")
+	if !mapped {
+		return entryLocation{}, e.IsDir() && f.containsMappedPath("")
+	}
+
+	loc := entryLocation{targetPath: targetPath, mapped: true, mapping: mapping}
+
+	if e.IsDir() {
+		return loc, true
+	}
+
+	// patterns refer to paths inside the restore root, so they don't apply to a single file being restored.
+	loc.included = true
+
+	return loc, f.includeFile(loc, e)
+}
@@ -0,0 +1,220 @@
+package restore_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/fs"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/restore"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/kopia/kopia/snapshot/upload"
+)
+
+func TestRestoreFilters(t *testing.T) {
+	sourceRoot := mockfs.NewDirectory()
+
+	docs := sourceRoot.AddDir("docs", 0o755)
+	docs.AddFile("report.docx", []byte("report"), 0o644)
+	docs.AddFile("notes.txt", []byte("notes"), 0o644)
+	docs.AddDir("drafts", 0o755).AddFile("draft.docx", bytes.Repeat([]byte("d"), 1000), 0o644)
+
+	photos := sourceRoot.AddDir("photos", 0o755)
+	photos.AddFile("a.jpg", bytes.Repeat([]byte("a"), 5000), 0o644)
+	photos.AddFile("b.jpg", []byte("b"), 0o644)
+	photos.AddDir("cache", 0o755).AddFile("thumb.jpg", []byte("t"), 0o644)
+
+	sourceRoot.AddFile("readme.txt", []byte("readme"), 0o644)
+	sourceRoot.AddSymlink("link", "readme.txt", 0o777)
+
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	man, err := upload.NewUploader(env.RepositoryWriter).Upload(ctx, sourceRoot, nil, snapshot.SourceInfo{})
+	require.NoError(t, err)
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	rootEntry, err := snapshotfs.SnapshotRoot(env.RepositoryWriter, man)
+	require.NoError(t, err)
+
+	cases := []struct {
+		name    string
+		options restore.Options
+		want    []string
+	}{
+		{
+			name:    "include-extension",
+			options: restore.Options{IncludePatterns: []string{"*.docx"}},
+			want:    []string{"docs/", "docs/drafts/", "docs/drafts/draft.docx", "docs/report.docx"},
+		},
+		{
+			name:    "include-directory",
+			options: restore.Options{IncludePatterns: []string{"/photos"}, ExcludePatterns: []string{"cache/"}},
+			want:    []string{"photos/", "photos/a.jpg", "photos/b.jpg"},
+		},
+		{
+			name:    "exclude",
+			options: restore.Options{ExcludePatterns: []string{"docs", "*.jpg", "link"}},
+			// directories left without restored entries are not created.
+			want: []string{"readme.txt"},
+		},
+		{
+			name:    "size",
+			options: restore.Options{MinFileSize: 10, MaxFileSize: 2000},
+			want:    []string{"docs/", "docs/drafts/", "docs/drafts/draft.docx", "link"},
+		},
+		{
+			name:    "modified-after",
+			options: restore.Options{IncludePatterns: []string{"*.txt"}, ModifiedAfter: mockfs.DefaultModTime.Add(-time.Hour)},
+			want:    []string{"docs/", "docs/notes.txt", "readme.txt"},
+		},
+		{
+			name:    "modified-before",
+			options: restore.Options{ModifiedBefore: mockfs.DefaultModTime},
+			want:    nil,
+		},
+		{
+			name: "path-mappings",
+			options: restore.Options{PathMappings: []restore.PathMapping{
+				{Source: "docs", Target: "restored/documents"},
+				{Source: "docs/drafts", Target: "drafts"},
+				{Source: "photos/a.jpg", Target: "a.jpg"},
+			}},
+			want: []string{
+				"a.jpg",
+				"drafts/", "drafts/draft.docx",
+				"restored/", "restored/documents/", "restored/documents/notes.txt", "restored/documents/report.docx",
+			},
+		},
+		{
+			name: "path-mappings-with-filters",
+			options: restore.Options{
+				PathMappings:    []restore.PathMapping{{Source: "/docs/", Target: "out"}},
+				IncludePatterns: []string{"*.docx"},
+				ExcludePatterns: []string{"drafts"},
+			},
+			want: []string{"out/", "out/report.docx"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			targetDir := testutil.TempDirectory(t)
+			output := &restore.FilesystemOutput{
+				TargetPath:           targetDir,
+				OverwriteDirectories: true,
+				OverwriteFiles:       true,
+				SkipOwners:           true,
+			}
+			require.NoError(t, output.Init(ctx))
+
+			opt := tc.options
+			opt.RestoreDirEntryAtDepth = math.MaxInt32
+
+			st, err := restore.Entry(ctx, env.RepositoryWriter, output, rootEntry, opt)
+			require.NoError(t, err)
+
+			got := listRestoredFiles(t, targetDir)
+			require.Equal(t, tc.want, got)
+
+			var restored []string
+
+			for _, p := range got {
+				if p[len(p)-1] != '/' {
+					restored = append(restored, p)
+				}
+			}
+
+			require.EqualValues(t, len(restored), st.RestoredFileCount+st.RestoredSymlinkCount)
+		})
+	}
+
+	t.Run("tar", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		output := restore.NewTarOutput(nopCloser{&buf})
+
+		_, err := restore.Entry(ctx, env.RepositoryWriter, output, rootEntry, restore.Options{
+			IncludePatterns:        []string{"*.docx"},
+			PathMappings:           []restore.PathMapping{{Source: "docs", Target: "out"}},
+			RestoreDirEntryAtDepth: math.MaxInt32,
+		})
+		require.NoError(t, err)
+		require.NoError(t, output.Close(ctx))
+
+		var names []string
+
+		tr := tar.NewReader(&buf)
+
+		for {
+			h, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+
+			require.NoError(t, err)
+
+			names = append(names, h.Name)
+		}
+
+		require.ElementsMatch(t, []string{"out/", "out/report.docx", "out/drafts/", "out/drafts/draft.docx"}, names)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		output := &restore.FilesystemOutput{TargetPath: testutil.TempDirectory(t)}
+
+		_, err := restore.Entry(ctx, env.RepositoryWriter, output, rootEntry, restore.Options{
+			ExcludePatterns: []string{"*.txt"},
+			DeleteExtra:     true,
+		})
+		require.ErrorContains(t, err, "cannot be combined")
+
+		_, err = restore.Entry(ctx, env.RepositoryWriter, output, rootEntry, restore.Options{
+			PathMappings: []restore.PathMapping{{Source: "docs", Target: "../outside"}},
+		})
+		require.ErrorContains(t, err, "invalid path mapping target")
+	})
+}
+
+func listRestoredFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	var result []string
+
+	require.NoError(t, filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == dir {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			rel += "/"
+		}
+
+		result = append(result, rel)
+
+		return nil
+	}))
+
+	return result
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,228 @@
+package restore
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/repo/object"
+)
+
+const restoreJournalSuffix = ".kopia-restore-journal"
+
+// journalSyncInterval is the maximum time between syncs of the journal. Records which are lost when
+// the restore is interrupted before the sync only cause the files to be restored again.
+const journalSyncInterval = 5 * time.Second
+
+// JournalPath returns the path of the journal of resumable restore into the provided target directory.
+// The journal is stored next to the target, so that it's not part of the restored data.
+func JournalPath(targetPath string) string {
+	targetPath = filepath.Clean(targetPath)
+
+	return filepath.Join(filepath.Dir(targetPath), "."+filepath.Base(targetPath)+restoreJournalSuffix)
+}
+
+// journalRecord records a file that has been completely written to the output.
+type journalRecord struct {
+	Path     string    `json:"path"`
+	ObjectID object.ID `json:"oid"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mtime"`
+}
+
+// restoreJournal keeps track of completed files, allowing interrupted restore to be resumed.
+type restoreJournal struct {
+	targetPath string
+	filename   string
+
+	mu sync.Mutex
+	// +checklocks:mu
+	completed map[string]journalRecord
+	// +checklocks:mu
+	f *os.File
+	// +checklocks:mu
+	lastSync time.Time
+}
+
+func openRestoreJournal(ctx context.Context, targetPath, filename string) (*restoreJournal, error) {
+	j := &restoreJournal{
+		targetPath: targetPath,
+		filename:   filename,
+		completed:  map[string]journalRecord{},
+		lastSync:   clock.Now(),
+	}
+
+	if err := j.load(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(j.filename), outputDirMode); err != nil {
+		return nil, errors.Wrap(err, "unable to create restore journal directory")
+	}
+
+	f, err := os.OpenFile(j.filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600) //nolint:mnd
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open restore journal")
+	}
+
+	j.f = f
+
+	return j, nil
+}
+
+func (j *restoreJournal) load(ctx context.Context) error {
+	f, err := os.Open(j.filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "unable to open restore journal")
+	}
+
+	defer f.Close() //nolint:errcheck
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1<<20) //nolint:mnd
+
+	for s.Scan() {
+		var rec journalRecord
+
+		if err := json.Unmarshal(s.Bytes(), &rec); err != nil {
+			// the last record may be truncated if the restore was interrupted while writing it.
+			log(ctx).Debugf("ignoring invalid restore journal record: %v", err)
+			continue
+		}
+
+		j.completed[rec.Path] = rec
+	}
+
+	if len(j.completed) > 0 {
+		log(ctx).Infof("Resuming restore using journal %v with %v completed files.", j.filename, len(j.completed))
+	}
+
+	return errors.Wrap(s.Err(), "error reading restore journal")
+}
+
+func (j *restoreJournal) localPath(relativePath string) string {
+	return filepath.Join(j.targetPath, filepath.FromSlash(relativePath))
+}
+
+// isCompleted returns true if the file has been recorded as completed and remains unchanged in the output.
+func (j *restoreJournal) isCompleted(relativePath string, f fs.File) bool {
+	oid, ok := journalObjectID(f)
+	if !ok {
+		return false
+	}
+
+	j.mu.Lock()
+	rec, ok := j.completed[relativePath]
+	j.mu.Unlock()
+
+	if !ok || rec.ObjectID != oid {
+		return false
+	}
+
+	st, err := os.Lstat(j.localPath(relativePath))
+	if err != nil || !st.Mode().IsRegular() {
+		return false
+	}
+
+	return st.Size() == rec.Size && st.ModTime().Equal(rec.ModTime)
+}
+
+// exists returns true if the output contains an entry at the provided path.
+func (j *restoreJournal) exists(relativePath string) bool {
+	_, err := os.Lstat(j.localPath(relativePath))
+
+	return err == nil
+}
+
+// recordCompleted appends the record of completed file to the journal.
+func (j *restoreJournal) recordCompleted(relativePath string, f fs.File) error {
+	oid, ok := journalObjectID(f)
+	if !ok {
+		return nil
+	}
+
+	st, err := os.Lstat(j.localPath(relativePath))
+	if err != nil {
+		return errors.Wrap(err, "unable to stat restored file")
+	}
+
+	rec := journalRecord{
+		Path:     relativePath,
+		ObjectID: oid,
+		Size:     st.Size(),
+		ModTime:  st.ModTime(),
+	}
+
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal restore journal record")
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, err := j.f.Write(append(b, '\n')); err != nil {
+		return errors.Wrap(err, "unable to write restore journal")
+	}
+
+	j.completed[relativePath] = rec
+
+	if now := clock.Now(); now.Sub(j.lastSync) >= journalSyncInterval {
+		if err := j.f.Sync(); err != nil {
+			return errors.Wrap(err, "unable to sync restore journal")
+		}
+
+		j.lastSync = now
+	}
+
+	return nil
+}
+
+// close closes the journal and removes it when the restore has finished.
+func (j *restoreJournal) close(ctx context.Context, finished bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if !finished {
+		if err := j.f.Sync(); err != nil {
+			log(ctx).Warnf("unable to sync restore journal: %v", err)
+		}
+	}
+
+	if err := j.f.Close(); err != nil {
+		log(ctx).Warnf("unable to close restore journal: %v", err)
+	}
+
+	if !finished {
+		log(ctx).Infof("Restore journal kept in %v, run the restore again to resume it.", j.filename)
+		return
+	}
+
+	if err := os.Remove(j.filename); err != nil {
+		log(ctx).Warnf("unable to remove restore journal: %v", err)
+	}
+}
+
+func journalObjectID(f fs.File) (object.ID, bool) {
+	h, ok := f.(object.HasObjectID)
+	if !ok {
+		return object.EmptyID, false
+	}
+
+	return h.ObjectID(), true
+}
@@ -0,0 +1,128 @@
+package restore_test
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/restore"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/kopia/kopia/snapshot/upload"
+)
+
+func TestResumableRestore(t *testing.T) {
+	files := map[string][]byte{}
+
+	sourceRoot := mockfs.NewDirectory()
+
+	for i := range 2 {
+		dir := sourceRoot.AddDir(fmt.Sprintf("dir%v", i), 0o755)
+
+		for j := range 10 {
+			data := make([]byte, 1000+j)
+			rand.Read(data)
+
+			name := fmt.Sprintf("file%v", j)
+			dir.AddFile(name, data, 0o644)
+			files[filepath.Join(fmt.Sprintf("dir%v", i), name)] = data
+		}
+	}
+
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	man, err := upload.NewUploader(env.RepositoryWriter).Upload(ctx, sourceRoot, nil, snapshot.SourceInfo{})
+	require.NoError(t, err)
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	rootEntry, err := snapshotfs.SnapshotRoot(env.RepositoryWriter, man)
+	require.NoError(t, err)
+
+	targetDir := testutil.TempDirectory(t)
+
+	newOutput := func() *restore.FilesystemOutput {
+		o := &restore.FilesystemOutput{
+			TargetPath:           targetDir,
+			OverwriteDirectories: true,
+			OverwriteFiles:       true,
+			SkipOwners:           true,
+		}
+		require.NoError(t, o.Init(ctx))
+
+		return o
+	}
+
+	// interrupt the restore after some files have been written.
+	var cancelOnce sync.Once
+
+	cancel := make(chan struct{})
+
+	st1, err := restore.Entry(ctx, env.RepositoryWriter, newOutput(), rootEntry, restore.Options{
+		Parallel:               1,
+		Resumable:              true,
+		RestoreDirEntryAtDepth: math.MaxInt32,
+		Cancel:                 cancel,
+		ProgressCallback: func(_ context.Context, s restore.Stats) {
+			if s.RestoredFileCount >= 5 {
+				cancelOnce.Do(func() { close(cancel) })
+			}
+		},
+	})
+	require.NoError(t, err)
+	require.Less(t, int(st1.RestoredFileCount), len(files))
+	require.FileExists(t, restore.JournalPath(targetDir))
+
+	// modify one of restored files, which must be restored again.
+	var modified string
+
+	for name := range files {
+		if _, err := os.Stat(filepath.Join(targetDir, name)); err == nil {
+			modified = name
+			break
+		}
+	}
+
+	require.NotEmpty(t, modified)
+	require.NoError(t, os.WriteFile(filepath.Join(targetDir, modified), []byte("partial"), 0o600))
+
+	out2 := newOutput()
+
+	st2, err := restore.Entry(ctx, env.RepositoryWriter, out2, rootEntry, restore.Options{
+		Resumable:              true,
+		RestoreDirEntryAtDepth: math.MaxInt32,
+	})
+	require.NoError(t, err)
+
+	// the output provided by the caller is not modified.
+	require.False(t, out2.WriteFilesAtomically)
+
+	require.EqualValues(t, st1.RestoredFileCount-1, st2.ResumedFileCount)
+	require.EqualValues(t, 1, st2.RedoneFileCount)
+	require.EqualValues(t, len(files), st2.RestoredFileCount+st2.ResumedFileCount)
+
+	for name, want := range files {
+		got, err := os.ReadFile(filepath.Join(targetDir, name))
+		require.NoError(t, err)
+		require.Equal(t, want, got, name)
+	}
+
+	// journal is removed after the restore completes.
+	require.NoFileExists(t, restore.JournalPath(targetDir))
+
+	// resumable restore is only supported for filesystem output.
+	_, err = restore.Entry(ctx, env.RepositoryWriter, restore.NewTarOutput(nopCloser{io.Discard}), rootEntry, restore.Options{
+		Resumable: true,
+	})
+	require.ErrorContains(t, err, "only supported for filesystem output")
+}
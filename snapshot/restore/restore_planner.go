@@ -0,0 +1,509 @@
+package restore
+
+import (
+	"cmp"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/object"
+)
+
+// DefaultMaxSpoolSize is the default maximum size of the local spool used when restoring with pack-ordered reads.
+const DefaultMaxSpoolSize = 1e9
+
+// plannedFile is a file whose restore has been deferred until its contents are fetched from the packs.
+type plannedFile struct {
+	entry    fs.File
+	objectID object.ID
+
+	// contents stored in regular packs, which will be fetched into the spool.
+	contents []content.Info
+
+	// write writes the file to the output, reporting stats and handling errors.
+	write func(ctx context.Context, f fs.File) error
+}
+
+// position returns the earliest position of file contents in the packs, which is used for ordering files.
+func (pf *plannedFile) position() (blob.ID, uint32) {
+	if len(pf.contents) == 0 {
+		return "", 0
+	}
+
+	first := slices.MinFunc(pf.contents, comparePackPosition)
+
+	return first.PackBlobID, first.PackOffset
+}
+
+func comparePackPosition(a, b content.Info) int {
+	if c := cmp.Compare(a.PackBlobID, b.PackBlobID); c != 0 {
+		return c
+	}
+
+	return cmp.Compare(a.PackOffset, b.PackOffset)
+}
+
+// restorePlanner collects files to restore and writes them in waves, each of which fetches the contents
+// required by its files by reading each pack blob once using coalesced range requests into a bounded
+// local spool, from which the files are then assembled.
+type restorePlanner struct {
+	cr            content.Reader
+	spoolDir      string
+	maxSpoolSize  int64
+	fetchParallel int
+	writeParallel int
+
+	mu sync.Mutex
+	// +checklocks:mu
+	files []*plannedFile
+}
+
+func newRestorePlanner(rep repo.Repository, options Options, fetchParallel, writeParallel int) *restorePlanner {
+	if !options.PackOrderedReads {
+		return nil
+	}
+
+	dr, ok := rep.(repo.DirectRepository)
+	if !ok {
+		// contents can only be fetched by pack with direct repository connection.
+		return nil
+	}
+
+	maxSpoolSize := options.MaxSpoolSize
+	if maxSpoolSize == 0 {
+		maxSpoolSize = DefaultMaxSpoolSize
+	}
+
+	return &restorePlanner{
+		cr:            dr.ContentReader(),
+		spoolDir:      options.SpoolDirectory,
+		maxSpoolSize:  maxSpoolSize,
+		fetchParallel: fetchParallel,
+		writeParallel: writeParallel,
+	}
+}
+
+func (p *restorePlanner) add(pf *plannedFile) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.files = append(p.files, pf)
+}
+
+// run fetches contents and writes all planned files.
+func (p *restorePlanner) run(ctx context.Context) error {
+	p.mu.Lock()
+	files := p.files
+	p.files = nil
+	p.mu.Unlock()
+
+	if len(files) == 0 {
+		return nil
+	}
+
+	sp, err := newContentSpool(p.spoolDir)
+	if err != nil {
+		return err
+	}
+
+	defer sp.close(ctx)
+
+	cr := &spoolContentReader{p.cr, sp}
+
+	if err := p.resolveContents(ctx, cr, files); err != nil {
+		return err
+	}
+
+	// files larger than the spool can't be fetched in any wave, so they are read directly from the repository.
+	files, large := splitLargeFiles(files, p.maxSpoolSize)
+
+	if err := p.writeDirectly(ctx, large); err != nil {
+		return err
+	}
+
+	slices.SortStableFunc(files, func(a, b *plannedFile) int {
+		ab, ao := a.position()
+		bb, bo := b.position()
+
+		if c := cmp.Compare(ab, bb); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(ao, bo)
+	})
+
+	// number of files remaining to be written that need each content.
+	remaining := map[content.ID]int{}
+
+	for _, pf := range files {
+		for _, ci := range pf.contents {
+			remaining[ci.ContentID]++
+		}
+	}
+
+	var (
+		wave      []*plannedFile
+		waveBytes int64
+		scheduled = map[content.ID]bool{}
+	)
+
+	for _, pf := range files {
+		fileBytes := newContentBytes(pf, sp, scheduled)
+
+		if len(wave) > 0 && sp.totalSize()+waveBytes+fileBytes > p.maxSpoolSize {
+			if err := p.processWave(ctx, cr, wave, remaining); err != nil {
+				return err
+			}
+
+			wave, waveBytes = nil, 0
+			clear(scheduled)
+
+			fileBytes = newContentBytes(pf, sp, scheduled)
+		}
+
+		if len(wave) == 0 && sp.totalSize()+fileBytes > p.maxSpoolSize {
+			// contents spooled for files in later waves leave no room for this file.
+			if err := p.writeDirectly(ctx, []*plannedFile{pf}); err != nil {
+				return err
+			}
+
+			releaseContents(ctx, sp, pf, remaining)
+
+			continue
+		}
+
+		for _, ci := range pf.contents {
+			scheduled[ci.ContentID] = true
+		}
+
+		wave = append(wave, pf)
+		waveBytes += fileBytes
+	}
+
+	return p.processWave(ctx, cr, wave, remaining)
+}
+
+// splitLargeFiles separates files whose contents exceed the provided spool size.
+func splitLargeFiles(files []*plannedFile, maxSpoolSize int64) (small, large []*plannedFile) {
+	for _, pf := range files {
+		if newContentBytes(pf, nil, nil) > maxSpoolSize {
+			large = append(large, pf)
+		} else {
+			small = append(small, pf)
+		}
+	}
+
+	return small, large
+}
+
+// writeDirectly writes the provided files reading their contents directly from the repository.
+func (p *restorePlanner) writeDirectly(ctx context.Context, files []*plannedFile) error {
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(p.writeParallel)
+
+	for _, pf := range files {
+		eg.Go(func() error {
+			return pf.write(ctx, pf.entry)
+		})
+	}
+
+	return errors.Wrap(eg.Wait(), "error writing files")
+}
+
+// newContentBytes returns the number of bytes of file contents that are neither spooled nor scheduled to be fetched,
+// with nil spool and schedule it returns the size of all distinct file contents.
+func newContentBytes(pf *plannedFile, sp *contentSpool, scheduled map[content.ID]bool) int64 {
+	var total int64
+
+	seen := map[content.ID]bool{}
+
+	for _, ci := range pf.contents {
+		if scheduled[ci.ContentID] || seen[ci.ContentID] || (sp != nil && sp.has(ci.ContentID)) {
+			continue
+		}
+
+		seen[ci.ContentID] = true
+		total += int64(ci.OriginalLength)
+	}
+
+	return total
+}
+
+// resolveContents determines the contents backing each planned file and their locations in the packs.
+func (p *restorePlanner) resolveContents(ctx context.Context, cr *spoolContentReader, files []*plannedFile) error {
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(p.fetchParallel)
+
+	for _, pf := range files {
+		eg.Go(func() error {
+			cids, err := object.VerifyObject(ctx, cr, pf.objectID)
+			if err != nil {
+				// the file will be read directly from the repository, which will report the error.
+				log(ctx).Debugf("unable to determine contents of %v: %v", pf.objectID, err)
+				return nil
+			}
+
+			for _, cid := range cids {
+				ci, err := cr.ContentInfo(ctx, cid)
+				if err != nil {
+					continue
+				}
+
+				// contents stored in special packs are served from the metadata cache.
+				if strings.HasPrefix(string(ci.PackBlobID), string(content.PackBlobIDPrefixRegular)) {
+					pf.contents = append(pf.contents, ci)
+				}
+			}
+
+			return nil
+		})
+	}
+
+	return errors.Wrap(eg.Wait(), "error resolving file contents")
+}
+
+// processWave fetches contents of the provided files into the spool and writes the files.
+func (p *restorePlanner) processWave(ctx context.Context, cr *spoolContentReader, wave []*plannedFile, remaining map[content.ID]int) error {
+	if len(wave) == 0 {
+		return nil
+	}
+
+	sp := cr.spool
+
+	byPack := map[blob.ID][]content.Info{}
+	fetching := map[content.ID]bool{}
+
+	for _, pf := range wave {
+		for _, ci := range pf.contents {
+			if fetching[ci.ContentID] || sp.has(ci.ContentID) {
+				continue
+			}
+
+			fetching[ci.ContentID] = true
+			byPack[ci.PackBlobID] = append(byPack[ci.PackBlobID], ci)
+		}
+	}
+
+	fetchGroup, fetchCtx := errgroup.WithContext(ctx)
+	fetchGroup.SetLimit(p.fetchParallel)
+
+	for packID, infos := range byPack {
+		fetchGroup.Go(func() error {
+			if err := p.cr.ReadPackContents(fetchCtx, packID, infos, content.DefaultPackReadMaxGap, sp.put); err != nil {
+				if fetchCtx.Err() != nil {
+					return errors.Wrap(fetchCtx.Err(), "error fetching contents")
+				}
+
+				// contents that were not spooled will be read directly from the repository, which will report the error.
+				log(ctx).Warnf("unable to fetch contents of pack %v: %v", packID, err)
+			}
+
+			return nil
+		})
+	}
+
+	if err := fetchGroup.Wait(); err != nil {
+		return errors.Wrap(err, "error fetching contents")
+	}
+
+	var mu sync.Mutex
+
+	writeGroup, writeCtx := errgroup.WithContext(ctx)
+	writeGroup.SetLimit(p.writeParallel)
+
+	for _, pf := range wave {
+		writeGroup.Go(func() error {
+			err := pf.write(writeCtx, &spooledFile{
+				File:     pf.entry,
+				objectID: pf.objectID,
+				cr:       cr,
+			})
+
+			mu.Lock()
+			releaseContents(ctx, sp, pf, remaining)
+			mu.Unlock()
+
+			return err
+		})
+	}
+
+	return errors.Wrap(writeGroup.Wait(), "error writing files")
+}
+
+// releaseContents records that the file has been written and removes spooled contents no longer needed by other files.
+func releaseContents(ctx context.Context, sp *contentSpool, pf *plannedFile, remaining map[content.ID]int) {
+	for _, ci := range pf.contents {
+		remaining[ci.ContentID]--
+
+		if remaining[ci.ContentID] == 0 {
+			sp.remove(ctx, ci.ContentID)
+		}
+	}
+}
+
+// contentSpool stores fetched contents in a local directory until all files using them are written.
+type contentSpool struct {
+	dir string
+	mu  sync.Mutex
+	// +checklocks:mu
+	sizes map[content.ID]int64
+	// +checklocks:mu
+	size int64
+}
+
+func newContentSpool(baseDir string) (*contentSpool, error) {
+	dir, err := os.MkdirTemp(baseDir, "kopia-restore-spool")
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create spool directory")
+	}
+
+	return &contentSpool{
+		dir:   dir,
+		sizes: map[content.ID]int64{},
+	}, nil
+}
+
+func (s *contentSpool) path(cid content.ID) string {
+	return filepath.Join(s.dir, cid.String())
+}
+
+func (s *contentSpool) put(ci content.Info, data gather.Bytes) error {
+	f, err := os.Create(s.path(ci.ContentID))
+	if err != nil {
+		return errors.Wrap(err, "unable to create spool file")
+	}
+
+	if _, err := data.WriteTo(f); err != nil {
+		f.Close() //nolint:errcheck
+
+		return errors.Wrap(err, "unable to write spool file")
+	}
+
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "unable to close spool file")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sizes[ci.ContentID] = int64(data.Length())
+	s.size += int64(data.Length())
+
+	return nil
+}
+
+func (s *contentSpool) has(cid content.ID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.sizes[cid]
+
+	return ok
+}
+
+func (s *contentSpool) get(cid content.ID) ([]byte, bool) {
+	if !s.has(cid) {
+		return nil, false
+	}
+
+	b, err := os.ReadFile(s.path(cid))
+	if err != nil {
+		return nil, false
+	}
+
+	return b, true
+}
+
+func (s *contentSpool) remove(ctx context.Context, cid content.ID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, ok := s.sizes[cid]
+	if !ok {
+		return
+	}
+
+	delete(s.sizes, cid)
+	s.size -= n
+
+	if err := os.Remove(s.path(cid)); err != nil {
+		log(ctx).Debugf("unable to remove spool file: %v", err)
+	}
+}
+
+func (s *contentSpool) totalSize() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.size
+}
+
+func (s *contentSpool) close(ctx context.Context) {
+	if err := os.RemoveAll(s.dir); err != nil {
+		log(ctx).Warnf("unable to remove spool directory %v: %v", s.dir, err)
+	}
+}
+
+// spoolContentReader serves contents from the spool, falling back to the repository.
+type spoolContentReader struct {
+	content.Reader
+
+	spool *contentSpool
+}
+
+func (r *spoolContentReader) GetContent(ctx context.Context, cid content.ID) ([]byte, error) {
+	if b, ok := r.spool.get(cid); ok {
+		return b, nil
+	}
+
+	//nolint:wrapcheck
+	return r.Reader.GetContent(ctx, cid)
+}
+
+// PrefetchContents is a no-op, since contents have been fetched into the spool.
+func (r *spoolContentReader) PrefetchContents(_ context.Context, _ []content.ID, _ string) []content.ID {
+	return nil
+}
+
+// spooledFile is a file which reads its contents from the spool.
+type spooledFile struct {
+	fs.File
+
+	objectID object.ID
+	cr       *spoolContentReader
+}
+
+// ObjectID implements object.HasObjectID.
+func (f *spooledFile) ObjectID() object.ID {
+	return f.objectID
+}
+
+func (f *spooledFile) Open(ctx context.Context) (fs.Reader, error) {
+	r, err := object.Open(ctx, f.cr, f.objectID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to open object: %v", f.objectID)
+	}
+
+	return &spooledFileReader{r, f.File}, nil
+}
+
+type spooledFileReader struct {
+	object.Reader
+
+	entry fs.Entry
+}
+
+func (r *spooledFileReader) Entry() (fs.Entry, error) {
+	return r.entry, nil
+}
@@ -0,0 +1,124 @@
+package restore_test
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/restore"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/kopia/kopia/snapshot/upload"
+)
+
+func TestPackOrderedRestore(t *testing.T) {
+	files := map[string][]byte{}
+
+	sourceRoot := mockfs.NewDirectory()
+
+	for i := range 3 {
+		dir := sourceRoot.AddDir(fmt.Sprintf("dir%v", i), 0o755)
+
+		for j := range 20 {
+			data := make([]byte, 10000+1000*j)
+			rand.Read(data)
+
+			name := fmt.Sprintf("file%v", j)
+			dir.AddFile(name, data, 0o644)
+			files[filepath.Join(fmt.Sprintf("dir%v", i), name)] = data
+		}
+	}
+
+	// file spanning multiple contents and a duplicate of another file.
+	large := make([]byte, 2500000)
+	rand.Read(large)
+	sourceRoot.AddFile("large", large, 0o644)
+	files["large"] = large
+
+	sourceRoot.AddFile("duplicate", files[filepath.Join("dir0", "file0")], 0o644)
+	files["duplicate"] = files[filepath.Join("dir0", "file0")]
+
+	cases := []struct {
+		name    string
+		options restore.Options
+	}{
+		{"regular", restore.Options{}},
+		{"pack-ordered", restore.Options{PackOrderedReads: true}},
+		{"pack-ordered-small-spool", restore.Options{PackOrderedReads: true, MaxSpoolSize: 200000}},
+		{"pack-ordered-sequential", restore.Options{PackOrderedReads: true, Parallel: 1, MaxSpoolSize: 1}},
+	}
+
+	getBlobCount := map[string]int64{}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+			man, err := upload.NewUploader(env.RepositoryWriter).Upload(ctx, sourceRoot, nil, snapshot.SourceInfo{})
+			require.NoError(t, err)
+			require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+			env.MustReopen(t)
+
+			rootEntry, err := snapshotfs.SnapshotRoot(env.Repository, man)
+			require.NoError(t, err)
+
+			targetDir := testutil.TempDirectory(t)
+			output := &restore.FilesystemOutput{
+				TargetPath:           targetDir,
+				OverwriteDirectories: true,
+				OverwriteFiles:       true,
+			}
+			require.NoError(t, output.Init(ctx))
+
+			before := getBlobRequests(env)
+
+			opt := tc.options
+			opt.SpoolDirectory = testutil.TempDirectory(t)
+			opt.RestoreDirEntryAtDepth = 1000
+
+			st, err := restore.Entry(ctx, env.Repository, output, rootEntry, opt)
+			require.NoError(t, err)
+
+			getBlobCount[tc.name] = getBlobRequests(env) - before
+
+			require.EqualValues(t, len(files), st.RestoredFileCount)
+			require.EqualValues(t, 4, st.RestoredDirCount)
+
+			for name, want := range files {
+				got, err := os.ReadFile(filepath.Join(targetDir, name))
+				require.NoError(t, err)
+				require.Equal(t, want, got, name)
+			}
+
+			// spool is cleaned up
+			spoolEntries, err := os.ReadDir(opt.SpoolDirectory)
+			require.NoError(t, err)
+			require.Empty(t, spoolEntries)
+		})
+	}
+
+	t.Logf("GetBlob requests: %v", getBlobCount)
+
+	require.Less(t, getBlobCount["pack-ordered"]*4, getBlobCount["regular"])
+	require.Less(t, getBlobCount["pack-ordered-small-spool"]*2, getBlobCount["regular"])
+}
+
+func getBlobRequests(env *repotesting.Environment) int64 {
+	var total int64
+
+	for _, m := range []string{"GetBlob-partial", "GetBlob-full"} {
+		if d := env.RepositoryMetrics().Snapshot(false).DurationDistributions["blob_storage_latency[method:"+m+"]"]; d != nil {
+			total += d.Count
+		}
+	}
+
+	return total
+}
@@ -0,0 +1,93 @@
+package restore_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/kopia/kopia/snapshot/restore"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/kopia/kopia/snapshot/upload"
+)
+
+func TestRestoreExpandedArchives(t *testing.T) {
+	var zipData bytes.Buffer
+
+	zw := zip.NewWriter(&zipData)
+
+	for name, content := range map[string]string{"dir/m1.txt": "member one", "m2.txt": "member two"} {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: mockfs.DefaultModTime})
+		require.NoError(t, err)
+
+		_, err = io.WriteString(w, content)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, zw.Close())
+
+	sourceRoot := mockfs.NewDirectory()
+	sourceRoot.AddFile("archive.zip", zipData.Bytes(), 0o640)
+
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	pol := *policy.DefaultPolicy
+	pol.FilesPolicy.ExpandArchives = []string{"*.zip"}
+
+	man, err := upload.NewUploader(env.RepositoryWriter).Upload(ctx, sourceRoot, policy.BuildTree(nil, &pol), snapshot.SourceInfo{})
+	require.NoError(t, err)
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	rootEntry, err := snapshotfs.SnapshotRoot(env.RepositoryWriter, man)
+	require.NoError(t, err)
+
+	restoreTo := func(opts restore.Options) string {
+		targetDir := testutil.TempDirectory(t)
+
+		o := &restore.FilesystemOutput{
+			TargetPath:           targetDir,
+			OverwriteDirectories: true,
+			OverwriteFiles:       true,
+			SkipOwners:           true,
+		}
+		require.NoError(t, o.Init(ctx))
+
+		opts.RestoreDirEntryAtDepth = math.MaxInt32
+
+		_, err := restore.Entry(ctx, env.RepositoryWriter, o, rootEntry, opts)
+		require.NoError(t, err)
+
+		return targetDir
+	}
+
+	// by default the original archive is restored.
+	restoredDir := restoreTo(restore.Options{})
+	require.Equal(t, []string{"archive.zip"}, listRestoredFiles(t, restoredDir))
+
+	st, err := os.Stat(filepath.Join(restoredDir, "archive.zip"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o640), st.Mode())
+	require.True(t, mockfs.DefaultModTime.Equal(st.ModTime()))
+
+	restored, err := os.ReadFile(filepath.Join(restoredDir, "archive.zip"))
+	require.NoError(t, err)
+	require.Equal(t, zipData.Bytes(), restored)
+
+	// the archive can also be extracted.
+	extractedDir := restoreTo(restore.Options{ExtractArchives: true})
+	require.Equal(t, []string{"archive.zip/", "archive.zip/dir/", "archive.zip/dir/m1.txt", "archive.zip/m2.txt"}, listRestoredFiles(t, extractedDir))
+
+	b, err := os.ReadFile(filepath.Join(extractedDir, "archive.zip", "dir", "m1.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "member one", string(b))
+}
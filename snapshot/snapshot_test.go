@@ -218,6 +218,26 @@ func TestUpdatePins(t *testing.T) {
 	require.Equal(t, []string{"a", "b", "d", "e"}, m.Pins)
 }
 
+func TestAnomalies(t *testing.T) {
+	m := snapshot.Manifest{}
+
+	require.Empty(t, m.Anomalies())
+
+	m.AddAnomaly(snapshot.AnomalyModifiedFiles, "all files were modified")
+	m.AddAnomaly(snapshot.AnomalyExtensionChurn, "all files were renamed")
+
+	require.Equal(t, map[string]string{
+		"tag:anomaly":                 "detected",
+		"tag:anomaly-modified-files":  "all files were modified",
+		"tag:anomaly-extension-churn": "all files were renamed",
+	}, m.Tags)
+
+	require.Equal(t, []snapshot.Anomaly{
+		{Kind: snapshot.AnomalyExtensionChurn, Description: "all files were renamed"},
+		{Kind: snapshot.AnomalyModifiedFiles, Description: "all files were modified"},
+	}, m.Anomalies())
+}
+
 // Helper to create a Manifest with given times.
 func newManifest(start, end time.Time) *snapshot.Manifest {
 	return &snapshot.Manifest{
@@ -0,0 +1,81 @@
+// Package snapshotanomaly reports anomalies detected during snapshots, such as mass changes of files
+// made by ransomware, and protects the last good snapshot from expiration.
+package snapshotanomaly
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/notification"
+	"github.com/kopia/kopia/notification/notifydata"
+	"github.com/kopia/kopia/notification/notifytemplate"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/logging"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+// PinName is the pin added to the previous snapshot when anomalies are detected in a snapshot.
+const PinName = "anomaly-detected"
+
+var log = logging.Module("snapshotanomaly")
+
+// Report sends a high-severity notification about anomalies detected in the provided snapshot, which must have been saved.
+// When enabled in the policy, the last complete snapshot among previous ones is pinned first,
+// which must be done before applying retention policy.
+func Report(ctx context.Context, rep repo.RepositoryWriter, man *snapshot.Manifest, previous []*snapshot.Manifest, pol *policy.Policy, opt notifytemplate.Options) error {
+	anomalies := man.Anomalies()
+	if len(anomalies) == 0 {
+		return nil
+	}
+
+	log(ctx).Warnf("Possible ransomware activity detected in snapshot %v of %v:", man.ID, man.Source)
+
+	for _, a := range anomalies {
+		log(ctx).Warnf("  %v: %v", a.Kind, a.Description)
+	}
+
+	ev := &notifydata.SnapshotAnomaly{
+		Source:     man.Source,
+		SnapshotID: string(man.ID),
+		StartTime:  man.StartTime.ToTime(),
+		Anomalies:  anomalies,
+	}
+
+	var pinErr error
+
+	if pol.AnomalyDetection.PinPreviousSnapshot.OrDefault(false) {
+		if prev := lastCompleteSnapshot(previous); prev != nil {
+			if pinErr = pinSnapshot(ctx, rep, prev); pinErr == nil {
+				log(ctx).Warnf("Pinned previous snapshot %v with %q.", prev.ID, PinName)
+
+				ev.PinnedSnapshotID = string(prev.ID)
+			}
+		}
+	}
+
+	notification.Send(ctx, rep, notifytemplate.SnapshotAnomaly, ev, notification.SeverityError, opt)
+
+	return pinErr
+}
+
+func lastCompleteSnapshot(previous []*snapshot.Manifest) *snapshot.Manifest {
+	var result *snapshot.Manifest
+
+	for _, m := range previous {
+		if m.IncompleteReason == "" && (result == nil || m.StartTime.After(result.StartTime)) {
+			result = m
+		}
+	}
+
+	return result
+}
+
+func pinSnapshot(ctx context.Context, rep repo.RepositoryWriter, m *snapshot.Manifest) error {
+	if !m.UpdatePins([]string{PinName}, nil) {
+		return nil
+	}
+
+	return errors.Wrap(snapshot.UpdateSnapshot(ctx, rep, m), "unable to pin previous snapshot")
+}
@@ -0,0 +1,95 @@
+package snapshotanomaly_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/notification/notifyprofile"
+	"github.com/kopia/kopia/notification/notifytemplate"
+	"github.com/kopia/kopia/notification/sender"
+	"github.com/kopia/kopia/notification/sender/testsender"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/kopia/kopia/snapshot/snapshotanomaly"
+)
+
+func TestReport(t *testing.T) {
+	ctx, te := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+	ctx = testsender.CaptureMessages(ctx)
+
+	require.NoError(t, notifyprofile.SaveProfile(ctx, te.RepositoryWriter, notifyprofile.Config{
+		ProfileName: "my-profile",
+		MethodConfig: sender.MethodConfig{
+			Type:   "testsender",
+			Config: &testsender.Options{Format: sender.FormatPlainText},
+		},
+	}))
+
+	si := te.LocalPathSourceInfo("/dummy/path")
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	saveSnapshot := func(start time.Time, incompleteReason string) *snapshot.Manifest {
+		t.Helper()
+
+		m := &snapshot.Manifest{
+			Source:           si,
+			StartTime:        fs.UTCTimestampFromTime(start),
+			EndTime:          fs.UTCTimestampFromTime(start.Add(time.Minute)),
+			IncompleteReason: incompleteReason,
+		}
+
+		_, err := snapshot.SaveSnapshot(ctx, te.RepositoryWriter, m)
+		require.NoError(t, err)
+
+		return m
+	}
+
+	complete := saveSnapshot(t0, "")
+	incomplete := saveSnapshot(t0.Add(time.Hour), "checkpoint")
+
+	pol := *policy.DefaultPolicy
+
+	// no anomalies, nothing to report.
+	clean := saveSnapshot(t0.Add(2*time.Hour), "")
+	require.NoError(t, snapshotanomaly.Report(ctx, te.RepositoryWriter, clean, []*snapshot.Manifest{complete, incomplete}, &pol, notifytemplate.DefaultOptions))
+	require.Empty(t, testsender.MessagesInContext(ctx))
+
+	// anomalies are reported without pinning by default.
+	suspicious := &snapshot.Manifest{Source: si, StartTime: fs.UTCTimestampFromTime(t0.Add(3 * time.Hour))}
+	suspicious.AddAnomaly(snapshot.AnomalyModifiedFiles, "all files were modified")
+
+	_, err := snapshot.SaveSnapshot(ctx, te.RepositoryWriter, suspicious)
+	require.NoError(t, err)
+
+	require.NoError(t, snapshotanomaly.Report(ctx, te.RepositoryWriter, suspicious, []*snapshot.Manifest{complete, incomplete}, &pol, notifytemplate.DefaultOptions))
+
+	msgs := testsender.MessagesInContext(ctx)
+	require.Len(t, msgs, 1)
+	require.Contains(t, msgs[0].Subject, "Possible ransomware activity detected")
+	require.Contains(t, msgs[0].Body, "all files were modified")
+	require.Empty(t, complete.Pins)
+
+	// with the policy, the last complete previous snapshot is pinned.
+	pol.AnomalyDetection.PinPreviousSnapshot = policy.NewOptionalBool(true)
+
+	require.NoError(t, snapshotanomaly.Report(ctx, te.RepositoryWriter, suspicious, []*snapshot.Manifest{complete, incomplete}, &pol, notifytemplate.DefaultOptions))
+
+	msgs = testsender.MessagesInContext(ctx)
+	require.Len(t, msgs, 2)
+	require.Contains(t, msgs[1].Body, string(complete.ID))
+
+	snaps, err := snapshot.ListSnapshots(ctx, te.RepositoryWriter, si)
+	require.NoError(t, err)
+
+	for _, m := range snaps {
+		if m.StartTime.Equal(complete.StartTime) {
+			require.Equal(t, []string{snapshotanomaly.PinName}, m.Pins)
+		} else {
+			require.Empty(t, m.Pins)
+		}
+	}
+}
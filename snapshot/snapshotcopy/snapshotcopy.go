@@ -0,0 +1,477 @@
+// Package snapshotcopy copies snapshots between repositories by transferring the contents they reference.
+package snapshotcopy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/compression"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/logging"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+var log = logging.Module("snapshotcopy")
+
+const (
+	// DefaultParallel is the default number of files copied in parallel within a directory.
+	DefaultParallel = 8
+
+	// DefaultCheckpointInterval is the default number of bytes written to the destination repository
+	// between flushes, which bounds the amount of work repeated when an interrupted copy is resumed.
+	DefaultCheckpointInterval = 1 << 30
+)
+
+// Options controls copying of snapshots.
+type Options struct {
+	Parallel           int
+	CheckpointInterval int64
+}
+
+// Stats describes the work performed by the Copier.
+type Stats struct {
+	CopiedSnapshots      int   `json:"copiedSnapshots"`
+	SkippedSnapshots     int   `json:"skippedSnapshots"`
+	CopiedContents       int64 `json:"copiedContents"`
+	CopiedBytes          int64 `json:"copiedBytes"`
+	ExistingContents     int64 `json:"existingContents"`
+	RewrittenDirectories int64 `json:"rewrittenDirectories"`
+}
+
+// Copier copies snapshots from the source repository to the destination repository.
+//
+// When both repositories compute content IDs the same way, contents are copied verbatim and snapshots
+// keep their object IDs. Otherwise, contents receive new IDs in the destination, which requires rewriting
+// index objects and directory manifests referring to them.
+type Copier struct {
+	src     repo.DirectRepository
+	dst     repo.DirectRepositoryWriter
+	opt     Options
+	sameIDs bool
+
+	mu sync.Mutex
+	// +checklocks:mu
+	translatedContents map[content.ID]content.ID
+	// +checklocks:mu
+	translatedDirectories map[object.ID]object.ID
+	// +checklocks:mu
+	bytesSinceCheckpoint int64
+
+	copiedSnapshots      atomic.Int32
+	skippedSnapshots     atomic.Int32
+	copiedContents       atomic.Int64
+	copiedBytes          atomic.Int64
+	existingContents     atomic.Int64
+	rewrittenDirectories atomic.Int64
+}
+
+// NewCopier returns a Copier from the source repository to the destination repository.
+func NewCopier(src repo.DirectRepository, dst repo.DirectRepositoryWriter, opt Options) *Copier {
+	if opt.Parallel <= 0 {
+		opt.Parallel = DefaultParallel
+	}
+
+	if opt.CheckpointInterval <= 0 {
+		opt.CheckpointInterval = DefaultCheckpointInterval
+	}
+
+	sf := src.ContentReader().ContentFormat()
+	df := dst.ContentReader().ContentFormat()
+
+	return &Copier{
+		src:                   src,
+		dst:                   dst,
+		opt:                   opt,
+		sameIDs:               sf.GetHashFunction() == df.GetHashFunction() && bytes.Equal(sf.GetHmacSecret(), df.GetHmacSecret()),
+		translatedContents:    map[content.ID]content.ID{},
+		translatedDirectories: map[object.ID]object.ID{},
+	}
+}
+
+// PreservesObjectIDs returns true if copied snapshots keep their object IDs.
+func (c *Copier) PreservesObjectIDs() bool {
+	return c.sameIDs
+}
+
+// Stats returns the statistics of copied snapshots.
+func (c *Copier) Stats() Stats {
+	return Stats{
+		CopiedSnapshots:      int(c.copiedSnapshots.Load()),
+		SkippedSnapshots:     int(c.skippedSnapshots.Load()),
+		CopiedContents:       c.copiedContents.Load(),
+		CopiedBytes:          c.copiedBytes.Load(),
+		ExistingContents:     c.existingContents.Load(),
+		RewrittenDirectories: c.rewrittenDirectories.Load(),
+	}
+}
+
+// CopySnapshot copies the provided snapshot manifest along with all contents it references and returns
+// the manifest saved in the destination repository. Snapshots that already exist in the destination,
+// which is the case for snapshots copied before the copy got interrupted, are not copied again.
+func (c *Copier) CopySnapshot(ctx context.Context, m *snapshot.Manifest) (*snapshot.Manifest, error) {
+	existing, err := c.findExisting(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing != nil {
+		log(ctx).Debugf("snapshot of %v at %v already exists as %v", m.Source, m.StartTime, existing.ID)
+		c.skippedSnapshots.Add(1)
+
+		return existing, nil
+	}
+
+	if m.RootEntry == nil {
+		return nil, errors.Errorf("snapshot %v has no root entry", m.ID)
+	}
+
+	newRoot, err := c.copyEntry(ctx, ".", m.RootEntry)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error copying snapshot %v", m.ID)
+	}
+
+	// manifest is copied preserving its times, description, tags and pins.
+	newm := *m
+	newm.RootEntry = newRoot
+
+	if _, err := snapshot.SaveSnapshot(ctx, c.dst, &newm); err != nil {
+		return nil, errors.Wrap(err, "error saving snapshot")
+	}
+
+	if err := c.checkpoint(ctx); err != nil {
+		return nil, err
+	}
+
+	c.copiedSnapshots.Add(1)
+
+	return &newm, nil
+}
+
+// findExisting returns the snapshot of the same source with the same start time in the destination repository.
+func (c *Copier) findExisting(ctx context.Context, m *snapshot.Manifest) (*snapshot.Manifest, error) {
+	previous, err := snapshot.ListSnapshots(ctx, c.dst, m.Source)
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing snapshots in destination repository")
+	}
+
+	for _, p := range previous {
+		if p.StartTime.Equal(m.StartTime) {
+			return p, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (c *Copier) copyEntry(ctx context.Context, relativePath string, de *snapshot.DirEntry) (*snapshot.DirEntry, error) {
+	result := *de
+
+	switch {
+	case de.ObjectID == object.EmptyID:
+		// nothing to copy
+
+	case de.Type == snapshot.EntryTypeDirectory:
+		oid, err := c.copyDirectory(ctx, relativePath, de.ObjectID)
+		if err != nil {
+			return nil, err
+		}
+
+		result.ObjectID = oid
+
+	default:
+		oid, err := c.copyObject(ctx, de.ObjectID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error copying %v", relativePath)
+		}
+
+		result.ObjectID = oid
+	}
+
+	if de.ArchiveFile != nil {
+		af, err := c.copyEntry(ctx, relativePath, de.ArchiveFile)
+		if err != nil {
+			return nil, err
+		}
+
+		result.ArchiveFile = af
+	}
+
+	return &result, nil
+}
+
+func (c *Copier) copyDirectory(ctx context.Context, relativePath string, oid object.ID) (object.ID, error) {
+	c.mu.Lock()
+	newOID, ok := c.translatedDirectories[oid]
+	c.mu.Unlock()
+
+	if ok {
+		return newOID, nil
+	}
+
+	dm, err := c.readDirManifest(ctx, oid)
+	if err != nil {
+		return object.EmptyID, errors.Wrapf(err, "error reading directory %v", relativePath)
+	}
+
+	eg, egctx := errgroup.WithContext(ctx)
+	eg.SetLimit(c.opt.Parallel)
+
+	for i, de := range dm.Entries {
+		childPath := path.Join(relativePath, de.Name)
+
+		if de.Type == snapshot.EntryTypeDirectory {
+			// subdirectories are copied sequentially, which bounds the number of goroutines.
+			newEntry, err := c.copyEntry(egctx, childPath, de)
+			if err != nil {
+				eg.Go(func() error { return err })
+				break
+			}
+
+			dm.Entries[i] = newEntry
+
+			continue
+		}
+
+		eg.Go(func() error {
+			newEntry, err := c.copyEntry(egctx, childPath, de)
+			if err != nil {
+				return err
+			}
+
+			dm.Entries[i] = newEntry
+
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return object.EmptyID, errors.Wrapf(err, "error copying contents of %v", relativePath)
+	}
+
+	if c.sameIDs {
+		// directory manifest is unchanged, copy it as-is.
+		newOID, err = c.copyObject(ctx, oid)
+	} else {
+		newOID, err = c.rewriteDirManifest(ctx, relativePath, oid, dm)
+	}
+
+	if err != nil {
+		return object.EmptyID, errors.Wrapf(err, "error copying directory %v", relativePath)
+	}
+
+	c.mu.Lock()
+	c.translatedDirectories[oid] = newOID
+	c.mu.Unlock()
+
+	return newOID, nil
+}
+
+func (c *Copier) readDirManifest(ctx context.Context, oid object.ID) (*snapshot.DirManifest, error) {
+	r, err := c.src.OpenObject(ctx, oid)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open directory object")
+	}
+
+	defer r.Close() //nolint:errcheck
+
+	var dm snapshot.DirManifest
+
+	if err := json.NewDecoder(r).Decode(&dm); err != nil {
+		return nil, errors.Wrap(err, "unable to parse directory manifest")
+	}
+
+	return &dm, nil
+}
+
+func (c *Copier) rewriteDirManifest(ctx context.Context, relativePath string, oid object.ID, dm *snapshot.DirManifest) (object.ID, error) {
+	c.rewrittenDirectories.Add(1)
+
+	//nolint:wrapcheck
+	return snapshotfs.WriteDirManifest(ctx, c.dst, relativePath, dm, c.metadataCompression(ctx, oid))
+}
+
+// metadataCompression returns the name of the compression used by the source object, adjusted for the destination.
+func (c *Copier) metadataCompression(ctx context.Context, oid object.ID) compression.Name {
+	for {
+		indexObjectID, ok := oid.IndexObjectID()
+		if !ok {
+			break
+		}
+
+		oid = indexObjectID
+	}
+
+	cid, _, _ := oid.ContentID()
+
+	ci, err := c.src.ContentReader().ContentInfo(ctx, cid)
+	if err != nil {
+		return ""
+	}
+
+	comp, err := c.destinationCompression(ctx, ci.CompressionHeaderID)
+	if err != nil || comp == content.NoCompression {
+		return ""
+	}
+
+	return compression.HeaderIDToName[comp]
+}
+
+// destinationCompression returns the compressor to be used in the destination for contents compressed
+// with the provided compressor in the source. Dictionary compressors are replaced with their equivalents
+// without dictionaries unless the destination has compression dictionaries enabled, in which case
+// the contents are compressed using dictionaries of the destination.
+func (c *Copier) destinationCompression(ctx context.Context, comp compression.HeaderID) (compression.HeaderID, error) {
+	cm := c.dst.ContentManager()
+
+	if comp == content.NoCompression || !cm.SupportsContentCompression() {
+		return content.NoCompression, nil
+	}
+
+	dc, ok := compression.ByHeaderID[comp].(compression.DictionaryCompressor)
+	if !ok {
+		return comp, nil
+	}
+
+	enabled, err := cm.CompressionDictionariesEnabled(ctx)
+	if err != nil {
+		return content.NoCompression, errors.Wrap(err, "unable to determine destination compression")
+	}
+
+	if enabled {
+		return comp, nil
+	}
+
+	return dc.HeaderIDWithoutDictionary(), nil
+}
+
+func (c *Copier) copyObject(ctx context.Context, oid object.ID) (object.ID, error) {
+	//nolint:wrapcheck
+	return object.TranslateObject(ctx, sourceContentReader{c.src.ContentReader()}, oid, c.copyContent, c.dst.NewObjectWriter, "")
+}
+
+// sourceContentReader adapts content.Reader of the source repository for reading objects.
+type sourceContentReader struct {
+	content.Reader
+}
+
+func (r sourceContentReader) PrefetchContents(_ context.Context, _ []content.ID, _ string) []content.ID {
+	return nil
+}
+
+// copyContent copies the content to the destination repository unless it already exists there and returns its ID
+// in the destination.
+func (c *Copier) copyContent(ctx context.Context, cid content.ID) (content.ID, error) {
+	c.mu.Lock()
+	newID, ok := c.translatedContents[cid]
+	c.mu.Unlock()
+
+	if ok {
+		return newID, nil
+	}
+
+	if c.sameIDs && c.existsInDestination(ctx, cid) {
+		// content is not decrypted or re-encrypted.
+		c.existingContents.Add(1)
+		c.rememberContent(cid, cid)
+
+		return cid, nil
+	}
+
+	cr := c.src.ContentReader()
+
+	ci, err := cr.ContentInfo(ctx, cid)
+	if err != nil {
+		return content.EmptyID, errors.Wrap(err, "unable to get content info")
+	}
+
+	data, err := cr.GetContent(ctx, cid)
+	if err != nil {
+		return content.EmptyID, errors.Wrap(err, "unable to read content")
+	}
+
+	if !c.sameIDs {
+		newID, err := content.IDFromHash(cid.Prefix(), c.dst.ContentReader().ContentFormat().HashFunc()(nil, gather.FromSlice(data)))
+		if err != nil {
+			return content.EmptyID, errors.Wrap(err, "unable to compute content ID")
+		}
+
+		if c.existsInDestination(ctx, newID) {
+			c.existingContents.Add(1)
+			c.rememberContent(cid, newID)
+
+			return newID, nil
+		}
+	}
+
+	comp, err := c.destinationCompression(ctx, ci.CompressionHeaderID)
+	if err != nil {
+		return content.EmptyID, err
+	}
+
+	newID, err = c.dst.ContentManager().WriteContent(ctx, gather.FromSlice(data), cid.Prefix(), comp)
+	if err != nil {
+		return content.EmptyID, errors.Wrap(err, "unable to write content")
+	}
+
+	if c.sameIDs && newID != cid {
+		return content.EmptyID, errors.Errorf("unexpected content ID %v of copied content %v", newID, cid)
+	}
+
+	c.copiedContents.Add(1)
+	c.copiedBytes.Add(int64(len(data)))
+	c.rememberContent(cid, newID)
+
+	if c.addCheckpointBytes(int64(len(data))) {
+		if err := c.checkpoint(ctx); err != nil {
+			return content.EmptyID, err
+		}
+	}
+
+	return newID, nil
+}
+
+func (c *Copier) existsInDestination(ctx context.Context, cid content.ID) bool {
+	ci, err := c.dst.ContentInfo(ctx, cid)
+
+	return err == nil && !ci.Deleted
+}
+
+func (c *Copier) rememberContent(cid, newID content.ID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.translatedContents[cid] = newID
+}
+
+// addCheckpointBytes records bytes written to the destination and returns true when a checkpoint is due.
+func (c *Copier) addCheckpointBytes(n int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.bytesSinceCheckpoint += n
+
+	if c.bytesSinceCheckpoint < c.opt.CheckpointInterval {
+		return false
+	}
+
+	c.bytesSinceCheckpoint = 0
+
+	return true
+}
+
+// checkpoint flushes the destination repository, so that copied contents are not copied again
+// if the copy is interrupted.
+func (c *Copier) checkpoint(ctx context.Context) error {
+	return errors.Wrap(c.dst.Flush(ctx), "error flushing destination repository")
+}
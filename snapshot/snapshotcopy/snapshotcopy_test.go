@@ -0,0 +1,220 @@
+package snapshotcopy_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/feature"
+	"github.com/kopia/kopia/internal/fshasher"
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/compression"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/format"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/kopia/kopia/snapshot/snapshotcopy"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/kopia/kopia/snapshot/upload"
+)
+
+func TestCopySnapshot(t *testing.T) {
+	cases := []struct {
+		name          string
+		opts          []repotesting.Options
+		preservesOIDs bool
+	}{
+		{
+			name:          "SameHashing",
+			preservesOIDs: true,
+		},
+		{
+			name: "DifferentHashing",
+			opts: []repotesting.Options{{
+				NewRepositoryOptions: func(nro *repo.NewRepositoryOptions) {
+					nro.BlockFormat.Hash = "BLAKE2B-256"
+					nro.BlockFormat.HMACSecret = []byte("another-hmac-secret")
+				},
+			}},
+		},
+		{
+			name: "DifferentSecret",
+			opts: []repotesting.Options{{
+				NewRepositoryOptions: func(nro *repo.NewRepositoryOptions) {
+					nro.BlockFormat.HMACSecret = []byte("another-hmac-secret")
+				},
+			}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, srcEnv := repotesting.NewEnvironment(t, format.FormatVersion3)
+			_, dstEnv := repotesting.NewEnvironment(t, format.FormatVersion3, tc.opts...)
+
+			sourceRoot := mockfs.NewDirectory()
+			sourceRoot.AddFile("small", []byte("small file"), 0o644)
+			sourceRoot.AddFile("large", randomBytes(2500000), 0o644)
+
+			sub := sourceRoot.AddDir("sub", 0o755)
+			sub.AddFile("duplicate", []byte("small file"), 0o644)
+			sub.AddSymlink("link", "../small", 0o777)
+
+			nested := sub.AddDir("nested", 0o755)
+
+			for i := range 10 {
+				nested.AddFile(fmt.Sprintf("file%v", i), randomBytes(1000), 0o644)
+			}
+
+			src := snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/path"}
+
+			man, err := upload.NewUploader(srcEnv.RepositoryWriter).Upload(ctx, sourceRoot, nil, src)
+			require.NoError(t, err)
+
+			man.Description = "some description"
+			man.Tags = map[string]string{"tag:foo": "bar"}
+			man.Pins = []string{"pin1"}
+			man.StartTime = man.StartTime.Add(-time.Hour)
+
+			_, err = snapshot.SaveSnapshot(ctx, srcEnv.RepositoryWriter, man)
+			require.NoError(t, err)
+			require.NoError(t, srcEnv.RepositoryWriter.Flush(ctx))
+
+			c := snapshotcopy.NewCopier(srcEnv.RepositoryWriter, dstEnv.RepositoryWriter, snapshotcopy.Options{})
+			require.Equal(t, tc.preservesOIDs, c.PreservesObjectIDs())
+
+			copied, err := c.CopySnapshot(ctx, man)
+			require.NoError(t, err)
+			require.NotEqual(t, man.ID, copied.ID)
+			require.Equal(t, man.Source, copied.Source)
+			require.Equal(t, man.Description, copied.Description)
+			require.Equal(t, man.Tags, copied.Tags)
+			require.Equal(t, man.Pins, copied.Pins)
+			require.True(t, man.StartTime.Equal(copied.StartTime))
+			require.True(t, man.EndTime.Equal(copied.EndTime))
+			require.Equal(t, man.RootEntry.DirSummary, copied.RootEntry.DirSummary)
+
+			if tc.preservesOIDs {
+				require.Equal(t, man.RootObjectID(), copied.RootObjectID())
+			} else {
+				require.NotEqual(t, man.RootObjectID(), copied.RootObjectID())
+			}
+
+			st := c.Stats()
+			require.Equal(t, 1, st.CopiedSnapshots)
+			require.Positive(t, st.CopiedContents)
+			require.Zero(t, st.ExistingContents)
+
+			dstEnv.MustReopen(t)
+
+			verifySameContents(t, srcEnv.RepositoryWriter, man, dstEnv.RepositoryWriter, copied)
+
+			loaded, err := snapshot.LoadSnapshot(ctx, dstEnv.RepositoryWriter, copied.ID)
+			require.NoError(t, err)
+			require.Equal(t, man.Tags, loaded.Tags)
+
+			// copying again is a no-op.
+			c2 := snapshotcopy.NewCopier(srcEnv.RepositoryWriter, dstEnv.RepositoryWriter, snapshotcopy.Options{})
+
+			again, err := c2.CopySnapshot(ctx, man)
+			require.NoError(t, err)
+			require.Equal(t, copied.ID, again.ID)
+			require.Equal(t, snapshotcopy.Stats{SkippedSnapshots: 1}, c2.Stats())
+
+			// another snapshot of the same data only copies the manifest.
+			man2, err := upload.NewUploader(srcEnv.RepositoryWriter).Upload(ctx, sourceRoot, nil, src)
+			require.NoError(t, err)
+
+			_, err = snapshot.SaveSnapshot(ctx, srcEnv.RepositoryWriter, man2)
+			require.NoError(t, err)
+
+			copied2, err := c2.CopySnapshot(ctx, man2)
+			require.NoError(t, err)
+			require.Equal(t, copied.RootObjectID(), copied2.RootObjectID())
+			require.Zero(t, c2.Stats().CopiedContents)
+			require.Equal(t, 1, c2.Stats().CopiedSnapshots)
+
+			snapshots, err := snapshot.ListSnapshots(ctx, dstEnv.RepositoryWriter, src)
+			require.NoError(t, err)
+			require.Len(t, snapshots, 2)
+		})
+	}
+}
+
+func TestCopySnapshotDictionaryCompression(t *testing.T) {
+	ctx, srcEnv := repotesting.NewEnvironment(t, format.FormatVersion3)
+	_, dstEnv := repotesting.NewEnvironment(t, format.FormatVersion3)
+
+	fm := srcEnv.RepositoryWriter.FormatManager()
+
+	mp, err := fm.GetMutableParameters(ctx)
+	require.NoError(t, err)
+
+	blobcfg, err := fm.BlobCfgBlob(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, fm.SetParameters(ctx, mp, blobcfg, []feature.Required{{Feature: format.FeatureCompressionDictionaries}}))
+
+	sourceRoot := mockfs.NewDirectory()
+	sourceRoot.AddFile("compressible", bytes.Repeat([]byte("compressible data "), 1000), 0o644)
+
+	pol := *policy.DefaultPolicy
+	pol.CompressionPolicy.CompressorName = "zstd-dict"
+
+	src := snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/path"}
+
+	man, err := upload.NewUploader(srcEnv.RepositoryWriter).Upload(ctx, sourceRoot, policy.BuildTree(nil, &pol), src)
+	require.NoError(t, err)
+
+	_, err = snapshot.SaveSnapshot(ctx, srcEnv.RepositoryWriter, man)
+	require.NoError(t, err)
+
+	// the destination does not allow dictionary compressors, so contents are compressed without dictionaries.
+	copied, err := snapshotcopy.NewCopier(srcEnv.RepositoryWriter, dstEnv.RepositoryWriter, snapshotcopy.Options{}).CopySnapshot(ctx, man)
+	require.NoError(t, err)
+
+	verifySameContents(t, srcEnv.RepositoryWriter, man, dstEnv.RepositoryWriter, copied)
+
+	headers := map[compression.HeaderID]bool{}
+
+	require.NoError(t, dstEnv.RepositoryWriter.ContentReader().IterateContents(ctx, content.IterateOptions{}, func(ci content.Info) error {
+		headers[ci.CompressionHeaderID] = true
+		return nil
+	}))
+
+	require.True(t, headers[compression.HeaderZstdDefault])
+	require.False(t, headers[compression.HeaderZstdDictDefault])
+}
+
+func verifySameContents(t *testing.T, srcRep repo.Repository, srcMan *snapshot.Manifest, dstRep repo.Repository, dstMan *snapshot.Manifest) {
+	t.Helper()
+
+	ctx := t.Context()
+
+	srcRoot, err := snapshotfs.SnapshotRoot(srcRep, srcMan)
+	require.NoError(t, err)
+
+	dstRoot, err := snapshotfs.SnapshotRoot(dstRep, dstMan)
+	require.NoError(t, err)
+
+	h1, err := fshasher.Hash(ctx, srcRoot)
+	require.NoError(t, err)
+
+	h2, err := fshasher.Hash(ctx, dstRoot)
+	require.NoError(t, err)
+
+	require.Equal(t, h1, h2)
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	rand.Read(b)
+
+	return b
+}
@@ -0,0 +1,219 @@
+// Package snapshotexport exports snapshots to self-contained archives and imports them back.
+//
+// An exported archive contains a complete repository holding the exported snapshots, which is
+// protected with its own password. It can be imported into another repository or opened directly
+// as read-only storage for browsing, mounting and restoring.
+package snapshotexport
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/archive"
+	"github.com/kopia/kopia/repo/blob/filesystem"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/logging"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotcopy"
+)
+
+var log = logging.Module("snapshotexport")
+
+// Export writes the provided snapshots along with all contents they reference to an archive protected
+// with the provided password.
+//
+// The archive is assembled in a temporary directory, which needs enough space to hold the exported contents.
+func Export(ctx context.Context, src repo.DirectRepository, snapshots []*snapshot.Manifest, w io.Writer, password string, opt snapshotcopy.Options) (snapshotcopy.Stats, error) {
+	tmpDir, err := os.MkdirTemp("", "kopia-export")
+	if err != nil {
+		return snapshotcopy.Stats{}, errors.Wrap(err, "unable to create temporary directory")
+	}
+
+	defer os.RemoveAll(tmpDir) //nolint:errcheck
+
+	st, err := filesystem.New(ctx, &filesystem.Options{Path: filepath.Join(tmpDir, "repository")}, true)
+	if err != nil {
+		return snapshotcopy.Stats{}, errors.Wrap(err, "unable to create temporary storage")
+	}
+
+	defer st.Close(ctx) //nolint:errcheck
+
+	if err := repo.Initialize(ctx, st, &repo.NewRepositoryOptions{}, password); err != nil {
+		return snapshotcopy.Stats{}, errors.Wrap(err, "unable to initialize exported repository")
+	}
+
+	dst, err := connectAndOpen(ctx, st, tmpDir, password, false)
+	if err != nil {
+		return snapshotcopy.Stats{}, err
+	}
+
+	var stats snapshotcopy.Stats
+
+	err = repo.DirectWriteSession(ctx, dst, repo.WriteSessionOptions{Purpose: "snapshot export"}, func(ctx context.Context, w repo.DirectRepositoryWriter) error {
+		c := snapshotcopy.NewCopier(src, w, opt)
+
+		for _, m := range snapshots {
+			log(ctx).Infof("Exporting snapshot of %v at %v", m.Source, m.StartTime.ToTime())
+
+			if _, err := c.CopySnapshot(ctx, m); err != nil {
+				return errors.Wrapf(err, "error exporting snapshot %v", m.ID)
+			}
+		}
+
+		stats = c.Stats()
+
+		return nil
+	})
+
+	if cerr := dst.Close(ctx); err == nil {
+		err = errors.Wrap(cerr, "error closing exported repository")
+	}
+
+	if err != nil {
+		return snapshotcopy.Stats{}, errors.Wrap(err, "error exporting snapshots")
+	}
+
+	if err := archive.Write(ctx, w, st); err != nil {
+		return snapshotcopy.Stats{}, errors.Wrap(err, "error writing archive")
+	}
+
+	return stats, nil
+}
+
+// Archive is an exported archive opened as a read-only repository.
+type Archive struct {
+	repo.DirectRepository
+
+	tmpDir string
+}
+
+// Close closes the repository and removes temporary files associated with it.
+func (a *Archive) Close(ctx context.Context) error {
+	err := a.DirectRepository.Close(ctx)
+
+	if rerr := os.RemoveAll(a.tmpDir); err == nil {
+		err = rerr
+	}
+
+	return errors.Wrap(err, "error closing archive")
+}
+
+// Snapshots returns all snapshots in the archive ordered by source and start time.
+func (a *Archive) Snapshots(ctx context.Context) ([]*snapshot.Manifest, error) {
+	sources, err := snapshot.ListSources(ctx, a)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list sources")
+	}
+
+	var result []*snapshot.Manifest
+
+	for _, si := range sources {
+		snapshots, err := snapshot.ListSnapshots(ctx, a, si)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to list snapshots of %v", si)
+		}
+
+		sort.Slice(snapshots, func(i, j int) bool {
+			return snapshots[i].StartTime.Before(snapshots[j].StartTime)
+		})
+
+		result = append(result, snapshots...)
+	}
+
+	return result, nil
+}
+
+// OpenArchive opens the exported archive as a read-only repository.
+func OpenArchive(ctx context.Context, filename, password string) (*Archive, error) {
+	st, err := archive.New(ctx, &archive.Options{Path: filename}, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open archive")
+	}
+
+	defer st.Close(ctx) //nolint:errcheck
+
+	tmpDir, err := os.MkdirTemp("", "kopia-import")
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create temporary directory")
+	}
+
+	rep, err := connectAndOpen(ctx, st, tmpDir, password, true)
+	if err != nil {
+		os.RemoveAll(tmpDir) //nolint:errcheck
+
+		return nil, err
+	}
+
+	return &Archive{rep, tmpDir}, nil
+}
+
+// Import copies all snapshots from the exported archive into the repository and returns the imported manifests.
+// Snapshots that already exist in the repository are not imported again.
+func Import(ctx context.Context, filename, password string, dst repo.DirectRepositoryWriter, opt snapshotcopy.Options) ([]*snapshot.Manifest, snapshotcopy.Stats, error) {
+	a, err := OpenArchive(ctx, filename, password)
+	if err != nil {
+		return nil, snapshotcopy.Stats{}, err
+	}
+
+	defer a.Close(ctx) //nolint:errcheck
+
+	snapshots, err := a.Snapshots(ctx)
+	if err != nil {
+		return nil, snapshotcopy.Stats{}, err
+	}
+
+	c := snapshotcopy.NewCopier(a, dst, opt)
+
+	var result []*snapshot.Manifest
+
+	for _, m := range snapshots {
+		log(ctx).Infof("Importing snapshot of %v at %v", m.Source, m.StartTime.ToTime())
+
+		newm, err := c.CopySnapshot(ctx, m)
+		if err != nil {
+			return nil, c.Stats(), errors.Wrapf(err, "error importing snapshot %v", m.ID)
+		}
+
+		result = append(result, newm)
+	}
+
+	return result, c.Stats(), nil
+}
+
+// connectAndOpen connects to the repository in the provided storage keeping the configuration and caches
+// in the provided directory and opens it.
+func connectAndOpen(ctx context.Context, st blob.Storage, dir, password string, readOnly bool) (repo.DirectRepository, error) {
+	configFile := filepath.Join(dir, "repository.config")
+
+	if err := repo.Connect(ctx, configFile, st, password, &repo.ConnectOptions{
+		ClientOptions: repo.ClientOptions{
+			ReadOnly: readOnly,
+		},
+		CachingOptions: content.CachingOptions{
+			CacheDirectory: filepath.Join(dir, "cache"),
+		},
+	}); err != nil {
+		return nil, errors.Wrap(err, "unable to connect")
+	}
+
+	rep, err := repo.Open(ctx, configFile, password, &repo.Options{})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open")
+	}
+
+	dr, ok := rep.(repo.DirectRepository)
+	if !ok {
+		rep.Close(ctx) //nolint:errcheck
+
+		return nil, errors.New("unexpected repository type")
+	}
+
+	return dr, nil
+}
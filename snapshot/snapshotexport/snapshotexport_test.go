@@ -0,0 +1,112 @@
+package snapshotexport_test
+
+import (
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/fshasher"
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotcopy"
+	"github.com/kopia/kopia/snapshot/snapshotexport"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/kopia/kopia/snapshot/upload"
+)
+
+const exportPassword = "export-password"
+
+func TestExportImport(t *testing.T) {
+	ctx, srcEnv := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	sourceRoot := mockfs.NewDirectory()
+	sourceRoot.AddFile("small", []byte("small file"), 0o644)
+	sourceRoot.AddFile("large", randomBytes(3000000), 0o644)
+	sourceRoot.AddDir("sub", 0o755).AddFile("other", randomBytes(1000), 0o644)
+
+	src := snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/path"}
+
+	man, err := upload.NewUploader(srcEnv.RepositoryWriter).Upload(ctx, sourceRoot, nil, src)
+	require.NoError(t, err)
+
+	man.Tags = map[string]string{"tag:legal": "hold"}
+
+	_, err = snapshot.SaveSnapshot(ctx, srcEnv.RepositoryWriter, man)
+	require.NoError(t, err)
+	require.NoError(t, srcEnv.RepositoryWriter.Flush(ctx))
+
+	fname := filepath.Join(testutil.TempDirectory(t), "export.kopia")
+
+	f, err := os.Create(fname)
+	require.NoError(t, err)
+
+	st, err := snapshotexport.Export(ctx, srcEnv.RepositoryWriter, []*snapshot.Manifest{man}, f, exportPassword, snapshotcopy.Options{})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.Equal(t, 1, st.CopiedSnapshots)
+
+	_, err = snapshotexport.OpenArchive(ctx, fname, "wrong-password")
+	require.Error(t, err)
+
+	// archive can be browsed without the original repository.
+	a, err := snapshotexport.OpenArchive(ctx, fname, exportPassword)
+	require.NoError(t, err)
+
+	snapshots, err := a.Snapshots(ctx)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	require.Equal(t, man.Tags, snapshots[0].Tags)
+	require.True(t, man.StartTime.Equal(snapshots[0].StartTime))
+
+	verifySameContents(t, srcEnv.RepositoryWriter, man, a, snapshots[0])
+	require.NoError(t, a.Close(ctx))
+
+	// import into another repository.
+	_, dstEnv := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	imported, st, err := snapshotexport.Import(ctx, fname, exportPassword, dstEnv.RepositoryWriter, snapshotcopy.Options{})
+	require.NoError(t, err)
+	require.Len(t, imported, 1)
+	require.Equal(t, 1, st.CopiedSnapshots)
+	require.NoError(t, dstEnv.RepositoryWriter.Flush(ctx))
+
+	verifySameContents(t, srcEnv.RepositoryWriter, man, dstEnv.RepositoryWriter, imported[0])
+
+	// importing again does not duplicate snapshots.
+	_, st, err = snapshotexport.Import(ctx, fname, exportPassword, dstEnv.RepositoryWriter, snapshotcopy.Options{})
+	require.NoError(t, err)
+	require.Equal(t, snapshotcopy.Stats{SkippedSnapshots: 1}, st)
+}
+
+func verifySameContents(t *testing.T, srcRep repo.Repository, srcMan *snapshot.Manifest, dstRep repo.Repository, dstMan *snapshot.Manifest) {
+	t.Helper()
+
+	ctx := t.Context()
+
+	srcRoot, err := snapshotfs.SnapshotRoot(srcRep, srcMan)
+	require.NoError(t, err)
+
+	dstRoot, err := snapshotfs.SnapshotRoot(dstRep, dstMan)
+	require.NoError(t, err)
+
+	h1, err := fshasher.Hash(ctx, srcRoot)
+	require.NoError(t, err)
+
+	h2, err := fshasher.Hash(ctx, dstRoot)
+	require.NoError(t, err)
+
+	require.Equal(t, h1, h2)
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	rand.Read(b)
+
+	return b
+}
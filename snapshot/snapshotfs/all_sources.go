@@ -14,7 +14,8 @@
 )
 
 type repositoryAllSources struct {
-	rep repo.Repository
+	rep         repo.Repository
+	timeMachine bool
 }
 
 func (s *repositoryAllSources) IsDir() bool {
@@ -89,9 +90,10 @@ func (s *repositoryAllSources) Iterate(ctx context.Context) (fs.DirectoryIterato
 
 	for u := range users {
 		entries = append(entries, &sourceDirectories{
-			rep:      s.rep,
-			userHost: u,
-			name:     name2safe[u],
+			rep:         s.rep,
+			userHost:    u,
+			name:        name2safe[u],
+			timeMachine: s.timeMachine,
 		})
 	}
 
@@ -0,0 +1,210 @@
+package snapshotfs
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// FileVersion describes a single version of a file or directory, which was present
+// with the same object ID in one or more consecutive snapshots.
+type FileVersion struct {
+	ObjectID object.ID          `json:"obj"`
+	Type     snapshot.EntryType `json:"type"`
+	Size     int64              `json:"size"`
+	ModTime  fs.UTCTimestamp    `json:"mtime"`
+
+	FirstSnapshotID   manifest.ID     `json:"firstSnapshotID"`
+	FirstSnapshotTime fs.UTCTimestamp `json:"firstSnapshotTime"`
+	LastSnapshotID    manifest.ID     `json:"lastSnapshotID"`
+	LastSnapshotTime  fs.UTCTimestamp `json:"lastSnapshotTime"`
+	SnapshotCount     int             `json:"snapshotCount"`
+}
+
+// fileHistoryWalker resolves the same path in a sequence of snapshots, remembering object IDs
+// of directories on the path so that unchanged directories don't need to be read again.
+type fileHistoryWalker struct {
+	rep   repo.Repository
+	parts []string
+
+	// object IDs of directories along the path in the previous snapshot and the resulting entry.
+	prevDirs  []object.ID
+	prevEntry *snapshot.DirEntry
+
+	dirsRead int
+}
+
+func (w *fileHistoryWalker) resolve(ctx context.Context, root *snapshot.DirEntry) (*snapshot.DirEntry, error) {
+	current := root
+
+	var dirs []object.ID
+
+	for i, part := range w.parts {
+		if current.Type != snapshot.EntryTypeDirectory {
+			current = nil
+			break
+		}
+
+		if i < len(w.prevDirs) && w.prevDirs[i] == current.ObjectID {
+			// the remainder of the path is unchanged since the previous snapshot.
+			dirs = append(dirs, w.prevDirs[i:]...)
+			current = w.prevEntry
+
+			break
+		}
+
+		dirs = append(dirs, current.ObjectID)
+
+		w.dirsRead++
+
+		e, err := DirectoryEntry(w.rep, current.ObjectID, nil).Child(ctx, part)
+		if errors.Is(err, fs.ErrEntryNotFound) {
+			current = nil
+			break
+		}
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading directory %v", current.ObjectID)
+		}
+
+		h, ok := e.(snapshot.HasDirEntry)
+		if !ok {
+			return nil, errors.Errorf("entry %q does not have directory entry", part)
+		}
+
+		current = h.DirEntry()
+	}
+
+	w.prevDirs = dirs
+	w.prevEntry = current
+
+	return current, nil
+}
+
+// FileHistory returns distinct versions of the entry at the provided slash-separated path relative
+// to the snapshot root in the provided snapshots, ordered by snapshot start time. Consecutive snapshots
+// where the entry has the same object ID are collapsed into a single version, snapshots where the entry
+// does not exist are skipped.
+func FileHistory(ctx context.Context, rep repo.Repository, manifests []*snapshot.Manifest, relPath string) ([]*FileVersion, error) {
+	w := &fileHistoryWalker{rep: rep}
+
+	return w.history(ctx, manifests, relPath)
+}
+
+func (w *fileHistoryWalker) history(ctx context.Context, manifests []*snapshot.Manifest, relPath string) ([]*FileVersion, error) {
+	for _, p := range strings.Split(relPath, "/") {
+		if p != "" && p != "." {
+			w.parts = append(w.parts, p)
+		}
+	}
+
+	sorted := append([]*snapshot.Manifest(nil), manifests...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].StartTime.Before(sorted[j].StartTime)
+	})
+
+	var (
+		result []*FileVersion
+		last   *FileVersion
+	)
+
+	for _, m := range sorted {
+		if m.RootEntry == nil {
+			continue
+		}
+
+		de, err := w.resolve(ctx, m.RootEntry)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error resolving %q in snapshot %v", relPath, m.ID)
+		}
+
+		if de == nil {
+			last = nil
+			continue
+		}
+
+		if last != nil && last.ObjectID == de.ObjectID {
+			last.LastSnapshotID = m.ID
+			last.LastSnapshotTime = m.StartTime
+			last.SnapshotCount++
+
+			continue
+		}
+
+		last = &FileVersion{
+			ObjectID:          de.ObjectID,
+			Type:              de.Type,
+			Size:              de.FileSize,
+			ModTime:           de.ModTime,
+			FirstSnapshotID:   m.ID,
+			FirstSnapshotTime: m.StartTime,
+			LastSnapshotID:    m.ID,
+			LastSnapshotTime:  m.StartTime,
+			SnapshotCount:     1,
+		}
+
+		result = append(result, last)
+	}
+
+	return result, nil
+}
+
+// SourceFileHistory describes versions of a file found in snapshots of a single source.
+type SourceFileHistory struct {
+	Source   snapshot.SourceInfo `json:"source"`
+	Path     string              `json:"path"`
+	Versions []*FileVersion      `json:"versions"`
+}
+
+// FindFileHistory returns versions of the file or directory identified by the provided source info
+// in snapshots of that source and all sources of its parent directories.
+func FindFileHistory(ctx context.Context, rep repo.Repository, si snapshot.SourceInfo) ([]*SourceFileHistory, error) {
+	var result []*SourceFileHistory
+
+	src := si
+
+	for src.Path != "" {
+		manifests, err := snapshot.ListSnapshots(ctx, rep, src)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error listing snapshots of %v", src)
+		}
+
+		if len(manifests) > 0 {
+			relPath, err := filepath.Rel(src.Path, si.Path)
+			if err != nil {
+				return nil, errors.Wrap(err, "unable to determine relative path")
+			}
+
+			relPath = filepath.ToSlash(relPath)
+
+			versions, err := FileHistory(ctx, rep, manifests, relPath)
+			if err != nil {
+				return nil, err
+			}
+
+			result = append(result, &SourceFileHistory{
+				Source:   src,
+				Path:     relPath,
+				Versions: versions,
+			})
+		}
+
+		parentPath := filepath.Dir(src.Path)
+		if parentPath == src.Path {
+			break
+		}
+
+		src.Path = parentPath
+	}
+
+	return result, nil
+}
@@ -0,0 +1,108 @@
+package snapshotfs_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/kopia/kopia/snapshot/upload"
+)
+
+type countingRepository struct {
+	repo.Repository
+
+	opened int
+}
+
+func (r *countingRepository) OpenObject(ctx context.Context, id object.ID) (object.Reader, error) {
+	r.opened++
+
+	//nolint:wrapcheck
+	return r.Repository.OpenObject(ctx, id)
+}
+
+func TestFileHistory(t *testing.T) {
+	ctx, te := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	u := upload.NewUploader(te.RepositoryWriter)
+	si := te.LocalPathSourceInfo("/dummy/path")
+
+	root := mockfs.NewDirectory()
+	b := root.AddDir("a", 0o755).AddDir("b", 0o755)
+	b.AddFile("notes.md", []byte{1, 2, 3}, 0o644)
+	other := root.AddDir("other", 0o755)
+	other.AddFile("x", []byte{1}, 0o644)
+
+	var manifests []*snapshot.Manifest
+
+	takeSnapshot := func() {
+		t.Helper()
+
+		man, err := u.Upload(ctx, root, nil, si)
+		require.NoError(t, err)
+
+		_, err = snapshot.SaveSnapshot(ctx, te.RepositoryWriter, man)
+		require.NoError(t, err)
+
+		manifests = append(manifests, man)
+	}
+
+	takeSnapshot() // 0
+	takeSnapshot() // 1 - identical
+
+	other.AddFile("x", []byte{2}, 0o644)
+	takeSnapshot() // 2 - notes.md unchanged, but root changed
+
+	b.AddFile("notes.md", []byte{1, 2, 3, 4}, 0o644)
+	takeSnapshot() // 3 - new version
+
+	b.Remove("notes.md")
+	takeSnapshot() // 4 - deleted
+
+	b.AddFile("notes.md", []byte{1, 2, 3, 4}, 0o644)
+	takeSnapshot() // 5 - restored
+
+	cr := &countingRepository{Repository: te.RepositoryWriter}
+
+	versions, err := snapshotfs.FileHistory(ctx, cr, manifests, "a/b/notes.md")
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+
+	require.Equal(t, int64(3), versions[0].Size)
+	require.Equal(t, 3, versions[0].SnapshotCount)
+	require.Equal(t, manifests[0].ID, versions[0].FirstSnapshotID)
+	require.Equal(t, manifests[2].ID, versions[0].LastSnapshotID)
+
+	require.Equal(t, int64(4), versions[1].Size)
+	require.Equal(t, 1, versions[1].SnapshotCount)
+	require.Equal(t, manifests[3].ID, versions[1].FirstSnapshotID)
+
+	require.Equal(t, versions[1].ObjectID, versions[2].ObjectID)
+	require.Equal(t, manifests[5].ID, versions[2].FirstSnapshotID)
+
+	// 3 directories in the first snapshot, none in the identical one, root only when
+	// other directory changed and all 3 in each of the remaining snapshots.
+	require.Equal(t, 3+0+1+3+3+3, cr.opened)
+
+	hist, err := snapshotfs.FindFileHistory(ctx, te.RepositoryWriter, snapshot.SourceInfo{
+		Host:     si.Host,
+		UserName: si.UserName,
+		Path:     "/dummy/path/a/b/notes.md",
+	})
+	require.NoError(t, err)
+	require.Len(t, hist, 1)
+	require.Equal(t, si, hist[0].Source)
+	require.Equal(t, "a/b/notes.md", hist[0].Path)
+	require.Len(t, hist[0].Versions, 3)
+
+	versions, err = snapshotfs.FileHistory(ctx, te.RepositoryWriter, manifests, "no/such/file")
+	require.NoError(t, err)
+	require.Empty(t, versions)
+}
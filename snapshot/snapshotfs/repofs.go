@@ -96,6 +96,9 @@ type repositoryDirectory struct {
 	mu         sync.Mutex
 	summary    *fs.DirectorySummary
 	dirEntries map[string]*snapshot.DirEntry
+
+	// entries in the order in which they are stored, directories first, then ordered by name.
+	orderedEntries []*snapshot.DirEntry
 }
 
 type repositoryFile struct {
@@ -141,13 +144,31 @@ func (rd *repositoryDirectory) Iterate(ctx context.Context) (fs.DirectoryIterato
 		return nil, err
 	}
 
-	var entries []fs.Entry
+	rd.mu.Lock()
+	entries := rd.orderedEntries
+	rd.mu.Unlock()
 
-	for _, de := range rd.dirEntries {
-		entries = append(entries, EntryFromDirEntry(rd.repo, de))
+	return &repositoryDirectoryIterator{rd.repo, entries}, nil
+}
+
+// repositoryDirectoryIterator returns entries in the order in which they are stored, creating them lazily.
+type repositoryDirectoryIterator struct {
+	repo    repo.Repository
+	entries []*snapshot.DirEntry
+}
+
+func (it *repositoryDirectoryIterator) Next(_ context.Context) (fs.Entry, error) {
+	if len(it.entries) == 0 {
+		return nil, nil
 	}
 
-	return fs.StaticIterator(entries, nil), nil
+	de := it.entries[0]
+	it.entries = it.entries[1:]
+
+	return EntryFromDirEntry(it.repo, de), nil
+}
+
+func (it *repositoryDirectoryIterator) Close() {
 }
 
 func (rd *repositoryDirectory) ensureDirEntriesLoaded(ctx context.Context) error {
@@ -193,11 +214,19 @@ func (rd *repositoryDirectory) loadLocked(ctx context.Context) error {
 
 	rd.summary = summ
 	rd.dirEntries = map[string]*snapshot.DirEntry{}
+	rd.orderedEntries = nil
 
 	for _, e := range ent {
 		rd.dirEntries[e.Name] = e
 	}
 
+	// when names are duplicated, the last entry wins.
+	for _, e := range ent {
+		if rd.dirEntries[e.Name] == e {
+			rd.orderedEntries = append(rd.orderedEntries, e)
+		}
+	}
+
 	return nil
 }
 
@@ -206,6 +235,7 @@ func (rd *repositoryDirectory) Close() {
 	defer rd.mu.Unlock()
 
 	rd.dirEntries = nil
+	rd.orderedEntries = nil
 }
 
 func (rf *repositoryFile) Open(ctx context.Context) (fs.Reader, error) {
@@ -276,6 +306,17 @@ func withFileInfo(r object.Reader, e fs.Entry) fs.Reader {
 	return &readCloserWithFileInfo{r, e}
 }
 
+// ArchiveFile returns the original file of the archive expanded into the provided directory or nil
+// if the entry is not a directory of expanded archive members.
+func ArchiveFile(e fs.Entry) fs.File {
+	rd, ok := e.(*repositoryDirectory)
+	if !ok || rd.metadata.ArchiveFile == nil || rd.metadata.ArchiveFile.Type != snapshot.EntryTypeFile {
+		return nil
+	}
+
+	return &repositoryFile{repositoryEntry{rd.metadata.ArchiveFile, rd.repo}}
+}
+
 // DirectoryEntry returns fs.Directory based on repository object with the specified ID.
 // The existence or validity of the directory object is not validated until its contents are read.
 func DirectoryEntry(rep repo.Repository, objectID object.ID, dirSummary *fs.DirectorySummary) fs.Directory {
@@ -111,6 +111,11 @@ func (w *TreeWalker) processEntry(ctx context.Context, e fs.Entry, entryPath str
 	}
 
 	if dir, ok := e.(fs.Directory); ok {
+		// original files of expanded archives are stored next to the directories of their members.
+		if af := ArchiveFile(dir); af != nil && !w.alreadyProcessed(ctx, af) {
+			w.processEntry(ctx, af, entryPath)
+		}
+
 		w.processDirEntry(ctx, dir, entryPath)
 	}
 }
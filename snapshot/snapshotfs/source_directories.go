@@ -16,9 +16,10 @@
 )
 
 type sourceDirectories struct {
-	rep      repo.Repository
-	userHost string
-	name     string
+	rep         repo.Repository
+	userHost    string
+	name        string
+	timeMachine bool
 }
 
 func (s *sourceDirectories) IsDir() bool {
@@ -98,7 +99,11 @@ func (s *sourceDirectories) Iterate(ctx context.Context) (fs.DirectoryIterator,
 	var entries []fs.Entry
 
 	for _, src := range sources {
-		entries = append(entries, &sourceSnapshots{s.rep, src, name2safe[src.Path]})
+		if s.timeMachine {
+			entries = append(entries, &sourceTimeMachine{virtualDirectory: virtualDirectory{name2safe[src.Path], s.rep.Time()}, rep: s.rep, src: src})
+		} else {
+			entries = append(entries, &sourceSnapshots{s.rep, src, name2safe[src.Path]})
+		}
 	}
 
 	return fs.StaticIterator(entries, nil), nil
@@ -81,19 +81,7 @@ func (s *sourceSnapshots) Iterate(ctx context.Context) (fs.DirectoryIterator, er
 			name += fmt.Sprintf(" (%v)", m.IncompleteReason)
 		}
 
-		de := &snapshot.DirEntry{
-			Name:        name,
-			Permissions: 0o555, //nolint:mnd
-			Type:        snapshot.EntryTypeDirectory,
-			ModTime:     m.StartTime,
-			ObjectID:    m.RootObjectID(),
-		}
-
-		if m.RootEntry != nil {
-			de.DirSummary = m.RootEntry.DirSummary
-		}
-
-		entries = append(entries, EntryFromDirEntry(s.rep, de))
+		entries = append(entries, snapshotRootEntry(s.rep, m, name))
 	}
 
 	return fs.StaticIterator(entries, nil), nil
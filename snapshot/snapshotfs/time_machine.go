@@ -0,0 +1,355 @@
+package snapshotfs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// Names of the directories of each source in the time machine tree.
+const (
+	TimeMachineLatest   = "latest"
+	TimeMachinePrevious = "previous"
+	TimeMachineByDate   = "by-date"
+	TimeMachineVersions = "versions"
+)
+
+// virtualDirectory provides the attributes of read-only directories which don't exist in snapshots.
+type virtualDirectory struct {
+	name    string
+	modTime time.Time
+}
+
+func (d *virtualDirectory) IsDir() bool {
+	return true
+}
+
+func (d *virtualDirectory) Name() string {
+	return d.name
+}
+
+func (d *virtualDirectory) Mode() os.FileMode {
+	return 0o555 | os.ModeDir //nolint:mnd
+}
+
+func (d *virtualDirectory) ModTime() time.Time {
+	return d.modTime
+}
+
+func (d *virtualDirectory) Size() int64 {
+	return 0
+}
+
+func (d *virtualDirectory) Sys() any {
+	return nil
+}
+
+func (d *virtualDirectory) Owner() fs.OwnerInfo {
+	return fs.OwnerInfo{}
+}
+
+func (d *virtualDirectory) Device() fs.DeviceInfo {
+	return fs.DeviceInfo{}
+}
+
+func (d *virtualDirectory) LocalFilesystemPath() string {
+	return ""
+}
+
+func (d *virtualDirectory) SupportsMultipleIterations() bool {
+	return true
+}
+
+func (d *virtualDirectory) Close() {
+}
+
+// sourceTimeMachine contains the 'latest', 'previous', 'by-date' and 'versions' directories of a single source.
+type sourceTimeMachine struct {
+	virtualDirectory
+
+	rep repo.Repository
+	src snapshot.SourceInfo
+
+	mu sync.Mutex
+	// +checklocks:mu
+	loaded bool
+	// +checklocks:mu
+	manifests []*snapshot.Manifest // sorted by start time
+	// +checklocks:mu
+	complete []*snapshot.Manifest // snapshots which are not incomplete, sorted by start time
+}
+
+//nolint:gochecknoglobals
+var sourceTimeMachineEntryNames = []string{TimeMachineByDate, TimeMachineLatest, TimeMachinePrevious, TimeMachineVersions}
+
+// snapshots returns all snapshots and the complete snapshots of the source, which are listed when first needed.
+func (s *sourceTimeMachine) snapshots(ctx context.Context) (manifests, complete []*snapshot.Manifest, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.loaded {
+		manifests, err := snapshot.ListSnapshots(ctx, s.rep, s.src)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "unable to list snapshots")
+		}
+
+		s.manifests = snapshot.SortByTime(manifests, false)
+
+		for _, m := range s.manifests {
+			if m.IncompleteReason == "" && m.RootEntry != nil {
+				s.complete = append(s.complete, m)
+			}
+		}
+
+		s.loaded = true
+	}
+
+	return s.manifests, s.complete, nil
+}
+
+// entry returns the directory with the provided name or nil if there are no snapshots to provide it.
+func (s *sourceTimeMachine) entry(name string, manifests, complete []*snapshot.Manifest) fs.Entry {
+	n := len(complete)
+
+	switch {
+	case name == TimeMachineByDate:
+		return &dateDirectory{virtualDirectory{TimeMachineByDate, s.rep.Time()}, s.rep, manifests, 0}
+
+	case name == TimeMachineLatest && n > 0:
+		return snapshotRootEntry(s.rep, complete[n-1], TimeMachineLatest)
+
+	case name == TimeMachinePrevious && n > 1:
+		return snapshotRootEntry(s.rep, complete[n-2], TimeMachinePrevious)
+
+	case name == TimeMachineVersions && n > 0:
+		return newVersionsEntry(s.rep, complete, EntryFromDirEntry(s.rep, complete[n-1].RootEntry), TimeMachineVersions, ".")
+
+	default:
+		return nil
+	}
+}
+
+func (s *sourceTimeMachine) Child(ctx context.Context, name string) (fs.Entry, error) {
+	manifests, complete, err := s.snapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if e := s.entry(name, manifests, complete); e != nil {
+		return e, nil
+	}
+
+	return nil, fs.ErrEntryNotFound
+}
+
+func (s *sourceTimeMachine) Iterate(ctx context.Context) (fs.DirectoryIterator, error) {
+	manifests, complete, err := s.snapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []fs.Entry
+
+	for _, name := range sourceTimeMachineEntryNames {
+		if e := s.entry(name, manifests, complete); e != nil {
+			entries = append(entries, e)
+		}
+	}
+
+	return fs.StaticIterator(entries, nil), nil
+}
+
+// dateDirectory groups snapshots by year, month and day of their start time.
+type dateDirectory struct {
+	virtualDirectory
+
+	rep       repo.Repository
+	manifests []*snapshot.Manifest // sorted by start time
+	level     int                  // 0 - years, 1 - months, 2 - days, 3 - snapshots
+}
+
+const dateDirectorySnapshotsLevel = 3
+
+//nolint:gochecknoglobals
+var dateDirectoryFormats = []string{"2006", "01", "02", "150405"}
+
+func (d *dateDirectory) Child(ctx context.Context, name string) (fs.Entry, error) {
+	//nolint:wrapcheck
+	return fs.IterateEntriesAndFindChild(ctx, d, name)
+}
+
+func (d *dateDirectory) Iterate(_ context.Context) (fs.DirectoryIterator, error) {
+	if d.level == dateDirectorySnapshotsLevel {
+		return fs.StaticIterator(d.snapshotEntries(), nil), nil
+	}
+
+	var entries []fs.Entry
+
+	for _, m := range d.manifests {
+		name := m.StartTime.Format(dateDirectoryFormats[d.level])
+
+		var last *dateDirectory
+
+		if len(entries) > 0 {
+			last = entries[len(entries)-1].(*dateDirectory) //nolint:forcetypeassert
+		}
+
+		if last == nil || last.name != name {
+			last = &dateDirectory{virtualDirectory{name, m.StartTime.ToTime()}, d.rep, nil, d.level + 1}
+			entries = append(entries, last)
+		}
+
+		last.manifests = append(last.manifests, m)
+	}
+
+	return fs.StaticIterator(entries, nil), nil
+}
+
+func (d *dateDirectory) snapshotEntries() []fs.Entry {
+	names := map[string]string{}
+
+	for _, m := range d.manifests {
+		name := m.StartTime.Format(dateDirectoryFormats[d.level])
+		if m.IncompleteReason != "" {
+			name += fmt.Sprintf(" (%v)", m.IncompleteReason)
+		}
+
+		names[string(m.ID)] = name
+	}
+
+	// multiple snapshots may have started in the same second.
+	names = disambiguateSafeNames(names)
+
+	var entries []fs.Entry
+
+	for _, m := range d.manifests {
+		entries = append(entries, snapshotRootEntry(d.rep, m, names[string(m.ID)]))
+	}
+
+	return entries
+}
+
+// versionsDirectory mirrors a directory of the latest snapshot, in which each file is replaced with a directory
+// containing the distinct versions of the file.
+type versionsDirectory struct {
+	virtualDirectory
+
+	rep       repo.Repository
+	manifests []*snapshot.Manifest // complete snapshots sorted by start time
+	dir       fs.Directory
+	relPath   string
+}
+
+func newVersionsEntry(rep repo.Repository, manifests []*snapshot.Manifest, e fs.Entry, name, relPath string) fs.Entry {
+	if d, ok := e.(fs.Directory); ok {
+		return &versionsDirectory{virtualDirectory{name, e.ModTime()}, rep, manifests, d, relPath}
+	}
+
+	return &fileVersionsDirectory{virtualDirectory{name, e.ModTime()}, rep, manifests, relPath}
+}
+
+func (d *versionsDirectory) Child(ctx context.Context, name string) (fs.Entry, error) {
+	e, err := d.dir.Child(ctx, name)
+	if err != nil {
+		//nolint:wrapcheck
+		return nil, err
+	}
+
+	return newVersionsEntry(d.rep, d.manifests, e, name, path.Join(d.relPath, name)), nil
+}
+
+func (d *versionsDirectory) Iterate(ctx context.Context) (fs.DirectoryIterator, error) {
+	var entries []fs.Entry
+
+	if err := fs.IterateEntries(ctx, d.dir, func(_ context.Context, e fs.Entry) error {
+		entries = append(entries, newVersionsEntry(d.rep, d.manifests, e, e.Name(), path.Join(d.relPath, e.Name())))
+		return nil
+	}); err != nil {
+		return nil, errors.Wrapf(err, "error reading %v", d.relPath)
+	}
+
+	return fs.StaticIterator(entries, nil), nil
+}
+
+// fileVersionsDirectory contains the distinct versions of a single file, named after the start time
+// of the first snapshot containing each version and the extension of the file.
+type fileVersionsDirectory struct {
+	virtualDirectory
+
+	rep       repo.Repository
+	manifests []*snapshot.Manifest
+	relPath   string
+}
+
+func (d *fileVersionsDirectory) Child(ctx context.Context, name string) (fs.Entry, error) {
+	//nolint:wrapcheck
+	return fs.IterateEntriesAndFindChild(ctx, d, name)
+}
+
+func (d *fileVersionsDirectory) Iterate(ctx context.Context) (fs.DirectoryIterator, error) {
+	versions, err := FileHistory(ctx, d.rep, d.manifests, d.relPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get versions of %v", d.relPath)
+	}
+
+	ext := path.Ext(d.relPath)
+
+	var entries []fs.Entry
+
+	for _, v := range versions {
+		if v.Type == snapshot.EntryTypeDirectory {
+			continue
+		}
+
+		entries = append(entries, EntryFromDirEntry(d.rep, &snapshot.DirEntry{
+			Name:        v.FirstSnapshotTime.Format("20060102-150405") + ext,
+			Permissions: 0o444, //nolint:mnd
+			Type:        v.Type,
+			ModTime:     v.ModTime,
+			FileSize:    v.Size,
+			ObjectID:    v.ObjectID,
+		}))
+	}
+
+	return fs.StaticIterator(entries, nil), nil
+}
+
+// snapshotRootEntry returns the root directory of the provided snapshot with the provided name.
+func snapshotRootEntry(rep repo.Repository, m *snapshot.Manifest, name string) fs.Entry {
+	de := &snapshot.DirEntry{
+		Name:        name,
+		Permissions: 0o555, //nolint:mnd
+		Type:        snapshot.EntryTypeDirectory,
+		ModTime:     m.StartTime,
+		ObjectID:    m.RootObjectID(),
+	}
+
+	if m.RootEntry != nil {
+		de.DirSummary = m.RootEntry.DirSummary
+	}
+
+	return EntryFromDirEntry(rep, de)
+}
+
+// TimeMachineEntry returns fs.Directory that contains all snapshot sources found in the repository,
+// each of which provides 'latest' and 'previous' snapshots, snapshots organized by date under 'by-date'
+// and the distinct versions of each file under 'versions'. Snapshots are loaded lazily when browsing.
+func TimeMachineEntry(rep repo.Repository) fs.Directory {
+	return &repositoryAllSources{rep: rep, timeMachine: true}
+}
+
+var (
+	_ fs.Directory = (*sourceTimeMachine)(nil)
+	_ fs.Directory = (*dateDirectory)(nil)
+	_ fs.Directory = (*versionsDirectory)(nil)
+	_ fs.Directory = (*fileVersionsDirectory)(nil)
+)
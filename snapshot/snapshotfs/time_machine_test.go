@@ -0,0 +1,149 @@
+package snapshotfs_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/kopia/kopia/snapshot/upload"
+)
+
+func TestTimeMachine(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	u := upload.NewUploader(env.RepositoryWriter)
+	si := snapshot.SourceInfo{UserName: "some-user", Host: "some-host", Path: "/some/path"}
+
+	root := mockfs.NewDirectory()
+	sub := root.AddDir("sub", 0o755)
+	sub.AddFile("notes.txt", []byte("v1"), 0o644)
+	root.AddFile("static", []byte("static"), 0o644)
+
+	takeSnapshot := func(timestamp, incompleteReason string) {
+		t.Helper()
+
+		man, err := u.Upload(ctx, root, nil, si)
+		require.NoError(t, err)
+
+		ts, err := time.Parse(time.RFC3339, timestamp)
+		require.NoError(t, err)
+
+		man.IncompleteReason = incompleteReason
+		mustWriteSnapshotManifest(ctx, t, env.RepositoryWriter, si, fs.UTCTimestampFromTime(ts), man)
+	}
+
+	takeSnapshot("2024-12-31T23:00:00Z", "")
+
+	sub.AddFile("notes.txt", []byte("v2"), 0o644)
+	takeSnapshot("2025-01-02T10:00:00Z", "")
+	takeSnapshot("2025-01-02T11:00:00Z", "")
+
+	sub.AddFile("notes.txt", []byte("v3"), 0o644)
+	takeSnapshot("2025-01-02T12:00:00Z", "")
+
+	sub.AddFile("notes.txt", []byte("v4"), 0o644)
+	takeSnapshot("2025-01-02T13:00:00Z", "checkpoint")
+
+	tm := snapshotfs.TimeMachineEntry(env.RepositoryWriter)
+
+	const srcDir = "some-user@some-host/some_path/"
+
+	snapshotRoots := []string{
+		srcDir + "by-date/2024/12/31/230000/",
+		srcDir + "by-date/2025/01/02/100000/",
+		srcDir + "by-date/2025/01/02/110000/",
+		srcDir + "by-date/2025/01/02/120000/",
+		srcDir + "by-date/2025/01/02/130000 (checkpoint)/",
+		srcDir + "latest/",
+		srcDir + "previous/",
+	}
+
+	gotNames := iterateAllNames(ctx, t, tm, "")
+
+	// contents of snapshot roots are verified below.
+	for name := range gotNames {
+		for _, r := range snapshotRoots {
+			if strings.HasPrefix(name, r) && name != r {
+				delete(gotNames, name)
+			}
+		}
+	}
+
+	wantNames := map[string]struct{}{
+		"some-user@some-host/":                                {},
+		srcDir:                                                {},
+		srcDir + "by-date/":                                   {},
+		srcDir + "by-date/2024/":                              {},
+		srcDir + "by-date/2024/12/":                           {},
+		srcDir + "by-date/2024/12/31/":                        {},
+		srcDir + "by-date/2025/":                              {},
+		srcDir + "by-date/2025/01/":                           {},
+		srcDir + "by-date/2025/01/02/":                        {},
+		srcDir + "versions/":                                  {},
+		srcDir + "versions/static/":                           {},
+		srcDir + "versions/static/20241231-230000":            {},
+		srcDir + "versions/sub/":                              {},
+		srcDir + "versions/sub/notes.txt/":                    {},
+		srcDir + "versions/sub/notes.txt/20241231-230000.txt": {},
+		srcDir + "versions/sub/notes.txt/20250102-100000.txt": {},
+		srcDir + "versions/sub/notes.txt/20250102-120000.txt": {},
+	}
+
+	for _, r := range snapshotRoots {
+		wantNames[r] = struct{}{}
+	}
+
+	require.Equal(t, wantNames, gotNames)
+
+	readFile := func(elements ...string) string {
+		t.Helper()
+
+		e, err := snapshotfs.GetNestedEntry(ctx, tm, elements)
+		require.NoError(t, err)
+
+		r, err := testutil.EnsureType[fs.File](t, e).Open(ctx)
+		require.NoError(t, err)
+
+		defer r.Close()
+
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+
+		return string(data)
+	}
+
+	// incomplete snapshots are not used as 'latest' or 'previous'.
+	require.Equal(t, "v3", readFile("some-user@some-host", "some_path", "latest", "sub", "notes.txt"))
+	require.Equal(t, "v2", readFile("some-user@some-host", "some_path", "previous", "sub", "notes.txt"))
+	require.Equal(t, "v4", readFile("some-user@some-host", "some_path", "by-date", "2025", "01", "02", "130000 (checkpoint)", "sub", "notes.txt"))
+	require.Equal(t, "v1", readFile("some-user@some-host", "some_path", "versions", "sub", "notes.txt", "20241231-230000.txt"))
+	require.Equal(t, "v2", readFile("some-user@some-host", "some_path", "versions", "sub", "notes.txt", "20250102-100000.txt"))
+
+	srcTM, err := snapshotfs.GetNestedEntry(ctx, tm, []string{"some-user@some-host", "some_path"})
+	require.NoError(t, err)
+
+	_, err = testutil.EnsureType[fs.Directory](t, srcTM).Child(ctx, "no-such-entry")
+	require.ErrorIs(t, err, fs.ErrEntryNotFound)
+
+	// snapshots are listed once per source directory, newer snapshots show up when it's looked up again.
+	latest, err := testutil.EnsureType[fs.Directory](t, srcTM).Child(ctx, snapshotfs.TimeMachineLatest)
+	require.NoError(t, err)
+
+	sub.AddFile("notes.txt", []byte("v5"), 0o644)
+	takeSnapshot("2025-01-02T14:00:00Z", "")
+
+	latest2, err := testutil.EnsureType[fs.Directory](t, srcTM).Child(ctx, snapshotfs.TimeMachineLatest)
+	require.NoError(t, err)
+	require.Equal(t, latest.ModTime(), latest2.ModTime())
+
+	require.Equal(t, "v5", readFile("some-user@some-host", "some_path", "latest", "sub", "notes.txt"))
+}
@@ -23,40 +23,54 @@
 	"github.com/kopia/kopia/repo/object"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/kopia/kopia/snapshot/snapshotsearch"
 )
 
 // User-visible log output.
 var userLog = logging.Module("snapshotgc")
 
-func findInUseContentIDs(ctx context.Context, log *contentlog.Logger, rep repo.Repository, used *bigmap.Set) error {
+func markObjectInUse(ctx context.Context, rep repo.Repository, used *bigmap.Set, oid object.ID) error {
+	contentIDs, verr := rep.VerifyObject(ctx, oid)
+	if verr != nil {
+		return errors.Wrapf(verr, "error verifying %v", oid)
+	}
+
+	var cidbuf [128]byte
+
+	for _, cid := range contentIDs {
+		used.Put(ctx, cid.Append(cidbuf[:0]))
+	}
+
+	return nil
+}
+
+// findInUseContentIDs marks contents reachable from snapshots and their search indexes as used
+// and returns search indexes of snapshots that no longer exist.
+func findInUseContentIDs(ctx context.Context, log *contentlog.Logger, rep repo.Repository, used *bigmap.Set) ([]*snapshotsearch.IndexManifest, error) {
+	// search indexes are written after their snapshots, so listing them first ensures that
+	// an index written concurrently is not mistaken for the index of a deleted snapshot.
+	indexes, err := snapshotsearch.ListIndexes(ctx, rep, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list search indexes")
+	}
+
 	ids, err := snapshot.ListSnapshotManifests(ctx, rep, nil, nil)
 	if err != nil {
-		return errors.Wrap(err, "unable to list snapshot manifest IDs")
+		return nil, errors.Wrap(err, "unable to list snapshot manifest IDs")
 	}
 
 	manifests, err := snapshot.LoadSnapshots(ctx, rep, ids)
 	if err != nil {
-		return errors.Wrap(err, "unable to load manifest IDs")
+		return nil, errors.Wrap(err, "unable to load manifest IDs")
 	}
 
 	w, twerr := snapshotfs.NewTreeWalker(ctx, snapshotfs.TreeWalkerOptions{
 		EntryCallback: func(ctx context.Context, _ fs.Entry, oid object.ID, _ string) error {
-			contentIDs, verr := rep.VerifyObject(ctx, oid)
-			if verr != nil {
-				return errors.Wrapf(verr, "error verifying %v", oid)
-			}
-
-			var cidbuf [128]byte
-
-			for _, cid := range contentIDs {
-				used.Put(ctx, cid.Append(cidbuf[:0]))
-			}
-
-			return nil
+			return markObjectInUse(ctx, rep, used, oid)
 		},
 	})
 	if twerr != nil {
-		return errors.Wrap(twerr, "unable to create tree walker")
+		return nil, errors.Wrap(twerr, "unable to create tree walker")
 	}
 
 	defer w.Close(ctx)
@@ -66,15 +80,33 @@ func findInUseContentIDs(ctx context.Context, log *contentlog.Logger, rep repo.R
 	for _, m := range manifests {
 		root, err := snapshotfs.SnapshotRoot(rep, m)
 		if err != nil {
-			return errors.Wrap(err, "unable to get snapshot root")
+			return nil, errors.Wrap(err, "unable to get snapshot root")
 		}
 
 		if err := w.Process(ctx, root, ""); err != nil {
-			return errors.Wrap(err, "error processing snapshot root")
+			return nil, errors.Wrap(err, "error processing snapshot root")
 		}
 	}
 
-	return nil
+	// objects of orphaned indexes are kept until the next run, because indexes written concurrently
+	// may use them as their base. Indexes share most of their bases, so each object is processed once.
+	marked := map[object.ID]bool{}
+
+	for _, im := range indexes {
+		for _, oid := range im.ObjectIDs() {
+			if marked[oid] {
+				continue
+			}
+
+			if err := markObjectInUse(ctx, rep, used, oid); err != nil {
+				return nil, errors.Wrap(err, "error processing search index")
+			}
+
+			marked[oid] = true
+		}
+	}
+
+	return snapshotsearch.FindOrphanedIndexes(indexes, manifests), nil
 }
 
 // Run performs garbage collection on all the snapshots in the repository.
@@ -98,10 +130,19 @@ func runInternal(ctx context.Context, rep repo.DirectRepositoryWriter, gcDelete
 	}
 	defer used.Close(ctx)
 
-	if err := findInUseContentIDs(ctx, log, rep, used); err != nil {
+	orphanedIndexes, err := findInUseContentIDs(ctx, log, rep, used)
+	if err != nil {
 		return nil, errors.Wrap(err, "unable to find in-use content ID")
 	}
 
+	if gcDelete {
+		for _, im := range orphanedIndexes {
+			if err := rep.DeleteManifest(ctx, im.ID); err != nil {
+				return nil, errors.Wrapf(err, "unable to delete search index of snapshot %v", im.SnapshotID)
+			}
+		}
+	}
+
 	return findUnreferencedAndRepairRereferenced(ctx, log, rep, gcDelete, safety, maintenanceStartTime, used)
 }
 
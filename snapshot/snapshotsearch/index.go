@@ -0,0 +1,517 @@
+// Package snapshotsearch maintains per-snapshot indexes of file names, which allow finding files
+// across all snapshots without walking snapshot trees.
+package snapshotsearch
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/compression"
+	"github.com/kopia/kopia/repo/logging"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+// ManifestType is the value of the "type" label for search index manifests.
+const ManifestType = "snapshot-search-index"
+
+// SnapshotIDLabel is the manifest label that holds the ID of the indexed snapshot.
+const SnapshotIDLabel = "snapshotID"
+
+const (
+	indexVersion    = 1
+	indexCompressor = compression.Name("zstd-fastest")
+
+	// maxIndexChainLength limits the number of base indexes which must be loaded to read an index.
+	maxIndexChainLength = 16
+)
+
+var log = logging.Module("kopia/snapshotsearch")
+
+// IndexManifest describes search index of a single snapshot.
+type IndexManifest struct {
+	ID            manifest.ID         `json:"-"`
+	SnapshotID    manifest.ID         `json:"snapshotID"`
+	Source        snapshot.SourceInfo `json:"source"`
+	IndexObjectID object.ID           `json:"indexObjectID"`
+
+	// BaseIndexObjectIDs are the objects of the indexes this index was built on, nearest first.
+	BaseIndexObjectIDs []object.ID `json:"baseIndexObjectIDs,omitempty"`
+
+	// number of directories and entries stored in the index object itself.
+	Directories int `json:"directories"`
+	Entries     int `json:"entries"`
+}
+
+// ObjectIDs returns the IDs of all objects needed to read the index.
+func (im *IndexManifest) ObjectIDs() []object.ID {
+	return append([]object.ID{im.IndexObjectID}, im.BaseIndexObjectIDs...)
+}
+
+// indexEntry is a single entry of an indexed directory.
+type indexEntry struct {
+	Name     string          `json:"n"`
+	ObjectID object.ID       `json:"o"`
+	Size     int64           `json:"s,omitempty"`
+	ModTime  fs.UTCTimestamp `json:"m,omitempty"`
+	IsDir    bool            `json:"d,omitempty"`
+}
+
+// indexData is the contents of the index object. Directories are keyed by their object IDs,
+// so identical subtrees are stored once. Directories found in the base index, if any, are not
+// stored again, so indexes of subsequent snapshots only contain directories which have changed.
+type indexData struct {
+	Version     int                     `json:"version"`
+	Base        object.ID               `json:"base,omitempty"`
+	Root        indexEntry              `json:"root"`
+	Directories map[string][]indexEntry `json:"dirs"`
+}
+
+func (d *indexData) entryCount() int {
+	n := 0
+
+	for _, entries := range d.Directories {
+		n += len(entries)
+	}
+
+	return n
+}
+
+func indexLabels(man *snapshot.Manifest) map[string]string {
+	return map[string]string{
+		manifest.TypeLabelKey:  ManifestType,
+		SnapshotIDLabel:        string(man.ID),
+		snapshot.HostnameLabel: man.Source.Host,
+		snapshot.UsernameLabel: man.Source.UserName,
+		snapshot.PathLabel:     man.Source.Path,
+	}
+}
+
+func sourceLabels(si snapshot.SourceInfo) map[string]string {
+	return map[string]string{
+		manifest.TypeLabelKey:  ManifestType,
+		snapshot.HostnameLabel: si.Host,
+		snapshot.UsernameLabel: si.UserName,
+		snapshot.PathLabel:     si.Path,
+	}
+}
+
+// ListIndexes returns manifests of all search indexes in the repository, optionally limited to the provided source.
+func ListIndexes(ctx context.Context, rep repo.Repository, src *snapshot.SourceInfo) ([]*IndexManifest, error) {
+	labels := map[string]string{
+		manifest.TypeLabelKey: ManifestType,
+	}
+
+	if src != nil {
+		labels = sourceLabels(*src)
+	}
+
+	entries, err := repo.QueryManifests(ctx, rep, manifest.Query{Labels: labels})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to find search index manifests")
+	}
+
+	var result []*IndexManifest
+
+	for _, e := range entries {
+		im := &IndexManifest{}
+
+		if _, err := rep.GetManifest(ctx, e.ID, im); err != nil {
+			if errors.Is(err, manifest.ErrNotFound) {
+				continue
+			}
+
+			return nil, errors.Wrapf(err, "unable to load search index manifest %v", e.ID)
+		}
+
+		im.ID = e.ID
+		result = append(result, im)
+	}
+
+	return result, nil
+}
+
+func findIndexForSnapshot(ctx context.Context, rep repo.Repository, snapshotID manifest.ID) (*IndexManifest, error) {
+	entries, err := rep.FindManifests(ctx, map[string]string{
+		manifest.TypeLabelKey: ManifestType,
+		SnapshotIDLabel:       string(snapshotID),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to find search index manifest")
+	}
+
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	im := &IndexManifest{}
+
+	if _, err := rep.GetManifest(ctx, entries[0].ID, im); err != nil {
+		return nil, errors.Wrap(err, "unable to load search index manifest")
+	}
+
+	im.ID = entries[0].ID
+
+	return im, nil
+}
+
+// BuildIndex builds and stores the search index of the provided snapshot, which must have been saved.
+// Directories unchanged since the most recently indexed snapshot of the same source are copied from
+// its index instead of being read from the repository.
+func BuildIndex(ctx context.Context, rep repo.RepositoryWriter, man *snapshot.Manifest) (*IndexManifest, error) {
+	if man.ID == "" {
+		return nil, errors.New("snapshot has not been saved")
+	}
+
+	if man.RootEntry == nil {
+		return nil, errors.New("snapshot has no root entry")
+	}
+
+	if existing, err := findIndexForSnapshot(ctx, rep, man.ID); err != nil || existing != nil {
+		return existing, err
+	}
+
+	b := &indexBuilder{
+		rep: rep,
+		d: &indexData{
+			Version:     indexVersion,
+			Root:        toIndexEntry(man.RootEntry),
+			Directories: map[string][]indexEntry{},
+		},
+		inPreviousCache: map[string]bool{},
+	}
+
+	var bases []object.ID
+
+	previousManifest, previous := loadPreviousIndex(ctx, rep, man.Source)
+	if previous != nil {
+		b.previous = previous
+
+		// the previous index is used as a base unless the chain of bases would become too long,
+		// in which case its directories are copied to start a new chain.
+		if len(previousManifest.BaseIndexObjectIDs) < maxIndexChainLength {
+			b.d.Base = previousManifest.IndexObjectID
+			bases = previousManifest.ObjectIDs()
+		}
+	}
+
+	if b.d.Root.IsDir {
+		if err := b.indexDirectory(ctx, man.RootEntry.ObjectID); err != nil {
+			return nil, err
+		}
+	}
+
+	oid, err := writeIndex(ctx, rep, b.d)
+	if err != nil {
+		return nil, err
+	}
+
+	im := &IndexManifest{
+		SnapshotID:         man.ID,
+		Source:             man.Source,
+		IndexObjectID:      oid,
+		BaseIndexObjectIDs: bases,
+		Directories:        len(b.d.Directories),
+		Entries:            b.d.entryCount(),
+	}
+
+	id, err := rep.PutManifest(ctx, indexLabels(man), im)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to write search index manifest")
+	}
+
+	im.ID = id
+
+	return im, nil
+}
+
+// loadPreviousIndex returns the most recently written index of the provided source or nil.
+func loadPreviousIndex(ctx context.Context, rep repo.Repository, si snapshot.SourceInfo) (*IndexManifest, *indexData) {
+	entries, err := repo.QueryManifests(ctx, rep, manifest.Query{Labels: sourceLabels(si), Newest: 1})
+	if err != nil || len(entries) == 0 {
+		return nil, nil
+	}
+
+	im := &IndexManifest{}
+
+	if _, err := rep.GetManifest(ctx, entries[0].ID, im); err != nil {
+		log(ctx).Debugf("unable to load previous search index manifest: %v", err)
+		return nil, nil
+	}
+
+	d, err := (&indexLoader{rep: rep}).load(ctx, im.IndexObjectID)
+	if err != nil {
+		log(ctx).Debugf("unable to load previous search index: %v", err)
+		return nil, nil
+	}
+
+	return im, d
+}
+
+// indexBuilder builds the index of a single snapshot.
+type indexBuilder struct {
+	rep      repo.Repository
+	d        *indexData
+	previous *indexData // previous index of the same source including its bases or nil
+
+	inPreviousCache map[string]bool
+}
+
+func (b *indexBuilder) indexDirectory(ctx context.Context, oid object.ID) error {
+	key := oid.String()
+
+	if _, ok := b.d.Directories[key]; ok {
+		return nil
+	}
+
+	if b.inPrevious(key) {
+		if b.d.Base == object.EmptyID {
+			b.copyFromPrevious(key)
+		}
+
+		return nil
+	}
+
+	var entries []indexEntry
+
+	err := fs.IterateEntries(ctx, snapshotfs.DirectoryEntry(b.rep, oid, nil), func(_ context.Context, e fs.Entry) error {
+		h, ok := e.(snapshot.HasDirEntry)
+		if !ok {
+			return nil
+		}
+
+		entries = append(entries, toIndexEntry(h.DirEntry()))
+
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "unable to read directory %v", oid)
+	}
+
+	b.d.Directories[key] = entries
+
+	for _, e := range entries {
+		if !e.IsDir {
+			continue
+		}
+
+		if err := b.indexDirectory(ctx, e.ObjectID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// inPrevious determines whether the entire subtree rooted at the provided directory is in the previous index.
+// The previous index should always be complete, but if it isn't, the missing subtrees are read again.
+func (b *indexBuilder) inPrevious(key string) bool {
+	if b.previous == nil {
+		return false
+	}
+
+	if v, ok := b.inPreviousCache[key]; ok {
+		return v
+	}
+
+	entries, result := b.previous.Directories[key]
+
+	for _, e := range entries {
+		if e.IsDir && !b.inPrevious(e.ObjectID.String()) {
+			result = false
+			break
+		}
+	}
+
+	b.inPreviousCache[key] = result
+
+	return result
+}
+
+// copyFromPrevious copies the subtree rooted at the provided directory from the previous index.
+func (b *indexBuilder) copyFromPrevious(key string) {
+	if _, ok := b.d.Directories[key]; ok {
+		return
+	}
+
+	entries := b.previous.Directories[key]
+	b.d.Directories[key] = entries
+
+	for _, e := range entries {
+		if e.IsDir {
+			b.copyFromPrevious(e.ObjectID.String())
+		}
+	}
+}
+
+func toIndexEntry(de *snapshot.DirEntry) indexEntry {
+	return indexEntry{
+		Name:     de.Name,
+		ObjectID: de.ObjectID,
+		Size:     de.FileSize,
+		ModTime:  de.ModTime,
+		IsDir:    de.Type == snapshot.EntryTypeDirectory,
+	}
+}
+
+func writeIndex(ctx context.Context, rep repo.RepositoryWriter, d *indexData) (object.ID, error) {
+	w := rep.NewObjectWriter(ctx, object.WriterOptions{
+		Description:        "SEARCH INDEX",
+		Compressor:         indexCompressor,
+		MetadataCompressor: indexCompressor,
+	})
+
+	defer w.Close() //nolint:errcheck
+
+	if err := json.NewEncoder(w).Encode(d); err != nil {
+		return object.EmptyID, errors.Wrap(err, "unable to encode search index")
+	}
+
+	oid, err := w.Result()
+	if err != nil {
+		return object.EmptyID, errors.Wrap(err, "unable to write search index")
+	}
+
+	return oid, nil
+}
+
+func loadIndexObject(ctx context.Context, rep repo.Repository, oid object.ID) (*indexData, error) {
+	r, err := rep.OpenObject(ctx, oid)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open search index")
+	}
+
+	defer r.Close() //nolint:errcheck
+
+	d := &indexData{}
+
+	if err := json.NewDecoder(r).Decode(d); err != nil {
+		return nil, errors.Wrap(err, "unable to decode search index")
+	}
+
+	if d.Version != indexVersion {
+		return nil, errors.Errorf("unsupported search index version %v", d.Version)
+	}
+
+	return d, nil
+}
+
+// indexLoader loads indexes together with their base indexes. Objects of the most recently loaded
+// chain are kept, since indexes of consecutive snapshots of a source share most of their bases.
+type indexLoader struct {
+	rep     repo.Repository
+	objects map[object.ID]*indexData
+}
+
+// load returns the index stored in the provided object with directories of all its base indexes.
+func (l *indexLoader) load(ctx context.Context, oid object.ID) (*indexData, error) {
+	objects := map[object.ID]*indexData{}
+
+	var result *indexData
+
+	for next, depth := oid, 0; next != object.EmptyID; depth++ {
+		if depth > maxIndexChainLength {
+			return nil, errors.Errorf("too many base indexes of search index %v", oid)
+		}
+
+		d := l.objects[next]
+		if d == nil {
+			var err error
+
+			if d, err = loadIndexObject(ctx, l.rep, next); err != nil {
+				return nil, err
+			}
+		}
+
+		objects[next] = d
+
+		if result == nil {
+			result = &indexData{
+				Version:     d.Version,
+				Root:        d.Root,
+				Directories: map[string][]indexEntry{},
+			}
+		}
+
+		for k, v := range d.Directories {
+			if _, ok := result.Directories[k]; !ok {
+				result.Directories[k] = v
+			}
+		}
+
+		next = d.Base
+	}
+
+	l.objects = objects
+
+	return result, nil
+}
+
+// BuildMissingIndexes builds search indexes of all snapshots that don't have them, optionally limited
+// to the provided source, and returns the number of indexes built.
+func BuildMissingIndexes(ctx context.Context, rep repo.RepositoryWriter, src *snapshot.SourceInfo) (int, error) {
+	ids, err := snapshot.ListSnapshotManifests(ctx, rep, src, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to list snapshots")
+	}
+
+	snapshots, err := snapshot.LoadSnapshots(ctx, rep, ids)
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to load snapshots")
+	}
+
+	indexes, err := ListIndexes(ctx, rep, src)
+	if err != nil {
+		return 0, err
+	}
+
+	indexed := map[manifest.ID]bool{}
+	for _, im := range indexes {
+		indexed[im.SnapshotID] = true
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].StartTime.Before(snapshots[j].StartTime)
+	})
+
+	built := 0
+
+	for _, man := range snapshots {
+		if indexed[man.ID] || man.RootEntry == nil {
+			continue
+		}
+
+		if _, err := BuildIndex(ctx, rep, man); err != nil {
+			return built, errors.Wrapf(err, "unable to index snapshot %v", man.ID)
+		}
+
+		built++
+	}
+
+	return built, nil
+}
+
+// FindOrphanedIndexes returns search indexes of snapshots that are not among the provided live snapshots.
+func FindOrphanedIndexes(indexes []*IndexManifest, liveSnapshots []*snapshot.Manifest) []*IndexManifest {
+	live := map[manifest.ID]bool{}
+	for _, man := range liveSnapshots {
+		live[man.ID] = true
+	}
+
+	var result []*IndexManifest
+
+	for _, im := range indexes {
+		if !live[im.SnapshotID] {
+			result = append(result, im)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,398 @@
+package snapshotsearch
+
+import (
+	"container/list"
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/wcmatch"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// ErrInvalidQuery is returned when the search query is malformed.
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Query describes files to be found using snapshot search indexes.
+//
+// Pattern is matched against file names, unless it contains a slash, in which case
+// it's matched against the entire path relative to the snapshot root, starting with a slash.
+type Query struct {
+	Pattern    string `json:"pattern"`
+	Regex      bool   `json:"regex,omitempty"`
+	IgnoreCase bool   `json:"ignoreCase,omitempty"`
+
+	// MinSize and MaxSize limit the size of matching entries, zero MaxSize means no limit.
+	MinSize int64 `json:"minSize,omitempty"`
+	MaxSize int64 `json:"maxSize,omitempty"`
+
+	// ModifiedAfter and ModifiedBefore limit modification times of matching entries, zero values mean no limit.
+	ModifiedAfter  time.Time `json:"modifiedAfter"`
+	ModifiedBefore time.Time `json:"modifiedBefore"`
+
+	// Source, when provided, limits the search to snapshots of a single source.
+	Source *snapshot.SourceInfo `json:"source,omitempty"`
+
+	IncludeDirectories bool `json:"includeDirectories,omitempty"`
+
+	// MaxResults, when positive, limits the number of returned files.
+	MaxResults int `json:"maxResults,omitempty"`
+}
+
+// SnapshotRef identifies a snapshot in which a version of a file was found.
+type SnapshotRef struct {
+	ID        manifest.ID     `json:"id"`
+	StartTime fs.UTCTimestamp `json:"startTime"`
+}
+
+// Version describes a single version of a file, which was found in one or more consecutive snapshots.
+type Version struct {
+	ObjectID  object.ID       `json:"obj"`
+	Size      int64           `json:"size"`
+	ModTime   fs.UTCTimestamp `json:"mtime"`
+	Snapshots []SnapshotRef   `json:"snapshots"`
+}
+
+// File describes all versions of a single matching path, ordered by snapshot time.
+type File struct {
+	Source   snapshot.SourceInfo `json:"source"`
+	Path     string              `json:"path"`
+	IsDir    bool                `json:"isDir,omitempty"`
+	Versions []*Version          `json:"versions"`
+}
+
+// Results contains the results of a search.
+type Results struct {
+	Files []*File `json:"files"`
+
+	SnapshotsSearched   int  `json:"snapshotsSearched"`
+	SnapshotsNotIndexed int  `json:"snapshotsNotIndexed"`
+	Truncated           bool `json:"truncated,omitempty"`
+
+	// SourcesNotIndexed lists sources which have snapshots, but none of them is indexed, typically because
+	// building search indexes is not enabled in their policies.
+	SourcesNotIndexed []snapshot.SourceInfo `json:"sourcesNotIndexed,omitempty"`
+}
+
+type hit struct {
+	path  string
+	entry indexEntry
+}
+
+// maxMemoizedHits limits the number of hits kept in the searcher memo.
+const maxMemoizedHits = 1 << 20
+
+type memoEntry struct {
+	key  string
+	hits []hit
+}
+
+// hitMemo is a LRU cache of hits keyed by directory object ID and path, since directories don't change
+// between snapshots unless their object IDs do. The cost of each entry is the number of its hits plus one.
+type hitMemo struct {
+	entries map[string]*list.Element
+	lru     list.List // of *memoEntry, most recently used first
+	cost    int
+	maxCost int
+}
+
+func (m *hitMemo) get(key string) ([]hit, bool) {
+	e, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	m.lru.MoveToFront(e)
+
+	return e.Value.(*memoEntry).hits, true //nolint:forcetypeassert
+}
+
+func (m *hitMemo) put(key string, hits []hit) {
+	m.entries[key] = m.lru.PushFront(&memoEntry{key, hits})
+	m.cost += len(hits) + 1
+
+	for m.cost > m.maxCost {
+		oldest := m.lru.Remove(m.lru.Back()).(*memoEntry) //nolint:forcetypeassert
+
+		delete(m.entries, oldest.key)
+
+		m.cost -= len(oldest.hits) + 1
+	}
+}
+
+type searcher struct {
+	q         Query
+	matchName func(string) bool
+	matchPath bool
+
+	memo *hitMemo
+}
+
+func newSearcher(q Query) (*searcher, error) {
+	if q.Pattern == "" {
+		return nil, errors.Wrap(ErrInvalidQuery, "pattern must be provided")
+	}
+
+	s := &searcher{
+		q:         q,
+		matchPath: strings.Contains(q.Pattern, "/"),
+		memo:      &hitMemo{entries: map[string]*list.Element{}, maxCost: maxMemoizedHits},
+	}
+
+	if q.Regex {
+		expr := q.Pattern
+		if q.IgnoreCase {
+			expr = "(?i)" + expr
+		}
+
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, errors.Wrapf(ErrInvalidQuery, "invalid regular expression: %v", err)
+		}
+
+		s.matchName = re.MatchString
+	} else {
+		m, err := wcmatch.NewWildcardMatcher(q.Pattern, wcmatch.IgnoreCase(q.IgnoreCase))
+		if err != nil {
+			return nil, errors.Wrapf(ErrInvalidQuery, "invalid pattern: %v", err)
+		}
+
+		s.matchName = func(v string) bool { return m.Match(v, false) }
+	}
+
+	return s, nil
+}
+
+func (s *searcher) matches(relPath string, e indexEntry) bool {
+	if e.IsDir && !s.q.IncludeDirectories {
+		return false
+	}
+
+	if e.Size < s.q.MinSize {
+		return false
+	}
+
+	if s.q.MaxSize > 0 && e.Size > s.q.MaxSize {
+		return false
+	}
+
+	if !s.q.ModifiedAfter.IsZero() && !e.ModTime.ToTime().After(s.q.ModifiedAfter) {
+		return false
+	}
+
+	if !s.q.ModifiedBefore.IsZero() && !e.ModTime.ToTime().Before(s.q.ModifiedBefore) {
+		return false
+	}
+
+	if s.matchPath {
+		return s.matchName("/" + relPath)
+	}
+
+	return s.matchName(e.Name)
+}
+
+func (s *searcher) searchDirectory(d *indexData, oid object.ID, dirPath string) []hit {
+	key := oid.String() + "\x00" + dirPath
+
+	if hits, ok := s.memo.get(key); ok {
+		return hits
+	}
+
+	var hits []hit
+
+	for _, e := range d.Directories[oid.String()] {
+		p := e.Name
+		if dirPath != "" {
+			p = dirPath + "/" + e.Name
+		}
+
+		if s.matches(p, e) {
+			hits = append(hits, hit{p, e})
+		}
+
+		if e.IsDir {
+			hits = append(hits, s.searchDirectory(d, e.ObjectID, p)...)
+		}
+	}
+
+	s.memo.put(key, hits)
+
+	return hits
+}
+
+func (s *searcher) searchIndex(d *indexData, src snapshot.SourceInfo) []hit {
+	if d.Root.IsDir {
+		return s.searchDirectory(d, d.Root.ObjectID, "")
+	}
+
+	root := d.Root
+	if root.Name == "" {
+		root.Name = src.Path[strings.LastIndexAny(src.Path, `/\`)+1:]
+	}
+
+	if s.matches(root.Name, root) {
+		return []hit{{root.Name, root}}
+	}
+
+	return nil
+}
+
+// Search finds files matching the provided query in all indexed snapshots and returns their versions.
+func Search(ctx context.Context, rep repo.Repository, q Query) (*Results, error) {
+	s, err := newSearcher(q)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := snapshot.ListSnapshotManifests(ctx, rep, q.Source, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list snapshots")
+	}
+
+	snapshots, err := snapshot.LoadSnapshots(ctx, rep, ids)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load snapshots")
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].StartTime.Before(snapshots[j].StartTime)
+	})
+
+	indexes, err := ListIndexes(ctx, rep, q.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	indexBySnapshot := map[manifest.ID]*IndexManifest{}
+	for _, im := range indexes {
+		indexBySnapshot[im.SnapshotID] = im
+	}
+
+	snapshotsBySource := map[snapshot.SourceInfo][]*snapshot.Manifest{}
+
+	var sources []snapshot.SourceInfo
+
+	for _, man := range snapshots {
+		if snapshotsBySource[man.Source] == nil {
+			sources = append(sources, man.Source)
+		}
+
+		snapshotsBySource[man.Source] = append(snapshotsBySource[man.Source], man)
+	}
+
+	sort.Slice(sources, func(i, j int) bool {
+		return sources[i].String() < sources[j].String()
+	})
+
+	var (
+		res   = &Results{}
+		files = map[string]*File{}
+	)
+
+	for _, src := range sources {
+		searched := res.SnapshotsSearched
+
+		if err := s.searchSource(ctx, rep, snapshotsBySource[src], indexBySnapshot, res, files); err != nil {
+			return nil, err
+		}
+
+		if res.SnapshotsSearched == searched {
+			res.SourcesNotIndexed = append(res.SourcesNotIndexed, src)
+		}
+	}
+
+	for _, f := range files {
+		res.Files = append(res.Files, f)
+	}
+
+	sort.Slice(res.Files, func(i, j int) bool {
+		if a, b := res.Files[i].Source.String(), res.Files[j].Source.String(); a != b {
+			return a < b
+		}
+
+		return res.Files[i].Path < res.Files[j].Path
+	})
+
+	if q.MaxResults > 0 && len(res.Files) > q.MaxResults {
+		res.Files = res.Files[:q.MaxResults]
+		res.Truncated = true
+	}
+
+	return res, nil
+}
+
+// searchSource searches the snapshots of a single source ordered by time. Each source uses its own loader,
+// since indexes of consecutive snapshots of a source share most of their base indexes.
+func (s *searcher) searchSource(ctx context.Context, rep repo.Repository, snapshots []*snapshot.Manifest, indexBySnapshot map[manifest.ID]*IndexManifest, res *Results, files map[string]*File) error {
+	var (
+		loader = &indexLoader{rep: rep}
+
+		// consecutive snapshots of unchanged sources often share the same index object.
+		lastIndexID object.ID
+		d           *indexData
+	)
+
+	for _, man := range snapshots {
+		im := indexBySnapshot[man.ID]
+		if im == nil {
+			res.SnapshotsNotIndexed++
+			continue
+		}
+
+		if d == nil || im.IndexObjectID != lastIndexID {
+			var err error
+
+			d, err = loader.load(ctx, im.IndexObjectID)
+			if err != nil {
+				return errors.Wrapf(err, "unable to load search index of snapshot %v", man.ID)
+			}
+
+			lastIndexID = im.IndexObjectID
+		}
+
+		res.SnapshotsSearched++
+
+		for _, h := range s.searchIndex(d, man.Source) {
+			addVersion(files, man, h)
+		}
+	}
+
+	return nil
+}
+
+func addVersion(files map[string]*File, man *snapshot.Manifest, h hit) {
+	key := man.Source.String() + "\x00" + h.path
+
+	f := files[key]
+	if f == nil {
+		f = &File{
+			Source: man.Source,
+			Path:   h.path,
+			IsDir:  h.entry.IsDir,
+		}
+
+		files[key] = f
+	}
+
+	ref := SnapshotRef{ID: man.ID, StartTime: man.StartTime}
+
+	if n := len(f.Versions); n > 0 && f.Versions[n-1].ObjectID == h.entry.ObjectID {
+		f.Versions[n-1].Snapshots = append(f.Versions[n-1].Snapshots, ref)
+		return
+	}
+
+	f.Versions = append(f.Versions, &Version{
+		ObjectID:  h.entry.ObjectID,
+		Size:      h.entry.Size,
+		ModTime:   h.entry.ModTime,
+		Snapshots: []SnapshotRef{ref},
+	})
+}
@@ -0,0 +1,188 @@
+package snapshotsearch_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotsearch"
+	"github.com/kopia/kopia/snapshot/upload"
+)
+
+func TestSearch(t *testing.T) {
+	ctx, te := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	u := upload.NewUploader(te.RepositoryWriter)
+	si := te.LocalPathSourceInfo("/dummy/path")
+
+	root := mockfs.NewDirectory()
+	docs := root.AddDir("docs", 0o755)
+	docs.AddFile("budget-2023.xlsx", []byte{1, 2, 3}, 0o644)
+	docs.AddFile("notes.md", []byte{1}, 0o644)
+	root.AddDir("photos", 0o755).AddFile("img.jpg", make([]byte, 1000), 0o644)
+
+	takeSnapshot := func() *snapshot.Manifest {
+		t.Helper()
+
+		man, err := u.Upload(ctx, root, nil, si)
+		require.NoError(t, err)
+
+		_, err = snapshot.SaveSnapshot(ctx, te.RepositoryWriter, man)
+		require.NoError(t, err)
+
+		return man
+	}
+
+	s1 := takeSnapshot()
+	im1, err := snapshotsearch.BuildIndex(ctx, te.RepositoryWriter, s1)
+	require.NoError(t, err)
+	require.Equal(t, 3, im1.Directories)
+	require.Equal(t, 5, im1.Entries)
+
+	// indexing again returns existing index.
+	im1b, err := snapshotsearch.BuildIndex(ctx, te.RepositoryWriter, s1)
+	require.NoError(t, err)
+	require.Equal(t, im1.ID, im1b.ID)
+
+	s2 := takeSnapshot()
+
+	docs.AddFile("budget-2023.xlsx", []byte{1, 2, 3, 4}, 0o644)
+
+	s3 := takeSnapshot()
+
+	// s2 and s3 are indexed retroactively.
+	n, err := snapshotsearch.BuildMissingIndexes(ctx, te.RepositoryWriter, nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+
+	indexes, err := snapshotsearch.ListIndexes(ctx, te.RepositoryWriter, &si)
+	require.NoError(t, err)
+
+	indexBySnapshot := map[manifest.ID]*snapshotsearch.IndexManifest{}
+	for _, im := range indexes {
+		indexBySnapshot[im.SnapshotID] = im
+	}
+
+	// indexes only store directories which are not in their base.
+	im2, im3 := indexBySnapshot[s2.ID], indexBySnapshot[s3.ID]
+	require.Zero(t, im2.Directories)
+	require.Equal(t, []object.ID{im1.IndexObjectID}, im2.BaseIndexObjectIDs)
+	require.Equal(t, 2, im3.Directories)
+	require.Equal(t, []object.ID{im2.IndexObjectID, im1.IndexObjectID}, im3.BaseIndexObjectIDs)
+
+	res, err := snapshotsearch.Search(ctx, te.RepositoryWriter, snapshotsearch.Query{Pattern: "BUDGET*", IgnoreCase: true})
+	require.NoError(t, err)
+	require.Equal(t, 3, res.SnapshotsSearched)
+	require.Zero(t, res.SnapshotsNotIndexed)
+	require.Len(t, res.Files, 1)
+
+	f := res.Files[0]
+	require.Equal(t, "docs/budget-2023.xlsx", f.Path)
+	require.Len(t, f.Versions, 2)
+	require.Equal(t, int64(3), f.Versions[0].Size)
+	require.Len(t, f.Versions[0].Snapshots, 2)
+	require.Equal(t, s1.ID, f.Versions[0].Snapshots[0].ID)
+	require.Equal(t, s2.ID, f.Versions[0].Snapshots[1].ID)
+	require.Equal(t, int64(4), f.Versions[1].Size)
+	require.Equal(t, s3.ID, f.Versions[1].Snapshots[0].ID)
+
+	cases := []struct {
+		q    snapshotsearch.Query
+		want []string
+	}{
+		{snapshotsearch.Query{Pattern: "*.md"}, []string{"docs/notes.md"}},
+		{snapshotsearch.Query{Pattern: `^(img|notes)\.`, Regex: true}, []string{"docs/notes.md", "photos/img.jpg"}},
+		{snapshotsearch.Query{Pattern: "/photos/*"}, []string{"photos/img.jpg"}},
+		{snapshotsearch.Query{Pattern: "*", MinSize: 100}, []string{"photos/img.jpg"}},
+		{snapshotsearch.Query{Pattern: "*", MaxSize: 2}, []string{"docs/notes.md"}},
+		{snapshotsearch.Query{Pattern: "docs", IncludeDirectories: true}, []string{"docs"}},
+		{snapshotsearch.Query{Pattern: "*", ModifiedBefore: time.Unix(1, 0)}, nil},
+		{snapshotsearch.Query{Pattern: "*", MaxResults: 1}, []string{"docs/budget-2023.xlsx"}},
+	}
+
+	for _, tc := range cases {
+		res, err := snapshotsearch.Search(ctx, te.RepositoryWriter, tc.q)
+		require.NoError(t, err)
+
+		var got []string
+		for _, f := range res.Files {
+			got = append(got, f.Path)
+		}
+
+		require.Equal(t, tc.want, got, "%+v", tc.q)
+	}
+
+	_, err = snapshotsearch.Search(ctx, te.RepositoryWriter, snapshotsearch.Query{Pattern: "(", Regex: true})
+	require.ErrorIs(t, err, snapshotsearch.ErrInvalidQuery)
+
+	// deleting the snapshot orphans its index.
+	require.NoError(t, te.RepositoryWriter.DeleteManifest(ctx, s3.ID))
+
+	indexes, err = snapshotsearch.ListIndexes(ctx, te.RepositoryWriter, &si)
+	require.NoError(t, err)
+	require.Len(t, indexes, 3)
+
+	orphaned := snapshotsearch.FindOrphanedIndexes(indexes, []*snapshot.Manifest{s1, s2})
+	require.Len(t, orphaned, 1)
+	require.Equal(t, s3.ID, orphaned[0].SnapshotID)
+}
+
+func TestSearchMultipleSources(t *testing.T) {
+	ctx, te := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	u := upload.NewUploader(te.RepositoryWriter)
+
+	root := mockfs.NewDirectory()
+	root.AddFile("report.pdf", []byte{1, 2, 3}, 0o644)
+
+	sources := []snapshot.SourceInfo{
+		te.LocalPathSourceInfo("/path/a"),
+		te.LocalPathSourceInfo("/path/b"),
+		te.LocalPathSourceInfo("/path/not-indexed"),
+	}
+
+	// interleave snapshots of all sources, only the first two are indexed.
+	for i := range 3 {
+		root.AddFile("report.pdf", make([]byte, i+1), 0o644)
+
+		for j, si := range sources {
+			man, err := u.Upload(ctx, root, nil, si)
+			require.NoError(t, err)
+
+			_, err = snapshot.SaveSnapshot(ctx, te.RepositoryWriter, man)
+			require.NoError(t, err)
+
+			if j < 2 {
+				_, err = snapshotsearch.BuildIndex(ctx, te.RepositoryWriter, man)
+				require.NoError(t, err)
+			}
+		}
+	}
+
+	res, err := snapshotsearch.Search(ctx, te.RepositoryWriter, snapshotsearch.Query{Pattern: "*.pdf"})
+	require.NoError(t, err)
+	require.Equal(t, 6, res.SnapshotsSearched)
+	require.Equal(t, 3, res.SnapshotsNotIndexed)
+	require.Equal(t, []snapshot.SourceInfo{sources[2]}, res.SourcesNotIndexed)
+	require.Len(t, res.Files, 2)
+
+	for i, f := range res.Files {
+		require.Equal(t, sources[i], f.Source)
+		require.Len(t, f.Versions, 3)
+
+		for j, v := range f.Versions {
+			require.Equal(t, int64(j+1), v.Size)
+		}
+	}
+
+	// sources with some indexed snapshots are not reported.
+	res, err = snapshotsearch.Search(ctx, te.RepositoryWriter, snapshotsearch.Query{Pattern: "*.pdf", Source: &sources[0]})
+	require.NoError(t, err)
+	require.Empty(t, res.SourcesNotIndexed)
+}
@@ -0,0 +1,35 @@
+package sourceverify
+
+import (
+	"context"
+
+	"github.com/kopia/kopia/notification"
+	"github.com/kopia/kopia/notification/notifydata"
+	"github.com/kopia/kopia/notification/notifytemplate"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// Report sends a high-severity notification about the source files of the provided snapshot with probable bit rot.
+func Report(ctx context.Context, rep repo.Repository, man *snapshot.Manifest, result *Result, opt notifytemplate.Options) {
+	if len(result.Mismatches) == 0 {
+		return
+	}
+
+	ev := &notifydata.SourceBitRot{
+		Source:      man.Source,
+		SnapshotID:  string(man.ID),
+		StartTime:   man.StartTime.ToTime(),
+		HashedFiles: result.HashedFiles,
+	}
+
+	for _, m := range result.Mismatches {
+		ev.Files = append(ev.Files, notifydata.SourceBitRotFile{
+			Path:    m.Path,
+			Size:    m.Size,
+			ModTime: m.ModTime,
+		})
+	}
+
+	notification.Send(ctx, rep, notifytemplate.SourceBitRot, ev, notification.SeverityError, opt)
+}
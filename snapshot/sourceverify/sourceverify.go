@@ -0,0 +1,249 @@
+// Package sourceverify detects silent corruption (bit rot) of source files by re-hashing live files whose
+// metadata matches the latest snapshot and comparing them with the contents stored in the repository.
+//
+// The verification only reads the source files and the repository index, it never modifies the snapshot.
+package sourceverify
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/hashing"
+	"github.com/kopia/kopia/repo/logging"
+	"github.com/kopia/kopia/repo/object"
+)
+
+var log = logging.Module("sourceverify")
+
+// Options provides options for source verification.
+type Options struct {
+	// percentage of files with matching metadata to re-hash [0.0 .. 100.0].
+	FilesPercent float64
+	Parallelism  int
+}
+
+// Mismatch describes a source file whose contents differ from the snapshot even though its size and
+// modification time are the same, which is a probable sign of bit rot.
+type Mismatch struct {
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mtime"`
+	ObjectID object.ID `json:"objectID"`
+}
+
+// Result contains the result of source verification.
+type Result struct {
+	MatchingFiles int64      `json:"matchingFiles"` // files whose metadata matched the snapshot
+	HashedFiles   int64      `json:"hashedFiles"`
+	HashedBytes   int64      `json:"hashedBytes"`
+	ErrorCount    int64      `json:"errorCount"` // files which could not be re-hashed
+	Mismatches    []Mismatch `json:"mismatches,omitempty"`
+}
+
+type verifier struct {
+	rep      repo.DirectRepository
+	hashFunc hashing.HashFunc
+	opts     Options
+
+	mu sync.Mutex
+	// +checklocks:mu
+	result Result
+}
+
+// Verify re-hashes live source files whose size and modification time match the corresponding files in the
+// snapshot root and returns the files whose contents don't match.
+func Verify(ctx context.Context, rep repo.DirectRepository, snapshotRoot, live fs.Entry, opts Options) (*Result, error) {
+	v := &verifier{
+		rep:      rep,
+		hashFunc: rep.ContentReader().ContentFormat().HashFunc(),
+		opts:     opts,
+	}
+
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(max(opts.Parallelism, 1))
+
+	if err := v.verifyEntry(ctx, eg, snapshotRoot, live, "."); err != nil {
+		eg.Wait() //nolint:errcheck
+
+		return nil, err
+	}
+
+	if err := eg.Wait(); err != nil {
+		return nil, errors.Wrap(err, "error verifying source files")
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	sort.Slice(v.result.Mismatches, func(i, j int) bool {
+		return v.result.Mismatches[i].Path < v.result.Mismatches[j].Path
+	})
+
+	result := v.result
+
+	return &result, nil
+}
+
+func (v *verifier) verifyEntry(ctx context.Context, eg *errgroup.Group, snapEntry, liveEntry fs.Entry, relPath string) error {
+	switch se := snapEntry.(type) {
+	case fs.Directory:
+		ld, ok := liveEntry.(fs.Directory)
+		if !ok {
+			return nil
+		}
+
+		return v.verifyDirectory(ctx, eg, se, ld, relPath)
+
+	case fs.File:
+		lf, ok := liveEntry.(fs.File)
+		if !ok || lf.Size() != se.Size() || !lf.ModTime().Equal(se.ModTime()) {
+			// the file was legitimately changed since the snapshot.
+			return nil
+		}
+
+		hoid, ok := se.(object.HasObjectID)
+		if !ok {
+			return nil
+		}
+
+		v.mu.Lock()
+		v.result.MatchingFiles++
+		v.mu.Unlock()
+
+		if 100*rand.Float64() >= v.opts.FilesPercent { //nolint:gosec,mnd
+			return nil
+		}
+
+		eg.Go(func() error {
+			v.verifyFile(ctx, lf, hoid.ObjectID(), relPath)
+			return nil
+		})
+	}
+
+	return nil
+}
+
+func (v *verifier) verifyDirectory(ctx context.Context, eg *errgroup.Group, snapDir, liveDir fs.Directory, relPath string) error {
+	//nolint:wrapcheck
+	return fs.IterateEntries(ctx, snapDir, func(ctx context.Context, se fs.Entry) error {
+		le, err := liveDir.Child(ctx, se.Name())
+		if err != nil {
+			// the entry was removed since the snapshot or is not readable.
+			return nil //nolint:nilerr
+		}
+
+		return v.verifyEntry(ctx, eg, se, le, path.Join(relPath, se.Name()))
+	})
+}
+
+func (v *verifier) verifyFile(ctx context.Context, f fs.File, oid object.ID, relPath string) {
+	matches, err := v.fileMatches(ctx, f, oid)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if err != nil {
+		log(ctx).Warnf("unable to verify %v: %v", relPath, err)
+
+		v.result.ErrorCount++
+
+		return
+	}
+
+	v.result.HashedFiles++
+	v.result.HashedBytes += f.Size()
+
+	if !matches {
+		log(ctx).Errorf("probable bit rot: contents of %v have changed without a change of size or modification time", relPath)
+
+		v.result.Mismatches = append(v.result.Mismatches, Mismatch{
+			Path:     relPath,
+			Size:     f.Size(),
+			ModTime:  f.ModTime().UTC(),
+			ObjectID: oid,
+		})
+	}
+}
+
+func (v *verifier) fileMatches(ctx context.Context, f fs.File, oid object.ID) (bool, error) {
+	r, err := f.Open(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "unable to open file")
+	}
+	defer r.Close() //nolint:errcheck
+
+	var buf []byte
+
+	return v.objectMatches(ctx, r, oid, &buf)
+}
+
+// objectMatches reads the next part of the file backing the provided object, hashes it in the same way
+// contents are hashed when written and compares the hashes with the IDs of the contents of the object.
+func (v *verifier) objectMatches(ctx context.Context, r io.Reader, oid object.ID, buf *[]byte) (bool, error) {
+	if indexObjectID, ok := oid.IndexObjectID(); ok {
+		entries, err := object.LoadIndexObject(ctx, indexContentReader{v.rep}, indexObjectID)
+		if err != nil {
+			return false, errors.Wrap(err, "unable to load index object")
+		}
+
+		for _, e := range entries {
+			matches, err := v.objectMatches(ctx, io.LimitReader(r, e.Length), e.Object, buf)
+			if !matches || err != nil {
+				return matches, err
+			}
+		}
+
+		return true, nil
+	}
+
+	contentID, _, ok := oid.ContentID()
+	if !ok {
+		return false, errors.Errorf("unrecognized object type: %v", oid)
+	}
+
+	ci, err := v.rep.ContentInfo(ctx, contentID)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to get content info for %v", contentID)
+	}
+
+	if cap(*buf) < int(ci.OriginalLength) {
+		*buf = make([]byte, ci.OriginalLength)
+	}
+
+	data := (*buf)[:ci.OriginalLength]
+
+	if _, err := io.ReadFull(r, data); err != nil {
+		return false, errors.Wrap(err, "unable to read file")
+	}
+
+	var hashOutput [hashing.MaxHashSize]byte
+
+	actualID, err := content.IDFromHash(contentID.Prefix(), v.hashFunc(hashOutput[:0], gather.FromSlice(data)))
+	if err != nil {
+		return false, errors.Wrap(err, "invalid hash")
+	}
+
+	return actualID == contentID, nil
+}
+
+// indexContentReader allows loading index objects of a direct repository.
+type indexContentReader struct {
+	repo.DirectRepository
+}
+
+func (r indexContentReader) GetContent(ctx context.Context, contentID content.ID) ([]byte, error) {
+	//nolint:wrapcheck
+	return r.ContentReader().GetContent(ctx, contentID)
+}
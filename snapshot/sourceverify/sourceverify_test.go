@@ -0,0 +1,116 @@
+package sourceverify_test
+
+import (
+	"crypto/rand"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/kopia/kopia/snapshot/sourceverify"
+	"github.com/kopia/kopia/snapshot/upload"
+)
+
+func TestVerify(t *testing.T) {
+	ctx, te := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	dir := testutil.TempDirectory(t)
+	mtime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	writeFile := func(name string, data []byte) {
+		t.Helper()
+
+		fname := filepath.Join(dir, name)
+
+		require.NoError(t, os.MkdirAll(filepath.Dir(fname), 0o755))
+		require.NoError(t, os.WriteFile(fname, data, 0o600))
+		require.NoError(t, os.Chtimes(fname, mtime, mtime))
+	}
+
+	large := make([]byte, 3<<20)
+	rand.Read(large)
+
+	writeFile("unchanged.txt", []byte("unchanged contents"))
+	writeFile("sub/rotten.txt", []byte("original contents"))
+	writeFile("sub/modified.txt", []byte("original contents"))
+	writeFile("large.bin", large)
+
+	pol := *policy.DefaultPolicy
+	pol.SplitterPolicy.Algorithm = "FIXED-1M"
+
+	live, err := localfs.NewEntry(dir)
+	require.NoError(t, err)
+
+	man, err := upload.NewUploader(te.RepositoryWriter).Upload(ctx, live, policy.BuildTree(nil, &pol), te.LocalPathSourceInfo(dir))
+	require.NoError(t, err)
+
+	root, err := snapshotfs.SnapshotRoot(te.RepositoryWriter, man)
+	require.NoError(t, err)
+
+	verify := func(percent float64) *sourceverify.Result {
+		t.Helper()
+
+		live, err := localfs.NewEntry(dir)
+		require.NoError(t, err)
+
+		result, err := sourceverify.Verify(ctx, te.RepositoryWriter, root, live, sourceverify.Options{
+			FilesPercent: percent,
+			Parallelism:  4,
+		})
+		require.NoError(t, err)
+
+		return result
+	}
+
+	result := verify(100)
+	require.EqualValues(t, 4, result.MatchingFiles)
+	require.EqualValues(t, 4, result.HashedFiles)
+	require.Empty(t, result.Mismatches)
+
+	// silent corruption keeps the size and modification time.
+	writeFile("sub/rotten.txt", []byte("origiNal contents"))
+
+	large[2<<20] ^= 1
+	writeFile("large.bin", large)
+
+	// legitimate modification changes the modification time.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "modified.txt"), []byte("modified contents"), 0o600))
+
+	result = verify(100)
+	require.EqualValues(t, 3, result.MatchingFiles)
+	require.EqualValues(t, 3, result.HashedFiles)
+	require.Zero(t, result.ErrorCount)
+	require.Len(t, result.Mismatches, 2)
+	require.Equal(t, "large.bin", result.Mismatches[0].Path)
+	require.Equal(t, "sub/rotten.txt", result.Mismatches[1].Path)
+	require.EqualValues(t, len("original contents"), result.Mismatches[1].Size)
+	require.True(t, mtime.Equal(result.Mismatches[1].ModTime))
+
+	// no files are re-hashed when sampling 0 percent.
+	result = verify(0)
+	require.EqualValues(t, 3, result.MatchingFiles)
+	require.Zero(t, result.HashedFiles)
+	require.Empty(t, result.Mismatches)
+
+	// the snapshot copy is not modified.
+	e, err := snapshotfs.GetNestedEntry(ctx, root, []string{"sub", "rotten.txt"})
+	require.NoError(t, err)
+
+	r, err := e.(fs.File).Open(ctx)
+	require.NoError(t, err)
+
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, "original contents", string(data))
+}
@@ -21,6 +21,9 @@ type Stats struct {
 	CachedFiles int32 `json:"cachedFiles"`
 	// +checkatomic
 	NonCachedFiles int32 `json:"nonCachedFiles"`
+	// number of cached files, which were found under a different path in previous snapshots.
+	// +checkatomic
+	MovedFiles int32 `json:"movedFiles,omitempty"`
 
 	// +checkatomic
 	TotalDirectoryCount int32 `json:"dirCount"`
@@ -34,6 +37,11 @@ type Stats struct {
 	IgnoredErrorCount int32 `json:"ignoredErrorCount"`
 	// +checkatomic
 	ErrorCount int32 `json:"errorCount"`
+
+	// number of files for which automatic compression selected no compression, fast or strong compression.
+	AutoCompressionNoneCount   int32 `json:"autoCompressionNone,omitempty"`
+	AutoCompressionFastCount   int32 `json:"autoCompressionFast,omitempty"`
+	AutoCompressionStrongCount int32 `json:"autoCompressionStrong,omitempty"`
 }
 
 // AddExcluded adds the information about excluded file to the statistics.
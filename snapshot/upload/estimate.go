@@ -23,24 +23,32 @@ type SampleBucket struct {
 	Count     int      `json:"count"`
 	TotalSize int64    `json:"totalSize"`
 	Examples  []string `json:"examples,omitempty"`
+
+	// ExampleReasons describe why each of the examples was excluded, only set for excluded files.
+	ExampleReasons []string `json:"exampleReasons,omitempty"`
 }
 
-func (b *SampleBucket) add(fname string, size int64, maxExamplesPerBucket int) {
+func (b *SampleBucket) add(fname string, size int64, reason string, maxExamplesPerBucket int) {
 	b.Count++
 	b.TotalSize += size
 
 	if len(b.Examples) < maxExamplesPerBucket {
 		b.Examples = append(b.Examples, fmt.Sprintf("%v - %v", fname, units.BytesString(size)))
+
+		if reason != "" {
+			b.ExampleReasons = append(b.ExampleReasons, reason)
+		}
 	}
 }
 
 // SampleBuckets is a collection of buckets for interesting file sizes sorted in descending order.
 type SampleBuckets []*SampleBucket
 
-func (b SampleBuckets) add(fname string, size int64, maxExamplesPerBucket int) {
+// add adds the file to the bucket for its size, the reason is provided for excluded files.
+func (b SampleBuckets) add(fname string, size int64, reason string, maxExamplesPerBucket int) {
 	for _, bucket := range b {
 		if size >= bucket.MinSize {
-			bucket.add(fname, size, maxExamplesPerBucket)
+			bucket.add(fname, size, reason, maxExamplesPerBucket)
 			break
 		}
 	}
@@ -65,18 +73,24 @@ func makeBuckets() SampleBuckets {
 	}
 }
 
+// ExcludedDir describes a directory excluded from the snapshot.
+type ExcludedDir struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason,omitempty"`
+}
+
 // EstimateProgress must be provided by the caller of Estimate to report results.
 type EstimateProgress interface {
 	Processing(ctx context.Context, dirname string)
 	Error(ctx context.Context, filename string, err error, isIgnored bool)
-	Stats(ctx context.Context, s *snapshot.Stats, includedFiles, excludedFiles SampleBuckets, excludedDirs []string, final bool)
+	Stats(ctx context.Context, s *snapshot.Stats, includedFiles, excludedFiles SampleBuckets, excludedDirs []ExcludedDir, final bool)
 }
 
 // Estimate walks the provided directory tree and invokes provided progress callback as it discovers
 // items to be snapshotted.
 func Estimate(ctx context.Context, entry fs.Directory, policyTree *policy.Tree, progress EstimateProgress, maxExamplesPerBucket int) error {
 	stats := &snapshot.Stats{}
-	ed := []string{}
+	ed := []ExcludedDir{}
 	ib := makeBuckets()
 	eb := makeBuckets()
 
@@ -85,31 +99,31 @@ func Estimate(ctx context.Context, entry fs.Directory, policyTree *policy.Tree,
 		progress.Stats(ctx, stats, ib, eb, ed, true)
 	}()
 
-	onIgnoredFile := func(ctx context.Context, relativePath string, e fs.Entry, pol *policy.Tree) {
+	onIgnoredFile := func(ctx context.Context, relativePath string, e fs.Entry, pol *policy.Tree, reason string) {
 		_ = pol
 
 		if e.IsDir() {
 			if len(ed) < maxExamplesPerBucket {
-				ed = append(ed, relativePath)
+				ed = append(ed, ExcludedDir{relativePath, reason})
 			}
 
 			atomic.AddInt32(&stats.ExcludedDirCount, 1)
 
-			estimateLog(ctx).Debugf("excluded dir %v", relativePath)
+			estimateLog(ctx).Debugf("excluded dir %v: %v", relativePath, reason)
 		} else {
-			estimateLog(ctx).Debugf("excluded file %v (%v)", relativePath, units.BytesString(e.Size()))
+			estimateLog(ctx).Debugf("excluded file %v (%v): %v", relativePath, units.BytesString(e.Size()), reason)
 			atomic.AddInt32(&stats.ExcludedFileCount, 1)
 			atomic.AddInt64(&stats.ExcludedTotalFileSize, e.Size())
-			eb.add(relativePath, e.Size(), maxExamplesPerBucket)
+			eb.add(relativePath, e.Size(), reason, maxExamplesPerBucket)
 		}
 	}
 
 	entry = ignorefs.New(entry, policyTree, ignorefs.ReportIgnoredFiles(onIgnoredFile))
 
-	return estimate(ctx, ".", entry, policyTree, stats, ib, eb, &ed, progress, maxExamplesPerBucket)
+	return estimate(ctx, ".", entry, policyTree, &ignorefs.MIMERules{}, stats, ib, eb, &ed, progress, maxExamplesPerBucket)
 }
 
-func estimate(ctx context.Context, relativePath string, entry fs.Entry, policyTree *policy.Tree, stats *snapshot.Stats, ib, eb SampleBuckets, ed *[]string, progress EstimateProgress, maxExamplesPerBucket int) error {
+func estimate(ctx context.Context, relativePath string, entry fs.Entry, policyTree *policy.Tree, mimeRules *ignorefs.MIMERules, stats *snapshot.Stats, ib, eb SampleBuckets, ed *[]ExcludedDir, progress EstimateProgress, maxExamplesPerBucket int) error {
 	// see if the context got canceled
 	select {
 	case <-ctx.Done():
@@ -137,7 +151,7 @@ func estimate(ctx context.Context, relativePath string, entry fs.Entry, policyTr
 
 			child, err = iter.Next(ctx)
 			for child != nil {
-				if err = estimate(ctx, filepath.Join(relativePath, child.Name()), child, policyTree.Child(child.Name()), stats, ib, eb, ed, progress, maxExamplesPerBucket); err != nil {
+				if err = estimate(ctx, filepath.Join(relativePath, child.Name()), child, policyTree.Child(child.Name()), mimeRules, stats, ib, eb, ed, progress, maxExamplesPerBucket); err != nil {
 					break
 				}
 
@@ -164,7 +178,18 @@ func estimate(ctx context.Context, relativePath string, entry fs.Entry, policyTr
 		}
 
 	case fs.File:
-		ib.add(relativePath, entry.Size(), maxExamplesPerBucket)
+		if r := mimeRules.ExcludingRule(ctx, policyTree.EffectivePolicy(), entry); r != nil {
+			reason := fmt.Sprintf("matched attribute rule %q", r)
+
+			estimateLog(ctx).Debugf("excluded file %v (%v): %v", relativePath, units.BytesString(entry.Size()), reason)
+			atomic.AddInt32(&stats.ExcludedFileCount, 1)
+			atomic.AddInt64(&stats.ExcludedTotalFileSize, entry.Size())
+			eb.add(relativePath, entry.Size(), reason, maxExamplesPerBucket)
+
+			return nil
+		}
+
+		ib.add(relativePath, entry.Size(), "", maxExamplesPerBucket)
 		atomic.AddInt32(&stats.TotalFileCount, 1)
 		atomic.AddInt64(&stats.TotalFileSize, entry.Size())
 	}
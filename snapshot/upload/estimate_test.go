@@ -21,6 +21,7 @@ type fakeProgress struct {
 	expectedFiles       int32
 	expectedDirectories int32
 	expectedErrors      int32
+	expectedExcluded    int32
 }
 
 func (p *fakeProgress) Processing(context.Context, string) {}
@@ -32,7 +33,7 @@ func (p *fakeProgress) Stats(
 	ctx context.Context,
 	s *snapshot.Stats,
 	includedFiles, excludedFiles upload.SampleBuckets,
-	excludedDirs []string,
+	excludedDirs []upload.ExcludedDir,
 	final bool,
 ) {
 	if !final {
@@ -42,6 +43,7 @@ func (p *fakeProgress) Stats(
 	assert.Equal(p.t, p.expectedErrors, s.ErrorCount)
 	assert.Equal(p.t, p.expectedFiles, s.TotalFileCount)
 	assert.Equal(p.t, p.expectedDirectories, s.TotalDirectoryCount)
+	assert.Equal(p.t, p.expectedExcluded, s.ExcludedFileCount)
 }
 
 func TestEstimate_SkipsStreamingDirectory(t *testing.T) {
@@ -65,3 +67,22 @@ func TestEstimate_SkipsStreamingDirectory(t *testing.T) {
 	err := upload.Estimate(testlogging.Context(t), rootDir, policyTree, p, 1)
 	require.NoError(t, err)
 }
+
+func TestEstimate_MIMETypeRules(t *testing.T) {
+	rootDir := mockfs.NewDirectory()
+	rootDir.AddFile("doc.pdf", []byte("%PDF-1.4\n"), 0o644)
+	rootDir.AddFile("doc.txt", []byte("plain text"), 0o644)
+
+	pol := *policy.DefaultPolicy
+	pol.FilesPolicy.AttributeRules = []string{"mime:application/pdf"}
+
+	p := &fakeProgress{
+		t:                   t,
+		expectedFiles:       1,
+		expectedDirectories: 1,
+		expectedExcluded:    1,
+	}
+
+	err := upload.Estimate(testlogging.Context(t), rootDir, policy.BuildTree(nil, &pol), p, 1)
+	require.NoError(t, err)
+}
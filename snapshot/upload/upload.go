@@ -5,12 +5,14 @@
 	"bytes"
 	"context"
 	stderrors "errors"
+	"fmt"
 	"io"
 	"math/rand"
 	"os"
 	"path"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -21,6 +23,7 @@
 	"go.opentelemetry.io/otel/trace"
 
 	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/archivefs"
 	"github.com/kopia/kopia/fs/ignorefs"
 	"github.com/kopia/kopia/internal/contentlog"
 	"github.com/kopia/kopia/internal/contentlog/logparam"
@@ -77,6 +80,9 @@ type Uploader struct {
 	// 100=never use cached entries
 	ForceHashPercentage float64
 
+	// probability with which files detected as moved since previous snapshots will be hashed again, must be [0..100]
+	ForceHashMovedPercentage float64
+
 	// Number of files to hash and upload in parallel.
 	ParallelUploads int
 
@@ -104,6 +110,9 @@ type Uploader struct {
 	// stats must be allocated on heap to enforce 64-bit alignment due to atomic access on ARM.
 	stats *snapshot.Stats
 
+	// selects compression for files using automatic compression and remembers decisions for file extensions.
+	autoCompression *compression.AutoSelector
+
 	isCanceled atomic.Bool
 
 	getTicker func(time.Duration) <-chan time.Time
@@ -116,6 +125,15 @@ type Uploader struct {
 
 	workerPool *workshare.Pool[*uploadWorkItem]
 
+	// finds files moved since previous snapshots, nil if there are none.
+	movedFiles *movedFileIndex
+
+	// signals of mass changes of files since previous snapshots, nil if anomaly detection is disabled.
+	changeSignals *changeSignals
+
+	// MIME type rules of policies, which are applied to files before they are snapshotted.
+	mimeRules *ignorefs.MIMERules
+
 	traceEnabled bool
 }
 
@@ -164,10 +182,13 @@ func (u *Uploader) uploadFileInternal(ctx context.Context, parentCheckpointRegis
 	metadataComp := pol.MetadataCompressionPolicy.MetadataCompressor()
 	splitterName := pol.SplitterPolicy.SplitterForFile(f)
 
+	// parts of the file share a single automatic compression decision, which is counted once.
+	autoCompression := u.autoCompression.ForFile()
+
 	chunkSize := pol.UploadPolicy.ParallelUploadAboveSize.OrDefault(-1)
 	if chunkSize < 0 || f.Size() <= chunkSize {
 		// all data fits in 1 full chunks, upload directly
-		return u.uploadFileData(ctx, parentCheckpointRegistry, f, f.Name(), 0, -1, comp, metadataComp, splitterName)
+		return u.uploadFileData(ctx, parentCheckpointRegistry, f, f.Name(), 0, -1, comp, metadataComp, splitterName, autoCompression)
 	}
 
 	// we always have N+1 parts, first N are exactly chunkSize, last one has undetermined length
@@ -192,11 +213,11 @@ func (u *Uploader) uploadFileInternal(ctx context.Context, parentCheckpointRegis
 		if wg.CanShareWork(u.workerPool) {
 			// another goroutine is available, delegate to them
 			wg.RunAsync(u.workerPool, func(_ *workshare.Pool[*uploadWorkItem], _ *uploadWorkItem) {
-				parts[i], partErrors[i] = u.uploadFileData(ctx, parentCheckpointRegistry, f, uuid.NewString(), offset, length, comp, metadataComp, splitterName)
+				parts[i], partErrors[i] = u.uploadFileData(ctx, parentCheckpointRegistry, f, uuid.NewString(), offset, length, comp, metadataComp, splitterName, autoCompression)
 			}, nil)
 		} else {
 			// just do the work in the current goroutine
-			parts[i], partErrors[i] = u.uploadFileData(ctx, parentCheckpointRegistry, f, uuid.NewString(), offset, length, comp, metadataComp, splitterName)
+			parts[i], partErrors[i] = u.uploadFileData(ctx, parentCheckpointRegistry, f, uuid.NewString(), offset, length, comp, metadataComp, splitterName, autoCompression)
 		}
 	}
 
@@ -235,7 +256,7 @@ func concatenateParts(ctx context.Context, rep repo.RepositoryWriter, name strin
 	return de, nil
 }
 
-func (u *Uploader) uploadFileData(ctx context.Context, parentCheckpointRegistry *checkpointRegistry, f fs.File, fname string, offset, length int64, compressor, metadataComp compression.Name, splitterName string) (*snapshot.DirEntry, error) {
+func (u *Uploader) uploadFileData(ctx context.Context, parentCheckpointRegistry *checkpointRegistry, f fs.File, fname string, offset, length int64, compressor, metadataComp compression.Name, splitterName string, autoCompression *compression.AutoSelector) (*snapshot.DirEntry, error) {
 	file, err := f.Open(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to open file")
@@ -248,6 +269,8 @@ func (u *Uploader) uploadFileData(ctx context.Context, parentCheckpointRegistry
 		MetadataCompressor: metadataComp,
 		Splitter:           splitterName,
 		AsyncWrites:        1, // upload chunk in parallel to writing another chunk
+		AutoCompression:    autoCompression,
+		AutoCompressionKey: autoCompressionKey(f),
 	})
 	defer writer.Close() //nolint:errcheck
 
@@ -363,6 +386,8 @@ func (u *Uploader) uploadStreamingFileInternal(ctx context.Context, relativePath
 		Compressor:         comp,
 		MetadataCompressor: metadataComp,
 		Splitter:           pol.SplitterPolicy.SplitterForFile(f),
+		AutoCompression:    u.autoCompression,
+		AutoCompressionKey: autoCompressionKey(f),
 	})
 
 	defer writer.Close() //nolint:errcheck
@@ -617,7 +642,7 @@ func (u *Uploader) uploadDirWithCheckpointing(ctx context.Context, rootDir fs.Di
 			rootDir = overrideDir
 		}
 
-		switch osSnapshotDir, cleanup, err := createOSSnapshot(ctx, rootDir, p); {
+		switch osSnapshotDir, cleanup, err := createOSSnapshot(ctx, rootDir, p, u.EnableActions); {
 		case err == nil:
 			defer cleanup()
 
@@ -754,9 +779,9 @@ func findCachedEntry(ctx context.Context, entryRelativePath string, entry fs.Ent
 	return nil
 }
 
-func (u *Uploader) maybeIgnoreCachedEntry(ctx context.Context, ent fs.Entry) fs.Entry {
+func (u *Uploader) maybeIgnoreCachedEntry(ctx context.Context, ent fs.Entry, forceHashPercentage float64) fs.Entry {
 	if h, ok := ent.(object.HasObjectID); ok {
-		if 100*rand.Float64() < u.ForceHashPercentage { //nolint:gosec
+		if 100*rand.Float64() < forceHashPercentage { //nolint:gosec
 			uploadLog(ctx).Debugw("re-hashing cached object", "oid", h.ObjectID())
 			return nil
 		}
@@ -848,9 +873,41 @@ func (u *Uploader) processSingle(
 	// note this function runs in parallel and updates 'u.stats', which must be done using atomic operations.
 	t0 := timetrack.StartTimer()
 
+	if f, ok := entry.(fs.File); ok {
+		// MIME type rules are applied to all files, including unchanged ones, which may have been
+		// snapshotted before the rules were added.
+		if !u.DisableIgnoreRules {
+			if r := u.mimeRules.ExcludingRule(ctx, policyTree.EffectivePolicy(), f); r != nil {
+				u.reportIgnored(uploadLog(ctx), entryRelativePath, f, policyTree, fmt.Sprintf("matched attribute rule %q", r), true)
+				return nil
+			}
+		}
+
+		if format := archiveFormatToExpand(ctx, f, entryRelativePath, policyTree); format != "" {
+			return u.processArchive(ctx, f, format, entryRelativePath, parentDirBuilder, policyTree, prevDirs, parentCheckpointRegistry, t0)
+		}
+	}
+
 	if _, ok := entry.(fs.Directory); !ok {
+		logMessage := "cached"
+
 		// See if we had this name during either of previous passes.
-		if cachedEntry := u.maybeIgnoreCachedEntry(ctx, findCachedEntry(ctx, entryRelativePath, entry, prevDirs, policyTree)); cachedEntry != nil {
+		cachedEntry := u.maybeIgnoreCachedEntry(ctx, findCachedEntry(ctx, entryRelativePath, entry, prevDirs, policyTree), u.ForceHashPercentage)
+
+		if cachedEntry != nil && u.changeSignals != nil {
+			u.changeSignals.unchangedFile()
+		}
+
+		if cachedEntry == nil && u.movedFiles != nil && detectsMovedFiles(policyTree.EffectivePolicy()) {
+			// See if we had this file under a different name.
+			if cachedEntry = u.maybeIgnoreCachedEntry(ctx, u.movedFiles.find(entry), u.ForceHashMovedPercentage); cachedEntry != nil {
+				atomic.AddInt32(&u.stats.MovedFiles, 1)
+
+				logMessage = "cached moved file"
+			}
+		}
+
+		if cachedEntry != nil {
 			atomic.AddInt32(&u.stats.CachedFiles, 1)
 			atomic.AddInt64(&u.stats.TotalFileSize, cachedEntry.Size())
 			u.Progress.CachedFile(entryRelativePath, cachedEntry.Size())
@@ -863,42 +920,28 @@ func (u *Uploader) processSingle(
 				return errors.Wrap(err, "unable to create dir entry")
 			}
 
+			recordFileIdentity(cachedDirEntry, entry, policyTree.EffectivePolicy())
+
 			return u.processEntryUploadResult(ctx, cachedDirEntry, nil, entryRelativePath, parentDirBuilder,
 				false,
 				u.OverrideEntryLogDetail.OrDefault(policyTree.EffectivePolicy().LoggingPolicy.Entries.CacheHit.OrDefault(policy.LogDetailNone)),
-				"cached", t0)
+				logMessage, t0)
 		}
 	}
 
 	switch entry := entry.(type) {
 	case fs.Directory:
-		childDirBuilder := &snapshotfs.DirManifestBuilder{}
-
 		childLocalDirPathOrEmpty := ""
 		if localDirPathOrEmpty != "" {
 			childLocalDirPathOrEmpty = filepath.Join(localDirPathOrEmpty, entry.Name())
 		}
 
-		childTree := policyTree.Child(entry.Name())
-		childPrevDirs := uniqueChildDirectories(ctx, prevDirs, entry.Name())
-
-		de, err := uploadDirInternal(ctx, u, entry, childTree, childPrevDirs, childLocalDirPathOrEmpty, entryRelativePath, childDirBuilder, parentCheckpointRegistry)
-		if errors.Is(err, errCanceled) {
+		de, err := u.uploadChildDirectory(ctx, entry, entryRelativePath, parentDirBuilder, policyTree, prevDirs, childLocalDirPathOrEmpty, parentCheckpointRegistry)
+		if err != nil {
 			return err
 		}
 
-		if err != nil {
-			// Note: This only catches errors in subdirectories of the snapshot root, not on the snapshot
-			// root itself. The intention is to always fail if the top level directory can't be read,
-			// otherwise a meaningless, empty snapshot is created that can't be restored.
-			var dre dirReadError
-			if errors.As(err, &dre) {
-				isIgnoredError := childTree.EffectivePolicy().ErrorHandlingPolicy.IgnoreDirectoryErrors.OrDefault(false)
-				u.reportErrorAndMaybeCancel(dre.error, isIgnoredError, parentDirBuilder, entryRelativePath)
-			} else {
-				return errors.Wrapf(err, "unable to process directory %q", entry.Name())
-			}
-		} else {
+		if de != nil {
 			parentDirBuilder.AddEntry(de)
 		}
 
@@ -917,6 +960,11 @@ func (u *Uploader) processSingle(
 		atomic.AddInt32(&u.stats.NonCachedFiles, 1)
 
 		de, err := u.uploadFileInternal(ctx, parentCheckpointRegistry, entryRelativePath, entry, policyTree.Child(entry.Name()).EffectivePolicy())
+		recordFileIdentity(de, entry, policyTree.EffectivePolicy())
+
+		if err == nil && u.changeSignals != nil {
+			u.changeSignals.uploadedFile(ctx, u.repo, entry, de, prevDirs)
+		}
 
 		return u.processEntryUploadResult(ctx, de, err, entryRelativePath, parentDirBuilder,
 			policyTree.EffectivePolicy().ErrorHandlingPolicy.IgnoreFileErrors.OrDefault(false),
@@ -989,6 +1037,186 @@ func (u *Uploader) processEntryUploadResult(
 	return nil
 }
 
+// uploadChildDirectory uploads the directory and returns its entry or nil if the error reading it has been reported.
+func (u *Uploader) uploadChildDirectory(
+	ctx context.Context,
+	entry fs.Directory,
+	entryRelativePath string,
+	parentDirBuilder *snapshotfs.DirManifestBuilder,
+	policyTree *policy.Tree,
+	prevDirs []fs.Directory,
+	childLocalDirPathOrEmpty string,
+	parentCheckpointRegistry *checkpointRegistry,
+) (*snapshot.DirEntry, error) {
+	childDirBuilder := &snapshotfs.DirManifestBuilder{}
+
+	childTree := policyTree.Child(entry.Name())
+	childPrevDirs := uniqueChildDirectories(ctx, prevDirs, entry.Name())
+
+	de, err := uploadDirInternal(ctx, u, entry, childTree, childPrevDirs, childLocalDirPathOrEmpty, entryRelativePath, childDirBuilder, parentCheckpointRegistry)
+	if errors.Is(err, errCanceled) {
+		return nil, err
+	}
+
+	if err != nil {
+		// Note: This only catches errors in subdirectories of the snapshot root, not on the snapshot
+		// root itself. The intention is to always fail if the top level directory can't be read,
+		// otherwise a meaningless, empty snapshot is created that can't be restored.
+		var dre dirReadError
+		if errors.As(err, &dre) {
+			isIgnoredError := childTree.EffectivePolicy().ErrorHandlingPolicy.IgnoreDirectoryErrors.OrDefault(false)
+			u.reportErrorAndMaybeCancel(dre.error, isIgnoredError, parentDirBuilder, entryRelativePath)
+
+			return nil, nil
+		}
+
+		return nil, errors.Wrapf(err, "unable to process directory %q", entry.Name())
+	}
+
+	return de, nil
+}
+
+// archiveFormatToExpand returns the format of the archive if the policy requests expanding the file
+// or an empty string otherwise.
+func archiveFormatToExpand(ctx context.Context, f fs.File, entryRelativePath string, policyTree *policy.Tree) archivefs.Format {
+	if !policyTree.EffectivePolicy().FilesPolicy.ShouldExpandArchive(f.Name()) {
+		return ""
+	}
+
+	format := archivefs.FormatFromName(f.Name())
+	if format == "" {
+		uploadLog(ctx).Debugf("not expanding %v, unsupported archive type", entryRelativePath)
+	}
+
+	return format
+}
+
+// findCachedArchive returns the directory of the archive expanded in one of the previous directories
+// whose original file has the same metadata as the provided file.
+func findCachedArchive(ctx context.Context, f fs.File, prevDirs []fs.Directory) (fs.Entry, fs.File) {
+	for _, d := range prevDirs {
+		child, err := d.Child(ctx, f.Name())
+		if err != nil {
+			continue
+		}
+
+		if af := snapshotfs.ArchiveFile(child); af != nil && metadataEquals(f, af) {
+			return child, af
+		}
+	}
+
+	return nil, nil
+}
+
+// newCachedArchiveDirEntry makes the DirEntry of an unchanged archive reusing the members and
+// the original file stored in a previous snapshot.
+func newCachedArchiveDirEntry(f fs.File, cachedDir fs.Entry, cachedFile fs.File) (*snapshot.DirEntry, error) {
+	hde, ok := cachedDir.(snapshot.HasDirEntry)
+	if !ok {
+		return nil, errors.New("cached entry does not implement HasDirEntry")
+	}
+
+	fileDE, err := newCachedDirEntry(f, cachedFile, f.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	cached := hde.DirEntry()
+
+	var summ *fs.DirectorySummary
+
+	if s := cached.DirSummary; s != nil {
+		s2 := s.Clone()
+
+		summ = &s2
+	}
+
+	return &snapshot.DirEntry{
+		Name:          f.Name(),
+		Type:          snapshot.EntryTypeDirectory,
+		Permissions:   fileDE.Permissions,
+		ModTime:       fileDE.ModTime,
+		UserID:        fileDE.UserID,
+		GroupID:       fileDE.GroupID,
+		ObjectID:      cached.ObjectID,
+		DirSummary:    summ,
+		ArchiveFormat: cached.ArchiveFormat,
+		ArchiveFile:   fileDE,
+	}, nil
+}
+
+// processArchive uploads the original archive file together with the directory of its members,
+// which are expanded only if the archive has changed since the previous snapshot.
+// Archives which can't be read are uploaded as regular files.
+func (u *Uploader) processArchive(
+	ctx context.Context,
+	f fs.File,
+	format archivefs.Format,
+	entryRelativePath string,
+	parentDirBuilder *snapshotfs.DirManifestBuilder,
+	policyTree *policy.Tree,
+	prevDirs []fs.Directory,
+	parentCheckpointRegistry *checkpointRegistry,
+	t0 timetrack.Timer,
+) error {
+	pol := policyTree.EffectivePolicy()
+
+	if cachedDir, cachedFile := findCachedArchive(ctx, f, prevDirs); cachedDir != nil && u.maybeIgnoreCachedEntry(ctx, cachedFile, u.ForceHashPercentage) != nil {
+		atomic.AddInt32(&u.stats.CachedFiles, 1)
+		atomic.AddInt64(&u.stats.TotalFileSize, f.Size())
+		u.Progress.CachedFile(entryRelativePath, f.Size())
+
+		de, err := newCachedArchiveDirEntry(f, cachedDir, cachedFile)
+
+		u.Progress.FinishedFile(entryRelativePath, err)
+
+		if err != nil {
+			return errors.Wrap(err, "unable to create dir entry")
+		}
+
+		return u.processEntryUploadResult(ctx, de, nil, entryRelativePath, parentDirBuilder,
+			false,
+			u.OverrideEntryLogDetail.OrDefault(pol.LoggingPolicy.Entries.CacheHit.OrDefault(policy.LogDetailNone)),
+			"cached archive", t0)
+	}
+
+	atomic.AddInt32(&u.stats.NonCachedFiles, 1)
+
+	fileDE, err := u.uploadFileInternal(ctx, parentCheckpointRegistry, entryRelativePath, f, policyTree.Child(f.Name()).EffectivePolicy())
+	if err != nil {
+		return u.processEntryUploadResult(ctx, nil, err, entryRelativePath, parentDirBuilder,
+			pol.ErrorHandlingPolicy.IgnoreFileErrors.OrDefault(false),
+			u.OverrideEntryLogDetail.OrDefault(pol.LoggingPolicy.Entries.Snapshotted.OrDefault(policy.LogDetailNone)),
+			"snapshotted file", t0)
+	}
+
+	// members are read from the uploaded copy, so that they always match the original file.
+	ad, err := archivefs.Open(ctx, snapshotfs.EntryFromDirEntry(u.repo, fileDE).(fs.File), format, archivefs.Options{}) //nolint:forcetypeassert
+	if err != nil {
+		uploadLog(ctx).Warnf("unable to expand archive %v, uploading as a file: %v", entryRelativePath, err)
+
+		return u.processEntryUploadResult(ctx, fileDE, nil, entryRelativePath, parentDirBuilder,
+			false,
+			u.OverrideEntryLogDetail.OrDefault(pol.LoggingPolicy.Entries.Snapshotted.OrDefault(policy.LogDetailNone)),
+			"snapshotted file", t0)
+	}
+
+	defer ad.Close()
+
+	de, err := u.uploadChildDirectory(ctx, ad, entryRelativePath, parentDirBuilder, policyTree, prevDirs, "", parentCheckpointRegistry)
+	if err != nil {
+		return err
+	}
+
+	if de != nil {
+		de.ArchiveFormat = format
+		de.ArchiveFile = fileDE
+		parentDirBuilder.AddEntry(de)
+	}
+
+	return nil
+}
+
 func uniqueChildDirectories(ctx context.Context, dirs []fs.Directory, childName string) []fs.Directory {
 	var result []fs.Directory
 
@@ -1003,14 +1231,14 @@ func uniqueChildDirectories(ctx context.Context, dirs []fs.Directory, childName
 	return uniqueDirectories(result)
 }
 
-func maybeLogEntryProcessed(logger logging.Logger, level policy.LogDetail, msg, relativePath string, de *snapshot.DirEntry, err error, timer timetrack.Timer) {
+func maybeLogEntryProcessed(logger logging.Logger, level policy.LogDetail, msg, relativePath string, de *snapshot.DirEntry, err error, timer timetrack.Timer, extraKeyValues ...any) {
 	if level <= policy.LogDetailNone && err == nil {
 		return
 	}
 
 	var (
 		bitsBuf       [10]any
-		keyValuePairs = append(bitsBuf[:0], "path", relativePath)
+		keyValuePairs = append(append(bitsBuf[:0], "path", relativePath), extraKeyValues...)
 	)
 
 	if err != nil {
@@ -1301,6 +1529,10 @@ func (u *Uploader) Upload(
 	prototypeMan := s
 
 	u.stats = &snapshot.Stats{}
+	u.movedFiles = nil
+	u.changeSignals = nil
+	u.mimeRules = &ignorefs.MIMERules{}
+	u.autoCompression = compression.NewAutoSelector()
 	u.totalWrittenBytes.Store(0)
 
 	var err error
@@ -1323,11 +1555,29 @@ func (u *Uploader) Upload(
 
 	s.IncompleteReason = u.incompleteReason()
 	s.EndTime = fs.UTCTimestampFromTime(u.repo.Time())
+
+	if u.changeSignals != nil {
+		for _, a := range u.changeSignals.anomalies(policyTree.EffectivePolicy().AnomalyDetection) {
+			uploadLog(ctx).Warnw("anomaly detected", "source", sourceInfo, "kind", a.Kind, "description", a.Description)
+			s.AddAnomaly(a.Kind, a.Description)
+		}
+	}
+
+	acs := u.autoCompression.Stats()
+	u.stats.AutoCompressionNoneCount = acs.None
+	u.stats.AutoCompressionFastCount = acs.Fast
+	u.stats.AutoCompressionStrongCount = acs.Strong
+
 	s.Stats = *u.stats
 
 	return &s, nil
 }
 
+// autoCompressionKey returns the key under which automatic compression decisions for a file are remembered.
+func autoCompressionKey(f fs.Entry) string {
+	return strings.ToLower(filepath.Ext(f.Name()))
+}
+
 func (u *Uploader) uploadDir(
 	ctx context.Context,
 	previousManifests []*snapshot.Manifest,
@@ -1343,12 +1593,21 @@ func (u *Uploader) uploadDir(
 		}
 	}
 
+	if len(previousDirs) > 0 && policyTree.EffectivePolicy().AnomalyDetection.Enabled.OrDefault(false) {
+		u.changeSignals = newChangeSignals()
+	}
+
 	estimationCtl := u.startDataSizeEstimation(ctx, entry, policyTree)
 	defer func() {
 		estimationCtl.Cancel()
 		estimationCtl.Wait()
 	}()
 
+	// moved files are indexed while the size of the upload is being estimated, before any file is processed.
+	if len(previousDirs) > 0 && policyTree.AnyEffectivePolicy(detectsMovedFiles) {
+		u.movedFiles = buildMovedFileIndex(ctx, previousDirs)
+	}
+
 	wrapped := u.wrapIgnorefs(uploadLog(ctx), entry, policyTree, true /* reportIgnoreStats */)
 
 	return u.uploadDirWithCheckpointing(ctx, wrapped, policyTree, previousDirs, prototypeManifest)
@@ -1381,27 +1640,31 @@ func (u *Uploader) wrapIgnorefs(logger logging.Logger, entry fs.Directory, polic
 		return entry
 	}
 
-	return ignorefs.New(entry, policyTree, ignorefs.ReportIgnoredFiles(func(_ context.Context, fname string, md fs.Entry, policyTree *policy.Tree) {
-		if md.IsDir() {
-			maybeLogEntryProcessed(
-				logger,
-				policyTree.EffectivePolicy().LoggingPolicy.Directories.Ignored.OrDefault(policy.LogDetailNone),
-				"ignored directory", fname, nil, nil, timetrack.StartTimer())
+	return ignorefs.New(entry, policyTree, ignorefs.ReportIgnoredFiles(func(_ context.Context, fname string, md fs.Entry, policyTree *policy.Tree, reason string) {
+		u.reportIgnored(logger, fname, md, policyTree, reason, reportIgnoreStats)
+	}))
+}
 
-			if reportIgnoreStats {
-				u.Progress.ExcludedDir(fname)
-			}
-		} else {
-			maybeLogEntryProcessed(
-				logger,
-				policyTree.EffectivePolicy().LoggingPolicy.Entries.Ignored.OrDefault(policy.LogDetailNone),
-				"ignored", fname, nil, nil, timetrack.StartTimer())
+func (u *Uploader) reportIgnored(logger logging.Logger, fname string, md fs.Entry, policyTree *policy.Tree, reason string, reportIgnoreStats bool) {
+	if md.IsDir() {
+		maybeLogEntryProcessed(
+			logger,
+			policyTree.EffectivePolicy().LoggingPolicy.Directories.Ignored.OrDefault(policy.LogDetailNone),
+			"ignored directory", fname, nil, nil, timetrack.StartTimer(), "reason", reason)
 
-			if reportIgnoreStats {
-				u.Progress.ExcludedFile(fname, md.Size())
-			}
+		if reportIgnoreStats {
+			u.Progress.ExcludedDir(fname)
 		}
+	} else {
+		maybeLogEntryProcessed(
+			logger,
+			policyTree.EffectivePolicy().LoggingPolicy.Entries.Ignored.OrDefault(policy.LogDetailNone),
+			"ignored", fname, nil, nil, timetrack.StartTimer(), "reason", reason)
 
-		u.stats.AddExcluded(md)
-	}))
+		if reportIgnoreStats {
+			u.Progress.ExcludedFile(fname, md.Size())
+		}
+	}
+
+	u.stats.AddExcluded(md)
 }
@@ -0,0 +1,237 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+const (
+	// modified files smaller than that are not sampled, since the entropy of their contents is not meaningful.
+	minEntropySampleFileSize = 4096
+
+	// number of bytes read from the beginning of the previous and current version of a modified file.
+	entropySampleSize = 16384
+
+	// maximum number of modified files sampled per snapshot and minimum for the entropy signal to be meaningful.
+	maxEntropySamples = 1000
+	minEntropySamples = 10
+
+	// entropy in bits per byte below which contents are considered compressible and above which they are likely encrypted.
+	compressibleEntropy   = 7.0
+	incompressibleEntropy = 7.5
+)
+
+// changeSignals accumulates signals of mass changes of files compared to previous snapshots,
+// which are typical for ransomware encrypting files.
+type changeSignals struct {
+	comparedFiles    atomic.Int32 // files which were found in previous snapshots
+	modifiedFiles    atomic.Int32 // files found in previous snapshots under the same name with different contents
+	sampledFiles     atomic.Int32 // modified files whose entropy was sampled
+	entropyIncreases atomic.Int32 // sampled files, which were compressible and became incompressible
+
+	mu sync.Mutex
+	// number of files which replaced a file from previous snapshots with the same name and an extension appended.
+	// +checklocks:mu
+	appendedExtensions map[string]int
+}
+
+func newChangeSignals() *changeSignals {
+	return &changeSignals{
+		appendedExtensions: map[string]int{},
+	}
+}
+
+// unchangedFile records a file found in previous snapshots under the same name with unchanged metadata.
+func (s *changeSignals) unchangedFile() {
+	s.comparedFiles.Add(1)
+}
+
+// uploadedFile records a file which was not found in previous snapshots or whose metadata has changed.
+func (s *changeSignals) uploadedFile(ctx context.Context, rep repo.Repository, entry fs.Entry, de *snapshot.DirEntry, prevDirs []fs.Directory) {
+	if prev := findPreviousFile(ctx, entry.Name(), prevDirs); prev != nil {
+		s.comparedFiles.Add(1)
+
+		prevOID := prev.(object.HasObjectID).ObjectID() //nolint:forcetypeassert
+
+		if prevOID == de.ObjectID {
+			return
+		}
+
+		s.modifiedFiles.Add(1)
+
+		if prev.Size() >= minEntropySampleFileSize && de.FileSize >= minEntropySampleFileSize {
+			s.sampleEntropy(ctx, rep, prevOID, de.ObjectID)
+		}
+
+		return
+	}
+
+	// a new file, see if it replaced a file from previous snapshots by appending an extension, e.g. "a.doc" => "a.doc.locked".
+	name := entry.Name()
+
+	ext := path.Ext(name)
+	if ext == "" || ext == name {
+		return
+	}
+
+	if findPreviousFile(ctx, strings.TrimSuffix(name, ext), prevDirs) == nil {
+		return
+	}
+
+	s.comparedFiles.Add(1)
+
+	s.mu.Lock()
+	s.appendedExtensions[strings.ToLower(ext)]++
+	s.mu.Unlock()
+}
+
+func (s *changeSignals) sampleEntropy(ctx context.Context, rep repo.Repository, prevOID, oid object.ID) {
+	if s.sampledFiles.Add(1) > maxEntropySamples {
+		s.sampledFiles.Add(-1)
+		return
+	}
+
+	prevEntropy, err := sampleObjectEntropy(ctx, rep, prevOID)
+	if err == nil {
+		var newEntropy float64
+
+		if newEntropy, err = sampleObjectEntropy(ctx, rep, oid); err == nil {
+			if prevEntropy < compressibleEntropy && newEntropy >= incompressibleEntropy {
+				s.entropyIncreases.Add(1)
+			}
+
+			return
+		}
+	}
+
+	uploadLog(ctx).Debugw("unable to sample entropy", "error", err)
+	s.sampledFiles.Add(-1)
+}
+
+// anomalies returns the signals which exceed the thresholds in the policy.
+func (s *changeSignals) anomalies(pol policy.AnomalyDetectionPolicy) []snapshot.Anomaly {
+	compared := int(s.comparedFiles.Load())
+	if compared == 0 || compared < pol.MinFiles.OrDefault(policy.DefaultAnomalyMinFiles) {
+		return nil
+	}
+
+	var result []snapshot.Anomaly
+
+	if modified := int(s.modifiedFiles.Load()); exceedsThreshold(modified, compared, pol.ModifiedFilesPercent, policy.DefaultAnomalyModifiedFilesPercent) {
+		result = append(result, snapshot.Anomaly{
+			Kind:        snapshot.AnomalyModifiedFiles,
+			Description: fmt.Sprintf("%v%% of files were modified (%v of %v), threshold is %v%%", percent(modified, compared), modified, compared, pol.ModifiedFilesPercent.OrDefault(policy.DefaultAnomalyModifiedFilesPercent)),
+		})
+	}
+
+	if ext, renamed := s.mostAppendedExtension(); exceedsThreshold(renamed, compared, pol.ExtensionChurnPercent, policy.DefaultAnomalyExtensionChurnPercent) {
+		result = append(result, snapshot.Anomaly{
+			Kind:        snapshot.AnomalyExtensionChurn,
+			Description: fmt.Sprintf("%v%% of files were renamed with the %q extension appended (%v of %v), threshold is %v%%", percent(renamed, compared), ext, renamed, compared, pol.ExtensionChurnPercent.OrDefault(policy.DefaultAnomalyExtensionChurnPercent)),
+		})
+	}
+
+	if sampled, increased := int(s.sampledFiles.Load()), int(s.entropyIncreases.Load()); sampled >= minEntropySamples && exceedsThreshold(increased, sampled, pol.EntropyIncreasePercent, policy.DefaultAnomalyEntropyIncreasePercent) {
+		result = append(result, snapshot.Anomaly{
+			Kind:        snapshot.AnomalyEntropyIncrease,
+			Description: fmt.Sprintf("%v%% of sampled modified files became incompressible (%v of %v), threshold is %v%%", percent(increased, sampled), increased, sampled, pol.EntropyIncreasePercent.OrDefault(policy.DefaultAnomalyEntropyIncreasePercent)),
+		})
+	}
+
+	return result
+}
+
+func (s *changeSignals) mostAppendedExtension() (ext string, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var exts []string
+
+	for e := range s.appendedExtensions {
+		exts = append(exts, e)
+	}
+
+	// for deterministic results when counts are equal.
+	sort.Strings(exts)
+
+	for _, e := range exts {
+		if c := s.appendedExtensions[e]; c > count {
+			ext, count = e, c
+		}
+	}
+
+	return ext, count
+}
+
+func exceedsThreshold(count, total int, threshold *policy.OptionalInt, defaultThreshold int) bool {
+	return count > 0 && percent(count, total) >= threshold.OrDefault(defaultThreshold)
+}
+
+func percent(count, total int) int {
+	return 100 * count / total //nolint:mnd
+}
+
+// findPreviousFile returns the file with the provided name from previous snapshots or nil if not found.
+func findPreviousFile(ctx context.Context, name string, prevDirs []fs.Directory) fs.Entry {
+	for _, d := range prevDirs {
+		if e, err := d.Child(ctx, name); err == nil {
+			if _, ok := e.(object.HasObjectID); ok && !e.IsDir() {
+				return e
+			}
+		}
+	}
+
+	return nil
+}
+
+// sampleObjectEntropy returns the Shannon entropy in bits per byte of the beginning of the object.
+func sampleObjectEntropy(ctx context.Context, rep repo.Repository, oid object.ID) (float64, error) {
+	r, err := rep.OpenObject(ctx, oid)
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to open object")
+	}
+	defer r.Close() //nolint:errcheck
+
+	buf := make([]byte, entropySampleSize)
+
+	n, err := io.ReadFull(r, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return 0, errors.Wrap(err, "unable to read object")
+	}
+
+	return byteEntropy(buf[:n]), nil
+}
+
+func byteEntropy(b []byte) float64 {
+	var counts [256]int
+
+	for _, c := range b {
+		counts[c]++
+	}
+
+	var result float64
+
+	for _, c := range counts {
+		if c > 0 {
+			p := float64(c) / float64(len(b))
+			result -= p * math.Log2(p)
+		}
+	}
+
+	return result
+}
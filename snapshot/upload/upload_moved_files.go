@@ -0,0 +1,137 @@
+package upload
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/timetrack"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+// movedFileKey identifies a file independently of its path.
+type movedFileKey struct {
+	device uint64
+	inode  uint64
+	ctime  int64 // zero on platforms which don't provide it
+	size   int64
+	mtime  fs.UTCTimestamp
+}
+
+// movedFileIndexProgressInterval is the number of indexed files between progress log messages.
+const movedFileIndexProgressInterval = 100000
+
+// movedFileIndex finds files from previous snapshots by their device, inode, change time, size and modification time,
+// which allows files that were moved or renamed since then to be reused without hashing them.
+// Some filesystems update the change time of renamed files, in which case only files moved together
+// with their directories are found.
+//
+// The index is built before the upload by walking the previous snapshots, which include checkpoints
+// of interrupted uploads, so the files moved while resuming an upload are found as well.
+type movedFileIndex struct {
+	objectIDs map[movedFileKey]object.ID
+	indexed   int
+}
+
+// buildMovedFileIndex indexes files in previous snapshots.
+func buildMovedFileIndex(ctx context.Context, prevDirs []fs.Directory) *movedFileIndex {
+	t0 := timetrack.StartTimer()
+
+	x := &movedFileIndex{objectIDs: map[movedFileKey]object.ID{}}
+
+	for _, d := range prevDirs {
+		if err := x.addDirectory(ctx, d); err != nil {
+			uploadLog(ctx).Debugw("unable to index previous snapshot for moved files", "error", err)
+		}
+	}
+
+	uploadLog(ctx).Debugw("indexed previous snapshots for moved files", "files", len(x.objectIDs), "dur", t0.Elapsed())
+
+	return x
+}
+
+// find returns the file with the object ID from previous snapshots with the same identity as the provided file
+// or nil if not found.
+func (x *movedFileIndex) find(entry fs.Entry) fs.Entry {
+	if _, ok := entry.(fs.File); !ok {
+		return nil
+	}
+
+	d := entry.Device()
+	if d.Ino == 0 {
+		return nil
+	}
+
+	oid, ok := x.objectIDs[movedFileKey{d.Dev, d.Ino, d.Ctime, entry.Size(), fs.UTCTimestampFromTime(entry.ModTime())}]
+	if !ok {
+		return nil
+	}
+
+	return movedFile{entry, oid}
+}
+
+func (x *movedFileIndex) addDirectory(ctx context.Context, dir fs.Directory) error {
+	return errors.Wrapf(fs.IterateEntries(ctx, dir, func(ctx context.Context, e fs.Entry) error {
+		h, ok := e.(snapshot.HasDirEntry)
+		if !ok {
+			return nil
+		}
+
+		de := h.DirEntry()
+
+		switch {
+		case de.ArchiveFormat != "":
+			// members of expanded archives are never moved on their own.
+			return nil
+
+		case de.Type == snapshot.EntryTypeDirectory:
+			if sd, ok := e.(fs.Directory); ok {
+				return x.addDirectory(ctx, sd)
+			}
+
+		case de.Type == snapshot.EntryTypeFile && de.Inode != 0:
+			key := movedFileKey{de.Device, de.Inode, de.Ctime, de.FileSize, de.ModTime}
+			if _, ok := x.objectIDs[key]; !ok {
+				x.objectIDs[key] = de.ObjectID
+			}
+
+			if x.indexed++; x.indexed%movedFileIndexProgressInterval == 0 {
+				uploadLog(ctx).Infof("Indexed %v files of previous snapshots to detect moved files...", x.indexed)
+			}
+		}
+
+		return nil
+	}), "error indexing %v", dir.Name())
+}
+
+// movedFile is a file found in previous snapshots under a different name, which has the same contents.
+type movedFile struct {
+	fs.Entry
+
+	oid object.ID
+}
+
+func (f movedFile) ObjectID() object.ID {
+	return f.oid
+}
+
+func detectsMovedFiles(pol *policy.Policy) bool {
+	return pol.UploadPolicy.DetectMovedFiles.OrDefault(false)
+}
+
+// recordFileIdentity stores the device and inode of the file in its directory entry,
+// which allows the next snapshot to find the file after it has been moved or renamed.
+func recordFileIdentity(de *snapshot.DirEntry, entry fs.Entry, pol *policy.Policy) {
+	if de == nil || de.Type != snapshot.EntryTypeFile || !detectsMovedFiles(pol) {
+		return
+	}
+
+	if d := entry.Device(); d.Ino != 0 {
+		de.Device = d.Dev
+		de.Inode = d.Ino
+		de.Ctime = d.Ctime
+	}
+}
@@ -8,13 +8,53 @@
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/snapshot/ossnapshot"
 	"github.com/kopia/kopia/snapshot/policy"
 )
 
-func osSnapshotMode(*policy.OSSnapshotPolicy) policy.OSSnapshotMode {
-	return policy.OSSnapshotNever
+func osSnapshotMode(p *policy.OSSnapshotPolicy) policy.OSSnapshotMode {
+	return p.FilesystemSnapshot.Enable.OrDefault(policy.OSSnapshotNever)
 }
 
-func createOSSnapshot(context.Context, fs.Directory, *policy.OSSnapshotPolicy) (newRoot fs.Directory, cleanup func(), err error) {
-	return nil, nil, errors.New("not supported on this platform")
+func createOSSnapshot(ctx context.Context, root fs.Directory, p *policy.OSSnapshotPolicy, actionsEnabled bool) (newRoot fs.Directory, cleanup func(), err error) {
+	local := root.LocalFilesystemPath()
+	if local == "" {
+		return nil, nil, errors.New("not a local filesystem")
+	}
+
+	// commands come from the policy stored in the repository, so they are subject to the same gate as actions.
+	if p.FilesystemSnapshot.ProviderOrDefault() == policy.FilesystemSnapshotProviderCommand && !actionsEnabled {
+		return nil, nil, errors.New("command file system snapshot provider requires actions to be enabled")
+	}
+
+	prov, err := ossnapshot.NewProvider(&p.FilesystemSnapshot)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to create snapshot provider")
+	}
+
+	uploadLog(ctx).Infof("creating %v file system snapshot of %v", prov.Name(), local)
+
+	snap, err := prov.Create(ctx, local)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "unable to create %v snapshot", prov.Name())
+	}
+
+	// the snapshot must be removed even if the upload gets canceled.
+	removeSnapshot := func() {
+		if err := snap.Remove(context.WithoutCancel(ctx)); err != nil {
+			uploadLog(ctx).Errorf("unable to remove file system snapshot: %v", err)
+		}
+	}
+
+	newRoot, err = localfs.Directory(snap.Path)
+	if err != nil {
+		removeSnapshot()
+
+		return nil, nil, errors.Wrapf(err, "unable to open snapshot directory %v", snap.Path)
+	}
+
+	uploadLog(ctx).Debugf("snapshot of %v is at %v", local, snap.Path)
+
+	return newRoot, removeSnapshot, nil
 }
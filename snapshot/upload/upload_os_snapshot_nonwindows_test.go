@@ -0,0 +1,152 @@
+//go:build !windows
+
+package upload
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/ossnapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+// markingProvider creates fake snapshots and adds a marker file to each of them, so that tests
+// can tell whether the upload was read from the snapshot.
+type markingProvider struct {
+	*ossnapshot.FakeProvider
+}
+
+func (p markingProvider) Create(ctx context.Context, path string) (*ossnapshot.Snapshot, error) {
+	snap, err := p.FakeProvider.Create(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(filepath.Join(snap.Path, "from-snapshot"), []byte{1, 2}, 0o600); err != nil {
+		return nil, errors.Wrap(err, "unable to write marker")
+	}
+
+	return snap, nil
+}
+
+func TestUpload_FilesystemSnapshot(t *testing.T) {
+	cases := []struct {
+		name       string
+		mode       policy.OSSnapshotMode
+		createErr  error
+		wantErr    bool
+		wantMarker bool
+	}{
+		{name: "Always", mode: policy.OSSnapshotAlways, wantMarker: true},
+		{name: "WhenAvailable", mode: policy.OSSnapshotWhenAvailable, wantMarker: true},
+		{name: "WhenAvailableFailure", mode: policy.OSSnapshotWhenAvailable, createErr: errTest},
+		{name: "AlwaysFailure", mode: policy.OSSnapshotAlways, createErr: errTest, wantErr: true},
+		{name: "Never", mode: policy.OSSnapshotNever},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := testlogging.Context(t)
+			th := newUploadTestHarness(ctx, t)
+
+			t.Cleanup(th.cleanup)
+
+			fake := &ossnapshot.FakeProvider{CreateErr: tc.createErr}
+			providerName := "test-marking-" + tc.name
+
+			ossnapshot.RegisterProvider(providerName, func(*policy.FilesystemSnapshotPolicy, ossnapshot.Runner) (ossnapshot.Provider, error) {
+				return markingProvider{fake}, nil
+			})
+
+			td := testutil.TempDirectory(t)
+			require.NoError(t, os.WriteFile(filepath.Join(td, "f1"), []byte{1, 2, 3, 4}, 0o600))
+
+			srcdir, err := localfs.Directory(td)
+			require.NoError(t, err)
+
+			pol := *policy.DefaultPolicy
+			pol.OSSnapshotPolicy.FilesystemSnapshot = policy.FilesystemSnapshotPolicy{
+				Enable:   policy.NewOSSnapshotMode(tc.mode),
+				Provider: providerName,
+			}
+
+			man, err := NewUploader(th.repo).Upload(ctx, srcdir, policy.BuildTree(nil, &pol), snapshot.SourceInfo{})
+			if tc.wantErr {
+				require.ErrorIs(t, err, errTest)
+				require.Empty(t, fake.Created())
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			entries, err := fs.GetAllEntries(ctx, snapshotfs.DirectoryEntry(th.repo, man.RootObjectID(), nil))
+			require.NoError(t, err)
+
+			var names []string
+
+			for _, e := range entries {
+				names = append(names, e.Name())
+			}
+
+			if tc.wantMarker {
+				require.ElementsMatch(t, []string{"f1", "from-snapshot"}, names)
+			} else {
+				require.Equal(t, []string{"f1"}, names)
+			}
+
+			// every snapshot that was created must have been removed.
+			require.Equal(t, fake.Created(), fake.Removed())
+
+			for _, p := range fake.Removed() {
+				require.NoDirExists(t, p)
+			}
+		})
+	}
+}
+
+func TestUpload_FilesystemSnapshotCommandRequiresActions(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	t.Cleanup(th.cleanup)
+
+	td := testutil.TempDirectory(t)
+	marker := filepath.Join(td, "marker")
+
+	srcdir, err := localfs.Directory(td)
+	require.NoError(t, err)
+
+	pol := *policy.DefaultPolicy
+	pol.OSSnapshotPolicy.FilesystemSnapshot = policy.FilesystemSnapshotPolicy{
+		Enable:        policy.NewOSSnapshotMode(policy.OSSnapshotAlways),
+		Provider:      policy.FilesystemSnapshotProviderCommand,
+		CreateCommand: "touch " + marker,
+		RemoveCommand: "true",
+	}
+
+	u := NewUploader(th.repo)
+	u.EnableActions = false
+
+	_, err = u.Upload(ctx, srcdir, policy.BuildTree(nil, &pol), snapshot.SourceInfo{})
+	require.ErrorContains(t, err, "requires actions to be enabled")
+	require.NoFileExists(t, marker)
+
+	// when available, the snapshot is skipped with a warning.
+	pol.OSSnapshotPolicy.FilesystemSnapshot.Enable = policy.NewOSSnapshotMode(policy.OSSnapshotWhenAvailable)
+
+	_, err = u.Upload(ctx, srcdir, policy.BuildTree(nil, &pol), snapshot.SourceInfo{})
+	require.NoError(t, err)
+	require.NoFileExists(t, marker)
+}
@@ -20,7 +20,7 @@ func osSnapshotMode(p *policy.OSSnapshotPolicy) policy.OSSnapshotMode {
 }
 
 //nolint:wrapcheck
-func createOSSnapshot(ctx context.Context, root fs.Directory, _ *policy.OSSnapshotPolicy) (newRoot fs.Directory, cleanup func(), finalErr error) {
+func createOSSnapshot(ctx context.Context, root fs.Directory, _ *policy.OSSnapshotPolicy, _ bool) (newRoot fs.Directory, cleanup func(), finalErr error) {
 	local := root.LocalFilesystemPath()
 	if local == "" {
 		return nil, nil, errors.New("not a local filesystem")
@@ -1,6 +1,7 @@
 package upload
 
 import (
+	"archive/zip"
 	"bytes"
 	"context"
 	"crypto/rand"
@@ -11,6 +12,7 @@
 	"os"
 	"path/filepath"
 	"runtime/debug"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -1321,6 +1323,36 @@ func verifyFileContent(t *testing.T, f1Entry fs.File, f2Name string) {
 	}
 }
 
+func TestParallelUploadAutoCompressionStats(t *testing.T) {
+	t.Parallel()
+
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	t.Cleanup(th.cleanup)
+
+	u := NewUploader(th.repo)
+	u.ParallelUploads = 4
+
+	pol := *policy.DefaultPolicy
+	pol.CompressionPolicy.CompressorName = compression.AutoCompressorName
+
+	n := policy.OptionalInt64(1 << 20)
+	pol.UploadPolicy.ParallelUploadAboveSize = &n
+
+	root := mockfs.NewDirectory()
+	root.AddFile("large.txt", bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog. "), 100000), 0o644)
+	root.AddFile("small.txt", []byte("hello world"), 0o644)
+
+	man, err := u.Upload(ctx, root, policy.BuildTree(nil, &pol), snapshot.SourceInfo{})
+	require.NoError(t, err)
+
+	// parts of the large file are counted as a single file.
+	require.Equal(t, int32(2), man.Stats.AutoCompressionStrongCount)
+	require.Zero(t, man.Stats.AutoCompressionFastCount)
+	require.Zero(t, man.Stats.AutoCompressionNoneCount)
+}
+
 func verifyContainsOffset(t *testing.T, entries []object.IndirectObjectEntry, want int64) {
 	t.Helper()
 
@@ -1562,8 +1594,8 @@ func TestUploadLogging(t *testing.T) {
 				"snapshotted file":      {"dur", "path", "size"},
 				"snapshotted symlink":   {"dur", "path", "size"},
 				"snapshotted directory": {"dur", "path", "size"},
-				"ignored directory":     {"dur", "path"},
-				"ignored":               {"dur", "path"},
+				"ignored directory":     {"dur", "path", "reason"},
+				"ignored":               {"dur", "path", "reason"},
 			},
 			wantEntries: []string{
 				"ignored directory d1/d3",
@@ -1708,3 +1740,341 @@ func verifyLogDetails(t *testing.T, desc string, wantDetailKeys []string, keysAn
 	sort.Strings(wantDetailKeys)
 	require.Equal(t, wantDetailKeys, gotDetailKeys, "invalid details for "+desc)
 }
+
+func makeTestZip(t *testing.T, members map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	zw := zip.NewWriter(&buf)
+
+	for _, name := range slices.Sorted(maps.Keys(members)) {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: mockfs.DefaultModTime})
+		require.NoError(t, err)
+
+		_, err = io.WriteString(w, members[name])
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, zw.Close())
+
+	return buf.Bytes()
+}
+
+func TestUpload_ExpandArchives(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	t.Cleanup(th.cleanup)
+
+	pol := *policy.DefaultPolicy
+	pol.FilesPolicy.ExpandArchives = []string{"a*.zip"}
+	policyTree := policy.BuildTree(nil, &pol)
+
+	unchanged := strings.Repeat("unchanged member ", 1000)
+
+	src1 := mockfs.NewDirectory()
+	src1.AddFile("a.zip", makeTestZip(t, map[string]string{"dir/m1": unchanged, "m2": "v1"}), defaultPermissions)
+	src1.AddFile("a-invalid.zip", []byte("not a zip file"), defaultPermissions)
+	src1.AddFile("b.zip", makeTestZip(t, map[string]string{"m1": "b"}), defaultPermissions)
+
+	u := NewUploader(th.repo)
+
+	man1, err := u.Upload(ctx, src1, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+
+	root := snapshotfs.EntryFromDirEntry(th.repo, man1.RootEntry).(fs.Directory)
+
+	archive, err := root.Child(ctx, "a.zip")
+	require.NoError(t, err)
+
+	archiveDir, ok := archive.(fs.Directory)
+	require.True(t, ok, "archive was not expanded")
+	require.Equal(t, "zip", archive.(snapshot.HasDirEntry).DirEntry().ArchiveFormat)
+	require.NotNil(t, snapshotfs.ArchiveFile(archive), "original archive was not stored")
+
+	m1, err := fs.IterateEntriesAndFindChild(ctx, archiveDir, "dir")
+	require.NoError(t, err)
+
+	m1, err = m1.(fs.Directory).Child(ctx, "m1")
+	require.NoError(t, err)
+
+	r, err := m1.(fs.File).Open(ctx)
+	require.NoError(t, err)
+
+	content, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.Equal(t, unchanged, string(content))
+
+	// archives which can't be read and those not matching the patterns are uploaded as files.
+	for _, name := range []string{"a-invalid.zip", "b.zip"} {
+		e, err := root.Child(ctx, name)
+		require.NoError(t, err)
+
+		_, ok := e.(fs.File)
+		require.True(t, ok, name)
+	}
+
+	// new version of the archive with one member changed.
+	src2 := mockfs.NewDirectory()
+	src2.AddFile("a.zip", makeTestZip(t, map[string]string{"dir/m1": unchanged, "m2": "version 2"}), defaultPermissions)
+
+	man2, err := u.Upload(ctx, src2, policyTree, snapshot.SourceInfo{}, man1)
+	require.NoError(t, err)
+
+	// the original archive and the changed member are uploaded.
+	require.Equal(t, int32(1), atomic.LoadInt32(&man2.Stats.CachedFiles))
+	require.Equal(t, int32(2), atomic.LoadInt32(&man2.Stats.NonCachedFiles))
+
+	// unchanged archives are not expanded again.
+	man3, err := u.Upload(ctx, src2, policyTree, snapshot.SourceInfo{}, man2)
+	require.NoError(t, err)
+
+	require.Equal(t, int32(1), atomic.LoadInt32(&man3.Stats.CachedFiles))
+	require.Equal(t, int32(0), atomic.LoadInt32(&man3.Stats.NonCachedFiles))
+	require.Equal(t, man2.RootObjectID(), man3.RootObjectID())
+}
+
+func TestUpload_DetectMovedFiles(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	t.Cleanup(th.cleanup)
+
+	pol := *policy.DefaultPolicy
+	pol.UploadPolicy.DetectMovedFiles = policy.NewOptionalBool(true)
+	policyTree := policy.BuildTree(nil, &pol)
+
+	src1 := mockfs.NewDirectory()
+	src1.AddDir("photos", defaultPermissions)
+	src1.AddFileDevice("photos/p1.jpg", []byte("photo 1"), defaultPermissions, fs.DeviceInfo{Dev: 1, Ino: 101})
+	src1.AddFileDevice("photos/p2.jpg", []byte("photo 2"), defaultPermissions, fs.DeviceInfo{Dev: 1, Ino: 102})
+	src1.AddFile("no-inode.txt", []byte("no inode"), defaultPermissions)
+
+	u := NewUploader(th.repo)
+
+	man1, err := u.Upload(ctx, src1, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+	require.Equal(t, int32(3), atomic.LoadInt32(&man1.Stats.NonCachedFiles))
+
+	oid1 := objectIDOfChild(ctx, t, th, man1, "photos", "p1.jpg")
+
+	// the directory was renamed, the content of p1.jpg is different to prove it was not read again.
+	src2 := mockfs.NewDirectory()
+	src2.AddDir("renamed", defaultPermissions)
+	src2.AddFileDevice("renamed/p1.jpg", []byte("PHOTO 1"), defaultPermissions, fs.DeviceInfo{Dev: 1, Ino: 101})
+	src2.AddFileDevice("renamed/p2.jpg", []byte("photo 2"), defaultPermissions, fs.DeviceInfo{Dev: 2, Ino: 102})
+	src2.AddFile("moved-no-inode.txt", []byte("no inode"), defaultPermissions)
+
+	man2, err := u.Upload(ctx, src2, policyTree, snapshot.SourceInfo{}, man1)
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&man2.Stats.CachedFiles), "CachedFiles")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&man2.Stats.MovedFiles), "MovedFiles")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&man2.Stats.NonCachedFiles), "NonCachedFiles")
+	assert.Equal(t, oid1, objectIDOfChild(ctx, t, th, man2, "renamed", "p1.jpg"))
+
+	// the identity of moved files is recorded again, so they can be found after moving them once more.
+	src3 := mockfs.NewDirectory()
+	src3.AddFileDevice("p1.jpg", []byte("PHOTO 1"), defaultPermissions, fs.DeviceInfo{Dev: 1, Ino: 101})
+
+	man3, err := u.Upload(ctx, src3, policyTree, snapshot.SourceInfo{}, man2)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&man3.Stats.MovedFiles), "MovedFiles")
+
+	// with the safety percentage of 100, moved files are always hashed again.
+	u.ForceHashMovedPercentage = 100
+
+	man4, err := u.Upload(ctx, src2, policyTree, snapshot.SourceInfo{}, man1)
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(0), atomic.LoadInt32(&man4.Stats.MovedFiles), "MovedFiles")
+	assert.Equal(t, int32(3), atomic.LoadInt32(&man4.Stats.NonCachedFiles), "NonCachedFiles")
+	assert.NotEqual(t, oid1, objectIDOfChild(ctx, t, th, man4, "renamed", "p1.jpg"))
+
+	// without the policy, the identity of files is neither recorded nor used.
+	u.ForceHashMovedPercentage = 0
+
+	man5, err := u.Upload(ctx, src1, policy.BuildTree(nil, policy.DefaultPolicy), snapshot.SourceInfo{})
+	require.NoError(t, err)
+
+	de := childDirEntry(ctx, t, th, man5, "photos", "p1.jpg")
+	assert.Zero(t, de.Inode)
+	assert.Zero(t, de.Device)
+
+	man6, err := u.Upload(ctx, src2, policyTree, snapshot.SourceInfo{}, man5)
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&man6.Stats.MovedFiles), "MovedFiles")
+
+	// files with the same inode, but different change time, are not the same file.
+	src7 := mockfs.NewDirectory()
+	src7.AddFileDevice("p1.jpg", []byte("PHOTO 1"), defaultPermissions, fs.DeviceInfo{Dev: 1, Ino: 101, Ctime: 1})
+
+	man7, err := u.Upload(ctx, src7, policyTree, snapshot.SourceInfo{}, man2)
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&man7.Stats.MovedFiles), "MovedFiles")
+}
+
+func TestUpload_MIMETypeRules(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	t.Cleanup(th.cleanup)
+
+	pol := *policy.DefaultPolicy
+	pol.FilesPolicy.AttributeRules = []string{"mime:application/pdf"}
+	policyTree := policy.BuildTree(nil, &pol)
+
+	src := mockfs.NewDirectory()
+	src.AddFile("doc.pdf", []byte("%PDF-1.4\n"), defaultPermissions)
+	src.AddFile("doc.txt", []byte("plain text"), defaultPermissions)
+
+	u := NewUploader(th.repo)
+
+	man1, err := u.Upload(ctx, src, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&man1.Stats.NonCachedFiles), "NonCachedFiles")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&man1.Stats.ExcludedFileCount), "ExcludedFileCount")
+
+	// files unchanged since the previous snapshot taken before the rules were added are excluded as well.
+	man2, err := u.Upload(ctx, src, policy.BuildTree(nil, policy.DefaultPolicy), snapshot.SourceInfo{})
+	require.NoError(t, err)
+
+	man3, err := u.Upload(ctx, src, policyTree, snapshot.SourceInfo{}, man2)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&man3.Stats.CachedFiles), "CachedFiles")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&man3.Stats.ExcludedFileCount), "ExcludedFileCount")
+
+	_, err = snapshotfs.EntryFromDirEntry(th.repo, man3.RootEntry).(fs.Directory).Child(ctx, "doc.pdf")
+	require.ErrorIs(t, err, fs.ErrEntryNotFound)
+}
+
+func childDirEntry(ctx context.Context, t *testing.T, th *uploadTestHarness, man *snapshot.Manifest, names ...string) *snapshot.DirEntry {
+	t.Helper()
+
+	e := snapshotfs.EntryFromDirEntry(th.repo, man.RootEntry)
+
+	for _, n := range names {
+		d, ok := e.(fs.Directory)
+		require.True(t, ok, "not a directory")
+
+		var err error
+
+		e, err = d.Child(ctx, n)
+		require.NoError(t, err)
+	}
+
+	return e.(snapshot.HasDirEntry).DirEntry()
+}
+
+func objectIDOfChild(ctx context.Context, t *testing.T, th *uploadTestHarness, man *snapshot.Manifest, names ...string) object.ID {
+	t.Helper()
+
+	return childDirEntry(ctx, t, th, man, names...).ObjectID
+}
+
+func TestUpload_DetectAnomalies(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	t.Cleanup(th.cleanup)
+
+	const numFiles = 20
+
+	pol := *policy.DefaultPolicy
+	pol.AnomalyDetection.Enabled = policy.NewOptionalBool(true)
+	pol.AnomalyDetection.MinFiles = newOptionalInt(numFiles)
+	policyTree := policy.BuildTree(nil, &pol)
+
+	text := bytes.Repeat([]byte("all work and no play makes jack a dull boy\n"), 200)
+
+	original := mockfs.NewDirectory()
+	encrypted := mockfs.NewDirectory()
+	renamed := mockfs.NewDirectory()
+
+	for i := range numFiles {
+		name := fmt.Sprintf("doc%v.txt", i)
+
+		random := make([]byte, 10000)
+		rand.Read(random)
+
+		original.AddFile(name, text, defaultPermissions)
+		encrypted.AddFile(name, random, defaultPermissions)
+		renamed.AddFile(name+".LOCKED", random, defaultPermissions)
+	}
+
+	u := NewUploader(th.repo)
+
+	man1, err := u.Upload(ctx, original, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+	require.Empty(t, man1.Anomalies(), "no anomalies without previous snapshots")
+
+	// unchanged files.
+	man2, err := u.Upload(ctx, original, policyTree, snapshot.SourceInfo{}, man1)
+	require.NoError(t, err)
+	require.Empty(t, man2.Anomalies())
+	require.Empty(t, man2.Tags[snapshot.AnomalyTag])
+
+	// files encrypted in place.
+	man3, err := u.Upload(ctx, encrypted, policyTree, snapshot.SourceInfo{}, man1)
+	require.NoError(t, err)
+	require.Equal(t, snapshot.AnomalyTagValue, man3.Tags[snapshot.AnomalyTag])
+	require.Equal(t, []string{snapshot.AnomalyEntropyIncrease, snapshot.AnomalyModifiedFiles}, anomalyKinds(man3))
+
+	// files encrypted and renamed with an extension appended.
+	man4, err := u.Upload(ctx, renamed, policyTree, snapshot.SourceInfo{}, man1)
+	require.NoError(t, err)
+	require.Equal(t, []string{snapshot.AnomalyExtensionChurn}, anomalyKinds(man4))
+	require.Contains(t, man4.Anomalies()[0].Description, `".locked"`)
+
+	// not enough files to be meaningful.
+	pol.AnomalyDetection.MinFiles = newOptionalInt(numFiles + 1)
+
+	man5, err := u.Upload(ctx, encrypted, policy.BuildTree(nil, &pol), snapshot.SourceInfo{}, man1)
+	require.NoError(t, err)
+	require.Empty(t, man5.Anomalies())
+
+	// threshold not reached.
+	pol.AnomalyDetection.MinFiles = newOptionalInt(numFiles)
+	pol.AnomalyDetection.ExtensionChurnPercent = newOptionalInt(100)
+
+	renamed.Remove("doc0.txt.LOCKED")
+	renamed.AddFile("doc0.txt", text, defaultPermissions)
+
+	man6, err := u.Upload(ctx, renamed, policy.BuildTree(nil, &pol), snapshot.SourceInfo{}, man1)
+	require.NoError(t, err)
+	require.Empty(t, man6.Anomalies())
+
+	// detection is disabled by default.
+	man7, err := u.Upload(ctx, encrypted, policy.BuildTree(nil, policy.DefaultPolicy), snapshot.SourceInfo{}, man1)
+	require.NoError(t, err)
+	require.Empty(t, man7.Anomalies())
+	require.Nil(t, man7.Tags)
+}
+
+func anomalyKinds(man *snapshot.Manifest) []string {
+	var result []string
+
+	for _, a := range man.Anomalies() {
+		result = append(result, a.Kind)
+	}
+
+	return result
+}
+
+func newOptionalInt(v int) *policy.OptionalInt {
+	i := policy.OptionalInt(v)
+	return &i
+}
+
+func TestByteEntropy(t *testing.T) {
+	random := make([]byte, entropySampleSize)
+	rand.Read(random)
+
+	assert.Zero(t, byteEntropy(nil))
+	assert.Zero(t, byteEntropy(bytes.Repeat([]byte{'a'}, 100)))
+	assert.InDelta(t, 1.0, byteEntropy([]byte("abababab")), 0.001)
+	assert.Less(t, byteEntropy([]byte(strings.Repeat("the quick brown fox jumps over the lazy dog ", 100))), compressibleEntropy)
+	assert.GreaterOrEqual(t, byteEntropy(random), incompressibleEntropy)
+}
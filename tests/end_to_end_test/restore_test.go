@@ -142,6 +142,19 @@ func TestRestoreCommand(t *testing.T) {
 	require.NoError(t, os.Chmod(restoreDir, 0o700))
 	compareDirs(t, source, restoreDir)
 
+	// Restore last snapshot fetching contents in pack order through a small spool
+	packOrderedRestoreDir := testutil.TempDirectory(t)
+	e.RunAndExpectSuccess(t, "restore", rootID, packOrderedRestoreDir, "--pack-ordered-reads", "--max-spool-size-mb=1", "--spool-dir", testutil.TempDirectory(t))
+	require.NoError(t, os.Chmod(packOrderedRestoreDir, 0o700))
+	compareDirs(t, source, packOrderedRestoreDir)
+
+	// Restore last snapshot using the journal, which is removed once the restore completes
+	resumableRestoreDir := testutil.TempDirectory(t)
+	e.RunAndExpectSuccess(t, "restore", rootID, resumableRestoreDir, "--resumable")
+	require.NoError(t, os.Chmod(resumableRestoreDir, 0o700))
+	compareDirs(t, source, resumableRestoreDir)
+	require.NoFileExists(t, restore.JournalPath(resumableRestoreDir))
+
 	// Attempt to restore into a target directory that already exists
 	e.RunAndExpectFailure(t, "restore", rootID, restoreDir, "--no-overwrite-directories")
 
@@ -467,6 +480,52 @@ func TestRestoreSymlinkWithoutTarget(t *testing.T) {
 	e.RunAndExpectSuccess(t, "snapshot", "restore", "--no-ignore-permission-errors", snapID, restoredDir)
 }
 
+func TestRestoreWithFilters(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	source := testutil.TempDirectory(t)
+
+	for _, name := range []string{"docs/report.docx", "docs/notes.txt", "docs/old/draft.docx", "photos/a.jpg"} {
+		fname := filepath.Join(source, filepath.FromSlash(name))
+
+		require.NoError(t, os.MkdirAll(filepath.Dir(fname), 0o700))
+		require.NoError(t, os.WriteFile(fname, []byte(name), 0o600))
+	}
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+
+	si := clitestutil.ListSnapshotsAndExpectSuccess(t, e, source)
+	require.Len(t, si, 1)
+	require.Len(t, si[0].Snapshots, 1)
+
+	snapID := si[0].Snapshots[0].SnapshotID
+
+	restoredDir := testutil.TempDirectory(t)
+	e.RunAndExpectSuccess(t, "snapshot", "restore", snapID, restoredDir, "--include=*.docx", "--exclude=old", "--map=docs=documents")
+
+	b, err := os.ReadFile(filepath.Join(restoredDir, "documents", "report.docx"))
+	require.NoError(t, err)
+	require.Equal(t, "docs/report.docx", string(b))
+
+	entries, err := os.ReadDir(restoredDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	entries, err = os.ReadDir(filepath.Join(restoredDir, "documents"))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	e.RunAndExpectFailure(t, "snapshot", "restore", snapID, testutil.TempDirectory(t), "--map=docs")
+	e.RunAndExpectFailure(t, "snapshot", "restore", snapID, testutil.TempDirectory(t), "--include=*.docx", "--delete-extra")
+}
+
 func TestRestoreSymlinkWithNonSymlinkOverwrite(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,46 @@
+package endtoend_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotAttributeRules(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	source := testutil.TempDirectory(t)
+
+	require.NoError(t, os.WriteFile(filepath.Join(source, "small.txt"), []byte("small"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(source, "large.bin"), bytes.Repeat([]byte{1}, 5000), 0o644))
+
+	e.RunAndExpectFailure(t, "policy", "set", source, "--add-attribute-rule", "size:large")
+	e.RunAndExpectSuccess(t, "policy", "set", source, "--add-attribute-rule", "size:1KB-")
+
+	lines := strings.Join(e.RunAndExpectSuccess(t, "policy", "show", source), "\n")
+	require.Contains(t, lines, "Ignore files matching attribute rules:")
+	require.Contains(t, lines, "    size:1KB-")
+
+	out := strings.Join(e.RunAndExpectSuccess(t, "snapshot", "estimate", source), "\n")
+	require.Contains(t, out, "Snapshot includes 1 file(s)")
+	require.Contains(t, out, " - large.bin - 5 KB\n   matched attribute rule \"size:1KB-\"")
+
+	e.RunAndExpectSuccess(t, "policy", "set", source, "--clear-attribute-rule")
+
+	out = strings.Join(e.RunAndExpectSuccess(t, "snapshot", "estimate", source), "\n")
+	require.Contains(t, out, "Snapshot includes 2 file(s)")
+}
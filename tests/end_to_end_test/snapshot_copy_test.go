@@ -0,0 +1,81 @@
+package endtoend_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/cli"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func (s *formatSpecificTestSuite) TestSnapshotCopy(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, s.formatFlags, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1, "--tags", "key1:value1")
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1, "--pin", "pin1")
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir2)
+
+	var sourceManifests []cli.SnapshotManifest
+
+	testutil.MustParseJSONLines(t, e.RunAndExpectSuccess(t, "snapshot", "list", "-a", "--json"), &sourceManifests)
+	require.Len(t, sourceManifests, 3)
+
+	for _, hash := range []string{"HMAC-SHA256", "BLAKE2B-256"} {
+		t.Run(hash, func(t *testing.T) {
+			dstenv := testenv.NewCLITest(t, s.formatFlags, runner)
+
+			dstenv.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", dstenv.RepoDir, "--block-hash", hash)
+
+			dstConfig := filepath.Join(dstenv.ConfigDir, ".kopia.config")
+
+			// either --all or snapshots to copy must be specified.
+			e.RunAndExpectFailure(t, "snapshot", "copy", "--to-repo", dstConfig)
+
+			e.RunAndExpectSuccess(t, "snapshot", "copy", "--to-repo", dstConfig, sharedTestDataDir1, "--latest-only")
+			require.Len(t, listSnapshotManifests(t, dstenv), 1)
+
+			e.RunAndExpectSuccess(t, "snapshot", "copy", "--to-repo", dstConfig, "--all")
+
+			// copying again does not create more snapshots.
+			e.RunAndExpectSuccess(t, "snapshot", "copy", "--to-repo", dstConfig, "--all")
+
+			dstManifests := listSnapshotManifests(t, dstenv)
+			require.Len(t, dstManifests, len(sourceManifests))
+
+			for i, want := range sourceManifests {
+				got := dstManifests[i]
+
+				require.Equal(t, want.Source, got.Source)
+				require.True(t, want.StartTime.Equal(got.StartTime))
+				require.True(t, want.EndTime.Equal(got.EndTime))
+				require.Equal(t, want.Tags, got.Tags)
+				require.Equal(t, want.Pins, got.Pins)
+			}
+
+			dstenv.RunAndExpectSuccess(t, "snapshot", "verify", "--verify-files-percent=100")
+
+			restoreDir := testutil.TempDirectory(t)
+			dstenv.RunAndExpectSuccess(t, "snapshot", "restore", string(dstManifests[2].ID), restoreDir)
+			compareDirs(t, sharedTestDataDir2, restoreDir)
+		})
+	}
+}
+
+func listSnapshotManifests(t *testing.T, e *testenv.CLITest) []cli.SnapshotManifest {
+	t.Helper()
+
+	var manifests []cli.SnapshotManifest
+
+	testutil.MustParseJSONLines(t, e.RunAndExpectSuccess(t, "snapshot", "list", "-a", "--json"), &manifests)
+
+	return manifests
+}
@@ -0,0 +1,74 @@
+package endtoend_test
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/clitestutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotExpandArchives(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	source := testutil.TempDirectory(t)
+
+	f, err := os.Create(filepath.Join(source, "backup.tar"))
+	require.NoError(t, err)
+
+	tw := tar.NewWriter(f)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "etc/hosts", Typeflag: tar.TypeReg, Mode: 0o644, Size: 9}))
+	_, err = tw.Write([]byte("localhost"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, f.Close())
+
+	e.RunAndExpectSuccess(t, "policy", "set", source, "--add-expand-archives", "*.tar")
+
+	lines := strings.Join(e.RunAndExpectSuccess(t, "policy", "show", source), "\n")
+	require.Contains(t, lines, "Expand archives:")
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+
+	si := clitestutil.ListSnapshotsAndExpectSuccess(t, e)
+	require.Len(t, si, 1)
+	require.Len(t, si[0].Snapshots, 1)
+
+	oid := si[0].Snapshots[0].ObjectID
+
+	// archive members can be browsed.
+	require.Equal(t, []string{"localhost"}, e.RunAndExpectSuccess(t, "show", oid+"/backup.tar/etc/hosts"))
+
+	// by default the original archive is restored.
+	restoreDir := testutil.TempDirectory(t)
+	e.RunAndExpectSuccess(t, "snapshot", "restore", oid, restoreDir)
+
+	original, err := os.ReadFile(filepath.Join(source, "backup.tar"))
+	require.NoError(t, err)
+
+	restored, err := os.ReadFile(filepath.Join(restoreDir, "backup.tar"))
+	require.NoError(t, err)
+	require.Equal(t, original, restored)
+
+	extractDir := testutil.TempDirectory(t)
+	e.RunAndExpectSuccess(t, "snapshot", "restore", "--extract-archives", oid, extractDir)
+
+	b, err := os.ReadFile(filepath.Join(extractDir, "backup.tar", "etc", "hosts"))
+	require.NoError(t, err)
+	require.Equal(t, "localhost", string(b))
+
+	e.RunAndExpectFailure(t, "policy", "set", source, "--add-expand-archives", "[")
+}
@@ -0,0 +1,76 @@
+package endtoend_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func (s *formatSpecificTestSuite) TestSnapshotExportImport(t *testing.T) {
+	t.Parallel()
+
+	const exportPassword = "export-password"
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, s.formatFlags, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1, "--tags", "key1:value1")
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir2)
+
+	sourceManifests := listSnapshotManifests(t, e)
+	require.Len(t, sourceManifests, 2)
+
+	archiveFile := filepath.Join(testutil.TempDirectory(t), "snapshots.kopia")
+
+	e.RunAndExpectFailure(t, "snapshot", "export", "--output", archiveFile, "--export-password", exportPassword)
+	e.RunAndExpectSuccess(t, "snapshot", "export", "--output", archiveFile, "--export-password", exportPassword, "--all")
+
+	// existing archive is not overwritten.
+	e.RunAndExpectFailure(t, "snapshot", "export", "--output", archiveFile, "--export-password", exportPassword, "--all")
+
+	// archive can be browsed and restored from directly.
+	browseenv := testenv.NewCLITest(t, s.formatFlags, runner)
+	browseenv.Environment["KOPIA_PASSWORD"] = exportPassword
+
+	browseenv.RunAndExpectFailure(t, "repo", "create", "archive", "--path", archiveFile)
+	browseenv.RunAndExpectSuccess(t, "repo", "connect", "archive", "--path", archiveFile)
+
+	archiveManifests := listSnapshotManifests(t, browseenv)
+	require.Len(t, archiveManifests, 2)
+	require.Equal(t, sourceManifests[0].Tags, archiveManifests[0].Tags)
+
+	restoreDir := testutil.TempDirectory(t)
+	browseenv.RunAndExpectSuccess(t, "snapshot", "restore", string(archiveManifests[1].ID), restoreDir)
+	compareDirs(t, sharedTestDataDir2, restoreDir)
+
+	browseenv.RunAndExpectFailure(t, "snapshot", "create", sharedTestDataDir1)
+	browseenv.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	// import into another repository.
+	dstenv := testenv.NewCLITest(t, s.formatFlags, runner)
+	dstenv.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", dstenv.RepoDir)
+
+	dstenv.RunAndExpectFailure(t, "snapshot", "import", archiveFile, "--export-password", "wrong-password")
+	dstenv.RunAndExpectSuccess(t, "snapshot", "import", archiveFile, "--export-password", exportPassword)
+	dstenv.RunAndExpectSuccess(t, "snapshot", "import", archiveFile, "--export-password", exportPassword)
+
+	dstManifests := listSnapshotManifests(t, dstenv)
+	require.Len(t, dstManifests, 2)
+
+	for i, want := range sourceManifests {
+		require.Equal(t, want.Source, dstManifests[i].Source)
+		require.True(t, want.StartTime.Equal(dstManifests[i].StartTime))
+		require.Equal(t, want.Tags, dstManifests[i].Tags)
+	}
+
+	restoreDir2 := testutil.TempDirectory(t)
+	dstenv.RunAndExpectSuccess(t, "snapshot", "restore", string(dstManifests[0].ID), restoreDir2)
+	compareDirs(t, sharedTestDataDir1, restoreDir2)
+}
@@ -0,0 +1,71 @@
+package endtoend_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/clitestutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotGitIgnoreAndIncludeRules(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	source := testutil.TempDirectory(t)
+
+	require.NoError(t, os.Mkdir(filepath.Join(source, "docs"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(source, ".gitignore"), []byte("build-*\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(source, "notes.txt"), []byte("notes"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(source, "build-1.txt"), []byte("build"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(source, "image.png"), []byte("image"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(source, "docs", "readme.txt"), []byte("readme"), 0o644))
+
+	e.RunAndExpectSuccess(t, "policy", "set", source, "--git-ignore", "true", "--add-include", "*.txt")
+
+	lines := strings.Join(e.RunAndExpectSuccess(t, "policy", "show", source), "\n")
+	require.Contains(t, lines, "Respect .gitignore files:")
+	require.Contains(t, lines, "Include only:")
+
+	out := strings.Join(e.RunAndExpectSuccess(t, "snapshot", "estimate", source), "\n")
+	require.Contains(t, out, "Snapshot includes 2 file(s)")
+	require.Contains(t, out, " - build-1.txt - 5 B\n   ignored by rule \"build-*\" from ./.gitignore")
+	require.Contains(t, out, " - image.png - 5 B\n   not matched by any include rule")
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+
+	si := clitestutil.ListSnapshotsAndExpectSuccess(t, e)
+	require.Len(t, si, 1)
+	require.Len(t, si[0].Snapshots, 1)
+
+	oid := si[0].Snapshots[0].ObjectID
+
+	require.ElementsMatch(t, []string{"docs", "notes.txt"}, e.RunAndExpectSuccess(t, "ls", oid))
+	require.Equal(t, []string{"readme.txt"}, e.RunAndExpectSuccess(t, "ls", oid+"/docs"))
+
+	e.RunAndExpectSuccess(t, "policy", "set", source, "--clear-include", "--git-ignore", "inherit")
+	require.ElementsMatch(t, []string{".gitignore", "build-1.txt", "docs", "image.png", "notes.txt"}, lsAfterSnapshot(t, e, source))
+}
+
+// lsAfterSnapshot creates a snapshot of the source and returns names of entries in its root directory.
+func lsAfterSnapshot(t *testing.T, e *testenv.CLITest, source string) []string {
+	t.Helper()
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+
+	si := clitestutil.ListSnapshotsAndExpectSuccess(t, e, source)
+	snapshots := si[0].Snapshots
+
+	return e.RunAndExpectSuccess(t, "ls", snapshots[len(snapshots)-1].ObjectID)
+}
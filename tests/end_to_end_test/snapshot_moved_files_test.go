@@ -0,0 +1,54 @@
+package endtoend_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotDetectMovedFiles(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("inode numbers are not available on Windows")
+	}
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	source := testutil.TempDirectory(t)
+
+	require.NoError(t, os.Mkdir(filepath.Join(source, "photos"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(source, "photos", "p1.jpg"), bytes.Repeat([]byte{1}, 5000), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(source, "photos", "p2.jpg"), bytes.Repeat([]byte{2}, 5000), 0o644))
+
+	e.RunAndExpectSuccess(t, "policy", "set", source, "--detect-moved-files=true")
+
+	lines := strings.Join(e.RunAndExpectSuccess(t, "policy", "show", source), "\n")
+	require.Contains(t, lines, "Detect moved files:")
+
+	var man1, man2 snapshot.Manifest
+
+	testutil.MustParseJSONLines(t, e.RunAndExpectSuccess(t, "snapshot", "create", source, "--json", "--json-verbose"), &man1)
+	require.Equal(t, int32(2), man1.Stats.NonCachedFiles)
+
+	require.NoError(t, os.Rename(filepath.Join(source, "photos"), filepath.Join(source, "renamed")))
+
+	testutil.MustParseJSONLines(t, e.RunAndExpectSuccess(t, "snapshot", "create", source, "--json", "--json-verbose"), &man2)
+	require.Equal(t, int32(2), man2.Stats.CachedFiles)
+	require.Equal(t, int32(2), man2.Stats.MovedFiles)
+	require.Equal(t, int32(0), man2.Stats.NonCachedFiles)
+}
@@ -0,0 +1,60 @@
+package endtoend_test
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/webdav"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/clitestutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotCreateFromWebDAVSource(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	td := testutil.TempDirectory(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(td, "docs", "sub"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(td, "docs", "sub", "file.txt"), []byte("remote content"), 0o644))
+
+	server := httptest.NewServer(&webdav.Handler{
+		FileSystem: webdav.Dir(td),
+		LockSystem: webdav.NewMemLS(),
+	})
+	defer server.Close()
+
+	source := "webdav://" + strings.TrimPrefix(server.URL, "http://") + "/docs"
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+
+	si := clitestutil.ListSnapshotsAndExpectSuccess(t, e, "--all")
+	require.Len(t, si, 1)
+	require.Equal(t, "127.0.0.1", si[0].Host)
+	require.Equal(t, "/docs", si[0].Path)
+	require.Len(t, si[0].Snapshots, 2)
+
+	// both snapshots have the same contents.
+	require.Equal(t, si[0].Snapshots[0].ObjectID, si[0].Snapshots[1].ObjectID)
+
+	restoreDir := testutil.TempDirectory(t)
+	e.RunAndExpectSuccess(t, "snapshot", "restore", si[0].Snapshots[0].ObjectID, restoreDir)
+
+	b, err := os.ReadFile(filepath.Join(restoreDir, "sub", "file.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "remote content", string(b))
+
+	e.RunAndExpectFailure(t, "snapshot", "create", "webdav://"+strings.TrimPrefix(server.URL, "http://")+"/missing")
+}
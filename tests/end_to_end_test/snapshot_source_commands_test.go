@@ -0,0 +1,75 @@
+package endtoend_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/clitestutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotCreateFromSourceCommands(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("requires unix shell")
+	}
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	// the source does not need to exist locally.
+	source := filepath.Join(testutil.TempDirectory(t), "dumps")
+
+	e.RunAndExpectSuccess(t, "policy", "set", source,
+		"--add-source-command", `db.sql=sh -c "echo database dump"`,
+		"--add-source-command", `vm.xml=sh -c "echo '<domain/>'"`)
+
+	lines := strings.Join(e.RunAndExpectSuccess(t, "policy", "show", source), "\n")
+	require.Contains(t, lines, "Snapshot output of commands:")
+	require.Contains(t, lines, `"sh" "-c" "echo database dump"`)
+
+	// source commands are not run unless actions are enabled.
+	_, stderr := e.RunAndExpectFailure(t, "snapshot", "create", source)
+	require.Contains(t, strings.Join(stderr, "\n"), "source commands require actions to be enabled")
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", source, "--force-enable-actions")
+
+	si := clitestutil.ListSnapshotsAndExpectSuccess(t, e)
+	require.Len(t, si, 1)
+	require.Len(t, si[0].Snapshots, 1)
+
+	restoreDir := testutil.TempDirectory(t)
+	e.RunAndExpectSuccess(t, "snapshot", "restore", si[0].Snapshots[0].ObjectID, restoreDir)
+
+	b, err := os.ReadFile(filepath.Join(restoreDir, "db.sql"))
+	require.NoError(t, err)
+	require.Equal(t, "database dump\n", string(b))
+
+	b, err = os.ReadFile(filepath.Join(restoreDir, "vm.xml"))
+	require.NoError(t, err)
+	require.Equal(t, "<domain/>\n", string(b))
+
+	// failing command fails the snapshot and reports its standard error.
+	e.RunAndExpectSuccess(t, "policy", "set", source,
+		"--add-source-command", `db.sql=sh -c "echo connection refused >&2; exit 2"`)
+
+	_, stderr = e.RunAndExpectFailure(t, "snapshot", "create", source, "--force-enable-actions")
+	require.Contains(t, strings.Join(stderr, "\n"), "connection refused")
+
+	si = clitestutil.ListSnapshotsAndExpectSuccess(t, e)
+	require.Len(t, si[0].Snapshots, 1)
+
+	e.RunAndExpectSuccess(t, "policy", "set", source, "--clear-source-commands")
+	e.RunAndExpectFailure(t, "snapshot", "create", source)
+}
@@ -0,0 +1,48 @@
+package endtoend_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotVerifySource(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	source := testutil.TempDirectory(t)
+	fname := filepath.Join(source, "file.txt")
+	mtime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	writeFile := func(data string) {
+		require.NoError(t, os.WriteFile(fname, []byte(data), 0o644))
+		require.NoError(t, os.Chtimes(fname, mtime, mtime))
+	}
+
+	writeFile("some contents")
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+	e.RunAndExpectSuccess(t, "snapshot", "verify", "--verify-source")
+
+	// change the contents without changing the size or modification time.
+	writeFile("same contents")
+
+	out, _ := e.RunAndExpectFailure(t, "snapshot", "verify", "--verify-source")
+	require.Contains(t, strings.Join(out, "\n"), "Probable bit rot: file.txt")
+
+	// the file is not re-hashed when sampling 0 percent.
+	e.RunAndExpectSuccess(t, "snapshot", "verify", "--verify-source", "--verify-source-percent=0")
+}